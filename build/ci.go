@@ -25,6 +25,7 @@ Available commands are:
 
    install    [ -arch architecture ] [ -cc compiler ] [ packages... ]                          -- builds packages and executables
    test       [ -coverage ] [ packages... ]                                                    -- runs the tests
+   firehose-module                                                                             -- builds and tests the firehose module outside of the repository
    lint                                                                                        -- runs certain pre-selected linters
    archive    [ -arch architecture ] [ -type zip|tar ] [ -signer key-envvar ] [ -signify key-envvar ] [ -upload dest ] -- archives build artifacts
    importkeys                                                                                  -- imports signing keys from env
@@ -44,6 +45,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -178,6 +180,8 @@ func main() {
 		doInstall(os.Args[2:])
 	case "test":
 		doTest(os.Args[2:])
+	case "firehose-module":
+		doFirehoseModule(os.Args[2:])
 	case "lint":
 		doLint(os.Args[2:])
 	case "archive":
@@ -340,24 +344,87 @@ func doTest(cmdline []string) {
 	env := build.Env()
 
 	packages := []string{"./..."}
+	// The Firehose instrumentation and its tests against the node are modules of their own,
+	// not matched by `./...`
+	modules := []string{".", "./firehose", "./firehose/itest"}
 	if len(flag.CommandLine.Args()) > 0 {
 		packages = flag.CommandLine.Args()
+		modules = []string{"."}
+	}
+
+	for _, module := range modules {
+		// Run the actual tests.
+		// Test a single package at a time. CI builders are slow
+		// and some tests run into timeouts under load.
+		gotest := goTool("test", buildFlags(env)...)
+		gotest.Dir = module
+		gotest.Args = append(gotest.Args, "-p", "1")
+		if *coverage {
+			gotest.Args = append(gotest.Args, "-covermode=atomic", "-cover")
+		}
+		if *verbose {
+			gotest.Args = append(gotest.Args, "-v")
+		}
+
+		gotest.Args = append(gotest.Args, packages...)
+		build.MustRun(gotest)
 	}
+}
 
-	// Run the actual tests.
-	// Test a single package at a time. CI builders are slow
-	// and some tests run into timeouts under load.
-	gotest := goTool("test", buildFlags(env)...)
-	gotest.Args = append(gotest.Args, "-p", "1")
-	if *coverage {
-		gotest.Args = append(gotest.Args, "-covermode=atomic", "-cover")
+// doFirehoseModule builds, vets and tests a copy of the firehose module outside of the
+// repository, the way external readers and tooling import it: resolved from its own go.mod
+// only, without any `replace` directive pointing back to this tree.
+func doFirehoseModule(cmdline []string) {
+	flag.CommandLine.Parse(cmdline)
+
+	edit := goTool("mod", "edit", "-json")
+	edit.Dir = "firehose"
+	out, err := edit.Output()
+	if err != nil {
+		log.Fatalf("firehose go.mod: %v", err)
 	}
-	if *verbose {
-		gotest.Args = append(gotest.Args, "-v")
+	var modfile struct{ Replace []json.RawMessage }
+	if err := json.Unmarshal(out, &modfile); err != nil {
+		log.Fatalf("firehose go.mod: %v", err)
 	}
+	if len(modfile.Replace) > 0 {
+		log.Fatal("firehose go.mod must not have replace directives, external importers ignore them")
+	}
+
+	workdir, err := ioutil.TempDir("", "firehose-module-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(workdir)
 
-	gotest.Args = append(gotest.Args, packages...)
-	build.MustRun(gotest)
+	// The integration tests run against this tree, they are a module of their own
+	err = filepath.Walk("firehose", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel("firehose", path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel == "itest" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(workdir, rel), 0755)
+		}
+		return cp.CopyFile(filepath.Join(workdir, rel), path)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, args := range [][]string{{"build", "./..."}, {"vet", "./..."}, {"test", "./..."}} {
+		gocmd := goTool(args[0], args[1:]...)
+		gocmd.Dir = workdir
+		// The copy's go.sum must be complete, nothing is resolved from a workspace either
+		gocmd.Env = append(gocmd.Env, "GOFLAGS=-mod=readonly", "GOWORK=off")
+		build.MustRun(gocmd)
+	}
 }
 
 // doLint runs golangci-lint on requested packages.
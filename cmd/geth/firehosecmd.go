@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/decode"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	firehoseInspectJSONFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the output as JSON instead of human-readable text",
+	}
+	firehoseImportEraDirFlag = cli.StringFlag{
+		Name:  "dir",
+		Usage: "Directory holding the era1 or RLP export files to replay",
+	}
+
+	firehoseCommand = cli.Command{
+		Name:      "firehose",
+		Usage:     "Firehose instrumentation tooling",
+		ArgsUsage: "",
+		Category:  "FIREHOSE COMMANDS",
+		Subcommands: []cli.Command{
+			firehoseInspectCommand,
+			firehoseReasonsCommand,
+			firehoseImportEraCommand,
+		},
+	}
+	firehoseInspectCommand = cli.Command{
+		Action:    utils.MigrateFlags(firehoseInspect),
+		Name:      "inspect",
+		Usage:     "Validate and pretty-print a captured Firehose stream",
+		ArgsUsage: "<file>",
+		Flags: []cli.Flag{
+			firehoseInspectJSONFlag,
+		},
+		Description: `
+The inspect command reads a Firehose stream captured from Geth standard output ('-' reads
+from standard input), validates its framing and prints a human-readable tree of blocks,
+transactions and nested calls along with their gas and value. Use --json to print the
+decoded elements as JSON instead.`,
+	}
+	firehoseReasonsCommand = cli.Command{
+		Action:    utils.MigrateFlags(firehoseReasons),
+		Name:      "reasons",
+		Usage:     "List the registered balance and gas change reasons",
+		ArgsUsage: "",
+		Flags: []cli.Flag{
+			firehoseInspectJSONFlag,
+		},
+		Description: `
+The reasons command lists all the balance and gas change reasons that can be found in
+Firehose BALANCE_CHANGE and GAS_CHANGE records. Use --json to print them as a JSON object.`,
+	}
+	firehoseImportEraCommand = cli.Command{
+		Action:    utils.MigrateFlags(firehoseImportEra),
+		Name:      "import-era",
+		Usage:     "Replay archived history files into the Firehose stream",
+		ArgsUsage: "",
+		Flags: []cli.Flag{
+			firehoseImportEraDirFlag,
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.GCModeFlag,
+			utils.SnapshotFlag,
+			utils.CacheDatabaseFlag,
+			utils.CacheGCFlag,
+			utils.TxLookupLimitFlag,
+		},
+		Description: `
+The import-era command replays the blocks of the era1 files (.era1) and RLP export files
+(.rlp, .rlp.gz) found in --dir, in the order of their names, into the Firehose stream,
+regenerating the history without syncing it from the network. Firehose instrumentation
+must be enabled.
+
+Blocks whose parent state is available, the history being imported from the genesis into
+a fresh data directory, are executed. Otherwise, they are verified against their parent
+and their receipts, only held by era1 files, and emitted as non-executed blocks.`,
+	}
+)
+
+func firehoseInspect(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+
+	var reader io.Reader = os.Stdin
+	if path := ctx.Args().First(); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	return inspectFirehoseStream(reader, os.Stdout, ctx.Bool(firehoseInspectJSONFlag.Name))
+}
+
+func firehoseImportEra(ctx *cli.Context) error {
+	dir := ctx.String(firehoseImportEraDirFlag.Name)
+	if dir == "" {
+		utils.Fatalf("This command requires the --%s flag.", firehoseImportEraDirFlag.Name)
+	}
+
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, false)
+	defer db.Close()
+	defer chain.Stop()
+
+	start := time.Now()
+	if err := utils.ImportFirehoseHistory(chain, dir); err != nil {
+		return err
+	}
+	log.Info("Replayed history into Firehose", "elapsed", common.PrettyDuration(time.Since(start)))
+
+	return nil
+}
+
+func firehoseReasons(ctx *cli.Context) error {
+	return printFirehoseReasons(os.Stdout, ctx.Bool(firehoseInspectJSONFlag.Name))
+}
+
+func printFirehoseReasons(writer io.Writer, jsonOutput bool) error {
+	balanceReasons, gasReasons := firehose.AllBalanceChangeReasons(), firehose.AllGasChangeReasons()
+
+	if jsonOutput {
+		return json.NewEncoder(writer).Encode(map[string]interface{}{
+			"balanceChangeReasons": balanceReasons,
+			"gasChangeReasons":     gasReasons,
+		})
+	}
+
+	fmt.Fprintln(writer, "Balance change reasons:")
+	for _, reason := range balanceReasons {
+		fmt.Fprintf(writer, "  %s\n", reason)
+	}
+
+	fmt.Fprintln(writer, "Gas change reasons:")
+	for _, reason := range gasReasons {
+		fmt.Fprintf(writer, "  %s\n", reason)
+	}
+
+	return nil
+}
+
+// inspectFirehoseStream decodes the Firehose stream read from `reader` and prints each decoded
+// element to `writer`, stopping at the first decoding or framing error.
+func inspectFirehoseStream(reader io.Reader, writer io.Writer, jsonOutput bool) error {
+	decoder := decode.NewDecoder(reader)
+	encoder := json.NewEncoder(writer)
+
+	blockCount, trxCount, callCount := 0, 0, 0
+	for {
+		element, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid firehose stream after %d valid block(s): %w", blockCount, err)
+		}
+
+		if block, ok := element.(*decode.Block); ok {
+			blockCount++
+			trxCount += len(block.Transactions)
+			for _, trx := range block.Transactions {
+				callCount += len(trx.Calls)
+			}
+		}
+
+		if jsonOutput {
+			if err := encoder.Encode(firehoseJSONElement(element)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		printFirehoseElement(writer, element)
+	}
+
+	if !jsonOutput {
+		fmt.Fprintf(writer, "Validated %d block(s), %d transaction(s) and %d call(s)\n", blockCount, trxCount, callCount)
+	}
+
+	return nil
+}
+
+func firehoseJSONElement(element interface{}) map[string]interface{} {
+	switch v := element.(type) {
+	case *decode.Init:
+		return map[string]interface{}{"init": v}
+	case *decode.Block:
+		return map[string]interface{}{"block": v}
+	case *decode.TransactionAbort:
+		return map[string]interface{}{"transactionAbort": v}
+	case *decode.HeadUpdate:
+		return map[string]interface{}{"headUpdate": v}
+	case *decode.SideChainBlock:
+		return map[string]interface{}{"sideChainBlock": v}
+	case *decode.UndoBlock:
+		return map[string]interface{}{"undoBlock": v}
+	case *decode.Heartbeat:
+		return map[string]interface{}{"heartbeat": v}
+	case *decode.PendingTransaction:
+		return map[string]interface{}{"pendingTransaction": v}
+	case *decode.PendingDrop:
+		return map[string]interface{}{"pendingDrop": v}
+	case *decode.PendingEffects:
+		return map[string]interface{}{"pendingEffects": v}
+	}
+
+	panic(fmt.Errorf("unhandled firehose element %T", element))
+}
+
+func printFirehoseElement(writer io.Writer, element interface{}) {
+	switch v := element.(type) {
+	case *decode.Init:
+		chainConfig := "unknown"
+		if v.ChainConfig != nil {
+			chainConfig = v.ChainConfig.String()
+		}
+
+		fmt.Fprintf(writer, "Init protocol %s, variant %s, node %s, chain config %s\n", v.ProtocolVersion, v.Variant, v.NodeVersion, chainConfig)
+
+	case *decode.TransactionAbort:
+		fmt.Fprintf(writer, "Transaction #%d %s of block #%d (%s) aborted: %s\n", v.TxIndex, v.TxHash.Hex(), v.BlockNumber, v.BlockHash.Hex(), v.Message)
+
+	case *decode.HeadUpdate:
+		fmt.Fprintf(writer, "Head moved to block #%d %s from block #%d %s\n", v.Number, v.Hash.Hex(), v.PreviousNumber, v.PreviousHash.Hex())
+
+	case *decode.SideChainBlock:
+		fmt.Fprintf(writer, "Block #%d %s is on a side chain forking from block #%d %s\n", v.Number, v.Hash.Hex(), v.ForkParentNumber, v.ForkParentHash.Hex())
+
+	case *decode.UndoBlock:
+		fmt.Fprintf(writer, "Block #%d %s undone, back to block #%d %s, %d log(s) removed\n", v.Number, v.Hash.Hex(), v.Number-1, v.ParentHash.Hex(), len(v.Logs))
+
+	case *decode.Heartbeat:
+		fmt.Fprintf(writer, "Heartbeat at %s, head block #%d %s\n", time.Unix(int64(v.Timestamp), 0).UTC().Format(time.RFC3339), v.HeadNumber, v.HeadHash.Hex())
+
+	case *decode.PendingTransaction:
+		to := "<contract creation>"
+		if v.To != nil {
+			to = v.To.Hex()
+		}
+
+		fmt.Fprintf(writer, "Pending trx %s %s -> %s, nonce %d, gas price %s, gas limit %d\n", v.Hash.Hex(), v.From.Hex(), to, v.Nonce, v.GasPrice, v.GasLimit)
+
+	case *decode.PendingDrop:
+		fmt.Fprintf(writer, "Pending trx %s dropped: %s\n", v.Hash.Hex(), v.Reason)
+
+	case *decode.PendingEffects:
+		fmt.Fprintf(writer, "Pending trx %s on top of block #%d %s %s, gas used %d, %d log(s), %d transfer(s)\n", v.Hash.Hex(), v.HeadNumber, v.HeadHash.Hex(), v.Status, v.GasUsed, len(v.Logs), len(v.Transfers))
+
+	case *decode.Block:
+		status := ""
+		if v.NonExecuted {
+			status = " [not executed]"
+		} else if v.Backfilled {
+			status = " [backfilled]"
+		} else if v.Proposed {
+			status = " [proposed]"
+		}
+
+		fmt.Fprintf(writer, "Block #%d %s, %d transaction(s), gas used %d/%d%s%s\n", v.Number, v.Header.Hash().Hex(), len(v.Transactions), v.Header.GasUsed, v.Header.GasLimit, formatFirehoseDuration(v.Duration), status)
+
+		for _, fork := range v.ForkActivations {
+			fmt.Fprintf(writer, "  Fork %s activated\n", fork.Name)
+		}
+
+		if v.Witness != nil {
+			fmt.Fprintf(writer, "  Witness of %d trie node(s) and %d code(s)\n", len(v.Witness.Nodes), len(v.Witness.Codes))
+		}
+
+		for _, trx := range v.Transactions {
+			to := "<contract creation>"
+			if trx.To != nil {
+				to = trx.To.Hex()
+			}
+
+			fmt.Fprintf(writer, "  Trx #%d %s %s -> %s, value %s, gas used %d/%d%s\n", trx.Index, trx.Hash.Hex(), trx.From.Hex(), to, trx.Value, trx.GasUsed, trx.GasLimit, formatFirehoseDuration(trx.Duration))
+			for _, call := range trx.Calls {
+				fmt.Fprintf(writer, "    %s%s\n", strings.Repeat("  ", int(call.Depth)), formatFirehoseCall(call))
+			}
+		}
+
+		for _, change := range v.BalanceChanges {
+			fmt.Fprintf(writer, "  Balance %s %s -> %s (%s)\n", change.Address.Hex(), change.Old, change.New, change.Reason)
+		}
+	}
+}
+
+// formatFirehoseDuration formats the wall-clock duration of a block or a transaction, it's
+// empty when the duration was not measured (timings disabled).
+func formatFirehoseDuration(duration time.Duration) string {
+	if duration == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(", took %s", duration)
+}
+
+func formatFirehoseCall(call *decode.Call) string {
+	kind := string(call.CallType)
+	if call.OpCode != "" && call.OpCode != kind {
+		kind += " (" + call.OpCode + ")"
+	}
+
+	value := call.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	out := fmt.Sprintf("#%d %s %s -> %s, value %s, gas used %d/%d", call.Index, kind, call.Caller.Hex(), call.Address.Hex(), value, call.GasLimit-call.GasLeft, call.GasLimit)
+	if call.DelegateCaller != nil {
+		out += fmt.Sprintf(", delegate caller %s", call.DelegateCaller.Hex())
+	}
+	if call.Precompile {
+		name := call.PrecompileName
+		if name == "" {
+			name = "unknown"
+		}
+		out += fmt.Sprintf(", precompile %s", name)
+	}
+
+	var status []string
+	if call.Failed {
+		status = append(status, "failed: "+call.FailureReason)
+	}
+	if call.Reverted {
+		status = append(status, "reverted")
+	}
+	if !call.ExecutedCode {
+		status = append(status, "no code")
+	}
+	if len(status) > 0 {
+		out += " [" + strings.Join(status, ", ") + "]"
+	}
+
+	return out
+}
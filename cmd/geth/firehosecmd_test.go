@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectFirehoseStream(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "firehose", "testdata", "golden", "nested_revert.golden"))
+	require.NoError(t, err)
+
+	output := bytes.NewBuffer(nil)
+	require.NoError(t, inspectFirehoseStream(bytes.NewReader(content), output, false))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 6)
+	assert.True(t, strings.HasPrefix(lines[0], "Block #1 "))
+	assert.True(t, strings.HasPrefix(lines[1], "  Trx #0 "))
+	assert.Contains(t, lines[2], "    #1 CALL ")
+	assert.Contains(t, lines[3], "      #2 CALL ")
+	assert.Contains(t, lines[3], "[failed: execution reverted, reverted]")
+	assert.Contains(t, lines[4], "(reward_mine_block)")
+	assert.Equal(t, "Validated 1 block(s), 1 transaction(s) and 2 call(s)", lines[5])
+
+	output.Reset()
+	require.NoError(t, inspectFirehoseStream(bytes.NewReader(content), output, true))
+
+	var element map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(output.Bytes(), &element))
+	assert.Contains(t, element, "block")
+
+	truncated := content[:bytes.Index(content, []byte("FIRE END_BLOCK"))]
+	assert.Error(t, inspectFirehoseStream(bytes.NewReader(truncated), output, false))
+}
+
+func TestPrintFirehoseReasons(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	require.NoError(t, printFirehoseReasons(output, false))
+	assert.Contains(t, output.String(), "Balance change reasons:\n")
+	assert.Contains(t, output.String(), "  reward_mine_block\n")
+	assert.Contains(t, output.String(), "  intrinsic_gas\n")
+
+	output.Reset()
+	require.NoError(t, printFirehoseReasons(output, true))
+
+	var reasons map[string][]string
+	require.NoError(t, json.Unmarshal(output.Bytes(), &reasons))
+	assert.Contains(t, reasons["balanceChangeReasons"], "gas_buy")
+	assert.Contains(t, reasons["gasChangeReasons"], "refund_after_execution")
+}
@@ -139,6 +139,7 @@ var (
 		utils.MainnetFlag,
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
+		utils.DeveloperSeedFlag,
 		utils.RopstenFlag,
 		utils.RinkebyFlag,
 		utils.GoerliFlag,
@@ -238,6 +239,8 @@ func init() {
 		utils.ShowDeprecated,
 		// See snapshot.go
 		snapshotCommand,
+		// See firehosecmd.go
+		firehoseCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
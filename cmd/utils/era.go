@@ -0,0 +1,362 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/golang/snappy"
+)
+
+// The e2store entry types of an era1 file, see https://github.com/eth-clients/e2store-format-specs
+const (
+	eraVersionEntry            = 0x3265
+	eraCompressedHeaderEntry   = 0x03
+	eraCompressedBodyEntry     = 0x04
+	eraCompressedReceiptsEntry = 0x05
+	eraTotalDifficultyEntry    = 0x06
+	eraAccumulatorEntry        = 0x07
+	eraBlockIndexEntry         = 0x3266
+)
+
+// eraBlock is a block read from a history file along with its receipts and total difficulty,
+// both nil when the file does not hold them (an RLP export file)
+type eraBlock struct {
+	block           *types.Block
+	receipts        types.Receipts
+	totalDifficulty *big.Int
+}
+
+// eraReader reads the blocks of an era1 file, an e2store file holding for each block its
+// snappy compressed header, body and receipts followed by its total difficulty. The
+// accumulator and the block index closing the file are skipped, the blocks being read in
+// order.
+type eraReader struct {
+	reader *bufio.Reader
+
+	header   *types.Header
+	body     *types.Body
+	receipts types.Receipts
+}
+
+func newEraReader(reader io.Reader) *eraReader {
+	return &eraReader{reader: bufio.NewReader(reader)}
+}
+
+// Next returns the next block of the file, io.EOF once all of them were read.
+func (r *eraReader) Next() (*eraBlock, error) {
+	for {
+		typ, value, err := r.readEntry()
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case eraCompressedHeaderEntry:
+			r.header = new(types.Header)
+			err = decodeSnappyRLP(value, r.header)
+		case eraCompressedBodyEntry:
+			r.body = new(types.Body)
+			err = decodeSnappyRLP(value, r.body)
+		case eraCompressedReceiptsEntry:
+			r.receipts = nil
+			err = decodeSnappyRLP(value, &r.receipts)
+		case eraTotalDifficultyEntry:
+			return r.completeBlock(value)
+		case eraVersionEntry, eraAccumulatorEntry, eraBlockIndexEntry:
+		default:
+			log.Debug("Skipping unknown era entry", "type", typ, "size", len(value))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("era entry of type %#x: %w", typ, err)
+		}
+	}
+}
+
+// completeBlock returns the block whose entries were read, `totalDifficulty` being the value
+// of its total difficulty entry closing them, a little-endian 256 bits integer.
+func (r *eraReader) completeBlock(totalDifficulty []byte) (*eraBlock, error) {
+	if r.header == nil || r.body == nil {
+		return nil, errors.New("era total difficulty entry without a preceding header and body")
+	}
+
+	td := make([]byte, len(totalDifficulty))
+	for i, b := range totalDifficulty {
+		td[len(td)-1-i] = b
+	}
+
+	block := &eraBlock{
+		block:           types.NewBlockWithHeader(r.header).WithBody(r.body.Transactions, r.body.Uncles),
+		receipts:        r.receipts,
+		totalDifficulty: new(big.Int).SetBytes(td),
+	}
+	r.header, r.body, r.receipts = nil, nil, nil
+
+	return block, nil
+}
+
+// readEntry reads an e2store entry, an 8 bytes header (a 2 bytes type, a 4 bytes length and
+// 2 reserved bytes, little-endian) followed by its value.
+func (r *eraReader) readEntry() (uint16, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, errors.New("truncated era entry header")
+		}
+		return 0, nil, err
+	}
+	if header[6] != 0 || header[7] != 0 {
+		return 0, nil, errors.New("era entry reserved bytes are not zero")
+	}
+
+	value := make([]byte, binary.LittleEndian.Uint32(header[2:6]))
+	if _, err := io.ReadFull(r.reader, value); err != nil {
+		return 0, nil, fmt.Errorf("truncated era entry value: %w", err)
+	}
+
+	return binary.LittleEndian.Uint16(header[0:2]), value, nil
+}
+
+func decodeSnappyRLP(value []byte, out interface{}) error {
+	return rlp.Decode(snappy.NewReader(bytes.NewReader(value)), out)
+}
+
+// exportReader reads the blocks of an RLP export file, as written by `geth export`, which
+// holds neither the receipts nor the total difficulty of the blocks.
+type exportReader struct {
+	stream *rlp.Stream
+}
+
+func (r *exportReader) Next() (*eraBlock, error) {
+	block := new(types.Block)
+	if err := r.stream.Decode(block); err != nil {
+		return nil, err
+	}
+
+	return &eraBlock{block: block}, nil
+}
+
+// historyFiles returns the history files of `dir` in the order of their names, era1 files
+// (`.era1`) and RLP export files (`.rlp`, optionally gzipped), the other files being skipped.
+func historyFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(name, ".era1") && !strings.HasSuffix(name, ".rlp") && !strings.HasSuffix(name, ".rlp.gz") {
+			log.Debug("Skipping non history file", "file", name)
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// ImportFirehoseHistory replays the blocks of the era1 and RLP export files of `dir`, in the
+// order of their names, into the Firehose stream.
+//
+// A block whose parent state is available (the chain being imported from its genesis into a
+// fresh database) is executed by inserting it into `chain`, emitting it like any block
+// imported. Otherwise, the block is verified without being executed, against its parent and
+// its receipts, only available in era1 files, and emitted as a non-executed block. Blocks
+// already in `chain` are skipped, they were emitted when imported.
+func ImportFirehoseHistory(chain *core.BlockChain, dir string) error {
+	if !firehose.Enabled {
+		return errors.New("firehose instrumentation is not enabled, use --firehose-enabled")
+	}
+
+	files, err := historyFiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no era1 nor RLP export file found in %q", dir)
+	}
+
+	importer := &historyImporter{chain: chain}
+	for _, file := range files {
+		if err := importer.importFile(file); err != nil {
+			return fmt.Errorf("history file %q: %w", file, err)
+		}
+	}
+
+	return importer.insertPending()
+}
+
+// historyImporter replays blocks into the Firehose stream, executed ones being inserted into
+// the chain in batches, see `ImportFirehoseHistory`.
+type historyImporter struct {
+	chain *core.BlockChain
+
+	// Last block replayed, executed or verified, the next one must be its child
+	last *types.Header
+
+	pending       types.Blocks
+	executed      int
+	verified      int
+	skipped       int
+	verifyingOnly bool
+}
+
+func (h *historyImporter) importFile(path string) error {
+	log.Info("Replaying history file into Firehose", "file", path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var next func() (*eraBlock, error)
+	switch {
+	case strings.HasSuffix(path, ".era1"):
+		next = newEraReader(file).Next
+	case strings.HasSuffix(path, ".gz"):
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		next = (&exportReader{stream: rlp.NewStream(reader, 0)}).Next
+	default:
+		next = (&exportReader{stream: rlp.NewStream(file, 0)}).Next
+	}
+
+	for {
+		block, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := h.replay(block); err != nil {
+			return fmt.Errorf("block #%d (%s): %w", block.block.NumberU64(), block.block.Hash(), err)
+		}
+	}
+
+	log.Info("Replayed history file into Firehose", "file", path, "executed", h.executed+len(h.pending), "verified", h.verified, "skipped", h.skipped)
+	return nil
+}
+
+func (h *historyImporter) replay(block *eraBlock) error {
+	number, hash := block.block.NumberU64(), block.block.Hash()
+	if number == 0 || h.chain.HasBlock(hash, number) {
+		h.skipped++
+		h.last = block.block.Header()
+		return nil
+	}
+
+	if h.last != nil && (h.last.Hash() != block.block.ParentHash() || h.last.Number.Uint64()+1 != number) {
+		return fmt.Errorf("not the child of the previous block #%d (%s)", h.last.Number, h.last.Hash())
+	}
+	h.last = block.block.Header()
+
+	if !h.verifyingOnly && (len(h.pending) > 0 || h.chain.HasBlockAndState(block.block.ParentHash(), number-1)) {
+		h.pending = append(h.pending, block.block)
+		if len(h.pending) >= importBatchSize {
+			return h.insertPending()
+		}
+		return nil
+	}
+
+	// The parent state is not available, it never will for the next blocks either
+	if err := h.insertPending(); err != nil {
+		return err
+	}
+	if !h.verifyingOnly {
+		log.Warn("Parent state unavailable, replaying the next blocks without executing them", "number", number, "hash", hash)
+		h.verifyingOnly = true
+	}
+
+	if block.receipts == nil {
+		return errors.New("parent state unavailable and no receipts to verify the block against, only era1 files hold receipts")
+	}
+	if err := verifyHistoryBlock(block.block, block.receipts); err != nil {
+		return err
+	}
+
+	firehoseContext := firehose.NewBlockContextWithBuffer(firehose.BlockSyncBuffer)
+	if err := firehoseContext.RecordNonExecutedBlock(block.block, block.receipts, h.chain.Config(), block.totalDifficulty); err != nil {
+		return fmt.Errorf("firehose non-executed block: %w", err)
+	}
+	if err := firehoseContext.FlushBlock(); err != nil {
+		return fmt.Errorf("firehose flush block: %w", err)
+	}
+	h.verified++
+
+	return nil
+}
+
+// insertPending executes the pending blocks by inserting them into the chain, which emits
+// them into the Firehose stream.
+func (h *historyImporter) insertPending() error {
+	if len(h.pending) == 0 {
+		return nil
+	}
+
+	if _, err := h.chain.InsertChain(h.pending); err != nil {
+		return err
+	}
+	h.executed += len(h.pending)
+	h.pending = nil
+
+	return nil
+}
+
+// verifyHistoryBlock checks the body and the `receipts` of `block` against its header, for
+// a block that cannot be executed.
+func verifyHistoryBlock(block *types.Block, receipts types.Receipts) error {
+	header := block.Header()
+	if hash := types.CalcUncleHash(block.Uncles()); hash != header.UncleHash {
+		return fmt.Errorf("uncle root hash mismatch: have %x, want %x", hash, header.UncleHash)
+	}
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != header.TxHash {
+		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, header.TxHash)
+	}
+
+	if len(receipts) != len(block.Transactions()) {
+		return fmt.Errorf("receipt count mismatch: have %d, want %d", len(receipts), len(block.Transactions()))
+	}
+	if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != header.ReceiptHash {
+		return fmt.Errorf("receipt root hash mismatch: have %x, want %x", hash, header.ReceiptHash)
+	}
+	if bloom := types.CreateBloom(receipts); bloom != header.Bloom {
+		return fmt.Errorf("logs bloom mismatch: have %x, want %x", bloom, header.Bloom)
+	}
+
+	gasUsed := uint64(0)
+	if len(receipts) > 0 {
+		gasUsed = receipts[len(receipts)-1].CumulativeGasUsed
+	}
+	if gasUsed != header.GasUsed {
+		return fmt.Errorf("gas used mismatch: have %d, want %d", gasUsed, header.GasUsed)
+	}
+
+	return nil
+}
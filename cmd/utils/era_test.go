@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+func TestEraReader(t *testing.T) {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		db     = rawdb.NewMemoryDatabase()
+		gspec  = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{sender: {Balance: big.NewInt(1_000_000_000_000_000)}}}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	genesis := gspec.MustCommit(db)
+	blocks, receipts := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(sender), common.Address{0xaa}, big.NewInt(1_000), 21_000, big.NewInt(1), nil), signer, key)
+		gen.AddTx(tx)
+	})
+
+	buffer := bytes.NewBuffer(nil)
+	writeEraEntry(t, buffer, eraVersionEntry, nil)
+	for i, block := range blocks {
+		writeEraEntry(t, buffer, eraCompressedHeaderEntry, snappyRLP(t, block.Header()))
+		writeEraEntry(t, buffer, eraCompressedBodyEntry, snappyRLP(t, block.Body()))
+		writeEraEntry(t, buffer, eraCompressedReceiptsEntry, snappyRLP(t, receipts[i]))
+
+		td := make([]byte, 32)
+		binary.LittleEndian.PutUint64(td, uint64(i+2)*1_000_000)
+		writeEraEntry(t, buffer, eraTotalDifficultyEntry, td)
+	}
+	writeEraEntry(t, buffer, eraAccumulatorEntry, make([]byte, 32))
+	writeEraEntry(t, buffer, eraBlockIndexEntry, make([]byte, 32))
+
+	reader := newEraReader(buffer)
+	for i, expected := range blocks {
+		block, err := reader.Next()
+		if err != nil {
+			t.Fatalf("block #%d: %v", i+1, err)
+		}
+		if block.block.Hash() != expected.Hash() {
+			t.Errorf("block #%d hash mismatch: have %s, want %s", i+1, block.block.Hash(), expected.Hash())
+		}
+		if want := big.NewInt(int64(i+2) * 1_000_000); block.totalDifficulty.Cmp(want) != 0 {
+			t.Errorf("block #%d total difficulty mismatch: have %s, want %s", i+1, block.totalDifficulty, want)
+		}
+		if err := verifyHistoryBlock(block.block, block.receipts); err != nil {
+			t.Errorf("block #%d verification failed: %v", i+1, err)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF once all blocks read, got %v", err)
+	}
+
+	// Receipts not matching the block are rejected
+	tampered := *receipts[1][0]
+	tampered.CumulativeGasUsed++
+	if err := verifyHistoryBlock(blocks[1], types.Receipts{&tampered}); err == nil {
+		t.Error("expected tampered receipts to be rejected")
+	}
+	if err := verifyHistoryBlock(blocks[1], nil); err == nil {
+		t.Error("expected missing receipts to be rejected")
+	}
+}
+
+func writeEraEntry(t *testing.T, writer io.Writer, typ uint16, value []byte) {
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint16(header[0:2], typ)
+	binary.LittleEndian.PutUint32(header[2:6], uint32(len(value)))
+
+	if _, err := writer.Write(append(header, value...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func snappyRLP(t *testing.T, value interface{}) []byte {
+	buffer := bytes.NewBuffer(nil)
+	writer := snappy.NewBufferedWriter(buffer)
+	if err := rlp.Encode(writer, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
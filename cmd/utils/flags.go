@@ -168,6 +168,10 @@ var (
 		Name:  "dev.period",
 		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
 	}
+	DeveloperSeedFlag = cli.StringFlag{
+		Name:  "dev.seed",
+		Usage: "Seed making developer mode deterministic, the developer account is derived from it and block timestamps from the genesis, producing reproducible chains and Firehose streams",
+	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
 		Usage: "Custom node name",
@@ -1608,7 +1612,20 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 			passphrase = list[0]
 		}
 		// setEtherbase has been called above, configuring the miner address from command line flags.
-		if cfg.Miner.Etherbase != (common.Address{}) {
+		if seed := ctx.GlobalString(DeveloperSeedFlag.Name); seed != "" {
+			key := developerKey(seed)
+			developer = accounts.Account{Address: crypto.PubkeyToAddress(key.PublicKey)}
+			if cfg.Miner.Etherbase != (common.Address{}) && cfg.Miner.Etherbase != developer.Address {
+				Fatalf("Etherbase %s does not match the developer account %s derived from --%s", cfg.Miner.Etherbase.Hex(), developer.Address.Hex(), DeveloperSeedFlag.Name)
+			}
+			if !ks.HasAddress(developer.Address) {
+				if developer, err = ks.ImportECDSA(key, passphrase); err != nil {
+					Fatalf("Failed to import developer account: %v", err)
+				}
+			}
+			cfg.Miner.Etherbase = developer.Address
+			cfg.Miner.DeterministicTime = true
+		} else if cfg.Miner.Etherbase != (common.Address{}) {
 			developer = accounts.Account{Address: cfg.Miner.Etherbase}
 		} else if accs := ks.Accounts(); len(accs) > 0 {
 			developer = ks.Accounts()[0]
@@ -1644,6 +1661,19 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	}
 }
 
+// developerKey derives the developer account key from the deterministic developer mode seed,
+// the same seed always yielding the same key.
+func developerKey(seed string) *ecdsa.PrivateKey {
+	hash := crypto.Keccak256([]byte("geth developer mode seed: " + seed))
+	for {
+		// A hash out of the curve order is next to impossible, hash again if so
+		if key, err := crypto.ToECDSA(hash); err == nil {
+			return key
+		}
+		hash = crypto.Keccak256(hash)
+	}
+}
+
 // SetDNSDiscoveryDefaults configures DNS discovery with the given URL if
 // no URLs are set.
 func SetDNSDiscoveryDefaults(cfg *ethconfig.Config, genesis common.Hash) {
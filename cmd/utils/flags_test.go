@@ -62,3 +62,12 @@ func Test_SplitTagsFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestDeveloperKey(t *testing.T) {
+	if !reflect.DeepEqual(developerKey("seed"), developerKey("seed")) {
+		t.Errorf("same seed derived different developer keys")
+	}
+	if reflect.DeepEqual(developerKey("seed"), developerKey("other")) {
+		t.Errorf("different seeds derived the same developer key")
+	}
+}
@@ -182,6 +182,8 @@ type Clique struct {
 	signFn SignerFn       // Signer function to authorize hashes with
 	lock   sync.RWMutex   // Protects the signer fields
 
+	deterministicTime bool // Derive header timestamps from the parent only, see DeterministicTime
+
 	// The fields below are for testing only
 	fakeDiff bool // Skip difficulty verifications
 }
@@ -538,6 +540,10 @@ func (c *Clique) Prepare(chain consensus.ChainHeaderReader, header *types.Header
 	if parent == nil {
 		return consensus.ErrUnknownAncestor
 	}
+	if c.deterministicTime {
+		header.Time = parent.Time + c.deterministicPeriod()
+		return nil
+	}
 	header.Time = parent.Time + c.config.Period
 	if header.Time < uint64(time.Now().Unix()) {
 		header.Time = uint64(time.Now().Unix())
@@ -546,13 +552,44 @@ func (c *Clique) Prepare(chain consensus.ChainHeaderReader, header *types.Header
 }
 
 // Finalize implements consensus.Engine, ensuring no uncles are set, nor block
-// rewards given.
+// rewards given, and recording the seal of the block with firehose.
 func (c *Clique) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, firehoseContext *firehose.Context) {
+	if firehoseContext.Enabled() {
+		c.recordFirehoseSeal(header, firehoseContext)
+	}
 	// No block rewards in PoA, so the state remains as is and uncles are dropped
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
 }
 
+// recordFirehoseSeal records the seal of the header being finalized, its signer
+// recovered from the signature along with the vote it casts, or the signers it
+// lists on checkpoints. Headers being assembled are not signed yet, nothing is
+// recorded for them.
+func (c *Clique) recordFirehoseSeal(header *types.Header, firehoseContext *firehose.Context) {
+	signer, err := ecrecover(header, c.signatures)
+	if err != nil {
+		return
+	}
+	seal := &firehose.CliqueSeal{
+		Signer: signer,
+		InTurn: header.Difficulty.Cmp(diffInTurn) == 0,
+	}
+	if header.Number.Uint64()%c.config.Epoch == 0 {
+		seal.Checkpoint = true
+		seal.Signers = make([]common.Address, (len(header.Extra)-extraVanity-extraSeal)/common.AddressLength)
+		for i := 0; i < len(seal.Signers); i++ {
+			copy(seal.Signers[i][:], header.Extra[extraVanity+i*common.AddressLength:])
+		}
+	} else if header.Coinbase != (common.Address{}) {
+		seal.Vote = &firehose.CliqueVote{
+			Address:   header.Coinbase,
+			Authorize: bytes.Equal(header.Nonce[:], nonceAuthVote),
+		}
+	}
+	firehoseContext.RecordCliqueSeal(seal)
+}
+
 // FinalizeAndAssemble implements consensus.Engine, ensuring no uncles are set,
 // nor block rewards given, and returns the final block.
 func (c *Clique) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt, firehoseContext *firehose.Context) (*types.Block, error) {
@@ -563,6 +600,23 @@ func (c *Clique) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *
 	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil)), nil
 }
 
+// DeterministicTime makes the engine derive the timestamp of the headers it prepares from
+// their parent only, one block period (or one second for a zero period) after it, instead of
+// catching up with the wall clock. Sealing stays paced by the block period. Given the same
+// signer and transactions, the produced chain is the same from one run to another.
+//
+// It must be called before the engine is used.
+func (c *Clique) DeterministicTime() {
+	c.deterministicTime = true
+}
+
+func (c *Clique) deterministicPeriod() uint64 {
+	if c.config.Period == 0 {
+		return 1
+	}
+	return c.config.Period
+}
+
 // Authorize injects a private key into the consensus engine to mint new blocks
 // with.
 func (c *Clique) Authorize(signer common.Address, signFn SignerFn) {
@@ -613,6 +667,10 @@ func (c *Clique) Seal(chain consensus.ChainHeaderReader, block *types.Block, res
 	}
 	// Sweet, the protocol permits us to sign the block, wait for our time
 	delay := time.Unix(int64(header.Time), 0).Sub(time.Now()) // nolint: gosimple
+	if c.deterministicTime {
+		// Timestamps are detached from the wall clock, sealing is paced by the period only
+		delay = time.Duration(c.config.Period) * time.Second
+	}
 	if header.Difficulty.Cmp(diffNoTurn) == 0 {
 		// It's not our turn explicitly to sign, delay it a bit
 		wiggle := time.Duration(len(snap.Signers)/2+1) * wiggleTime
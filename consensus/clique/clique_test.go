@@ -17,7 +17,9 @@
 package clique
 
 import (
+	"bytes"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -26,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -111,3 +114,79 @@ func TestReimportMirroredState(t *testing.T) {
 		t.Fatalf("chain head mismatch: have %d, want %d", head, 3)
 	}
 }
+
+// Tests that with deterministic timestamps, headers are prepared one block period after their
+// parent regardless of the wall clock.
+func TestDeterministicTime(t *testing.T) {
+	for _, period := range []uint64{0, 5} {
+		var (
+			db     = rawdb.NewMemoryDatabase()
+			config = *params.AllCliqueProtocolChanges.Clique
+		)
+		config.Period = period
+		engine := New(&config, db)
+		engine.DeterministicTime()
+
+		genspec := &core.Genesis{ExtraData: make([]byte, extraVanity+common.AddressLength+extraSeal)}
+		genesis := genspec.MustCommit(db)
+
+		chain, _ := core.NewBlockChain(db, nil, params.AllCliqueProtocolChanges, engine, vm.Config{}, nil, nil)
+		defer chain.Stop()
+
+		header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1)}
+		if err := engine.Prepare(chain, header); err != nil {
+			t.Fatalf("period %d: failed to prepare header: %v", period, err)
+		}
+		want := genesis.Time() + period
+		if period == 0 {
+			want = genesis.Time() + 1
+		}
+		if header.Time != want {
+			t.Errorf("period %d: timestamp mismatch: have %d, want %d", period, header.Time, want)
+		}
+	}
+}
+
+// Tests that the seal of the finalized headers, their signer along with the signers they
+// list or the vote they cast, is recorded with firehose, and that unsigned headers are not.
+func TestRecordFirehoseSeal(t *testing.T) {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		voted  = common.Address{0x0a}
+		engine = New(&params.CliqueConfig{Period: 1, Epoch: 2}, rawdb.NewMemoryDatabase())
+	)
+	sign := func(header *types.Header) *types.Header {
+		sig, _ := crypto.Sign(SealHash(header).Bytes(), key)
+		copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+		return header
+	}
+	checkpoint := &types.Header{Number: big.NewInt(2), Difficulty: diffInTurn, Extra: make([]byte, extraVanity+common.AddressLength+extraSeal)}
+	copy(checkpoint.Extra[extraVanity:], addr[:])
+	vote := &types.Header{Number: big.NewInt(3), Difficulty: diffNoTurn, Coinbase: voted, Extra: make([]byte, extraVanity+extraSeal)}
+	copy(vote.Nonce[:], nonceAuthVote)
+	unsigned := &types.Header{Number: big.NewInt(4), Difficulty: diffInTurn, Extra: make([]byte, extraVanity+extraSeal)}
+
+	tests := []struct {
+		header *types.Header
+		want   *firehose.CliqueSeal
+	}{
+		{sign(checkpoint), &firehose.CliqueSeal{Signer: addr, InTurn: true, Checkpoint: true, Signers: []common.Address{addr}}},
+		{sign(vote), &firehose.CliqueSeal{Signer: addr, Vote: &firehose.CliqueVote{Address: voted, Authorize: true}}},
+		{unsigned, nil},
+	}
+	for i, tt := range tests {
+		block := types.NewBlockWithHeader(tt.header)
+
+		ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+		ctx.EnableBlockTrace()
+		ctx.StartBlock(block)
+		engine.recordFirehoseSeal(tt.header, ctx)
+		ctx.FinalizeBlock(block)
+		ctx.EndBlock(block, nil)
+
+		if have := ctx.BlockTrace().CliqueSeal; !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: seal mismatch: have %+v, want %+v", i, have, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Reward is a balance change applied to an account when a block is finalized, a
+// credit when the amount is positive and a debit when it is negative.
+type Reward struct {
+	Account common.Address
+	Amount  *big.Int
+	Reason  firehose.BalanceChangeReason
+}
+
+// RewardScheme computes the rewards of a block. It allows chains with a
+// non-standard issuance (no uncle rewards, treasury fees, fee redirection) to
+// replace the standard ethash rewards without touching the consensus code.
+//
+// Each reward carries the balance change reason recorded by firehose, it must be
+// registered through firehose.RegisterBalanceChangeReason, with the category the
+// firehose self-check reconciles it in, while initializing the package defining
+// the scheme.
+type RewardScheme interface {
+	Rewards(config *params.ChainConfig, header *types.Header, uncles []*types.Header) []*Reward
+}
+
+// RewardSchemeFunc is an adapter to allow the use of ordinary functions as
+// reward schemes.
+type RewardSchemeFunc func(config *params.ChainConfig, header *types.Header, uncles []*types.Header) []*Reward
+
+// Rewards calls f(config, header, uncles).
+func (f RewardSchemeFunc) Rewards(config *params.ChainConfig, header *types.Header, uncles []*types.Header) []*Reward {
+	return f(config, header, uncles)
+}
+
+// StandardRewardScheme is the ethash reward scheme, used when none is configured.
+// The coinbase of the block is credited with the static block reward and rewards
+// for included uncles, the coinbase of each uncle block is also rewarded.
+var StandardRewardScheme RewardScheme = RewardSchemeFunc(standardRewards)
+
+func standardRewards(config *params.ChainConfig, header *types.Header, uncles []*types.Header) []*Reward {
+	// Select the correct block reward based on chain progression
+	blockReward := FrontierBlockReward
+	if config.IsByzantium(header.Number) {
+		blockReward = ByzantiumBlockReward
+	}
+	if config.IsConstantinople(header.Number) {
+		blockReward = ConstantinopleBlockReward
+	}
+	// Accumulate the rewards for the miner and any included uncles
+	rewards := make([]*Reward, 0, len(uncles)+1)
+	reward := new(big.Int).Set(blockReward)
+	for _, uncle := range uncles {
+		r := new(big.Int).Add(uncle.Number, big8)
+		r.Sub(r, header.Number)
+		r.Mul(r, blockReward)
+		r.Div(r, big8)
+		rewards = append(rewards, &Reward{Account: uncle.Coinbase, Amount: r, Reason: firehose.RewardMineUncleBalanceChangeReason})
+
+		reward.Add(reward, new(big.Int).Div(blockReward, big32))
+	}
+	return append(rewards, &Reward{Account: header.Coinbase, Amount: reward, Reason: firehose.RewardMineBlockBalanceChangeReason})
+}
+
+// accumulateRewards applies the rewards computed by the scheme for the given
+// block, in order. It panics if a reward carries an unregistered reason since
+// the scheme is then misconfigured.
+func accumulateRewards(scheme RewardScheme, config *params.ChainConfig, state *state.StateDB, header *types.Header, uncles []*types.Header, firehoseContext *firehose.Context) {
+	if scheme == nil {
+		scheme = StandardRewardScheme
+	}
+	for _, reward := range scheme.Rewards(config, header, uncles) {
+		if !reward.Reason.IsKnown() {
+			panic(fmt.Errorf("ethash reward of %s for block %d has unknown balance change reason %q, it must be registered through 'firehose.RegisterBalanceChangeReason'", reward.Account, header.Number, string(reward.Reason)))
+		}
+		if reward.Amount.Sign() < 0 {
+			state.SubBalance(reward.Account, new(big.Int).Neg(reward.Amount), firehoseContext, reward.Reason)
+		} else {
+			state.AddBalance(reward.Account, reward.Amount, false, firehoseContext, reward.Reason)
+		}
+	}
+}
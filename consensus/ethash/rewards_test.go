@@ -0,0 +1,114 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var treasuryFeeBalanceChangeReason = firehose.RegisterBalanceChangeReason("test_treasury_fee", firehose.TransferBalanceChangeCategory)
+
+func TestStandardRewards(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		miner       = common.HexToAddress("0x01")
+		uncleMiner  = common.HexToAddress("0x02")
+		header      = &types.Header{Number: big.NewInt(10), Coinbase: miner}
+		uncles      = []*types.Header{{Number: big.NewInt(9), Coinbase: uncleMiner}}
+		statedb, _  = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		firehoseCtx = firehose.NewSpeculativeExecutionContext(1024)
+	)
+	accumulateRewards(nil, params.MainnetChainConfig, statedb, header, uncles, firehoseCtx)
+
+	// Frontier rewards, the uncle is one block behind so its miner gets 7/8th of it
+	if have, want := statedb.GetBalance(uncleMiner), big.NewInt(4375e+15); have.Cmp(want) != 0 {
+		t.Errorf("uncle reward mismatch: have %v, want %v", have, want)
+	}
+	if have, want := statedb.GetBalance(miner), big.NewInt(515625e+13); have.Cmp(want) != 0 {
+		t.Errorf("block reward mismatch: have %v, want %v", have, want)
+	}
+	log := string(firehoseCtx.FirehoseLog())
+	if !strings.Contains(log, " 0000000000000000000000000000000000000002 . 3cb71f51fc558000 reward_mine_uncle ") {
+		t.Errorf("uncle reward not recorded:\n%s", log)
+	}
+	if !strings.Contains(log, " 0000000000000000000000000000000000000001 . 478eae0e571ba000 reward_mine_block ") {
+		t.Errorf("block reward not recorded:\n%s", log)
+	}
+}
+
+func TestCustomRewardScheme(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		miner    = common.HexToAddress("0x01")
+		treasury = common.HexToAddress("0x02")
+		header   = &types.Header{Number: big.NewInt(10), Coinbase: miner}
+	)
+	// A scheme paying the standard rewards then redirecting a tenth of them to a treasury
+	scheme := RewardSchemeFunc(func(config *params.ChainConfig, header *types.Header, uncles []*types.Header) []*Reward {
+		rewards := StandardRewardScheme.Rewards(config, header, uncles)
+		fee := new(big.Int).Div(rewards[len(rewards)-1].Amount, big.NewInt(10))
+
+		return append(rewards,
+			&Reward{Account: header.Coinbase, Amount: new(big.Int).Neg(fee), Reason: treasuryFeeBalanceChangeReason},
+			&Reward{Account: treasury, Amount: fee, Reason: treasuryFeeBalanceChangeReason},
+		)
+	})
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	firehoseCtx := firehose.NewSpeculativeExecutionContext(1024)
+	accumulateRewards(scheme, params.MainnetChainConfig, statedb, header, nil, firehoseCtx)
+
+	if have, want := statedb.GetBalance(miner), big.NewInt(45e+17); have.Cmp(want) != 0 {
+		t.Errorf("miner balance mismatch: have %v, want %v", have, want)
+	}
+	if have, want := statedb.GetBalance(treasury), big.NewInt(5e+17); have.Cmp(want) != 0 {
+		t.Errorf("treasury balance mismatch: have %v, want %v", have, want)
+	}
+	log := string(firehoseCtx.FirehoseLog())
+	if !strings.Contains(log, " 0000000000000000000000000000000000000001 4563918244f40000 3e73362871420000 test_treasury_fee ") {
+		t.Errorf("treasury fee debit not recorded:\n%s", log)
+	}
+	if !strings.Contains(log, " 0000000000000000000000000000000000000002 . 06f05b59d3b20000 test_treasury_fee ") {
+		t.Errorf("treasury fee credit not recorded:\n%s", log)
+	}
+}
+
+func TestRewardSchemeUnknownReason(t *testing.T) {
+	scheme := RewardSchemeFunc(func(config *params.ChainConfig, header *types.Header, uncles []*types.Header) []*Reward {
+		return []*Reward{{Account: header.Coinbase, Amount: big.NewInt(1), Reason: "unregistered"}}
+	})
+	defer func() {
+		if recover() == nil {
+			t.Errorf("reward with an unregistered reason applied")
+		}
+	}()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	accumulateRewards(scheme, params.MainnetChainConfig, statedb, &types.Header{Number: big.NewInt(1)}, nil, firehose.NoOpContext)
+}
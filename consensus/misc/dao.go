@@ -73,14 +73,16 @@ func VerifyDAOHeaderExtraData(config *params.ChainConfig, header *types.Header)
 // rules, transferring all balances of a set of DAO accounts to a single refund
 // contract.
 func ApplyDAOHardFork(statedb *state.StateDB, firehoseContext *firehose.Context) {
-	// Retrieve the contract to refund balances into
-	if !statedb.Exist(params.DAORefundContract) {
-		statedb.CreateAccount(params.DAORefundContract, firehoseContext)
-	}
+	firehoseContext.RecordIrregularStateChange(firehose.DAOForkIrregularStateChangeReason, func() {
+		// Retrieve the contract to refund balances into
+		if !statedb.Exist(params.DAORefundContract) {
+			statedb.CreateAccount(params.DAORefundContract, firehoseContext)
+		}
 
-	// Move every DAO account and extra-balance account funds into the refund contract
-	for _, addr := range params.DAODrainList() {
-		statedb.AddBalance(params.DAORefundContract, statedb.GetBalance(addr), false, firehoseContext, firehose.BalanceChangeReason("dao_refund_contract"))
-		statedb.SetBalance(addr, new(big.Int), firehoseContext, firehose.BalanceChangeReason("dao_adjust_balance"))
-	}
+		// Move every DAO account and extra-balance account funds into the refund contract
+		for _, addr := range params.DAODrainList() {
+			statedb.AddBalance(params.DAORefundContract, statedb.GetBalance(addr), false, firehoseContext, firehose.DaoRefundContractBalanceChangeReason)
+			statedb.SetBalance(addr, new(big.Int), firehoseContext, firehose.DaoAdjustBalanceBalanceChangeReason)
+		}
+	})
 }
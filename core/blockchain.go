@@ -18,7 +18,6 @@
 package core
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -38,7 +37,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/firehose"
@@ -127,7 +125,7 @@ type CacheConfig struct {
 	TrieCleanLimit      int           // Memory allowance (MB) to use for caching trie nodes in memory
 	TrieCleanJournal    string        // Disk journal for saving clean cache entries.
 	TrieCleanRejournal  time.Duration // Time interval to dump clean cache to disk periodically
-	TrieCleanNoPrefetch bool          // Whether to disable heuristic state prefetching for followup blocks
+	TrieCleanNoPrefetch bool          // Whether to disable heuristic state prefetching of the imported blocks
 	TrieDirtyLimit      int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieDirtyDisabled   bool          // Whether to disable trie write caching and GC altogether (archive node)
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
@@ -205,6 +203,12 @@ type BlockChain struct {
 	running       int32          // 0 if chain is running, 1 when stopped
 	procInterrupt int32          // interrupt signaler for block processing
 
+	firehoseBackfilling int32                  // 1 while the Firehose backfill of non-executed blocks is running
+	firehoseReplayed    *rawdb.FirehoseCursor  // Last Firehose block emitted before the restart, nil once passed
+	firehoseCursorLock  sync.Mutex             // Protects the Firehose cursor, completed by the sink's writer
+	firehoseUnwritten   []rawdb.FirehoseCursor // Firehose blocks marked pending, not yet written to the sink
+	firehoseCompleted   *rawdb.FirehoseCursor  // Last Firehose block written to the sink, nil if none
+
 	engine     consensus.Engine
 	validator  Validator // Block and state validator interface
 	prefetcher Prefetcher
@@ -223,6 +227,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
+	// Shared by the EVMs of the state processor and of the miner, see `GetVMConfig`
+	if vmConfig.RulesCache == nil {
+		vmConfig.RulesCache = vm.NewRulesCache(chainConfig)
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	receiptsCache, _ := lru.New(receiptsCacheLimit)
@@ -388,51 +396,36 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			panic(firehose.MissingGenesisPanicMessage)
 		}
 
-		genesis := firehose.GenesisConfig.(*Genesis)
-		if genesis == nil {
-			panic(firehose.MissingGenesisPanicMessage)
-		}
-
 		// As far as I can tell, the block's hash comes from the keccak hash of the rlp encoding
 		// of the block's header which includes all fields. So we can check the hash to ensure
 		// the genesis config computed matched Geth savec genesis block.
-		recomputedGenesisBlock := genesis.ToBlock(nil)
-		if bc.genesisBlock.Hash() != recomputedGenesisBlock.Hash() {
-			firehose.ReportHeaderComparisonResult(recomputedGenesisBlock.Header(), bc.genesisBlock.Header())
+		recomputedGenesisHeader := firehose.GenesisConfig.Header()
+		if bc.genesisBlock.Hash() != recomputedGenesisHeader.Hash() {
+			firehose.ReportHeaderComparisonResult(recomputedGenesisHeader, bc.genesisBlock.Header())
 			panic("firehose genesis block hash mismatch vs geth computed genesis block hash")
 		}
 
-		firehose.MaybeSyncContext().RecordGenesisBlock(bc.genesisBlock, func(ctx *firehose.Context) {
-			sortedAddrs := make([]common.Address, len(genesis.Alloc))
-			i := 0
-			for addr := range genesis.Alloc {
-				sortedAddrs[i] = addr
-				i++
-			}
-
-			sort.Slice(sortedAddrs, func(i, j int) bool {
-				return bytes.Compare(sortedAddrs[i][:], sortedAddrs[j][:]) <= -1
-			})
-
-			for _, addr := range sortedAddrs {
-				account := genesis.Alloc[addr]
-
-				ctx.RecordNewAccount(addr)
+		firehoseContext := firehose.NoOpContext
+		if firehose.MaybeSyncContext().Enabled() {
+			firehoseContext = firehose.NewBlockContextWithBuffer(firehose.BlockSyncBuffer)
+		}
 
-				ctx.RecordBalanceChange(addr, common.Big0, account.Balance, firehose.BalanceChangeReason("genesis_balance"))
-				if len(account.Code) > 0 {
-					ctx.RecordCodeChange(addr, nil, nil, crypto.Keccak256Hash(account.Code), account.Code)
-				}
+		firehoseContext.RecordGenesisBlock(bc.genesisBlock, bc.chainConfig, firehose.GenesisConfig)
+	}
 
-				if account.Nonce > 0 {
-					ctx.RecordNonceChange(addr, 0, account.Nonce)
-				}
+	if firehose.Enabled {
+		bc.firehoseReplayed = rawdb.ReadFirehoseCursor(bc.db)
+		if cursor := bc.firehoseReplayed; cursor != nil && cursor.Complete {
+			bc.firehoseCompleted = &rawdb.FirehoseCursor{Number: cursor.Number, Hash: cursor.Hash, Complete: true}
+		}
+		if err := bc.reemitIncompleteFirehoseBlock(); err != nil {
+			return nil, err
+		}
 
-				for key, value := range account.Storage {
-					ctx.RecordStorageChange(addr, key, common.Hash{}, value)
-				}
-			}
-		})
+		if firehose.HeartbeatInterval > 0 {
+			bc.wg.Add(1)
+			go bc.firehoseHeartbeatLoop()
+		}
 	}
 
 	// Take ownership of this particular state
@@ -605,6 +598,12 @@ func (bc *BlockChain) SetHeadBeyondRoot(head uint64, root common.Hash) (uint64,
 					newHeadBlock = bc.GetBlock(newHeadBlock.ParentHash(), newHeadBlock.NumberU64()-1) // Keep rewinding
 				}
 			}
+			// The rewound blocks emitted by Firehose are undone while their headers are still known
+			if firehose.Enabled {
+				if err := bc.undoFirehoseBlocks(newHeadBlock.NumberU64() + 1); err != nil {
+					log.Error("Failed undoing Firehose blocks", "number", newHeadBlock.NumberU64()+1, "err", err)
+				}
+			}
 			rawdb.WriteHeadBlockHash(db, newHeadBlock.Hash())
 
 			// Degrade the chain markers if they are explicitly reverted.
@@ -1057,7 +1056,12 @@ func (bc *BlockChain) Stop() {
 	bc.scope.Close()
 	close(bc.quit)
 	bc.StopInsert()
+
+	// A Firehose write blocked on a consumer that stopped reading would block the wait
+	// below forever, so we abort it if insertion did not complete in a timely fashion.
+	abortFirehoseSink := time.AfterFunc(firehose.SinkShutdownTimeout, firehose.CancelSink)
 	bc.wg.Wait()
+	abortFirehoseSink.Stop()
 
 	// Ensure that the entirety of the state snapshot is journalled to disk.
 	var snapBase common.Hash
@@ -1223,6 +1227,10 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		stats = struct{ processed, ignored int32 }{}
 		start = time.Now()
 		size  = 0
+
+		// Blocks written along with their receipts, emitted as non-executed Firehose blocks
+		firehoseBlocks   types.Blocks
+		firehoseReceipts []types.Receipts
 	)
 	// updateHead updates the head fast sync block if the inserted blocks are better
 	// and returns an indicator whether the inserted blocks are canonical.
@@ -1435,6 +1443,7 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 			rawdb.WriteBody(batch, block.Hash(), block.NumberU64(), block.Body())
 			rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receiptChain[i])
 			rawdb.WriteTxLookupEntriesByBlock(batch, block) // Always write tx indices for live blocks, we assume they are needed
+			firehoseBlocks, firehoseReceipts = append(firehoseBlocks, block), append(firehoseReceipts, receiptChain[i])
 
 			// Write everything belongs to the blocks into the database. So that
 			// we can ensure all components of body is completed(body, receipts,
@@ -1468,6 +1477,7 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 			}
 			return n, err
 		}
+		firehoseBlocks, firehoseReceipts = append(firehoseBlocks, ancientBlocks...), append(firehoseReceipts, ancientReceipts...)
 	}
 	// Write the tx index tail (block number from where we index) before write any live blocks
 	if len(liveBlocks) > 0 && liveBlocks[0].NumberU64() == ancientLimit+1 {
@@ -1491,6 +1501,10 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		}
 	}
 
+	if err := bc.emitFirehoseNonExecutedBlocks(firehoseBlocks, firehoseReceipts); err != nil {
+		return 0, err
+	}
+
 	head := blockChain[len(blockChain)-1]
 	context := []interface{}{
 		"count", stats.processed, "elapsed", common.PrettyDuration(time.Since(start)),
@@ -1916,18 +1930,30 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 				log.Error("Please file an issue, skip known block execution without receipt",
 					"hash", block.Hash(), "number", block.NumberU64())
 			}
+			firehoseEnabled := firehose.MaybeSyncContext().Enabled()
+			if firehoseEnabled {
+				bc.markFirehoseBlockPending(block)
+			}
+
+			previousHead := bc.CurrentBlock()
 			if err := bc.writeKnownBlock(block); err != nil {
 				return it.index, err
 			}
 
 			// some blocks with 0 transactions are only processed here
-			if firehoseContext := firehose.MaybeSyncContext(); firehoseContext.Enabled() {
+			if firehoseEnabled {
+				firehoseContext := firehose.NewBlockContextWithBuffer(firehose.BlockSyncBuffer)
 				firehoseContext.StartBlock(block)
+				firehoseContext.RecordForkActivations(bc.chainConfig, block)
 				firehoseContext.FinalizeBlock(block)
+				bc.recordFirehoseReplayedBlock(firehoseContext, block)
 				ptd := bc.GetTd(block.ParentHash(), block.NumberU64()-1)
 				td := new(big.Int).Add(block.Difficulty(), ptd)
 				firehoseContext.EndBlock(block, td)
-				firehoseContext.FlushBlock()
+				if err := bc.flushFirehoseBlock(firehoseContext, block, CanonStatTy, previousHead.Header()); err != nil {
+					return it.index, err
+				}
+				bc.recordFirehoseHeadUpdate(block, previousHead)
 			}
 
 			stats.processed++
@@ -1952,34 +1978,38 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		statedb.StartPrefetcher("chain")
 		activeState = statedb
 
-		// If we have a followup block, run that against the current state to pre-cache
-		// transactions and probabilistically some of the account/storage trie nodes.
-		var followupInterrupt uint32
-		if !bc.cacheConfig.TrieCleanNoPrefetch {
-			if followup, err := it.peek(); followup != nil && err == nil {
-				throwaway, _ := state.New(parent.Root, bc.stateCache, bc.snaps)
+		// Run the transactions of the block ahead of its processing against a throwaway copy of
+		// the parent state, pre-caching their signatures and probabilistically some of the
+		// account/storage trie nodes they touch. The prefetch is interrupted once the block is
+		// processed.
+		var prefetchInterrupt uint32
+		if !bc.cacheConfig.TrieCleanNoPrefetch && len(block.Transactions()) > 0 {
+			throwaway, _ := state.New(parent.Root, bc.stateCache, bc.snaps)
 
-				go func(start time.Time, followup *types.Block, throwaway *state.StateDB, interrupt *uint32) {
-					bc.prefetcher.Prefetch(followup, throwaway, bc.vmConfig, &followupInterrupt)
+			go func(start time.Time, block *types.Block, throwaway *state.StateDB, interrupt *uint32) {
+				bc.prefetcher.Prefetch(block, throwaway, bc.vmConfig, interrupt)
 
-					blockPrefetchExecuteTimer.Update(time.Since(start))
-					if atomic.LoadUint32(interrupt) == 1 {
-						blockPrefetchInterruptMeter.Mark(1)
-					}
-				}(time.Now(), followup, throwaway, &followupInterrupt)
-			}
+				blockPrefetchExecuteTimer.Update(time.Since(start))
+				if atomic.LoadUint32(interrupt) == 1 {
+					blockPrefetchInterruptMeter.Mark(1)
+				}
+			}(time.Now(), block, throwaway, &prefetchInterrupt)
 		}
 		// Process block using the parent state as reference point
 		firehoseContext := firehose.NoOpContext
 		if firehose.Enabled {
-			firehoseContext = firehose.NewBlockContextWithBuffer(firehose.BlockSyncBuffer)
+			firehoseContext = newFirehoseBlockContext(firehose.BlockSyncBuffer)
+			if firehose.BlockWitnessEnabled {
+				statedb.TrackAccessedState()
+			}
 		}
 
 		substart := time.Now()
 		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig, firehoseContext)
+		atomic.StoreUint32(&prefetchInterrupt, 1)
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
-			atomic.StoreUint32(&followupInterrupt, 1)
+			firehose.ReportBadBlock(firehoseContext, block, receipts, err)
 			return it.index, err
 		}
 		// Update the metrics touched during block processing
@@ -1999,11 +2029,17 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		substart = time.Now()
 		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
 			bc.reportBlock(block, receipts, err)
-			atomic.StoreUint32(&followupInterrupt, 1)
+			firehose.ReportBadBlock(firehoseContext, block, receipts, err)
 			return it.index, err
 		}
 
+		if err := bc.recordFirehoseBlockWitness(firehoseContext, block, statedb); err != nil {
+			return it.index, fmt.Errorf("firehose block witness: %w", err)
+		}
+
 		if firehoseContext.Enabled() {
+			bc.recordFirehoseReplayedBlock(firehoseContext, block)
+
 			// Calculate the total difficulty of the block
 			ptd := bc.GetTd(block.ParentHash(), block.NumberU64()-1)
 			td := new(big.Int).Add(block.Difficulty(), ptd)
@@ -2018,17 +2054,27 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 
 		blockValidationTimer.Update(time.Since(substart) - (statedb.AccountHashes + statedb.StorageHashes - triehash))
 
+		if firehoseContext.Enabled() {
+			bc.markFirehoseBlockPending(block)
+		}
+
 		// Write the block to the chain and get the status.
 		substart = time.Now()
+		previousHead := bc.CurrentBlock()
 		status, err := bc.writeBlockWithState(block, receipts, logs, statedb, false)
-		atomic.StoreUint32(&followupInterrupt, 1)
 		if err != nil {
 			return it.index, err
 		}
 
 		if firehoseContext.Enabled() {
 			// This is last point where there is no more an early return due to an error, we flush here
-			firehoseContext.FlushBlock()
+			if err := bc.flushFirehoseBlock(firehoseContext, block, status, previousHead.Header()); err != nil {
+				return it.index, err
+			}
+
+			if status == CanonStatTy {
+				bc.recordFirehoseHeadUpdate(block, previousHead)
+			}
 		}
 
 		// Update the metrics touched during block commit
@@ -0,0 +1,941 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func init() {
+	// The forks of the chain config are only known by the node, see `firehose.ForksActivatedAt`
+	firehose.ForksActivatedAt = (*params.ChainConfig).ForksActivatedAt
+}
+
+// firehoseBackfillTrieMemory is the amount of trie nodes the Firehose backfill keeps in memory
+// while reconstructing the state, above it they are flushed to the database.
+const firehoseBackfillTrieMemory = 256 * 1024 * 1024
+
+// recordFirehoseBlockWitness records, when enabled, the witness of `block` executed into
+// `statedb`: the Merkle proofs, against the parent state root, of the accounts and storage
+// slots accessed while executing the block along with the contract code loaded. The tracking
+// of the accounts not found must have been enabled on `statedb` before executing the block.
+func (bc *BlockChain) recordFirehoseBlockWitness(firehoseContext *firehose.Context, block *types.Block, statedb *state.StateDB) error {
+	if !firehose.BlockWitnessEnabled || !firehoseContext.Enabled() {
+		return nil
+	}
+
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return fmt.Errorf("parent block %d (%s) not found", block.NumberU64()-1, block.ParentHash())
+	}
+
+	parentState, err := state.New(parent.Root, statedb.Database(), nil)
+	if err != nil {
+		return fmt.Errorf("parent state: %w", err)
+	}
+
+	nodes := make(firehoseWitnessNodes)
+	for addr, slots := range statedb.AccessedState() {
+		proof, err := parentState.GetProof(addr)
+		if err != nil {
+			return fmt.Errorf("account %s proof: %w", addr, err)
+		}
+		for _, node := range proof {
+			nodes.Put(crypto.Keccak256(node), node)
+		}
+
+		// The slots of an account created by the block are proven by its absence
+		storageTrie := parentState.StorageTrie(addr)
+		if storageTrie == nil {
+			continue
+		}
+		for _, slot := range slots {
+			if err := storageTrie.Prove(crypto.Keccak256(slot[:]), 0, nodes); err != nil {
+				return fmt.Errorf("account %s slot %s proof: %w", addr, slot, err)
+			}
+		}
+	}
+
+	firehoseContext.RecordBlockWitness(nodes.sorted(), statedb.AccessedCode())
+	return nil
+}
+
+// firehoseWitnessNodes collects the trie nodes of Merkle proofs keyed by their hash.
+type firehoseWitnessNodes map[common.Hash][]byte
+
+func (n firehoseWitnessNodes) Put(key []byte, value []byte) error {
+	n[common.BytesToHash(key)] = common.CopyBytes(value)
+	return nil
+}
+
+func (n firehoseWitnessNodes) Delete(key []byte) error {
+	delete(n, common.BytesToHash(key))
+	return nil
+}
+
+// sorted returns the collected nodes sorted by hash.
+func (n firehoseWitnessNodes) sorted() [][]byte {
+	hashes := make([]common.Hash, 0, len(n))
+	for hash := range n {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+
+	nodes := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		nodes[i] = n[hash]
+	}
+
+	return nodes
+}
+
+// markFirehoseBlockPending persists an incomplete Firehose cursor for the block, it must be
+// called before the block is written to the database so that a crash happening between the
+// database write and the Firehose emission can be detected on restart. The persisted cursor
+// is the oldest block not yet written to the sink, which may hold several blocks in memory.
+func (bc *BlockChain) markFirehoseBlockPending(block *types.Block) {
+	bc.firehoseCursorLock.Lock()
+	defer bc.firehoseCursorLock.Unlock()
+
+	bc.firehoseUnwritten = append(bc.firehoseUnwritten, rawdb.FirehoseCursor{Number: block.NumberU64(), Hash: block.Hash()})
+	rawdb.WriteFirehoseCursor(bc.db, &bc.firehoseUnwritten[0])
+}
+
+// completeFirehoseBlock marks the persisted Firehose cursor as complete once the block's data
+// actually reached the sink, see `firehose.AfterSinkWrite`. Blocks are written in order, the
+// blocks marked pending before it, whose import failed, are dropped as well.
+func (bc *BlockChain) completeFirehoseBlock(number uint64, hash common.Hash) {
+	bc.firehoseCursorLock.Lock()
+	defer bc.firehoseCursorLock.Unlock()
+
+	for i, cursor := range bc.firehoseUnwritten {
+		if cursor.Hash == hash {
+			bc.firehoseUnwritten = bc.firehoseUnwritten[i+1:]
+			break
+		}
+	}
+
+	if len(bc.firehoseUnwritten) > 0 {
+		rawdb.WriteFirehoseCursor(bc.db, &bc.firehoseUnwritten[0])
+		return
+	}
+	bc.firehoseCompleted = &rawdb.FirehoseCursor{Number: number, Hash: hash, Complete: true}
+	rawdb.WriteFirehoseCursor(bc.db, bc.firehoseCompleted)
+}
+
+// dropFirehoseBlockPending forgets the block marked pending whose Firehose data was dropped
+// instead of being emitted, the persisted cursor goes back to the oldest block not yet
+// written to the sink or, if none, to the last block written.
+func (bc *BlockChain) dropFirehoseBlockPending(hash common.Hash) {
+	bc.firehoseCursorLock.Lock()
+	defer bc.firehoseCursorLock.Unlock()
+
+	for i, cursor := range bc.firehoseUnwritten {
+		if cursor.Hash == hash {
+			bc.firehoseUnwritten = append(bc.firehoseUnwritten[:i:i], bc.firehoseUnwritten[i+1:]...)
+			break
+		}
+	}
+
+	switch {
+	case len(bc.firehoseUnwritten) > 0:
+		rawdb.WriteFirehoseCursor(bc.db, &bc.firehoseUnwritten[0])
+	case bc.firehoseCompleted != nil:
+		rawdb.WriteFirehoseCursor(bc.db, bc.firehoseCompleted)
+	default:
+		rawdb.DeleteFirehoseCursor(bc.db)
+	}
+}
+
+// flushFirehoseBlock flushes the block's accumulated Firehose data and marks the persisted
+// Firehose cursor as complete once the data was written to the sink, which happens later
+// on when the sink's printer holds data in memory. The data of a block already emitted
+// before the restart is dropped instead when replays are skipped, see
+// `firehose.ReplayPolicySkip`.
+//
+// The block was written with `status` while `previousHead` was the chain head. A side chain
+// block is flushed by `flushFirehoseSideChainBlock`. A block reorganizing the chain, whose
+// parent is not the previous head, is flushed by `flushFirehoseReorgBlock`. Any other block
+// must be above the last emitted block, the flush fails otherwise.
+func (bc *BlockChain) flushFirehoseBlock(firehoseContext *firehose.Context, block *types.Block, status WriteStatus, previousHead *types.Header) error {
+	if status == SideStatTy {
+		return bc.flushFirehoseSideChainBlock(firehoseContext, block)
+	}
+	if block.ParentHash() != previousHead.Hash() {
+		return bc.flushFirehoseReorgBlock(firehoseContext, block)
+	}
+
+	number, hash := block.NumberU64(), block.Hash()
+	if firehose.BlockReplayPolicy == firehose.ReplayPolicySkip && bc.isFirehoseReplay(block) {
+		log.Debug("Skipping Firehose block already emitted before restart", "number", number, "hash", hash)
+		if err := firehoseContext.SkipReplayedBlock(); err != nil {
+			return fmt.Errorf("firehose skip replayed block: %w", err)
+		}
+
+		firehose.AfterSinkWrite(func() { bc.completeFirehoseBlock(number, hash) })
+		return nil
+	}
+
+	payload, trace, segmented := firehoseContext.FirehoseLog(), firehoseContext.BlockTrace(), firehoseContext.Segmented()
+	if !segmented {
+		// The payload of a block emitted in segments holds its last segment only, such a block
+		// is not stored, its data is never held in full
+		bc.storeFirehoseBlock(block, payload)
+	}
+	if err := firehoseContext.FlushBlock(); err != nil {
+		return fmt.Errorf("firehose flush block: %w", err)
+	}
+
+	firehose.AfterSinkWrite(func() { bc.completeFirehoseBlock(number, hash) })
+	bc.indexFirehoseBlock(block, trace)
+	firehose.ThrottleSync()
+	return nil
+}
+
+// flushFirehoseSideChainBlock flushes the block's accumulated Firehose data when the block was
+// imported on a side chain. Its data is dropped unless side chain blocks are enabled, in which
+// case it's emitted out of the order of the chain, followed by its `SIDE_CHAIN_BLOCK` record.
+// Either way, the persisted Firehose cursor is left untouched and the block is emitted as part
+// of the chain if a reorg makes it canonical later on, see `flushFirehoseReorgBlock`.
+func (bc *BlockChain) flushFirehoseSideChainBlock(firehoseContext *firehose.Context, block *types.Block) error {
+	bc.dropFirehoseBlockPending(block.Hash())
+	if !firehose.SideChainBlocksEnabled {
+		firehoseContext.DropBlock()
+		return nil
+	}
+
+	payload, segmented := firehoseContext.FirehoseLog(), firehoseContext.Segmented()
+	if err := firehoseContext.FlushSideChainBlock(); err != nil {
+		return fmt.Errorf("firehose flush side chain block: %w", err)
+	}
+
+	if !segmented {
+		bc.storeFirehoseBlock(block, payload)
+	}
+	bc.recordFirehoseSideChainBlock(block)
+	firehose.ThrottleSync()
+	return nil
+}
+
+// flushFirehoseReorgBlock flushes the block's accumulated Firehose data when the block made
+// the chain reorganize onto its branch. The emitted blocks of the old branch are undone first,
+// then the blocks of the new branch that were not emitted, imported on a side chain, are
+// re-executed and emitted before it.
+//
+// A block emitted in segments cannot have blocks emitted in between its segments, its
+// segments are aborted and it's re-executed after the blocks of its branch.
+func (bc *BlockChain) flushFirehoseReorgBlock(firehoseContext *firehose.Context, block *types.Block) error {
+	reexecute := firehoseContext.Segmented()
+	if reexecute {
+		firehoseContext.DropBlock()
+	}
+
+	err := bc.undoFirehoseBlocksWhile(func(last *firehose.EmittedBlock) bool {
+		return last.Number >= block.NumberU64() || bc.GetCanonicalHash(last.Number) != last.Hash
+	})
+	if err != nil {
+		return err
+	}
+
+	var branch types.Blocks
+	if last := firehose.SyncContext().LastEmittedBlock(); last != nil {
+		for parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1); parent != nil && parent.NumberU64() > last.Number; parent = bc.GetBlock(parent.ParentHash(), parent.NumberU64()-1) {
+			branch = append(types.Blocks{parent}, branch...)
+		}
+	}
+	if reexecute {
+		branch = append(branch, block)
+	}
+
+	for _, branchBlock := range branch {
+		// The block sync buffer holds the data of `block`, the branch blocks use their own
+		log.Debug("Re-executing Firehose block of the reorganized branch", "number", branchBlock.NumberU64(), "hash", branchBlock.Hash())
+		if err := bc.reprocessFirehoseBlock(branchBlock, bytes.NewBuffer(nil)); err != nil {
+			return fmt.Errorf("firehose reorganized branch block #%d (%s): %w", branchBlock.NumberU64(), branchBlock.Hash(), err)
+		}
+	}
+	if reexecute {
+		return nil
+	}
+
+	return bc.flushFirehoseBlock(firehoseContext, block, CanonStatTy, bc.GetHeader(block.ParentHash(), block.NumberU64()-1))
+}
+
+// isFirehoseReplay returns true if the block was already emitted completely before the node
+// restarted, that is if it's the last block completely emitted before the restart, as tracked
+// by the persisted Firehose cursor, or one of its ancestors. Once a block above it is
+// emitted, the stream moved past the restart and no block is a replay anymore.
+func (bc *BlockChain) isFirehoseReplay(block *types.Block) bool {
+	last := bc.firehoseReplayed
+	if last == nil {
+		return false
+	}
+
+	number := block.NumberU64()
+	if number > last.Number {
+		bc.firehoseReplayed = nil
+		return false
+	}
+
+	if number == last.Number {
+		// An incomplete block was cut short, emitting it again is not a replay
+		return last.Complete && block.Hash() == last.Hash
+	}
+
+	maxNonCanonical := uint64(math.MaxUint64)
+	ancestor, _ := bc.GetAncestor(last.Hash, last.Number, last.Number-number, &maxNonCanonical)
+	return ancestor == block.Hash()
+}
+
+// recordFirehoseReplayedBlock flags the block being recorded as replayed when it was already
+// emitted before the node restarted, see `firehose.ReplayPolicyMark`.
+func (bc *BlockChain) recordFirehoseReplayedBlock(firehoseContext *firehose.Context, block *types.Block) {
+	// A block emitted in segments cannot be skipped anymore, it's marked whatever the policy
+	if (firehose.BlockReplayPolicy == firehose.ReplayPolicyMark || firehoseContext.Segmented()) && bc.isFirehoseReplay(block) {
+		firehoseContext.RecordReplayedBlock(block)
+	}
+}
+
+// newFirehoseBlockContext returns the context recording an executed block in `buffer`, it
+// builds the block's typed trace when an index is enabled since the indexes are built out of
+// it.
+func newFirehoseBlockContext(buffer *bytes.Buffer) *firehose.Context {
+	firehoseContext := firehose.NewBlockContextWithBuffer(buffer)
+	if firehose.CallIndexEnabled || firehose.TransferIndexEnabled {
+		firehoseContext.EnableBlockTrace()
+	}
+
+	return firehoseContext
+}
+
+// indexFirehoseBlock indexes, in each enabled index, the Firehose block `trace` emitted for
+// `block`.
+func (bc *BlockChain) indexFirehoseBlock(block *types.Block, trace *firehose.BlockTrace) {
+	if trace == nil {
+		return
+	}
+
+	bc.indexFirehoseCalls(block, trace)
+	bc.indexFirehoseTransfers(block, trace)
+}
+
+// indexFirehoseCalls indexes, if enabled, each call of the Firehose block `trace` emitted for
+// `block` under both its caller and its callee, see `FirehoseCallsByAddress`.
+func (bc *BlockChain) indexFirehoseCalls(block *types.Block, trace *firehose.BlockTrace) {
+	if !firehose.CallIndexEnabled {
+		return
+	}
+
+	index := make(map[common.Address][]*rawdb.FirehoseCallLocation)
+	for _, trx := range trace.Transactions {
+		for _, call := range trx.Calls {
+			location := &rawdb.FirehoseCallLocation{TxIndex: trx.Index, TxHash: trx.Hash, CallIndex: call.Index, Ordinal: call.BeginOrdinal}
+
+			index[call.Caller] = append(index[call.Caller], location)
+			if call.Address != call.Caller {
+				index[call.Address] = append(index[call.Address], location)
+			}
+		}
+	}
+
+	batch := bc.db.NewBatch()
+	for address, locations := range index {
+		rawdb.WriteFirehoseCallIndex(batch, address, block.NumberU64(), block.Hash(), locations)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write Firehose call index", "err", err)
+	}
+}
+
+// indexFirehoseTransfers indexes, if enabled, each value transfer of the Firehose block `trace`
+// emitted for `block` under both its sender and its recipient, see `FirehoseTransfersByAddress`.
+func (bc *BlockChain) indexFirehoseTransfers(block *types.Block, trace *firehose.BlockTrace) {
+	if !firehose.TransferIndexEnabled {
+		return
+	}
+
+	index := make(map[common.Address][]*rawdb.FirehoseTransfer)
+	for _, transfer := range trace.ValueTransfers() {
+		indexed := &rawdb.FirehoseTransfer{Kind: string(transfer.Kind), To: transfer.To, Value: transfer.Value, Ordinal: transfer.Ordinal}
+		if transfer.Transaction != nil {
+			indexed.TxIndex, indexed.TxHash = transfer.Transaction.Index, transfer.Transaction.Hash
+		}
+
+		index[transfer.To] = append(index[transfer.To], indexed)
+		if transfer.From != nil {
+			indexed.From = *transfer.From
+			if *transfer.From != transfer.To {
+				index[*transfer.From] = append(index[*transfer.From], indexed)
+			}
+		}
+	}
+
+	batch := bc.db.NewBatch()
+	for address, transfers := range index {
+		rawdb.WriteFirehoseTransferIndex(batch, address, block.NumberU64(), block.Hash(), transfers)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write Firehose transfer index", "err", err)
+	}
+}
+
+// FirehoseCallLocation locates, in the canonical chain, a call indexed for an address.
+type FirehoseCallLocation struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	*rawdb.FirehoseCallLocation
+}
+
+// FirehoseCallsByAddress returns the calls, made by or to `address`, of the canonical blocks
+// from `from` up to `to` included, in their execution order. Only the blocks emitted while
+// the call index is enabled are indexed, see `firehose.CallIndexEnabled`.
+func (bc *BlockChain) FirehoseCallsByAddress(address common.Address, from uint64, to uint64) []*FirehoseCallLocation {
+	var calls []*FirehoseCallLocation
+	for _, entry := range rawdb.ReadFirehoseCallIndex(bc.db, address, from, to) {
+		if rawdb.ReadCanonicalHash(bc.db, entry.Number) != entry.Hash {
+			continue
+		}
+
+		for _, call := range entry.Calls {
+			calls = append(calls, &FirehoseCallLocation{BlockNumber: entry.Number, BlockHash: entry.Hash, FirehoseCallLocation: call})
+		}
+	}
+
+	return calls
+}
+
+// storeFirehoseBlock persists, if enabled, the Firehose block emitted for `block` out of its
+// `payload`, so it can be fetched again later, see `FirehoseBlock`. Only the blocks within
+// the retention window, below the highest of the chain head and `block`, are kept, the ones
+// leaving it are pruned.
+//
+// It must be called before the block is flushed, a `firehosestream` subscriber tapping the
+// sink after the block was written to it then finds the block in the store.
+func (bc *BlockChain) storeFirehoseBlock(block *types.Block, payload []byte) {
+	retention := firehose.BlockStoreRetention
+	if retention == 0 {
+		return
+	}
+
+	number, highest := block.NumberU64(), bc.CurrentBlock().NumberU64()
+	if number > highest {
+		highest = number
+	}
+	if number+retention <= highest {
+		return
+	}
+
+	rawdb.WriteFirehoseBlock(bc.db, number, block.Hash(), firehose.FramedBlock(number, block.Hash(), payload))
+	if highest >= retention {
+		rawdb.DeleteFirehoseBlocksBelow(bc.db, highest-retention+1)
+	}
+}
+
+// FirehoseBlock returns the Firehose block emitted for the block with `hash`, framed by its
+// `BLOCK_BEGIN` and `BLOCK_END` markers, nil if it's not stored. Blocks are stored only when
+// enabled and for the retention window, see `firehose.BlockStoreRetention`.
+func (bc *BlockChain) FirehoseBlock(hash common.Hash) []byte {
+	number := rawdb.ReadHeaderNumber(bc.db, hash)
+	if number == nil {
+		return nil
+	}
+
+	return rawdb.ReadFirehoseBlock(bc.db, *number, hash)
+}
+
+// FirehoseTransfer is a value transfer, of a canonical block, indexed for an address.
+type FirehoseTransfer struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	*rawdb.FirehoseTransfer
+}
+
+// FirehoseTransfersByAddress returns the value transfers, sent or received by `address`, of
+// the canonical blocks from `from` up to `to` included, in their execution order. Only the
+// blocks emitted while the transfer index is enabled are indexed, see
+// `firehose.TransferIndexEnabled`.
+func (bc *BlockChain) FirehoseTransfersByAddress(address common.Address, from uint64, to uint64) []*FirehoseTransfer {
+	var transfers []*FirehoseTransfer
+	for _, entry := range rawdb.ReadFirehoseTransferIndex(bc.db, address, from, to) {
+		if rawdb.ReadCanonicalHash(bc.db, entry.Number) != entry.Hash {
+			continue
+		}
+
+		for _, transfer := range entry.Transfers {
+			transfers = append(transfers, &FirehoseTransfer{BlockNumber: entry.Number, BlockHash: entry.Hash, FirehoseTransfer: transfer})
+		}
+	}
+
+	return transfers
+}
+
+// undoFirehoseBlocks emits a Firehose undo record for each emitted block at or above `number`,
+// from the last emitted one down, so that a block at `number`, on another branch or emitted
+// again, can be emitted without making the stream ambiguous.
+func (bc *BlockChain) undoFirehoseBlocks(number uint64) error {
+	return bc.undoFirehoseBlocksWhile(func(last *firehose.EmittedBlock) bool { return last.Number >= number })
+}
+
+// undoFirehoseBlocksWhile emits a Firehose undo record for the last emitted block as long as
+// `undo` returns true for it.
+func (bc *BlockChain) undoFirehoseBlocksWhile(undo func(last *firehose.EmittedBlock) bool) error {
+	syncContext := firehose.SyncContext()
+	for last := syncContext.LastEmittedBlock(); last != nil && undo(last); last = syncContext.LastEmittedBlock() {
+		header := bc.GetHeader(last.Hash, last.Number)
+		if header == nil {
+			return fmt.Errorf("firehose undo block #%d (%s): header not found", last.Number, last.Hash)
+		}
+
+		if err := syncContext.RecordUndoBlock(header, bc.firehoseBlockLogs(header)); err != nil {
+			return fmt.Errorf("firehose undo block: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// firehoseGenesisView adapts a genesis to the view Firehose has of it.
+type firehoseGenesisView struct {
+	genesis *Genesis
+}
+
+// NewFirehoseGenesisView returns the Firehose view of `genesis`, nil if `genesis` is nil,
+// see `firehose.GenesisView`.
+func NewFirehoseGenesisView(genesis *Genesis) firehose.GenesisView {
+	if genesis == nil {
+		return nil
+	}
+
+	return &firehoseGenesisView{genesis: genesis}
+}
+
+// DecodeFirehoseGenesis decodes the JSON genesis read from `reader` into its Firehose view.
+func DecodeFirehoseGenesis(reader io.Reader) (firehose.GenesisView, error) {
+	genesis := new(Genesis)
+	if err := json.NewDecoder(reader).Decode(genesis); err != nil {
+		return nil, err
+	}
+
+	return NewFirehoseGenesisView(genesis), nil
+}
+
+func (v *firehoseGenesisView) Header() *types.Header {
+	return v.genesis.ToBlock(nil).Header()
+}
+
+func (v *firehoseGenesisView) ChainConfig() *params.ChainConfig {
+	return v.genesis.Config
+}
+
+func (v *firehoseGenesisView) ForEachAccount(fn func(account *firehose.GenesisAccount)) {
+	for addr, account := range v.genesis.Alloc {
+		fn(&firehose.GenesisAccount{
+			Address: addr,
+			Balance: account.Balance,
+			Code:    account.Code,
+			Nonce:   account.Nonce,
+			Storage: account.Storage,
+		})
+	}
+}
+
+// firehoseBlockLogs returns the logs of the block `header`, in their block order, nil if
+// its receipts are not known.
+func (bc *BlockChain) firehoseBlockLogs(header *types.Header) []*types.Log {
+	var logs []*types.Log
+	for _, receipt := range bc.GetReceiptsByHash(header.Hash()) {
+		logs = append(logs, receipt.Logs...)
+	}
+
+	return logs
+}
+
+// firehoseHeartbeatLoop emits a Firehose heartbeat holding the chain head at each tick of
+// `firehose.HeartbeatInterval` when no block was emitted for at least the interval.
+func (bc *BlockChain) firehoseHeartbeatLoop() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(firehose.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if now.Sub(firehose.LastBlockWriteTime()) >= firehose.HeartbeatInterval {
+				firehose.MaybeSyncContext().RecordHeartbeat(bc.CurrentBlock().Header(), now)
+			}
+
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// recordFirehoseHeadUpdate emits a Firehose head update when the fork choice made `block`
+// the chain head while it's not a child of `previousHead`, i.e. when the chain reorganized.
+func (bc *BlockChain) recordFirehoseHeadUpdate(block *types.Block, previousHead *types.Block) {
+	if block.ParentHash() == previousHead.Hash() || bc.CurrentBlock().Hash() != block.Hash() {
+		return
+	}
+
+	firehose.MaybeSyncContext().RecordHeadUpdate(block, previousHead)
+}
+
+// recordFirehoseSideChainBlock emits a Firehose side chain block record once `block`, imported
+// on a side chain, was emitted.
+func (bc *BlockChain) recordFirehoseSideChainBlock(block *types.Block) {
+	// Walk back the side chain up to the canonical block it forks from
+	forkParent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	for forkParent != nil && bc.GetCanonicalHash(forkParent.Number.Uint64()) != forkParent.Hash() {
+		forkParent = bc.GetHeader(forkParent.ParentHash, forkParent.Number.Uint64()-1)
+	}
+	if forkParent == nil {
+		log.Warn("Firehose side chain block fork parent not found", "number", block.NumberU64(), "hash", block.Hash())
+		return
+	}
+
+	firehose.MaybeSyncContext().RecordSideChainBlock(block, forkParent)
+}
+
+// emitFirehoseNonExecutedBlocks emits the blocks imported along with their receipts without
+// being executed as non-executed Firehose blocks, if enabled. The persisted Firehose cursor is
+// left untouched, those blocks cannot be re-executed to be re-emitted.
+func (bc *BlockChain) emitFirehoseNonExecutedBlocks(blocks types.Blocks, receipts []types.Receipts) error {
+	if !firehose.NonExecutedBlocksEnabled || !firehose.MaybeSyncContext().Enabled() {
+		return nil
+	}
+
+	for i, block := range blocks {
+		if err := bc.undoFirehoseBlocks(block.NumberU64()); err != nil {
+			return err
+		}
+
+		firehoseContext := firehose.NewBlockContextWithBuffer(firehose.BlockSyncBuffer)
+		if err := firehoseContext.RecordNonExecutedBlock(block, receipts[i], bc.chainConfig, bc.GetTd(block.Hash(), block.NumberU64())); err != nil {
+			return fmt.Errorf("firehose non-executed block #%d (%s): %w", block.NumberU64(), block.Hash(), err)
+		}
+
+		bc.storeFirehoseBlock(block, firehoseContext.FirehoseLog())
+		if err := firehoseContext.FlushBlock(); err != nil {
+			return fmt.Errorf("firehose flush block: %w", err)
+		}
+		firehose.ThrottleSync()
+	}
+
+	if len(blocks) > 0 {
+		from, to := blocks[0].NumberU64(), blocks[len(blocks)-1].NumberU64()
+
+		backfill := rawdb.ReadFirehoseBackfill(bc.db)
+		if backfill == nil || backfill.Next > backfill.To {
+			backfill = &rawdb.FirehoseBackfill{From: from, To: to, Next: from}
+		} else if to > backfill.To {
+			backfill.To = to
+		}
+		rawdb.WriteFirehoseBackfill(bc.db, backfill)
+	}
+
+	return nil
+}
+
+// FirehoseBackfillStatus is the progress of the Firehose backfill of the blocks emitted as
+// non-executed blocks.
+type FirehoseBackfillStatus struct {
+	From    uint64 `json:"from"`
+	To      uint64 `json:"to"`
+	Next    uint64 `json:"next"`
+	Running bool   `json:"running"`
+}
+
+// FirehoseBackfillStatus returns the progress of the Firehose backfill, nil if no block was
+// ever emitted as a non-executed block.
+func (bc *BlockChain) FirehoseBackfillStatus() *FirehoseBackfillStatus {
+	backfill := rawdb.ReadFirehoseBackfill(bc.db)
+	if backfill == nil {
+		return nil
+	}
+
+	return &FirehoseBackfillStatus{
+		From:    backfill.From,
+		To:      backfill.To,
+		Next:    backfill.Next,
+		Running: atomic.LoadInt32(&bc.firehoseBackfilling) == 1,
+	}
+}
+
+// StartFirehoseBackfill starts, in the background, the re-execution of the blocks emitted as
+// non-executed blocks to emit their full Firehose block. It must be called once the blocks
+// are not imported without execution anymore (i.e. fast or snap sync completed). It's a no-op
+// if the backfill is already running, completed or if Firehose is not enabled.
+//
+// The state of the blocks is reconstructed by re-executing the chain from the closest block
+// below the range whose state is available, usually the genesis block.
+func (bc *BlockChain) StartFirehoseBackfill() {
+	if !firehose.MaybeSyncContext().Enabled() {
+		return
+	}
+
+	backfill := rawdb.ReadFirehoseBackfill(bc.db)
+	if backfill == nil || backfill.Next > backfill.To {
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&bc.firehoseBackfilling, 0, 1) {
+		return
+	}
+
+	bc.wg.Add(1)
+	go func() {
+		defer bc.wg.Done()
+		defer atomic.StoreInt32(&bc.firehoseBackfilling, 0)
+
+		if err := bc.backfillFirehose(backfill); err != nil {
+			log.Error("Firehose backfill failed, non-executed blocks are not re-emitted", "next", backfill.Next, "to", backfill.To, "err", err)
+		}
+	}()
+}
+
+func (bc *BlockChain) backfillFirehose(backfill *rawdb.FirehoseBackfill) error {
+	// Find the closest block below the backfill whose state is available
+	block := bc.GetBlockByNumber(backfill.Next - 1)
+	for block != nil && !bc.HasState(block.Root()) {
+		block = bc.GetBlockByNumber(block.NumberU64() - 1)
+	}
+	if block == nil {
+		return fmt.Errorf("no state available below block #%d", backfill.Next)
+	}
+
+	database := state.NewDatabaseWithConfig(bc.db, &trie.Config{Cache: 16})
+	statedb, err := state.New(block.Root(), database, nil)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Starting Firehose backfill of non-executed blocks", "from", backfill.Next, "to", backfill.To, "state", block.NumberU64())
+
+	var (
+		start  = time.Now()
+		logged time.Time
+		parent common.Hash
+	)
+	defer func() {
+		if parent != (common.Hash{}) {
+			database.TrieDB().Dereference(parent)
+		}
+	}()
+
+	for block.NumberU64() < backfill.To {
+		select {
+		case <-bc.quit:
+			log.Info("Firehose backfill interrupted", "next", backfill.Next, "to", backfill.To)
+			return nil
+		default:
+		}
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Firehose backfilling non-executed blocks", "block", block.NumberU64()+1, "next", backfill.Next, "to", backfill.To, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+
+		number := block.NumberU64() + 1
+		if block = bc.GetBlockByNumber(number); block == nil {
+			return fmt.Errorf("block #%d not found", number)
+		}
+
+		root, err := bc.backfillFirehoseBlock(block, statedb, number >= backfill.Next)
+		if err != nil {
+			return fmt.Errorf("block #%d (%s): %w", number, block.Hash(), err)
+		}
+
+		// Hold the state of the block in memory, releasing the parent's one
+		database.TrieDB().Reference(root, common.Hash{})
+		if parent != (common.Hash{}) {
+			database.TrieDB().Dereference(parent)
+		}
+		parent = root
+
+		if nodes, _ := database.TrieDB().Size(); nodes > firehoseBackfillTrieMemory {
+			if err := database.TrieDB().Cap(firehoseBackfillTrieMemory / 2); err != nil {
+				return err
+			}
+		}
+
+		if statedb, err = state.New(root, database, nil); err != nil {
+			return err
+		}
+
+		if number >= backfill.Next {
+			backfill.Next = number + 1
+			rawdb.WriteFirehoseBackfill(bc.db, backfill)
+		}
+	}
+
+	log.Info("Completed Firehose backfill of non-executed blocks", "from", backfill.From, "to", backfill.To, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// backfillFirehoseBlock re-executes `block` on top of `statedb`, emitting it as a backfilled
+// Firehose block if `emit` is true, and returns the resulting state root.
+func (bc *BlockChain) backfillFirehoseBlock(block *types.Block, statedb *state.StateDB, emit bool) (common.Hash, error) {
+	// Imported blocks are processed and emitted under the chain lock, the Firehose buffers are
+	// shared and the emission of a block cannot be interleaved with another one
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	firehoseContext := firehose.NoOpContext
+	if emit {
+		firehoseContext = newFirehoseBlockContext(firehose.BlockSyncBuffer)
+		if firehose.BlockWitnessEnabled {
+			statedb.TrackAccessedState()
+		}
+	}
+
+	if _, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig, firehoseContext); err != nil {
+		return common.Hash{}, err
+	}
+
+	if err := bc.recordFirehoseBlockWitness(firehoseContext, block, statedb); err != nil {
+		return common.Hash{}, fmt.Errorf("firehose block witness: %w", err)
+	}
+
+	root, err := statedb.Commit(bc.chainConfig.IsEIP158(block.Number()))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if root != block.Root() {
+		return common.Hash{}, fmt.Errorf("state root mismatch, re-executed %s, expected %s", root, block.Root())
+	}
+
+	if emit {
+		firehoseContext.RecordBackfilledBlock(block)
+		firehoseContext.EndBlock(block, bc.GetTd(block.Hash(), block.NumberU64()))
+
+		// The backfilled block replaces the non-executed one stored, if any, a block emitted in
+		// segments is not stored, the non-executed one is then removed
+		payload, trace, segmented := firehoseContext.FirehoseLog(), firehoseContext.BlockTrace(), firehoseContext.Segmented()
+		if segmented {
+			rawdb.DeleteFirehoseBlock(bc.db, block.NumberU64(), block.Hash())
+		} else {
+			bc.storeFirehoseBlock(block, payload)
+		}
+		if err := firehoseContext.FlushBlock(); err != nil {
+			return common.Hash{}, fmt.Errorf("firehose flush block: %w", err)
+		}
+		bc.indexFirehoseBlock(block, trace)
+		firehose.ThrottleSync()
+	}
+
+	return root, nil
+}
+
+// reemitIncompleteFirehoseBlock checks the persisted Firehose cursor and, if the emission of
+// the last blocks was cut short (most probably by a crash), re-executes them to emit them
+// again, from the cursor's block up to the chain head since the sink may have held several
+// blocks in memory. Readers discard the partial block since it has a `BLOCK_BEGIN` without
+// `BLOCK_END`.
+//
+// When the state a block is re-executed against was pruned, the chain is rewound below the
+// block instead, the blocks are emitted once re-imported. Any other failure is returned, the
+// node must not start with a gap in the Firehose stream.
+func (bc *BlockChain) reemitIncompleteFirehoseBlock() error {
+	cursor := rawdb.ReadFirehoseCursor(bc.db)
+	if cursor == nil || cursor.Complete {
+		return nil
+	}
+
+	head := bc.CurrentBlock().NumberU64()
+	if cursor.Number > head {
+		// The chain was rewound below the block, it's going to be re-imported and emitted normally
+		log.Info("Firehose incomplete block is above chain head, it will be emitted on re-import", "number", cursor.Number, "hash", cursor.Hash, "head", head)
+		return nil
+	}
+
+	block := bc.GetBlock(cursor.Hash, cursor.Number)
+	if block == nil {
+		// The block never made it to the database, it's going to be imported and emitted normally
+		log.Info("Firehose incomplete block not found in database, it will be emitted on import", "number", cursor.Number, "hash", cursor.Hash)
+		return nil
+	}
+
+	log.Warn("Re-emitting Firehose blocks whose emission was cut short", "from", cursor.Number, "hash", cursor.Hash, "to", head)
+	for block != nil {
+		if parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1); parent != nil && !bc.HasState(parent.Root) {
+			log.Warn("Firehose incomplete block parent state is missing, rewinding the chain to re-import it", "number", block.NumberU64(), "hash", block.Hash(), "root", parent.Root)
+			if err := bc.SetHead(block.NumberU64() - 1); err != nil {
+				return fmt.Errorf("firehose rewind chain below incomplete block %d (%s): %w", block.NumberU64(), block.Hash(), err)
+			}
+			return nil
+		}
+
+		if err := bc.reprocessFirehoseBlock(block, firehose.BlockSyncBuffer); err != nil {
+			return fmt.Errorf("firehose re-emit incomplete block %d (%s): %w", block.NumberU64(), block.Hash(), err)
+		}
+
+		if block.NumberU64() >= head {
+			break
+		}
+		block = bc.GetBlockByNumber(block.NumberU64() + 1)
+	}
+
+	return nil
+}
+
+// reprocessFirehoseBlock re-executes an already imported block against its parent state
+// and emits the resulting Firehose block, recorded in `buffer`.
+func (bc *BlockChain) reprocessFirehoseBlock(block *types.Block, buffer *bytes.Buffer) error {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return fmt.Errorf("parent block %d (%s) not found", block.NumberU64()-1, block.ParentHash())
+	}
+
+	statedb, err := state.New(parent.Root, bc.stateCache, bc.snaps)
+	if err != nil {
+		return fmt.Errorf("parent state: %w", err)
+	}
+
+	if firehose.BlockWitnessEnabled {
+		statedb.TrackAccessedState()
+	}
+
+	firehoseContext := newFirehoseBlockContext(buffer)
+	if _, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig, firehoseContext); err != nil {
+		return fmt.Errorf("process block: %w", err)
+	}
+
+	if err := bc.recordFirehoseBlockWitness(firehoseContext, block, statedb); err != nil {
+		return fmt.Errorf("block witness: %w", err)
+	}
+
+	firehoseContext.EndBlock(block, bc.GetTd(block.Hash(), block.NumberU64()))
+	return bc.flushFirehoseBlock(firehoseContext, block, CanonStatTy, parent)
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// BenchmarkFirehoseProcess measures the overhead of the Firehose instrumentation by
+// processing the same corpus of blocks with Firehose disabled and with Firehose enabled for
+// each output format. An operation is the processing of a single block, the time and the
+// allocations per operation are per block.
+func BenchmarkFirehoseProcess(b *testing.B) {
+	b.Run("off", func(b *testing.B) { benchmarkFirehoseProcess(b, "") })
+	b.Run("text", func(b *testing.B) { benchmarkFirehoseProcess(b, firehose.OutputFormatText) })
+	b.Run("jsonl", func(b *testing.B) { benchmarkFirehoseProcess(b, firehose.OutputFormatJSONLines) })
+}
+
+// benchmarkFirehoseProcess processes, one at a time and over and over again, the blocks of
+// the fixture corpus with Firehose enabled and its output encoded in `format`, or with
+// Firehose disabled when `format` is empty.
+func benchmarkFirehoseProcess(b *testing.B, format firehose.OutputFormat) {
+	defer func(enabled bool, blockBuffer, txBuffer *bytes.Buffer) {
+		firehose.Enabled, firehose.BlockSyncBuffer, firehose.TxSyncBuffer = enabled, blockBuffer, txBuffer
+	}(firehose.Enabled, firehose.BlockSyncBuffer, firehose.TxSyncBuffer)
+
+	config, db, genesis, blocks := firehoseBenchmarkCorpus(b)
+	chain, err := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer chain.Stop()
+	processor := NewStateProcessor(config, chain, chain.Engine())
+	stateDatabase := state.NewDatabase(db)
+
+	// The output is discarded once encoded, only its size is accounted
+	output := &countingDiscard{}
+	var sink firehose.Printer
+	switch format {
+	case firehose.OutputFormatText:
+		sink = firehose.NewDelegateToWriterPrinter(context.Background(), output)
+	case firehose.OutputFormatJSONLines:
+		sink = firehose.NewJSONLinesPrinter(firehose.NewDelegateToWriterPrinter(context.Background(), output))
+	}
+
+	firehose.Enabled = sink != nil
+	firehose.BlockSyncBuffer, firehose.TxSyncBuffer = bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index := i % len(blocks)
+		block, parent := blocks[index], genesis
+		if index > 0 {
+			parent = blocks[index-1]
+		}
+
+		b.StopTimer()
+		statedb, err := state.New(parent.Root(), stateDatabase, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+
+		firehoseContext := firehose.NoOpContext
+		if sink != nil {
+			firehoseContext = firehose.NewBlockContextWithBuffer(firehose.BlockSyncBuffer)
+		}
+		if _, _, _, err := processor.Process(block, statedb, vm.Config{}, firehoseContext); err != nil {
+			b.Fatalf("block #%d: %v", block.NumberU64(), err)
+		}
+		if sink != nil {
+			firehoseContext.EndBlock(block, block.Difficulty())
+			sink.Write(firehose.BlockSyncBuffer.Bytes())
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(output.written)/float64(b.N), "bytes/block")
+}
+
+// firehoseBenchmarkCorpus generates the fixture blocks of the Firehose benchmarks on top of
+// the returned genesis block. Each block holds value transfers, calls storing a value and
+// logging it, and a contract creation.
+func firehoseBenchmarkCorpus(b *testing.B) (*params.ChainConfig, ethdb.Database, *types.Block, []*types.Block) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xcc")
+		db       = rawdb.NewMemoryDatabase()
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(params.Ether)},
+				// PUSH1 0x00 CALLDATALOAD PUSH1 0x00 SSTORE PUSH1 0x20 PUSH1 0x00 LOG0 STOP
+				contract: {Balance: common.Big0, Code: common.FromHex("60003560005560206000a000")},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+
+	sign := func(tx *types.Transaction) *types.Transaction {
+		signed, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return signed
+	}
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 32, func(i int, block *BlockGen) {
+		for j := 0; j < 8; j++ {
+			block.AddTx(sign(types.NewTransaction(block.TxNonce(address), common.Address{0xaa, byte(j)}, big.NewInt(1000), params.TxGas, nil, nil)))
+		}
+		for j := 0; j < 8; j++ {
+			input := common.BigToHash(big.NewInt(int64(i*8 + j + 1))).Bytes()
+			block.AddTx(sign(types.NewTransaction(block.TxNonce(address), contract, common.Big0, 100000, nil, input)))
+		}
+		block.AddTx(sign(types.NewContractCreation(block.TxNonce(address), common.Big0, 100000, nil, common.FromHex("60003560005560206000a000"))))
+	})
+
+	return gspec.Config, db, genesis, blocks
+}
+
+// countingDiscard discards everything written to it, accounting only for its size.
+type countingDiscard struct {
+	written int
+}
+
+func (w *countingDiscard) Write(in []byte) (int, error) {
+	w.written += len(in)
+	return len(in), nil
+}
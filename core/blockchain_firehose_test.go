@@ -0,0 +1,567 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/decode"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// firehoseTestKey is the key of the account funded by the genesis of the Firehose test chains.
+var firehoseTestKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// firehoseTestChain is a chain created by `newFirehoseTestChain`, generating the blocks to
+// import on top of its genesis.
+type firehoseTestChain struct {
+	*BlockChain
+
+	t      *testing.T
+	gendb  ethdb.Database
+	sender common.Address
+	signer types.Signer
+}
+
+// newFirehoseTestChain creates a chain out of a genesis funding the account of `firehoseTestKey`
+// along with the accounts of `alloc`, then turns on the Firehose `flags`. They are turned on only
+// once the chain is created, the genesis block is not emitted. Once the test completes, the
+// emitted blocks are undone and the Firehose settings changed by the tests are restored.
+func newFirehoseTestChain(t *testing.T, alloc GenesisAlloc, flags ...*bool) *firehoseTestChain {
+	t.Helper()
+
+	var (
+		enabled, syncEnabled, nonExecutedEnabled = firehose.Enabled, firehose.SyncInstrumentationEnabled, firehose.NonExecutedBlocksEnabled
+		sideEnabled, witnessEnabled              = firehose.SideChainBlocksEnabled, firehose.BlockWitnessEnabled
+		callIndexEnabled, transferIndexEnabled   = firehose.CallIndexEnabled, firehose.TransferIndexEnabled
+		retention, replayPolicy, buffer          = firehose.BlockStoreRetention, firehose.BlockReplayPolicy, firehose.BlockSyncBuffer
+	)
+	t.Cleanup(func() {
+		firehose.Enabled, firehose.SyncInstrumentationEnabled, firehose.NonExecutedBlocksEnabled = enabled, syncEnabled, nonExecutedEnabled
+		firehose.SideChainBlocksEnabled, firehose.BlockWitnessEnabled = sideEnabled, witnessEnabled
+		firehose.CallIndexEnabled, firehose.TransferIndexEnabled = callIndexEnabled, transferIndexEnabled
+		firehose.BlockStoreRetention, firehose.BlockReplayPolicy = retention, replayPolicy
+	})
+
+	sender := crypto.PubkeyToAddress(firehoseTestKey.PublicKey)
+	gspec := &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{sender: {Balance: big.NewInt(1000000000)}}}
+	for address, account := range alloc {
+		gspec.Alloc[address] = account
+	}
+
+	gendb, db := rawdb.NewMemoryDatabase(), rawdb.NewMemoryDatabase()
+	gspec.MustCommit(gendb)
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+
+	for _, flag := range flags {
+		*flag = true
+	}
+	t.Cleanup(func() { chain.undoFirehoseBlocks(0) })
+
+	firehose.BlockSyncBuffer = bytes.NewBuffer(nil)
+	t.Cleanup(func() { firehose.BlockSyncBuffer = buffer })
+
+	return &firehoseTestChain{BlockChain: chain, t: t, gendb: gendb, sender: sender, signer: types.LatestSigner(gspec.Config)}
+}
+
+// generate generates `n` blocks on top of the genesis, `gen` adding their content.
+func (c *firehoseTestChain) generate(n int, gen func(i int, block *BlockGen)) ([]*types.Block, []types.Receipts) {
+	return GenerateChain(c.chainConfig, c.genesisBlock, ethash.NewFaker(), c.gendb, n, gen)
+}
+
+// addTx adds to `block` a transaction sent by the account of `firehoseTestKey`.
+func (c *firehoseTestChain) addTx(block *BlockGen, to common.Address, value *big.Int, gas uint64, gasPrice *big.Int) {
+	tx, err := types.SignTx(types.NewTransaction(block.TxNonce(c.sender), to, value, gas, gasPrice, nil), c.signer, firehoseTestKey)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	block.AddTx(tx)
+}
+
+func TestFirehoseBackfill(t *testing.T) {
+	chain := newFirehoseTestChain(t, nil, &firehose.Enabled, &firehose.SyncInstrumentationEnabled, &firehose.NonExecutedBlocksEnabled)
+	blocks, receipts := chain.generate(16, func(i int, block *BlockGen) {
+		chain.addTx(block, common.Address{0xaa}, big.NewInt(1000), params.TxGas, nil)
+	})
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts, 0); err != nil {
+		t.Fatalf("failed to insert receipt %d: %v", n, err)
+	}
+
+	want := FirehoseBackfillStatus{From: 1, To: 16, Next: 1}
+	if status := chain.FirehoseBackfillStatus(); status == nil || *status != want {
+		t.Fatalf("backfill status mismatch: have %+v, want %+v", status, want)
+	}
+
+	chain.StartFirehoseBackfill()
+
+	want = FirehoseBackfillStatus{From: 1, To: 16, Next: 17}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		status := chain.FirehoseBackfillStatus()
+		if *status == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backfill did not complete: have %+v, want %+v", status, want)
+		}
+	}
+}
+
+func TestFirehoseUndoBlocks(t *testing.T) {
+	logger := common.Address{0xcc}
+	chain := newFirehoseTestChain(t, GenesisAlloc{
+		// PUSH1 0x00 PUSH1 0x00 LOG0 STOP, emits an empty log
+		logger: {Balance: big.NewInt(0), Code: common.FromHex("0x60006000a000")},
+	}, &firehose.Enabled, &firehose.SyncInstrumentationEnabled)
+	canonical, _ := chain.generate(3, func(i int, block *BlockGen) {
+		chain.addTx(block, logger, big.NewInt(0), 100000, nil)
+	})
+	fork, _ := chain.generate(4, func(i int, block *BlockGen) {
+		block.SetCoinbase(common.Address{0xaa})
+	})
+
+	if n, err := chain.InsertChain(canonical); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+
+	// The fork blocks are emitted once the fork becomes canonical, the emitted canonical blocks
+	// at their heights are undone first instead of refusing the fork blocks
+	if n, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork block %d: %v", n, err)
+	}
+
+	// The logs of the undone blocks, recorded as removed along the undo records, are still known
+	for _, block := range canonical[1:] {
+		if logs := chain.firehoseBlockLogs(block.Header()); len(logs) != 1 || logs[0].Address != logger || logs[0].BlockHash != block.Hash() {
+			t.Fatalf("undone block #%d logs mismatch: have %v", block.NumberU64(), logs)
+		}
+	}
+
+	last, head := firehose.SyncContext().LastEmittedBlock(), chain.CurrentBlock()
+	if last == nil || last.Number != head.NumberU64() || last.Hash != head.Hash() {
+		t.Fatalf("last emitted block mismatch: have %v, want #%d (%s)", last, head.NumberU64(), head.Hash())
+	}
+
+	// Rewinding the chain undoes the rewound blocks
+	if err := chain.SetHead(1); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if last := firehose.SyncContext().LastEmittedBlock(); last == nil || last.Number != 1 || last.Hash != fork[0].Hash() {
+		t.Fatalf("last emitted block after rewind mismatch: have %v, want #1 (%s)", last, fork[0].Hash())
+	}
+}
+
+func TestFirehoseSideChainBlocks(t *testing.T) {
+	t.Run("dropped", func(t *testing.T) { testFirehoseSideChainBlocks(t, false) })
+	t.Run("emitted", func(t *testing.T) { testFirehoseSideChainBlocks(t, true) })
+}
+
+func testFirehoseSideChainBlocks(t *testing.T, sideChainBlocks bool) {
+	chain := newFirehoseTestChain(t, nil, &firehose.Enabled, &firehose.SyncInstrumentationEnabled)
+	firehose.SideChainBlocksEnabled, firehose.BlockStoreRetention = sideChainBlocks, 8
+
+	canonical, _ := chain.generate(3, func(i int, block *BlockGen) {})
+	fork, _ := chain.generate(4, func(i int, block *BlockGen) {
+		block.SetCoinbase(common.Address{0xaa})
+	})
+
+	if n, err := chain.InsertChain(canonical); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+
+	// The side chain blocks are emitted only if enabled, out of the order of the chain, the
+	// emitted canonical blocks are not undone either way
+	if n, err := chain.InsertChain(fork[:2]); err != nil {
+		t.Fatalf("failed to insert side block %d: %v", n, err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != canonical[2].Hash() {
+		t.Fatalf("chain head mismatch: have #%d (%s), want #3 (%s)", head.NumberU64(), head.Hash(), canonical[2].Hash())
+	}
+	for _, block := range fork[:2] {
+		if emitted := chain.FirehoseBlock(block.Hash()) != nil; emitted != sideChainBlocks {
+			t.Errorf("side block #%d emitted mismatch: have %t, want %t", block.NumberU64(), emitted, sideChainBlocks)
+		}
+	}
+	if last := firehose.SyncContext().LastEmittedBlock(); last == nil || last.Hash != canonical[2].Hash() {
+		t.Fatalf("last emitted block mismatch: have %v, want #3 (%s)", last, canonical[2].Hash())
+	}
+	if cursor := rawdb.ReadFirehoseCursor(chain.db); cursor == nil || cursor.Hash != canonical[2].Hash() || !cursor.Complete {
+		t.Fatalf("cursor mismatch: have %+v, want #3 (%s) complete", cursor, canonical[2].Hash())
+	}
+
+	// Once the side chain becomes canonical, its blocks not emitted yet are emitted
+	if n, err := chain.InsertChain(fork[2:]); err != nil {
+		t.Fatalf("failed to insert fork block %d: %v", n, err)
+	}
+	for _, block := range fork {
+		if chain.FirehoseBlock(block.Hash()) == nil {
+			t.Errorf("fork block #%d not emitted", block.NumberU64())
+		}
+	}
+	if last := firehose.SyncContext().LastEmittedBlock(); last == nil || last.Hash != fork[3].Hash() {
+		t.Fatalf("last emitted block mismatch: have %v, want #4 (%s)", last, fork[3].Hash())
+	}
+}
+
+func TestFirehoseReplayedBlocks(t *testing.T) {
+	for _, policy := range []firehose.ReplayPolicy{firehose.ReplayPolicyMark, firehose.ReplayPolicySkip} {
+		t.Run(string(policy), func(t *testing.T) { testFirehoseReplayedBlocks(t, policy) })
+	}
+}
+
+func testFirehoseReplayedBlocks(t *testing.T, policy firehose.ReplayPolicy) {
+	chain := newFirehoseTestChain(t, GenesisAlloc{
+		// The coinbase exists, rewarding it does not create it out of any transaction
+		common.Address{}: {Balance: big.NewInt(1)},
+	}, &firehose.Enabled, &firehose.SyncInstrumentationEnabled)
+	firehose.BlockStoreRetention, firehose.BlockReplayPolicy = 8, policy
+
+	blocks, _ := chain.generate(4, func(i int, block *BlockGen) {})
+
+	// The headers are kept while the head block is rewound on restart, the blocks up to the
+	// persisted cursor were emitted before the restart and are re-imported
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	chain.firehoseReplayed = &rawdb.FirehoseCursor{Number: 2, Hash: blocks[1].Hash(), Complete: true}
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+
+	for i, block := range blocks {
+		replayed := i < 2
+		stored := chain.FirehoseBlock(block.Hash())
+		if policy == firehose.ReplayPolicySkip {
+			if replayed != (stored == nil) {
+				t.Errorf("block #%d: emitted mismatch: have %v, want %v", block.NumberU64(), stored != nil, !replayed)
+			}
+			continue
+		}
+
+		element, err := decode.NewDecoder(bytes.NewReader(stored)).Next()
+		if err != nil {
+			t.Fatalf("block #%d: failed to decode stored block: %v", block.NumberU64(), err)
+		}
+		if decoded := element.(*decode.Block); decoded.Replayed != replayed {
+			t.Errorf("block #%d: replayed mismatch: have %v, want %v", block.NumberU64(), decoded.Replayed, replayed)
+		}
+	}
+
+	if chain.firehoseReplayed != nil {
+		t.Errorf("replay window not closed once past the last block emitted before the restart")
+	}
+	if last := firehose.SyncContext().LastEmittedBlock(); last == nil || last.Hash != blocks[3].Hash() {
+		t.Errorf("last emitted block mismatch: have %v, want #4 (%s)", last, blocks[3].Hash())
+	}
+}
+
+func TestFirehoseBlockWitness(t *testing.T) {
+	contract := common.Address{0xcc}
+	chain := newFirehoseTestChain(t, GenesisAlloc{
+		// PUSH1 0x00 SLOAD PUSH1 0x01 SSTORE STOP, copies slot 0 into slot 1
+		contract: {Balance: big.NewInt(0), Code: common.FromHex("0x6000546001550000"), Storage: map[common.Hash]common.Hash{{}: {0x01}}},
+	}, &firehose.Enabled, &firehose.BlockWitnessEnabled)
+	blocks, _ := chain.generate(1, func(i int, block *BlockGen) {
+		for _, to := range []common.Address{{0xaa}, contract} {
+			chain.addTx(block, to, big.NewInt(1000), 100000, nil)
+		}
+	})
+	genesis := chain.Genesis()
+
+	statedb, err := state.New(genesis.Root(), chain.stateCache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb.TrackAccessedState()
+
+	firehoseContext := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	firehoseContext.EnableBlockTrace()
+	if _, _, _, err := chain.processor.Process(blocks[0], statedb, vm.Config{}, firehoseContext); err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if err := chain.recordFirehoseBlockWitness(firehoseContext, blocks[0], statedb); err != nil {
+		t.Fatalf("failed to record witness: %v", err)
+	}
+	firehoseContext.EndBlock(blocks[0], nil)
+
+	witness := firehoseContext.BlockTrace().Witness
+	if witness == nil || len(witness.Nodes) == 0 || len(witness.Codes) != 1 {
+		t.Fatalf("unexpected witness %+v", witness)
+	}
+
+	// The block executed statelessly, against the witness only, must reach the same state root
+	witnessdb := rawdb.NewMemoryDatabase()
+	for _, node := range witness.Nodes {
+		witnessdb.Put(crypto.Keccak256(node), node)
+	}
+	for _, code := range witness.Codes {
+		rawdb.WriteCode(witnessdb, crypto.Keccak256Hash(code), code)
+	}
+
+	stateless, err := state.New(genesis.Root(), state.NewDatabase(witnessdb), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := chain.processor.Process(blocks[0], stateless, vm.Config{}, firehose.NoOpContext); err != nil {
+		t.Fatalf("failed to process block statelessly: %v", err)
+	}
+	if root := stateless.IntermediateRoot(true); root != blocks[0].Root() {
+		t.Fatalf("stateless state root mismatch: have %s, want %s", root, blocks[0].Root())
+	}
+}
+
+func TestFirehoseBlockStore(t *testing.T) {
+	chain := newFirehoseTestChain(t, nil, &firehose.Enabled, &firehose.SyncInstrumentationEnabled)
+	firehose.BlockStoreRetention = 2
+
+	blocks, _ := chain.generate(4, func(i int, block *BlockGen) {})
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+
+	// Only the blocks of the 2 most recent heights are kept
+	for _, block := range blocks[:2] {
+		if stored := chain.FirehoseBlock(block.Hash()); stored != nil {
+			t.Errorf("block #%d: pruned block still stored", block.NumberU64())
+		}
+	}
+	for _, block := range blocks[2:] {
+		element, err := decode.NewDecoder(bytes.NewReader(chain.FirehoseBlock(block.Hash()))).Next()
+		if err != nil {
+			t.Fatalf("block #%d: failed to decode stored block: %v", block.NumberU64(), err)
+		}
+		if decoded := element.(*decode.Block); decoded.Header.Hash() != block.Hash() {
+			t.Errorf("block #%d: stored block hash mismatch: have %s, want %s", block.NumberU64(), decoded.Header.Hash(), block.Hash())
+		}
+	}
+}
+
+func TestFirehoseCallIndex(t *testing.T) {
+	var (
+		contract = common.Address{0xcc}
+		callee   = common.Address{0xdd}
+	)
+	chain := newFirehoseTestChain(t, GenesisAlloc{
+		// CALL(GAS, 0xdd00..00, 0, 0, 0, 0, 0) STOP, an internal call to the callee
+		contract: {Balance: big.NewInt(0), Code: common.FromHex("0x60006000600060006000" + "73dd00000000000000000000000000000000000000" + "5af100")},
+	}, &firehose.Enabled, &firehose.SyncInstrumentationEnabled, &firehose.CallIndexEnabled)
+	blocks, _ := chain.generate(2, func(i int, block *BlockGen) {
+		chain.addTx(block, contract, big.NewInt(0), 100000, nil)
+	})
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+
+	// The callee is only reached through an internal call, at depth 1 of each transaction
+	calls := chain.FirehoseCallsByAddress(callee, 0, 2)
+	if len(calls) != 2 {
+		t.Fatalf("callee calls count mismatch: have %d, want 2", len(calls))
+	}
+	for i, call := range calls {
+		block := blocks[i]
+		if call.BlockNumber != block.NumberU64() || call.BlockHash != block.Hash() {
+			t.Errorf("call %d: block mismatch: have #%d (%s), want #%d (%s)", i, call.BlockNumber, call.BlockHash, block.NumberU64(), block.Hash())
+		}
+		if call.TxHash != block.Transactions()[0].Hash() || call.TxIndex != 0 {
+			t.Errorf("call %d: transaction mismatch: have %d (%s), want 0 (%s)", i, call.TxIndex, call.TxHash, block.Transactions()[0].Hash())
+		}
+		if call.CallIndex != 2 {
+			t.Errorf("call %d: call index mismatch: have %d, want 2", i, call.CallIndex)
+		}
+	}
+
+	// The contract is both the callee of the root call and the caller of the internal one
+	if calls := chain.FirehoseCallsByAddress(contract, 2, 2); len(calls) != 2 {
+		t.Errorf("contract calls count mismatch: have %d, want 2", len(calls))
+	}
+	if calls := chain.FirehoseCallsByAddress(chain.sender, 0, 0); len(calls) != 0 {
+		t.Errorf("sender calls count mismatch out of range: have %d, want 0", len(calls))
+	}
+}
+
+func TestFirehoseTransferIndex(t *testing.T) {
+	var (
+		contract    = common.Address{0xcc}
+		callee      = common.Address{0xdd}
+		beneficiary = common.Address{0xee}
+		coinbase    = common.Address{0xcb}
+	)
+	chain := newFirehoseTestChain(t, GenesisAlloc{
+		// CALL(GAS, 0xdd00..00, 5, 0, 0, 0, 0) POP SELFDESTRUCT(0xee00..00), sends 5 wei then the rest to the beneficiary
+		contract: {Balance: big.NewInt(1000), Code: common.FromHex("0x60006000600060006005" + "73dd00000000000000000000000000000000000000" + "5af150" + "73ee00000000000000000000000000000000000000" + "ff")},
+	}, &firehose.Enabled, &firehose.SyncInstrumentationEnabled, &firehose.TransferIndexEnabled)
+	blocks, _ := chain.generate(1, func(i int, block *BlockGen) {
+		block.SetCoinbase(coinbase)
+		chain.addTx(block, contract, big.NewInt(0), 100000, big.NewInt(1))
+	})
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+
+	tests := []struct {
+		address common.Address
+		kinds   []firehose.TransferKind
+		values  []int64
+	}{
+		{callee, []firehose.TransferKind{firehose.TransferKindCall}, []int64{5}},
+		{beneficiary, []firehose.TransferKind{firehose.TransferKindSelfDestruct}, []int64{995}},
+		{contract, []firehose.TransferKind{firehose.TransferKindCall, firehose.TransferKindSelfDestruct}, []int64{5, 995}},
+	}
+	for _, tt := range tests {
+		transfers := chain.FirehoseTransfersByAddress(tt.address, 0, 1)
+		if len(transfers) != len(tt.kinds) {
+			t.Fatalf("%s: transfers count mismatch: have %d, want %d", tt.address, len(transfers), len(tt.kinds))
+		}
+		for i, transfer := range transfers {
+			if transfer.Kind != string(tt.kinds[i]) || transfer.Value.Int64() != tt.values[i] {
+				t.Errorf("%s: transfer %d mismatch: have %s of %s, want %s of %d", tt.address, i, transfer.Kind, transfer.Value, tt.kinds[i], tt.values[i])
+			}
+			if transfer.From != contract || transfer.TxHash != blocks[0].Transactions()[0].Hash() {
+				t.Errorf("%s: transfer %d origin mismatch: have %s in %s", tt.address, i, transfer.From, transfer.TxHash)
+			}
+		}
+	}
+
+	// The miner is paid the transaction fee and the block reward, the latter out of any transaction
+	rewards := chain.FirehoseTransfersByAddress(coinbase, 1, 1)
+	if len(rewards) != 2 {
+		t.Fatalf("coinbase rewards count mismatch: have %d, want 2", len(rewards))
+	}
+	for i, reward := range rewards {
+		if reward.Kind != string(firehose.TransferKindReward) || reward.From != (common.Address{}) {
+			t.Errorf("coinbase reward %d mismatch: have %s from %s", i, reward.Kind, reward.From)
+		}
+	}
+	if rewards[1].TxHash != (common.Hash{}) || rewards[1].Value.Cmp(ethash.ConstantinopleBlockReward) != 0 {
+		t.Errorf("block reward mismatch: have %s in %s, want %s", rewards[1].Value, rewards[1].TxHash, ethash.ConstantinopleBlockReward)
+	}
+}
+
+func TestFirehoseGenesisView(t *testing.T) {
+	if view := NewFirehoseGenesisView(nil); view != nil {
+		t.Fatalf("view of a nil genesis must be nil, got %v", view)
+	}
+
+	genesis := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: GenesisAlloc{
+			common.Address{0xaa}: {Balance: big.NewInt(1), Code: []byte{0x00}, Nonce: 2, Storage: map[common.Hash]common.Hash{{0x01}: {0x10}}},
+			common.Address{0xbb}: {Balance: big.NewInt(3)},
+		},
+	}
+
+	view := NewFirehoseGenesisView(genesis)
+	if hash := view.Header().Hash(); hash != genesis.ToBlock(nil).Hash() {
+		t.Fatalf("genesis header hash mismatch: have %s, want %s", hash.Hex(), genesis.ToBlock(nil).Hash().Hex())
+	}
+	if view.ChainConfig() != params.TestChainConfig {
+		t.Fatalf("genesis chain config mismatch")
+	}
+
+	accounts := map[common.Address]*firehose.GenesisAccount{}
+	view.ForEachAccount(func(account *firehose.GenesisAccount) { accounts[account.Address] = account })
+	if len(accounts) != len(genesis.Alloc) {
+		t.Fatalf("genesis accounts count mismatch: have %d, want %d", len(accounts), len(genesis.Alloc))
+	}
+	for addr, want := range genesis.Alloc {
+		have := accounts[addr]
+		if have == nil || have.Balance.Cmp(want.Balance) != 0 || !bytes.Equal(have.Code, want.Code) || have.Nonce != want.Nonce || len(have.Storage) != len(want.Storage) {
+			t.Errorf("genesis account %s mismatch: have %+v, want %+v", addr.Hex(), have, want)
+		}
+	}
+}
+
+func TestFirehoseCursorCompletion(t *testing.T) {
+	chain := newFirehoseTestChain(t, nil)
+	blocks, _ := chain.generate(3, func(i int, block *BlockGen) {})
+
+	checkCursor := func(number uint64, hash common.Hash, complete bool) {
+		t.Helper()
+		cursor := rawdb.ReadFirehoseCursor(chain.db)
+		if cursor == nil || cursor.Number != number || cursor.Hash != hash || cursor.Complete != complete {
+			t.Fatalf("cursor mismatch: have %+v, want #%d (%s) complete %v", cursor, number, hash, complete)
+		}
+	}
+
+	// The sink holds the first two blocks in memory, the cursor stays on the oldest one
+	chain.markFirehoseBlockPending(blocks[0])
+	chain.markFirehoseBlockPending(blocks[1])
+	checkCursor(1, blocks[0].Hash(), false)
+
+	chain.completeFirehoseBlock(1, blocks[0].Hash())
+	checkCursor(2, blocks[1].Hash(), false)
+
+	chain.markFirehoseBlockPending(blocks[2])
+	chain.completeFirehoseBlock(2, blocks[1].Hash())
+	checkCursor(3, blocks[2].Hash(), false)
+
+	chain.completeFirehoseBlock(3, blocks[2].Hash())
+	checkCursor(3, blocks[2].Hash(), true)
+}
+
+func TestFirehoseReemitPrunedState(t *testing.T) {
+	chain := newFirehoseTestChain(t, nil, &firehose.Enabled, &firehose.SyncInstrumentationEnabled)
+	blocks, _ := chain.generate(5, func(i int, block *BlockGen) {
+		chain.addTx(block, common.Address{0xaa}, big.NewInt(1000), params.TxGas, nil)
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+
+	// The emission of block 3 was cut short by a crash, only the head state made it to disk
+	rawdb.WriteFirehoseCursor(chain.db, &rawdb.FirehoseCursor{Number: 3, Hash: blocks[2].Hash()})
+	if err := chain.stateCache.TrieDB().Commit(blocks[4].Root(), false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.New(blocks[1].Root(), state.NewDatabase(chain.db), nil); err == nil {
+		t.Fatal("parent state of block 3 not pruned")
+	}
+
+	restarted, err := NewBlockChain(chain.db, nil, chain.chainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to restart chain: %v", err)
+	}
+	defer restarted.Stop()
+
+	if head := restarted.CurrentBlock().NumberU64(); head >= 3 {
+		t.Fatalf("chain not rewound below the incomplete block: head %d", head)
+	}
+
+	// Re-importing the blocks emits them again, the stream has no gap
+	if n, err := restarted.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to re-insert block %d: %v", n, err)
+	}
+	if last := firehose.SyncContext().LastEmittedBlock(); last == nil || last.Hash != blocks[4].Hash() {
+		t.Fatalf("last emitted block mismatch: have %+v, want #5 (%s)", last, blocks[4].Hash())
+	}
+}
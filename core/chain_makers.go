@@ -208,6 +208,11 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(b.header.Number) == 0 {
 			misc.ApplyDAOHardFork(statedb, firehose.NoOpContext)
 		}
+		if config.IsPrague(b.header.Number) {
+			blockContext := NewEVMBlockContext(b.header, nil, &b.header.Coinbase)
+			vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, vm.Config{})
+			ProcessParentBlockHash(b.header.ParentHash, vmenv, statedb, firehose.NoOpContext)
+		}
 		// Execute any user modifications to the block
 		if gen != nil {
 			gen(i, b)
@@ -107,6 +107,6 @@ func CanTransfer(db vm.StateDB, addr common.Address, amount *big.Int) bool {
 
 // Transfer subtracts amount from sender and adds amount to recipient using the given Db
 func Transfer(db vm.StateDB, sender, recipient common.Address, amount *big.Int, firehoseContext *firehose.Context) {
-	db.SubBalance(sender, amount, firehoseContext, firehose.BalanceChangeReason("transfer"))
-	db.AddBalance(recipient, amount, false, firehoseContext, firehose.BalanceChangeReason("transfer"))
+	db.SubBalance(sender, amount, firehoseContext, firehose.TransferBalanceChangeReason)
+	db.AddBalance(recipient, amount, false, firehoseContext, firehose.TransferBalanceChangeReason)
 }
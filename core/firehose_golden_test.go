@@ -0,0 +1,112 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/internal/firehosetest"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	firehoseGoldenStore        = common.HexToAddress("0x2000000000000000000000000000000000000001")
+	firehoseGoldenRevert       = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	firehoseGoldenSelfDestruct = common.HexToAddress("0x2000000000000000000000000000000000000003")
+	firehoseGoldenDelegate     = common.HexToAddress("0x2000000000000000000000000000000000000004")
+	firehoseGoldenCallRevert   = common.HexToAddress("0x2000000000000000000000000000000000000005")
+)
+
+// TestFirehoseGolden generates a chain exercising the instrumented code paths, then processes
+// each of its blocks through `StateProcessor.Process` with Firehose enabled, comparing the
+// emitted stream against the golden files of `testdata/firehose`. Run with -update to accept
+// the new output.
+func TestFirehoseGolden(t *testing.T) {
+	var (
+		sender = firehosetest.Sender
+		signer = types.LatestSigner(params.TestChainConfig)
+		gendb  = rawdb.NewMemoryDatabase()
+		gspec  = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{
+			sender:                     {Balance: big.NewInt(params.Ether)},
+			firehoseGoldenStore:        {Code: firehosetest.StoreCode, Balance: new(big.Int)},
+			firehoseGoldenRevert:       {Code: firehosetest.RevertCode, Balance: new(big.Int)},
+			firehoseGoldenSelfDestruct: {Code: firehosetest.SelfDestructCode, Balance: big.NewInt(1000)},
+			firehoseGoldenDelegate:     {Code: firehosetest.CallCode("f4", firehoseGoldenStore, "600055"), Balance: new(big.Int)},
+			firehoseGoldenCallRevert:   {Code: firehosetest.CallCode("f1", firehoseGoldenRevert, "600055"), Balance: new(big.Int)},
+		}}
+		genesis = gspec.MustCommit(gendb)
+	)
+
+	newTx := func(b *BlockGen, to *common.Address, value int64, data []byte) {
+		var tx *types.Transaction
+		if to == nil {
+			tx = types.NewContractCreation(b.TxNonce(sender), big.NewInt(value), 200000, big.NewInt(1), data)
+		} else {
+			tx = types.NewTransaction(b.TxNonce(sender), *to, big.NewInt(value), 200000, big.NewInt(1), data)
+		}
+
+		signed, err := types.SignTx(tx, signer, firehosetest.SenderKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(signed)
+	}
+
+	sha256Precompile := common.BytesToAddress([]byte{0x02})
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 2, func(i int, b *BlockGen) {
+		switch i {
+		case 0:
+			// Creation of a contract whose runtime code is the store contract's one
+			newTx(b, nil, 0, firehosetest.StoreCreationCode)
+			newTx(b, &firehoseGoldenRevert, 0, nil)
+			newTx(b, &firehoseGoldenCallRevert, 0, nil)
+		case 1:
+			newTx(b, &firehoseGoldenSelfDestruct, 0, nil)
+			newTx(b, &sha256Precompile, 0, []byte("firehose"))
+			newTx(b, &firehoseGoldenDelegate, 0, nil)
+			newTx(b, &common.Address{0xaa}, 1000, nil)
+		}
+	})
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	processor := NewStateProcessor(gspec.Config, chain, chain.engine)
+	for _, block := range blocks {
+		parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+		statedb, err := state.New(parent.Root(), chain.stateCache, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		firehoseContext := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+		if _, _, _, err := processor.Process(block, statedb, vm.Config{}, firehoseContext); err != nil {
+			t.Fatalf("block #%d: failed to process: %v", block.NumberU64(), err)
+		}
+		firehoseContext.EndBlock(block, chain.GetTd(block.Hash(), block.NumberU64()))
+
+		goldenFile := filepath.Join("testdata", "firehose", fmt.Sprintf("block_%d.golden", block.NumberU64()))
+		firehosetest.CompareGolden(t, goldenFile, firehoseContext.FirehoseLog())
+	}
+}
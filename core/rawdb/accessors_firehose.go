@@ -0,0 +1,259 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// FirehoseCursor tracks the last block emitted to Firehose. A cursor that is not
+// complete denotes a block whose emission was started but never confirmed, most
+// probably because the node crashed while emitting it.
+type FirehoseCursor struct {
+	Number   uint64
+	Hash     common.Hash
+	Complete bool
+}
+
+// ReadFirehoseCursor retrieves the persisted Firehose cursor, nil if none exists.
+func ReadFirehoseCursor(db ethdb.KeyValueReader) *FirehoseCursor {
+	data, _ := db.Get(firehoseCursorKey)
+	if len(data) == 0 {
+		return nil
+	}
+	cursor := new(FirehoseCursor)
+	if err := rlp.DecodeBytes(data, cursor); err != nil {
+		log.Error("Invalid Firehose cursor RLP", "err", err)
+		return nil
+	}
+	return cursor
+}
+
+// WriteFirehoseCursor stores the Firehose cursor.
+func WriteFirehoseCursor(db ethdb.KeyValueWriter, cursor *FirehoseCursor) {
+	data, err := rlp.EncodeToBytes(cursor)
+	if err != nil {
+		log.Crit("Failed to RLP encode Firehose cursor", "err", err)
+	}
+	if err := db.Put(firehoseCursorKey, data); err != nil {
+		log.Crit("Failed to store Firehose cursor", "err", err)
+	}
+}
+
+// DeleteFirehoseCursor removes the Firehose cursor.
+func DeleteFirehoseCursor(db ethdb.KeyValueWriter) {
+	if err := db.Delete(firehoseCursorKey); err != nil {
+		log.Crit("Failed to delete Firehose cursor", "err", err)
+	}
+}
+
+// FirehoseBackfill tracks the range of blocks emitted to Firehose as non-executed blocks
+// that must be re-executed to emit their full Firehose block, `Next` is the next block
+// to re-execute. The backfill is completed once `Next` is above `To`.
+type FirehoseBackfill struct {
+	From uint64
+	To   uint64
+	Next uint64
+}
+
+// ReadFirehoseBackfill retrieves the persisted Firehose backfill, nil if none exists.
+func ReadFirehoseBackfill(db ethdb.KeyValueReader) *FirehoseBackfill {
+	data, _ := db.Get(firehoseBackfillKey)
+	if len(data) == 0 {
+		return nil
+	}
+	backfill := new(FirehoseBackfill)
+	if err := rlp.DecodeBytes(data, backfill); err != nil {
+		log.Error("Invalid Firehose backfill RLP", "err", err)
+		return nil
+	}
+	return backfill
+}
+
+// WriteFirehoseBackfill stores the Firehose backfill.
+func WriteFirehoseBackfill(db ethdb.KeyValueWriter, backfill *FirehoseBackfill) {
+	data, err := rlp.EncodeToBytes(backfill)
+	if err != nil {
+		log.Crit("Failed to RLP encode Firehose backfill", "err", err)
+	}
+	if err := db.Put(firehoseBackfillKey, data); err != nil {
+		log.Crit("Failed to store Firehose backfill", "err", err)
+	}
+}
+
+// ReadFirehoseBlock retrieves the Firehose block payload stored for the block, nil if none
+// is stored.
+func ReadFirehoseBlock(db ethdb.KeyValueReader, number uint64, hash common.Hash) []byte {
+	data, _ := db.Get(firehoseBlockKey(number, hash))
+	return data
+}
+
+// WriteFirehoseBlock stores the Firehose block payload of the block.
+func WriteFirehoseBlock(db ethdb.KeyValueWriter, number uint64, hash common.Hash, payload []byte) {
+	if err := db.Put(firehoseBlockKey(number, hash), payload); err != nil {
+		log.Crit("Failed to store Firehose block", "err", err)
+	}
+}
+
+// DeleteFirehoseBlock removes the Firehose block payload stored for the block, if any.
+func DeleteFirehoseBlock(db ethdb.KeyValueWriter, number uint64, hash common.Hash) {
+	if err := db.Delete(firehoseBlockKey(number, hash)); err != nil {
+		log.Crit("Failed to delete Firehose block", "err", err)
+	}
+}
+
+// DeleteFirehoseBlocksBelow removes the Firehose block payloads stored for the blocks below
+// `number`, on any branch, and returns the number of payloads removed.
+func DeleteFirehoseBlocksBelow(db ethdb.KeyValueStore, number uint64) int {
+	it := db.NewIterator(firehoseBlockPrefix, nil)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	deleted := 0
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(firehoseBlockPrefix)+8+common.HashLength {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(firehoseBlockPrefix):]) >= number {
+			break
+		}
+
+		if err := batch.Delete(key); err != nil {
+			log.Crit("Failed to delete Firehose block", "err", err)
+		}
+		deleted++
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete Firehose blocks", "err", err)
+	}
+	return deleted
+}
+
+// FirehoseCallLocation locates a call, in the Firehose block emitted for a block, made by or
+// to the address it's indexed for, see `WriteFirehoseCallIndex`.
+type FirehoseCallLocation struct {
+	TxIndex   uint64
+	TxHash    common.Hash
+	CallIndex uint64
+	Ordinal   uint64
+}
+
+// FirehoseCallIndexEntry holds the calls of a block indexed for an address.
+type FirehoseCallIndexEntry struct {
+	Number uint64
+	Hash   common.Hash
+	Calls  []*FirehoseCallLocation
+}
+
+// WriteFirehoseCallIndex stores the `calls` of the block indexed for `address`.
+func WriteFirehoseCallIndex(db ethdb.KeyValueWriter, address common.Address, number uint64, hash common.Hash, calls []*FirehoseCallLocation) {
+	data, err := rlp.EncodeToBytes(calls)
+	if err != nil {
+		log.Crit("Failed to RLP encode Firehose call index", "err", err)
+	}
+	if err := db.Put(firehoseCallIndexKey(address, number, hash), data); err != nil {
+		log.Crit("Failed to store Firehose call index", "err", err)
+	}
+}
+
+// ReadFirehoseCallIndex retrieves the calls indexed for `address` of the blocks from `from` up
+// to `to` included, on any branch, ordered by block number.
+func ReadFirehoseCallIndex(db ethdb.Iteratee, address common.Address, from uint64, to uint64) []*FirehoseCallIndexEntry {
+	var entries []*FirehoseCallIndexEntry
+	iterateFirehoseAddressIndex(db, firehoseCallIndexPrefix, address, from, to, func(number uint64, hash common.Hash, data []byte) {
+		entry := &FirehoseCallIndexEntry{Number: number, Hash: hash}
+		if err := rlp.DecodeBytes(data, &entry.Calls); err != nil {
+			log.Error("Invalid Firehose call index RLP", "address", address, "number", number, "err", err)
+			return
+		}
+		entries = append(entries, entry)
+	})
+	return entries
+}
+
+// FirehoseTransfer is a value transfer, in the Firehose block emitted for a block, sent or
+// received by the address it's indexed for, see `WriteFirehoseTransferIndex`. Transfers of
+// block and uncle rewards have no transaction, rewards have no sender.
+type FirehoseTransfer struct {
+	Kind    string
+	TxIndex uint64
+	TxHash  common.Hash
+	From    common.Address
+	To      common.Address
+	Value   *big.Int
+	Ordinal uint64
+}
+
+// FirehoseTransferIndexEntry holds the transfers of a block indexed for an address.
+type FirehoseTransferIndexEntry struct {
+	Number    uint64
+	Hash      common.Hash
+	Transfers []*FirehoseTransfer
+}
+
+// WriteFirehoseTransferIndex stores the `transfers` of the block indexed for `address`.
+func WriteFirehoseTransferIndex(db ethdb.KeyValueWriter, address common.Address, number uint64, hash common.Hash, transfers []*FirehoseTransfer) {
+	data, err := rlp.EncodeToBytes(transfers)
+	if err != nil {
+		log.Crit("Failed to RLP encode Firehose transfer index", "err", err)
+	}
+	if err := db.Put(firehoseTransferIndexKey(address, number, hash), data); err != nil {
+		log.Crit("Failed to store Firehose transfer index", "err", err)
+	}
+}
+
+// ReadFirehoseTransferIndex retrieves the transfers indexed for `address` of the blocks from
+// `from` up to `to` included, on any branch, ordered by block number.
+func ReadFirehoseTransferIndex(db ethdb.Iteratee, address common.Address, from uint64, to uint64) []*FirehoseTransferIndexEntry {
+	var entries []*FirehoseTransferIndexEntry
+	iterateFirehoseAddressIndex(db, firehoseTransferIndexPrefix, address, from, to, func(number uint64, hash common.Hash, data []byte) {
+		entry := &FirehoseTransferIndexEntry{Number: number, Hash: hash}
+		if err := rlp.DecodeBytes(data, &entry.Transfers); err != nil {
+			log.Error("Invalid Firehose transfer index RLP", "address", address, "number", number, "err", err)
+			return
+		}
+		entries = append(entries, entry)
+	})
+	return entries
+}
+
+// iterateFirehoseAddressIndex calls `fn` with each entry, indexed under `prefix` for `address`,
+// of the blocks from `from` up to `to` included, ordered by block number.
+func iterateFirehoseAddressIndex(db ethdb.Iteratee, prefix []byte, address common.Address, from uint64, to uint64, fn func(number uint64, hash common.Hash, data []byte)) {
+	prefix = append(append([]byte{}, prefix...), address.Bytes()...)
+	it := db.NewIterator(prefix, encodeBlockNumber(from))
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+8+common.HashLength {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(prefix):])
+		if number > to {
+			break
+		}
+		fn(number, common.BytesToHash(key[len(prefix)+8:]), it.Value())
+	}
+}
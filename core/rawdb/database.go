@@ -294,6 +294,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		preimages       stat
 		bloomBits       stat
 		cliqueSnaps     stat
+		firehoseBlocks  stat
+		firehoseCalls   stat
+		firehoseXfers   stat
 
 		// Ancient store statistics
 		ancientHeadersSize  common.StorageSize
@@ -352,6 +355,12 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
+		case bytes.HasPrefix(key, firehoseBlockPrefix) && len(key) == (len(firehoseBlockPrefix)+8+common.HashLength):
+			firehoseBlocks.Add(size)
+		case bytes.HasPrefix(key, firehoseCallIndexPrefix) && len(key) == (len(firehoseCallIndexPrefix)+common.AddressLength+8+common.HashLength):
+			firehoseCalls.Add(size)
+		case bytes.HasPrefix(key, firehoseTransferIndexPrefix) && len(key) == (len(firehoseTransferIndexPrefix)+common.AddressLength+8+common.HashLength):
+			firehoseXfers.Add(size)
 		case bytes.HasPrefix(key, []byte("cht-")) ||
 			bytes.HasPrefix(key, []byte("chtIndexV2-")) ||
 			bytes.HasPrefix(key, []byte("chtRootV2-")): // Canonical hash trie
@@ -368,7 +377,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, lastPivotKey,
 				fastTrieProgressKey, snapshotRootKey, snapshotJournalKey, snapshotGeneratorKey,
 				snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey, uncleanShutdownKey,
-				badBlockKey,
+				badBlockKey, firehoseCursorKey, firehoseBackfillKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -415,6 +424,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
 		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
+		{"Key-Value store", "Firehose blocks", firehoseBlocks.Size(), firehoseBlocks.Count()},
+		{"Key-Value store", "Firehose call index", firehoseCalls.Size(), firehoseCalls.Count()},
+		{"Key-Value store", "Firehose transfer index", firehoseXfers.Size(), firehoseXfers.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
 		{"Key-Value store", "Shutdown metadata", shutdownInfo.Size(), shutdownInfo.Count()},
 		{"Ancient store", "Headers", ancientHeadersSize.String(), ancients.String()},
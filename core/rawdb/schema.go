@@ -72,6 +72,12 @@ var (
 	// uncleanShutdownKey tracks the list of local crashes
 	uncleanShutdownKey = []byte("unclean-shutdown") // config prefix for the db
 
+	// firehoseCursorKey tracks the last block emitted to Firehose.
+	firehoseCursorKey = []byte("FirehoseCursor")
+
+	// firehoseBackfillKey tracks the re-execution of the blocks emitted as non-executed to Firehose.
+	firehoseBackfillKey = []byte("FirehoseBackfill")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -90,6 +96,10 @@ var (
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
+	firehoseBlockPrefix         = []byte("FirehoseBlock-")         // firehoseBlockPrefix + num (uint64 big endian) + hash -> Firehose block payload
+	firehoseCallIndexPrefix     = []byte("FirehoseCallIndex-")     // firehoseCallIndexPrefix + address + num (uint64 big endian) + hash -> Firehose call locations
+	firehoseTransferIndexPrefix = []byte("FirehoseTransferIndex-") // firehoseTransferIndexPrefix + address + num (uint64 big endian) + hash -> Firehose transfers
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -223,6 +233,21 @@ func IsCodeKey(key []byte) (bool, []byte) {
 	return false, nil
 }
 
+// firehoseBlockKey = firehoseBlockPrefix + num (uint64 big endian) + hash
+func firehoseBlockKey(number uint64, hash common.Hash) []byte {
+	return append(append(firehoseBlockPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// firehoseCallIndexKey = firehoseCallIndexPrefix + address + num (uint64 big endian) + hash
+func firehoseCallIndexKey(address common.Address, number uint64, hash common.Hash) []byte {
+	return append(append(append(firehoseCallIndexPrefix, address.Bytes()...), encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// firehoseTransferIndexKey = firehoseTransferIndexPrefix + address + num (uint64 big endian) + hash
+func firehoseTransferIndexKey(address common.Address, number uint64, hash common.Hash) []byte {
+	return append(append(append(firehoseTransferIndexPrefix, address.Bytes()...), encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // configKey = configPrefix + hash
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
@@ -0,0 +1,51 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// RollupFees is the extension point of the L2 variants (e.g. OP-stack, ArbOS) charging
+// their transactions for more than their L2 execution. The fees moved are recorded by
+// firehose as the `l1_data_fee` and `sequencer_fee` balance changes of the transaction,
+// the cost paid by the sender being the L2 execution fee plus the L1 data fee.
+type RollupFees interface {
+	// L1DataFee returns the fee charged to the sender of msg for posting its data to
+	// L1, credited to vault once the message is applied, a nil fee when none is due.
+	L1DataFee(msg Message, blockNumber *big.Int, statedb vm.StateDB) (fee *big.Int, vault common.Address)
+
+	// SequencerFeeVault returns the account paid the L2 execution fees in place of
+	// the block coinbase.
+	SequencerFeeVault() common.Address
+}
+
+var rollupFees RollupFees
+
+// RegisterRollupFees registers the fees charged by the L2 variant the node is built for,
+// it must only be called while initializing packages and panics if fees are already
+// registered.
+func RegisterRollupFees(fees RollupFees) {
+	if rollupFees != nil {
+		panic(fmt.Errorf("rollup fees already registered"))
+	}
+	rollupFees = fees
+}
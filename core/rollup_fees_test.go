@@ -0,0 +1,95 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testRollupFees charges a fixed L1 data fee per byte of data.
+type testRollupFees struct {
+	l1FeeVault, sequencerFeeVault common.Address
+}
+
+func (f *testRollupFees) L1DataFee(msg Message, blockNumber *big.Int, statedb vm.StateDB) (*big.Int, common.Address) {
+	return big.NewInt(int64(100 * len(msg.Data()))), f.l1FeeVault
+}
+
+func (f *testRollupFees) SequencerFeeVault() common.Address {
+	return f.sequencerFeeVault
+}
+
+// Tests that the L1 data fee is charged to the sender on top of the L2 execution fee, which
+// is paid to the sequencer fee vault, both recorded as typed balance changes.
+func TestRollupFees(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	defer func(fees RollupFees) { rollupFees = fees }(rollupFees)
+	rollupFees = nil
+
+	fees := &testRollupFees{l1FeeVault: common.HexToAddress("0x1a"), sequencerFeeVault: common.HexToAddress("0x5e")}
+	RegisterRollupFees(fees)
+
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		coinbase = common.HexToAddress("0xc0")
+		db       = rawdb.NewMemoryDatabase()
+		genesis  = (&Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}}}).MustCommit(db)
+	)
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	tx, _ := types.SignTx(types.NewTransaction(0, common.HexToAddress("0xdead"), big.NewInt(0), 100000, big.NewInt(1), []byte{0x01, 0x02}), types.LatestSigner(params.TestChainConfig), key)
+	header := &types.Header{Number: big.NewInt(1), GasLimit: genesis.GasLimit(), Difficulty: big.NewInt(1)}
+
+	evm := vm.NewEVM(NewEVMBlockContext(header, nil, &coinbase), vm.TxContext{}, statedb, params.TestChainConfig, vm.Config{})
+	receipt, _, firehoseLog, err := ApplyTransactionWithResult(evm, params.TestChainConfig, nil, &coinbase, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, new(uint64), firehose.NoOpContext, true)
+	if err != nil {
+		t.Fatalf("failed to apply transaction: %v", err)
+	}
+
+	executionFee := new(big.Int).SetUint64(receipt.GasUsed)
+	if have := statedb.GetBalance(fees.sequencerFeeVault); have.Cmp(executionFee) != 0 {
+		t.Errorf("sequencer fee vault balance mismatch: have %v, want %v", have, executionFee)
+	}
+	if have := statedb.GetBalance(fees.l1FeeVault); have.Cmp(big.NewInt(200)) != 0 {
+		t.Errorf("L1 fee vault balance mismatch: have %v, want %v", have, 200)
+	}
+	if have := statedb.GetBalance(coinbase); have.Sign() != 0 {
+		t.Errorf("coinbase paid %v", have)
+	}
+	want := new(big.Int).Sub(big.NewInt(params.Ether), new(big.Int).Add(executionFee, big.NewInt(200)))
+	if have := statedb.GetBalance(sender); have.Cmp(want) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", have, want)
+	}
+
+	log := string(firehoseLog)
+	if strings.Count(log, " l1_data_fee ") != 2 || strings.Count(log, " sequencer_fee ") != 1 || strings.Contains(log, " reward_transaction_fee ") {
+		t.Errorf("rollup fees not recorded as typed balance changes:\n%s", log)
+	}
+}
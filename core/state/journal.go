@@ -138,6 +138,17 @@ type (
 		address *common.Address
 		slot    *common.Hash
 	}
+
+	// Changes to the transient storage
+	transientStorageChange struct {
+		account       *common.Address
+		key, prevalue common.Hash
+	}
+
+	// Changes to the contracts created in the transaction (EIP-6780)
+	createContractChange struct {
+		account *common.Address
+	}
 )
 
 func (ch createObjectChange) revert(s *StateDB) {
@@ -267,3 +278,19 @@ func (ch accessListAddSlotChange) revert(s *StateDB) {
 func (ch accessListAddSlotChange) dirtied() *common.Address {
 	return nil
 }
+
+func (ch transientStorageChange) revert(s *StateDB) {
+	s.setTransientState(*ch.account, ch.key, ch.prevalue)
+}
+
+func (ch transientStorageChange) dirtied() *common.Address {
+	return nil
+}
+
+func (ch createContractChange) revert(s *StateDB) {
+	delete(s.createdContracts, *ch.account)
+}
+
+func (ch createContractChange) dirtied() *common.Address {
+	return nil
+}
@@ -18,6 +18,7 @@
 package state
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -100,6 +101,15 @@ type StateDB struct {
 	// Per-transaction access list
 	accessList *accessList
 
+	// Per-transaction transient storage (EIP-1153)
+	transientStorage transientStorage
+
+	// Contracts created in the current transaction (EIP-6780)
+	createdContracts map[common.Address]struct{}
+
+	// Accounts looked up but not found, nil unless enabled through `TrackAccessedState`
+	missingAccounts map[common.Address]struct{}
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -138,6 +148,8 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 		preimages:           make(map[common.Hash][]byte),
 		journal:             newJournal(),
 		accessList:          newAccessList(),
+		transientStorage:    newTransientStorage(),
+		createdContracts:    make(map[common.Address]struct{}),
 		hasher:              crypto.NewKeccakState(),
 	}
 	if sdb.snaps != nil {
@@ -397,6 +409,19 @@ func (s *StateDB) AddBalance(addr common.Address, amount *big.Int, isPrecompiled
 	}
 }
 
+// Touch touches the account associated with addr, the equivalent of adding a zero balance
+// to it: the account is created if it doesn't exist and, if empty, is deleted at the end of
+// the transaction (EIP-158). The touch is recorded as such, not as a balance change.
+func (s *StateDB) Touch(addr common.Address, isPrecompiledAddr bool, firehoseContext *firehose.Context) {
+	stateObject := s.GetOrNewStateObject(addr, isPrecompiledAddr, firehoseContext)
+	if stateObject != nil {
+		if firehoseContext.Enabled() {
+			firehoseContext.RecordAccountTouch(addr)
+		}
+		stateObject.AddBalance(common.Big0, firehoseContext, firehose.IgnoredBalanceChangeReason)
+	}
+}
+
 // SubBalance subtracts amount from the account associated with addr.
 func (s *StateDB) SubBalance(addr common.Address, amount *big.Int, firehoseContext *firehose.Context, reason firehose.BalanceChangeReason) {
 	stateObject := s.GetOrNewStateObject(addr, false, firehoseContext)
@@ -433,6 +458,36 @@ func (s *StateDB) SetState(addr common.Address, key, value common.Hash, firehose
 	}
 }
 
+// SetTransientState sets the transient storage `value` for `key` at `addr` (EIP-1153), the
+// change is journaled so it's rolled back when the enclosing call reverts.
+func (s *StateDB) SetTransientState(addr common.Address, key, value common.Hash, firehoseContext *firehose.Context) {
+	prev := s.GetTransientState(addr, key)
+	if prev == value {
+		return
+	}
+
+	if firehoseContext.Enabled() {
+		firehoseContext.RecordTransientStorageChange(addr, key, prev, value)
+	}
+
+	s.journal.append(transientStorageChange{
+		account:  &addr,
+		key:      key,
+		prevalue: prev,
+	})
+	s.setTransientState(addr, key, value)
+}
+
+// setTransientState sets the transient storage without journaling the change.
+func (s *StateDB) setTransientState(addr common.Address, key, value common.Hash) {
+	s.transientStorage.Set(addr, key, value)
+}
+
+// GetTransientState gets the transient storage for `key` at `addr` (EIP-1153).
+func (s *StateDB) GetTransientState(addr common.Address, key common.Hash) common.Hash {
+	return s.transientStorage.Get(addr, key)
+}
+
 // SetStorage replaces the entire storage for the specified account with given
 // storage. This function should only be used for debugging.
 func (s *StateDB) SetStorage(addr common.Address, storage map[common.Hash]common.Hash, firehoseContext *firehose.Context) {
@@ -468,6 +523,28 @@ func (s *StateDB) Suicide(addr common.Address, firehoseContext *firehose.Context
 	return true
 }
 
+// CreateContract marks the account at `addr` as a contract created in the current
+// transaction, the mark is journaled so it's rolled back when the creation reverts.
+func (s *StateDB) CreateContract(addr common.Address) {
+	if _, found := s.createdContracts[addr]; found {
+		return
+	}
+
+	s.journal.append(createContractChange{account: &addr})
+	s.createdContracts[addr] = struct{}{}
+}
+
+// Suicide6780 marks the given account as suicided, like `Suicide` does, only when it's a
+// contract created in the current transaction (EIP-6780). It returns whether the account
+// was marked.
+func (s *StateDB) Suicide6780(addr common.Address, firehoseContext *firehose.Context) bool {
+	if _, found := s.createdContracts[addr]; !found {
+		return false
+	}
+
+	return s.Suicide(addr, firehoseContext)
+}
+
 //
 // Setting, updating & deleting state object methods.
 //
@@ -542,6 +619,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 		var acc *snapshot.Account
 		if acc, err = s.snap.Account(crypto.HashData(s.hasher, addr.Bytes())); err == nil {
 			if acc == nil {
+				s.trackMissingAccount(addr)
 				return nil
 			}
 			data = &Account{
@@ -569,6 +647,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 			return nil
 		}
 		if len(enc) == 0 {
+			s.trackMissingAccount(addr)
 			return nil
 		}
 		data = new(Account)
@@ -738,6 +817,17 @@ func (s *StateDB) Copy() *StateDB {
 	// However, it doesn't cost us much to copy an empty list, so we do it anyway
 	// to not blow up if we ever decide copy it in the middle of a transaction
 	state.accessList = s.accessList.Copy()
+	state.transientStorage = s.transientStorage.Copy()
+	state.createdContracts = make(map[common.Address]struct{}, len(s.createdContracts))
+	for addr := range s.createdContracts {
+		state.createdContracts[addr] = struct{}{}
+	}
+	if s.missingAccounts != nil {
+		state.missingAccounts = make(map[common.Address]struct{}, len(s.missingAccounts))
+		for addr := range s.missingAccounts {
+			state.missingAccounts[addr] = struct{}{}
+		}
+	}
 
 	// If there's a prefetcher running, make an inactive copy of it that can
 	// only access data but does not actively preload (since the user will not
@@ -805,7 +895,11 @@ func (s *StateDB) GetRefund() uint64 {
 // Finalise finalises the state by removing the s destructed objects and clears
 // the journal as well as the refunds. Finalise, however, will not push any updates
 // into the tries just yet. Only IntermediateRoot or Commit will do that.
-func (s *StateDB) Finalise(deleteEmptyObjects bool) {
+//
+// The accounts removed, self-destructed ones and, when deleteEmptyObjects is set, the
+// empty ones touched (EIP-158), are recorded in firehoseContext.
+func (s *StateDB) Finalise(deleteEmptyObjects bool, firehoseContext *firehose.Context) {
+	var deletedObjects []*stateObject
 	addressesToPrefetch := make([][]byte, 0, len(s.journal.dirties))
 	for addr := range s.journal.dirties {
 		obj, exist := s.stateObjects[addr]
@@ -820,6 +914,9 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 		}
 		if obj.suicided || (deleteEmptyObjects && obj.empty()) {
 			obj.deleted = true
+			if firehoseContext.Enabled() {
+				deletedObjects = append(deletedObjects, obj)
+			}
 
 			// If state snapshotting is active, also mark the destruction there.
 			// Note, we can't do this only at the end of a block because multiple
@@ -844,16 +941,35 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 	if s.prefetcher != nil && len(addressesToPrefetch) > 0 {
 		s.prefetcher.prefetch(s.originalRoot, addressesToPrefetch)
 	}
+	if len(deletedObjects) > 0 {
+		recordDeletedAccounts(deletedObjects, firehoseContext)
+	}
 	// Invalidate journal because reverting across transactions is not allowed.
 	s.clearJournalAndRefund()
 }
 
+// recordDeletedAccounts records the removal of the deleted objects, in their address order
+// since they are collected out of a map.
+func recordDeletedAccounts(objects []*stateObject, firehoseContext *firehose.Context) {
+	sort.Slice(objects, func(i, j int) bool {
+		return bytes.Compare(objects[i].address[:], objects[j].address[:]) < 0
+	})
+
+	for _, obj := range objects {
+		reason := firehose.TouchCleanupAccountDeletionReason
+		if obj.suicided {
+			reason = firehose.SuicideAccountDeletionReason
+		}
+		firehoseContext.RecordDeletedAccount(obj.address, reason)
+	}
+}
+
 // IntermediateRoot computes the current root hash of the state trie.
 // It is called in between transactions to get the root hash that
 // goes into transaction receipts.
 func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	// Finalise all the dirty storage states and write them into the tries
-	s.Finalise(deleteEmptyObjects)
+	s.Finalise(deleteEmptyObjects, firehose.NoOpContext)
 
 	// If there was a trie prefetcher operating, it gets aborted and irrevocably
 	// modified after we start retrieving tries. Remove it from the statedb after
@@ -916,6 +1032,8 @@ func (s *StateDB) Prepare(thash, bhash common.Hash, ti int) {
 	s.bhash = bhash
 	s.txIndex = ti
 	s.accessList = newAccessList()
+	s.transientStorage = newTransientStorage()
+	s.createdContracts = make(map[common.Address]struct{})
 }
 
 func (s *StateDB) clearJournalAndRefund() {
@@ -157,7 +157,7 @@ func TestCopy(t *testing.T) {
 		obj.AddBalance(big.NewInt(int64(i)), firehose.NoOpContext, "test")
 		orig.updateStateObject(obj)
 	}
-	orig.Finalise(false)
+	orig.Finalise(false, nil)
 
 	// Copy the state
 	copy := orig.Copy()
@@ -183,7 +183,7 @@ func TestCopy(t *testing.T) {
 	// Finalise the changes on all concurrently
 	finalise := func(wg *sync.WaitGroup, db *StateDB) {
 		defer wg.Done()
-		db.Finalise(true)
+		db.Finalise(true, nil)
 	}
 
 	var wg sync.WaitGroup
@@ -681,7 +681,7 @@ func TestDeleteCreateRevert(t *testing.T) {
 
 	// Simulate self-destructing in one transaction, then create-reverting in another
 	state.Suicide(addr, firehose.NoOpContext)
-	state.Finalise(true)
+	state.Finalise(true, nil)
 
 	id := state.Snapshot()
 	state.SetBalance(addr, big.NewInt(2), firehose.NoOpContext, "test")
@@ -916,3 +916,97 @@ func TestStateDBAccessList(t *testing.T) {
 		t.Fatalf("expected empty, got %d", got)
 	}
 }
+
+func TestTransientStorageRevert(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	var (
+		addr = common.HexToAddress("0x01")
+		key  = common.HexToHash("0x02")
+	)
+	state.SetTransientState(addr, key, common.HexToHash("0x03"), nil)
+
+	snapshot := state.Snapshot()
+	state.SetTransientState(addr, key, common.HexToHash("0x04"), nil)
+	if value := state.GetTransientState(addr, key); value != common.HexToHash("0x04") {
+		t.Fatalf("transient storage mismatch: have %s", value.Hex())
+	}
+
+	state.RevertToSnapshot(snapshot)
+	if value := state.GetTransientState(addr, key); value != common.HexToHash("0x03") {
+		t.Errorf("transient storage not reverted: have %s", value.Hex())
+	}
+	if value := state.GetState(addr, key); value != (common.Hash{}) {
+		t.Errorf("transient storage leaked into the storage: have %s", value.Hex())
+	}
+
+	// The transient storage is cleared between transactions
+	state.Prepare(common.Hash{}, common.Hash{}, 1)
+	if value := state.GetTransientState(addr, key); value != (common.Hash{}) {
+		t.Errorf("transient storage not cleared: have %s", value.Hex())
+	}
+}
+
+func TestFinaliseRecordsDeletedAccounts(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		suicided = common.HexToAddress("0x0b")
+		touched  = common.HexToAddress("0x0a")
+		funded   = common.HexToAddress("0x0c")
+	)
+
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	state.SetCode(suicided, []byte{0x00}, firehose.NoOpContext)
+	state.AddBalance(funded, big.NewInt(1), false, firehose.NoOpContext, "test")
+	state.Finalise(true, firehose.NoOpContext)
+
+	firehoseContext := firehose.NewSpeculativeExecutionContext(1024)
+	firehoseContext.StartTransactionRaw(common.Hash{}, &suicided, common.Big0, nil, nil, nil, 100000, common.Big1, 0, nil, nil, nil, nil, 0, 0)
+
+	state.Suicide(suicided, firehose.NoOpContext)
+	state.Touch(touched, false, firehose.NoOpContext)
+	state.Touch(funded, false, firehose.NoOpContext)
+	state.Finalise(true, firehoseContext)
+
+	// The funded account is not empty, the others are recorded in address order
+	log := string(firehoseContext.FirehoseLog())
+	if strings.Contains(log, "FIRE DELETED_ACCOUNT 0 000000000000000000000000000000000000000c ") {
+		t.Errorf("non-empty touched account recorded as deleted:\n%s", log)
+	}
+	touchedAt := strings.Index(log, "FIRE DELETED_ACCOUNT 0 000000000000000000000000000000000000000a touch_cleanup ")
+	suicidedAt := strings.Index(log, "FIRE DELETED_ACCOUNT 0 000000000000000000000000000000000000000b suicide ")
+	if touchedAt == -1 || suicidedAt == -1 || touchedAt > suicidedAt {
+		t.Errorf("deleted accounts not recorded in address order:\n%s", log)
+	}
+}
+
+func TestCreateContractRevert(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	var (
+		reverted = common.HexToAddress("0x01")
+		created  = common.HexToAddress("0x02")
+	)
+	state.CreateAccount(reverted, nil)
+	state.CreateAccount(created, nil)
+
+	snapshot := state.Snapshot()
+	state.CreateContract(reverted)
+	state.RevertToSnapshot(snapshot)
+	state.CreateContract(created)
+
+	if state.Suicide6780(reverted, nil) {
+		t.Errorf("contract whose creation was reverted suicided")
+	}
+	if !state.Suicide6780(created, nil) || !state.HasSuicided(created) {
+		t.Errorf("contract created in the transaction not suicided")
+	}
+
+	// The created contracts are cleared between transactions
+	state.Prepare(common.Hash{}, common.Hash{}, 1)
+	if state.Suicide6780(created, nil) {
+		t.Errorf("contract created in a previous transaction suicided")
+	}
+}
@@ -0,0 +1,35 @@
+package state
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// transientStorage is the per-transaction storage of EIP-1153 (TSTORE/TLOAD), it's never
+// persisted and is discarded at the start of each transaction.
+type transientStorage map[common.Address]Storage
+
+func newTransientStorage() transientStorage {
+	return make(transientStorage)
+}
+
+// Set sets the transient storage `value` for `key` at `addr`.
+func (t transientStorage) Set(addr common.Address, key, value common.Hash) {
+	if _, found := t[addr]; !found {
+		t[addr] = make(Storage)
+	}
+	t[addr][key] = value
+}
+
+// Get gets the transient storage for `key` at `addr`.
+func (t transientStorage) Get(addr common.Address, key common.Hash) common.Hash {
+	return t[addr][key]
+}
+
+// Copy does a deep copy of the transient storage.
+func (t transientStorage) Copy() transientStorage {
+	storage := make(transientStorage, len(t))
+	for addr, slots := range t {
+		storage[addr] = slots.Copy()
+	}
+	return storage
+}
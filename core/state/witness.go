@@ -0,0 +1,79 @@
+package state
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TrackAccessedState enables the tracking of the accounts looked up but not found in the
+// state, they are reported by `AccessedState` along with the accounts loaded. It must be
+// called before the state is accessed.
+func (s *StateDB) TrackAccessedState() {
+	if s.missingAccounts == nil {
+		s.missingAccounts = make(map[common.Address]struct{})
+	}
+}
+
+func (s *StateDB) trackMissingAccount(addr common.Address) {
+	if s.missingAccounts != nil {
+		s.missingAccounts[addr] = struct{}{}
+	}
+}
+
+// AccessedState returns the accounts accessed by the state transitions applied so far, each
+// with the sorted storage slots read or written. The accounts looked up but not found are
+// reported, without any slot, only when enabled through `TrackAccessedState`.
+func (s *StateDB) AccessedState() map[common.Address][]common.Hash {
+	accessed := make(map[common.Address][]common.Hash, len(s.stateObjects)+len(s.missingAccounts))
+	for addr := range s.missingAccounts {
+		accessed[addr] = nil
+	}
+
+	for addr, obj := range s.stateObjects {
+		slots := make(map[common.Hash]struct{}, len(obj.originStorage)+len(obj.pendingStorage)+len(obj.dirtyStorage))
+		for _, storage := range []Storage{obj.originStorage, obj.pendingStorage, obj.dirtyStorage} {
+			for key := range storage {
+				slots[key] = struct{}{}
+			}
+		}
+
+		keys := make([]common.Hash, 0, len(slots))
+		for key := range slots {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+
+		accessed[addr] = keys
+	}
+
+	return accessed
+}
+
+// AccessedCode returns the contract code loaded from the database by the state transitions
+// applied so far, sorted by code hash, the code deployed by them is left out. It must be
+// called before the state is committed, committing forgets which code was deployed.
+func (s *StateDB) AccessedCode() [][]byte {
+	var hashes []common.Hash
+	codes := make(map[common.Hash][]byte)
+	for _, obj := range s.stateObjects {
+		if obj.code == nil || obj.dirtyCode {
+			continue
+		}
+
+		hash := common.BytesToHash(obj.CodeHash())
+		if _, found := codes[hash]; !found {
+			hashes = append(hashes, hash)
+			codes[hash] = obj.code
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+
+	accessed := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		accessed[i] = codes[hash]
+	}
+
+	return accessed
+}
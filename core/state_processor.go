@@ -17,7 +17,9 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
+	"runtime/debug"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -56,17 +58,26 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 // Process returns the receipts and logs accumulated during the process and
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
-func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config, firehoseContext *firehose.Context) (types.Receipts, []*types.Log, uint64, error) {
+func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config, firehoseContext *firehose.Context) (receipts types.Receipts, allLogs []*types.Log, gasUsed uint64, err error) {
 	var (
-		receipts types.Receipts
-		usedGas  = new(uint64)
-		header   = block.Header()
-		allLogs  []*types.Log
-		gp       = new(GasPool).AddGas(block.GasLimit())
+		usedGas = new(uint64)
+		header  = block.Header()
+		gp      = new(GasPool).AddGas(block.GasLimit())
 	)
 
+	span := firehose.StartSpan("Process", nil)
+	span.SetInt("block.number", int64(block.NumberU64()))
+	span.SetInt("tx.count", int64(len(block.Transactions())))
+	defer func() {
+		span.SetInt("gas.used", int64(gasUsed))
+		span.SetError(err)
+		span.End()
+	}()
+
 	if firehoseContext.Enabled() {
 		firehoseContext.StartBlock(block)
+		firehoseContext.RecordForkActivations(p.config, block)
+		firehoseContext.SetSpan(span)
 	}
 
 	// Mutate the block and state according to any hard-fork specs
@@ -75,15 +86,40 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	}
 
 	txFirehoseContext := firehoseContext
+	activeTxIndex := -1
 	if txFirehoseContext.Enabled() {
-		txFirehoseContext = firehose.NewTransactionContextWithBuffer(firehose.TxSyncBuffer)
+		txFirehoseContext = firehose.AcquireTransactionContextWithBuffer(firehose.TxSyncBuffer)
+		defer firehose.ReleaseContext(txFirehoseContext)
+
+		if firehoseContext.BlockTraceEnabled() {
+			txFirehoseContext.EnableBlockTrace()
+		}
+
+		// A panic of the instrumentation while in a transaction (e.g. inconsistent call index stack)
+		// is recovered here so the block import fails cleanly instead of crashing the node
+		defer func() {
+			if activeTxIndex == -1 {
+				return
+			}
+
+			if r := recover(); r != nil {
+				tx := block.Transactions()[activeTxIndex]
+				receipts, allLogs, gasUsed = nil, nil, 0
+				err = firehose.AbortTransaction(txFirehoseContext, block, activeTxIndex, tx.Hash(), r, debug.Stack())
+			}
+		}()
 	}
 
 	blockContext := NewEVMBlockContext(header, p.bc, nil)
-	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg, firehoseContext)
+	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
+	if p.config.IsPrague(header.Number) {
+		ProcessParentBlockHash(block.ParentHash(), vmenv, statedb, firehoseContext)
+	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		if txFirehoseContext.Enabled() {
+			activeTxIndex = i
+
 			// London fork not active in this branch yet, replace by `header.BaseFee` instead of `nil` when it's the case (and remove this comment)
 			txFirehoseContext.StartTransaction(tx, uint(i), nil)
 		}
@@ -99,7 +135,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		}
 
 		statedb.Prepare(tx.Hash(), block.Hash(), i)
-		receipt, err := applyTransaction(msg, p.config, p.bc, nil, gp, statedb, header, tx, usedGas, vmenv, txFirehoseContext)
+		receipt, _, err := applyTransaction(msg, p.config, p.bc, nil, gp, statedb, header, tx, usedGas, vmenv, txFirehoseContext)
 		if err != nil {
 			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
@@ -109,6 +145,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 
 			// We must flush using the "global" context here, since the speculative context don't hold the real global lock
 			firehoseContext.FlushTransaction(txFirehoseContext)
+			activeTxIndex = -1
 		}
 
 		receipts = append(receipts, receipt)
@@ -130,22 +167,26 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	return receipts, allLogs, *usedGas, nil
 }
 
-func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, evm *vm.EVM, txFirehoseContext *firehose.Context) (*types.Receipt, error) {
+func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, evm *vm.EVM, txFirehoseContext *firehose.Context) (*types.Receipt, *ExecutionResult, error) {
 	// Create a new context to be used in the EVM environment.
 	txContext := NewEVMTxContext(msg)
-	evm.Reset(txContext, statedb, txFirehoseContext)
+	txContext.FirehoseContext = txFirehoseContext
+	evm.Reset(txContext, statedb)
 
 	// Apply the transaction to the current state (included in the env).
 	result, err := ApplyMessage(evm, msg, gp)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Update the state with pending changes.
 	var root []byte
 	if config.IsByzantium(header.Number) {
-		statedb.Finalise(true)
+		statedb.Finalise(true, txFirehoseContext)
 	} else {
+		// Finalised first so the accounts removed are recorded, the intermediate root
+		// computation has then nothing more to finalise
+		statedb.Finalise(config.IsEIP158(header.Number), txFirehoseContext)
 		root = statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
 	}
 	*usedGas += result.UsedGas
@@ -172,7 +213,7 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 	receipt.BlockHash = statedb.BlockHash()
 	receipt.BlockNumber = header.Number
 	receipt.TransactionIndex = uint(statedb.TxIndex())
-	return receipt, err
+	return receipt, result, err
 }
 
 // ApplyTransaction attempts to apply a transaction to the given state database
@@ -180,12 +221,68 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
 func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config, txFirehoseContext *firehose.Context) (*types.Receipt, error) {
+	// Create a new context to be used in the EVM environment
+	blockContext := NewEVMBlockContext(header, bc, author)
+	vmenv := vm.NewEVM(blockContext, vm.TxContext{FirehoseContext: txFirehoseContext}, statedb, config, cfg)
+	return ApplyTransactionWithEVM(vmenv, config, bc, author, gp, statedb, header, tx, usedGas, txFirehoseContext)
+}
+
+// ApplyTransactionWithEVM works like `ApplyTransaction` but executes the transaction with
+// `evm`, reset with the context of the transaction, instead of creating a new EVM. The chain
+// rules and the precompiles are not derived again for each transaction of a block, `evm` must
+// have been created for the block context of `header` and `author`.
+func ApplyTransactionWithEVM(evm *vm.EVM, config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, txFirehoseContext *firehose.Context) (*types.Receipt, error) {
+	receipt, _, _, err := ApplyTransactionWithResult(evm, config, bc, author, gp, statedb, header, tx, usedGas, txFirehoseContext, false)
+	return receipt, err
+}
+
+// ApplyTransactionWithResult works like `ApplyTransactionWithEVM` but also returns the
+// execution result of the transaction (return data, gas used and EVM error). When
+// `captureFirehose` is true and Firehose is enabled, the transaction is recorded in a Firehose
+// context of its own instead of `txFirehoseContext` and its records, from `BEGIN_APPLY_TRX`
+// up to `END_APPLY_TRX`, are returned too.
+func ApplyTransactionWithResult(evm *vm.EVM, config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, txFirehoseContext *firehose.Context, captureFirehose bool) (*types.Receipt, *ExecutionResult, []byte, error) {
 	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	// Create a new context to be used in the EVM environment
-	blockContext := NewEVMBlockContext(header, bc, author)
-	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, cfg, txFirehoseContext)
-	return applyTransaction(msg, config, bc, author, gp, statedb, header, tx, usedGas, vmenv, txFirehoseContext)
+
+	var firehoseBuffer *bytes.Buffer
+	if captureFirehose && firehose.Enabled {
+		firehoseBuffer = bytes.NewBuffer(nil)
+		txFirehoseContext = firehose.AcquireTransactionContextWithBuffer(firehoseBuffer)
+		defer firehose.ReleaseContext(txFirehoseContext)
+
+		// London fork not active in this branch yet, replace by `header.BaseFee` instead of `nil` when it's the case (and remove this comment)
+		txFirehoseContext.StartTransaction(tx, uint(statedb.TxIndex()), nil)
+		txFirehoseContext.RecordTrxFrom(msg.From())
+	}
+
+	receipt, result, err := applyTransaction(msg, config, bc, author, gp, statedb, header, tx, usedGas, evm, txFirehoseContext)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var firehoseLog []byte
+	if firehoseBuffer != nil {
+		txFirehoseContext.EndTransaction(receipt)
+		firehoseLog = firehoseBuffer.Bytes()
+	}
+
+	return receipt, result, firehoseLog, nil
+}
+
+// ProcessParentBlockHash stores `prevHash`, the hash of the parent block, in the EIP-2935
+// history storage contract. It's a system call, executed with `evm` at the start of a Prague
+// block before any transaction, recorded as such by `firehoseContext`.
+func ProcessParentBlockHash(prevHash common.Hash, evm *vm.EVM, statedb *state.StateDB, firehoseContext *firehose.Context) {
+	if firehoseContext.Enabled() {
+		firehoseContext.StartSystemCall()
+		defer firehoseContext.EndSystemCall()
+	}
+
+	evm.Reset(vm.TxContext{Origin: params.SystemAddress, GasPrice: common.Big0, FirehoseContext: firehoseContext}, statedb)
+	statedb.AddAddressToAccessList(params.HistoryStorageAddress)
+	evm.Call(vm.AccountRef(params.SystemAddress), params.HistoryStorageAddress, prevHash.Bytes(), 30_000_000, common.Big0)
+	statedb.Finalise(true, firehoseContext)
 }
@@ -17,16 +17,21 @@
 package core
 
 import (
+	"bytes"
+	"errors"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
 	"golang.org/x/crypto/sha3"
@@ -111,6 +116,150 @@ func TestStateProcessorErrors(t *testing.T) {
 	}
 }
 
+func TestApplyTransactionWithResult(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender     = crypto.PubkeyToAddress(testKey.PublicKey)
+		// Reverts with the 0x2a byte as revert data
+		revertContract = common.HexToAddress("0xdead")
+		db             = rawdb.NewMemoryDatabase()
+		gspec          = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				sender:         {Balance: big.NewInt(params.Ether)},
+				revertContract: {Code: common.FromHex("602a60005360016000fd"), Balance: new(big.Int)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+
+	apply := func(nonce uint64, captureFirehose bool) (*types.Receipt, *ExecutionResult, []byte) {
+		t.Helper()
+
+		statedb, err := state.New(genesis.Root(), state.NewDatabase(db), nil)
+		if err != nil {
+			t.Fatalf("could not open state: %v", err)
+		}
+		statedb.SetNonce(sender, nonce, firehose.NoOpContext)
+
+		tx, _ := types.SignTx(types.NewTransaction(nonce, revertContract, big.NewInt(0), 100000, big.NewInt(1), nil), types.LatestSigner(params.TestChainConfig), testKey)
+		header := &types.Header{Number: big.NewInt(1), GasLimit: genesis.GasLimit(), Difficulty: big.NewInt(1)}
+
+		evm := vm.NewEVM(NewEVMBlockContext(header, nil, &common.Address{}), vm.TxContext{}, statedb, params.TestChainConfig, vm.Config{})
+		receipt, result, firehoseLog, err := ApplyTransactionWithResult(evm, params.TestChainConfig, nil, &common.Address{}, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, new(uint64), firehose.NoOpContext, captureFirehose)
+		if err != nil {
+			t.Fatalf("could not apply transaction: %v", err)
+		}
+		return receipt, result, firehoseLog
+	}
+
+	receipt, result, firehoseLog := apply(0, true)
+	if receipt.Status != types.ReceiptStatusFailed {
+		t.Errorf("receipt status mismatch: have %d, want %d", receipt.Status, types.ReceiptStatusFailed)
+	}
+	if result.Err != vm.ErrExecutionReverted || !bytes.Equal(result.Revert(), []byte{0x2a}) {
+		t.Errorf("result mismatch: have %v with revert data %x, want %v with revert data 2a", result.Err, result.Revert(), vm.ErrExecutionReverted)
+	}
+	if result.UsedGas != receipt.GasUsed {
+		t.Errorf("used gas mismatch: have %d, receipt has %d", result.UsedGas, receipt.GasUsed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(firehoseLog)), "\n")
+	if !strings.HasPrefix(lines[0], "FIRE BEGIN_APPLY_TRX ") || !strings.HasPrefix(lines[len(lines)-1], "FIRE END_APPLY_TRX ") {
+		t.Errorf("firehose log is not a complete transaction:\n%s", firehoseLog)
+	}
+	if !strings.Contains(string(firehoseLog), "FIRE EVM_REVERTED 1") {
+		t.Errorf("firehose log did not record the revert:\n%s", firehoseLog)
+	}
+
+	if _, _, firehoseLog := apply(1, false); firehoseLog != nil {
+		t.Errorf("firehose log captured while not requested:\n%s", firehoseLog)
+	}
+}
+
+func TestApplyTransactionWithEVM(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender     = crypto.PubkeyToAddress(testKey.PublicKey)
+		// Reverts with the 0x2a byte as revert data
+		revertContract = common.HexToAddress("0xdead")
+		db             = rawdb.NewMemoryDatabase()
+		gspec          = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				sender:         {Balance: big.NewInt(params.Ether)},
+				revertContract: {Code: common.FromHex("602a60005360016000fd"), Balance: new(big.Int)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(params.TestChainConfig)
+		header  = &types.Header{Number: big.NewInt(1), GasLimit: genesis.GasLimit(), Difficulty: big.NewInt(1)}
+	)
+	txs := make([]*types.Transaction, 3)
+	for i := range txs {
+		to := revertContract
+		if i == 1 {
+			to = common.Address{0xaa}
+		}
+		txs[i], _ = types.SignTx(types.NewTransaction(uint64(i), to, big.NewInt(1), 100000, big.NewInt(1), nil), signer, testKey)
+	}
+
+	// apply applies the transactions, with an EVM of their own or with the same re-used EVM,
+	// and returns their receipts, their Firehose records and the resulting state root
+	apply := func(reuse bool) ([]*types.Receipt, []string, common.Hash) {
+		t.Helper()
+
+		statedb, err := state.New(genesis.Root(), state.NewDatabase(db), nil)
+		if err != nil {
+			t.Fatalf("could not open state: %v", err)
+		}
+
+		var (
+			gp       = new(GasPool).AddGas(header.GasLimit)
+			usedGas  = new(uint64)
+			evm      = vm.NewEVM(NewEVMBlockContext(header, nil, &common.Address{}), vm.TxContext{}, statedb, params.TestChainConfig, vm.Config{})
+			receipts []*types.Receipt
+			logs     []string
+		)
+		for i, tx := range txs {
+			statedb.Prepare(tx.Hash(), common.Hash{}, i)
+			txFirehoseContext := firehose.NewSpeculativeExecutionContext(1024)
+
+			var receipt *types.Receipt
+			if reuse {
+				receipt, err = ApplyTransactionWithEVM(evm, params.TestChainConfig, nil, &common.Address{}, gp, statedb, header, tx, usedGas, txFirehoseContext)
+			} else {
+				receipt, err = ApplyTransaction(params.TestChainConfig, nil, &common.Address{}, gp, statedb, header, tx, usedGas, vm.Config{}, txFirehoseContext)
+			}
+			if err != nil {
+				t.Fatalf("could not apply transaction %d: %v", i, err)
+			}
+			receipts, logs = append(receipts, receipt), append(logs, string(txFirehoseContext.FirehoseLog()))
+		}
+		return receipts, logs, statedb.IntermediateRoot(true)
+	}
+
+	wantReceipts, wantLogs, wantRoot := apply(false)
+	receipts, logs, root := apply(true)
+	if root != wantRoot {
+		t.Errorf("state root mismatch: have %s, want %s", root, wantRoot)
+	}
+	for i := range txs {
+		if receipts[i].Status != wantReceipts[i].Status || receipts[i].CumulativeGasUsed != wantReceipts[i].CumulativeGasUsed {
+			t.Errorf("transaction %d: receipt mismatch: have status %d and gas %d, want status %d and gas %d", i, receipts[i].Status, receipts[i].CumulativeGasUsed, wantReceipts[i].Status, wantReceipts[i].CumulativeGasUsed)
+		}
+		if logs[i] != wantLogs[i] {
+			t.Errorf("transaction %d: firehose log mismatch:\nhave:\n%s\nwant:\n%s", i, logs[i], wantLogs[i])
+		}
+	}
+}
+
 // GenerateBadBlock constructs a "block" which contains the transactions. The transactions are not expected to be
 // valid, and no proper post-state can be made. But from the perspective of the blockchain, the block is sufficiently
 // valid to be considered for import:
@@ -150,3 +299,131 @@ func GenerateBadBlock(parent *types.Block, engine consensus.Engine, txs types.Tr
 	// Assemble and return the final block for sealing
 	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
 }
+
+func TestStateProcessorPrefetch(t *testing.T) {
+	var (
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender     = crypto.PubkeyToAddress(testKey.PublicKey)
+		// Reverts with the 0x2a byte as revert data
+		revertContract = common.HexToAddress("0xdead")
+		db             = rawdb.NewMemoryDatabase()
+		gspec          = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				sender:         {Balance: big.NewInt(params.Ether)},
+				revertContract: {Code: common.FromHex("602a60005360016000fd"), Balance: new(big.Int)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(params.TestChainConfig)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 3, func(i int, gen *BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(sender), revertContract, big.NewInt(1), 100000, big.NewInt(1), nil), signer, testKey)
+			gen.AddTx(tx)
+		}
+	})
+
+	// The imported blocks are processed while their transactions are prefetched
+	chain, _ := NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+
+	// The prefetch pass is not traced
+	statedb, _ := state.New(genesis.Root(), chain.stateCache, nil)
+	tracer := vm.NewStructLogger(nil)
+	chain.prefetcher.Prefetch(blocks[0], statedb, vm.Config{Debug: true, Tracer: tracer}, nil)
+
+	if nonce := statedb.GetNonce(sender); nonce != 2 {
+		t.Errorf("prefetched state nonce mismatch: have %d, want 2", nonce)
+	}
+	if logs := tracer.StructLogs(); len(logs) != 0 {
+		t.Errorf("prefetch pass traced %d steps", len(logs))
+	}
+}
+
+func TestApplyTransactionDelegationTargetWarm(t *testing.T) {
+	var (
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender     = crypto.PubkeyToAddress(testKey.PublicKey)
+		authority  = common.HexToAddress("0xa0")
+		// Reads its own balance, BALANCE costing 100 when warm and 2600 when cold
+		target = common.HexToAddress("0xb0")
+		db     = rawdb.NewMemoryDatabase()
+		config = *params.TestChainConfig
+	)
+	config.ShanghaiBlock, config.CancunBlock, config.PragueBlock = big.NewInt(0), big.NewInt(0), big.NewInt(0)
+
+	gspec := &Genesis{
+		Config: &config,
+		Alloc: GenesisAlloc{
+			sender:    {Balance: big.NewInt(params.Ether)},
+			authority: {Code: types.AddressToDelegation(target), Balance: new(big.Int)},
+			target:    {Code: append(append([]byte{byte(vm.PUSH20)}, target.Bytes()...), byte(vm.BALANCE), byte(vm.POP), byte(vm.STOP)), Balance: new(big.Int)},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+
+	statedb, err := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	if err != nil {
+		t.Fatalf("could not open state: %v", err)
+	}
+	tx, _ := types.SignTx(types.NewTransaction(0, authority, big.NewInt(0), 100000, big.NewInt(1), nil), types.LatestSigner(&config), testKey)
+	header := &types.Header{Number: big.NewInt(1), GasLimit: genesis.GasLimit(), Difficulty: big.NewInt(1)}
+
+	receipt, err := ApplyTransaction(&config, nil, &common.Address{}, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, new(uint64), vm.Config{}, firehose.NoOpContext)
+	if err != nil {
+		t.Fatalf("could not apply transaction: %v", err)
+	}
+
+	// Intrinsic gas, PUSH20, BALANCE of the warm delegation target and POP
+	if want := params.TxGas + 3 + vm.WarmStorageReadCostEIP2929 + 2; receipt.GasUsed != want {
+		t.Errorf("gas used mismatch: have %d, want %d", receipt.GasUsed, want)
+	}
+}
+
+func TestApplyTransactionInitCodeEIP3860(t *testing.T) {
+	var (
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender     = crypto.PubkeyToAddress(testKey.PublicKey)
+		db         = rawdb.NewMemoryDatabase()
+		config     = *params.TestChainConfig
+	)
+	config.ShanghaiBlock = big.NewInt(0)
+
+	gspec := &Genesis{
+		Config: &config,
+		Alloc:  GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+	}
+	genesis := gspec.MustCommit(db)
+
+	apply := func(size int) (*types.Receipt, error) {
+		t.Helper()
+
+		statedb, err := state.New(genesis.Root(), state.NewDatabase(db), nil)
+		if err != nil {
+			t.Fatalf("could not open state: %v", err)
+		}
+		// Zero bytes init code stops right away, deploying an empty contract
+		tx, _ := types.SignTx(types.NewContractCreation(0, big.NewInt(0), 1000000, big.NewInt(1), make([]byte, size)), types.LatestSigner(&config), testKey)
+		header := &types.Header{Number: big.NewInt(1), GasLimit: genesis.GasLimit(), Difficulty: big.NewInt(1)}
+
+		return ApplyTransaction(&config, nil, &common.Address{}, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, new(uint64), vm.Config{}, firehose.NoOpContext)
+	}
+
+	receipt, err := apply(params.MaxInitCodeSize)
+	if err != nil {
+		t.Fatalf("could not apply transaction: %v", err)
+	}
+	want := params.TxGasContractCreation + params.MaxInitCodeSize*params.TxDataZeroGas + params.MaxInitCodeSize/32*params.InitCodeWordGas
+	if receipt.GasUsed != want {
+		t.Errorf("gas used mismatch: have %d, want %d", receipt.GasUsed, want)
+	}
+
+	if _, err := apply(params.MaxInitCodeSize + 1); !errors.Is(err, ErrMaxInitCodeSizeExceeded) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrMaxInitCodeSizeExceeded)
+	}
+}
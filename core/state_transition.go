@@ -56,6 +56,10 @@ type StateTransition struct {
 	state           vm.StateDB
 	evm             *vm.EVM
 	firehoseContext *firehose.Context
+
+	// L1 data fee charged by L2 variants, see RollupFees
+	l1Fee      *big.Int
+	l1FeeVault common.Address
 }
 
 // Message represents a message sent to a contract.
@@ -71,6 +75,7 @@ type Message interface {
 	CheckNonce() bool
 	Data() []byte
 	AccessList() types.AccessList
+	SetCodeAuthorizations() []types.SetCodeAuthorization
 }
 
 // ExecutionResult includes all output after executing given evm
@@ -109,7 +114,7 @@ func (result *ExecutionResult) Revert() []byte {
 }
 
 // IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, accessList types.AccessList, isContractCreation bool, isHomestead, isEIP2028 bool) (uint64, error) {
+func IntrinsicGas(data []byte, accessList types.AccessList, authList []types.SetCodeAuthorization, isContractCreation bool, isHomestead, isEIP2028, isEIP3860 bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if isContractCreation && isHomestead {
@@ -141,14 +146,33 @@ func IntrinsicGas(data []byte, accessList types.AccessList, isContractCreation b
 			return 0, ErrGasUintOverflow
 		}
 		gas += z * params.TxDataZeroGas
+
+		if isContractCreation && isEIP3860 {
+			lenWords := toWordSize(uint64(len(data)))
+			if (math.MaxUint64-gas)/params.InitCodeWordGas < lenWords {
+				return 0, ErrGasUintOverflow
+			}
+			gas += lenWords * params.InitCodeWordGas
+		}
 	}
 	if accessList != nil {
 		gas += uint64(len(accessList)) * params.TxAccessListAddressGas
 		gas += uint64(accessList.StorageKeys()) * params.TxAccessListStorageKeyGas
 	}
+	if authList != nil {
+		gas += uint64(len(authList)) * params.CallNewAccountGas
+	}
 	return gas, nil
 }
 
+// toWordSize returns the ceiled word size required for init code payment calculation.
+func toWordSize(size uint64) uint64 {
+	if size > math.MaxUint64-31 {
+		return math.MaxUint64/32 + 1
+	}
+	return (size + 31) / 32
+}
+
 // NewStateTransition initialises and returns a new state transition object.
 func NewStateTransition(evm *vm.EVM, msg Message, gp *GasPool, firehoseContext *firehose.Context) *StateTransition {
 	return &StateTransition{
@@ -172,7 +196,7 @@ func NewStateTransition(evm *vm.EVM, msg Message, gp *GasPool, firehoseContext *
 // indicates a core error meaning that the message would always fail for that particular
 // state and would never be accepted within a block.
 func ApplyMessage(evm *vm.EVM, msg Message, gp *GasPool) (*ExecutionResult, error) {
-	return NewStateTransition(evm, msg, gp, evm.FirehoseContext()).TransitionDb()
+	return NewStateTransition(evm, msg, gp, evm.FirehoseContext).TransitionDb()
 }
 
 // to returns the recipient of the message.
@@ -185,7 +209,14 @@ func (st *StateTransition) to() common.Address {
 
 func (st *StateTransition) buyGas() error {
 	mgval := new(big.Int).Mul(new(big.Int).SetUint64(st.msg.Gas()), st.gasPrice)
-	if have, want := st.state.GetBalance(st.msg.From()), mgval; have.Cmp(want) < 0 {
+	balanceCheck := mgval
+	if rollupFees != nil {
+		st.l1Fee, st.l1FeeVault = rollupFees.L1DataFee(st.msg, st.evm.Context.BlockNumber, st.state)
+		if st.l1Fee != nil {
+			balanceCheck = new(big.Int).Add(mgval, st.l1Fee)
+		}
+	}
+	if have, want := st.state.GetBalance(st.msg.From()), balanceCheck; have.Cmp(want) < 0 {
 		return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFunds, st.msg.From().Hex(), have, want)
 	}
 	if err := st.gp.SubGas(st.msg.Gas()); err != nil {
@@ -194,7 +225,10 @@ func (st *StateTransition) buyGas() error {
 	st.gas += st.msg.Gas()
 
 	st.initialGas = st.msg.Gas()
-	st.state.SubBalance(st.msg.From(), mgval, st.firehoseContext, firehose.BalanceChangeReason("gas_buy"))
+	st.state.SubBalance(st.msg.From(), mgval, st.firehoseContext, firehose.GasBuyBalanceChangeReason)
+	if st.l1Fee != nil {
+		st.state.SubBalance(st.msg.From(), st.l1Fee, st.firehoseContext, firehose.L1DataFeeBalanceChangeReason)
+	}
 	return nil
 }
 
@@ -210,6 +244,10 @@ func (st *StateTransition) preCheck() error {
 				st.msg.From().Hex(), msgNonce, stNonce)
 		}
 	}
+	// Make sure a set code transaction carries at least one authorization (EIP-7702)
+	if authList := st.msg.SetCodeAuthorizations(); authList != nil && len(authList) == 0 {
+		return fmt.Errorf("%w: address %v", ErrEmptyAuthList, st.msg.From().Hex())
+	}
 	return st.buyGas()
 }
 
@@ -242,14 +280,15 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		return nil, err
 	}
 
-	msg := st.msg
-	sender := vm.AccountRef(msg.From())
-	homestead := st.evm.ChainConfig().IsHomestead(st.evm.Context.BlockNumber)
-	istanbul := st.evm.ChainConfig().IsIstanbul(st.evm.Context.BlockNumber)
-	contractCreation := msg.To() == nil
+	var (
+		msg              = st.msg
+		sender           = vm.AccountRef(msg.From())
+		rules            = st.evm.ChainRules()
+		contractCreation = msg.To() == nil
+	)
 
 	// Check clauses 4-5, subtract intrinsic gas if everything is correct
-	gas, err := IntrinsicGas(st.data, st.msg.AccessList(), contractCreation, homestead, istanbul)
+	gas, err := IntrinsicGas(st.data, st.msg.AccessList(), st.msg.SetCodeAuthorizations(), contractCreation, rules.IsHomestead, rules.IsIstanbul, rules.IsShanghai)
 	if err != nil {
 		return nil, err
 	}
@@ -257,8 +296,13 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		return nil, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, st.gas, gas)
 	}
 
+	// Check whether the init code size has been exceeded
+	if rules.IsShanghai && contractCreation && len(st.data) > params.MaxInitCodeSize {
+		return nil, fmt.Errorf("%w: code size %v limit %v", ErrMaxInitCodeSizeExceeded, len(st.data), params.MaxInitCodeSize)
+	}
+
 	if st.firehoseContext.Enabled() {
-		st.firehoseContext.RecordGasConsume(st.gas, gas, firehose.GasChangeReason("intrinsic_gas"))
+		st.firehoseContext.RecordGasConsume(st.gas, gas, firehose.IntrinsicGasChangeReason)
 	}
 	st.gas -= gas
 
@@ -268,8 +312,12 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	}
 
 	// Set up the initial access list.
-	if st.evm.ChainConfig().IsBerlin(st.evm.Context.BlockNumber) {
+	if rules.IsBerlin {
 		st.state.PrepareAccessList(msg.From(), msg.To(), st.evm.ActivePrecompiles(), msg.AccessList())
+		if rules.IsShanghai {
+			// The coinbase is warm from the start of the transaction (EIP-3651)
+			st.state.AddAddressToAccessList(st.evm.Context.Coinbase)
+		}
 	}
 
 	var (
@@ -281,10 +329,31 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	} else {
 		// Increment the nonce for the next transaction
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1, st.firehoseContext)
+
+		// Apply the EIP-7702 authorizations, an invalid one is skipped without failing the transaction
+		authList := msg.SetCodeAuthorizations()
+		for i := range authList {
+			st.applyAuthorization(&authList[i])
+		}
+		// The delegation target of the recipient is warm, resolved once the authorizations
+		// are applied since they may have just set it
+		if rules.IsPrague {
+			if target, ok := types.ParseDelegation(st.state.GetCode(st.to())); ok {
+				st.state.AddAddressToAccessList(target)
+			}
+		}
 		ret, st.gas, vmerr = st.evm.Call(sender, st.to(), st.data, st.gas, st.value)
 	}
 	st.refundGas()
-	st.state.AddBalance(st.evm.Context.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice), false, st.firehoseContext, firehose.BalanceChangeReason("reward_transaction_fee"))
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice)
+	if rollupFees != nil {
+		st.state.AddBalance(rollupFees.SequencerFeeVault(), fee, false, st.firehoseContext, firehose.SequencerFeeBalanceChangeReason)
+		if st.l1Fee != nil {
+			st.state.AddBalance(st.l1FeeVault, st.l1Fee, false, st.firehoseContext, firehose.L1DataFeeBalanceChangeReason)
+		}
+	} else {
+		st.state.AddBalance(st.evm.Context.Coinbase, fee, false, st.firehoseContext, firehose.RewardTransactionFeeBalanceChangeReason)
+	}
 
 	return &ExecutionResult{
 		UsedGas:    st.gasUsed(),
@@ -293,6 +362,58 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	}, nil
 }
 
+// validateAuthorization checks an EIP-7702 authorization against the current state,
+// returning the authority that signed it. The authority is returned even when the
+// authorization is invalid, if it could be recovered.
+func (st *StateTransition) validateAuthorization(auth *types.SetCodeAuthorization) (authority *common.Address, err error) {
+	// The authorization is valid on any chain when its chain id is zero
+	if auth.ChainID.Sign() != 0 && auth.ChainID.Cmp(st.evm.ChainConfig().ChainID) != 0 {
+		return nil, ErrAuthorizationWrongChainID
+	}
+	if auth.Nonce+1 < auth.Nonce {
+		return nil, ErrAuthorizationNonceOverflow
+	}
+	signer, err := auth.Authority()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthorizationInvalidSignature, err)
+	}
+	// The authority is accessed even when the authorization turns out to be invalid
+	st.state.AddAddressToAccessList(signer)
+
+	if _, delegated := types.ParseDelegation(st.state.GetCode(signer)); !delegated && st.state.GetCodeSize(signer) != 0 {
+		return &signer, ErrAuthorizationDestinationHasCode
+	}
+	if have := st.state.GetNonce(signer); have != auth.Nonce {
+		return &signer, ErrAuthorizationNonceMismatch
+	}
+	return &signer, nil
+}
+
+// applyAuthorization delegates the code of the authority of `auth` to the authorized
+// address, invalid authorizations are recorded and skipped.
+func (st *StateTransition) applyAuthorization(auth *types.SetCodeAuthorization) {
+	authority, err := st.validateAuthorization(auth)
+	if st.firehoseContext.Enabled() {
+		st.firehoseContext.RecordSetCodeAuthorization(auth.ChainID, auth.Address, auth.Nonce, authority, err == nil)
+	}
+	if err != nil {
+		return
+	}
+
+	// The intrinsic gas assumed the authority did not exist, refund the difference otherwise
+	if st.state.Exist(*authority) {
+		st.state.AddRefund(params.CallNewAccountGas - params.TxAuthTupleGas)
+	}
+
+	st.state.SetNonce(*authority, auth.Nonce+1, st.firehoseContext)
+	if auth.Address == (common.Address{}) {
+		// Delegating to the zero address clears the delegation
+		st.state.SetCode(*authority, nil, st.firehoseContext)
+		return
+	}
+	st.state.SetCode(*authority, types.AddressToDelegation(auth.Address), st.firehoseContext)
+}
+
 func (st *StateTransition) refundGas() {
 	// Apply refund counter, capped to half of the used gas.
 	refund := st.gasUsed() / 2
@@ -303,7 +424,7 @@ func (st *StateTransition) refundGas() {
 
 	// Return ETH for remaining gas, exchanged at the original rate.
 	remaining := new(big.Int).Mul(new(big.Int).SetUint64(st.gas), st.gasPrice)
-	st.state.AddBalance(st.msg.From(), remaining, false, st.firehoseContext, firehose.BalanceChangeReason("gas_refund"))
+	st.state.AddBalance(st.msg.From(), remaining, false, st.firehoseContext, firehose.GasRefundBalanceChangeReason)
 
 	// Also return remaining gas to the block gas counter so it is
 	// available for the next transaction.
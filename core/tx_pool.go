@@ -18,6 +18,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
 	"sort"
@@ -231,6 +232,8 @@ type TxPool struct {
 
 	istanbul bool // Fork indicator whether we are in the istanbul stage.
 	eip2718  bool // Fork indicator whether we are using EIP-2718 type transactions.
+	eip7702  bool // Fork indicator whether we are using EIP-7702 set code transactions.
+	shanghai bool // Fork indicator whether we are in the shanghai stage.
 
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
@@ -372,6 +375,7 @@ func (pool *TxPool) loop() {
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					list := pool.queue[addr].Flatten()
 					for _, tx := range list {
+						pool.recordPendingDrop(tx.Hash(), firehose.ExpiredPendingDropReason)
 						pool.removeTx(tx.Hash(), true)
 					}
 					queuedEvictionMeter.Mark(int64(len(list)))
@@ -429,6 +433,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 
 	pool.gasPrice = price
 	for _, tx := range pool.priced.Cap(price) {
+		pool.recordPendingDrop(tx.Hash(), firehose.UnderpricedPendingDropReason)
 		pool.removeTx(tx.Hash(), false)
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
@@ -528,6 +533,15 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if !pool.eip2718 && tx.Type() != types.LegacyTxType {
 		return ErrTxTypeNotSupported
 	}
+	// Accept set code transactions only once EIP-7702 activates.
+	if tx.Type() == types.SetCodeTxType {
+		if !pool.eip7702 {
+			return ErrTxTypeNotSupported
+		}
+		if len(tx.SetCodeAuthorizations()) == 0 {
+			return ErrEmptyAuthList
+		}
+	}
 	// Reject transactions over defined size to prevent DOS attacks
 	if uint64(tx.Size()) > txMaxSize {
 		return ErrOversizedData
@@ -559,8 +573,12 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
 		return ErrInsufficientFunds
 	}
+	// Check whether the init code size has been exceeded.
+	if pool.shanghai && tx.To() == nil && len(tx.Data()) > params.MaxInitCodeSize {
+		return fmt.Errorf("%w: code size %v limit %v", ErrMaxInitCodeSizeExceeded, len(tx.Data()), params.MaxInitCodeSize)
+	}
 	// Ensure the transaction has more gas than the basic tx fee.
-	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true, pool.istanbul)
+	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.SetCodeAuthorizations(), tx.To() == nil, true, pool.istanbul, pool.shanghai)
 	if err != nil {
 		return err
 	}
@@ -626,6 +644,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool, firehoseContext *fire
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxMeter.Mark(1)
+			pool.recordPendingDrop(tx.Hash(), firehose.UnderpricedPendingDropReason)
 			pool.removeTx(tx.Hash(), false)
 		}
 	}
@@ -640,6 +659,8 @@ func (pool *TxPool) add(tx *types.Transaction, local bool, firehoseContext *fire
 		}
 		// New transaction is better, replace old one
 		if old != nil {
+			pool.recordPendingDrop(old.Hash(), firehose.ReplacedPendingDropReason)
+			pool.recordPendingReplacement(tx, old)
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
@@ -650,6 +671,10 @@ func (pool *TxPool) add(tx *types.Transaction, local bool, firehoseContext *fire
 		pool.queueTxEvent(tx)
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
+		if firehoseContext.Enabled() && firehose.PendingTransactionsEnabled {
+			firehoseContext.RecordPendingTransaction(tx, from)
+		}
+
 		// Successful promotion, bump the heartbeat
 		pool.beats[from] = time.Now()
 		return old != nil, nil
@@ -671,6 +696,10 @@ func (pool *TxPool) add(tx *types.Transaction, local bool, firehoseContext *fire
 	pool.journalTx(from, tx)
 
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.To())
+
+	if firehoseContext.Enabled() && firehose.PendingTransactionsEnabled {
+		firehoseContext.RecordPendingTransaction(tx, from)
+	}
 	return replaced, nil
 }
 
@@ -691,6 +720,8 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
+		pool.recordPendingDrop(old.Hash(), firehose.ReplacedPendingDropReason)
+		pool.recordPendingReplacement(tx, old)
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		queuedReplaceMeter.Mark(1)
@@ -740,6 +771,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	inserted, old := list.Add(tx, pool.config.PriceBump)
 	if !inserted {
 		// An older transaction was better, discard this
+		pool.recordPendingDrop(hash, firehose.ReplacedPendingDropReason)
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
 		pendingDiscardMeter.Mark(1)
@@ -747,6 +779,8 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
+		pool.recordPendingDrop(old.Hash(), firehose.ReplacedPendingDropReason)
+		pool.recordPendingReplacement(tx, old)
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		pendingReplaceMeter.Mark(1)
@@ -910,6 +944,42 @@ func (pool *TxPool) Has(hash common.Hash) bool {
 	return pool.all.Get(hash) != nil
 }
 
+// recordPendingDrop emits a Firehose pending drop for the transaction `hash` leaving the pool
+// for `reason`, if enabled.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) recordPendingDrop(hash common.Hash, reason firehose.PendingDropReason) {
+	if !firehose.PendingTransactionsEnabled {
+		return
+	}
+
+	firehose.MaybeSyncContext().RecordPendingDrop(hash, reason)
+}
+
+// recordPendingReplacement emits a Firehose pending replacement of the transaction `old` by
+// `tx`, if enabled.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) recordPendingReplacement(tx *types.Transaction, old *types.Transaction) {
+	if !firehose.PendingTransactionsEnabled {
+		return
+	}
+
+	firehose.MaybeSyncContext().RecordPendingReplacement(tx.Hash(), old.Hash())
+}
+
+// recordPendingExecutable emits a Firehose pending executable for the transaction `tx` whose
+// nonce gap was filled by `gapFiller`, if enabled.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) recordPendingExecutable(tx *types.Transaction, gapFiller *types.Transaction) {
+	if !firehose.PendingTransactionsEnabled {
+		return
+	}
+
+	firehose.MaybeSyncContext().RecordPendingExecutable(tx.Hash(), gapFiller.Hash())
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
@@ -1216,6 +1286,8 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
 	pool.eip2718 = pool.chainconfig.IsBerlin(next)
+	pool.eip7702 = pool.chainconfig.IsPrague(next)
+	pool.shanghai = pool.chainconfig.IsShanghai(next)
 }
 
 // promoteExecutables moves transactions that have become processable from the
@@ -1235,6 +1307,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		forwards := list.Forward(pool.currentState.GetNonce(addr))
 		for _, tx := range forwards {
 			hash := tx.Hash()
+			pool.recordPendingDrop(hash, firehose.StaleNoncePendingDropReason)
 			pool.all.Remove(hash)
 		}
 		log.Trace("Removed old queued transactions", "count", len(forwards))
@@ -1242,6 +1315,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
+			pool.recordPendingDrop(hash, firehose.UnpayablePendingDropReason)
 			pool.all.Remove(hash)
 		}
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
@@ -1249,10 +1323,15 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 
 		// Gather all executable transactions and promote them
 		readies := list.Ready(pool.pendingNonces.get(addr))
-		for _, tx := range readies {
+		for i, tx := range readies {
 			hash := tx.Hash()
 			if pool.promoteTx(addr, hash, tx) {
 				promoted = append(promoted, tx)
+
+				// The transactions following the first one were held by the nonce gap it filled
+				if i > 0 {
+					pool.recordPendingExecutable(tx, readies[0])
+				}
 			}
 		}
 		log.Trace("Promoted queued transactions", "count", len(promoted))
@@ -1264,6 +1343,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			caps = list.Cap(int(pool.config.AccountQueue))
 			for _, tx := range caps {
 				hash := tx.Hash()
+				pool.recordPendingDrop(hash, firehose.PoolLimitPendingDropReason)
 				pool.all.Remove(hash)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
@@ -1326,6 +1406,7 @@ func (pool *TxPool) truncatePending() {
 					for _, tx := range caps {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
+						pool.recordPendingDrop(hash, firehose.PoolLimitPendingDropReason)
 						pool.all.Remove(hash)
 
 						// Update the account nonce to the dropped transaction
@@ -1353,6 +1434,7 @@ func (pool *TxPool) truncatePending() {
 				for _, tx := range caps {
 					// Drop the transaction from the global pools too
 					hash := tx.Hash()
+					pool.recordPendingDrop(hash, firehose.PoolLimitPendingDropReason)
 					pool.all.Remove(hash)
 
 					// Update the account nonce to the dropped transaction
@@ -1400,6 +1482,7 @@ func (pool *TxPool) truncateQueue() {
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
 			for _, tx := range list.Flatten() {
+				pool.recordPendingDrop(tx.Hash(), firehose.PoolLimitPendingDropReason)
 				pool.removeTx(tx.Hash(), true)
 			}
 			drop -= size
@@ -1409,6 +1492,7 @@ func (pool *TxPool) truncateQueue() {
 		// Otherwise drop only last few transactions
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+			pool.recordPendingDrop(txs[i].Hash(), firehose.PoolLimitPendingDropReason)
 			pool.removeTx(txs[i].Hash(), true)
 			drop--
 			queuedRateLimitMeter.Mark(1)
@@ -1428,6 +1512,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		olds := list.Forward(nonce)
 		for _, tx := range olds {
 			hash := tx.Hash()
+			pool.recordPendingDrop(hash, firehose.StaleNoncePendingDropReason)
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
@@ -1436,6 +1521,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
+			pool.recordPendingDrop(hash, firehose.UnpayablePendingDropReason)
 			pool.all.Remove(hash)
 		}
 		pool.priced.Removed(len(olds) + len(drops))
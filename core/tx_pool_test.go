@@ -271,6 +271,90 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+func setCodeTransaction(nonce uint64, gaslimit uint64, key *ecdsa.PrivateKey, authList []types.SetCodeAuthorization) *types.Transaction {
+	tx, _ := types.SignNewTx(key, types.NewPragueSigner(params.TestChainConfig.ChainID), &types.SetCodeTx{
+		ChainID:   params.TestChainConfig.ChainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(1),
+		Gas:       gaslimit,
+		To:        common.Address{},
+		Value:     big.NewInt(0),
+		AuthList:  authList,
+	})
+	return tx
+}
+
+func setupSetCodeTxPool(pragueBlock *big.Int, from common.Address) *TxPool {
+	config := *params.TestChainConfig
+	config.ShanghaiBlock = big.NewInt(0)
+	config.PragueBlock = pragueBlock
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.AddBalance(from, big.NewInt(0xffffffffffffff), false, firehose.NoOpContext, "test")
+
+	return NewTxPool(testTxPoolConfig, &config, &testBlockChain{statedb, 10000000, new(event.Feed)})
+}
+
+func TestSetCodeTransactions(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	authKey, _ := crypto.GenerateKey()
+	auth, _ := types.SignSetCode(authKey, types.SetCodeAuthorization{ChainID: params.TestChainConfig.ChainID, Address: common.Address{0xaa}})
+
+	// Set code transactions are rejected until Prague activates
+	pool := setupSetCodeTxPool(big.NewInt(100), from)
+	defer pool.Stop()
+
+	if err := pool.AddRemote(setCodeTransaction(0, 100000, key, []types.SetCodeAuthorization{auth})); !errors.Is(err, ErrTxTypeNotSupported) {
+		t.Error("expected", ErrTxTypeNotSupported, "got", err)
+	}
+
+	pool = setupSetCodeTxPool(big.NewInt(0), from)
+	defer pool.Stop()
+
+	if err := pool.AddRemote(setCodeTransaction(0, 100000, key, []types.SetCodeAuthorization{})); !errors.Is(err, ErrEmptyAuthList) {
+		t.Error("expected", ErrEmptyAuthList, "got", err)
+	}
+	// Each authorization is charged on top of the base transaction fee
+	if err := pool.AddRemote(setCodeTransaction(0, params.TxGas, key, []types.SetCodeAuthorization{auth})); !errors.Is(err, ErrIntrinsicGas) {
+		t.Error("expected", ErrIntrinsicGas, "got", err)
+	}
+	if err := pool.AddRemote(setCodeTransaction(0, params.TxGas+params.CallNewAccountGas, key, []types.SetCodeAuthorization{auth})); err != nil {
+		t.Error("expected", nil, "got", err)
+	}
+}
+
+func TestInitCodeTransactions(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	pool := setupSetCodeTxPool(nil, from)
+	defer pool.Stop()
+
+	create := func(nonce uint64, gaslimit uint64, size int) *types.Transaction {
+		tx, _ := types.SignTx(types.NewContractCreation(nonce, big.NewInt(0), gaslimit, big.NewInt(1), make([]byte, size)), types.HomesteadSigner{}, key)
+		return tx
+	}
+	// Init code above the limit is rejected whatever the gas
+	if err := pool.AddRemote(create(0, 1000000, params.MaxInitCodeSize+1)); !errors.Is(err, ErrMaxInitCodeSizeExceeded) {
+		t.Error("expected", ErrMaxInitCodeSizeExceeded, "got", err)
+	}
+	// Each word of init code is charged on top of the data fee
+	dataGas := params.TxGasContractCreation + params.MaxInitCodeSize*params.TxDataZeroGas
+	if err := pool.AddRemote(create(0, dataGas, params.MaxInitCodeSize)); !errors.Is(err, ErrIntrinsicGas) {
+		t.Error("expected", ErrIntrinsicGas, "got", err)
+	}
+	if err := pool.AddRemote(create(0, dataGas+params.MaxInitCodeSize/32*params.InitCodeWordGas, params.MaxInitCodeSize)); err != nil {
+		t.Error("expected", nil, "got", err)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
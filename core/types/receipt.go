@@ -141,7 +141,7 @@ func (r *Receipt) EncodeRLP(w io.Writer) error {
 		return rlp.Encode(w, data)
 	}
 	// It's an EIP-2718 typed TX receipt.
-	if r.Type != AccessListTxType {
+	if r.Type != AccessListTxType && r.Type != SetCodeTxType {
 		return ErrTxTypeNotSupported
 	}
 	buf := encodeBufferPool.Get().(*bytes.Buffer)
@@ -179,7 +179,7 @@ func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
 			return errEmptyTypedReceipt
 		}
 		r.Type = b[0]
-		if r.Type == AccessListTxType {
+		if r.Type == AccessListTxType || r.Type == SetCodeTxType {
 			var dec receiptRLP
 			if err := rlp.DecodeBytes(b[1:], &dec); err != nil {
 				return err
@@ -342,8 +342,8 @@ func (rs Receipts) EncodeIndex(i int, w *bytes.Buffer) {
 	switch r.Type {
 	case LegacyTxType:
 		rlp.Encode(w, data)
-	case AccessListTxType:
-		w.WriteByte(AccessListTxType)
+	case AccessListTxType, SetCodeTxType:
+		w.WriteByte(r.Type)
 		rlp.Encode(w, data)
 	default:
 		// For unsupported types, write nothing. Since this is for
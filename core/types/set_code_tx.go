@@ -0,0 +1,187 @@
+package types
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+//go:generate gencodec -type SetCodeAuthorization -field-override authorizationMarshaling -out gen_authorization.go
+
+// DelegationPrefix is the prefix of the code of an account delegating its execution to
+// another account through an EIP-7702 authorization, the delegated address follows it.
+var DelegationPrefix = []byte{0xef, 0x01, 0x00}
+
+// ParseDelegation returns the delegated address if `code` is a delegation designator.
+func ParseDelegation(code []byte) (common.Address, bool) {
+	if len(code) != len(DelegationPrefix)+common.AddressLength || !bytes.HasPrefix(code, DelegationPrefix) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(code[len(DelegationPrefix):]), true
+}
+
+// AddressToDelegation returns the delegation designator of `addr`.
+func AddressToDelegation(addr common.Address) []byte {
+	return append(common.CopyBytes(DelegationPrefix), addr.Bytes()...)
+}
+
+// SetCodeTx is the data of EIP-7702 set code transactions.
+//
+// The fee market of EIP-1559 is not supported by this chain, the transaction is priced at
+// its fee cap, the tip cap is kept for the canonical encoding of the transaction.
+type SetCodeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int // a.k.a. maxPriorityFeePerGas
+	GasFeeCap  *big.Int // a.k.a. maxFeePerGas
+	Gas        uint64
+	To         common.Address // a set code transaction cannot create a contract
+	Value      *big.Int
+	Data       []byte
+	AccessList AccessList
+	AuthList   []SetCodeAuthorization
+
+	// Signature values
+	V, R, S *big.Int
+}
+
+// SetCodeAuthorization is an authorization from an account to deploy the code of the
+// delegated address to the account.
+type SetCodeAuthorization struct {
+	ChainID *big.Int       `json:"chainId" gencodec:"required"`
+	Address common.Address `json:"address" gencodec:"required"`
+	Nonce   uint64         `json:"nonce" gencodec:"required"`
+	V       uint8          `json:"yParity" gencodec:"required"`
+	R       *big.Int       `json:"r" gencodec:"required"`
+	S       *big.Int       `json:"s" gencodec:"required"`
+}
+
+// field type overrides for gencodec
+type authorizationMarshaling struct {
+	ChainID *hexutil.Big
+	Nonce   hexutil.Uint64
+	V       hexutil.Uint64
+	R       *hexutil.Big
+	S       *hexutil.Big
+}
+
+// SignSetCode creates a signed authorization out of `auth`.
+func SignSetCode(prv *ecdsa.PrivateKey, auth SetCodeAuthorization) (SetCodeAuthorization, error) {
+	sighash := auth.SigHash()
+	sig, err := crypto.Sign(sighash[:], prv)
+	if err != nil {
+		return SetCodeAuthorization{}, err
+	}
+	r, s, _ := decodeSignature(sig)
+	return SetCodeAuthorization{
+		ChainID: auth.ChainID,
+		Address: auth.Address,
+		Nonce:   auth.Nonce,
+		V:       sig[64],
+		R:       r,
+		S:       s,
+	}, nil
+}
+
+// SigHash returns the hash of the authorization signed by the authority.
+func (a *SetCodeAuthorization) SigHash() common.Hash {
+	return prefixedRlpHash(0x05, []interface{}{
+		a.ChainID,
+		a.Address,
+		a.Nonce,
+	})
+}
+
+// Authority recovers the account that signed the authorization.
+func (a *SetCodeAuthorization) Authority() (common.Address, error) {
+	if a.R == nil || a.S == nil || !crypto.ValidateSignatureValues(a.V, a.R, a.S, true) {
+		return common.Address{}, ErrInvalidSig
+	}
+
+	sighash := a.SigHash()
+	var sig [crypto.SignatureLength]byte
+	a.R.FillBytes(sig[:32])
+	a.S.FillBytes(sig[32:64])
+	sig[64] = a.V
+
+	pub, err := crypto.Ecrecover(sighash[:], sig[:])
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(pub) == 0 || pub[0] != 4 {
+		return common.Address{}, errors.New("invalid public key")
+	}
+
+	var addr common.Address
+	copy(addr[:], crypto.Keccak256(pub[1:])[12:])
+	return addr, nil
+}
+
+// copy creates a deep copy of the transaction data and initializes all fields.
+func (tx *SetCodeTx) copy() TxData {
+	cpy := &SetCodeTx{
+		Nonce: tx.Nonce,
+		To:    tx.To,
+		Data:  common.CopyBytes(tx.Data),
+		Gas:   tx.Gas,
+		// These are copied below.
+		AccessList: make(AccessList, len(tx.AccessList)),
+		AuthList:   make([]SetCodeAuthorization, len(tx.AuthList)),
+		Value:      new(big.Int),
+		ChainID:    new(big.Int),
+		GasTipCap:  new(big.Int),
+		GasFeeCap:  new(big.Int),
+		V:          new(big.Int),
+		R:          new(big.Int),
+		S:          new(big.Int),
+	}
+	copy(cpy.AccessList, tx.AccessList)
+	copy(cpy.AuthList, tx.AuthList)
+	if tx.Value != nil {
+		cpy.Value.Set(tx.Value)
+	}
+	if tx.ChainID != nil {
+		cpy.ChainID.Set(tx.ChainID)
+	}
+	if tx.GasTipCap != nil {
+		cpy.GasTipCap.Set(tx.GasTipCap)
+	}
+	if tx.GasFeeCap != nil {
+		cpy.GasFeeCap.Set(tx.GasFeeCap)
+	}
+	if tx.V != nil {
+		cpy.V.Set(tx.V)
+	}
+	if tx.R != nil {
+		cpy.R.Set(tx.R)
+	}
+	if tx.S != nil {
+		cpy.S.Set(tx.S)
+	}
+	return cpy
+}
+
+// accessors for innerTx.
+
+func (tx *SetCodeTx) txType() byte           { return SetCodeTxType }
+func (tx *SetCodeTx) chainID() *big.Int      { return tx.ChainID }
+func (tx *SetCodeTx) accessList() AccessList { return tx.AccessList }
+func (tx *SetCodeTx) data() []byte           { return tx.Data }
+func (tx *SetCodeTx) gas() uint64            { return tx.Gas }
+func (tx *SetCodeTx) gasPrice() *big.Int     { return tx.GasFeeCap }
+func (tx *SetCodeTx) value() *big.Int        { return tx.Value }
+func (tx *SetCodeTx) nonce() uint64          { return tx.Nonce }
+func (tx *SetCodeTx) to() *common.Address    { tmp := tx.To; return &tmp }
+
+func (tx *SetCodeTx) rawSignatureValues() (v, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *SetCodeTx) setSignatureValues(chainID, v, r, s *big.Int) {
+	tx.ChainID, tx.V, tx.R, tx.S = chainID, v, r, s
+}
@@ -0,0 +1,126 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSetCodeAuthorizationAuthority(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	auth, err := SignSetCode(key, SetCodeAuthorization{
+		ChainID: big.NewInt(1),
+		Address: common.HexToAddress("0xdeadbeef"),
+		Nonce:   7,
+	})
+	if err != nil {
+		t.Fatalf("could not sign authorization: %v", err)
+	}
+
+	authority, err := auth.Authority()
+	if err != nil {
+		t.Fatalf("could not recover authority: %v", err)
+	}
+	if authority != addr {
+		t.Errorf("authority mismatch: have %x, want %x", authority, addr)
+	}
+
+	// Tampering with the authorization changes the recovered authority
+	auth.Nonce++
+	if authority, err := auth.Authority(); err == nil && authority == addr {
+		t.Errorf("tampered authorization recovered the original authority")
+	}
+
+	auth.V = 2
+	if _, err := auth.Authority(); err != ErrInvalidSig {
+		t.Errorf("invalid signature error mismatch: have %v, want %v", err, ErrInvalidSig)
+	}
+}
+
+func TestParseDelegation(t *testing.T) {
+	target := common.HexToAddress("0xdeadbeef")
+
+	delegated, ok := ParseDelegation(AddressToDelegation(target))
+	if !ok || delegated != target {
+		t.Errorf("delegation mismatch: have %x (%t), want %x", delegated, ok, target)
+	}
+
+	for _, code := range [][]byte{nil, common.FromHex("6001600055"), append(AddressToDelegation(target), 0x00)} {
+		if _, ok := ParseDelegation(code); ok {
+			t.Errorf("code %x parsed as a delegation", code)
+		}
+	}
+}
+
+func TestSetCodeTransactionCoding(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	authKey, _ := crypto.GenerateKey()
+
+	auth, err := SignSetCode(authKey, SetCodeAuthorization{
+		ChainID: big.NewInt(1),
+		Address: common.HexToAddress("0xdeadbeef"),
+		Nonce:   1,
+	})
+	if err != nil {
+		t.Fatalf("could not sign authorization: %v", err)
+	}
+
+	signer := NewPragueSigner(big.NewInt(1))
+	tx, err := SignNewTx(key, signer, &SetCodeTx{
+		ChainID:    big.NewInt(1),
+		Nonce:      3,
+		GasTipCap:  big.NewInt(1),
+		GasFeeCap:  big.NewInt(10),
+		Gas:        100000,
+		To:         common.HexToAddress("0x095e7baea6a6c7c4c2dfeb977efac326af552d87"),
+		Value:      big.NewInt(5),
+		Data:       []byte("abcdef"),
+		AccessList: AccessList{{Address: common.HexToAddress("0x01"), StorageKeys: []common.Hash{{0}}}},
+		AuthList:   []SetCodeAuthorization{auth},
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+
+	if tx.Type() != SetCodeTxType {
+		t.Errorf("type mismatch: have %d, want %d", tx.Type(), SetCodeTxType)
+	}
+	if tx.GasPrice().Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("gas price mismatch: have %v, want the fee cap 10", tx.GasPrice())
+	}
+
+	sender, err := Sender(signer, tx)
+	if err != nil {
+		t.Fatalf("could not recover sender: %v", err)
+	}
+	if sender != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("sender mismatch: have %x, want %x", sender, crypto.PubkeyToAddress(key.PublicKey))
+	}
+
+	// Signers before Prague reject set code transactions
+	if _, err := Sender(NewEIP2930Signer(big.NewInt(1)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("EIP-2930 signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+
+	for name, codec := range map[string]func(*Transaction) (*Transaction, error){"rlp": encodeDecodeBinary, "json": encodeDecodeJSON} {
+		parsedTx, err := codec(tx)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := assertEqual(parsedTx, tx); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		parsedAuths := parsedTx.SetCodeAuthorizations()
+		if len(parsedAuths) != 1 {
+			t.Fatalf("%s: authorization count mismatch: have %d, want 1", name, len(parsedAuths))
+		}
+		if authority, err := parsedAuths[0].Authority(); err != nil || authority != crypto.PubkeyToAddress(authKey.PublicKey) {
+			t.Errorf("%s: authority mismatch: have %x (%v), want %x", name, authority, err, crypto.PubkeyToAddress(authKey.PublicKey))
+		}
+	}
+}
@@ -42,6 +42,7 @@ var (
 const (
 	LegacyTxType = iota
 	AccessListTxType
+	SetCodeTxType = 0x04
 )
 
 // Transaction is an Ethereum transaction.
@@ -64,7 +65,7 @@ func NewTx(inner TxData) *Transaction {
 
 // TxData is the underlying data of a transaction.
 //
-// This is implemented by LegacyTx and AccessListTx.
+// This is implemented by LegacyTx, AccessListTx and SetCodeTx.
 type TxData interface {
 	txType() byte // returns the type ID
 	copy() TxData // creates a deep copy and initializes all fields
@@ -177,6 +178,10 @@ func (tx *Transaction) decodeTyped(b []byte) (TxData, error) {
 		var inner AccessListTx
 		err := rlp.DecodeBytes(b[1:], &inner)
 		return &inner, err
+	case SetCodeTxType:
+		var inner SetCodeTx
+		err := rlp.DecodeBytes(b[1:], &inner)
+		return &inner, err
 	default:
 		return nil, ErrTxTypeNotSupported
 	}
@@ -254,6 +259,16 @@ func (tx *Transaction) Data() []byte { return tx.inner.data() }
 // AccessList returns the access list of the transaction.
 func (tx *Transaction) AccessList() AccessList { return tx.inner.accessList() }
 
+// SetCodeAuthorizations returns the authorizations of an EIP-7702 set code transaction,
+// nil for the other transaction types.
+func (tx *Transaction) SetCodeAuthorizations() []SetCodeAuthorization {
+	setCodeTx, ok := tx.inner.(*SetCodeTx)
+	if !ok {
+		return nil
+	}
+	return setCodeTx.AuthList
+}
+
 // Gas returns the gas limit of the transaction.
 func (tx *Transaction) Gas() uint64 { return tx.inner.gas() }
 
@@ -488,6 +503,7 @@ type Message struct {
 	gasPrice   *big.Int
 	data       []byte
 	accessList AccessList
+	authList   []SetCodeAuthorization
 	checkNonce bool
 }
 
@@ -515,6 +531,7 @@ func (tx *Transaction) AsMessage(s Signer) (Message, error) {
 		amount:     tx.Value(),
 		data:       tx.Data(),
 		accessList: tx.AccessList(),
+		authList:   tx.SetCodeAuthorizations(),
 		checkNonce: true,
 	}
 
@@ -533,6 +550,9 @@ func (m Message) Data() []byte           { return m.data }
 func (m Message) AccessList() AccessList { return m.accessList }
 func (m Message) CheckNonce() bool       { return m.checkNonce }
 
+// SetCodeAuthorizations returns the EIP-7702 authorizations of the message.
+func (m Message) SetCodeAuthorizations() []SetCodeAuthorization { return m.authList }
+
 const txDataUpdatedMessage = "firehose patch broken"
 
 // If you arrive here because of a compilation error, there is a high chance that it's because the
@@ -0,0 +1,52 @@
+package vm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// P256VerifyAddress is the address of the secp256r1 signature verification precompile
+// specified by RIP-7212.
+var P256VerifyAddress = common.BytesToAddress([]byte{0x01, 0x00})
+
+func init() {
+	RegisterPrecompiledContract(P256VerifyAddress, "p256_verify", &p256Verify{}, func(rules params.Rules) bool { return rules.IsRIP7212 })
+}
+
+// p256Verify implements the secp256r1 (P-256) signature verification precompile of
+// RIP-7212, an invalid input or signature is not an error but returns an empty output.
+type p256Verify struct{}
+
+// p256VerifyInputLength is the length of the input, the hash, the r and s signature values
+// and the x and y public key coordinates, 32 bytes each.
+const p256VerifyInputLength = 160
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *p256Verify) RequiredGas(input []byte) uint64 {
+	return params.P256VerifyGas
+}
+
+func (c *p256Verify) Run(input []byte) ([]byte, error) {
+	if len(input) != p256VerifyInputLength {
+		return nil, nil
+	}
+
+	hash := input[:32]
+	r, s := new(big.Int).SetBytes(input[32:64]), new(big.Int).SetBytes(input[64:96])
+	x, y := new(big.Int).SetBytes(input[96:128]), new(big.Int).SetBytes(input[128:160])
+
+	curve := elliptic.P256()
+	if !curve.IsOnCurve(x, y) {
+		return nil, nil
+	}
+
+	if !ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, hash, r, s) {
+		return nil, nil
+	}
+
+	return true32Byte, nil
+}
@@ -0,0 +1,144 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CustomOpCode is an opcode added by a chain variant on top of the instruction set of the
+// active fork, see `RegisterOpCode`.
+type CustomOpCode struct {
+	OpCode OpCode
+	// Name is the name of the opcode as printed by the tracers and by Firehose, it can also be
+	// used as the key of the chain config's opcode overrides
+	Name string
+
+	// Pops and Pushes are the number of stack items the opcode consumes and produces
+	Pops, Pushes int
+
+	ConstantGas uint64
+	DynamicGas  CustomGasFunc // optional
+
+	// MemorySize returns the memory size the opcode requires, the memory is then expanded to
+	// it before the opcode is executed and the expansion cost is added to its dynamic gas
+	MemorySize CustomMemorySizeFunc // optional
+
+	Execute CustomExecutionFunc
+
+	// Writes is true when the opcode modifies the state, it's then rejected within a static call
+	Writes bool
+
+	// GasChangeReason is the reason the gas consumed by the opcode is recorded under by Firehose,
+	// the gas consumed is not recorded when it's empty
+	GasChangeReason firehose.GasChangeReason
+}
+
+// CustomGasFunc computes the dynamic gas cost of a custom opcode, it's called before the
+// opcode is executed with the stack the opcode is about to consume.
+type CustomGasFunc func(evm *EVM, contract *Contract, stack *Stack, mem *Memory) (uint64, error)
+
+// CustomMemorySizeFunc computes the memory size required by a custom opcode out of the stack
+// it's about to consume, `overflow` being true when the size does not fit in 64 bits.
+type CustomMemorySizeFunc func(stack *Stack) (size uint64, overflow bool)
+
+// CustomExecutionFunc executes a custom opcode, the returned data is ignored unless the
+// opcode halts the execution through an error.
+type CustomExecutionFunc func(scope *OpCodeScope) ([]byte, error)
+
+// OpCodeScope is the execution scope of a custom opcode.
+type OpCodeScope struct {
+	EVM      *EVM
+	Contract *Contract
+	Stack    *Stack
+	Memory   *Memory
+}
+
+// opCodeRegistration is an opcode registered through `RegisterOpCode`.
+type opCodeRegistration struct {
+	operation *operation
+	op        OpCode
+	isActive  func(rules params.Rules) bool
+}
+
+var registeredOpCodes []opCodeRegistration
+
+// RegisterOpCode registers a custom opcode, active for the chain rules for which `isActive`
+// returns true, it's meant for chain variants to add their own opcodes without copying the
+// whole jump table construction. It must only be called while initializing packages and
+// panics if the opcode's byte or name is already used by any fork.
+func RegisterOpCode(custom CustomOpCode, isActive func(rules params.Rules) bool) {
+	if custom.Name == "" {
+		panic(fmt.Errorf("opcode 0x%x must have a name", int(custom.OpCode)))
+	}
+	if custom.Execute == nil {
+		panic(fmt.Errorf("opcode %s must have an execution function", custom.Name))
+	}
+	if existing, found := opCodeToString[custom.OpCode]; found {
+		panic(fmt.Errorf("opcode %s cannot be registered at 0x%x, already used by %s", custom.Name, int(custom.OpCode), existing))
+	}
+	if existing, found := stringToOp[custom.Name]; found {
+		panic(fmt.Errorf("opcode %s cannot be registered at 0x%x, name already used at 0x%x", custom.Name, int(custom.OpCode), int(existing)))
+	}
+
+	execute := custom.Execute
+	operation := &operation{
+		execute: func(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+			return execute(&OpCodeScope{
+				EVM:      interpreter.evm,
+				Contract: callContext.contract,
+				Stack:    callContext.stack,
+				Memory:   callContext.memory,
+			})
+		},
+		constantGas: custom.ConstantGas,
+		minStack:    minStack(custom.Pops, custom.Pushes),
+		maxStack:    maxStack(custom.Pops, custom.Pushes),
+		writes:      custom.Writes,
+	}
+	if memorySize := custom.MemorySize; memorySize != nil {
+		operation.memorySize = memorySizeFunc(memorySize)
+	}
+	if dynamicGas, expandsMemory := custom.DynamicGas, custom.MemorySize != nil; dynamicGas != nil || expandsMemory {
+		operation.dynamicGas = func(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+			var gas uint64
+			if expandsMemory {
+				memoryGas, err := memoryGasCost(mem, memorySize)
+				if err != nil {
+					return 0, err
+				}
+				gas = memoryGas
+			}
+			if dynamicGas != nil {
+				opGas, err := dynamicGas(evm, contract, stack, mem)
+				if err != nil {
+					return 0, err
+				}
+				var overflow bool
+				if gas, overflow = math.SafeAdd(gas, opGas); overflow {
+					return 0, ErrGasUintOverflow
+				}
+			}
+			return gas, nil
+		}
+	}
+
+	opCodeToString[custom.OpCode] = custom.Name
+	stringToOp[custom.Name] = custom.OpCode
+	if custom.GasChangeReason != "" {
+		opCodeToGasChangeReasonMap[custom.OpCode] = custom.GasChangeReason
+	}
+
+	registeredOpCodes = append(registeredOpCodes, opCodeRegistration{operation, custom.OpCode, isActive})
+}
+
+// enableRegisteredOpCodes adds the registered opcodes active under `rules` to the jump table.
+func enableRegisteredOpCodes(jt *JumpTable, rules params.Rules) {
+	for _, registration := range registeredOpCodes {
+		if registration.isActive(rules) {
+			jt[registration.op] = registration.operation
+		}
+	}
+}
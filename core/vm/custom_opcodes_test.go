@@ -0,0 +1,153 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testDoubleOpCode doubles the item on top of the stack, it's active from Berlin
+const testDoubleOpCode OpCode = 0x0c
+
+var testDoubleGasChangeReason = firehose.RegisterGasChangeReason("test_double")
+
+// testFillOpCode sets the 32 bytes of memory at the offset on top of the stack to 0xff,
+// it's active from Berlin
+const testFillOpCode OpCode = 0x0e
+
+func init() {
+	RegisterOpCode(CustomOpCode{
+		OpCode:      testFillOpCode,
+		Name:        "MFILL",
+		Pops:        1,
+		ConstantGas: GasFastestStep,
+		MemorySize:  memoryMStore,
+		Execute: func(scope *OpCodeScope) ([]byte, error) {
+			offset := scope.Stack.Pop()
+			scope.Memory.Set(offset.Uint64(), 32, bytes.Repeat([]byte{0xff}, 32))
+			return nil, nil
+		},
+	}, func(rules params.Rules) bool { return rules.IsBerlin })
+
+	RegisterOpCode(CustomOpCode{
+		OpCode:      testDoubleOpCode,
+		Name:        "DOUBLE",
+		Pops:        1,
+		Pushes:      1,
+		ConstantGas: 7,
+		DynamicGas: func(evm *EVM, contract *Contract, stack *Stack, mem *Memory) (uint64, error) {
+			return stack.Back(0).Uint64(), nil
+		},
+		Execute: func(scope *OpCodeScope) ([]byte, error) {
+			x := scope.Stack.Pop()
+			scope.Stack.Push(x.Add(&x, &x))
+			return nil, nil
+		},
+		GasChangeReason: testDoubleGasChangeReason,
+	}, func(rules params.Rules) bool { return rules.IsBerlin })
+}
+
+func TestEVM_RegisteredOpCode(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller   = common.HexToAddress("0x01")
+		contract = common.HexToAddress("0x0c0de")
+	)
+
+	if name := testDoubleOpCode.String(); name != "DOUBLE" {
+		t.Errorf("name mismatch: have %q, want %q", name, "DOUBLE")
+	}
+	if op := StringToOp("DOUBLE"); op != testDoubleOpCode {
+		t.Errorf("opcode mismatch: have %v, want %v", op, testDoubleOpCode)
+	}
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(contract, []byte{
+		byte(PUSH1), 0x15, byte(testDoubleOpCode), // double(21)
+		byte(PUSH1), 0x00, byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH1), 0x00, byte(RETURN),
+	}, nil)
+
+	firehoseContext := startTestTx(&contract)
+
+	evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, params.AllEthashProtocolChanges, Config{})
+
+	ret, _, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if !bytes.Equal(ret, common.LeftPadBytes([]byte{0x2a}, 32)) {
+		t.Errorf("return mismatch: have %x, want 2a", ret)
+	}
+
+	// The constant and dynamic costs are recorded under the registered reason
+	if line := "FIRE GAS_CHANGE 1 99997 99969 test_double "; !bytes.Contains(firehoseContext.FirehoseLog(), []byte(line)) {
+		t.Errorf("firehose did not record %q:\n%s", line, firehoseContext.FirehoseLog())
+	}
+
+	// The opcode is not active before Berlin
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.BerlinBlock = big.NewInt(10)
+
+	evm = newTestEVM(TxContext{}, statedb, &chainConfig, Config{})
+	var invalidOpCode *ErrInvalidOpCode
+	if _, _, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int)); !errors.As(err, &invalidOpCode) {
+		t.Errorf("inactive opcode error mismatch: have %v, want an invalid opcode error", err)
+	}
+}
+
+func TestEVM_RegisteredOpCodeMemory(t *testing.T) {
+	var (
+		caller   = common.HexToAddress("0x01")
+		contract = common.HexToAddress("0x0c0de")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(contract, []byte{
+		byte(PUSH1), 0x00, byte(testFillOpCode), // mfill(0)
+		byte(PUSH1), 0x20, byte(PUSH1), 0x00, byte(RETURN),
+	}, nil)
+
+	evm := newTestEVM(TxContext{}, statedb, params.AllEthashProtocolChanges, Config{})
+
+	ret, gasLeft, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if !bytes.Equal(ret, bytes.Repeat([]byte{0xff}, 32)) {
+		t.Errorf("return mismatch: have %x, want 32 0xff bytes", ret)
+	}
+
+	// The pushes, MFILL and the expansion of the memory to one word, RETURN not expanding it
+	if gasUsed, want := 100000-gasLeft, uint64(3*GasFastestStep+GasFastestStep+3); gasUsed != want {
+		t.Errorf("gas used mismatch: have %d, want %d", gasUsed, want)
+	}
+}
+
+func TestRegisterOpCode_Conflicts(t *testing.T) {
+	execute := func(scope *OpCodeScope) ([]byte, error) { return nil, nil }
+	always := func(rules params.Rules) bool { return true }
+
+	for name, custom := range map[string]CustomOpCode{
+		"used byte": {OpCode: PUSH0, Name: "OTHER", Execute: execute},
+		"used name": {OpCode: 0x0d, Name: "DOUBLE", Execute: execute},
+		"no name":   {OpCode: 0x0d, Execute: execute},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: registration did not panic", name)
+				}
+			}()
+			RegisterOpCode(custom, always)
+		}()
+	}
+}
@@ -20,15 +20,24 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/holiman/uint256"
 )
 
 var activators = map[int]func(*JumpTable){
+	7516: enable7516,
+	6780: enable6780,
+	5656: enable5656,
+	4844: enable4844,
+	3860: enable3860,
+	3855: enable3855,
 	2929: enable2929,
 	2200: enable2200,
 	1884: enable1884,
 	1344: enable1344,
+	1153: enable1153,
 }
 
 // EnableEIP enables the given EIP on the config.
@@ -144,3 +153,166 @@ func enable2929(jt *JumpTable) {
 	jt[SELFDESTRUCT].constantGas = params.SelfdestructGasEIP150
 	jt[SELFDESTRUCT].dynamicGas = gasSelfdestructEIP2929
 }
+
+// enable3855 applies EIP-3855 (PUSH0 opcode)
+func enable3855(jt *JumpTable) {
+	// New opcode
+	jt[PUSH0] = &operation{
+		execute:     opPush0,
+		constantGas: GasQuickStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+}
+
+// enable3860 applies EIP-3860 (Limit and meter initcode), CREATE and CREATE2 charge
+// an extra cost per word of init code and fail when it exceeds params.MaxInitCodeSize
+func enable3860(jt *JumpTable) {
+	// The operations are shared by the jump tables, the CREATE and CREATE2 ones are copied
+	create := *jt[CREATE]
+	create.dynamicGas = gasCreateEip3860
+	jt[CREATE] = &create
+
+	create2 := *jt[CREATE2]
+	create2.dynamicGas = gasCreate2Eip3860
+	jt[CREATE2] = &create2
+}
+
+// opPush0 implements the PUSH0 opcode
+func opPush0(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	callContext.stack.push(new(uint256.Int))
+	return nil, nil
+}
+
+// enable1153 applies EIP-1153 "Transient Storage"
+// - Adds TLOAD that reads from transient storage
+// - Adds TSTORE that writes to transient storage
+func enable1153(jt *JumpTable) {
+	jt[TLOAD] = &operation{
+		execute:     opTload,
+		constantGas: WarmStorageReadCostEIP2929,
+		minStack:    minStack(1, 1),
+		maxStack:    maxStack(1, 1),
+	}
+
+	jt[TSTORE] = &operation{
+		execute:     opTstore,
+		constantGas: WarmStorageReadCostEIP2929,
+		minStack:    minStack(2, 0),
+		maxStack:    maxStack(2, 0),
+		writes:      true,
+	}
+}
+
+// opTload implements TLOAD opcode
+func opTload(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	loc := callContext.stack.peek()
+	hash := common.Hash(loc.Bytes32())
+	val := interpreter.evm.StateDB.GetTransientState(callContext.contract.Address(), hash)
+	loc.SetBytes(val.Bytes())
+	return nil, nil
+}
+
+// opTstore implements TSTORE opcode, the write protection of static calls is enforced by
+// the interpreter since the operation `writes`
+func opTstore(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	loc := callContext.stack.pop()
+	val := callContext.stack.pop()
+	interpreter.evm.StateDB.SetTransientState(callContext.contract.Address(),
+		loc.Bytes32(), val.Bytes32(), interpreter.evm.FirehoseContext)
+	return nil, nil
+}
+
+// enable5656 enables EIP-5656 (MCOPY opcode)
+// https://eips.ethereum.org/EIPS/eip-5656
+func enable5656(jt *JumpTable) {
+	jt[MCOPY] = &operation{
+		execute:     opMcopy,
+		constantGas: GasFastestStep,
+		dynamicGas:  gasMcopy,
+		minStack:    minStack(3, 0),
+		maxStack:    maxStack(3, 0),
+		memorySize:  memoryMcopy,
+	}
+}
+
+// opMcopy implements the MCOPY opcode (https://eips.ethereum.org/EIPS/eip-5656)
+func opMcopy(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	var (
+		dst    = callContext.stack.pop()
+		src    = callContext.stack.pop()
+		length = callContext.stack.pop()
+	)
+	// These values are checked for overflow during memory expansion calculation
+	// (the memorySize function on the opcode).
+	callContext.memory.Copy(dst.Uint64(), src.Uint64(), length.Uint64())
+	return nil, nil
+}
+
+// enable6780 applies EIP-6780 (SELFDESTRUCT only in same transaction), SELFDESTRUCT deletes
+// the account only when it's a contract created in the same transaction, it otherwise only
+// sends the account's balance to the beneficiary
+func enable6780(jt *JumpTable) {
+	// The operations are shared by the jump tables, the SELFDESTRUCT one is copied
+	selfdestruct := *jt[SELFDESTRUCT]
+	selfdestruct.execute = opSuicide6780
+	jt[SELFDESTRUCT] = &selfdestruct
+}
+
+// opSuicide6780 implements the SELFDESTRUCT opcode as of EIP-6780, the balance is moved
+// to the beneficiary before the account is deleted, if it is, so that it's burned only
+// when a contract created in the transaction is its own beneficiary
+func opSuicide6780(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	var (
+		beneficiary = callContext.stack.pop()
+		address     = callContext.contract.Address()
+		balance     = interpreter.evm.StateDB.GetBalance(address)
+	)
+	interpreter.evm.StateDB.SubBalance(address, balance, interpreter.evm.FirehoseContext, firehose.SuicideWithdrawBalanceChangeReason)
+	interpreter.evm.StateDB.AddBalance(beneficiary.Bytes20(), balance, false, interpreter.evm.FirehoseContext, firehose.SuicideRefundBalanceChangeReason)
+	interpreter.evm.StateDB.Suicide6780(address, interpreter.evm.FirehoseContext)
+	return nil, nil
+}
+
+// enable4844 applies EIP-4844 (BLOBHASH opcode)
+func enable4844(jt *JumpTable) {
+	jt[BLOBHASH] = &operation{
+		execute:     opBlobHash,
+		constantGas: GasFastestStep,
+		minStack:    minStack(1, 1),
+		maxStack:    maxStack(1, 1),
+	}
+}
+
+// opBlobHash implements the BLOBHASH opcode, the blob transactions are not supported, the
+// hashes are the ones set by embedders in the `TxContext`, none otherwise
+func opBlobHash(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	index := callContext.stack.peek()
+	if index.LtUint64(uint64(len(interpreter.evm.TxContext.BlobHashes))) {
+		blobHash := interpreter.evm.TxContext.BlobHashes[index.Uint64()]
+		index.SetBytes32(blobHash[:])
+	} else {
+		index.Clear()
+	}
+	return nil, nil
+}
+
+// enable7516 applies EIP-7516 (BLOBBASEFEE opcode)
+func enable7516(jt *JumpTable) {
+	jt[BLOBBASEFEE] = &operation{
+		execute:     opBlobBaseFee,
+		constantGas: GasQuickStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+}
+
+// opBlobBaseFee implements BLOBBASEFEE opcode
+func opBlobBaseFee(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	blobBaseFee := new(uint256.Int).SetUint64(params.BlobTxMinBlobGasprice)
+	if interpreter.evm.Context.BlobBaseFee != nil {
+		blobBaseFee, _ = uint256.FromBig(interpreter.evm.Context.BlobBaseFee)
+	}
+	callContext.stack.push(blobBaseFee)
+	return nil, nil
+}
@@ -0,0 +1,218 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestEVM_CancunOpCodes(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller   = common.HexToAddress("0x01")
+		contract = common.HexToAddress("0x0c0de")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(contract, []byte{
+		byte(PUSH1), 0x2a, byte(PUSH1), 0x01, byte(TSTORE), // tstore(1, 0x2a)
+		byte(PUSH1), 0x01, byte(TLOAD), byte(PUSH1), 0x00, byte(MSTORE), // mstore(0, tload(1))
+		byte(PUSH1), 0x20, byte(PUSH1), 0x00, byte(PUSH1), 0x20, byte(MCOPY), // mcopy(32, 0, 32)
+		byte(BLOBBASEFEE), byte(PUSH1), 0x40, byte(MSTORE), // mstore(64, blobbasefee)
+		byte(PUSH1), 0x00, byte(BLOBHASH), byte(PUSH1), 0x60, byte(MSTORE), // mstore(96, blobhash(0))
+		byte(PUSH1), 0x80, byte(PUSH1), 0x00, byte(RETURN),
+	}, nil)
+
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.CancunBlock = big.NewInt(1)
+
+	firehoseContext := startTestTx(&contract)
+
+	blobHash := common.HexToHash("0x01ff")
+	evm := newTestEVM(TxContext{BlobHashes: []common.Hash{blobHash}, FirehoseContext: firehoseContext}, statedb, &chainConfig, Config{})
+
+	ret, _, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	expected := append(common.LeftPadBytes([]byte{0x2a}, 32), common.LeftPadBytes([]byte{0x2a}, 32)...)
+	expected = append(expected, common.LeftPadBytes([]byte{byte(params.BlobTxMinBlobGasprice)}, 32)...)
+	expected = append(expected, blobHash.Bytes()...)
+	if !bytes.Equal(ret, expected) {
+		t.Errorf("return mismatch:\nhave %x\nwant %x", ret, expected)
+	}
+
+	// The transient storage is not persisted
+	if stored := statedb.GetState(contract, common.BytesToHash([]byte{0x01})); stored != (common.Hash{}) {
+		t.Errorf("transient storage leaked into the storage: have %s", stored.Hex())
+	}
+
+	for _, line := range []string{
+		"FIRE TRANSIENT_STORAGE_CHANGE 1 000000000000000000000000000000000000c0de ",
+		"FIRE GAS_CHANGE 1 99994 99894 transient_storage ",
+		"FIRE GAS_CHANGE 1 99773 99764 memory_copy ",
+	} {
+		if !bytes.Contains(firehoseContext.FirehoseLog(), []byte(line)) {
+			t.Errorf("firehose did not record %q:\n%s", line, firehoseContext.FirehoseLog())
+		}
+	}
+
+	if _, _, err := evm.StaticCall(AccountRef(caller), contract, nil, 100000); err != ErrWriteProtection {
+		t.Errorf("static call error mismatch: have %v, want %v", err, ErrWriteProtection)
+	}
+}
+
+func TestEVM_SelfdestructEIP6780(t *testing.T) {
+	for _, cancun := range []bool{false, true} {
+		var (
+			caller      = common.HexToAddress("0x01")
+			contract    = common.HexToAddress("0x0c0de")
+			beneficiary = common.HexToAddress("0xbe")
+		)
+		selfdestruct := append([]byte{byte(PUSH20)}, beneficiary.Bytes()...)
+		selfdestruct = append(selfdestruct, byte(SELFDESTRUCT))
+
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(contract, selfdestruct, nil)
+		statedb.AddBalance(contract, big.NewInt(10), false, nil, firehose.IgnoredBalanceChangeReason)
+
+		chainConfig := *params.AllEthashProtocolChanges
+		if cancun {
+			chainConfig.CancunBlock = big.NewInt(1)
+		}
+		evm := newTestEVM(TxContext{}, statedb, &chainConfig, Config{})
+
+		// A contract created before the transaction is deleted only before Cancun, its balance
+		// is sent to the beneficiary either way
+		if _, _, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int)); err != nil {
+			t.Fatalf("cancun %t: call failed: %v", cancun, err)
+		}
+		if suicided := statedb.HasSuicided(contract); suicided == cancun {
+			t.Errorf("cancun %t: existing contract suicided mismatch: have %t", cancun, suicided)
+		}
+		if balance := statedb.GetBalance(beneficiary); balance.Cmp(big.NewInt(10)) != 0 {
+			t.Errorf("cancun %t: beneficiary balance mismatch: have %v, want 10", cancun, balance)
+		}
+		if balance := statedb.GetBalance(contract); balance.Sign() != 0 {
+			t.Errorf("cancun %t: contract balance mismatch: have %v, want 0", cancun, balance)
+		}
+
+		// A contract created in the transaction is deleted whatever the fork
+		_, created, _, err := evm.Create(AccountRef(caller), selfdestruct, 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("cancun %t: create failed: %v", cancun, err)
+		}
+		if !statedb.HasSuicided(created) {
+			t.Errorf("cancun %t: contract created in the transaction not suicided", cancun)
+		}
+	}
+}
+
+// pointEvaluationAddress is the address of the Cancun point evaluation precompiled contract.
+var pointEvaluationAddress = common.BytesToAddress([]byte{10})
+
+func TestPrecompiledPointEvaluation_InvalidInput(t *testing.T) {
+	pointEvaluation := PrecompiledContractsCancun[pointEvaluationAddress]
+
+	if _, err := pointEvaluation.Run(make([]byte, 191)); err != errBlobVerifyInvalidInputLength {
+		t.Errorf("short input error mismatch: have %v, want %v", err, errBlobVerifyInvalidInputLength)
+	}
+
+	// The versioned hash of an all zeroes input does not match its commitment
+	if _, err := pointEvaluation.Run(make([]byte, 192)); err != errBlobVerifyMismatchedVersion {
+		t.Errorf("mismatched version error mismatch: have %v, want %v", err, errBlobVerifyMismatchedVersion)
+	}
+
+	if name := PrecompiledContractName(pointEvaluationAddress); name != "kzg_point_evaluation" {
+		t.Errorf("point evaluation name mismatch: have %q, want %q", name, "kzg_point_evaluation")
+	}
+}
+
+func TestPrecompiledPointEvaluation(t *testing.T) {
+	// A proof of the EIP-4844 reference tests, verified against the mainnet trusted setup
+	input := common.FromHex("01e798154708fe7789429634053cbf9f99b619f9f084048927333fce637f549b564c0a11a0f704f4fc3e8acfe0f8245f0ad1347b378fbf96e206da11a5d3630624d25032e67a7e6a4910df5834b8fe70e6bcfeeac0352434196bdf4b2485d5a18f59a8d2a1a625a17f3fea0fe5eb8c896db3764f3185481bc22f91b4aaffcca25f26936857bc3a7c2539ea8ec3a952b7873033e038326e87ed3e1276fd140253fa08e9fc25fb2d9a98527fc22a2c9612fbeafdad446cbc7bcdbdcd780af2c16a")
+	expected := common.FromHex("000000000000000000000000000000000000000000000000000000000000100073eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001")
+
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.CancunBlock = big.NewInt(1)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	evm := newTestEVM(TxContext{}, statedb, &chainConfig, Config{})
+
+	ret, gasLeft, err := evm.StaticCall(AccountRef(common.HexToAddress("0x01")), pointEvaluationAddress, input, 100000)
+	if err != nil {
+		t.Fatalf("point evaluation failed: %v", err)
+	}
+	if !bytes.Equal(ret, expected) {
+		t.Errorf("point evaluation output mismatch: have %x, want %x", ret, expected)
+	}
+	if gasUsed := 100000 - gasLeft; gasUsed != params.BlobTxPointEvaluationPrecompileGas {
+		t.Errorf("point evaluation gas used mismatch: have %d, want %d", gasUsed, params.BlobTxPointEvaluationPrecompileGas)
+	}
+
+	// The same proof of another claim is rejected
+	input[95] ^= 0x01
+	if _, _, err := evm.StaticCall(AccountRef(common.HexToAddress("0x01")), pointEvaluationAddress, input, 100000); !errors.Is(err, errBlobVerifyKZGProof) {
+		t.Errorf("wrong claim error mismatch: have %v, want %v", err, errBlobVerifyKZGProof)
+	}
+}
+
+func TestEVM_DelegationTargetAccessGas(t *testing.T) {
+	var (
+		caller    = common.HexToAddress("0x01")
+		contract  = common.HexToAddress("0x0c0de")
+		authority = common.HexToAddress("0xa0")
+		target    = common.HexToAddress("0xb0")
+	)
+
+	pragueConfig := *params.AllEthashProtocolChanges
+	pragueConfig.ShanghaiBlock, pragueConfig.CancunBlock, pragueConfig.PragueBlock = big.NewInt(1), big.NewInt(1), big.NewInt(1)
+
+	// The contract calls the authority, PUSH1 0 (x5), PUSH20 <authority>, PUSH2 0xffff, CALL,
+	// the authority delegating its code to the target
+	callGasUsed := func(config *params.ChainConfig, warmTarget bool) uint64 {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(contract, append(append(common.FromHex("0x60006000600060006000"), append([]byte{byte(PUSH20)}, authority.Bytes()...)...), byte(PUSH2), 0xff, 0xff, byte(CALL), byte(STOP)), nil)
+		statedb.SetCode(authority, types.AddressToDelegation(target), nil)
+		statedb.SetCode(target, []byte{byte(STOP)}, nil)
+		if warmTarget {
+			statedb.AddAddressToAccessList(target)
+		}
+
+		evm := newTestEVM(TxContext{}, statedb, config, Config{})
+		_, gasLeft, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		return 100000 - gasLeft
+	}
+
+	// The pushes, the warm CALL cost and the cold access of the authority
+	base := 5*3 + 3 + 3 + WarmStorageReadCostEIP2929 + ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929
+	for _, test := range []struct {
+		name       string
+		config     *params.ChainConfig
+		warmTarget bool
+		want       uint64
+	}{
+		// The designator is run as code, its invalid opcode burning the gas given to the call
+		{"before prague", params.AllEthashProtocolChanges, false, base + 0xffff},
+		{"cold target", &pragueConfig, false, base + ColdAccountAccessCostEIP2929},
+		{"warm target", &pragueConfig, true, base + WarmStorageReadCostEIP2929},
+	} {
+		if have := callGasUsed(test.config, test.warmTarget); have != test.want {
+			t.Errorf("%s: gas used mismatch: have %d, want %d", test.name, have, test.want)
+		}
+	}
+}
@@ -19,6 +19,8 @@ package vm
 import (
 	"errors"
 	"fmt"
+
+	"github.com/ethereum/go-ethereum/firehose"
 )
 
 // List evm execution errors
@@ -37,6 +39,7 @@ var (
 	ErrWriteProtection          = errors.New("write protection")
 	ErrReturnDataOutOfBounds    = errors.New("return data out of bounds")
 	ErrGasUintOverflow          = errors.New("gas uint64 overflow")
+	ErrNonceUintOverflow        = errors.New("nonce uint64 overflow")
 	ErrInvalidRetsub            = errors.New("invalid retsub")
 	ErrReturnStackExceeded      = errors.New("return stack limit reached")
 )
@@ -69,3 +72,52 @@ type ErrInvalidOpCode struct {
 }
 
 func (e *ErrInvalidOpCode) Error() string { return fmt.Sprintf("invalid opcode: %s", e.opcode) }
+
+var errorToCallFailureCodeMap = map[error]firehose.CallFailureCode{
+	ErrExecutionReverted:        firehose.ExecutionRevertedCallFailureCode,
+	ErrDepth:                    firehose.DepthLimitCallFailureCode,
+	ErrInsufficientBalance:      firehose.InsufficientBalanceCallFailureCode,
+	ErrOutOfGas:                 firehose.OutOfGasCallFailureCode,
+	ErrCodeStoreOutOfGas:        firehose.CodeStoreOutOfGasCallFailureCode,
+	ErrGasUintOverflow:          firehose.GasUintOverflowCallFailureCode,
+	ErrContractAddressCollision: firehose.ContractAddressCollisionCallFailureCode,
+	ErrNonceUintOverflow:        firehose.NonceOverflowCallFailureCode,
+	ErrMaxCodeSizeExceeded:      firehose.MaxCodeSizeExceededCallFailureCode,
+	ErrInvalidJump:              firehose.InvalidJumpCallFailureCode,
+	ErrInvalidSubroutineEntry:   firehose.InvalidSubroutineCallFailureCode,
+	ErrInvalidRetsub:            firehose.InvalidSubroutineCallFailureCode,
+	ErrReturnStackExceeded:      firehose.InvalidSubroutineCallFailureCode,
+	ErrWriteProtection:          firehose.WriteProtectionCallFailureCode,
+	ErrReturnDataOutOfBounds:    firehose.ReturnDataOutOfBoundsCallFailureCode,
+
+	errBadPairingInput:                     firehose.PrecompileFailureCallFailureCode,
+	errBlake2FInvalidInputLength:           firehose.PrecompileFailureCallFailureCode,
+	errBlake2FInvalidFinalFlag:             firehose.PrecompileFailureCallFailureCode,
+	errBLS12381InvalidInputLength:          firehose.PrecompileFailureCallFailureCode,
+	errBLS12381InvalidFieldElementTopBytes: firehose.PrecompileFailureCallFailureCode,
+	errBLS12381G1PointSubgroup:             firehose.PrecompileFailureCallFailureCode,
+	errBLS12381G2PointSubgroup:             firehose.PrecompileFailureCallFailureCode,
+	errBlobVerifyInvalidInputLength:        firehose.PrecompileFailureCallFailureCode,
+	errBlobVerifyMismatchedVersion:         firehose.PrecompileFailureCallFailureCode,
+	errBlobVerifyKZGProof:                  firehose.PrecompileFailureCallFailureCode,
+}
+
+// ErrorToCallFailureCode returns the stable Firehose code classifying `err`, the error a call
+// failed with, `firehose.UnknownCallFailureCode` when it's not one of the known execution
+// errors (like the errors of the curve libraries used by the precompiled contracts).
+func ErrorToCallFailureCode(err error) firehose.CallFailureCode {
+	switch err.(type) {
+	case *ErrStackUnderflow:
+		return firehose.StackUnderflowCallFailureCode
+	case *ErrStackOverflow:
+		return firehose.StackOverflowCallFailureCode
+	case *ErrInvalidOpCode:
+		return firehose.InvalidOpCodeCallFailureCode
+	}
+
+	if code, found := errorToCallFailureCodeMap[err]; found {
+		return code
+	}
+
+	return firehose.UnknownCallFailureCode
+}
@@ -0,0 +1,34 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/firehose"
+)
+
+func TestErrorToCallFailureCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want firehose.CallFailureCode
+	}{
+		{ErrExecutionReverted, firehose.ExecutionRevertedCallFailureCode},
+		{ErrDepth, firehose.DepthLimitCallFailureCode},
+		{ErrInsufficientBalance, firehose.InsufficientBalanceCallFailureCode},
+		{ErrOutOfGas, firehose.OutOfGasCallFailureCode},
+		{ErrCodeStoreOutOfGas, firehose.CodeStoreOutOfGasCallFailureCode},
+		{ErrContractAddressCollision, firehose.ContractAddressCollisionCallFailureCode},
+		{ErrReturnStackExceeded, firehose.InvalidSubroutineCallFailureCode},
+		{&ErrStackUnderflow{stackLen: 1, required: 2}, firehose.StackUnderflowCallFailureCode},
+		{&ErrStackOverflow{stackLen: 1025, limit: 1024}, firehose.StackOverflowCallFailureCode},
+		{&ErrInvalidOpCode{opcode: 0xfe}, firehose.InvalidOpCodeCallFailureCode},
+		{errBlake2FInvalidFinalFlag, firehose.PrecompileFailureCallFailureCode},
+		{errors.New("out of gas"), firehose.UnknownCallFailureCode},
+	}
+
+	for _, test := range tests {
+		if have := ErrorToCallFailureCode(test.err); have != test.want {
+			t.Errorf("%q: failure code mismatch: have %s, want %s", test.err, have, test.want)
+		}
+	}
+}
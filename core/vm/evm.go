@@ -20,9 +20,9 @@ import (
 	"errors"
 	"math/big"
 	"sync/atomic"
-	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
@@ -46,31 +46,11 @@ type (
 // ActivePrecompiles returns the addresses of the precompiles enabled with the current
 // configuration
 func (evm *EVM) ActivePrecompiles() []common.Address {
-	switch {
-	case evm.chainRules.IsBerlin:
-		return PrecompiledAddressesBerlin
-	case evm.chainRules.IsIstanbul:
-		return PrecompiledAddressesIstanbul
-	case evm.chainRules.IsByzantium:
-		return PrecompiledAddressesByzantium
-	default:
-		return PrecompiledAddressesHomestead
-	}
+	return evm.activePrecompiles
 }
 
 func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
-	var precompiles map[common.Address]PrecompiledContract
-	switch {
-	case evm.chainRules.IsBerlin:
-		precompiles = PrecompiledContractsBerlin
-	case evm.chainRules.IsIstanbul:
-		precompiles = PrecompiledContractsIstanbul
-	case evm.chainRules.IsByzantium:
-		precompiles = PrecompiledContractsByzantium
-	default:
-		precompiles = PrecompiledContractsHomestead
-	}
-	p, ok := precompiles[addr]
+	p, ok := evm.precompiles[addr]
 	return p, ok
 }
 
@@ -109,14 +89,20 @@ type BlockContext struct {
 	BlockNumber *big.Int       // Provides information for NUMBER
 	Time        *big.Int       // Provides information for TIME
 	Difficulty  *big.Int       // Provides information for DIFFICULTY
+	BlobBaseFee *big.Int       // Provides information for BLOBBASEFEE, nil is the minimum blob base fee
 }
 
 // TxContext provides the EVM with information about a transaction.
 // All fields can change between transactions.
 type TxContext struct {
 	// Message information
-	Origin   common.Address // Provides information for ORIGIN
-	GasPrice *big.Int       // Provides information for GASPRICE
+	Origin     common.Address // Provides information for ORIGIN
+	GasPrice   *big.Int       // Provides information for GASPRICE
+	BlobHashes []common.Hash  // Provides information for BLOBHASH
+
+	// FirehoseContext records the execution of the transaction, nil (`firehose.NoOpContext`)
+	// when nothing should be recorded
+	FirehoseContext *firehose.Context
 }
 
 // EVM is the Ethereum Virtual Machine base object and provides
@@ -156,25 +142,35 @@ type EVM struct {
 	// applied in opCall*.
 	callGasTemp uint64
 
-	firehoseContext *firehose.Context
-}
+	// precompiles are the precompiled contracts callable, by address, and activePrecompiles
+	// their addresses, see `DefaultPrecompiles` and `WithPrecompiles`
+	precompiles       map[common.Address]PrecompiledContract
+	activePrecompiles []common.Address
 
-func (evm *EVM) FirehoseContext() *firehose.Context {
-	return evm.firehoseContext
+	logger EVMLogger
+
+	// tracers are fed by the steps of the execution with the `Tracer` semantics, their
+	// frames being reported through `logger`, see `NewTracerLogger`
+	tracers []Tracer
 }
 
 // NewEVM returns a new EVM. The returned EVM is not thread safe and should
 // only ever be used *once*.
-func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig *params.ChainConfig, vmConfig Config, firehoseContext *firehose.Context) *EVM {
+func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig *params.ChainConfig, vmConfig Config) *EVM {
 	evm := &EVM{
-		Context:         blockCtx,
-		TxContext:       txCtx,
-		StateDB:         statedb,
-		vmConfig:        vmConfig,
-		chainConfig:     chainConfig,
-		chainRules:      chainConfig.Rules(blockCtx.BlockNumber),
-		interpreters:    make([]Interpreter, 0, 1),
-		firehoseContext: firehoseContext,
+		Context:      blockCtx,
+		TxContext:    txCtx,
+		StateDB:      statedb,
+		vmConfig:     vmConfig,
+		chainConfig:  chainConfig,
+		interpreters: make([]Interpreter, 0, 1),
+	}
+	if cache := vmConfig.RulesCache; cache != nil && cache.config == chainConfig && blockCtx.BlockNumber != nil {
+		cached := cache.lookup(blockCtx.BlockNumber)
+		evm.chainRules, evm.precompiles, evm.activePrecompiles = cached.rules, cached.precompiles, cached.activePrecompiles
+	} else {
+		evm.chainRules = chainConfig.Rules(blockCtx.BlockNumber)
+		evm.precompiles, evm.activePrecompiles = DefaultPrecompiles(evm.chainRules)
 	}
 
 	if chainConfig.IsEWASM(blockCtx.BlockNumber) {
@@ -197,16 +193,17 @@ func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig
 	// as we always want to have the built-in EVM as the failover option.
 	evm.interpreters = append(evm.interpreters, NewEVMInterpreter(evm, vmConfig))
 	evm.interpreter = evm.interpreters[0]
+	evm.logger, evm.tracers = newEVMLogger(evm)
 
 	return evm
 }
 
-// Reset resets the EVM with a new transaction context.Reset
+// Reset resets the EVM with a new transaction context, including its Firehose context.
 // This is not threadsafe and should only be done very cautiously.
-func (evm *EVM) Reset(txCtx TxContext, statedb StateDB, firehoseContext *firehose.Context) {
+func (evm *EVM) Reset(txCtx TxContext, statedb StateDB) {
 	evm.TxContext = txCtx
 	evm.StateDB = statedb
-	evm.firehoseContext = firehoseContext
+	evm.logger, evm.tracers = newEVMLogger(evm)
 }
 
 // Cancel cancels any running EVM operation. This may be called concurrently and
@@ -230,32 +227,20 @@ func (evm *EVM) Interpreter() Interpreter {
 // the necessary steps to create accounts and reverses the state in case of an
 // execution error or failed value transfer.
 func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("CALL")
-		evm.firehoseContext.RecordCallParams("CALL", caller.Address(), addr, value, gas, input)
+	if evm.logger != nil {
+		evm.logger.CaptureEnter(CALL, caller.Address(), addr, input, gas, value)
+		defer func(startGas uint64) { evm.logger.CaptureExit(ret, startGas-leftOverGas, err) }(gas)
 	}
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, gas, nil
 	}
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, gas, ErrDepth
 	}
 	// Fail if we're trying to transfer more than the available balance
 	if value.Sign() != 0 && !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
-		}
-
 		return nil, gas, ErrInsufficientBalance
 	}
 	snapshot := evm.StateDB.Snapshot()
@@ -263,39 +248,23 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 
 	if !evm.StateDB.Exist(addr) {
 		if !isPrecompile && evm.chainRules.IsEIP158 && value.Sign() == 0 {
-			// Calling a non existing account, don't do anything, but ping the tracer
-			if evm.vmConfig.Debug && evm.depth == 0 {
-				evm.vmConfig.Tracer.CaptureStart(caller.Address(), addr, false, input, gas, value)
-				evm.vmConfig.Tracer.CaptureEnd(ret, 0, 0, nil)
-			}
-
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.EndCall(gas, nil)
-			}
-
+			// Calling a non existing account, don't do anything, the loggers are notified of
+			// the call nonetheless
 			return nil, gas, nil
 		}
-		evm.StateDB.CreateAccount(addr, evm.firehoseContext)
-	}
-	evm.Context.Transfer(evm.StateDB, caller.Address(), addr, value, evm.firehoseContext)
-
-	// Capture the tracer start/end events in debug mode
-	if evm.vmConfig.Debug && evm.depth == 0 {
-		evm.vmConfig.Tracer.CaptureStart(caller.Address(), addr, false, input, gas, value)
-		defer func(startGas uint64, startTime time.Time) { // Lazy evaluation of the parameters
-			evm.vmConfig.Tracer.CaptureEnd(ret, startGas-gas, time.Since(startTime), err)
-		}(gas, time.Now())
+		evm.StateDB.CreateAccount(addr, evm.FirehoseContext)
 	}
+	evm.Context.Transfer(evm.StateDB, caller.Address(), addr, value, evm.FirehoseContext)
 
 	if isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas, evm.firehoseContext)
+		ret, gas, err = evm.runPrecompiledContract(p, caller.Address(), addr, value, input, gas, false)
 	} else {
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
-		code := evm.StateDB.GetCode(addr)
+		code, codeHash := evm.resolveCode(addr)
 		if len(code) == 0 {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallWithoutCode()
+			if evm.FirehoseContext.Enabled() {
+				evm.FirehoseContext.RecordCallWithoutCode()
 			}
 
 			ret, err = nil, nil // gas is unchanged
@@ -303,8 +272,8 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 			addrCopy := addr
 			// If the account has no code, we can abort here
 			// The depth-check is already done, and precompiles handled above
-			contract := NewContract(caller, AccountRef(addrCopy), value, gas, evm.firehoseContext)
-			contract.SetCallCode(&addrCopy, evm.StateDB.GetCodeHash(addrCopy), code)
+			contract := NewContract(caller, AccountRef(addrCopy), value, gas, evm.FirehoseContext)
+			contract.SetCallCode(&addrCopy, codeHash, code)
 			ret, err = run(evm, contract, input, false)
 			gas = contract.Gas
 		}
@@ -313,31 +282,14 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// above we revert to the snapshot and consume any gas remaining. Additionally
 	// when we're in homestead this also counts for code storage gas errors.
 	if err != nil {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(gas, err.Error())
-		}
-
 		evm.StateDB.RevertToSnapshot(snapshot)
 		if err != ErrExecutionReverted {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordGasConsume(gas, gas, firehose.FailedExecutionGasChangeReason)
-			}
-
-			gas = 0
-		} else {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
-			}
+			gas -= evm.burnGas(gas)
 		}
 		// TODO: consider clearing up unused snapshots:
 		//} else {
 		//	evm.StateDB.DiscardSnapshot(snapshot)
 	}
-
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.EndCall(gas, ret)
-	}
-
 	return ret, gas, err
 }
 
@@ -349,23 +301,15 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 // CallCode differs from Call in the sense that it executes the given address'
 // code with the caller as context.
 func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("CALLCODE")
-		evm.firehoseContext.RecordCallParams("CALLCODE", caller.Address(), addr, value, gas, input)
+	if evm.logger != nil {
+		evm.logger.CaptureEnter(CALLCODE, caller.Address(), addr, input, gas, value)
+		defer func(startGas uint64) { evm.logger.CaptureExit(ret, startGas-leftOverGas, err) }(gas)
 	}
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, gas, nil
 	}
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, gas, ErrDepth
 	}
 	// Fail if we're trying to transfer more than the available balance
@@ -373,49 +317,29 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	// if caller doesn't have enough balance, it would be an error to allow
 	// over-charging itself. So the check here is necessary.
 	if !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
-		}
-
 		return nil, gas, ErrInsufficientBalance
 	}
 	var snapshot = evm.StateDB.Snapshot()
 
 	// It is allowed to call precompiles, even via delegatecall
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas, evm.firehoseContext)
+		ret, gas, err = evm.runPrecompiledContract(p, caller.Address(), caller.Address(), value, input, gas, false)
 	} else {
 		addrCopy := addr
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
-		contract := NewContract(caller, AccountRef(caller.Address()), value, gas, evm.firehoseContext)
-		contract.SetCallCode(&addrCopy, evm.StateDB.GetCodeHash(addrCopy), evm.StateDB.GetCode(addrCopy))
+		contract := NewContract(caller, AccountRef(caller.Address()), value, gas, evm.FirehoseContext)
+		code, codeHash := evm.resolveCode(addrCopy)
+		contract.SetCallCode(&addrCopy, codeHash, code)
 		ret, err = run(evm, contract, input, false)
 		gas = contract.Gas
 	}
 	if err != nil {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(gas, err.Error())
-		}
-
 		evm.StateDB.RevertToSnapshot(snapshot)
 		if err != ErrExecutionReverted {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordGasConsume(gas, gas, firehose.FailedExecutionGasChangeReason)
-			}
-
-			gas = 0
-		} else {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
-			}
+			gas -= evm.burnGas(gas)
 		}
 	}
-
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.EndCall(gas, ret)
-	}
-
 	return ret, gas, err
 }
 
@@ -425,76 +349,38 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 // DelegateCall differs from CallCode in the sense that it executes the given address'
 // code with the caller as context and the caller is set to the caller of the caller.
 func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("DELEGATE")
-
-		// Firehose a Delegate Call is quite different then a standard Call or event Call Code
-		// because it executes using the state of the parent call. Assumuming a contract that
-		// receives a method `execute`, let's say this contract is A. When in the `execute`
-		// method a `delegatecall` is performed to contract B, the net effect is that code of
-		// B is loaded and executed against the current state and value of contract A. As such,
-		// the real caller is the one that called contract A.
-		//
-		// Thoughts: When I wrote this comment, I realized that it's misleading in Firehose stack
-		// in fact. The caller is still contract A, we should probably have recorded the parent
-		// caller as actually another extra field only available on Delegate Call. The same problem
-		// arise with the `value` field, it's actually the value sent to parent call that initiate
-		// `execute` on contract A.
-
-		// It's a sure thing that caller is a Contract, it cannot be anything else, so we are safe
-		parent := caller.(*Contract)
-		evm.firehoseContext.RecordCallParams("DELEGATE", parent.Address(), addr, parent.value, gas, input)
+	if evm.logger != nil {
+		evm.logger.CaptureEnter(DELEGATECALL, caller.Address(), addr, input, gas, nil)
+		defer func(startGas uint64) { evm.logger.CaptureExit(ret, startGas-leftOverGas, err) }(gas)
 	}
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, gas, nil
 	}
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, gas, ErrDepth
 	}
 	var snapshot = evm.StateDB.Snapshot()
 
 	// It is allowed to call precompiles, even via delegatecall
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas, evm.firehoseContext)
+		parent := caller.(*Contract)
+		ret, gas, err = evm.runPrecompiledContract(p, parent.CallerAddress, parent.Address(), parent.value, input, gas, false)
 	} else {
 		addrCopy := addr
 		// Initialise a new contract and make initialise the delegate values
-		contract := NewContract(caller, AccountRef(caller.Address()), nil, gas, evm.firehoseContext).AsDelegate()
-		contract.SetCallCode(&addrCopy, evm.StateDB.GetCodeHash(addrCopy), evm.StateDB.GetCode(addrCopy))
+		contract := NewContract(caller, AccountRef(caller.Address()), nil, gas, evm.FirehoseContext).AsDelegate()
+		code, codeHash := evm.resolveCode(addrCopy)
+		contract.SetCallCode(&addrCopy, codeHash, code)
 		ret, err = run(evm, contract, input, false)
 		gas = contract.Gas
 	}
 	if err != nil {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(gas, err.Error())
-		}
-
 		evm.StateDB.RevertToSnapshot(snapshot)
 		if err != ErrExecutionReverted {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordGasConsume(gas, gas, firehose.FailedExecutionGasChangeReason)
-			}
-			gas = 0
-		} else {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
-			}
+			gas -= evm.burnGas(gas)
 		}
 	}
-
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.EndCall(gas, ret)
-	}
-
 	return ret, gas, err
 }
 
@@ -503,23 +389,15 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 // Opcodes that attempt to perform such modifications will result in exceptions
 // instead of performing the modifications.
 func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("STATIC")
-		evm.firehoseContext.RecordCallParams("STATIC", caller.Address(), addr, firehose.EmptyValue, gas, input)
+	if evm.logger != nil {
+		evm.logger.CaptureEnter(STATICCALL, caller.Address(), addr, input, gas, nil)
+		defer func(startGas uint64) { evm.logger.CaptureExit(ret, startGas-leftOverGas, err) }(gas)
 	}
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, gas, nil
 	}
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, gas, ErrDepth
 	}
 	// We take a snapshot here. This is a bit counter-intuitive, and could probably be skipped.
@@ -531,14 +409,14 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 
 	p, isPrecompile := evm.precompile(addr)
 
-	// We do an AddBalance of zero here, just in order to trigger a touch.
+	// We touch the account here, the equivalent of an AddBalance of zero.
 	// This doesn't matter on Mainnet, where all empties are gone at the time of Byzantium,
 	// but is the correct thing to do and matters on other networks, in tests, and potential
 	// future scenarios
-	evm.StateDB.AddBalance(addr, big0, isPrecompile, evm.firehoseContext, firehose.IgnoredBalanceChangeReason)
+	evm.StateDB.Touch(addr, isPrecompile, evm.FirehoseContext)
 
 	if isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas, evm.firehoseContext)
+		ret, gas, err = evm.runPrecompiledContract(p, caller.Address(), addr, big0, input, gas, true)
 	} else {
 		// At this point, we use a copy of address. If we don't, the go compiler will
 		// leak the 'contract' to the outer scope, and make allocation for 'contract'
@@ -546,8 +424,9 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 		addrCopy := addr
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
-		contract := NewContract(caller, AccountRef(addrCopy), new(big.Int), gas, evm.firehoseContext)
-		contract.SetCallCode(&addrCopy, evm.StateDB.GetCodeHash(addrCopy), evm.StateDB.GetCode(addrCopy))
+		contract := NewContract(caller, AccountRef(addrCopy), new(big.Int), gas, evm.FirehoseContext)
+		code, codeHash := evm.resolveCode(addrCopy)
+		contract.SetCallCode(&addrCopy, codeHash, code)
 		// When an error was returned by the EVM or when setting the creation code
 		// above we revert to the snapshot and consume any gas remaining. Additionally
 		// when we're in Homestead this also counts for code storage gas errors.
@@ -555,28 +434,11 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 		gas = contract.Gas
 	}
 	if err != nil {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(gas, err.Error())
-		}
-
 		evm.StateDB.RevertToSnapshot(snapshot)
 		if err != ErrExecutionReverted {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordGasConsume(gas, gas, firehose.FailedExecutionGasChangeReason)
-			}
-
-			gas = 0
-		} else {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
-			}
+			gas -= evm.burnGas(gas)
 		}
 	}
-
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.EndCall(gas, ret)
-	}
-
 	return ret, gas, err
 }
 
@@ -593,30 +455,27 @@ func (c *codeAndHash) Hash() common.Hash {
 }
 
 // create creates a new contract using code as deployment code.
-func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64, value *big.Int, address common.Address) ([]byte, common.Address, uint64, error) {
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("CREATE")
-		evm.firehoseContext.RecordCallParams("CREATE", caller.Address(), address, value, gas, nil)
+func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64, value *big.Int, address common.Address, opCode OpCode) (ret []byte, createdAddr common.Address, leftOverGas uint64, err error) {
+	if evm.logger != nil {
+		evm.logger.CaptureEnter(opCode, caller.Address(), address, codeAndHash.code, gas, value)
+		defer func(startGas uint64) { evm.logger.CaptureExit(ret, startGas-leftOverGas, err) }(gas)
 	}
 
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if evm.depth > int(params.CallCreateDepth) {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, common.Address{}, gas, ErrDepth
 	}
 	if !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
-		}
-
 		return nil, common.Address{}, gas, ErrInsufficientBalance
 	}
 	nonce := evm.StateDB.GetNonce(caller.Address())
-	evm.StateDB.SetNonce(caller.Address(), nonce+1, evm.firehoseContext)
+	if nonce+1 < nonce {
+		// The caller nonce cannot be incremented (EIP-2681), the creation is aborted before
+		// anything is modified and the gas it was given is returned to the caller
+		return nil, common.Address{}, gas, ErrNonceUintOverflow
+	}
+	evm.StateDB.SetNonce(caller.Address(), nonce+1, evm.FirehoseContext)
 	// We add this to the access list _before_ taking a snapshot. Even if the creation fails,
 	// the access-list change should not be rolled back
 	if evm.chainRules.IsBerlin {
@@ -625,45 +484,30 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	// Ensure there's no existing contract already at the designated address
 	contractHash := evm.StateDB.GetCodeHash(address)
 	if evm.StateDB.GetNonce(address) != 0 || (contractHash != (common.Hash{}) && contractHash != emptyCodeHash) {
-		if evm.firehoseContext.Enabled() {
-			// In the case of a contract collision, the gas is fully consume since the retured gas value in the
-			// return a little below is 0. This means we are facing not a revertion like other early failure
-			// reasons we usually see but with an actual assertion failure which burns the remaining gas that
-			// was allowed to the creation. Hence why we have an `EndFailedCall` and using `false` to show
-			// the call is **not** reverted.
-			evm.firehoseContext.EndFailedCall(gas, false, ErrContractAddressCollision.Error())
-		}
-
-		return nil, common.Address{}, 0, ErrContractAddressCollision
+		// The caller nonce increment is kept and, unlike the checks above, all the gas given
+		// to the creation is burned
+		return nil, common.Address{}, gas - evm.burnGas(gas), ErrContractAddressCollision
 	}
 
 	// Create a new account on the state
 	snapshot := evm.StateDB.Snapshot()
-	evm.StateDB.CreateAccount(address, evm.firehoseContext)
+	evm.StateDB.CreateAccount(address, evm.FirehoseContext)
+	evm.StateDB.CreateContract(address)
 	if evm.chainRules.IsEIP158 {
-		evm.StateDB.SetNonce(address, 1, evm.firehoseContext)
+		evm.StateDB.SetNonce(address, 1, evm.FirehoseContext)
 	}
-	evm.Context.Transfer(evm.StateDB, caller.Address(), address, value, evm.firehoseContext)
+	evm.Context.Transfer(evm.StateDB, caller.Address(), address, value, evm.FirehoseContext)
 
 	// Initialise a new contract and set the code that is to be used by the EVM.
 	// The contract is a scoped environment for this execution context only.
-	contract := NewContract(caller, AccountRef(address), value, gas, evm.firehoseContext)
+	contract := NewContract(caller, AccountRef(address), value, gas, evm.FirehoseContext)
 	contract.SetCodeOptionalHash(&address, codeAndHash)
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
-		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
-		}
-
 		return nil, address, gas, nil
 	}
 
-	if evm.vmConfig.Debug && evm.depth == 0 {
-		evm.vmConfig.Tracer.CaptureStart(caller.Address(), address, true, codeAndHash.code, gas, value)
-	}
-	start := time.Now()
-
-	ret, err := run(evm, contract, nil, false)
+	ret, err = run(evm, contract, nil, false)
 
 	// check whether the max code size has been exceeded
 	maxCodeSizeExceeded := evm.chainRules.IsEIP158 && len(ret) > params.MaxCodeSize
@@ -674,8 +518,8 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	if err == nil && !maxCodeSizeExceeded {
 		createDataGas := uint64(len(ret)) * params.CreateDataGas
 
-		if contract.UseGas(createDataGas, firehose.GasChangeReason("code_storage")) {
-			evm.StateDB.SetCode(address, ret, evm.firehoseContext)
+		if contract.UseGas(createDataGas, firehose.CodeStorageGasChangeReason) {
+			evm.StateDB.SetCode(address, ret, evm.FirehoseContext)
 		} else {
 			err = ErrCodeStoreOutOfGas
 		}
@@ -686,42 +530,21 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	// when we're in homestead this also counts for code storage gas errors.
 	if maxCodeSizeExceeded || (err != nil && (evm.chainRules.IsHomestead || err != ErrCodeStoreOutOfGas)) {
 		evm.StateDB.RevertToSnapshot(snapshot)
-
-		if evm.firehoseContext.Enabled() {
-			if err != nil {
-				evm.firehoseContext.RecordCallFailed(contract.Gas, err.Error())
-			} else {
-				evm.firehoseContext.RecordCallFailed(contract.Gas, ErrMaxCodeSizeExceeded.Error())
-			}
-		}
-
 		if err != ErrExecutionReverted {
-			contract.UseGas(contract.Gas, firehose.FailedExecutionGasChangeReason)
-		} else {
-			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
-			}
+			contract.UseGas(evm.burnGas(contract.Gas), firehose.IgnoredGasChangeReason)
 		}
 	}
 	// Assign err if contract code size exceeds the max while the err is still empty.
 	if maxCodeSizeExceeded && err == nil {
 		err = ErrMaxCodeSizeExceeded
 	}
-	if evm.vmConfig.Debug && evm.depth == 0 {
-		evm.vmConfig.Tracer.CaptureEnd(ret, gas-contract.Gas, time.Since(start), err)
-	}
-
-	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.EndCall(contract.Gas, nil)
-	}
-
 	return ret, address, contract.Gas, err
 }
 
 // Create creates a new contract using code as deployment code.
 func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
 	contractAddr = crypto.CreateAddress(caller.Address(), evm.StateDB.GetNonce(caller.Address()))
-	return evm.create(caller, &codeAndHash{code: code}, gas, value, contractAddr)
+	return evm.create(caller, &codeAndHash{code: code}, gas, value, contractAddr, CREATE)
 }
 
 // Create2 creates a new contract using code as deployment code.
@@ -731,8 +554,23 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, endowment *big.Int, salt *uint256.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
 	codeAndHash := &codeAndHash{code: code}
 	contractAddr = crypto.CreateAddress2(caller.Address(), salt.Bytes32(), codeAndHash.Hash().Bytes())
-	return evm.create(caller, codeAndHash, gas, endowment, contractAddr)
+	return evm.create(caller, codeAndHash, gas, endowment, contractAddr, CREATE2)
+}
+
+// resolveCode returns the code executed when calling `addr` along with its hash, following
+// the EIP-7702 delegation of `addr` once Prague is active.
+func (evm *EVM) resolveCode(addr common.Address) ([]byte, common.Hash) {
+	code := evm.StateDB.GetCode(addr)
+	if evm.chainRules.IsPrague {
+		if target, ok := types.ParseDelegation(code); ok {
+			return evm.StateDB.GetCode(target), evm.StateDB.GetCodeHash(target)
+		}
+	}
+	return code, evm.StateDB.GetCodeHash(addr)
 }
 
 // ChainConfig returns the environment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
+
+// ChainRules returns the rules of the chain configuration at the block being executed.
+func (evm *EVM) ChainRules() params.Rules { return evm.chainRules }
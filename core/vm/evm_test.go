@@ -0,0 +1,231 @@
+package vm
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestEVM returns an EVM at block 1 over the given state, all transfers are allowed
+// and none of them moves any balance.
+func newTestEVM(txCtx TxContext, statedb StateDB, chainConfig *params.ChainConfig, config Config) *EVM {
+	blockCtx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+		BlockNumber: big.NewInt(1),
+	}
+	return NewEVM(blockCtx, txCtx, statedb, chainConfig, config)
+}
+
+// startTestTx returns a speculative execution context recording a transaction to the
+// given address, nil for a creation, with a 100000 gas limit.
+func startTestTx(to *common.Address) *firehose.Context {
+	ctx := firehose.NewSpeculativeExecutionContext(1024)
+	ctx.StartTransactionRaw(common.Hash{}, to, common.Big0, nil, nil, nil, 100000, common.Big1, 0, nil, nil, nil, nil, 0, 0)
+	return ctx
+}
+
+func TestEVM_ResetFirehoseContext(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller   = common.HexToAddress("0x01")
+		contract = common.HexToAddress("0x0c0de")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(contract, []byte{byte(PUSH1), 0x20, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(CALLDATACOPY)}, nil)
+
+	// The EVM is reused across transactions, each recorded in its own context
+	first, second := startTestTx(&contract), startTestTx(&contract)
+	evm := newTestEVM(TxContext{FirehoseContext: first}, statedb, params.AllEthashProtocolChanges, Config{})
+	if _, _, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+	firstLog := append([]byte(nil), first.FirehoseLog()...)
+
+	evm.Reset(TxContext{FirehoseContext: second}, statedb)
+	if evm.FirehoseContext != second {
+		t.Fatalf("firehose context not reset")
+	}
+	if _, _, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("second call failed: %v", err)
+	}
+
+	line := []byte(" call_data_copy ")
+	if !bytes.Contains(firstLog, line) {
+		t.Errorf("first context did not record the first call:\n%s", firstLog)
+	}
+	if !bytes.Contains(second.FirehoseLog(), line) {
+		t.Errorf("second context did not record the second call:\n%s", second.FirehoseLog())
+	}
+	if !bytes.Equal(first.FirehoseLog(), firstLog) {
+		t.Errorf("first context recorded the second call:\n%s", first.FirehoseLog())
+	}
+
+	// A zero transaction context records nothing
+	evm.Reset(TxContext{}, statedb)
+	if _, _, err := evm.Call(AccountRef(caller), contract, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("unrecorded call failed: %v", err)
+	}
+}
+
+func TestEVM_StaticCallAccountTouch(t *testing.T) {
+	defer func(enabled, touches bool) {
+		firehose.Enabled, firehose.AccountTouchesEnabled = enabled, touches
+	}(firehose.Enabled, firehose.AccountTouchesEnabled)
+	firehose.Enabled = true
+
+	var (
+		caller = common.HexToAddress("0x01")
+		empty  = common.HexToAddress("0xe0")
+	)
+
+	staticCall := func() []byte {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		firehoseContext := startTestTx(&empty)
+
+		evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, params.AllEthashProtocolChanges, Config{})
+		if _, _, err := evm.StaticCall(AccountRef(caller), empty, nil, 100000); err != nil {
+			t.Fatalf("static call failed: %v", err)
+		}
+		return firehoseContext.FirehoseLog()
+	}
+
+	// The touch is never recorded as a balance change, and as a touch only when enabled
+	firehose.AccountTouchesEnabled = false
+	if log := staticCall(); bytes.Contains(log, []byte("BALANCE_CHANGE")) || bytes.Contains(log, []byte("ACCOUNT_TOUCH")) {
+		t.Errorf("touch recorded while disabled:\n%s", log)
+	}
+
+	firehose.AccountTouchesEnabled = true
+	log := staticCall()
+	if bytes.Contains(log, []byte("BALANCE_CHANGE")) {
+		t.Errorf("touch recorded as a balance change:\n%s", log)
+	}
+	if line := "FIRE ACCOUNT_TOUCH 1 00000000000000000000000000000000000000e0 "; !bytes.Contains(log, []byte(line)) {
+		t.Errorf("firehose did not record %q:\n%s", line, log)
+	}
+}
+
+func TestEVM_CallParamsDepthAndReadOnly(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller = common.HexToAddress("0x01")
+		outer  = common.HexToAddress("0x0a")
+		inner  = common.HexToAddress("0x0b")
+	)
+
+	// The outer contract calls the inner one, without value, which is allowed in a static frame
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(outer, append([]byte{
+		byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00,
+		byte(PUSH20)}, append(inner.Bytes(), byte(PUSH2), 0xff, 0xff, byte(CALL))...), nil)
+
+	firehoseContext := startTestTx(&outer)
+
+	evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, params.AllEthashProtocolChanges, Config{})
+	if _, _, err := evm.StaticCall(AccountRef(caller), outer, nil, 100000); err != nil {
+		t.Fatalf("static call failed: %v", err)
+	}
+
+	for _, line := range []string{
+		"FIRE EVM_PARAM STATIC 1 0000000000000000000000000000000000000001 000000000000000000000000000000000000000a . 100000 . . 0 true\n",
+		"FIRE EVM_PARAM CALL 2 000000000000000000000000000000000000000a 000000000000000000000000000000000000000b . 65535 . . 1 true\n",
+	} {
+		if !bytes.Contains(firehoseContext.FirehoseLog(), []byte(line)) {
+			t.Errorf("firehose did not record %q:\n%s", line, firehoseContext.FirehoseLog())
+		}
+	}
+}
+
+func TestEVM_CreateFailures(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller   = common.HexToAddress("0x01")
+		overflow = common.HexToAddress("0x02")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetNonce(overflow, math.MaxUint64, nil)
+
+	// The contract address of the caller first creation already holds code
+	statedb.SetCode(crypto.CreateAddress(caller, 0), []byte{byte(STOP)}, nil)
+
+	tests := []struct {
+		name    string
+		caller  common.Address
+		err     error
+		gasLeft uint64
+		line    string
+	}{
+		{"collision", caller, ErrContractAddressCollision, 0, "FIRE EVM_CREATE_FAILED 1 contract_address_collision burned 100000\n"},
+		{"nonce overflow", overflow, ErrNonceUintOverflow, 100000, "FIRE EVM_CREATE_FAILED 1 nonce_overflow returned 100000\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			firehoseContext := startTestTx(nil)
+
+			evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, params.AllEthashProtocolChanges, Config{})
+			_, _, gasLeft, err := evm.Create(AccountRef(test.caller), []byte{byte(STOP)}, 100000, common.Big0)
+			if err != test.err {
+				t.Fatalf("error mismatch: have %v, want %v", err, test.err)
+			}
+			if gasLeft != test.gasLeft {
+				t.Errorf("gas left mismatch: have %d, want %d", gasLeft, test.gasLeft)
+			}
+			if !bytes.Contains(firehoseContext.FirehoseLog(), []byte(test.line)) {
+				t.Errorf("firehose did not record %q:\n%s", test.line, firehoseContext.FirehoseLog())
+			}
+		})
+	}
+}
+
+func TestEVM_CallGasRetention(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller = common.HexToAddress("0x01")
+		outer  = common.HexToAddress("0x0a")
+		inner  = common.HexToAddress("0x0b")
+	)
+
+	// The outer contract calls the inner one requesting more gas than it has, the call is
+	// given all but one 64th of the gas available once the cost of the call paid (21 gas for
+	// the pushes, 2600 for the cold account access)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(outer, append([]byte{
+		byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00,
+		byte(PUSH20)}, append(inner.Bytes(), byte(PUSH4), 0xff, 0xff, 0xff, 0xff, byte(CALL))...), nil)
+
+	firehoseContext := startTestTx(&outer)
+
+	evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, params.AllEthashProtocolChanges, Config{})
+	if _, _, err := evm.Call(AccountRef(caller), outer, nil, 100000, common.Big0); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	for _, line := range []string{
+		"FIRE EVM_END_CALL 2 95858 . 6 95858 0 1521\n",
+		"FIRE EVM_END_CALL 1 97379 . 8 100000 2621 0\n",
+	} {
+		if !bytes.Contains(firehoseContext.FirehoseLog(), []byte(line)) {
+			t.Errorf("firehose did not record %q:\n%s", line, firehoseContext.FirehoseLog())
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package vm
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/holiman/uint256"
+)
+
+// CallFrameLogger is notified of the entry and exit of every call frame, the outermost one
+// included, unlike a `Tracer` that only sees the outermost frame through `CaptureStart` and
+// `CaptureEnd`. The hooks have the signatures of the upstream `EVMLogger` ones.
+type CallFrameLogger interface {
+	CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int)
+
+	// CaptureExit is called with the gas used by the frame, including any remaining gas
+	// burned because of an error other than a revert, see `GasBurnLogger`.
+	CaptureExit(output []byte, gasUsed uint64, err error)
+}
+
+// GasBurnLogger is implemented by the loggers accounting for the gas burned when a frame
+// fails with an error other than a revert. `CaptureGasBurn` is called with the gas left by
+// the frame right before it's burned, the frame's `CaptureExit` following it.
+type GasBurnLogger interface {
+	CaptureGasBurn(gasLeft uint64)
+}
+
+// EVMLogger is the full set of hooks driving an EVM logger, the `Tracer` ones for the
+// execution steps plus the `CallFrameLogger` ones for call frames.
+//
+// Contrary to a `Tracer` configured through `Config.Tracer`, `CaptureStart` and
+// `CaptureEnd` are never called since the outermost frame is received through
+// `CaptureEnter` and `CaptureExit`. `CaptureState` is called once the gas of the step has
+// been consumed and before it's executed, the gas received excludes any gas consumed
+// directly while computing the dynamic cost, like cold state access, which is recorded
+// on its own. `CaptureFault` is called with the step that ended a frame with an error.
+type EVMLogger interface {
+	Tracer
+	CallFrameLogger
+}
+
+// firehoseLogger is the `EVMLogger` recording the execution into the Firehose context of
+// the EVM, state changes are recorded through the Firehose context given to the `StateDB`
+// methods directly.
+type firehoseLogger struct {
+	evm *EVM
+
+	// The frames currently entered, the outermost one first
+	frames []firehoseFrame
+}
+
+// firehoseFrame is a call frame entered by the EVM, its caller and value being the ones
+// inherited by the delegate calls it performs.
+type firehoseFrame struct {
+	caller    common.Address
+	value     *big.Int
+	create    bool
+	gas       uint64
+	gasBurned bool
+	gasLeft   uint64
+}
+
+// newEVMLogger returns the logger fed by the execution of `evm`, the Firehose one when
+// the instrumentation is enabled multiplexed with the configured `Config.Loggers` and the
+// `Config.Tracer` in debug mode, along with the tracers fed by the steps of the execution,
+// see `NewTracerLogger`.
+func newEVMLogger(evm *EVM) (EVMLogger, []Tracer) {
+	var loggers []EVMLogger
+	if evm.FirehoseContext.Enabled() {
+		loggers = append(loggers, &firehoseLogger{evm: evm})
+	}
+	loggers = append(loggers, evm.vmConfig.Loggers...)
+	if evm.vmConfig.Debug && evm.vmConfig.Tracer != nil {
+		loggers = append(loggers, NewTracerLogger(evm.vmConfig.Tracer))
+	}
+
+	var tracers []Tracer
+	for _, logger := range loggers {
+		if tracer, ok := logger.(*tracerLogger); ok {
+			tracers = append(tracers, tracer.tracer)
+		}
+	}
+
+	return NewMuxLogger(loggers...), tracers
+}
+
+// burnGas returns the gas burned by a frame failing with an error other than a revert, all
+// the `gasLeft` remaining gas, notifying the loggers accounting for it, see `GasBurnLogger`.
+func (evm *EVM) burnGas(gasLeft uint64) uint64 {
+	if logger, ok := evm.logger.(GasBurnLogger); ok {
+		logger.CaptureGasBurn(gasLeft)
+	}
+
+	return gasLeft
+}
+
+var firehoseCallTypes = map[OpCode]firehose.CallType{
+	CALL:         firehose.CallTypeCall,
+	CALLCODE:     firehose.CallTypeCallCode,
+	DELEGATECALL: firehose.CallTypeDelegate,
+	STATICCALL:   firehose.CallTypeStatic,
+	CREATE:       firehose.CallTypeCreate,
+	CREATE2:      firehose.CallTypeCreate,
+}
+
+func (l *firehoseLogger) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	ctx := l.evm.FirehoseContext
+	callType := firehoseCallTypes[typ]
+
+	precompile, codeHash, hasCode := l.evm.firehoseCallCode(typ, to, input)
+	ctx.StartCall(callType, l.evm.firehoseOpCode(typ), precompile, codeHash, hasCode)
+
+	// The call is entered before the interpreter runs it, a static call is thus not yet
+	// flagged as read-only by the interpreter, only its nested calls are
+	depth, readOnly := uint64(l.evm.depth), typ == STATICCALL || l.evm.readOnly()
+
+	frame := firehoseFrame{caller: from, value: value, create: typ == CREATE || typ == CREATE2, gas: gas}
+	switch typ {
+	case DELEGATECALL:
+		// Firehose a Delegate Call is quite different then a standard Call or event Call Code
+		// because it executes using the state of the parent call. Assumuming a contract that
+		// receives a method `execute`, let's say this contract is A. When in the `execute`
+		// method a `delegatecall` is performed to contract B, the net effect is that code of
+		// B is loaded and executed against the current state and value of contract A. As such,
+		// the logical caller is the one that called contract A.
+		//
+		// The caller and value fields are kept as contract A and the value sent to it for
+		// compatibility, the value being inherited by the delegate call. The logical caller
+		// (the caller of contract A) is recorded as the extra delegate caller field so that
+		// consumers can distinguish the proxy (contract A) from the logical caller.
+		frame.value = new(big.Int)
+		if len(l.frames) > 0 {
+			parent := l.frames[len(l.frames)-1]
+			frame.caller, frame.value = parent.caller, parent.value
+		}
+
+		ctx.RecordDelegateCallParams(from, frame.caller, to, frame.value, gas, input, depth, readOnly)
+
+	case CREATE, CREATE2:
+		// The init code is recorded as the input of the call only when enabled, see
+		// `firehose.CreationCodeEnabled`.
+		ctx.RecordCallParams(callType, from, to, value, gas, input, depth, readOnly)
+
+	default:
+		if value == nil {
+			frame.value = firehose.EmptyValue
+		}
+
+		ctx.RecordCallParams(callType, from, to, frame.value, gas, input, depth, readOnly)
+	}
+	l.frames = append(l.frames, frame)
+
+	if precompile {
+		ctx.RecordPrecompiledCall(PrecompiledContractName(to))
+	}
+}
+
+// CaptureGasBurn records the gas left by the active frame before it's burned, it's
+// accounted for once the frame exits.
+func (l *firehoseLogger) CaptureGasBurn(gasLeft uint64) {
+	if len(l.frames) > 0 {
+		frame := &l.frames[len(l.frames)-1]
+		frame.gasBurned, frame.gasLeft = true, gasLeft
+	}
+}
+
+func (l *firehoseLogger) CaptureExit(output []byte, gasUsed uint64, err error) {
+	ctx := l.evm.FirehoseContext
+
+	var frame firehoseFrame
+	if len(l.frames) > 0 {
+		frame, l.frames = l.frames[len(l.frames)-1], l.frames[:len(l.frames)-1]
+	}
+
+	gasLeft := frame.gas - gasUsed
+	if frame.gasBurned {
+		gasLeft = frame.gasLeft
+	}
+	if frame.create && err != nil {
+		// The output of a creation is the runtime code it stored, none when it failed
+		output = nil
+	}
+
+	switch {
+	case err == nil:
+		ctx.EndCall(gasLeft, output)
+
+	case err == ErrExecutionReverted || err == ErrDepth || err == ErrInsufficientBalance:
+		// An explicit revert or a failure occurring before the execution started (depth and
+		// balance checks) gives the remaining gas back to the caller.
+		ctx.RecordCallFailed(gasLeft, ErrorToCallFailureCode(err), err.Error())
+		ctx.RecordCallReverted()
+		ctx.EndCall(gasLeft, output)
+
+	case err == ErrNonceUintOverflow:
+		// The creation is aborted before the caller nonce is incremented, the gas given to it
+		// is returned to the caller like for a revert.
+		ctx.RecordCallFailed(gasLeft, firehose.NonceOverflowCallFailureCode, err.Error())
+		ctx.RecordCreateFailed(firehose.NonceOverflowCallFailureCode, firehose.ReturnedGasDisposition, gasLeft)
+		ctx.RecordCallReverted()
+		ctx.EndCall(gasLeft, output)
+
+	case err == ErrContractAddressCollision:
+		// The creation is aborted once the caller nonce is incremented, the gas given to it is
+		// burned without any code being run.
+		ctx.RecordCallFailed(gasLeft, firehose.ContractAddressCollisionCallFailureCode, err.Error())
+		ctx.RecordCreateFailed(firehose.ContractAddressCollisionCallFailureCode, firehose.BurnedGasDisposition, gasLeft)
+		ctx.RecordGasConsume(gasLeft, gasLeft, firehose.FailedExecutionGasChangeReason)
+		ctx.EndCall(0, nil)
+
+	case !frame.gasBurned:
+		// The runtime code of a creation that could not be stored before homestead is not
+		// stored, the creation succeeding with the gas left
+		ctx.EndCall(gasLeft, nil)
+
+	default:
+		// Any other failure is an assertion failure burning all the gas that was allowed to
+		// the call.
+		ctx.RecordCallFailed(gasLeft, ErrorToCallFailureCode(err), err.Error())
+		ctx.RecordGasConsume(gasLeft, gasLeft, firehose.FailedExecutionGasChangeReason)
+		ctx.EndCall(0, output)
+	}
+}
+
+func (l *firehoseLogger) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState records the gas consumed by the operation, `gas` being the gas available
+// before the operation and `cost` being its constant and dynamic gas costs aggregated.
+func (l *firehoseLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rData []byte, contract *Contract, depth int, err error) error {
+	if cost != 0 {
+		if reason := OpCodeToGasChangeReason(op); reason != firehose.IgnoredGasChangeReason {
+			env.FirehoseContext.RecordGasConsume(gas, cost, reason)
+		}
+	}
+
+	return nil
+}
+
+func (l *firehoseLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (l *firehoseLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
+
+// firehoseOpCode returns the name of the opcode `op` that triggered the call being recorded
+// by Firehose, empty for the root call of a transaction since no opcode triggered it.
+func (evm *EVM) firehoseOpCode(op OpCode) string {
+	if evm.depth == 0 {
+		return ""
+	}
+
+	return op.String()
+}
+
+// firehoseCallCode returns whether the call entered with `op` targets a precompiled contract
+// and the hash of the code it executes, if any: the init code of a contract creation, the
+// code of `to`, or of the account it delegates to, otherwise.
+func (evm *EVM) firehoseCallCode(op OpCode, to common.Address, input []byte) (precompile bool, codeHash common.Hash, hasCode bool) {
+	if op == CREATE || op == CREATE2 {
+		return false, crypto.Keccak256Hash(input), len(input) > 0
+	}
+
+	if _, precompile = evm.precompile(to); precompile {
+		return true, common.Hash{}, false
+	}
+
+	code, codeHash := evm.resolveCode(to)
+	return false, codeHash, len(code) > 0
+}
+
+// firehoseCallGasRetention returns the gas retained by the caller at the site of a call
+// requesting `requested` gas out of the `available` gas of the caller, once the cost of the
+// call itself paid, when only `allowed` gas was given to the call because of the EIP-150
+// "all but one 64th" rule. It's 0 when the requested gas was allowed as-is.
+func firehoseCallGasRetention(requested *uint256.Int, available, allowed uint64) uint64 {
+	if requested.IsUint64() && requested.Uint64() <= allowed {
+		return 0
+	}
+
+	return available - allowed
+}
+
+// readOnly returns true when the EVM currently executes under STATICCALL restrictions.
+func (evm *EVM) readOnly() bool {
+	interpreter, ok := evm.interpreter.(*EVMInterpreter)
+	return ok && interpreter.readOnly
+}
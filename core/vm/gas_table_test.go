@@ -19,12 +19,14 @@ package vm
 import (
 	"math"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -86,13 +88,13 @@ func TestEIP2200(t *testing.T) {
 		statedb.CreateAccount(address, firehose.NoOpContext)
 		statedb.SetCode(address, hexutil.MustDecode(tt.input), firehose.NoOpContext)
 		statedb.SetState(address, common.Hash{}, common.BytesToHash([]byte{tt.original}), firehose.NoOpContext)
-		statedb.Finalise(true) // Push the state into the "original" slot
+		statedb.Finalise(true, nil) // Push the state into the "original" slot
 
 		vmctx := BlockContext{
 			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
 			Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
 		}
-		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{ExtraEips: []int{2200}}, firehose.NoOpContext)
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{ExtraEips: []int{2200}})
 
 		_, gas, err := vmenv.Call(AccountRef(common.Address{}), address, nil, tt.gaspool, new(big.Int))
 		if err != tt.failure {
@@ -106,3 +108,87 @@ func TestEIP2200(t *testing.T) {
 		}
 	}
 }
+
+func TestEIP2929AccessRecording(t *testing.T) {
+	address := common.BytesToAddress([]byte("contract"))
+	other := common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.CreateAccount(address, firehose.NoOpContext)
+	// SLOAD(1), BALANCE(other), SLOAD(1) again, SSTORE(3, 2), STOP
+	statedb.SetCode(address, hexutil.MustDecode("0x60015450"+"73"+other.Hex()[2:]+"3150"+"60015450"+"6002600355"+"00"), firehose.NoOpContext)
+	statedb.PrepareAccessList(common.Address{}, &address, nil, nil)
+
+	firehoseContext := firehose.NewSpeculativeExecutionContext(0)
+	firehoseContext.EnableAccessRecording()
+
+	config := *params.AllEthashProtocolChanges
+	config.BerlinBlock = new(big.Int)
+	vmenv := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, &config, Config{})
+
+	if _, _, err := vmenv.Call(AccountRef(common.Address{}), address, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	want := types.AccessList{
+		{Address: address, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(3))}},
+		{Address: other, StorageKeys: []common.Hash{}},
+	}
+	if have := firehoseContext.AccessList(); !reflect.DeepEqual(have, want) {
+		t.Errorf("access list mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestCreateGasEIP3860(t *testing.T) {
+	for i, tt := range []struct {
+		size     uint64
+		shanghai bool
+		failure  error
+	}{
+		{size: params.MaxInitCodeSize, shanghai: false},
+		{size: params.MaxInitCodeSize, shanghai: true},
+		{size: params.MaxInitCodeSize + 1, shanghai: false},
+		{size: params.MaxInitCodeSize + 1, shanghai: true, failure: ErrOutOfGas},
+	} {
+		for _, op := range []OpCode{CREATE, CREATE2} {
+			address := common.BytesToAddress([]byte("contract"))
+
+			// CREATE(0, 0, size) or CREATE2(0, 0, size, 0) of zero bytes init code
+			code, pushes := []byte{byte(PUSH2), byte(tt.size >> 8), byte(tt.size), byte(PUSH1), 0, byte(PUSH1), 0}, uint64(3)
+			if op == CREATE2 {
+				code, pushes = append([]byte{byte(PUSH1), 0}, code...), pushes+1
+			}
+			code = append(code, byte(op), byte(STOP))
+
+			statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+			statedb.CreateAccount(address, firehose.NoOpContext)
+			statedb.SetCode(address, code, firehose.NoOpContext)
+
+			config := *params.AllEthashProtocolChanges
+			if tt.shanghai {
+				config.ShanghaiBlock = new(big.Int)
+			}
+			vmenv := newTestEVM(TxContext{}, statedb, &config, Config{})
+
+			_, gas, err := vmenv.Call(AccountRef(common.Address{}), address, nil, 1000000, new(big.Int))
+			if err != tt.failure {
+				t.Fatalf("test %d, %v: failure mismatch: have %v, want %v", i, op, err, tt.failure)
+			}
+			if err != nil {
+				continue
+			}
+			// Pushes, memory expansion, creation and hashing costs are the same on both forks
+			words := toWordSize(tt.size)
+			want := pushes*GasFastestStep + params.CreateGas + words*words/params.QuadCoeffDiv + words*params.MemoryGas
+			if op == CREATE2 {
+				want += words * params.Sha3WordGas
+			}
+			if tt.shanghai {
+				want += words * params.InitCodeWordGas
+			}
+			if used := 1000000 - gas; used != want {
+				t.Errorf("test %d, %v: gas used mismatch: have %d, want %d", i, op, used, want)
+			}
+		}
+	}
+}
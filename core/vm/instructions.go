@@ -249,8 +249,8 @@ func opSha3(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]by
 		evm.StateDB.AddPreimage(interpreter.hasherBuf, data)
 	}
 
-	if interpreter.evm.firehoseContext.Enabled() {
-		interpreter.evm.firehoseContext.RecordKeccak(interpreter.hasherBuf, data)
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordKeccak(interpreter.hasherBuf, data)
 	}
 
 	size.SetBytes(interpreter.hasherBuf[:])
@@ -522,7 +522,7 @@ func opSstore(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]
 	loc := callContext.stack.pop()
 	val := callContext.stack.pop()
 	interpreter.evm.StateDB.SetState(callContext.contract.Address(),
-		loc.Bytes32(), val.Bytes32(), interpreter.evm.firehoseContext)
+		loc.Bytes32(), val.Bytes32(), interpreter.evm.FirehoseContext)
 	return nil, nil
 }
 
@@ -580,7 +580,11 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]
 	// reuse size int for stackvalue
 	stackvalue := size
 
-	callContext.contract.UseGas(gas, firehose.GasChangeReason("contract_creation"))
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordCallGasRetention(callContext.contract.Gas - gas)
+	}
+
+	callContext.contract.UseGas(gas, firehose.ContractCreationGasChangeReason)
 	//TODO: use uint256.Int instead of converting with toBig()
 	var bigVal = big0
 	if !value.IsZero() {
@@ -600,8 +604,8 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]
 		stackvalue.SetBytes(addr.Bytes())
 	}
 
-	if interpreter.evm.firehoseContext.Enabled() {
-		interpreter.evm.firehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
 	}
 
 	callContext.stack.push(&stackvalue)
@@ -624,7 +628,11 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([
 
 	// Apply EIP150
 	gas -= gas / 64
-	callContext.contract.UseGas(gas, firehose.GasChangeReason("contract_creation2"))
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordCallGasRetention(callContext.contract.Gas - gas)
+	}
+
+	callContext.contract.UseGas(gas, firehose.ContractCreation2GasChangeReason)
 	// reuse size int for stackvalue
 	stackvalue := size
 	//TODO: use uint256.Int instead of converting with toBig()
@@ -641,8 +649,8 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([
 		stackvalue.SetBytes(addr.Bytes())
 	}
 
-	if interpreter.evm.firehoseContext.Enabled() {
-		interpreter.evm.firehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
 	}
 
 	callContext.stack.push(&stackvalue)
@@ -675,6 +683,10 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]by
 		bigVal = value.ToBig()
 	}
 
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordCallGasRetention(firehoseCallGasRetention(&temp, callContext.contract.Gas+interpreter.evm.callGasTemp, interpreter.evm.callGasTemp))
+	}
+
 	ret, returnGas, err := interpreter.evm.Call(callContext.contract, toAddr, args, gas, bigVal)
 
 	if err != nil {
@@ -687,8 +699,8 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]by
 		callContext.memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 
-	if interpreter.evm.firehoseContext.Enabled() {
-		interpreter.evm.firehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
 	}
 
 	callContext.contract.Gas += returnGas
@@ -714,6 +726,10 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) (
 		bigVal = value.ToBig()
 	}
 
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordCallGasRetention(firehoseCallGasRetention(&temp, callContext.contract.Gas+interpreter.evm.callGasTemp, interpreter.evm.callGasTemp))
+	}
+
 	ret, returnGas, err := interpreter.evm.CallCode(callContext.contract, toAddr, args, gas, bigVal)
 	if err != nil {
 		temp.Clear()
@@ -725,8 +741,8 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) (
 		callContext.memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 
-	if interpreter.evm.firehoseContext.Enabled() {
-		interpreter.evm.firehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
 	}
 
 	callContext.contract.Gas += returnGas
@@ -745,6 +761,10 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCt
 	// Get arguments from the memory.
 	args := callContext.memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordCallGasRetention(firehoseCallGasRetention(&temp, callContext.contract.Gas+interpreter.evm.callGasTemp, interpreter.evm.callGasTemp))
+	}
+
 	ret, returnGas, err := interpreter.evm.DelegateCall(callContext.contract, toAddr, args, gas)
 	if err != nil {
 		temp.Clear()
@@ -756,8 +776,8 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCt
 		callContext.memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 
-	if interpreter.evm.firehoseContext.Enabled() {
-		interpreter.evm.firehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
 	}
 
 	callContext.contract.Gas += returnGas
@@ -776,6 +796,10 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx)
 	// Get arguments from the memory.
 	args := callContext.memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordCallGasRetention(firehoseCallGasRetention(&temp, callContext.contract.Gas+interpreter.evm.callGasTemp, interpreter.evm.callGasTemp))
+	}
+
 	ret, returnGas, err := interpreter.evm.StaticCall(callContext.contract, toAddr, args, gas)
 	if err != nil {
 		temp.Clear()
@@ -787,8 +811,8 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx)
 		callContext.memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 
-	if interpreter.evm.firehoseContext.Enabled() {
-		interpreter.evm.firehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
+	if interpreter.evm.FirehoseContext.Enabled() {
+		interpreter.evm.FirehoseContext.RecordGasRefund(callContext.contract.Gas, returnGas)
 	}
 
 	callContext.contract.Gas += returnGas
@@ -816,8 +840,8 @@ func opStop(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]by
 func opSuicide(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
 	beneficiary := callContext.stack.pop()
 	balance := interpreter.evm.StateDB.GetBalance(callContext.contract.Address())
-	interpreter.evm.StateDB.AddBalance(beneficiary.Bytes20(), balance, false, interpreter.evm.firehoseContext, firehose.BalanceChangeReason("suicide_refund"))
-	interpreter.evm.StateDB.Suicide(callContext.contract.Address(), interpreter.evm.firehoseContext)
+	interpreter.evm.StateDB.AddBalance(beneficiary.Bytes20(), balance, false, interpreter.evm.FirehoseContext, firehose.SuicideRefundBalanceChangeReason)
+	interpreter.evm.StateDB.Suicide(callContext.contract.Address(), interpreter.evm.FirehoseContext)
 	return nil, nil
 }
 
@@ -842,7 +866,7 @@ func makeLog(size int) executionFunc {
 			// This is a non-consensus field, but assigned here because
 			// core/state doesn't know the current block number.
 			BlockNumber: interpreter.evm.Context.BlockNumber.Uint64(),
-		}, interpreter.evm.firehoseContext)
+		}, interpreter.evm.FirehoseContext)
 
 		return nil, nil
 	}
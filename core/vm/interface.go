@@ -31,6 +31,7 @@ type StateDB interface {
 	SubBalance(common.Address, *big.Int, *firehose.Context, firehose.BalanceChangeReason)
 	AddBalance(common.Address, *big.Int, bool, *firehose.Context, firehose.BalanceChangeReason)
 	GetBalance(common.Address) *big.Int
+	Touch(common.Address, bool, *firehose.Context)
 
 	GetNonce(common.Address) uint64
 	SetNonce(common.Address, uint64, *firehose.Context)
@@ -48,9 +49,15 @@ type StateDB interface {
 	GetState(common.Address, common.Hash) common.Hash
 	SetState(common.Address, common.Hash, common.Hash, *firehose.Context)
 
+	GetTransientState(addr common.Address, key common.Hash) common.Hash
+	SetTransientState(addr common.Address, key, value common.Hash, firehoseContext *firehose.Context)
+
 	Suicide(common.Address, *firehose.Context) bool
 	HasSuicided(common.Address) bool
 
+	CreateContract(common.Address)
+	Suicide6780(common.Address, *firehose.Context) bool
+
 	// Exist reports whether the given account exists in state.
 	// Notably this should also return true for suicided accounts.
 	Exist(common.Address) bool
@@ -39,6 +39,14 @@ type Config struct {
 	EVMInterpreter   string // External EVM interpreter options
 
 	ExtraEips []int // Additional EIPS that are to be enabled
+
+	// Loggers are fed by the same execution as the Firehose instrumentation, see `EVMLogger`,
+	// a `Tracer` being adapted through `NewTracerLogger`
+	Loggers []EVMLogger
+
+	// RulesCache, when set, provides the chain rules and the precompiled contracts of the
+	// EVMs created for its chain config instead of deriving them for each EVM
+	RulesCache *RulesCache
 }
 
 // Interpreter is used to run Ethereum based contracts and will utilise the
@@ -99,6 +107,10 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	if cfg.JumpTable[STOP] == nil {
 		var jt JumpTable
 		switch {
+		case evm.chainRules.IsCancun:
+			jt = cancunInstructionSet
+		case evm.chainRules.IsShanghai:
+			jt = shanghaiInstructionSet
 		case evm.chainRules.IsBerlin:
 			jt = berlinInstructionSet
 		case evm.chainRules.IsIstanbul:
@@ -123,6 +135,8 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 				log.Error("EIP activation failed", "eip", eip, "error", err)
 			}
 		}
+		enableRegisteredOpCodes(&jt, evm.chainRules)
+		applyOpcodeOverrides(&jt, evm.chainConfig.OpcodeOverrides)
 		cfg.JumpTable = jt
 	}
 
@@ -188,17 +202,26 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	}()
 	contract.Input = input
 
-	if in.cfg.Debug {
+	if len(in.evm.tracers) > 0 {
 		defer func() {
 			if err != nil {
-				if !logged {
-					in.cfg.Tracer.CaptureState(in.evm, pcCopy, op, gasCopy, cost, mem, stack, in.returnData, contract, in.evm.depth, err)
-				} else {
-					in.cfg.Tracer.CaptureFault(in.evm, pcCopy, op, gasCopy, cost, mem, stack, contract, in.evm.depth, err)
+				for _, tracer := range in.evm.tracers {
+					if !logged {
+						tracer.CaptureState(in.evm, pcCopy, op, gasCopy, cost, mem, stack, in.returnData, contract, in.evm.depth, err)
+					} else {
+						tracer.CaptureFault(in.evm, pcCopy, op, gasCopy, cost, mem, stack, contract, in.evm.depth, err)
+					}
 				}
 			}
 		}()
 	}
+	if in.evm.logger != nil {
+		defer func() {
+			if err != nil {
+				in.evm.logger.CaptureFault(in.evm, pcCopy, op, gasCopy, cost, mem, stack, contract, in.evm.depth, err)
+			}
+		}()
+	}
 	// The Interpreter main run loop (contextual). This loop runs until either an
 	// explicit STOP, RETURN or SELFDESTRUCT is executed, an error occurred during
 	// the execution of one of the operations or until the done flag is set by the
@@ -209,7 +232,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		if steps%1000 == 0 && atomic.LoadInt32(&in.evm.abort) != 0 {
 			break
 		}
-		if in.cfg.Debug {
+		if in.evm.logger != nil {
 			// Capture pre-execution values for tracing.
 			logged, pcCopy, gasCopy = false, pc, contract.Gas
 		}
@@ -277,26 +300,17 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			mem.Resize(memorySize)
 		}
 
-		if in.evm.firehoseContext.Enabled() {
-			if cost != 0 {
-				gasChangeReason := OpCodeToGasChangeReason(op)
-				if gasChangeReason != firehose.IgnoredGasChangeReason {
-					// When execution reach this point, `contract.UseGas` has been called once
-					// (for only a static) or twice (for both static + dynamic cost). Since it
-					// has been called, it's mean the `c.Gas` has already been adjusted down
-					// to remaining after cost.
-					//
-					// Hence to retrieve the `gasOld` value, we need to come back at state when
-					// gas was not consumed, which means doing `contract.Gas + cost`.
-					in.evm.firehoseContext.RecordGasConsume(contract.Gas+cost, cost, gasChangeReason)
-				}
-			}
+		if in.evm.logger != nil {
+			// Both static and dynamic costs have been consumed at this point, `contract.Gas + cost`
+			// is the gas available before the operation once any gas directly consumed while
+			// computing the dynamic cost (e.g. cold state access) has been accounted for
+			in.evm.logger.CaptureState(in.evm, pc, op, contract.Gas+cost, cost, mem, stack, in.returnData, contract, in.evm.depth, err)
 		}
 
-		if in.cfg.Debug {
-			in.cfg.Tracer.CaptureState(in.evm, pc, op, gasCopy, cost, mem, stack, in.returnData, contract, in.evm.depth, err)
-			logged = true
+		for _, tracer := range in.evm.tracers {
+			tracer.CaptureState(in.evm, pc, op, gasCopy, cost, mem, stack, in.returnData, contract, in.evm.depth, err)
 		}
+		logged = true
 
 		// execute the operation
 		res, err = operation.execute(&pc, in, callContext)
@@ -57,11 +57,34 @@ var (
 	constantinopleInstructionSet   = newConstantinopleInstructionSet()
 	istanbulInstructionSet         = newIstanbulInstructionSet()
 	berlinInstructionSet           = newBerlinInstructionSet()
+	shanghaiInstructionSet         = newShanghaiInstructionSet()
+	cancunInstructionSet           = newCancunInstructionSet()
 )
 
 // JumpTable contains the EVM opcodes supported at a given fork.
 type JumpTable [256]*operation
 
+// newCancunInstructionSet returns the frontier, homestead, byzantium,
+// contantinople, istanbul, petersburg, berlin, shanghai and cancun instructions.
+func newCancunInstructionSet() JumpTable {
+	instructionSet := newShanghaiInstructionSet()
+	enable4844(&instructionSet) // BLOBHASH opcode - https://eips.ethereum.org/EIPS/eip-4844
+	enable7516(&instructionSet) // BLOBBASEFEE opcode - https://eips.ethereum.org/EIPS/eip-7516
+	enable1153(&instructionSet) // Transient storage opcodes - https://eips.ethereum.org/EIPS/eip-1153
+	enable5656(&instructionSet) // MCOPY opcode - https://eips.ethereum.org/EIPS/eip-5656
+	enable6780(&instructionSet) // SELFDESTRUCT only in same transaction - https://eips.ethereum.org/EIPS/eip-6780
+	return instructionSet
+}
+
+// newShanghaiInstructionSet returns the frontier, homestead, byzantium,
+// contantinople, istanbul, petersburg, berlin and shanghai instructions.
+func newShanghaiInstructionSet() JumpTable {
+	instructionSet := newBerlinInstructionSet()
+	enable3855(&instructionSet) // PUSH0 instruction - https://eips.ethereum.org/EIPS/eip-3855
+	enable3860(&instructionSet) // Limit and meter initcode - https://eips.ethereum.org/EIPS/eip-3860
+	return instructionSet
+}
+
 // newBerlinInstructionSet returns the frontier, homestead, byzantium,
 // contantinople, istanbul, petersburg and berlin instructions.
 func newBerlinInstructionSet() JumpTable {
@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// muxLogger forwards every hook to each of its loggers, in order, so a single execution
+// feeds them all with the same gas and call frame data.
+type muxLogger []EVMLogger
+
+// NewMuxLogger returns a logger forwarding every hook to each of the `loggers` in order,
+// nil when there is none.
+func NewMuxLogger(loggers ...EVMLogger) EVMLogger {
+	switch len(loggers) {
+	case 0:
+		return nil
+	case 1:
+		return loggers[0]
+	}
+
+	return muxLogger(loggers)
+}
+
+func (m muxLogger) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	for _, logger := range m {
+		logger.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+func (m muxLogger) CaptureExit(output []byte, gasUsed uint64, err error) {
+	for _, logger := range m {
+		logger.CaptureExit(output, gasUsed, err)
+	}
+}
+
+// CaptureGasBurn forwards the hook to the loggers implementing `GasBurnLogger`.
+func (m muxLogger) CaptureGasBurn(gasLeft uint64) {
+	for _, logger := range m {
+		if burnLogger, ok := logger.(GasBurnLogger); ok {
+			burnLogger.CaptureGasBurn(gasLeft)
+		}
+	}
+}
+
+// CaptureStart forwards the hook to all loggers and returns the first error encountered.
+func (m muxLogger) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) (err error) {
+	for _, logger := range m {
+		if loggerErr := logger.CaptureStart(from, to, create, input, gas, value); loggerErr != nil && err == nil {
+			err = loggerErr
+		}
+	}
+	return
+}
+
+// CaptureState forwards the hook to all loggers and returns the first error encountered.
+func (m muxLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rData []byte, contract *Contract, depth int, err error) (outErr error) {
+	for _, logger := range m {
+		if loggerErr := logger.CaptureState(env, pc, op, gas, cost, memory, stack, rData, contract, depth, err); loggerErr != nil && outErr == nil {
+			outErr = loggerErr
+		}
+	}
+	return
+}
+
+// CaptureFault forwards the hook to all loggers and returns the first error encountered.
+func (m muxLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) (outErr error) {
+	for _, logger := range m {
+		if loggerErr := logger.CaptureFault(env, pc, op, gas, cost, memory, stack, contract, depth, err); loggerErr != nil && outErr == nil {
+			outErr = loggerErr
+		}
+	}
+	return
+}
+
+// CaptureEnd forwards the hook to all loggers and returns the first error encountered.
+func (m muxLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) (outErr error) {
+	for _, logger := range m {
+		if loggerErr := logger.CaptureEnd(output, gasUsed, t, err); loggerErr != nil && outErr == nil {
+			outErr = loggerErr
+		}
+	}
+	return
+}
+
+// tracerLogger adapts a `Tracer` to an `EVMLogger` so the tracers, the ones of the tracing
+// API included, are fed by the same execution as the Firehose instrumentation and the other
+// loggers. The outermost frame is reported to the tracer through `CaptureStart` and
+// `CaptureEnd`, the nested ones being seen through its steps.
+//
+// The steps are reported with the `Tracer` semantics rather than the `EVMLogger` ones, by
+// the interpreter directly, see `EVM.tracers`: `CaptureState` is called with the gas
+// available before the step, failing steps included, `CaptureFault` with the steps failing
+// once executed.
+type tracerLogger struct {
+	tracer Tracer
+
+	depth int
+	start time.Time
+}
+
+// NewTracerLogger returns a logger feeding `tracer`, to be multiplexed with the other
+// loggers through `Config.Loggers`.
+func NewTracerLogger(tracer Tracer) EVMLogger {
+	return &tracerLogger{tracer: tracer}
+}
+
+func (l *tracerLogger) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if l.depth == 0 {
+		l.start = time.Now()
+		l.tracer.CaptureStart(from, to, typ == CREATE || typ == CREATE2, input, gas, value)
+	}
+	l.depth++
+}
+
+func (l *tracerLogger) CaptureExit(output []byte, gasUsed uint64, err error) {
+	l.depth--
+	if l.depth == 0 {
+		l.tracer.CaptureEnd(output, gasUsed, time.Since(l.start), err)
+	}
+}
+
+func (l *tracerLogger) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+func (l *tracerLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rData []byte, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (l *tracerLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (l *tracerLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
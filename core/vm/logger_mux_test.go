@@ -0,0 +1,156 @@
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+type recordingLogger struct {
+	enters []OpCode
+	exits  []uint64
+	steps  int
+}
+
+func (l *recordingLogger) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	l.enters = append(l.enters, typ)
+}
+
+func (l *recordingLogger) CaptureExit(output []byte, gasUsed uint64, err error) {
+	l.exits = append(l.exits, gasUsed)
+}
+
+func (l *recordingLogger) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+func (l *recordingLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rData []byte, contract *Contract, depth int, err error) error {
+	l.steps++
+	return nil
+}
+
+func (l *recordingLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (l *recordingLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
+
+func TestMuxLogger(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller = common.HexToAddress("0x01")
+		callee = common.HexToAddress("0x0a")
+		target = common.HexToAddress("0x0b")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	// PUSH1 0 (x5), PUSH20 <target>, GAS, CALL, STOP
+	statedb.SetCode(callee, append(append(common.FromHex("0x60006000600060006000"), append([]byte{byte(PUSH20)}, target.Bytes()...)...), byte(GAS), byte(CALL), byte(STOP)), firehose.NoOpContext)
+	statedb.SetCode(target, []byte{byte(STOP)}, firehose.NoOpContext)
+
+	firehoseContext := startTestTx(&callee)
+
+	first, second := &recordingLogger{}, &recordingLogger{}
+	evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, params.AllEthashProtocolChanges, Config{Loggers: []EVMLogger{first, second}})
+
+	_, gasLeft, err := evm.Call(AccountRef(caller), callee, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	for i, logger := range []*recordingLogger{first, second} {
+		if len(logger.enters) != 2 || logger.enters[0] != CALL || logger.enters[1] != CALL {
+			t.Errorf("logger %d: unexpected frames %v", i, logger.enters)
+		}
+		if len(logger.exits) != 2 || logger.exits[1] != 100000-gasLeft {
+			t.Errorf("logger %d: unexpected exit gas used %v, want outermost %d", i, logger.exits, 100000-gasLeft)
+		}
+		if logger.steps != 10 {
+			t.Errorf("logger %d: unexpected steps count %d", i, logger.steps)
+		}
+	}
+
+	if count := bytes.Count(firehoseContext.FirehoseLog(), []byte("EVM_RUN_CALL")); count != 2 {
+		t.Errorf("firehose recorded %d calls, want 2", count)
+	}
+}
+
+// frameTracer is a struct logger recording the outermost frame it's notified of.
+type frameTracer struct {
+	*StructLogger
+	starts, ends int
+	gasUsed      uint64
+}
+
+func (t *frameTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.starts++
+	return t.StructLogger.CaptureStart(from, to, create, input, gas, value)
+}
+
+func (t *frameTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.ends, t.gasUsed = t.ends+1, gasUsed
+	return t.StructLogger.CaptureEnd(output, gasUsed, d, err)
+}
+
+func TestTracerLogger(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller = common.HexToAddress("0x01")
+		callee = common.HexToAddress("0x0a")
+		target = common.HexToAddress("0x0b")
+	)
+
+	// PUSH1 0 (x5), PUSH20 <target>, GAS, CALL, STOP, the target being cold
+	newState := func() *state.StateDB {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(callee, append(append(common.FromHex("0x60006000600060006000"), append([]byte{byte(PUSH20)}, target.Bytes()...)...), byte(GAS), byte(CALL), byte(STOP)), firehose.NoOpContext)
+		statedb.SetCode(target, []byte{byte(STOP)}, firehose.NoOpContext)
+		return statedb
+	}
+
+	// The tracer configured in debug mode is the reference
+	debugTracer := NewStructLogger(nil)
+	evm := newTestEVM(TxContext{}, newState(), params.AllEthashProtocolChanges, Config{Debug: true, Tracer: debugTracer})
+	if _, _, err := evm.Call(AccountRef(caller), callee, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("debug call failed: %v", err)
+	}
+
+	// The same tracer multiplexed with the Firehose instrumentation sees the same execution
+	firehoseContext := startTestTx(&callee)
+	tracer := &frameTracer{StructLogger: NewStructLogger(nil)}
+	evm = newTestEVM(TxContext{FirehoseContext: firehoseContext}, newState(), params.AllEthashProtocolChanges, Config{Loggers: []EVMLogger{NewTracerLogger(tracer)}})
+	_, gasLeft, err := evm.Call(AccountRef(caller), callee, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	if have, want := len(tracer.StructLogs()), len(debugTracer.StructLogs()); have != want || have != 10 {
+		t.Fatalf("steps count mismatch: have %d, want %d", have, want)
+	}
+	for i, step := range tracer.StructLogs() {
+		want := debugTracer.StructLogs()[i]
+		if step.Pc != want.Pc || step.Op != want.Op || step.Gas != want.Gas || step.GasCost != want.GasCost || step.Depth != want.Depth {
+			t.Errorf("step %d mismatch: have %s gas %d cost %d, want %s gas %d cost %d", i, step.Op, step.Gas, step.GasCost, want.Op, want.Gas, want.GasCost)
+		}
+	}
+	if tracer.starts != 1 || tracer.ends != 1 || tracer.gasUsed != 100000-gasLeft {
+		t.Errorf("outermost frame mismatch: %d starts, %d ends, gas used %d, want 1, 1 and %d", tracer.starts, tracer.ends, tracer.gasUsed, 100000-gasLeft)
+	}
+
+	if count := bytes.Count(firehoseContext.FirehoseLog(), []byte("EVM_RUN_CALL")); count != 2 {
+		t.Errorf("firehose recorded %d calls, want 2", count)
+	}
+}
@@ -97,6 +97,15 @@ func (m *Memory) GetPtr(offset, size int64) []byte {
 	return nil
 }
 
+// Copy copies `length` bytes from the `src` offset to the `dst` offset, the regions may
+// overlap. The memory must have been resized beforehand to hold both regions.
+func (m *Memory) Copy(dst, src, length uint64) {
+	if length == 0 {
+		return
+	}
+	copy(m.store[dst:], m.store[src:src+length])
+}
+
 // Len returns the length of the backing slice
 func (m *Memory) Len() int {
 	return len(m.store)
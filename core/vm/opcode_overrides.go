@@ -0,0 +1,49 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CheckOpcodeOverrides ensures every override of the chain config targets a known opcode.
+func CheckOpcodeOverrides(overrides map[string]*params.OpcodeOverride) error {
+	for name, override := range overrides {
+		if _, known := stringToOp[name]; !known {
+			return fmt.Errorf("opcode override of unknown opcode %q", name)
+		}
+		if override == nil {
+			return fmt.Errorf("opcode override of %s is empty", name)
+		}
+	}
+	return nil
+}
+
+// applyOpcodeOverrides applies the chain variant's opcode overrides to the jump table. The
+// overridden operations are copies, the globally defined jump tables are not polluted.
+//
+// The overrides are validated by `CheckOpcodeOverrides` when the chain config is set up, the
+// ones not applicable to the jump table are skipped: a cost override of an opcode not active
+// yet in the fork, like PUSH0 before Shanghai, only takes effect once the opcode activates.
+func applyOpcodeOverrides(jt *JumpTable, overrides map[string]*params.OpcodeOverride) {
+	for name, override := range overrides {
+		op, known := stringToOp[name]
+		if !known || override == nil {
+			continue
+		}
+		if override.Disabled {
+			jt[op] = nil
+			continue
+		}
+
+		if override.ConstantGas != nil {
+			if jt[op] == nil {
+				continue
+			}
+
+			operation := *jt[op]
+			operation.constantGas = *override.ConstantGas
+			jt[op] = &operation
+		}
+	}
+}
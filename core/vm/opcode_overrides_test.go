@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestEVM_OpcodeOverrides(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller       = common.HexToAddress("0x01")
+		copyContract = common.HexToAddress("0x0c0de")
+		killContract = common.HexToAddress("0xdead")
+		copyGas      = uint64(100)
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(copyContract, []byte{byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(CALLDATACOPY)}, nil)
+	statedb.SetCode(killContract, []byte{byte(CALLER), byte(SELFDESTRUCT)}, nil)
+
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.OpcodeOverrides = map[string]*params.OpcodeOverride{
+		"CALLDATACOPY": {ConstantGas: &copyGas},
+		"SELFDESTRUCT": {Disabled: true},
+	}
+
+	firehoseContext := startTestTx(&copyContract)
+
+	evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, &chainConfig, Config{})
+
+	if _, _, err := evm.Call(AccountRef(caller), copyContract, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	// The overridden constant cost is recorded as any other opcode cost
+	if line := "FIRE GAS_CHANGE 1 99991 99891 call_data_copy "; !bytes.Contains(firehoseContext.FirehoseLog(), []byte(line)) {
+		t.Errorf("firehose did not record %q:\n%s", line, firehoseContext.FirehoseLog())
+	}
+
+	var invalidOpCode *ErrInvalidOpCode
+	if _, _, err := evm.Call(AccountRef(caller), killContract, nil, 100000, new(big.Int)); !errors.As(err, &invalidOpCode) {
+		t.Errorf("disabled opcode error mismatch: have %v, want an invalid opcode error", err)
+	}
+
+	// The globally defined jump tables are not polluted by the overrides
+	if have := berlinInstructionSet[CALLDATACOPY].constantGas; have != GasFastestStep {
+		t.Errorf("global CALLDATACOPY constant gas mismatch: have %d, want %d", have, GasFastestStep)
+	}
+	if berlinInstructionSet[SELFDESTRUCT] == nil {
+		t.Errorf("global SELFDESTRUCT operation was removed")
+	}
+}
+
+func TestCheckOpcodeOverrides(t *testing.T) {
+	if err := CheckOpcodeOverrides(map[string]*params.OpcodeOverride{"SELFDESTRUCT": {Disabled: true}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := CheckOpcodeOverrides(map[string]*params.OpcodeOverride{"SUICIDE": {Disabled: true}}); err == nil {
+		t.Errorf("expected an error for an unknown opcode")
+	}
+	if err := CheckOpcodeOverrides(map[string]*params.OpcodeOverride{"SELFDESTRUCT": nil}); err == nil {
+		t.Errorf("expected an error for an empty override")
+	}
+}
+
+func TestEVM_OpcodeOverridesBeforeActivation(t *testing.T) {
+	var (
+		caller        = common.HexToAddress("0x01")
+		pushContract  = common.HexToAddress("0x0c0de")
+		push0Contract = common.HexToAddress("0x0c0de0")
+		push0Gas      = uint64(5)
+		pushGas       = uint64(10)
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(pushContract, []byte{byte(PUSH1), 0x00}, nil)
+	statedb.SetCode(push0Contract, []byte{byte(PUSH0)}, nil)
+
+	// Shanghai activates after the test EVM block, PUSH0 is not active yet
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.ShanghaiBlock = big.NewInt(2)
+	chainConfig.CancunBlock = nil
+	chainConfig.PragueBlock = nil
+	chainConfig.OpcodeOverrides = map[string]*params.OpcodeOverride{
+		"PUSH0": {ConstantGas: &push0Gas},
+		"PUSH1": {ConstantGas: &pushGas},
+	}
+
+	evm := newTestEVM(TxContext{}, statedb, &chainConfig, Config{})
+
+	// The overrides of active opcodes still apply
+	_, leftOverGas, err := evm.Call(AccountRef(caller), pushContract, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if have := 100000 - leftOverGas; have != pushGas {
+		t.Errorf("gas used mismatch: have %d, want %d", have, pushGas)
+	}
+
+	var invalidOpCode *ErrInvalidOpCode
+	if _, _, err := evm.Call(AccountRef(caller), push0Contract, nil, 100000, new(big.Int)); !errors.As(err, &invalidOpCode) {
+		t.Errorf("inactive opcode error mismatch: have %v, want an invalid opcode error", err)
+	}
+}
@@ -105,6 +105,8 @@ const (
 	GASLIMIT
 	CHAINID     OpCode = 0x46
 	SELFBALANCE OpCode = 0x47
+	BLOBHASH    OpCode = 0x49
+	BLOBBASEFEE OpCode = 0x4a
 )
 
 // 0x50 range - 'storage' and execution.
@@ -121,6 +123,10 @@ const (
 	MSIZE    OpCode = 0x59
 	GAS      OpCode = 0x5a
 	JUMPDEST OpCode = 0x5b
+	TLOAD    OpCode = 0x5c
+	TSTORE   OpCode = 0x5d
+	MCOPY    OpCode = 0x5e
+	PUSH0    OpCode = 0x5f
 )
 
 // 0x60 range.
@@ -282,6 +288,8 @@ var opCodeToString = map[OpCode]string{
 	GASLIMIT:    "GASLIMIT",
 	CHAINID:     "CHAINID",
 	SELFBALANCE: "SELFBALANCE",
+	BLOBHASH:    "BLOBHASH",
+	BLOBBASEFEE: "BLOBBASEFEE",
 
 	// 0x50 range - 'storage' and execution.
 	POP: "POP",
@@ -298,6 +306,10 @@ var opCodeToString = map[OpCode]string{
 	MSIZE:    "MSIZE",
 	GAS:      "GAS",
 	JUMPDEST: "JUMPDEST",
+	TLOAD:    "TLOAD",
+	TSTORE:   "TSTORE",
+	MCOPY:    "MCOPY",
+	PUSH0:    "PUSH0",
 
 	// 0x60 range - push.
 	PUSH1:  "PUSH1",
@@ -451,6 +463,8 @@ var stringToOp = map[string]OpCode{
 	"DIFFICULTY":     DIFFICULTY,
 	"GASLIMIT":       GASLIMIT,
 	"SELFBALANCE":    SELFBALANCE,
+	"BLOBHASH":       BLOBHASH,
+	"BLOBBASEFEE":    BLOBBASEFEE,
 	"POP":            POP,
 	"MLOAD":          MLOAD,
 	"MSTORE":         MSTORE,
@@ -463,6 +477,10 @@ var stringToOp = map[string]OpCode{
 	"MSIZE":          MSIZE,
 	"GAS":            GAS,
 	"JUMPDEST":       JUMPDEST,
+	"TLOAD":          TLOAD,
+	"TSTORE":         TSTORE,
+	"MCOPY":          MCOPY,
+	"PUSH0":          PUSH0,
 	"PUSH1":          PUSH1,
 	"PUSH2":          PUSH2,
 	"PUSH3":          PUSH3,
@@ -549,24 +567,27 @@ func StringToOp(str string) OpCode {
 // Firehose additions
 
 var opCodeToGasChangeReasonMap = map[OpCode]firehose.GasChangeReason{
-	CREATE:         firehose.GasChangeReason("contract_creation"),
-	CREATE2:        firehose.GasChangeReason("contract_creation2"),
-	CALL:           firehose.GasChangeReason("call"),
-	STATICCALL:     firehose.GasChangeReason("static_call"),
-	CALLCODE:       firehose.GasChangeReason("call_code"),
-	DELEGATECALL:   firehose.GasChangeReason("delegate_call"),
-	RETURN:         firehose.GasChangeReason("return"),
-	REVERT:         firehose.GasChangeReason("revert"),
-	LOG0:           firehose.GasChangeReason("event_log"),
-	LOG1:           firehose.GasChangeReason("event_log"),
-	LOG2:           firehose.GasChangeReason("event_log"),
-	LOG3:           firehose.GasChangeReason("event_log"),
-	LOG4:           firehose.GasChangeReason("event_log"),
-	SELFDESTRUCT:   firehose.GasChangeReason("self_destruct"),
-	CALLDATACOPY:   firehose.GasChangeReason("call_data_copy"),
-	CODECOPY:       firehose.GasChangeReason("code_copy"),
-	EXTCODECOPY:    firehose.GasChangeReason("ext_code_copy"),
-	RETURNDATACOPY: firehose.GasChangeReason("return_data_copy"),
+	CREATE:         firehose.ContractCreationGasChangeReason,
+	CREATE2:        firehose.ContractCreation2GasChangeReason,
+	CALL:           firehose.CallGasChangeReason,
+	STATICCALL:     firehose.StaticCallGasChangeReason,
+	CALLCODE:       firehose.CallCodeGasChangeReason,
+	DELEGATECALL:   firehose.DelegateCallGasChangeReason,
+	RETURN:         firehose.ReturnGasChangeReason,
+	REVERT:         firehose.RevertGasChangeReason,
+	LOG0:           firehose.EventLogGasChangeReason,
+	LOG1:           firehose.EventLogGasChangeReason,
+	LOG2:           firehose.EventLogGasChangeReason,
+	LOG3:           firehose.EventLogGasChangeReason,
+	LOG4:           firehose.EventLogGasChangeReason,
+	SELFDESTRUCT:   firehose.SelfDestructGasChangeReason,
+	CALLDATACOPY:   firehose.CallDataCopyGasChangeReason,
+	CODECOPY:       firehose.CodeCopyGasChangeReason,
+	EXTCODECOPY:    firehose.ExtCodeCopyGasChangeReason,
+	RETURNDATACOPY: firehose.ReturnDataCopyGasChangeReason,
+	MCOPY:          firehose.MemoryCopyGasChangeReason,
+	TLOAD:          firehose.TransientStorageGasChangeReason,
+	TSTORE:         firehose.TransientStorageGasChangeReason,
 }
 
 // We only track a few high costs op code that gives a rough idea where gas is spent
@@ -21,6 +21,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -60,6 +61,9 @@ func gasSStoreEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Memory, m
 		cost = ColdSloadCostEIP2929
 		// If the caller cannot afford the cost, this change will be rolled back
 		evm.StateDB.AddSlotToAccessList(contract.Address(), slot)
+		if evm.FirehoseContext.AccessRecordingEnabled() {
+			evm.FirehoseContext.RecordStorageAccess(contract.Address(), slot)
+		}
 		if !addrPresent {
 			// Once we're done with YOLOv2 and schedule this for mainnet, might
 			// be good to remove this panic here, which is just really a
@@ -125,6 +129,9 @@ func gasSLoadEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Memory, me
 		// If the caller cannot afford the cost, this change will be rolled back
 		// If he does afford it, we can skip checking the same thing later on, during execution
 		evm.StateDB.AddSlotToAccessList(contract.Address(), slot)
+		if evm.FirehoseContext.AccessRecordingEnabled() {
+			evm.FirehoseContext.RecordStorageAccess(contract.Address(), slot)
+		}
 		return ColdSloadCostEIP2929, nil
 	}
 	return WarmStorageReadCostEIP2929, nil
@@ -145,6 +152,9 @@ func gasExtCodeCopyEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Memo
 	// Check slot presence in the access list
 	if !evm.StateDB.AddressInAccessList(addr) {
 		evm.StateDB.AddAddressToAccessList(addr)
+		if evm.FirehoseContext.AccessRecordingEnabled() {
+			evm.FirehoseContext.RecordAccountAccess(addr)
+		}
 		var overflow bool
 		// We charge (cold-warm), since 'warm' is already charged as constantGas
 		if gas, overflow = math.SafeAdd(gas, ColdAccountAccessCostEIP2929-WarmStorageReadCostEIP2929); overflow {
@@ -168,6 +178,9 @@ func gasEip2929AccountCheck(evm *EVM, contract *Contract, stack *Stack, mem *Mem
 	if !evm.StateDB.AddressInAccessList(addr) {
 		// If the caller cannot afford the cost, this change will be rolled back
 		evm.StateDB.AddAddressToAccessList(addr)
+		if evm.FirehoseContext.AccessRecordingEnabled() {
+			evm.FirehoseContext.RecordAccountAccess(addr)
+		}
 		// The warm storage read cost is already charged as constantGas
 		return ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929, nil
 	}
@@ -180,11 +193,31 @@ func makeCallVariantGasCallEIP2929(oldCalculator gasFunc) gasFunc {
 		// Check slot presence in the access list
 		if !evm.StateDB.AddressInAccessList(addr) {
 			evm.StateDB.AddAddressToAccessList(addr)
+			if evm.FirehoseContext.AccessRecordingEnabled() {
+				evm.FirehoseContext.RecordAccountAccess(addr)
+			}
 			// The WarmStorageReadCostEIP2929 (100) is already deducted in the form of a constant cost
-			if !contract.UseGas(ColdAccountAccessCostEIP2929-WarmStorageReadCostEIP2929, firehose.GasChangeReason("state_cold_access")) {
+			if !contract.UseGas(ColdAccountAccessCostEIP2929-WarmStorageReadCostEIP2929, firehose.StateColdAccessGasChangeReason) {
 				return 0, ErrOutOfGas
 			}
 		}
+		// Once Prague is active, the code executed is the one of the target an EIP-7702
+		// delegation points to, its access is charged on top
+		if evm.chainRules.IsPrague {
+			if target, ok := types.ParseDelegation(evm.StateDB.GetCode(addr)); ok {
+				cost := WarmStorageReadCostEIP2929
+				if !evm.StateDB.AddressInAccessList(target) {
+					evm.StateDB.AddAddressToAccessList(target)
+					if evm.FirehoseContext.AccessRecordingEnabled() {
+						evm.FirehoseContext.RecordAccountAccess(target)
+					}
+					cost = ColdAccountAccessCostEIP2929
+				}
+				if !contract.UseGas(cost, firehose.StateColdAccessGasChangeReason) {
+					return 0, ErrOutOfGas
+				}
+			}
+		}
 		// Now call the old calculator, which takes into account
 		// - create new account
 		// - transfer value
@@ -209,6 +242,9 @@ func gasSelfdestructEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Mem
 	if !evm.StateDB.AddressInAccessList(address) {
 		// If the caller cannot afford the cost, this change will be rolled back
 		evm.StateDB.AddAddressToAccessList(address)
+		if evm.FirehoseContext.AccessRecordingEnabled() {
+			evm.FirehoseContext.RecordAccountAccess(address)
+		}
 		gas = ColdAccountAccessCostEIP2929
 	}
 	// if empty and transfers value
@@ -0,0 +1,203 @@
+package vm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// precompiledContractNames are the names of the precompiled contracts, as recorded by
+// Firehose, the registered ones are added by `RegisterPrecompiledContract`.
+var precompiledContractNames = map[common.Address]string{
+	common.BytesToAddress([]byte{1}): "ecrecover",
+	common.BytesToAddress([]byte{2}): "sha256",
+	common.BytesToAddress([]byte{3}): "ripemd160",
+	common.BytesToAddress([]byte{4}): "identity",
+	common.BytesToAddress([]byte{5}): "modexp",
+	common.BytesToAddress([]byte{6}): "bn256_add",
+	common.BytesToAddress([]byte{7}): "bn256_scalar_mul",
+	common.BytesToAddress([]byte{8}): "bn256_pairing",
+	common.BytesToAddress([]byte{9}): "blake2f",
+
+	common.BytesToAddress([]byte{10}): "kzg_point_evaluation",
+
+	common.BytesToAddress([]byte{11}): "bls12381_g1_add",
+	common.BytesToAddress([]byte{12}): "bls12381_g1_multiexp",
+	common.BytesToAddress([]byte{13}): "bls12381_g2_add",
+	common.BytesToAddress([]byte{14}): "bls12381_g2_multiexp",
+	common.BytesToAddress([]byte{15}): "bls12381_pairing",
+	common.BytesToAddress([]byte{16}): "bls12381_map_g1",
+	common.BytesToAddress([]byte{17}): "bls12381_map_g2",
+}
+
+// precompileRegistration is a precompiled contract registered through
+// `RegisterPrecompiledContract`.
+type precompileRegistration struct {
+	address  common.Address
+	contract PrecompiledContract
+	isActive func(rules params.Rules) bool
+}
+
+// registeredPrecompiles are kept in registration order so that the active addresses
+// returned by `DefaultPrecompiles` are deterministic.
+var registeredPrecompiles []precompileRegistration
+
+// RegisterPrecompiledContract registers a custom precompiled contract at `addr`, active
+// for the chain rules for which `isActive` returns true, it's meant for chain variants to
+// add their own precompiles without patching the EVM. It must only be called while
+// initializing packages and panics if the address or the name is already used.
+func RegisterPrecompiledContract(addr common.Address, name string, contract PrecompiledContract, isActive func(rules params.Rules) bool) {
+	if name == "" {
+		panic(fmt.Errorf("precompiled contract at %s must have a name", addr.Hex()))
+	}
+
+	if existing, found := precompiledContractNames[addr]; found {
+		panic(fmt.Errorf("precompiled contract %q cannot be registered at %s, already used by %q", name, addr.Hex(), existing))
+	}
+
+	for existingAddr, existing := range precompiledContractNames {
+		if existing == name {
+			panic(fmt.Errorf("precompiled contract %q cannot be registered at %s, name already used at %s", name, addr.Hex(), existingAddr.Hex()))
+		}
+	}
+
+	precompiledContractNames[addr] = name
+	registeredPrecompiles = append(registeredPrecompiles, precompileRegistration{addr, contract, isActive})
+}
+
+// PrecompiledContractName returns the name of the precompiled contract at `addr`, empty
+// if there is none or if it was not registered.
+func PrecompiledContractName(addr common.Address) string {
+	return precompiledContractNames[addr]
+}
+
+// DefaultPrecompiles returns the precompiled contracts active under `rules` along with
+// their addresses, the ones of the fork followed by the EIP-2537 ones when activated and
+// by the registered ones.
+func DefaultPrecompiles(rules params.Rules) (map[common.Address]PrecompiledContract, []common.Address) {
+	var precompiles map[common.Address]PrecompiledContract
+	var addresses []common.Address
+	switch {
+	case rules.IsCancun:
+		precompiles, addresses = PrecompiledContractsCancun, PrecompiledAddressesCancun
+	case rules.IsBerlin:
+		precompiles, addresses = PrecompiledContractsBerlin, PrecompiledAddressesBerlin
+	case rules.IsIstanbul:
+		precompiles, addresses = PrecompiledContractsIstanbul, PrecompiledAddressesIstanbul
+	case rules.IsByzantium:
+		precompiles, addresses = PrecompiledContractsByzantium, PrecompiledAddressesByzantium
+	default:
+		precompiles, addresses = PrecompiledContractsHomestead, PrecompiledAddressesHomestead
+	}
+
+	var extended bool
+	if rules.IsEIP2537 {
+		// The fork's sets are shared, copy them before adding the BLS12-381 ones
+		precompiles, addresses = copyPrecompiles(precompiles, addresses)
+		extended = true
+
+		for _, addr := range PrecompiledAddressesBLS {
+			precompiles[addr] = PrecompiledContractsBLS[addr]
+		}
+		addresses = append(addresses, PrecompiledAddressesBLS...)
+	}
+
+	for _, registration := range registeredPrecompiles {
+		if !registration.isActive(rules) {
+			continue
+		}
+
+		// The fork's sets are shared, copy them before adding the first registered one
+		if !extended {
+			precompiles, addresses = copyPrecompiles(precompiles, addresses)
+			extended = true
+		}
+
+		precompiles[registration.address] = registration.contract
+		addresses = append(addresses, registration.address)
+	}
+
+	return precompiles, addresses
+}
+
+func copyPrecompiles(precompiles map[common.Address]PrecompiledContract, addresses []common.Address) (map[common.Address]PrecompiledContract, []common.Address) {
+	precompilesCopy := make(map[common.Address]PrecompiledContract, len(precompiles)+len(PrecompiledAddressesBLS)+len(registeredPrecompiles))
+	for addr, contract := range precompiles {
+		precompilesCopy[addr] = contract
+	}
+
+	return precompilesCopy, append([]common.Address(nil), addresses...)
+}
+
+// WithPrecompiles replaces the precompiled contracts of the EVM, `active` being the addresses
+// warmed at the start of a transaction (EIP-2929), and returns the EVM.
+func (evm *EVM) WithPrecompiles(precompiles map[common.Address]PrecompiledContract, active []common.Address) *EVM {
+	evm.precompiles = precompiles
+	evm.activePrecompiles = active
+
+	return evm
+}
+
+// StatefulPrecompiledContract is a precompiled contract accessing the state (e.g. a native
+// token bridge or a staking contract). Its state changes must go through the `StateDB` with
+// the Firehose context of the call so they are recorded in the Firehose stream like the ones
+// of any other contract.
+type StatefulPrecompiledContract interface {
+	PrecompiledContract
+
+	// RunStateful runs the precompiled contract in the context of the call, `Run` is never
+	// called for a stateful precompiled contract.
+	RunStateful(ctx *PrecompileContext, input []byte) ([]byte, error)
+}
+
+// PrecompileContext is the context of a call to a stateful precompiled contract.
+type PrecompileContext struct {
+	EVM *EVM
+
+	// Caller is the account calling the precompiled contract, the caller of the calling
+	// contract for a DELEGATECALL
+	Caller common.Address
+
+	// Address is the account whose state the call executes against, the precompiled contract
+	// itself for a CALL or a STATICCALL and the calling contract for a CALLCODE or a DELEGATECALL
+	Address common.Address
+	Value   *big.Int
+
+	// ReadOnly is true when the call must not modify the state (i.e. STATICCALL), the
+	// precompiled contract must then fail with `ErrWriteProtection` if it would
+	ReadOnly bool
+}
+
+// StateDB returns the state the precompiled contract executes against.
+func (c *PrecompileContext) StateDB() StateDB {
+	return c.EVM.StateDB
+}
+
+// FirehoseContext returns the Firehose context the state changes must be recorded into.
+func (c *PrecompileContext) FirehoseContext() *firehose.Context {
+	return c.EVM.FirehoseContext
+}
+
+// boundStatefulPrecompile binds a stateful precompiled contract to the context of a call
+// so it can be run like any other precompiled contract.
+type boundStatefulPrecompile struct {
+	StatefulPrecompiledContract
+	ctx *PrecompileContext
+}
+
+func (p boundStatefulPrecompile) Run(input []byte) ([]byte, error) {
+	return p.RunStateful(p.ctx, input)
+}
+
+// runPrecompiledContract runs the precompiled contract `p`, giving it the context of the
+// call when it's a stateful one.
+func (evm *EVM) runPrecompiledContract(p PrecompiledContract, caller, address common.Address, value *big.Int, input []byte, gas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	if stateful, isStateful := p.(StatefulPrecompiledContract); isStateful {
+		p = boundStatefulPrecompile{stateful, &PrecompileContext{EVM: evm, Caller: caller, Address: address, Value: value, ReadOnly: readOnly}}
+	}
+
+	return RunPrecompiledContract(p, input, gas, evm.FirehoseContext)
+}
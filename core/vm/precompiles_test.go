@@ -0,0 +1,233 @@
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// echoPrecompile returns its input for a fixed gas cost.
+type echoPrecompile struct{}
+
+func (echoPrecompile) RequiredGas(input []byte) uint64  { return 10 }
+func (echoPrecompile) Run(input []byte) ([]byte, error) { return input, nil }
+
+var echoPrecompileAddress = common.HexToAddress("0x0300")
+
+func init() {
+	RegisterPrecompiledContract(echoPrecompileAddress, "echo", echoPrecompile{}, func(rules params.Rules) bool { return rules.IsBerlin })
+}
+
+// counterPrecompile stores its input in the storage of the account it executes against.
+type counterPrecompile struct{}
+
+func (counterPrecompile) RequiredGas(input []byte) uint64 { return 100 }
+func (counterPrecompile) Run(input []byte) ([]byte, error) {
+	panic("stateful precompile run without context")
+}
+
+func (counterPrecompile) RunStateful(ctx *PrecompileContext, input []byte) ([]byte, error) {
+	if ctx.ReadOnly {
+		return nil, ErrWriteProtection
+	}
+
+	ctx.StateDB().SetState(ctx.Address, common.Hash{}, common.BytesToHash(input), ctx.FirehoseContext())
+	return ctx.Caller.Bytes(), nil
+}
+
+func TestRegisterPrecompiledContract(t *testing.T) {
+	precompiles, addresses := DefaultPrecompiles(params.Rules{IsBerlin: true})
+	if _, found := precompiles[echoPrecompileAddress]; !found || addresses[len(addresses)-1] != echoPrecompileAddress {
+		t.Errorf("registered precompile is not active under Berlin rules")
+	}
+	if _, found := PrecompiledContractsBerlin[echoPrecompileAddress]; found || len(PrecompiledAddressesBerlin) != 9 {
+		t.Errorf("registered precompile leaked into the Berlin precompiles")
+	}
+
+	precompiles, _ = DefaultPrecompiles(params.Rules{IsIstanbul: true})
+	if _, found := precompiles[echoPrecompileAddress]; found {
+		t.Errorf("registered precompile is active under Istanbul rules")
+	}
+
+	if name := PrecompiledContractName(echoPrecompileAddress); name != "echo" {
+		t.Errorf("registered precompile name mismatch: have %q, want %q", name, "echo")
+	}
+
+	for _, invalid := range []struct {
+		addr common.Address
+		name string
+	}{
+		{common.BytesToAddress([]byte{1}), "other"},
+		{echoPrecompileAddress, "other"},
+		{common.HexToAddress("0x0301"), "echo"},
+		{common.HexToAddress("0x0301"), ""},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q at %s should have panicked", invalid.name, invalid.addr.Hex())
+				}
+			}()
+			RegisterPrecompiledContract(invalid.addr, invalid.name, echoPrecompile{}, func(params.Rules) bool { return true })
+		}()
+	}
+}
+
+func TestDefaultPrecompiles_EIP2537(t *testing.T) {
+	precompiles, addresses := DefaultPrecompiles(params.Rules{IsBerlin: true, IsEIP2537: true})
+	for _, addr := range PrecompiledAddressesBLS {
+		if _, found := precompiles[addr]; !found {
+			t.Errorf("BLS12-381 precompile %s is not active under EIP-2537 rules", addr.Hex())
+		}
+	}
+	if len(addresses) != len(PrecompiledAddressesBerlin)+len(PrecompiledAddressesBLS)+1 {
+		t.Errorf("active precompiles mismatch: have %v", addresses)
+	}
+	if _, found := PrecompiledContractsBerlin[PrecompiledAddressesBLS[0]]; found {
+		t.Errorf("BLS12-381 precompiles leaked into the Berlin precompiles")
+	}
+
+	precompiles, _ = DefaultPrecompiles(params.Rules{IsBerlin: true})
+	if _, found := precompiles[PrecompiledAddressesBLS[0]]; found {
+		t.Errorf("BLS12-381 precompiles are active without EIP-2537")
+	}
+
+	// The BLS12-381 precompiles follow the Cancun point evaluation one, both are active together
+	precompiles, _ = DefaultPrecompiles(params.Rules{IsBerlin: true, IsCancun: true, IsEIP2537: true})
+	if _, ok := precompiles[common.BytesToAddress([]byte{10})].(*kzgPointEvaluation); !ok {
+		t.Errorf("point evaluation precompile is not active along with the BLS12-381 ones")
+	}
+	for _, addr := range PrecompiledAddressesBLS {
+		if _, found := precompiles[addr]; !found {
+			t.Errorf("BLS12-381 precompile %s is not active under Cancun and EIP-2537 rules", addr.Hex())
+		}
+	}
+}
+
+func TestDefaultPrecompiles_RIP7212(t *testing.T) {
+	precompiles, addresses := DefaultPrecompiles(params.Rules{IsBerlin: true, IsRIP7212: true})
+	if _, found := precompiles[P256VerifyAddress]; !found {
+		t.Errorf("secp256r1 precompile is not active under RIP-7212 rules")
+	}
+	if addresses[len(PrecompiledAddressesBerlin)] != P256VerifyAddress {
+		t.Errorf("active precompiles mismatch: have %v", addresses)
+	}
+
+	precompiles, _ = DefaultPrecompiles(params.Rules{IsBerlin: true})
+	if _, found := precompiles[P256VerifyAddress]; found {
+		t.Errorf("secp256r1 precompile is active without RIP-7212")
+	}
+
+	if name := PrecompiledContractName(P256VerifyAddress); name != "p256_verify" {
+		t.Errorf("secp256r1 precompile name mismatch: have %q, want %q", name, "p256_verify")
+	}
+}
+
+func TestEVM_BLS12381Precompile(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller = common.HexToAddress("0x01")
+		g1Add  = PrecompiledAddressesBLS[0]
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.EIP2537Block = big.NewInt(1)
+
+	firehoseContext := startTestTx(&g1Add)
+
+	evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, &chainConfig, Config{})
+
+	// Adding two points at infinity
+	ret, gasLeft, err := evm.Call(AccountRef(caller), g1Add, make([]byte, 256), 1000, new(big.Int))
+	if err != nil || !bytes.Equal(ret, make([]byte, 128)) || gasLeft != 1000-params.Bls12381G1AddGas {
+		t.Fatalf("BLS12-381 G1 addition mismatch: ret %x, gas left %d, err %v", ret, gasLeft, err)
+	}
+
+	for _, line := range []string{
+		"FIRE EVM_PRECOMPILE 1 bls12381_g1_add\n",
+		"FIRE GAS_CHANGE 1 1000 625 precompiled_contract ",
+	} {
+		if !bytes.Contains(firehoseContext.FirehoseLog(), []byte(line)) {
+			t.Errorf("firehose did not record %q:\n%s", line, firehoseContext.FirehoseLog())
+		}
+	}
+}
+
+func TestEVM_WithPrecompiles(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller = common.HexToAddress("0x01")
+		custom = common.HexToAddress("0x0200")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	firehoseContext := startTestTx(&custom)
+
+	evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, params.AllEthashProtocolChanges, Config{}).
+		WithPrecompiles(map[common.Address]PrecompiledContract{custom: echoPrecompile{}}, []common.Address{custom})
+
+	if active := evm.ActivePrecompiles(); len(active) != 1 || active[0] != custom {
+		t.Fatalf("active precompiles mismatch: have %v", active)
+	}
+
+	ret, gasLeft, err := evm.Call(AccountRef(caller), custom, []byte("firehose"), 100, new(big.Int))
+	if err != nil || string(ret) != "firehose" || gasLeft != 90 {
+		t.Fatalf("custom precompile call mismatch: ret %q, gas left %d, err %v", ret, gasLeft, err)
+	}
+
+	// The custom precompile was not registered, its name is unknown
+	if !bytes.Contains(firehoseContext.FirehoseLog(), []byte("FIRE EVM_PRECOMPILE 1 .\n")) {
+		t.Errorf("firehose did not record the precompiled call:\n%s", firehoseContext.FirehoseLog())
+	}
+}
+
+func TestEVM_StatefulPrecompile(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		caller  = common.HexToAddress("0x01")
+		counter = common.HexToAddress("0x0200")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	firehoseContext := startTestTx(&counter)
+
+	evm := newTestEVM(TxContext{FirehoseContext: firehoseContext}, statedb, params.AllEthashProtocolChanges, Config{}).
+		WithPrecompiles(map[common.Address]PrecompiledContract{counter: counterPrecompile{}}, []common.Address{counter})
+
+	ret, gasLeft, err := evm.Call(AccountRef(caller), counter, []byte{0x2a}, 1000, new(big.Int))
+	if err != nil || common.BytesToAddress(ret) != caller || gasLeft != 900 {
+		t.Fatalf("stateful precompile call mismatch: ret %x, gas left %d, err %v", ret, gasLeft, err)
+	}
+	if stored := statedb.GetState(counter, common.Hash{}); stored != common.BytesToHash([]byte{0x2a}) {
+		t.Errorf("stateful precompile storage mismatch: have %s", stored.Hex())
+	}
+
+	// The state change is recorded within the precompiled call
+	storageChange := []byte("FIRE STORAGE_CHANGE 1 0000000000000000000000000000000000000200 ")
+	if !bytes.Contains(firehoseContext.FirehoseLog(), storageChange) {
+		t.Errorf("firehose did not record the stateful precompile storage change:\n%s", firehoseContext.FirehoseLog())
+	}
+
+	if _, _, err := evm.StaticCall(AccountRef(caller), counter, []byte{0x2b}, 1000); err != ErrWriteProtection {
+		t.Errorf("stateful precompile static call error mismatch: have %v, want %v", err, ErrWriteProtection)
+	}
+	if stored := statedb.GetState(counter, common.Hash{}); stored != common.BytesToHash([]byte{0x2a}) {
+		t.Errorf("stateful precompile static call modified the storage: have %s", stored.Hex())
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// RulesCache caches the chain rules of a chain config, along with the precompiled contracts
+// active under them, so that creating an EVM does not derive them again. All the forks of
+// the config are activated by block number, the rules are the same for all the blocks between
+// two consecutive fork blocks: they are cached by interval, identified by the number of fork
+// blocks at or below the block number.
+//
+// The cache is used by the EVMs created for its chain config with a `Config` holding it,
+// the chain config must not be modified once the cache is created.
+type RulesCache struct {
+	config *params.ChainConfig
+	forks  []*big.Int
+
+	lock      sync.RWMutex
+	intervals map[int]*cachedRules
+}
+
+type cachedRules struct {
+	rules             params.Rules
+	precompiles       map[common.Address]PrecompiledContract
+	activePrecompiles []common.Address
+}
+
+// NewRulesCache creates a rules cache for the chain config.
+func NewRulesCache(config *params.ChainConfig) *RulesCache {
+	return &RulesCache{
+		config:    config,
+		forks:     config.ForkBlocks(),
+		intervals: make(map[int]*cachedRules),
+	}
+}
+
+// lookup returns the rules of block number num, computing them on the first lookup of its
+// interval.
+func (c *RulesCache) lookup(num *big.Int) *cachedRules {
+	interval := sort.Search(len(c.forks), func(i int) bool { return c.forks[i].Cmp(num) > 0 })
+
+	c.lock.RLock()
+	cached := c.intervals[interval]
+	c.lock.RUnlock()
+	if cached != nil {
+		return cached
+	}
+
+	cached = &cachedRules{rules: c.config.Rules(num)}
+	cached.precompiles, cached.activePrecompiles = DefaultPrecompiles(cached.rules)
+
+	c.lock.Lock()
+	c.intervals[interval] = cached
+	c.lock.Unlock()
+
+	return cached
+}
@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestRulesCache(t *testing.T) {
+	config := *params.TestChainConfig
+	config.HomesteadBlock = big.NewInt(2)
+	config.DAOForkBlock = big.NewInt(2)
+	config.EIP150Block = big.NewInt(5)
+	config.EIP155Block = big.NewInt(5)
+	config.EIP158Block = big.NewInt(5)
+	config.ByzantiumBlock = big.NewInt(10)
+	config.ConstantinopleBlock = big.NewInt(10)
+	config.PetersburgBlock = big.NewInt(10)
+	config.IstanbulBlock = big.NewInt(20)
+	config.MuirGlacierBlock = big.NewInt(20)
+	config.BerlinBlock = big.NewInt(30)
+
+	cache := NewRulesCache(&config)
+	vmConfig := Config{RulesCache: cache}
+
+	for num := int64(0); num < 40; num++ {
+		blockCtx := BlockContext{BlockNumber: big.NewInt(num)}
+
+		cached := NewEVM(blockCtx, TxContext{}, nil, &config, vmConfig)
+		computed := NewEVM(blockCtx, TxContext{}, nil, &config, Config{})
+
+		if !reflect.DeepEqual(cached.chainRules, computed.chainRules) {
+			t.Errorf("block %d: cached rules %+v, want %+v", num, cached.chainRules, computed.chainRules)
+		}
+		if !reflect.DeepEqual(cached.activePrecompiles, computed.activePrecompiles) {
+			t.Errorf("block %d: cached active precompiles %v, want %v", num, cached.activePrecompiles, computed.activePrecompiles)
+		}
+		if len(cached.precompiles) != len(computed.precompiles) {
+			t.Errorf("block %d: cached precompiles count %d, want %d", num, len(cached.precompiles), len(computed.precompiles))
+		}
+	}
+
+	// Blocks 0-1, 2-4, 5-9, 10-19, 20-29 and 30+
+	if len(cache.intervals) != 6 {
+		t.Errorf("cached intervals %d, want 6", len(cache.intervals))
+	}
+
+	// A cache for another config is ignored
+	other := config
+	other.BerlinBlock = nil
+	evm := NewEVM(BlockContext{BlockNumber: big.NewInt(35)}, TxContext{}, nil, &other, vmConfig)
+	if evm.chainRules.IsBerlin {
+		t.Errorf("rules of the cached config used for another config")
+	}
+}
@@ -117,6 +117,9 @@ func Execute(code, input []byte, cfg *Config) ([]byte, *state.StateDB, error) {
 	)
 	if cfg.ChainConfig.IsBerlin(vmenv.Context.BlockNumber) {
 		cfg.State.PrepareAccessList(cfg.Origin, &address, vmenv.ActivePrecompiles(), nil)
+		if cfg.ChainConfig.IsShanghai(vmenv.Context.BlockNumber) {
+			cfg.State.AddAddressToAccessList(cfg.Coinbase)
+		}
 	}
 	cfg.State.CreateAccount(address, firehose.NoOpContext)
 	// set the receiver's (the executing contract) code for execution.
@@ -149,6 +152,9 @@ func Create(input []byte, cfg *Config) ([]byte, common.Address, uint64, error) {
 	)
 	if cfg.ChainConfig.IsBerlin(vmenv.Context.BlockNumber) {
 		cfg.State.PrepareAccessList(cfg.Origin, nil, vmenv.ActivePrecompiles(), nil)
+		if cfg.ChainConfig.IsShanghai(vmenv.Context.BlockNumber) {
+			cfg.State.AddAddressToAccessList(cfg.Coinbase)
+		}
 	}
 
 	// Call the code with the given configuration.
@@ -175,6 +181,9 @@ func Call(address common.Address, input []byte, cfg *Config) ([]byte, uint64, er
 	statedb := cfg.State
 	if cfg.ChainConfig.IsBerlin(vmenv.Context.BlockNumber) {
 		statedb.PrepareAccessList(cfg.Origin, &address, vmenv.ActivePrecompiles(), nil)
+		if cfg.ChainConfig.IsShanghai(vmenv.Context.BlockNumber) {
+			statedb.AddAddressToAccessList(cfg.Coinbase)
+		}
 	}
 
 	// Call the code with the given configuration.
@@ -81,6 +81,17 @@ func (st *Stack) peek() *uint256.Int {
 	return &st.data[st.len()-1]
 }
 
+// Push pushes `d` on top of the stack, the stack limit is checked by the interpreter
+// against the number of items the operation declared to push.
+func (st *Stack) Push(d *uint256.Int) {
+	st.push(d)
+}
+
+// Pop removes the item on top of the stack and returns it.
+func (st *Stack) Pop() uint256.Int {
+	return st.pop()
+}
+
 // Back returns the n'th item in stack
 func (st *Stack) Back(n int) *uint256.Int {
 	return &st.data[st.len()-n-1]
@@ -0,0 +1,155 @@
+package bls12381
+
+import (
+	"errors"
+)
+
+// Flags of the first byte of a compressed point (ZCash serialization).
+const (
+	compressedFlag = 0x80
+	infinityFlag   = 0x40
+	largestYFlag   = 0x20
+	flagsMask      = 0xe0
+)
+
+// FromCompressed constructs a new G1 point given its 48 bytes compressed form (ZCash
+// serialization, as used by the KZG commitments and proofs of EIP-4844). It checks the
+// point is on the curve and in the correct subgroup.
+func (g *G1) FromCompressed(in []byte) (*PointG1, error) {
+	if len(in) != 48 {
+		return nil, errors.New("compressed g1 point should be 48 bytes")
+	}
+	xBytes, isInfinity, largestY, err := decodeCompressedFlags(in)
+	if err != nil {
+		return nil, err
+	}
+	if isInfinity {
+		return g.Zero(), nil
+	}
+	x, err := fromBytes(xBytes)
+	if err != nil {
+		return nil, err
+	}
+	// y^2 = x^3 + b
+	y, y2 := new(fe), new(fe)
+	square(y2, x)
+	mul(y2, y2, x)
+	add(y2, y2, b)
+	if !sqrt(y, y2) {
+		return nil, errors.New("point is not on curve")
+	}
+	if isLexicographicallyLargest(y) != largestY {
+		neg(y, y)
+	}
+	p := &PointG1{*x, *y, *new(fe).one()}
+	if !g.InCorrectSubgroup(p) {
+		return nil, errors.New("point is not in correct subgroup")
+	}
+	return p, nil
+}
+
+// ToCompressed serializes a G1 point into its 48 bytes compressed form.
+func (g *G1) ToCompressed(p *PointG1) []byte {
+	out := make([]byte, 48)
+	if g.IsZero(p) {
+		out[0] = compressedFlag | infinityFlag
+		return out
+	}
+	a := g.Affine(new(PointG1).Set(p))
+	copy(out, toBytes(&a[0]))
+	out[0] |= compressedFlag
+	if isLexicographicallyLargest(&a[1]) {
+		out[0] |= largestYFlag
+	}
+	return out
+}
+
+// FromCompressed constructs a new G2 point given its 96 bytes compressed form (ZCash
+// serialization, as used by the KZG trusted setup of EIP-4844). It checks the point is on
+// the curve and in the correct subgroup.
+func (g *G2) FromCompressed(in []byte) (*PointG2, error) {
+	if len(in) != 96 {
+		return nil, errors.New("compressed g2 point should be 96 bytes")
+	}
+	xBytes, isInfinity, largestY, err := decodeCompressedFlags(in)
+	if err != nil {
+		return nil, err
+	}
+	if isInfinity {
+		return g.Zero(), nil
+	}
+	x, err := g.f.fromBytes(xBytes)
+	if err != nil {
+		return nil, err
+	}
+	// y^2 = x^3 + b2
+	y, y2 := new(fe2), new(fe2)
+	g.f.square(y2, x)
+	g.f.mul(y2, y2, x)
+	g.f.add(y2, y2, b2)
+	if !g.f.sqrt(y, y2) {
+		return nil, errors.New("point is not on curve")
+	}
+	if isLexicographicallyLargest2(y) != largestY {
+		g.f.neg(y, y)
+	}
+	p := &PointG2{*x, *y, *new(fe2).one()}
+	if !g.InCorrectSubgroup(p) {
+		return nil, errors.New("point is not in correct subgroup")
+	}
+	return p, nil
+}
+
+// ToCompressed serializes a G2 point into its 96 bytes compressed form.
+func (g *G2) ToCompressed(p *PointG2) []byte {
+	out := make([]byte, 96)
+	if g.IsZero(p) {
+		out[0] = compressedFlag | infinityFlag
+		return out
+	}
+	a := g.Affine(new(PointG2).Set(p))
+	copy(out, g.f.toBytes(&a[0]))
+	out[0] |= compressedFlag
+	if isLexicographicallyLargest2(&a[1]) {
+		out[0] |= largestYFlag
+	}
+	return out
+}
+
+// decodeCompressedFlags returns the x coordinate bytes of a compressed point without the
+// flags, along with the infinity and the y sign flags.
+func decodeCompressedFlags(in []byte) (x []byte, isInfinity bool, largestY bool, err error) {
+	flags := in[0] & flagsMask
+	if flags&compressedFlag == 0 {
+		return nil, false, false, errors.New("point is not in compressed form")
+	}
+	x = make([]byte, len(in))
+	copy(x, in)
+	x[0] &^= flagsMask
+	if flags&infinityFlag != 0 {
+		if flags&largestYFlag != 0 {
+			return nil, false, false, errors.New("point at infinity cannot have the y sign flag")
+		}
+		for _, b := range x {
+			if b != 0 {
+				return nil, false, false, errors.New("point at infinity must have a zero x coordinate")
+			}
+		}
+		return nil, true, false, nil
+	}
+	return x, false, flags&largestYFlag != 0, nil
+}
+
+// isLexicographicallyLargest returns true if e is larger than its negation.
+func isLexicographicallyLargest(e *fe) bool {
+	return toBig(e).Cmp(pMinus1Over2) > 0
+}
+
+// isLexicographicallyLargest2 returns true if e is larger than its negation, comparing the
+// c1 coefficients and then the c0 ones.
+func isLexicographicallyLargest2(e *fe2) bool {
+	if !e[1].isZero() {
+		return isLexicographicallyLargest(&e[1])
+	}
+	return isLexicographicallyLargest(&e[0])
+}
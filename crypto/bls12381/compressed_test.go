@@ -0,0 +1,61 @@
+package bls12381
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestG1Compressed(t *testing.T) {
+	g := NewG1()
+	for i := int64(0); i < 8; i++ {
+		p := g.MulScalar(g.New(), g.One(), big.NewInt(i*1000003))
+		compressed := g.ToCompressed(p)
+		decoded, err := g.FromCompressed(compressed)
+		if err != nil {
+			t.Fatalf("decoding compressed point %d: %v", i, err)
+		}
+		if !g.Equal(p, decoded) {
+			t.Fatalf("compressed point %d round trip mismatch", i)
+		}
+	}
+
+	// The generator as serialized by the ZCash specification
+	generator := common.FromHex("97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb")
+	if compressed := g.ToCompressed(g.One()); !bytes.Equal(compressed, generator) {
+		t.Errorf("compressed generator mismatch: have %x, want %x", compressed, generator)
+	}
+
+	for _, invalid := range [][]byte{
+		generator[1:],
+		append([]byte{generator[0] &^ compressedFlag}, generator[1:]...),
+		append([]byte{compressedFlag | infinityFlag | largestYFlag}, make([]byte, 47)...),
+		append([]byte{compressedFlag | infinityFlag}, append(make([]byte, 46), 1)...),
+	} {
+		if _, err := g.FromCompressed(invalid); err == nil {
+			t.Errorf("decoding invalid compressed point %x should have failed", invalid)
+		}
+	}
+}
+
+func TestG2Compressed(t *testing.T) {
+	g := NewG2()
+	for i := int64(0); i < 8; i++ {
+		p := g.MulScalar(g.New(), g.One(), big.NewInt(i*1000003))
+		decoded, err := g.FromCompressed(g.ToCompressed(p))
+		if err != nil {
+			t.Fatalf("decoding compressed point %d: %v", i, err)
+		}
+		if !g.Equal(p, decoded) {
+			t.Fatalf("compressed point %d round trip mismatch", i)
+		}
+	}
+
+	// The generator as serialized by the ZCash specification
+	generator := common.FromHex("93e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8")
+	if compressed := g.ToCompressed(g.One()); !bytes.Equal(compressed, generator) {
+		t.Errorf("compressed generator mismatch: have %x, want %x", compressed, generator)
+	}
+}
@@ -0,0 +1,147 @@
+// Package kzg4844 implements the verification of the KZG proofs of EIP-4844 (point
+// evaluation) on top of the BLS12-381 implementation of the crypto/bls12381 package.
+package kzg4844
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto/bls12381"
+)
+
+const (
+	// FieldElementsPerBlob is the number of field elements of a blob.
+	FieldElementsPerBlob = 4096
+
+	// VersionedHashVersionKZG is the version byte of the versioned hash of a KZG commitment.
+	VersionedHashVersionKZG = 0x01
+)
+
+// BLSModulus is the order of the BLS12-381 scalar field, points and claims must be lower.
+var BLSModulus = bls12381.NewG1().Q()
+
+// Commitment is a serialized commitment to a polynomial (compressed G1 point).
+type Commitment [48]byte
+
+// Proof is a serialized KZG proof (compressed G1 point).
+type Proof [48]byte
+
+// Point is a big-endian serialized field element at which a polynomial is evaluated.
+type Point [32]byte
+
+// Claim is a big-endian serialized field element, the claimed evaluation of a polynomial.
+type Claim [32]byte
+
+var (
+	// ErrNoTrustedSetup is returned when verifying a proof without a trusted setup loaded.
+	ErrNoTrustedSetup = errors.New("kzg trusted setup not loaded")
+
+	errInvalidProof = errors.New("invalid kzg proof")
+)
+
+// mainnetTrustedSetupTauG2 is the compressed [τ]G2 point of the trusted setup of the KZG
+// ceremony used by mainnet, the second G2 monomial point of its `trusted_setup.json`.
+var mainnetTrustedSetupTauG2 = common.FromHex("0xb5bfd7dd8cdeb128843bc287230af38926187075cbfbefa81009a2ce615ac53d2914e5870cb452d2afaaab24f3499f72185cbfee53492714734429b7b38608e23926c911cceceac9a36851477ba4c60b087041de621000edc98edada20c1def2")
+
+// trustedSetupTauG2 holds the [τ]G2 point (*bls12381.PointG2) of the trusted setup, the
+// mainnet one unless another one is loaded.
+var trustedSetupTauG2 atomic.Value
+
+func init() {
+	if err := LoadTrustedSetup(mainnetTrustedSetupTauG2); err != nil {
+		panic(fmt.Errorf("mainnet trusted setup: %w", err))
+	}
+}
+
+// LoadTrustedSetup loads the trusted setup from its compressed [τ]G2 point, the only point
+// of the setup required to verify proofs.
+func LoadTrustedSetup(tauG2 []byte) error {
+	point, err := bls12381.NewG2().FromCompressed(tauG2)
+	if err != nil {
+		return fmt.Errorf("invalid trusted setup [τ]G2 point: %w", err)
+	}
+
+	trustedSetupTauG2.Store(point)
+	return nil
+}
+
+// LoadTrustedSetupFile loads the trusted setup from the JSON file of the KZG ceremony, its
+// second G2 monomial point being [τ]G2.
+func LoadTrustedSetupFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read trusted setup: %w", err)
+	}
+
+	var setup struct {
+		G2Monomial []hexutil.Bytes `json:"g2_monomial"`
+		SetupG2    []hexutil.Bytes `json:"setup_G2"`
+	}
+	if err := json.Unmarshal(content, &setup); err != nil {
+		return fmt.Errorf("decode trusted setup %q: %w", path, err)
+	}
+
+	points := setup.G2Monomial
+	if len(points) == 0 {
+		points = setup.SetupG2
+	}
+	if len(points) < 2 {
+		return fmt.Errorf("trusted setup %q has %d G2 points, expected at least 2", path, len(points))
+	}
+
+	return LoadTrustedSetup(points[1])
+}
+
+// CalcBlobHashV1 returns the versioned hash of a commitment, its SHA256 hash with the first
+// byte replaced by `VersionedHashVersionKZG`.
+func CalcBlobHashV1(commitment Commitment) common.Hash {
+	hash := sha256.Sum256(commitment[:])
+	hash[0] = VersionedHashVersionKZG
+	return hash
+}
+
+// VerifyProof verifies that the polynomial committed to by `commitment` evaluates to
+// `claim` at `point`, that is e(commitment - [claim]G1, G2) == e(proof, [τ]G2 - [point]G2).
+func VerifyProof(commitment Commitment, point Point, claim Claim, proof Proof) error {
+	tauG2, _ := trustedSetupTauG2.Load().(*bls12381.PointG2)
+	if tauG2 == nil {
+		return ErrNoTrustedSetup
+	}
+
+	z, y := new(big.Int).SetBytes(point[:]), new(big.Int).SetBytes(claim[:])
+	if z.Cmp(BLSModulus) >= 0 {
+		return errors.New("point is not a field element")
+	}
+	if y.Cmp(BLSModulus) >= 0 {
+		return errors.New("claim is not a field element")
+	}
+
+	g1, g2 := bls12381.NewG1(), bls12381.NewG2()
+	commitmentPoint, err := g1.FromCompressed(commitment[:])
+	if err != nil {
+		return fmt.Errorf("invalid commitment: %w", err)
+	}
+	proofPoint, err := g1.FromCompressed(proof[:])
+	if err != nil {
+		return fmt.Errorf("invalid proof: %w", err)
+	}
+
+	commitmentMinusY := g1.Sub(g1.New(), commitmentPoint, g1.MulScalar(g1.New(), g1.One(), y))
+	tauMinusZ := g2.Sub(g2.New(), tauG2, g2.MulScalar(g2.New(), g2.One(), z))
+
+	engine := bls12381.NewPairingEngine()
+	engine.AddPair(commitmentMinusY, g2.One())
+	engine.AddPairInv(proofPoint, tauMinusZ)
+	if !engine.Check() {
+		return errInvalidProof
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package kzg4844
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/bls12381"
+)
+
+// linearProof returns, for a trusted setup with the secret `tau`, the commitment to the
+// polynomial f(x) = a + b*x, its evaluation at z and the proof of that evaluation (the
+// quotient (f(x) - f(z)) / (x - z) being the constant b).
+func linearProof(tau, a, b, z *big.Int) (tauG2 []byte, commitment Commitment, point Point, claim Claim, proof Proof) {
+	g1, g2 := bls12381.NewG1(), bls12381.NewG2()
+
+	fTau := new(big.Int).Mod(new(big.Int).Add(a, new(big.Int).Mul(b, tau)), BLSModulus)
+	fZ := new(big.Int).Mod(new(big.Int).Add(a, new(big.Int).Mul(b, z)), BLSModulus)
+
+	tauG2 = g2.ToCompressed(g2.MulScalar(g2.New(), g2.One(), tau))
+	copy(commitment[:], g1.ToCompressed(g1.MulScalar(g1.New(), g1.One(), fTau)))
+	copy(proof[:], g1.ToCompressed(g1.MulScalar(g1.New(), g1.One(), b)))
+	z.FillBytes(point[:])
+	fZ.FillBytes(claim[:])
+	return
+}
+
+func loadedTrustedSetup() *bls12381.PointG2 {
+	tauG2, _ := trustedSetupTauG2.Load().(*bls12381.PointG2)
+	return tauG2
+}
+
+func restoreTrustedSetup(tauG2 *bls12381.PointG2) {
+	trustedSetupTauG2.Store(tauG2)
+}
+
+func TestVerifyProof(t *testing.T) {
+	defer restoreTrustedSetup(loadedTrustedSetup())
+
+	tauG2, commitment, point, claim, proof := linearProof(big.NewInt(0x1337), big.NewInt(42), big.NewInt(7), big.NewInt(3))
+
+	restoreTrustedSetup(nil)
+	if err := VerifyProof(commitment, point, claim, proof); err != ErrNoTrustedSetup {
+		t.Fatalf("verifying without trusted setup error mismatch: have %v, want %v", err, ErrNoTrustedSetup)
+	}
+
+	if err := LoadTrustedSetup(tauG2); err != nil {
+		t.Fatalf("loading trusted setup: %v", err)
+	}
+	if err := VerifyProof(commitment, point, claim, proof); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+
+	wrongClaim := claim
+	wrongClaim[31]++
+	if err := VerifyProof(commitment, point, wrongClaim, proof); err == nil {
+		t.Errorf("proof of a wrong claim accepted")
+	}
+
+	var outOfField Point
+	BLSModulus.FillBytes(outOfField[:])
+	if err := VerifyProof(commitment, outOfField, claim, proof); err == nil {
+		t.Errorf("point out of the field accepted")
+	}
+}
+
+func TestLoadTrustedSetupFile(t *testing.T) {
+	defer restoreTrustedSetup(loadedTrustedSetup())
+
+	tauG2, commitment, point, claim, proof := linearProof(big.NewInt(0x4844), big.NewInt(1), big.NewInt(2), big.NewInt(5))
+	g2One := bls12381.NewG2().ToCompressed(bls12381.NewG2().One())
+
+	path := filepath.Join(t.TempDir(), "trusted_setup.json")
+	content := `{"g1_monomial": [], "g2_monomial": ["` + common.Bytes2Hex(g2One) + `", "0x` + common.Bytes2Hex(tauG2) + `"]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadTrustedSetupFile(path); err == nil {
+		t.Fatalf("loading a trusted setup with a non prefixed hex point should have failed")
+	}
+
+	content = `{"g2_monomial": ["0x` + common.Bytes2Hex(g2One) + `", "0x` + common.Bytes2Hex(tauG2) + `"]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTrustedSetupFile(path); err != nil {
+		t.Fatalf("loading trusted setup file: %v", err)
+	}
+	if err := VerifyProof(commitment, point, claim, proof); err != nil {
+		t.Errorf("valid proof rejected: %v", err)
+	}
+}
+
+func TestCalcBlobHashV1(t *testing.T) {
+	hash := CalcBlobHashV1(Commitment{})
+	if hash[0] != VersionedHashVersionKZG {
+		t.Errorf("versioned hash version mismatch: have %d, want %d", hash[0], VersionedHashVersionKZG)
+	}
+}
@@ -0,0 +1,378 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// PublicFirehoseAPI provides an API to access the Firehose related information of the node.
+type PublicFirehoseAPI struct {
+	e *Ethereum
+}
+
+// NewPublicFirehoseAPI creates a new Firehose API.
+func NewPublicFirehoseAPI(e *Ethereum) *PublicFirehoseAPI {
+	return &PublicFirehoseAPI{e}
+}
+
+// BackfillStatus returns the progress of the backfill of the blocks emitted as non-executed
+// blocks while fast or snap syncing, nil if there was no such blocks.
+func (api *PublicFirehoseAPI) BackfillStatus() *core.FirehoseBackfillStatus {
+	return api.e.BlockChain().FirehoseBackfillStatus()
+}
+
+// SinkStatus returns the current state of the throttling applied to the Firehose output,
+// the backlog waiting to be written and the state of the rate limit, see
+// `--firehose-sink-throttle-threshold` and `--firehose-sink-rate-limit`.
+func (api *PublicFirehoseAPI) SinkStatus() *firehose.SinkStatus {
+	return firehose.CurrentSinkStatus()
+}
+
+// GetBlock returns the Firehose block emitted for the block with the given number or hash,
+// framed by its `BLOCK_BEGIN` and `BLOCK_END` markers, nil if it's not stored (anymore). Only
+// the blocks of the most recent heights are stored, see `--firehose-block-store-retention`,
+// and never the ones emitted in segments, see `--firehose-block-segment-size`.
+func (api *PublicFirehoseAPI) GetBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	if firehose.BlockStoreRetention == 0 {
+		return nil, errors.New("firehose block store is disabled, see --firehose-block-store-retention")
+	}
+
+	header, err := api.e.APIBackend.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil || err != nil {
+		return nil, err
+	}
+
+	return api.e.BlockChain().FirehoseBlock(header.Hash()), nil
+}
+
+// FirehoseCall locates a call, made by or to the address looked up, in the Firehose block
+// emitted for a canonical block, see `GetCallsByAddress`.
+type FirehoseCall struct {
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	CallIndex        hexutil.Uint64 `json:"callIndex"`
+	Ordinal          hexutil.Uint64 `json:"ordinal"`
+}
+
+// GetCallsByAddress returns the calls, made by or to `address`, of the canonical blocks from
+// `fromBlock` up to `toBlock` included, in their execution order. Unlike logs, it covers the
+// internal transactions of the address. Only the blocks emitted while the call index is
+// enabled are indexed, see `--firehose-call-index`.
+func (api *PublicFirehoseAPI) GetCallsByAddress(ctx context.Context, address common.Address, fromBlock rpc.BlockNumber, toBlock rpc.BlockNumber) ([]*FirehoseCall, error) {
+	if !firehose.CallIndexEnabled {
+		return nil, errors.New("firehose call index is disabled, see --firehose-call-index")
+	}
+
+	head := api.e.BlockChain().CurrentBlock().NumberU64()
+	from, to := resolveFirehoseBlockNumber(fromBlock, head), resolveFirehoseBlockNumber(toBlock, head)
+	if from > to {
+		return nil, fmt.Errorf("invalid block range, from block %d is above to block %d", from, to)
+	}
+
+	calls := []*FirehoseCall{}
+	for _, call := range api.e.BlockChain().FirehoseCallsByAddress(address, from, to) {
+		calls = append(calls, &FirehoseCall{
+			BlockNumber:      hexutil.Uint64(call.BlockNumber),
+			BlockHash:        call.BlockHash,
+			TransactionIndex: hexutil.Uint64(call.TxIndex),
+			TransactionHash:  call.TxHash,
+			CallIndex:        hexutil.Uint64(call.CallIndex),
+			Ordinal:          hexutil.Uint64(call.Ordinal),
+		})
+	}
+
+	return calls, nil
+}
+
+// FirehoseTransfer is a value transfer, sent or received by the address looked up, in the
+// Firehose block emitted for a canonical block, see `GetTransfers`. Rewards have no sender,
+// block and uncle rewards have no transaction.
+type FirehoseTransfer struct {
+	BlockNumber      hexutil.Uint64  `json:"blockNumber"`
+	BlockHash        common.Hash     `json:"blockHash"`
+	TransactionIndex *hexutil.Uint64 `json:"transactionIndex,omitempty"`
+	TransactionHash  *common.Hash    `json:"transactionHash,omitempty"`
+	Kind             string          `json:"kind"`
+	From             *common.Address `json:"from,omitempty"`
+	To               common.Address  `json:"to"`
+	Value            *hexutil.Big    `json:"value"`
+	Ordinal          hexutil.Uint64  `json:"ordinal"`
+}
+
+// GetTransfers returns the value transfers, sent or received by `address`, of the canonical
+// blocks from `fromBlock` up to `toBlock` included, in their execution order: value sent along
+// calls, including internal ones, self-destructed balances and rewards. Only the blocks emitted
+// while the transfer index is enabled are indexed, see `--firehose-transfer-index`.
+func (api *PublicFirehoseAPI) GetTransfers(ctx context.Context, address common.Address, fromBlock rpc.BlockNumber, toBlock rpc.BlockNumber) ([]*FirehoseTransfer, error) {
+	if !firehose.TransferIndexEnabled {
+		return nil, errors.New("firehose transfer index is disabled, see --firehose-transfer-index")
+	}
+
+	head := api.e.BlockChain().CurrentBlock().NumberU64()
+	from, to := resolveFirehoseBlockNumber(fromBlock, head), resolveFirehoseBlockNumber(toBlock, head)
+	if from > to {
+		return nil, fmt.Errorf("invalid block range, from block %d is above to block %d", from, to)
+	}
+
+	transfers := []*FirehoseTransfer{}
+	for _, transfer := range api.e.BlockChain().FirehoseTransfersByAddress(address, from, to) {
+		result := &FirehoseTransfer{
+			BlockNumber: hexutil.Uint64(transfer.BlockNumber),
+			BlockHash:   transfer.BlockHash,
+			Kind:        transfer.Kind,
+			To:          transfer.To,
+			Value:       (*hexutil.Big)(transfer.Value),
+			Ordinal:     hexutil.Uint64(transfer.Ordinal),
+		}
+		if transfer.TxHash != (common.Hash{}) {
+			txIndex, txHash := hexutil.Uint64(transfer.TxIndex), transfer.TxHash
+			result.TransactionIndex, result.TransactionHash = &txIndex, &txHash
+		}
+		if transfer.Kind != string(firehose.TransferKindReward) {
+			from := transfer.From
+			result.From = &from
+		}
+		transfers = append(transfers, result)
+	}
+
+	return transfers, nil
+}
+
+// PrivateFirehoseAPI provides the Firehose API reserved to local tooling, served on the IPC
+// endpoint and only on HTTP or WebSocket when explicitly enabled.
+type PrivateFirehoseAPI struct {
+	e *Ethereum
+}
+
+// NewPrivateFirehoseAPI creates a new private Firehose API.
+func NewPrivateFirehoseAPI(e *Ethereum) *PrivateFirehoseAPI {
+	return &PrivateFirehoseAPI{e}
+}
+
+// firehoseStreamBuffer is the count of sink writes buffered for a stream subscriber before
+// it's considered lagging and its subscription is ended.
+const firehoseStreamBuffer = 4096
+
+// FirehoseStreamAPI provides the raw Firehose stream to local tooling, it's served only on the
+// IPC endpoint, never on HTTP or WebSocket whatever the modules enabled on them.
+type FirehoseStreamAPI struct {
+	e *Ethereum
+}
+
+// NewFirehoseStreamAPI creates a new Firehose stream API.
+func NewFirehoseStreamAPI(e *Ethereum) *FirehoseStreamAPI {
+	return &FirehoseStreamAPI{e}
+}
+
+// Stream subscribes to the raw Firehose stream, each notification being a chunk of the bytes
+// written to standard output, exactly in the console format, from now on. The stream starts
+// wherever the sink is, consumers must skip the records up to the first `BLOCK_BEGIN` marker.
+// The subscription ends if the subscriber lags behind by more than 4096 sink writes.
+//
+// When `fromBlock` is given, the canonical blocks from it up to the head are sent first, the
+// live stream starting only once they are. Only the blocks still held by the block store can
+// be sent, see `--firehose-block-store-retention`, the ones emitted in segments are not stored
+// and are skipped. A block emitted while the live stream starts may be received twice.
+func (api *FirehoseStreamAPI) Stream(ctx context.Context, fromBlock *rpc.BlockNumber) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if !firehose.Enabled {
+		return nil, errors.New("firehose is disabled")
+	}
+	if fromBlock != nil && firehose.BlockStoreRetention == 0 {
+		return nil, errors.New("firehose block store is disabled, see --firehose-block-store-retention")
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		// The stored blocks are sent before the sink is tapped, the live chunks would pile up in
+		// the tap meanwhile. The blocks emitted in between are sent from the store once the tap
+		// is opened, blocks are stored before being emitted.
+		var next uint64
+		if fromBlock != nil {
+			next = resolveFirehoseBlockNumber(*fromBlock, api.e.BlockChain().CurrentBlock().NumberU64())
+			for last := api.lastStoredBlock(); next <= last; last = api.lastStoredBlock() {
+				if next = api.sendStoredBlocks(notifier, rpcSub, next, last); next == 0 {
+					return
+				}
+			}
+		}
+
+		tap := firehose.TapSink(firehoseStreamBuffer)
+		defer tap.Close()
+
+		if fromBlock != nil {
+			if api.sendStoredBlocks(notifier, rpcSub, next, api.lastStoredBlock()) == 0 {
+				return
+			}
+		}
+
+		for {
+			select {
+			case chunk, open := <-tap.Chunks():
+				if !open {
+					log.Warn("Firehose stream subscription ended", "id", rpcSub.ID, "err", tap.Err())
+					return
+				}
+				if err := notifier.Notify(rpcSub.ID, string(chunk)); err != nil {
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// sendStoredBlocks sends to the subscriber the stored canonical blocks from `from` up to `to`
+// included, read one at a time as they are sent. It returns the number of the block following
+// `to`, 0 if the subscription ended.
+func (api *FirehoseStreamAPI) sendStoredBlocks(notifier *rpc.Notifier, rpcSub *rpc.Subscription, from uint64, to uint64) uint64 {
+	bc := api.e.BlockChain()
+	for number := from; number <= to; number++ {
+		select {
+		case <-rpcSub.Err():
+			return 0
+		case <-notifier.Closed():
+			return 0
+		default:
+		}
+
+		block := bc.FirehoseBlock(bc.GetCanonicalHash(number))
+		if block == nil {
+			continue
+		}
+		if err := notifier.Notify(rpcSub.ID, string(block)); err != nil {
+			return 0
+		}
+	}
+
+	return to + 1
+}
+
+// lastStoredBlock returns the number of the highest canonical block that can be held by the
+// block store, the highest of the chain head and the last block emitted in the order of the
+// chain, the latter being above the head while non-executed blocks are emitted.
+func (api *FirehoseStreamAPI) lastStoredBlock() uint64 {
+	head := api.e.BlockChain().CurrentBlock().NumberU64()
+	if last := firehose.SyncContext().LastEmittedBlock(); last != nil && last.Number > head {
+		return last.Number
+	}
+
+	return head
+}
+
+const (
+	// firehoseVerifyRangeLimit is the maximum amount of blocks re-executed by a single
+	// `VerifyRange` call.
+	firehoseVerifyRangeLimit = 1024
+
+	// defaultFirehoseVerifyReexec is the amount of blocks re-executed to regenerate the parent
+	// state of the blocks verified by `VerifyRange` when it's not available anymore.
+	defaultFirehoseVerifyReexec = uint64(128)
+)
+
+// FirehoseBlockVerification is the outcome of the re-execution of a block, see `VerifyRange`.
+type FirehoseBlockVerification struct {
+	BlockNumber       hexutil.Uint64 `json:"blockNumber"`
+	BlockHash         common.Hash    `json:"blockHash"`
+	Passed            bool           `json:"passed"`
+	ReceiptsRoot      common.Hash    `json:"receiptsRoot"`
+	ReceiptsRootMatch bool           `json:"receiptsRootMatch"`
+	LogsBloomMatch    bool           `json:"logsBloomMatch"`
+	Error             string         `json:"error,omitempty"`
+}
+
+// VerifyRange re-executes the canonical blocks from `fromBlock` up to `toBlock` included and
+// verifies, for each of them, the receipts root and the logs bloom recomputed out of the
+// receipts against the ones of the stored header, returning a pass or fail report per block.
+// It's an online spot check of the data the Firehose blocks are emitted from, limited to
+// 1024 blocks. The parent state of `fromBlock` is regenerated if it's not available anymore.
+//
+// The verification stops at the first block that cannot be executed, its report holding the
+// error, the state of the blocks after it cannot be derived.
+func (api *PrivateFirehoseAPI) VerifyRange(ctx context.Context, fromBlock rpc.BlockNumber, toBlock rpc.BlockNumber) ([]*FirehoseBlockVerification, error) {
+	bc := api.e.BlockChain()
+	head := bc.CurrentBlock().NumberU64()
+	from, to := resolveFirehoseBlockNumber(fromBlock, head), resolveFirehoseBlockNumber(toBlock, head)
+	if from > to {
+		return nil, fmt.Errorf("invalid block range, from block %d is above to block %d", from, to)
+	}
+	if from == 0 {
+		return nil, errors.New("invalid block range, the genesis block cannot be re-executed")
+	}
+	if to-from >= firehoseVerifyRangeLimit {
+		return nil, fmt.Errorf("invalid block range, %d blocks requested while at most %d can be verified at once", to-from+1, firehoseVerifyRangeLimit)
+	}
+
+	parent := bc.GetBlockByNumber(from - 1)
+	if parent == nil {
+		return nil, fmt.Errorf("block #%d not found", from-1)
+	}
+	statedb, release, err := api.e.stateAtBlock(parent, defaultFirehoseVerifyReexec)
+	if err != nil {
+		return nil, fmt.Errorf("parent state of block #%d: %w", from, err)
+	}
+	defer release()
+
+	verifications := []*FirehoseBlockVerification{}
+	for number := from; number <= to; number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+
+		verification := &FirehoseBlockVerification{BlockNumber: hexutil.Uint64(number), BlockHash: block.Hash()}
+		verifications = append(verifications, verification)
+
+		receipts, _, _, err := bc.Processor().Process(block, statedb, vm.Config{}, firehose.NoOpContext)
+		if err != nil {
+			verification.Error = err.Error()
+			break
+		}
+		// Finalised so the next block is executed on top of this one
+		statedb.IntermediateRoot(bc.Config().IsEIP158(block.Number()))
+
+		verification.ReceiptsRoot = types.DeriveSha(receipts, trie.NewStackTrie(nil))
+		verification.ReceiptsRootMatch = verification.ReceiptsRoot == block.ReceiptHash()
+		verification.LogsBloomMatch = types.CreateBloom(receipts) == block.Bloom()
+		verification.Passed = verification.ReceiptsRootMatch && verification.LogsBloomMatch
+		if !verification.Passed {
+			log.Warn("Firehose verification of block failed", "number", number, "hash", block.Hash(), "receipts_root_match", verification.ReceiptsRootMatch, "logs_bloom_match", verification.LogsBloomMatch)
+		}
+	}
+
+	return verifications, nil
+}
+
+// resolveFirehoseBlockNumber resolves the special block numbers (latest, pending) to `head`.
+func resolveFirehoseBlockNumber(number rpc.BlockNumber, head uint64) uint64 {
+	if number < 0 {
+		return head
+	}
+
+	return uint64(number)
+}
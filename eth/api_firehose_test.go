@@ -0,0 +1,131 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestFirehoseVerifyRange(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddr: {Balance: big.NewInt(1000000000000000000)}},
+	}).MustCommit(db)
+
+	signer := types.LatestSigner(params.TestChainConfig)
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 3, func(i int, gen *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(testAddr), common.Address{0xaa}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen.AddTx(tx)
+	})
+
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+
+	api := NewPrivateFirehoseAPI(&Ethereum{blockchain: chain, chainDb: db})
+
+	verifications, err := api.VerifyRange(context.Background(), 1, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to verify range: %v", err)
+	}
+	if len(verifications) != 3 {
+		t.Fatalf("verifications count mismatch: have %d, want 3", len(verifications))
+	}
+	for i, verification := range verifications {
+		block := blocks[i]
+		if !verification.Passed || verification.BlockHash != block.Hash() || verification.ReceiptsRoot != block.ReceiptHash() {
+			t.Errorf("block #%d verification mismatch: have %+v", block.NumberU64(), verification)
+		}
+	}
+
+	// A stored header whose receipts root and bloom do not match the execution fails
+	header := blocks[2].Header()
+	header.ReceiptHash, header.Bloom = common.Hash{0x01}, types.Bloom{0x01}
+	tampered := blocks[2].WithSeal(header)
+	rawdb.WriteBlock(db, tampered)
+	rawdb.WriteCanonicalHash(db, tampered.Hash(), tampered.NumberU64())
+
+	verifications, err = api.VerifyRange(context.Background(), 2, 3)
+	if err != nil {
+		t.Fatalf("failed to verify range: %v", err)
+	}
+	if len(verifications) != 2 || !verifications[0].Passed {
+		t.Fatalf("verifications mismatch: have %+v", verifications)
+	}
+	if have := verifications[1]; have.Passed || have.ReceiptsRootMatch || have.LogsBloomMatch || have.ReceiptsRoot != blocks[2].ReceiptHash() {
+		t.Errorf("tampered block verification mismatch: have %+v", have)
+	}
+
+	if _, err := api.VerifyRange(context.Background(), 0, 1); err == nil {
+		t.Error("expected an error verifying the genesis block")
+	}
+	if _, err := api.VerifyRange(context.Background(), 3, 1); err == nil {
+		t.Error("expected an error verifying an inverted range")
+	}
+}
+
+func TestFirehoseStream_FromBlock(t *testing.T) {
+	defer func(enabled bool, retention uint64) {
+		firehose.Enabled, firehose.BlockStoreRetention = enabled, retention
+	}(firehose.Enabled, firehose.BlockStoreRetention)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 3, nil)
+
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	firehose.Enabled, firehose.BlockStoreRetention = true, 16
+	for _, block := range blocks {
+		rawdb.WriteFirehoseBlock(db, block.NumberU64(), block.Hash(), firehose.FramedBlock(block.NumberU64(), block.Hash(), []byte("FIRE END_BLOCK\n")))
+	}
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("firehosestream", NewFirehoseStreamAPI(&Ethereum{blockchain: chain, chainDb: db})); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	chunks := make(chan string, 8)
+	sub, err := client.Subscribe(context.Background(), "firehosestream", chunks, "stream", "0x2")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// The stored blocks from the requested one up to the head are sent first, in order
+	for _, block := range blocks[1:] {
+		select {
+		case chunk := <-chunks:
+			if want := string(rawdb.ReadFirehoseBlock(db, block.NumberU64(), block.Hash())); chunk != want {
+				t.Errorf("block #%d mismatch: have %q, want %q", block.NumberU64(), chunk, want)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("block #%d not received", block.NumberU64())
+		}
+	}
+}
@@ -45,6 +45,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/miner"
@@ -93,6 +94,8 @@ type Ethereum struct {
 
 	p2pServer *p2p.Server
 
+	firehosePendingEffects *firehosePendingEffects // Speculative execution of pending transactions, nil if disabled
+
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 }
 
@@ -149,6 +152,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		bloomIndexer:      core.NewBloomIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms),
 		p2pServer:         stack.Server(),
 	}
+	if engine, ok := eth.engine.(*clique.Clique); ok && config.Miner.DeterministicTime {
+		engine.DeterministicTime()
+	}
 
 	bcVersion := rawdb.ReadDatabaseVersion(chainDb)
 	var dbVer = "<nil>"
@@ -334,6 +340,20 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   s.netRPCService,
 			Public:    true,
+		}, {
+			Namespace: "firehose",
+			Version:   "1.0",
+			Service:   NewPublicFirehoseAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "firehose",
+			Version:   "1.0",
+			Service:   NewPrivateFirehoseAPI(s),
+		}, {
+			Namespace: "firehosestream",
+			Version:   "1.0",
+			Service:   NewFirehoseStreamAPI(s),
+			IPCOnly:   true,
 		},
 	}...)
 }
@@ -531,6 +551,10 @@ func (s *Ethereum) Start() error {
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	if firehose.PendingEffectsEnabled && firehose.MaybeSyncContext().Enabled() {
+		s.firehosePendingEffects = newFirehosePendingEffects(s.blockchain, s.txPool)
+	}
 	return nil
 }
 
@@ -543,6 +567,9 @@ func (s *Ethereum) Stop() error {
 	// Then stop everything else.
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
+	if s.firehosePendingEffects != nil {
+		s.firehosePendingEffects.stop()
+	}
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.blockchain.Stop()
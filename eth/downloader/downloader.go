@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
@@ -1646,6 +1647,9 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 						log.Warn("Invalid header encountered", "number", chunk[n].Number, "hash", chunk[n].Hash(), "parent", chunk[n].ParentHash, "err", err)
 						return fmt.Errorf("%w: %v", errInvalidChain, err)
 					}
+					if firehose.BlockProgressEnabled {
+						firehose.SyncContext().RecordSyncProgress(firehose.SyncStageHeaders, chunk[len(chunk)-1])
+					}
 					// All verifications passed, track all headers within the alloted limits
 					if mode == FastSync {
 						head := chunk[len(chunk)-1].Number.Uint64()
@@ -1731,6 +1735,9 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 	for i, result := range results {
 		blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
 	}
+	if firehose.BlockProgressEnabled {
+		firehose.SyncContext().RecordSyncProgress(firehose.SyncStageBodies, last)
+	}
 	if index, err := d.blockchain.InsertChain(blocks); err != nil {
 		if index < len(results) {
 			log.Debug("Downloaded item processing failed", "number", results[index].Header.Number, "hash", results[index].Header.Hash(), "err", err)
@@ -1923,6 +1930,9 @@ func (d *Downloader) commitFastSyncData(results []*fetchResult, stateSync *state
 		log.Debug("Downloaded item processing failed", "number", results[index].Header.Number, "hash", results[index].Header.Hash(), "err", err)
 		return fmt.Errorf("%w: %v", errInvalidChain, err)
 	}
+	if firehose.BlockProgressEnabled {
+		firehose.SyncContext().RecordSyncProgress(firehose.SyncStageReceipts, last)
+	}
 	return nil
 }
 
@@ -1934,6 +1944,9 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 	if _, err := d.blockchain.InsertReceiptChain([]*types.Block{block}, []types.Receipts{result.Receipts}, d.ancientLimit); err != nil {
 		return err
 	}
+	if firehose.BlockProgressEnabled {
+		firehose.SyncContext().RecordSyncProgress(firehose.SyncStageReceipts, block.Header())
+	}
 	if err := d.blockchain.FastSyncCommitHead(block.Hash()); err != nil {
 		return err
 	}
@@ -230,6 +230,7 @@ func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, co
 			DatasetsInMem:    config.DatasetsInMem,
 			DatasetsOnDisk:   config.DatasetsOnDisk,
 			DatasetsLockMmap: config.DatasetsLockMmap,
+			RewardScheme:     config.RewardScheme,
 		}, notify, noverify)
 		engine.SetThreads(-1) // Disable CPU mining
 		return engine
@@ -0,0 +1,134 @@
+package eth
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// firehosePendingEffectsQueue is the number of batches of transactions waiting to be
+// speculatively executed, batches received while the queue is full are skipped.
+const firehosePendingEffectsQueue = 64
+
+// firehosePendingEffects speculatively executes the transactions promoted as executable in
+// the transaction pool on top of the current head and emits their predicted effects.
+type firehosePendingEffects struct {
+	chain *core.BlockChain
+	sink  *firehose.Context // Context the predicted effects are recorded to
+
+	txsCh  chan core.NewTxsEvent
+	txsSub event.Subscription
+	work   chan []*types.Transaction
+	wg     sync.WaitGroup
+}
+
+func newFirehosePendingEffects(chain *core.BlockChain, txpool *core.TxPool) *firehosePendingEffects {
+	p := &firehosePendingEffects{
+		chain: chain,
+		sink:  firehose.MaybeSyncContext(),
+		txsCh: make(chan core.NewTxsEvent, txChanSize),
+		work:  make(chan []*types.Transaction, firehosePendingEffectsQueue),
+	}
+	p.txsSub = txpool.SubscribeNewTxsEvent(p.txsCh)
+
+	p.wg.Add(2)
+	go p.dispatchLoop()
+	go p.executeLoop()
+
+	return p
+}
+
+func (p *firehosePendingEffects) stop() {
+	p.txsSub.Unsubscribe()
+	p.wg.Wait()
+}
+
+// dispatchLoop queues the new executable transactions, it never blocks the transaction pool
+// while transactions are being executed.
+func (p *firehosePendingEffects) dispatchLoop() {
+	defer p.wg.Done()
+	defer close(p.work)
+
+	for {
+		select {
+		case event := <-p.txsCh:
+			select {
+			case p.work <- event.Txs:
+			default:
+				log.Debug("Firehose pending effects lagging behind, skipping transactions", "count", len(event.Txs))
+			}
+
+		case <-p.txsSub.Err():
+			return
+		}
+	}
+}
+
+func (p *firehosePendingEffects) executeLoop() {
+	defer p.wg.Done()
+
+	for txs := range p.work {
+		p.execute(txs)
+	}
+}
+
+// execute executes `txs` on top of the current head, the transactions of a sender are applied
+// one after the other while the ones of different senders are executed independently.
+func (p *firehosePendingEffects) execute(txs []*types.Transaction) {
+	head := p.chain.CurrentBlock()
+	headState, err := p.chain.StateAt(head.Root())
+	if err != nil {
+		log.Debug("Firehose pending effects head state not available", "number", head.NumberU64(), "hash", head.Hash(), "err", err)
+		return
+	}
+
+	var (
+		config = p.chain.Config()
+		header = &types.Header{
+			ParentHash: head.Hash(),
+			Coinbase:   head.Coinbase(),
+			Number:     new(big.Int).Add(head.Number(), common.Big1),
+			GasLimit:   head.GasLimit(),
+			Time:       head.Time() + 1,
+			Difficulty: head.Difficulty(),
+		}
+		signer = types.MakeSigner(config, header.Number)
+		states = make(map[common.Address]*state.StateDB)
+	)
+
+	for _, tx := range txs {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+
+		statedb := states[from]
+		if statedb == nil {
+			statedb = headState.Copy()
+			states[from] = statedb
+		}
+		statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+		execution := firehose.NewSpeculativeExecution()
+		execution.StartTransaction(tx, from)
+
+		receipt, err := core.ApplyTransaction(config, p.chain, &header.Coinbase, new(core.GasPool).AddGas(header.GasLimit), statedb, header, tx, new(uint64), vm.Config{}, execution.Context())
+		if err != nil {
+			execution.Release()
+			log.Debug("Firehose pending transaction not executable on top of head", "hash", tx.Hash(), "number", head.NumberU64(), "err", err)
+			continue
+		}
+
+		execution.EndTransaction(receipt)
+		p.sink.RecordPendingEffects(head.Header(), execution.Trace())
+		execution.Release()
+	}
+}
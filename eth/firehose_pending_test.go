@@ -0,0 +1,70 @@
+package eth
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/decode"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestFirehosePendingEffects(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	(&core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddr: {Balance: big.NewInt(1000000000000000000)}},
+	}).MustCommit(db)
+
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	// Enabled only once the chain is created, the genesis block is not emitted
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		signer    = types.LatestSigner(params.TestChainConfig)
+		recipient = common.Address{0xaa}
+		txs       []*types.Transaction
+	)
+	for _, nonce := range []uint64{0, 1, 5} {
+		tx, err := types.SignTx(types.NewTransaction(nonce, recipient, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	effects := &firehosePendingEffects{chain: chain, sink: firehose.NewBlockContextWithBuffer(buffer)}
+	effects.execute(txs)
+
+	// The transactions of a sender are applied one after the other, the one with a nonce gap
+	// cannot be executed on top of the head
+	decoder := decode.NewDecoder(buffer)
+	for _, tx := range txs[:2] {
+		element, err := decoder.Next()
+		if err != nil {
+			t.Fatalf("failed to decode effects of %s: %v", tx.Hash(), err)
+		}
+
+		have := element.(*decode.PendingEffects)
+		if have.Hash != tx.Hash() || have.HeadHash != chain.Genesis().Hash() || have.Status != "succeeded" || have.GasUsed != params.TxGas {
+			t.Errorf("effects mismatch: have %+v", have)
+		}
+		if len(have.Transfers) != 1 || have.Transfers[0].From != testAddr || have.Transfers[0].To != recipient || have.Transfers[0].Value.Cmp(big.NewInt(1000)) != 0 {
+			t.Errorf("transfers mismatch: have %+v", have.Transfers)
+		}
+	}
+	if element, err := decoder.Next(); err == nil {
+		t.Errorf("unexpected effects %+v", element)
+	}
+}
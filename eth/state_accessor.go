@@ -207,24 +207,24 @@ func (eth *Ethereum) stateAtTransaction(block *types.Block, txIndex int, reexec
 		return nil, vm.BlockContext{}, statedb, release, nil
 	}
 	// Recompute transactions up to the target index.
-	signer := types.MakeSigner(eth.blockchain.Config(), block.Number())
+	var (
+		signer  = types.MakeSigner(eth.blockchain.Config(), block.Number())
+		context = core.NewEVMBlockContext(block.Header(), eth.blockchain, nil)
+		vmenv   = vm.NewEVM(context, vm.TxContext{}, statedb, eth.blockchain.Config(), vm.Config{})
+	)
 	for idx, tx := range block.Transactions() {
 		// Assemble the transaction call message and return if the requested offset
-		msg, _ := tx.AsMessage(signer)
-		txContext := core.NewEVMTxContext(msg)
-		context := core.NewEVMBlockContext(block.Header(), eth.blockchain, nil)
 		if idx == txIndex {
+			msg, _ := tx.AsMessage(signer)
 			return msg, context, statedb, release, nil
 		}
-		// Not yet the searched for transaction, execute on top of the current state
-		vmenv := vm.NewEVM(context, txContext, statedb, eth.blockchain.Config(), vm.Config{}, firehoseContext)
-		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+		// Not yet the searched for transaction, execute on top of the current state, the
+		// modifications are finalised as they are when the block is processed
+		statedb.Prepare(tx.Hash(), block.Hash(), idx)
+		if _, _, _, err := core.ApplyTransactionWithResult(vmenv, eth.blockchain.Config(), eth.blockchain, nil, new(core.GasPool).AddGas(tx.Gas()), statedb, block.Header(), tx, new(uint64), firehoseContext, false); err != nil {
 			release()
 			return nil, vm.BlockContext{}, nil, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
 		}
-		// Ensure any modifications are committed to the state
-		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
-		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
 	}
 	release()
 	return nil, vm.BlockContext{}, nil, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, block.Hash())
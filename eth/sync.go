@@ -303,10 +303,13 @@ func (cs *chainSyncer) startSync(op *chainSyncOp) {
 
 // doSync synchronizes the local blockchain with a remote peer.
 func (h *handler) doSync(op *chainSyncOp) error {
-	if firehose.Enabled {
+	if firehose.Enabled && !firehose.NonExecutedBlocksEnabled {
 		// If Firehose is enabled, we force the mode to be a FullSync mode to ensure we correctly
 		// process all transactions. It should probably be adapter so that speculative execution
 		// node could use fast sync which is not the case here.
+		//
+		// When non-executed blocks are enabled, the operator accepts that the blocks synced
+		// without execution are emitted out of their header and receipts only.
 		if op.mode != downloader.FullSync {
 			log.Warn("Firehose changed syncing mode to 'full', it is required for proper extraction of the data when enabling Firehose instrumentation through --firehose-enabled", "old", op.mode, "new", downloader.FullSync)
 		}
@@ -347,6 +350,8 @@ func (h *handler) doSync(op *chainSyncOp) error {
 		log.Info("Snap sync complete, auto disabling")
 		atomic.StoreUint32(&h.snapSync, 0)
 	}
+	// Blocks are not imported without execution anymore, re-emit the non-executed ones, if any
+	h.chain.StartFirehoseBackfill()
 	// If we've successfully finished a sync cycle and passed any required checkpoint,
 	// enable accepting transactions from the network.
 	head := h.chain.CurrentBlock()
@@ -0,0 +1,139 @@
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/decode"
+)
+
+// callTracerFrame is a single call of the `callTracer` result, only the fields that are
+// compared against the Firehose call tree are decoded.
+type callTracerFrame struct {
+	Type    string            `json:"type"`
+	From    common.Address    `json:"from"`
+	To      common.Address    `json:"to"`
+	Value   *hexutil.Big      `json:"value,omitempty"`
+	Gas     *hexutil.Uint64   `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64   `json:"gasUsed,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Calls   []callTracerFrame `json:"calls,omitempty"`
+}
+
+// describeFirehoseCall identifies `call` in the description of a discrepancy.
+func describeFirehoseCall(call *decode.Call) string {
+	return fmt.Sprintf("call #%d (%s %s -> %s)", call.Index, call.CallType, call.Caller.Hex(), call.Address.Hex())
+}
+
+// firehoseCallTypes maps the call types of the `callTracer` to the ones emitted by Firehose
+var firehoseCallTypes = map[string]firehose.CallType{
+	"CALL":         firehose.CallTypeCall,
+	"CALLCODE":     firehose.CallTypeCallCode,
+	"DELEGATECALL": firehose.CallTypeDelegate,
+	"STATICCALL":   firehose.CallTypeStatic,
+	"CREATE":       firehose.CallTypeCreate,
+	"CREATE2":      firehose.CallTypeCreate,
+}
+
+// DiffFirehoseCallTrace compares the Firehose output of a single transaction against the
+// result of the built-in `callTracer` run on the same transaction. Call trees, gas numbers
+// and failure/revert statuses are diffed and a description of each discrepancy found is
+// returned, nil when both agree.
+//
+// This is a diagnostic tool meant to catch gas accounting divergences between Firehose data
+// and `debug_traceTransaction`. The `callTracer` does not trace pre-compiled contract calls
+// and reports the transaction gas limit as the root call's gas, those are hence not compared.
+func DiffFirehoseCallTrace(firehoseLog []byte, callTracerResult json.RawMessage) ([]string, error) {
+	trx, err := decode.DecodeTransaction(bytes.NewReader(firehoseLog))
+	if err != nil {
+		return nil, fmt.Errorf("decode firehose log: %w", err)
+	}
+	if len(trx.Calls) == 0 {
+		return nil, fmt.Errorf("firehose log contains no call")
+	}
+
+	frame := new(callTracerFrame)
+	if err := json.Unmarshal(callTracerResult, frame); err != nil {
+		return nil, fmt.Errorf("parse callTracer result: %w", err)
+	}
+
+	// The calls are decoded in their execution order, the root call first
+	children := map[uint64][]*decode.Call{}
+	for _, call := range trx.Calls[1:] {
+		children[call.ParentIndex] = append(children[call.ParentIndex], call)
+	}
+
+	var differences []string
+	diff := func(call *decode.Call, format string, args ...interface{}) {
+		differences = append(differences, describeFirehoseCall(call)+": "+fmt.Sprintf(format, args...))
+	}
+
+	diffFirehoseCall(trx.Calls[0], children, frame, true, diff)
+	return differences, nil
+}
+
+func diffFirehoseCall(call *decode.Call, children map[uint64][]*decode.Call, frame *callTracerFrame, isRoot bool, diff func(call *decode.Call, format string, args ...interface{})) {
+	if expected := firehoseCallTypes[frame.Type]; expected != call.CallType {
+		diff(call, "call type differs, firehose %s, callTracer %s", call.CallType, frame.Type)
+	}
+
+	if call.Caller != frame.From {
+		diff(call, "caller differs, firehose %s, callTracer %s", call.Caller.Hex(), frame.From.Hex())
+	}
+
+	// A failed creation has no address in the `callTracer` result
+	if call.Address != frame.To && !(call.CallType == firehose.CallTypeCreate && frame.To == (common.Address{})) {
+		diff(call, "callee differs, firehose %s, callTracer %s", call.Address.Hex(), frame.To.Hex())
+	}
+
+	if frame.Value != nil && call.Value.Cmp(frame.Value.ToInt()) != 0 {
+		diff(call, "value differs, firehose %s, callTracer %s", call.Value, frame.Value.ToInt())
+	}
+
+	if !isRoot && frame.Gas != nil && call.GasLimit != uint64(*frame.Gas) {
+		diff(call, "gas limit differs, firehose %d, callTracer %d", call.GasLimit, uint64(*frame.Gas))
+	}
+
+	if gasUsed := call.GasLimit - call.GasLeft; frame.GasUsed != nil && gasUsed != uint64(*frame.GasUsed) {
+		diff(call, "gas used differs, firehose %d, callTracer %d", gasUsed, uint64(*frame.GasUsed))
+	}
+
+	if failed := frame.Error != ""; failed != call.Failed {
+		diff(call, "failure status differs, firehose %t, callTracer %t (%q)", call.Failed, failed, frame.Error)
+	}
+
+	if reverted := frame.Error == vm.ErrExecutionReverted.Error(); reverted && !call.Reverted {
+		diff(call, "revert status differs, firehose %t, callTracer %t", call.Reverted, reverted)
+	}
+
+	calls := make([]*decode.Call, 0, len(children[call.Index]))
+	for _, child := range children[call.Index] {
+		// The `callTracer` does not report the calls to precompiled contracts, both sides use
+		// the precompiles active at the block (`vm.DefaultPrecompiles`)
+		if child.Precompile {
+			continue
+		}
+		calls = append(calls, child)
+	}
+
+	frames := make([]*callTracerFrame, 0, len(frame.Calls))
+	for i := range frame.Calls {
+		if frame.Calls[i].Type == "SELFDESTRUCT" {
+			continue
+		}
+		frames = append(frames, &frame.Calls[i])
+	}
+
+	if len(calls) != len(frames) {
+		diff(call, "sub-calls count differs, firehose %d, callTracer %d", len(calls), len(frames))
+	}
+
+	for i := 0; i < len(calls) && i < len(frames); i++ {
+		diffFirehoseCall(calls[i], children, frames[i], false, diff)
+	}
+}
@@ -310,6 +310,8 @@ type Tracer struct {
 	ctx map[string]interface{} // Transaction context gathered throughout execution
 	err error                  // Error, if one has occurred
 
+	precompiles map[common.Address]vm.PrecompiledContract // Precompiles active at the traced block
+
 	interrupt uint32 // Atomic flag to signal execution interruption
 	reason    error  // Textual reason for the interruption
 }
@@ -402,7 +404,7 @@ func New(code string, txCtx vm.TxContext) (*Tracer, error) {
 		return 1
 	})
 	tracer.vm.PushGlobalGoFunction("isPrecompiled", func(ctx *duktape.Context) int {
-		_, ok := vm.PrecompiledContractsIstanbul[common.BytesToAddress(popSlice(ctx))]
+		_, ok := tracer.precompiles[common.BytesToAddress(popSlice(ctx))]
 		ctx.PushBoolean(ok)
 		return 1
 	})
@@ -552,15 +554,17 @@ func (jst *Tracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost
 			// Compute intrinsic gas
 			isHomestead := env.ChainConfig().IsHomestead(env.Context.BlockNumber)
 			isIstanbul := env.ChainConfig().IsIstanbul(env.Context.BlockNumber)
+			isShanghai := env.ChainConfig().IsShanghai(env.Context.BlockNumber)
 			var input []byte
 			if data, ok := jst.ctx["input"].([]byte); ok {
 				input = data
 			}
-			intrinsicGas, err := core.IntrinsicGas(input, nil, jst.ctx["type"] == "CREATE", isHomestead, isIstanbul)
+			intrinsicGas, err := core.IntrinsicGas(input, nil, nil, jst.ctx["type"] == "CREATE", isHomestead, isIstanbul, isShanghai)
 			if err != nil {
 				return err
 			}
 			jst.ctx["intrinsicGas"] = intrinsicGas
+			jst.precompiles, _ = vm.DefaultPrecompiles(env.ChainConfig().Rules(env.Context.BlockNumber))
 			jst.inited = true
 		}
 		// If tracing was interrupted, set the error and stop
@@ -60,7 +60,7 @@ func testCtx() *vmContext {
 }
 
 func runTrace(tracer *Tracer, vmctx *vmContext) (json.RawMessage, error) {
-	env := vm.NewEVM(vmctx.blockCtx, vmctx.txCtx, &dummyStatedb{}, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer}, firehose.NoOpContext)
+	env := vm.NewEVM(vmctx.blockCtx, vmctx.txCtx, &dummyStatedb{}, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
 	var (
 		startGas uint64 = 10000
 		value           = big.NewInt(0)
@@ -150,7 +150,7 @@ func TestHaltBetweenSteps(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	env := vm.NewEVM(vm.BlockContext{BlockNumber: big.NewInt(1)}, vm.TxContext{}, &dummyStatedb{}, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer}, firehose.NoOpContext)
+	env := vm.NewEVM(vm.BlockContext{BlockNumber: big.NewInt(1)}, vm.TxContext{}, &dummyStatedb{}, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
 	contract := vm.NewContract(&account{}, &account{}, big.NewInt(0), 0, firehose.NoOpContext)
 
 	tracer.CaptureState(env, 0, 0, 0, 0, nil, nil, nil, contract, 0, nil)
@@ -162,3 +162,31 @@ func TestHaltBetweenSteps(t *testing.T) {
 		t.Errorf("Expected timeout error, got %v", err)
 	}
 }
+
+func TestIsPrecompiled(t *testing.T) {
+	code := "{step: function() {}, fault: function() {}, result: function() { return isPrecompiled(toAddress('0x000000000000000000000000000000000000000a')); }}"
+
+	cancun := *params.TestChainConfig
+	cancun.CancunBlock = big.NewInt(0)
+
+	for _, tt := range []struct {
+		config *params.ChainConfig
+		want   string
+	}{
+		{params.TestChainConfig, "false"},
+		{&cancun, "true"},
+	} {
+		vmctx := testCtx()
+		tracer, err := New(code, vmctx.txCtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := vm.NewEVM(vmctx.blockCtx, vmctx.txCtx, &dummyStatedb{}, tt.config, vm.Config{Debug: true, Tracer: tracer})
+		contract := vm.NewContract(&account{}, &account{}, big.NewInt(0), 0, firehose.NoOpContext)
+		tracer.CaptureState(env, 0, 0, 0, 0, nil, nil, nil, contract, 0, nil)
+
+		if have, err := tracer.GetResult(); err != nil || string(have) != tt.want {
+			t.Errorf("cancun block %v: point evaluation precompiled mismatch: have %s (%v), want %s", tt.config.CancunBlock, have, err, tt.want)
+		}
+	}
+}
@@ -178,7 +178,7 @@ func TestPrestateTracerCreate2(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create call tracer: %v", err)
 	}
-	evm := vm.NewEVM(context, txContext, statedb, params.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer}, firehose.NoOpContext)
+	evm := vm.NewEVM(context, txContext, statedb, params.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
 
 	msg, err := tx.AsMessage(signer)
 	if err != nil {
@@ -253,7 +253,7 @@ func TestCallTracer(t *testing.T) {
 			if err != nil {
 				t.Fatalf("failed to create call tracer: %v", err)
 			}
-			evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer}, firehose.NoOpContext)
+			evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
 
 			msg, err := tx.AsMessage(signer)
 			if err != nil {
@@ -284,6 +284,103 @@ func TestCallTracer(t *testing.T) {
 	}
 }
 
+// Iterates over all the input-output datasets in the tracer test harness and
+// diffs the Firehose output against the call tracer result of the same transaction.
+func TestFirehoseCallTracerDiff(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), "call_tracer_") {
+			continue
+		}
+		file := file // capture range variable
+		t.Run(camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json")), func(t *testing.T) {
+			blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+			if err != nil {
+				t.Fatalf("failed to read testcase: %v", err)
+			}
+			test := new(callTracerTest)
+			if err := json.Unmarshal(blob, test); err != nil {
+				t.Fatalf("failed to parse testcase: %v", err)
+			}
+			tx := new(types.Transaction)
+			if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+				t.Fatalf("failed to parse testcase input: %v", err)
+			}
+			signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+			origin, _ := signer.Sender(tx)
+			txContext := vm.TxContext{
+				Origin:   origin,
+				GasPrice: tx.GasPrice(),
+			}
+			context := vm.BlockContext{
+				CanTransfer: core.CanTransfer,
+				Transfer:    core.Transfer,
+				Coinbase:    test.Context.Miner,
+				BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+				Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+				Difficulty:  (*big.Int)(test.Context.Difficulty),
+				GasLimit:    uint64(test.Context.GasLimit),
+			}
+			_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
+
+			// Run the transaction with both the call tracer and the Firehose instrumentation
+			tracer, err := New("callTracer", txContext)
+			if err != nil {
+				t.Fatalf("failed to create call tracer: %v", err)
+			}
+			firehoseContext := firehose.NewSpeculativeExecutionContext(64 * 1024)
+			firehoseContext.StartTransaction(tx, 0, nil)
+			firehoseContext.RecordTrxFrom(origin)
+			txContext.FirehoseContext = firehoseContext
+
+			evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+
+			msg, err := tx.AsMessage(signer)
+			if err != nil {
+				t.Fatalf("failed to prepare transaction for tracing: %v", err)
+			}
+			st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()), firehoseContext)
+			result, err := st.TransitionDb()
+			if err != nil {
+				t.Fatalf("failed to execute transaction: %v", err)
+			}
+			firehoseContext.EndTransaction(&types.Receipt{GasUsed: result.UsedGas, CumulativeGasUsed: result.UsedGas})
+
+			res, err := tracer.GetResult()
+			if err != nil {
+				t.Fatalf("failed to retrieve trace result: %v", err)
+			}
+
+			differences, err := DiffFirehoseCallTrace(firehoseContext.FirehoseLog(), res)
+			if err != nil {
+				t.Fatalf("failed to diff firehose output against call tracer: %v", err)
+			}
+			if len(differences) > 0 {
+				t.Fatalf("firehose output differs from call tracer:\n  - %s", strings.Join(differences, "\n  - "))
+			}
+
+			// Ensure a divergence is actually surfaced by tampering with the call tracer result
+			tampered := new(callTracerFrame)
+			if err := json.Unmarshal(res, tampered); err != nil {
+				t.Fatalf("failed to unmarshal trace result: %v", err)
+			}
+			tamperedGasUsed := *tampered.GasUsed + 1
+			tampered.GasUsed = &tamperedGasUsed
+			tamperedRes, _ := json.Marshal(tampered)
+
+			if differences, _ := DiffFirehoseCallTrace(firehoseContext.FirehoseLog(), tamperedRes); len(differences) != 1 {
+				t.Fatalf("expected a single gas used difference against tampered call tracer result, got %v", differences)
+			}
+		})
+	}
+}
+
 // jsonEqual is similar to reflect.DeepEqual, but does a 'bounce' via json prior to
 // comparison
 func jsonEqual(x, y interface{}) bool {
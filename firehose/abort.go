@@ -0,0 +1,50 @@
+package firehose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// AbortTransaction must be called when the instrumentation panicked while `txContext` was
+// recording transaction `txHash` at index `txIndex` of `block`, `reason` being the recovered
+// panic value and `stack` the stack trace of the panic.
+//
+// A `TRX_ABORT <blockNumber> <blockHash> <txIndex> <txHash> <partialTrace> <message>` record
+// is emitted straight to "stdout", the block being processed is never flushed. `partialTrace`
+// is the hex encoded records of the transaction written up to the panic, from its
+// `BEGIN_APPLY_TRX`, and `message` the JSON encoded panic message. A diagnostic dump
+// containing the stack trace is also written to disk.
+//
+// The returned error must be used to fail the block import, the transaction context must
+// be reset (or released) before being re-used.
+func AbortTransaction(txContext *Context, block *types.Block, txIndex int, txHash common.Hash, reason interface{}, stack []byte) error {
+	message := fmt.Sprint(reason)
+	partialTrace := txContext.FirehoseLog()
+
+	if ctx := MaybeSyncContext(); ctx != nil {
+		ctx.printer.Print("TRX_ABORT",
+			Uint64(block.NumberU64()),
+			Hash(block.Hash()),
+			Uint(uint(txIndex)),
+			Hash(txHash),
+			Hex(partialTrace),
+			JSON(message),
+		)
+	}
+
+	dumpFile := filepath.Join(os.TempDir(), fmt.Sprintf("firehose_trx_abort_%d_%d.log", block.NumberU64(), txIndex))
+	dump := fmt.Sprintf("Block #%d (%s), transaction #%d (%s)\n\nPanic: %s\n\n%s\nPartial trace:\n%s", block.NumberU64(), block.Hash(), txIndex, txHash, message, stack, partialTrace)
+	if err := os.WriteFile(dumpFile, []byte(dump), 0644); err != nil {
+		log.Warn("Unable to write Firehose transaction abort diagnostic dump", "file", dumpFile, "err", err)
+	}
+
+	log.Error("Firehose instrumentation panicked while applying transaction, aborting block import",
+		"number", block.NumberU64(), "hash", block.Hash(), "tx_index", txIndex, "tx", txHash, "panic", message, "dump", dumpFile)
+
+	return fmt.Errorf("firehose instrumentation panicked on tx %d [%v]: %s", txIndex, txHash.Hex(), message)
+}
@@ -0,0 +1,96 @@
+package firehose
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// accessRecorder accumulates the accounts and storage slots accessed while executing, in the
+// order they were first accessed, see `Context.EnableAccessRecording`.
+type accessRecorder struct {
+	list     types.AccessList
+	accounts map[common.Address]int
+	slots    map[common.Address]map[common.Hash]struct{}
+}
+
+func newAccessRecorder() *accessRecorder {
+	return &accessRecorder{
+		accounts: make(map[common.Address]int),
+		slots:    make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+func (r *accessRecorder) addAccount(addr common.Address) int {
+	if index, found := r.accounts[addr]; found {
+		return index
+	}
+
+	r.list = append(r.list, types.AccessTuple{Address: addr, StorageKeys: []common.Hash{}})
+	r.accounts[addr] = len(r.list) - 1
+	r.slots[addr] = make(map[common.Hash]struct{})
+
+	return len(r.list) - 1
+}
+
+func (r *accessRecorder) addSlot(addr common.Address, slot common.Hash) {
+	index := r.addAccount(addr)
+	if _, found := r.slots[addr][slot]; found {
+		return
+	}
+
+	r.slots[addr][slot] = struct{}{}
+	r.list[index].StorageKeys = append(r.list[index].StorageKeys, slot)
+}
+
+// EnableAccessRecording makes the context record, in memory only, the accounts and storage
+// slots accessed for the first time (cold accesses in EIP-2929 terms) while executing, the
+// accesses are retrieved as an access list through `AccessList`. Nothing is emitted, the
+// recording works even when Firehose is disabled.
+//
+// The accounts and slots warm from the start of a transaction (sender, recipient, precompiled
+// contracts and the transaction's own access list) are never accessed cold, they are not
+// recorded. Accesses are only tracked from the Berlin fork on.
+func (ctx *Context) EnableAccessRecording() {
+	if ctx.accesses == nil {
+		ctx.accesses = newAccessRecorder()
+	}
+}
+
+// AccessRecordingEnabled returns true when the context records the state accesses, see
+// `EnableAccessRecording`.
+func (ctx *Context) AccessRecordingEnabled() bool {
+	return ctx != nil && ctx.accesses != nil
+}
+
+// RecordAccountAccess records the first access to the account `addr`.
+func (ctx *Context) RecordAccountAccess(addr common.Address) {
+	if !ctx.AccessRecordingEnabled() {
+		return
+	}
+
+	ctx.accesses.addAccount(addr)
+}
+
+// RecordStorageAccess records the first access to the storage `slot` of the account `addr`.
+func (ctx *Context) RecordStorageAccess(addr common.Address, slot common.Hash) {
+	if !ctx.AccessRecordingEnabled() {
+		return
+	}
+
+	ctx.accesses.addSlot(addr, slot)
+}
+
+// AccessList returns the deduplicated accounts and storage slots recorded so far, in the
+// order they were first accessed, nil if the access recording is not enabled.
+func (ctx *Context) AccessList() types.AccessList {
+	if !ctx.AccessRecordingEnabled() {
+		return nil
+	}
+
+	list := make(types.AccessList, len(ctx.accesses.list))
+	for i, tuple := range ctx.accesses.list {
+		list[i] = types.AccessTuple{Address: tuple.Address, StorageKeys: append([]common.Hash{}, tuple.StorageKeys...)}
+	}
+
+	return list
+}
@@ -0,0 +1,53 @@
+package firehose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReportBadBlock must be called when `block`, recorded by `blockContext`, failed processing
+// or consensus validation with `failure`. A diagnostic report holding the failing check, the
+// receipts computed so far and the partially captured trace of the block is written to
+// `BadBlocksDir`, so that a consensus split introduced by the instrumentation can be
+// investigated. The partial trace can be fed to `geth firehose inspect`.
+//
+// It returns the path of the report, empty if it could not be written.
+func ReportBadBlock(blockContext *Context, block *types.Block, receipts types.Receipts, failure error) string {
+	if !blockContext.Enabled() {
+		return ""
+	}
+
+	// The segments already emitted are discarded, the partial trace holds the last one only
+	blockContext.abortSegments()
+
+	dir := BadBlocksDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "Block #%d (%s)\n\nFailure: %v\n\nReceipts:\n", block.NumberU64(), block.Hash(), failure)
+	for i, receipt := range receipts {
+		fmt.Fprintf(&report, "\t%d: cumulative: %v gas: %v status: %v tx: %s logs: %d state: %x\n",
+			i, receipt.CumulativeGasUsed, receipt.GasUsed, receipt.Status, receipt.TxHash.Hex(), len(receipt.Logs), receipt.PostState)
+	}
+	fmt.Fprintf(&report, "\nPartial trace:\n%s", blockContext.FirehoseLog())
+
+	reportFile := filepath.Join(dir, fmt.Sprintf("firehose_bad_block_%d_%s.log", block.NumberU64(), block.Hash().Hex()))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Warn("Unable to create Firehose bad block diagnostics directory", "dir", dir, "err", err)
+		return ""
+	}
+	if err := os.WriteFile(reportFile, []byte(report.String()), 0644); err != nil {
+		log.Warn("Unable to write Firehose bad block diagnostic report", "file", reportFile, "err", err)
+		return ""
+	}
+
+	log.Error("Firehose captured bad block", "number", block.NumberU64(), "hash", block.Hash(), "report", reportFile)
+	return reportFile
+}
@@ -0,0 +1,36 @@
+package firehose
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportBadBlock(t *testing.T) {
+	defer func(enabled bool, dir string) { Enabled, BadBlocksDir = enabled, dir }(Enabled, BadBlocksDir)
+	Enabled, BadBlocksDir = true, t.TempDir()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: common.Big1})
+	receipts := types.Receipts{{CumulativeGasUsed: 21000, GasUsed: 21000, Status: types.ReceiptStatusSuccessful}}
+
+	ctx := NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.StartBlock(block)
+
+	reportFile := ReportBadBlock(ctx, block, receipts, errors.New("invalid merkle root"))
+	require.NotEmpty(t, reportFile)
+
+	report, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(report), "Failure: invalid merkle root\n")
+	assert.Contains(t, string(report), "0: cumulative: 21000 gas: 21000 status: 1")
+	assert.Contains(t, string(report), "Partial trace:\n"+string(ctx.FirehoseLog()))
+	assert.Contains(t, string(report), "FIRE BEGIN_BLOCK 1")
+
+	assert.Empty(t, ReportBadBlock(NoOpContext, block, receipts, errors.New("invalid merkle root")))
+}
@@ -0,0 +1,156 @@
+package firehose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// ErrBufferMemoryCapExceeded is the error of a block whose Firehose data does not fit in the
+// buffers under `BufferMemoryCap`, the block is not emitted and its import fails.
+var ErrBufferMemoryCapExceeded = errors.New("firehose buffer memory cap exceeded")
+
+const (
+	// bufferTuningWindow is the amount of most recent payloads whose largest size determines
+	// the capacity a buffer is shrunk to
+	bufferTuningWindow = 256
+
+	// bufferShrinkFactor is the factor by which the capacity of a buffer must exceed the
+	// largest recent payload for the buffer to be shrunk
+	bufferShrinkFactor = 4
+
+	// bufferMinSize is the capacity below which a buffer is never shrunk
+	bufferMinSize = 64 * 1024
+)
+
+// tunedBuffer is one of the buffers re-used across blocks or transactions, `BlockSyncBuffer`
+// and `TxSyncBuffer`. It's grown as needed while accumulating a payload, without ever
+// exceeding its share of `BufferMemoryCap`, and shrunk once the payloads it accumulated over
+// the last `bufferTuningWindow` ones are all much smaller than its capacity, a pathological
+// block does not keep its memory retained for the rest of the process.
+//
+// tunedBuffer is **not** thread-safe, like the buffer it tunes.
+type tunedBuffer struct {
+	buffer *bytes.Buffer
+	name   string
+	gauge  metrics.Gauge
+
+	sizes    [bufferTuningWindow]int
+	next     int
+	observed int
+}
+
+// syncBuffers are the tuned buffers allocated by `AllocateBuffers`, nil until then.
+var syncBuffers struct {
+	block *tunedBuffer
+	tx    *tunedBuffer
+}
+
+// tunedBufferOf returns the tuned buffer wrapping `buffer`, nil if it's not one of the
+// buffers allocated by `AllocateBuffers`.
+func tunedBufferOf(buffer *bytes.Buffer) *tunedBuffer {
+	for _, tuned := range []*tunedBuffer{syncBuffers.block, syncBuffers.tx} {
+		if tuned != nil && tuned.buffer == buffer {
+			return tuned
+		}
+	}
+
+	return nil
+}
+
+// otherCapacity returns the capacity of the other tuned buffers, the memory not available to
+// this one under `BufferMemoryCap`.
+func (b *tunedBuffer) otherCapacity() (capacity int) {
+	for _, tuned := range []*tunedBuffer{syncBuffers.block, syncBuffers.tx} {
+		if tuned != nil && tuned != b {
+			capacity += tuned.buffer.Cap()
+		}
+	}
+
+	return capacity
+}
+
+// reserve makes room in the buffer for `size` more bytes, growing it within the memory left
+// by the other buffers under `BufferMemoryCap`. An error wrapping `ErrBufferMemoryCapExceeded`
+// is returned if the bytes do not fit, the buffer is left untouched.
+func (b *tunedBuffer) reserve(size int) error {
+	buffer := b.buffer
+	if BufferMemoryCap <= 0 || buffer.Cap()-buffer.Len() >= size {
+		return nil
+	}
+
+	available := BufferMemoryCap - b.otherCapacity()
+	needed := buffer.Len() + size
+	if needed > available {
+		bufferCapExceededCounter.Inc(1)
+		return fmt.Errorf("%w: %s buffer needs %d bytes, %d bytes available out of %d", ErrBufferMemoryCapExceeded, b.name, needed, available, BufferMemoryCap)
+	}
+
+	// Grown like `bytes.Buffer` does, but never above the memory available
+	capacity := 2*buffer.Cap() + size
+	if capacity > available {
+		capacity = available
+	}
+
+	b.replace(append(make([]byte, 0, capacity), buffer.Bytes()...))
+	return nil
+}
+
+// observe records the size of the payload accumulated by the buffer, which must be flushed
+// already, and shrinks the buffer if its capacity is oversized for the recent payloads. The
+// buffer keeps its initial capacity until a full window of payloads was observed.
+func (b *tunedBuffer) observe(size int) {
+	b.sizes[b.next] = size
+	b.next = (b.next + 1) % bufferTuningWindow
+	if b.observed < bufferTuningWindow {
+		b.observed++
+		return
+	}
+
+	largest := 0
+	for _, size := range b.sizes {
+		if size > largest {
+			largest = size
+		}
+	}
+
+	target := 2 * largest
+	if target < bufferMinSize {
+		target = bufferMinSize
+	}
+
+	if b.buffer.Cap() > bufferShrinkFactor*target {
+		b.replace(make([]byte, 0, target))
+	}
+	b.gauge.Update(int64(b.buffer.Cap()))
+}
+
+// replace swaps the storage of the buffer for `storage`, the slices previously returned by
+// the buffer (e.g. a flushed block payload still referenced) are left untouched.
+func (b *tunedBuffer) replace(storage []byte) {
+	*b.buffer = *bytes.NewBuffer(storage)
+}
+
+// newTunedBuffer allocates a buffer of `size` bytes, its capacity is reported by `gauge`.
+func newTunedBuffer(name string, size int, gauge metrics.Gauge) *tunedBuffer {
+	tuned := &tunedBuffer{buffer: bytes.NewBuffer(make([]byte, 0, size)), name: name, gauge: gauge}
+	gauge.Update(int64(size))
+
+	return tuned
+}
+
+// validateBufferSizes returns an error if the initial buffer sizes do not fit under
+// `BufferMemoryCap`.
+func validateBufferSizes() error {
+	if BlockBufferSize < 0 || TxBufferSize < 0 {
+		return fmt.Errorf("firehose buffer sizes must not be negative, block buffer size is %d and transaction buffer size is %d", BlockBufferSize, TxBufferSize)
+	}
+
+	if BufferMemoryCap > 0 && BlockBufferSize+TxBufferSize > BufferMemoryCap {
+		return fmt.Errorf("firehose block buffer size %d and transaction buffer size %d exceed the buffer memory cap %d", BlockBufferSize, TxBufferSize, BufferMemoryCap)
+	}
+
+	return nil
+}
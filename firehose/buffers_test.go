@@ -0,0 +1,106 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withBuffers(t *testing.T, blockSize, txSize, memoryCap int) {
+	t.Helper()
+
+	enabled, buffers, block, tx := Enabled, syncBuffers, BlockSyncBuffer, TxSyncBuffer
+	sizes := [3]int{BlockBufferSize, TxBufferSize, BufferMemoryCap}
+	t.Cleanup(func() {
+		Enabled, syncBuffers, BlockSyncBuffer, TxSyncBuffer = enabled, buffers, block, tx
+		BlockBufferSize, TxBufferSize, BufferMemoryCap = sizes[0], sizes[1], sizes[2]
+	})
+
+	Enabled = true
+	BlockBufferSize, TxBufferSize, BufferMemoryCap = blockSize, txSize, memoryCap
+	require.NoError(t, validateBufferSizes())
+	AllocateBuffers()
+}
+
+func TestTunedBuffer_Shrink(t *testing.T) {
+	withBuffers(t, 1024*1024, 1024, 0)
+
+	tuned := syncBuffers.block
+	for i := 0; i < bufferTuningWindow; i++ {
+		tuned.observe(1000)
+	}
+	assert.Equal(t, 1024*1024, BlockSyncBuffer.Cap(), "the initial capacity is kept until a full window is observed")
+
+	tuned.observe(1000)
+	assert.Equal(t, bufferMinSize, BlockSyncBuffer.Cap())
+	assert.True(t, tuned.buffer == BlockSyncBuffer, "the global buffer must be tuned in place")
+
+	// A capacity fitting the recent payloads is kept
+	tuned.observe(100 * 1024)
+	assert.Equal(t, bufferMinSize, BlockSyncBuffer.Cap())
+}
+
+func TestTunedBuffer_MemoryCap(t *testing.T) {
+	withBuffers(t, 1024, 1024, 4096)
+
+	tuned := syncBuffers.block
+	require.NoError(t, tuned.reserve(2000))
+	assert.Equal(t, 3072, BlockSyncBuffer.Cap(), "the growth is capped by the memory left by the transaction buffer")
+
+	BlockSyncBuffer.Write(make([]byte, 2000))
+	assert.ErrorIs(t, tuned.reserve(1073), ErrBufferMemoryCapExceeded)
+	assert.Equal(t, 2000, BlockSyncBuffer.Len())
+}
+
+func TestTunedBuffer_InvalidSizes(t *testing.T) {
+	defer func(block, tx, memoryCap int) {
+		BlockBufferSize, TxBufferSize, BufferMemoryCap = block, tx, memoryCap
+	}(BlockBufferSize, TxBufferSize, BufferMemoryCap)
+
+	BlockBufferSize, TxBufferSize, BufferMemoryCap = 1024, 1024, 1024
+	assert.EqualError(t, validateBufferSizes(), "firehose block buffer size 1024 and transaction buffer size 1024 exceed the buffer memory cap 1024")
+}
+
+func TestFlushBlock_BufferMemoryCap(t *testing.T) {
+	defer func(enabled, syncEnabled bool, ctx *Context) {
+		Enabled, SyncInstrumentationEnabled, syncContext = enabled, syncEnabled, ctx
+	}(Enabled, SyncInstrumentationEnabled, syncContext)
+
+	withBuffers(t, 1024, 1024, 4096)
+
+	printer := NewToBufferPrinter(1024)
+	Enabled, SyncInstrumentationEnabled, syncContext = true, true, NewContext(printer, false)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+
+	// The transaction data does not fit, the block is not emitted
+	ctx := NewBlockContextWithBuffer(BlockSyncBuffer)
+	ctx.StartBlock(block)
+
+	txContext := AcquireTransactionContextWithBuffer(TxSyncBuffer)
+	defer ReleaseContext(txContext)
+
+	txContext.printer.Print("DATA", strings.Repeat("a", 4096))
+	ctx.FlushTransaction(txContext)
+	ctx.EndBlock(block, nil)
+
+	err := ctx.FlushBlock()
+	assert.ErrorIs(t, err, ErrBufferMemoryCapExceeded)
+	assert.Equal(t, 0, printer.Buffer().Len())
+	assert.Nil(t, syncContext.LastEmittedBlock())
+
+	// The next block fitting in the buffers is emitted
+	ctx = NewBlockContextWithBuffer(BlockSyncBuffer)
+	ctx.StartBlock(block)
+	txContext.printer.Print("DATA", "a")
+	ctx.FlushTransaction(txContext)
+	ctx.EndBlock(block, nil)
+
+	require.NoError(t, ctx.FlushBlock())
+	assert.True(t, bytes.Contains(printer.Buffer().Bytes(), []byte("FIRE DATA a\n")))
+}
@@ -2,6 +2,7 @@ package firehose
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -11,17 +12,44 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"go.uber.org/atomic"
 )
 
 // NoOpContext can be used when no recording should happen for a given code path
 var NoOpContext *Context
 
-var syncContext *Context = NewContext(&DelegateToWriterPrinter{writer: os.Stdout}, false)
+var syncContext *Context = NewContext(NewDelegateToWriterPrinter(sinkContext, os.Stdout), false)
+
+// blockWriteLock serializes the writes of framed blocks with the records emitted concurrently
+// to block processing, like the pending transaction ones, so they never end up in the middle
+// of a block.
+var blockWriteLock sync.Mutex
+
+// lastBlockWrite is the unix time, in nanoseconds, at which the last framed block was
+// written, see `LastBlockWriteTime`.
+var lastBlockWrite = atomic.NewInt64(0)
+
+// sinkContext is the context of all writes to standard output, it's canceled by `CancelSink`.
+var sinkContext, cancelSinkContext = context.WithCancel(context.Background())
+
+// CancelSink aborts any write to standard output currently blocked on a consumer that
+// stopped reading, all subsequent writes fail with `ErrSinkCanceled`. It should be used
+// only when the node is shutting down as the Firehose stream cannot be used anymore
+// after this call.
+//
+// The sink is canceled as a whole, a blocked write cannot be aborted for a single operation
+// the way `EVM.Cancel` aborts an execution: the abandoned write may still complete later on,
+// any write sent after it would then interleave with it.
+func CancelSink() {
+	cancelSinkContext()
+}
 
 // MaybeSyncContext is used when syncing blocks with the network for mindreader consumption, there
 // is always a single active sync context use for the whole syncing process, should not be used
@@ -56,6 +84,8 @@ func NewContext(printer Printer, transactionScopedContext bool) *Context {
 		inBlock:                  atomic.NewBool(false),
 		inTransaction:            atomic.NewBool(false),
 		totalOrderingCounter:     atomic.NewUint64(0),
+		creations:                &contractCreations{},
+		callStack:                NewStack[openedCall](16),
 	}
 
 	ctx.resetBlock()
@@ -75,37 +105,95 @@ type Context struct {
 	transactionScopedContext bool
 	flushTxLock              sync.Mutex
 
+	// Emission state, only tracked by the sync context, see `LastEmittedBlock`
+	emittedLock sync.Mutex
+	lastEmitted *EmittedBlock
+
 	// Block state
 	inBlock              *atomic.Bool
+	blockNumber          uint64
+	blockHash            common.Hash
+	blockOutOfOrder      bool
+	blockStartTime       time.Time
 	blockLogIndex        uint64
 	totalOrderingCounter *atomic.Uint64
+	accounting           *blockAccounting
+	creations            *contractCreations
+	counts               RecordCounts
+	trace                *traceBuilder
+	segment              *segmentedBlock
+	span                 *Span
+
+	// Live state publication, only by the contexts of the block import, see `Probe`
+	probed bool
+
+	// State accesses, recorded only when enabled, see `EnableAccessRecording`
+	accesses *accessRecorder
 
 	// Transaction state
 	inTransaction   *atomic.Bool
+	trxStartTime    time.Time
+	trxGasPrice     *big.Int
 	activeCallIndex string
 	nextCallIndex   uint64
-	callIndexStack  *ExtendedStack
+	callStack       *Stack[openedCall]
+
+	// Gas retained by the caller at the site of the next call, see `RecordCallGasRetention`
+	callSiteGasRetained uint64
+
+	// Scratch space re-used across records to avoid allocations
+	topicsScratch []string
 }
 
 func (ctx *Context) resetBlock() {
 	ctx.inBlock.Store(false)
+	ctx.blockNumber = 0
+	ctx.blockHash = common.Hash{}
+	ctx.blockOutOfOrder = false
+	ctx.blockStartTime = time.Time{}
 	ctx.blockLogIndex = 0
 	ctx.totalOrderingCounter.Store(0)
+
+	ctx.accounting = nil
+	if SelfCheck != SelfCheckOff {
+		ctx.accounting = newBlockAccounting()
+	}
+
+	ctx.creations.resetBlock()
+	ctx.counts = RecordCounts{}
+	ctx.span = nil
+	ctx.probeBlock(false)
+
+	if ctx.trace != nil {
+		ctx.trace.resetBlock()
+	}
 }
 
 func (ctx *Context) resetTransaction() {
 	ctx.inTransaction.Store(false)
+	ctx.trxStartTime = time.Time{}
+	ctx.trxGasPrice = nil
 	ctx.nextCallIndex = 0
 	ctx.activeCallIndex = "0"
-	ctx.callIndexStack = &ExtendedStack{}
-	ctx.callIndexStack.Push(ctx.activeCallIndex)
+	ctx.callStack.Reset()
+	ctx.callStack.Push(openedCall{index: ctx.activeCallIndex})
+	ctx.callSiteGasRetained = 0
+	ctx.creations.resetTransaction()
+	ctx.probeTransaction(-1)
+
+	if ctx.trace != nil {
+		ctx.trace.resetTransaction()
+	}
 }
 
-func (ctx *Context) InitVersion(nodeVersion, dmVersion, variant string) {
+// InitVersion records the versions of the node and of the Firehose protocol along with the
+// `chainConfig` of the chain, nil if it's not known, so that consumers can bootstrap their
+// decoding rules (e.g. the fork activations) out of the stream itself.
+func (ctx *Context) InitVersion(nodeVersion, dmVersion, variant string, chainConfig *params.ChainConfig) {
 	if ctx == nil {
 		return
 	}
-	ctx.printer.Print("INIT", dmVersion, variant, nodeVersion)
+	ctx.printer.Print("INIT", dmVersion, variant, nodeVersion, JSON(chainConfig))
 }
 
 func NewSpeculativeExecutionContext(initialAllocationInBytes int) *Context {
@@ -115,7 +203,54 @@ func NewSpeculativeExecutionContext(initialAllocationInBytes int) *Context {
 // NewBlockContextWithBuffer creates a new block context with a buffer to accumulate the
 // firehose logs. This should be used when tracing a block.
 func NewBlockContextWithBuffer(buffer *bytes.Buffer) *Context {
-	return NewContext(NewToBufferPrinterWithBuffer(buffer), false)
+	ctx := NewContext(NewToBufferPrinterWithBuffer(buffer), false)
+	ctx.probed = syncBuffers.block != nil && buffer == syncBuffers.block.buffer
+
+	return ctx
+}
+
+// transactionContextPool recycles transaction scoped contexts so that the index stack,
+// the ordinal counters and the printer are re-used across transactions instead of being
+// re-allocated every time, see `AcquireTransactionContextWithBuffer`.
+var transactionContextPool = sync.Pool{
+	New: func() interface{} {
+		return NewContext(&ToBufferPrinter{}, true)
+	},
+}
+
+// AcquireTransactionContextWithBuffer works like `NewTransactionContextWithBuffer` but
+// re-uses a previously released context when one is available. The context must be given
+// back through `ReleaseContext` once the caller is done with it.
+func AcquireTransactionContextWithBuffer(buffer *bytes.Buffer) *Context {
+	ctx := transactionContextPool.Get().(*Context)
+
+	// Force a reset to ensure we start with a clean buffer
+	buffer.Reset()
+	printer := ctx.printer.(*ToBufferPrinter)
+	printer.buffer, printer.tuned, printer.err = buffer, tunedBufferOf(buffer), nil
+	ctx.probed = printer.tuned != nil && printer.tuned == syncBuffers.tx
+
+	return ctx
+}
+
+// ReleaseContext resets the context and gives it back to the pool of transaction contexts
+// for future re-use. The context must not be used anymore after this call.
+//
+// Only contexts obtained through `AcquireTransactionContextWithBuffer` should be released.
+func ReleaseContext(ctx *Context) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.Reset()
+	ctx.trace = nil
+	ctx.probed = false
+
+	// We must not retain the buffer, it's owned by the caller that acquired the context
+	printer := ctx.printer.(*ToBufferPrinter)
+	printer.buffer, printer.tuned = nil, nil
+
+	transactionContextPool.Put(ctx)
 }
 
 // NewTransactionContextWithBuffer creates a new transaction context with a buffer to accumulate the
@@ -129,6 +264,49 @@ func (ctx *Context) Enabled() bool {
 	return ctx != nil && Enabled
 }
 
+// EnableBlockTrace makes the context build, alongside the records it emits, the typed trace
+// of the blocks it records so that in-process consumers can use it without parsing the
+// records, see `BlockTrace`.
+//
+// A transaction context flushed in a block context must have the block trace enabled too,
+// its transactions are merged in the block's trace when flushed.
+func (ctx *Context) EnableBlockTrace() {
+	if ctx == nil || ctx.trace != nil {
+		return
+	}
+
+	ctx.trace = &traceBuilder{}
+}
+
+func (ctx *Context) BlockTraceEnabled() bool {
+	return ctx != nil && ctx.trace != nil
+}
+
+// BlockTrace returns the typed trace of the last block recorded up to its `END_BLOCK` record,
+// nil if the block trace is not enabled or if no block was completed since the last block
+// started.
+//
+// The trace is built out of the same data as the emitted records, it follows the same rules
+// as the `decode` package. It must be treated as read-only.
+func (ctx *Context) BlockTrace() *BlockTrace {
+	if ctx == nil || ctx.trace == nil {
+		return nil
+	}
+
+	return ctx.trace.completed
+}
+
+// TransactionTraces returns the typed traces of the transactions recorded up to their
+// `END_APPLY_TRX` record outside of any block, like the ones of a speculative execution
+// context, nil if the block trace is not enabled. They must be treated as read-only.
+func (ctx *Context) TransactionTraces() []*TransactionTrace {
+	if ctx == nil || ctx.trace == nil {
+		return nil
+	}
+
+	return ctx.trace.transactions
+}
+
 func (ctx *Context) FirehoseLog() []byte {
 	if ctx == nil {
 		return nil
@@ -143,26 +321,137 @@ func (ctx *Context) FirehoseLog() []byte {
 
 // Block methods
 
-func (ctx *Context) RecordGenesisBlock(block *types.Block, recordGenesisAlloc func(ctx *Context)) {
+// RecordNonExecutedBlock records `block` which was imported along with its `receipts` without
+// being executed, like the blocks below the pivot of a fast or snap sync. The block is flagged
+// by a `NON_EXECUTED_BLOCK` record, its transactions are recorded out of their receipts only,
+// without any call nor change. Senders are recovered through the signer of `config`.
+func (ctx *Context) RecordNonExecutedBlock(block *types.Block, receipts types.Receipts, config *params.ChainConfig, totalDifficulty *big.Int) error {
 	if ctx == nil {
-		return
+		return nil
 	}
 
-	if ctx.inBlock.Load() {
-		panic("trying to record genesis block while in block context")
+	ctx.StartBlock(block)
+	ctx.printer.Print("NON_EXECUTED_BLOCK", Uint64(block.NumberU64()))
+	ctx.RecordForkActivations(config, block)
+
+	// Nothing was executed, the recorded changes cannot reconcile with the block accounting
+	ctx.accounting = nil
+	if ctx.trace != nil {
+		ctx.trace.block.NonExecuted = true
 	}
 
-	zero := common.Address{}
-	root := block.Root()
+	signer := types.MakeSigner(config, block.Number())
+	cumulativeGasUsed := uint64(0)
+	for i, tx := range block.Transactions() {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return fmt.Errorf("transaction #%d (%s) sender: %w", i, tx.Hash(), err)
+		}
+
+		// Receipts received from the network hold only their consensus fields, the gas
+		// used by the transaction is derived from the cumulative gas used
+		receipt := *receipts[i]
+		receipt.GasUsed = receipt.CumulativeGasUsed - cumulativeGasUsed
+		cumulativeGasUsed = receipt.CumulativeGasUsed
+
+		ctx.StartTransaction(tx, uint(i), nil)
+		ctx.RecordTrxFrom(from)
+		ctx.EndTransaction(&receipt)
+	}
 
-	ctx.StartBlock(block)
-	ctx.StartTransactionRaw(common.Hash{}, &zero, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0)
-	ctx.RecordTrxFrom(zero)
-	recordGenesisAlloc(ctx)
-	ctx.EndTransaction(&types.Receipt{PostState: root[:]})
 	ctx.FinalizeBlock(block)
-	ctx.EndBlock(block, block.Difficulty())
-	ctx.FlushBlock()
+	ctx.EndBlock(block, totalDifficulty)
+
+	return nil
+}
+
+// RecordBackfilledBlock flags the block being recorded as a block that was first emitted as a
+// non-executed block and that is emitted again, fully, once re-executed. Backfilled blocks are
+// emitted while the chain is synced, out of the order of the other blocks.
+func (ctx *Context) RecordBackfilledBlock(block *types.Block) {
+	if ctx == nil {
+		return
+	}
+
+	if !ctx.inBlock.Load() {
+		panic("recording a backfilled block while not in a block scope")
+	}
+
+	ctx.printer.Print("BACKFILLED_BLOCK", Uint64(block.NumberU64()))
+	ctx.blockOutOfOrder = true
+
+	if ctx.trace != nil && ctx.trace.block != nil {
+		ctx.trace.block.Backfilled = true
+	}
+}
+
+// RecordForkActivations records the forks of `config` activated at `block`, the block being
+// recorded, so that consumers can adjust their decoding rules from this block on.
+func (ctx *Context) RecordForkActivations(config *params.ChainConfig, block *types.Block) {
+	if ctx == nil {
+		return
+	}
+
+	if !ctx.inBlock.Load() {
+		panic("recording fork activations while not in a block scope")
+	}
+
+	if ForksActivatedAt == nil {
+		return
+	}
+
+	for _, fork := range ForksActivatedAt(config, block.Number()) {
+		ctx.printer.Print("FORK_ACTIVATION", fork, Uint64(block.NumberU64()), Uint64(block.Time()))
+
+		if ctx.trace != nil && ctx.trace.block != nil {
+			ctx.trace.block.ForkActivations = append(ctx.trace.block.ForkActivations, &ForkActivation{Name: fork, Number: block.NumberU64(), Timestamp: block.Time()})
+		}
+	}
+}
+
+// RecordBlockWitness records the witness of the block being recorded, the trie `nodes` proving,
+// against the parent state root, every account and storage slot accessed while executing the
+// block along with the contract `codes` loaded, enabling its stateless re-execution.
+func (ctx *Context) RecordBlockWitness(nodes [][]byte, codes [][]byte) {
+	if ctx == nil {
+		return
+	}
+
+	if !ctx.inBlock.Load() {
+		panic("recording a block witness while not in a block scope")
+	}
+
+	witness := &BlockWitness{Nodes: make([]hexutil.Bytes, len(nodes)), Codes: make([]hexutil.Bytes, len(codes))}
+	for i, node := range nodes {
+		witness.Nodes[i] = node
+	}
+	for i, code := range codes {
+		witness.Codes[i] = code
+	}
+
+	ctx.printer.Print("BLOCK_WITNESS", JSON(witness))
+
+	if ctx.trace != nil && ctx.trace.block != nil {
+		ctx.trace.block.Witness = witness
+	}
+}
+
+// RecordCliqueSeal records the seal of the block being recorded on Clique (proof-of-authority)
+// chains, sparing consumers from recovering the signer of each block and tracking its votes.
+func (ctx *Context) RecordCliqueSeal(seal *CliqueSeal) {
+	if ctx == nil {
+		return
+	}
+
+	if !ctx.inBlock.Load() {
+		panic("recording a clique seal while not in a block scope")
+	}
+
+	ctx.printer.Print("CLIQUE_SEAL", JSON(seal))
+
+	if ctx.trace != nil && ctx.trace.block != nil {
+		ctx.trace.block.CliqueSeal = seal
+	}
 }
 
 func (ctx *Context) StartBlock(block *types.Block) {
@@ -170,41 +459,299 @@ func (ctx *Context) StartBlock(block *types.Block) {
 		panic("entering a block while already in a block scope")
 	}
 
-	ctx.printer.Print("BEGIN_BLOCK", Uint64(block.NumberU64()))
+	ctx.blockNumber = block.NumberU64()
+	ctx.blockHash = block.Hash()
+	if TimingsEnabled {
+		ctx.blockStartTime = time.Now()
+	}
+	ctx.probeBlock(true)
+
+	header := block.Header()
+	ctx.printer.Print("BEGIN_BLOCK",
+		Uint64(block.NumberU64()),
+		Uint64(header.GasLimit),
+		Uint64(gasTarget(header)),
+		// London fork not active in this branch yet, replace by `header.BaseFee` when it's the case (and remove this comment)
+		".",
+		Hex(header.Bloom[:]),
+	)
+
+	if ctx.trace != nil {
+		ctx.trace.startBlock(header, gasTarget(header))
+	}
 }
 
+// FinalizeBlock records the end of the transactions of `block`, the changes recorded after it
+// being the ones applied to the block itself (e.g. block and uncle rewards). When the block
+// created contracts, they are listed by a `CREATED_CONTRACTS` record, in their creation order,
+// so consumers don't have to derive them out of the calls.
 func (ctx *Context) FinalizeBlock(block *types.Block) {
+	if ctx.inBlock.Load() && len(ctx.creations.created) > 0 {
+		ctx.printer.Print("CREATED_CONTRACTS", JSON(ctx.creations.created))
+
+		if ctx.trace != nil && ctx.trace.block != nil {
+			ctx.trace.block.CreatedContracts = ctx.creations.created
+		}
+	}
+
 	// We must not check if the finalize block is actually in the a block since
 	// when firehose block progress only is enabled, it would hit a panic
 	ctx.printer.Print("FINALIZE_BLOCK", Uint64(block.NumberU64()))
+
+	if ctx.trace != nil && ctx.trace.block != nil {
+		ctx.trace.block.Finalized = true
+	}
+
+	if BlockProgressEnabled && !ctx.inBlock.Load() {
+		// When only block progress is enabled, the finalize block line is the whole block
+		ctx.blockBoundary()
+	}
 }
 
+// EndBlock records the end of `block`, along with its header, its uncles and the chain's
+// `totalDifficulty` at it. The uncles are recorded as complete headers, not only their hash,
+// so that consumers can compute the uncle rewards and check the ommers hash of the header.
 func (ctx *Context) EndBlock(block *types.Block, totalDifficulty *big.Int) {
+	ctx.selfCheckBlock(block)
+
+	duration := elapsedSince(ctx.blockStartTime)
+	counts := ctx.counts
 	ctx.printer.Print("END_BLOCK",
 		Uint64(block.NumberU64()),
 		Uint64(uint64(block.Size())),
+		Uint64(uint64(duration)),
 		JSON(map[string]interface{}{
 			"header":          block.Header(),
 			"uncles":          block.Body().Uncles,
 			"totalDifficulty": (*hexutil.Big)(totalDifficulty),
+			"counts":          &counts,
 		}),
 	)
+
+	if ctx.trace != nil {
+		if ctx.trace.block != nil {
+			ctx.trace.block.Counts = &counts
+		}
+		ctx.trace.endBlock(block, totalDifficulty, duration)
+	}
+}
+
+// gasTarget returns the gas the block is targeting, around which the base fee is adjusted
+// from the London fork (EIP-1559) on, the whole gas limit before it.
+func gasTarget(header *types.Header) uint64 {
+	// London fork not active in this branch yet, divide by `params.ElasticityMultiplier` once London is active (and remove this comment)
+	return header.GasLimit
+}
+
+// elapsedSince returns the wall-clock time elapsed since `start`, 0 if `start` is not set
+// because the timings are disabled, see `TimingsEnabled`.
+func elapsedSince(start time.Time) time.Duration {
+	if start.IsZero() {
+		return 0
+	}
+
+	return time.Since(start)
+}
+
+// RecordHeadUpdate records that the fork choice moved the chain head from `previousHead` to
+// `head` which is not a child of `previousHead`, the blocks of the branch the head left are
+// not canonical anymore. It must be called once `head` was emitted, outside of any block.
+func (ctx *Context) RecordHeadUpdate(head *types.Block, previousHead *types.Block) {
+	if ctx == nil {
+		return
+	}
+
+	if ctx.inBlock.Load() {
+		panic("recording a head update while in a block scope")
+	}
+
+	ctx.printer.Print("HEAD_UPDATE",
+		Uint64(head.NumberU64()),
+		Hash(head.Hash()),
+		Uint64(previousHead.NumberU64()),
+		Hash(previousHead.Hash()),
+	)
+}
+
+// RecordSideChainBlock records that `block`, once emitted, was imported on a side chain, i.e.
+// it's not part of the canonical chain, `forkParent` being the canonical block it forks from.
+// It must be called once `block` was emitted, outside of any block.
+func (ctx *Context) RecordSideChainBlock(block *types.Block, forkParent *types.Header) {
+	if ctx == nil {
+		return
+	}
+
+	if ctx.inBlock.Load() {
+		panic("recording a side chain block while in a block scope")
+	}
+
+	ctx.printer.Print("SIDE_CHAIN_BLOCK",
+		Uint64(block.NumberU64()),
+		Hash(block.Hash()),
+		Uint64(forkParent.Number.Uint64()),
+		Hash(forkParent.Hash()),
+	)
 }
 
 // FlushBlock flushes the accumulated context's printer to "stdout" and reset's the
 // context. If the printer is not a ToBufferPrinter, this is a no-op.
-func (ctx *Context) FlushBlock() {
+//
+// An error is returned if the block could not be written to "stdout", in which case
+// the Firehose stream is broken and block processing should stop. An error is also
+// returned, without writing anything, if the block is at the same or a lower height
+// than the last emitted block which was not undone first, see `RecordUndoBlock`.
+func (ctx *Context) FlushBlock() (err error) {
 	if ctx == nil || !Enabled {
-		return
+		return nil
 	}
 
 	// We flush to stdout only if the received `ctx` accumulated all the Firehose
 	// logs in a buffer. Other context already flushed to stdout.
 	if v, ok := ctx.printer.(*ToBufferPrinter); ok {
-		syncContext.printer.Write(v.buffer.Bytes())
+		span := StartSpan("firehose.FlushBlock", ctx.span)
+		span.SetInt("block.number", int64(ctx.blockNumber))
+		span.SetInt("payload.bytes", int64(v.buffer.Len()))
+		defer func() {
+			span.SetError(err)
+			span.End()
+		}()
+
+		if err := v.Err(); err != nil {
+			ctx.exitBlock()
+			return fmt.Errorf("block #%d (%s) not emitted: %w", ctx.blockNumber, ctx.blockHash, err)
+		}
+
+		if !ctx.blockOutOfOrder {
+			if err := syncContext.trackEmittedBlock(ctx.blockNumber, ctx.blockHash); err != nil {
+				ctx.exitBlock()
+				return err
+			}
+		}
+
+		if ctx.segment != nil {
+			if err := ctx.writeLastSegment(v.buffer.Bytes()); err != nil {
+				ctx.exitBlock()
+				return err
+			}
+		} else {
+			writeBlockWithMarkers(syncContext.printer, ctx.blockNumber, ctx.blockHash, ctx.blockStartTime, v.buffer.Bytes())
+		}
+		summarizeEmittedBlock(ctx.blockNumber, v.buffer.Len())
+		if v.tuned != nil {
+			v.tuned.observe(v.buffer.Len())
+		}
 	}
 
 	ctx.exitBlock()
+	syncContext.blockBoundary()
+
+	return SinkErr()
+}
+
+// SinkErr returns the first error that happened while writing to standard output, if any.
+func SinkErr() error {
+	if v, ok := syncContext.printer.(SinkErrorer); ok {
+		return v.Err()
+	}
+
+	return nil
+}
+
+// blockBoundary notifies the context's printer that a complete block was written
+// to it, giving a chance to batching printers to send their accumulated data.
+func (ctx *Context) blockBoundary() {
+	if v, ok := ctx.printer.(blockBoundaryNotifier); ok {
+		v.BlockBoundary()
+	}
+}
+
+// FlushSink sends all data accumulated by the sync context's printer, if any, to its
+// underlying writer. It should be called before the process exits.
+func FlushSink() {
+	if v, ok := syncContext.printer.(flusher); ok {
+		v.Flush()
+	}
+}
+
+// AfterSinkWrite calls `fn` once all the data written so far to standard output, through the
+// sync context's printer, actually reached it. Printers holding data in memory call `fn`
+// once it was sent, possibly from another goroutine, the others right away. `fn` is never
+// called if writing the data failed.
+func AfterSinkWrite(fn func()) {
+	afterWrite(syncContext.printer, fn)
+}
+
+func afterWrite(printer Printer, fn func()) {
+	if v, ok := printer.(writeNotifier); ok {
+		v.AfterWrite(fn)
+		return
+	}
+
+	if v, ok := printer.(SinkErrorer); ok && v.Err() != nil {
+		return
+	}
+	fn()
+}
+
+// writeBlockWithMarkers writes the block's payload between a `BLOCK_BEGIN <num> <hash> <timestamp>
+// <elapsed>` and a `BLOCK_END <num> <hash> <lineCount> <timestamp> <elapsed>` lines, `lineCount`
+// being the number of lines of the payload, see `markerTimings` for the timings relative to
+// the block `start`. Readers use them to detect a block whose emission was cut short by a
+// crash, in which case the node re-emits the full block on restart.
+func writeBlockWithMarkers(printer Printer, number uint64, hash common.Hash, start time.Time, payload []byte) {
+	blockWriteLock.Lock()
+	defer blockWriteLock.Unlock()
+
+	// A block emitted in segments whose processing failed is aborted by the next block
+	abortOpenSegmentedBlock()
+	printBlockWithMarkers(printer, number, hash, start, payload)
+
+	lastBlockWrite.Store(time.Now().UnixNano())
+}
+
+func printBlockWithMarkers(printer Printer, number uint64, hash common.Hash, start time.Time, payload []byte) {
+	timestamp, elapsed := markerTimings(start)
+	printer.Print("BLOCK_BEGIN", Uint64(number), Hash(hash), timestamp, elapsed)
+	printer.Write(payload)
+
+	timestamp, elapsed = markerTimings(start)
+	printer.Print("BLOCK_END", Uint64(number), Hash(hash), strconv.Itoa(bytes.Count(payload, []byte{'\n'})), timestamp, elapsed)
+}
+
+// markerTimings returns the wall-clock time at which a block marker is written, in nanoseconds
+// since the Unix epoch, and the monotonic time elapsed since the block `start`, so consumers
+// can measure the latency of the block availability. Both are 0 when the timings are
+// disabled, see `TimingsEnabled`, the elapsed time also when `start` is not set.
+func markerTimings(start time.Time) (timestamp string, elapsed string) {
+	if !TimingsEnabled {
+		return "0", "0"
+	}
+
+	now := time.Now()
+	if start.IsZero() {
+		return Uint64(uint64(now.UnixNano())), "0"
+	}
+
+	return Uint64(uint64(now.UnixNano())), Uint64(uint64(now.Sub(start)))
+}
+
+// FramedBlock returns the block's `payload` framed by its `BLOCK_BEGIN` and `BLOCK_END` markers,
+// exactly as it's written to the output when the block is flushed, see `FlushBlock`.
+func FramedBlock(number uint64, hash common.Hash, payload []byte) []byte {
+	printer := NewToBufferPrinter(len(payload) + 256)
+	printBlockWithMarkers(printer, number, hash, time.Time{}, payload)
+
+	return printer.Buffer().Bytes()
+}
+
+// LastBlockWriteTime returns the time at which the last block was written, the zero time if
+// no block was written since the process started.
+func LastBlockWriteTime() time.Time {
+	if nanos := lastBlockWrite.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+
+	return time.Time{}
 }
 
 // exitBlock is used when an abnormal condition is encountered while processing
@@ -215,12 +762,17 @@ func (ctx *Context) exitBlock() {
 		panic("exiting a block while not already within a block scope")
 	}
 
+	ctx.abortSegments()
 	ctx.resetBlock()
 
 	// We must reset transcation because exit block can be called while a transaction is inflight
 	ctx.resetTransaction()
 }
 
+// StartSystemCall starts a call executed by the system out of any transaction, at the start
+// or at the end of the block (e.g. the EIP-4788 beacon root or EIP-2935 history storage
+// updates). The calls and state changes recorded until `EndSystemCall` are flagged as
+// system ones by the `SYSTEM_CALL_START` and `SYSTEM_CALL_END` records framing them.
 func (ctx *Context) StartSystemCall() {
 	if ctx == nil {
 		return
@@ -235,6 +787,10 @@ func (ctx *Context) StartSystemCall() {
 	}
 
 	ctx.printer.Print("SYSTEM_CALL_START")
+
+	if ctx.trace != nil {
+		ctx.trace.startSystemCall()
+	}
 }
 
 func (ctx *Context) EndSystemCall() {
@@ -250,6 +806,10 @@ func (ctx *Context) EndSystemCall() {
 		panic("ending a system call while not in a transaction scope")
 	}
 
+	if ctx.trace != nil {
+		ctx.trace.endSystemCall()
+	}
+
 	ctx.resetTransaction()
 	ctx.printer.Print("SYSTEM_CALL_END")
 }
@@ -284,6 +844,8 @@ func (ctx *Context) StartTransaction(tx *types.Transaction, txIndex uint, baseFe
 		tx.Type(),
 		txIndex,
 	)
+
+	ctx.recordTrxSignature(tx)
 }
 
 func gasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
@@ -293,6 +855,9 @@ func gasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
 	switch tx.Type() {
 	case types.LegacyTxType, types.AccessListTxType:
 		return tx.GasPrice()
+	case setCodeTxType:
+		// Without London, a set code transaction is priced at its fee cap which is what `GasPrice` returns
+		return tx.GasPrice()
 	}
 
 	panic(errUnhandledTransactionType("gasPrice", tx.Type()))
@@ -324,6 +889,18 @@ func (ctx *Context) StartTransactionRaw(
 	if !ctx.inTransaction.CAS(false, true) {
 		panic("entering a transaction while already in a transaction scope")
 	}
+	ctx.probeTransaction(int64(txIndex))
+
+	if TimingsEnabled {
+		ctx.trxStartTime = time.Now()
+	}
+
+	if ctx.accounting != nil {
+		ctx.accounting.startTransaction(gasPrice)
+	}
+
+	ctx.creations.startTransaction(hash)
+	ctx.trxGasPrice = gasPrice
 
 	// We start assuming the "null" value (i.e. a dot character), and update if `to` is set
 	toAsString := "."
@@ -336,6 +913,7 @@ func (ctx *Context) StartTransactionRaw(
 	// London fork not active in this branch yet, add proper handling here when it's the case (and remove this comment)
 	maxPriorityFeePerGasAsString := "."
 
+	ordinal := ctx.totalOrderingCounter.Inc()
 	ctx.printer.Print("BEGIN_APPLY_TRX",
 		Hash(hash),
 		toAsString,
@@ -351,9 +929,34 @@ func (ctx *Context) StartTransactionRaw(
 		maxFeePerGasAsString,
 		maxPriorityFeePerGasAsString,
 		Uint8(txType),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ordinal),
 		Uint(txIndex),
 	)
+
+	if ctx.trace != nil {
+		var toCopy *common.Address
+		if to != nil {
+			toCopy = new(common.Address)
+			*toCopy = *to
+		}
+
+		ctx.trace.trx = &TransactionTrace{
+			Hash:         hash,
+			To:           toCopy,
+			Value:        copyBigInt(value),
+			V:            copyBytes(v),
+			R:            copyBytes(r),
+			S:            copyBytes(s),
+			GasLimit:     gasLimit,
+			GasPrice:     copyBigInt(gasPrice),
+			Nonce:        nonce,
+			Input:        copyBytes(data),
+			AccessList:   accessList.marshal(),
+			Type:         txType,
+			BeginOrdinal: ordinal,
+			Index:        uint64(txIndex),
+		}
+	}
 }
 
 func (ctx *Context) RecordTrxFrom(from common.Address) {
@@ -369,6 +972,58 @@ func (ctx *Context) RecordTrxFrom(from common.Address) {
 	ctx.printer.Print("TRX_FROM",
 		Addr(from),
 	)
+
+	if ctx.trace != nil && ctx.trace.trx != nil {
+		ctx.trace.trx.From = from
+	}
+}
+
+// RecordSetCodeAuthorization records an EIP-7702 authorization of a set code transaction,
+// delegating on `chainID` the code of its authority to `address` if the authority's nonce is
+// `nonce`. The `authority` is nil when it could not be recovered from the authorization's
+// signature. An authorization is `applied` when it passed validation and delegated the
+// authority's code to `address`.
+func (ctx *Context) RecordSetCodeAuthorization(chainID *big.Int, address common.Address, nonce uint64, authority *common.Address, applied bool) {
+	if ctx == nil {
+		return
+	}
+
+	if !ctx.inTransaction.Load() {
+		debug.PrintStack()
+		panic("the RecordSetCodeAuthorization should have been call within a transaction, something is deeply wrong")
+	}
+
+	authorityAsString := "."
+	if authority != nil {
+		authorityAsString = Addr(*authority)
+	}
+
+	ordinal := ctx.totalOrderingCounter.Inc()
+	ctx.printer.Print("SET_CODE_AUTHORIZATION",
+		Hex(chainID.Bytes()),
+		Addr(address),
+		Uint64(nonce),
+		authorityAsString,
+		Bool(applied),
+		Uint64(ordinal),
+	)
+
+	if ctx.trace != nil && ctx.trace.trx != nil {
+		var authorityCopy *common.Address
+		if authority != nil {
+			authorityCopy = new(common.Address)
+			*authorityCopy = *authority
+		}
+
+		ctx.trace.trx.SetCodeAuthorizations = append(ctx.trace.trx.SetCodeAuthorizations, &SetCodeAuthorization{
+			ChainID:   copyBigInt(chainID),
+			Address:   address,
+			Nonce:     nonce,
+			Authority: authorityCopy,
+			Applied:   applied,
+			Ordinal:   ordinal,
+		})
+	}
 }
 
 // FlushTransaction flushes the transaction context to the printer of the global context
@@ -384,8 +1039,34 @@ func (ctx *Context) FlushTransaction(txContext *Context) {
 		ctx.flushTxLock.Lock()
 		defer ctx.flushTxLock.Unlock()
 
+		// The data of a transaction not fitting in its buffer is missing, so is the block's
+		if err := v.Err(); err != nil {
+			if block, ok := ctx.printer.(*ToBufferPrinter); ok {
+				block.fail(err)
+			}
+		}
+
+		txBytes := v.buffer.Len()
 		ctx.printer.Write(v.buffer.Bytes())
+		if v.tuned != nil {
+			v.tuned.observe(txBytes)
+		}
 		v.Reset()
+
+		ctx.maybeEmitSegment()
+		ctx.probeFlushedTransaction(txBytes)
+	}
+
+	if ctx.accounting != nil && txContext.accounting != nil {
+		ctx.accounting.merge(txContext.accounting)
+	}
+
+	ctx.creations.merge(txContext.creations)
+	ctx.counts.merge(&txContext.counts)
+
+	if ctx.trace != nil && ctx.trace.block != nil && txContext.trace != nil {
+		ctx.trace.block.Transactions = append(ctx.trace.block.Transactions, txContext.trace.transactions...)
+		txContext.trace.transactions = nil
 	}
 
 	// Reset the transaction context for future re-use, if desired
@@ -420,45 +1101,145 @@ func (ctx *Context) EndTransaction(receipt *types.Receipt) {
 			"address": log.Address,
 			"topics":  log.Topics,
 			"data":    hexutil.Bytes(log.Data),
+			"index":   log.Index,
 		}
 	}
 
+	// The contract address is set only for a contract creation
+	contractAddress := "."
+	if receipt.ContractAddress != (common.Address{}) {
+		contractAddress = Addr(receipt.ContractAddress)
+	}
+
+	// London fork not active in this branch yet, the effective gas price is the gas price of the transaction
+	effectiveGasPrice := ctx.trxGasPrice
+	if effectiveGasPrice == nil {
+		effectiveGasPrice = common.Big0
+	}
+
+	if ctx.accounting != nil {
+		ctx.accounting.endTransaction(receipt.GasUsed)
+	}
+
+	ctx.creations.endTransaction()
+
+	duration := elapsedSince(ctx.trxStartTime)
+	ordinal := ctx.totalOrderingCounter.Inc()
 	ctx.printer.Print(
 		"END_APPLY_TRX",
 		Uint64(receipt.GasUsed),
 		Hex(receipt.PostState),
 		Uint64(receipt.CumulativeGasUsed),
 		Hex(receipt.Bloom[:]),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ordinal),
+		Uint64(uint64(duration)),
+		Uint64(receipt.Status),
+		Hex(effectiveGasPrice.Bytes()),
+		contractAddress,
 		JSON(logItems),
 	)
 
+	if ctx.trace != nil {
+		ctx.trace.endTransaction(receipt, effectiveGasPrice, ordinal, duration)
+	}
+
 	ctx.resetTransaction()
 }
 
 // Call methods
 
-func (ctx *Context) StartCall(callType string) {
+// StartCall opens a new call of kind `callType`, `opCode` is the name of the EVM opcode
+// that triggered the call, it must be empty for the root call of a transaction which is
+// not triggered by any opcode.
+// StartCall records the beginning of a call triggered by `opCode`, empty for the root call of
+// the transaction. The `precompile` flag is true when the call targets a precompiled contract
+// and `hasCode` is true when it executes code, whose hash is `codeHash`: the code of the
+// called account, or of the account it delegates to (EIP-7702), or the init code of a
+// contract creation.
+//
+// The record references the index and the ordinal of the parent call, both 0 for the root
+// call, along with the depth of the call, so the call tree can be built in a single pass
+// even when the records are not processed in order.
+func (ctx *Context) StartCall(callType CallType, opCode string, precompile bool, codeHash common.Hash, hasCode bool) {
 	if ctx == nil {
 		return
 	}
 
+	// We start assuming the "null" value (i.e. a dot character), and update if `opCode` is set
+	opCodeAsString := "."
+	if opCode != "" {
+		opCodeAsString = opCode
+	}
+
+	codeHashAsString := "."
+	if hasCode {
+		codeHashAsString = Hash(codeHash)
+	}
+
+	ctx.counts.Calls++
+	ordinal := ctx.totalOrderingCounter.Inc()
+	parent, depth := ctx.callStack.MustPeek(), ctx.callStack.Len()-1
 	ctx.printer.Print("EVM_RUN_CALL",
-		callType,
-		ctx.openCall(),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		callType.mustBeKnown(),
+		ctx.openCall(ordinal),
+		Uint64(ordinal),
+		opCodeAsString,
+		Bool(precompile),
+		codeHashAsString,
+		Bool(hasCode),
+		parent.index,
+		Uint64(parent.ordinal),
+		Uint64(uint64(depth)),
 	)
+
+	ctx.creations.startCall(callType, codeHash)
+
+	if ctx.trace != nil {
+		call := ctx.trace.startCall(callType, opCode, ctx.nextCallIndex, ordinal)
+		if call != nil {
+			call.Precompile = precompile
+			call.HasCode = hasCode
+			if hasCode {
+				call.CodeHash = &codeHash
+			}
+		}
+	}
 }
 
-func (ctx *Context) openCall() string {
+// openedCall is a call of the transaction not yet ended, `ordinal` being the one of its
+// `EVM_RUN_CALL` record. The bottom of the stack of opened calls is the transaction itself,
+// whose index and ordinal are 0.
+//
+// The `gasLimit` is the one recorded by the parameters of the call and `gasRetained` the gas
+// retained by the caller at the call site, both accounted in its `EVM_END_CALL` record.
+type openedCall struct {
+	index       string
+	ordinal     uint64
+	gasLimit    uint64
+	gasRetained uint64
+}
+
+func (ctx *Context) openCall(ordinal uint64) string {
 	ctx.nextCallIndex++
 	ctx.activeCallIndex = strconv.FormatUint(ctx.nextCallIndex, 10)
 
-	ctx.callIndexStack.Push(ctx.activeCallIndex)
+	ctx.callStack.Push(openedCall{index: ctx.activeCallIndex, ordinal: ordinal, gasRetained: ctx.callSiteGasRetained})
+	ctx.callSiteGasRetained = 0
+	ctx.probeCallDepth()
 
 	return ctx.activeCallIndex
 }
 
+// activeTraceCall returns the active call of the block trace, nil when the block trace is
+// not enabled or when no call is active
+func (ctx *Context) activeTraceCall() *Call {
+	if ctx.trace == nil {
+		return nil
+	}
+
+	return ctx.trace.activeCall()
+}
+
 func (ctx *Context) callIndex() string {
 	if !ctx.transactionScopedContext && !ctx.inBlock.Load() {
 		debug.PrintStack()
@@ -468,19 +1249,94 @@ func (ctx *Context) callIndex() string {
 	return ctx.activeCallIndex
 }
 
-func (ctx *Context) RecordCallParams(callType string, caller common.Address, callee common.Address, value *big.Int, gasLimit uint64, input []byte) {
+// RecordCallGasRetention records the gas retained by the caller at the site of the call about
+// to start, the part of its available gas it kept because of the EIP-150 "all but one 64th"
+// rule, 0 when the gas requested by the caller was allowed as-is. It's accounted in the
+// `EVM_END_CALL` record of the next call started, the root call of a transaction never
+// retaining any gas.
+func (ctx *Context) RecordCallGasRetention(gasRetained uint64) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.callSiteGasRetained = gasRetained
+}
+
+// RecordCallParams records the parameters of the active call. The `depth` is the depth of the
+// call in the EVM call stack, 0 for the root call of the transaction, and `readOnly` is true
+// when the call executes under STATICCALL restrictions (it's a static call or is nested in
+// one), any state modification attempted within it failing.
+//
+// The `input` of a contract creation is its init code, only recorded when enabled, see
+// `CreationCodeEnabled`.
+func (ctx *Context) RecordCallParams(callType CallType, caller common.Address, callee common.Address, value *big.Int, gasLimit uint64, input []byte, depth uint64, readOnly bool) {
 	if ctx == nil {
 		return
 	}
 
+	if callType == CallTypeCreate {
+		input = creationCode(input)
+	}
+
+	ctx.printCallParams(callType, caller, callee, value, gasLimit, input, ".", depth, readOnly)
+	ctx.creations.recordCallParams(caller, callee)
+	ctx.traceCallParams(caller, callee, value, gasLimit, input, readOnly)
+}
+
+// RecordDelegateCallParams records the parameters of a delegate call. The `caller` and `value`
+// are the address of the contract performing the delegate call and the value it received,
+// kept as-is for compatibility, a delegate call inheriting the value of its parent frame.
+// The `delegateCaller` is the logical caller of the frame, i.e. the caller of the contract
+// performing the delegate call (`msg.sender` within the frame). The `depth` and `readOnly`
+// are the same as for `RecordCallParams`.
+func (ctx *Context) RecordDelegateCallParams(caller common.Address, delegateCaller common.Address, callee common.Address, value *big.Int, gasLimit uint64, input []byte, depth uint64, readOnly bool) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printCallParams(CallTypeDelegate, caller, callee, value, gasLimit, input, Addr(delegateCaller), depth, readOnly)
+
+	if call := ctx.traceCallParams(caller, callee, value, gasLimit, input, readOnly); call != nil {
+		call.DelegateCaller = &delegateCaller
+	}
+}
+
+// traceCallParams records the parameters in the active call of the block trace, if any,
+// returning the call
+func (ctx *Context) traceCallParams(caller common.Address, callee common.Address, value *big.Int, gasLimit uint64, input []byte, readOnly bool) *Call {
+	if ctx.trace == nil {
+		return nil
+	}
+
+	call := ctx.trace.activeCall()
+	if call != nil {
+		call.Caller = caller
+		call.Address = callee
+		call.Value = copyBigInt(value)
+		call.GasLimit = gasLimit
+		call.Input = copyBytes(input)
+		call.ReadOnly = readOnly
+	}
+
+	return call
+}
+
+func (ctx *Context) printCallParams(callType CallType, caller common.Address, callee common.Address, value *big.Int, gasLimit uint64, input []byte, delegateCaller string, depth uint64, readOnly bool) {
+	active := ctx.callStack.MustPop()
+	active.gasLimit = gasLimit
+	ctx.callStack.Push(active)
+
 	ctx.printer.Print("EVM_PARAM",
-		callType,
+		callType.mustBeKnown(),
 		ctx.callIndex(),
 		Addr(caller),
 		Addr(callee),
 		Hex(value.Bytes()),
 		Uint64(gasLimit),
 		Hex(input),
+		delegateCaller,
+		Uint64(depth),
+		Bool(readOnly),
 	)
 }
 
@@ -492,9 +1348,38 @@ func (ctx *Context) RecordCallWithoutCode() {
 	ctx.printer.Print("ACCOUNT_WITHOUT_CODE",
 		ctx.callIndex(),
 	)
+
+	if call := ctx.activeTraceCall(); call != nil {
+		call.ExecutedCode = false
+	}
+}
+
+// RecordPrecompiledCall records that the active call targets the precompiled contract
+// `name`, empty when the precompiled contract has no registered name.
+func (ctx *Context) RecordPrecompiledCall(name string) {
+	if ctx == nil {
+		return
+	}
+
+	if call := ctx.activeTraceCall(); call != nil {
+		call.Precompile = true
+		call.PrecompileName = name
+	}
+
+	if name == "" {
+		name = "."
+	}
+
+	ctx.printer.Print("EVM_PRECOMPILE",
+		ctx.callIndex(),
+		name,
+	)
 }
 
-func (ctx *Context) RecordCallFailed(gasLeft uint64, reason string) {
+// RecordCallFailed records that the active call failed, `code` classifying the failure and
+// `reason` being its error message, informative only since it can change from one node version
+// to the other.
+func (ctx *Context) RecordCallFailed(gasLeft uint64, code CallFailureCode, reason string) {
 	if ctx == nil {
 		return
 	}
@@ -502,8 +1387,38 @@ func (ctx *Context) RecordCallFailed(gasLeft uint64, reason string) {
 	ctx.printer.Print("EVM_CALL_FAILED",
 		ctx.callIndex(),
 		Uint64(gasLeft),
+		string(code),
 		reason,
 	)
+
+	ctx.creations.recordCallFailed()
+
+	if call := ctx.activeTraceCall(); call != nil {
+		call.Failed = true
+		call.FailureCode = code
+		call.FailureReason = reason
+	}
+}
+
+// RecordCreateFailed records that the active call, a contract creation, was aborted before
+// running its init code, `code` being why and `disposition` what happened to the `gas` it was
+// given. Unlike the other failures, whether the gas is burned or returned depends on the check
+// that failed, a contract address collision burning it while a nonce overflow returns it.
+func (ctx *Context) RecordCreateFailed(code CallFailureCode, disposition GasDisposition, gas uint64) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printer.Print("EVM_CREATE_FAILED",
+		ctx.callIndex(),
+		string(code),
+		string(disposition),
+		Uint64(gas),
+	)
+
+	if call := ctx.activeTraceCall(); call != nil {
+		call.CreateFailure = &CreateFailure{Code: code, GasDisposition: disposition, Gas: gas}
+	}
 }
 
 func (ctx *Context) RecordCallReverted() {
@@ -514,38 +1429,77 @@ func (ctx *Context) RecordCallReverted() {
 	ctx.printer.Print("EVM_REVERTED",
 		ctx.callIndex(),
 	)
+
+	if call := ctx.activeTraceCall(); call != nil {
+		call.Reverted = true
+	}
 }
 
-func (ctx *Context) closeCall() string {
-	previousIndex := ctx.callIndexStack.MustPop()
-	ctx.activeCallIndex = ctx.callIndexStack.MustPeek()
+func (ctx *Context) closeCall() openedCall {
+	previous := ctx.callStack.MustPop()
+	ctx.activeCallIndex = ctx.callStack.MustPeek().index
+	ctx.creations.endCall()
+	ctx.probeCallDepth()
 
-	return previousIndex
+	return previous
 }
 
-func (ctx *Context) EndCall(gasLeft uint64, returnValue []byte) {
-	if ctx == nil {
-		return
+// printEndCall prints the `EVM_END_CALL` record closing the active call, accounting the gas
+// flow through it: its gas limit, the gas it used and the gas retained by its caller at the
+// call site, `gasLeft` being the gas returned to its caller.
+func (ctx *Context) printEndCall(gasLeft uint64, returnValue []byte, ordinal uint64) (gasUsed, gasRetained uint64) {
+	call := ctx.closeCall()
+	if call.gasLimit > gasLeft {
+		gasUsed = call.gasLimit - gasLeft
 	}
 
 	ctx.printer.Print("EVM_END_CALL",
-		ctx.closeCall(),
+		call.index,
 		Uint64(gasLeft),
 		Hex(returnValue),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ordinal),
+		Uint64(call.gasLimit),
+		Uint64(gasUsed),
+		Uint64(call.gasRetained),
 	)
+
+	return gasUsed, call.gasRetained
+}
+
+// EndCall records the end of the active call, `returnValue` being the data it returned. The
+// data returned by a contract creation is its runtime code, only recorded when enabled, see
+// `CreationCodeEnabled`.
+//
+// The record accounts the gas flow through the call: the `gasLeft` returned to the caller,
+// the gas limit of the call, the gas it used (burned gas included) and the gas retained by
+// the caller at the call site, see `RecordCallGasRetention`.
+func (ctx *Context) EndCall(gasLeft uint64, returnValue []byte) {
+	if ctx == nil {
+		return
+	}
+
+	if call := ctx.creations.activeCall(); call != nil && call.creation != nil {
+		returnValue = creationCode(returnValue)
+	}
+
+	ordinal := ctx.totalOrderingCounter.Inc()
+	gasUsed, gasRetained := ctx.printEndCall(gasLeft, returnValue, ordinal)
+
+	if ctx.trace != nil {
+		ctx.trace.endCall(gasLeft, returnValue, ordinal, gasUsed, gasRetained)
+	}
 }
 
 // EndFailedCall is works similarly to EndCall but actualy also prints extra required line
 // like EVM_CALL_FAILED and EVM_REVERTED when it's the case. This is used on early exit in the
 // the instrumentation when a failure (and revertion) occurs to reduce the actual method call
 // peformed.
-func (ctx *Context) EndFailedCall(gasLeft uint64, reverted bool, reason string) {
+func (ctx *Context) EndFailedCall(gasLeft uint64, reverted bool, code CallFailureCode, reason string) {
 	if ctx == nil {
 		return
 	}
 
-	ctx.RecordCallFailed(gasLeft, reason)
+	ctx.RecordCallFailed(gasLeft, code, reason)
 
 	if reverted {
 		ctx.RecordCallReverted()
@@ -554,12 +1508,12 @@ func (ctx *Context) EndFailedCall(gasLeft uint64, reverted bool, reason string)
 		gasLeft = 0
 	}
 
-	ctx.printer.Print("EVM_END_CALL",
-		ctx.closeCall(),
-		Uint64(gasLeft),
-		Hex(nil),
-		Uint64(ctx.totalOrderingCounter.Inc()),
-	)
+	ordinal := ctx.totalOrderingCounter.Inc()
+	gasUsed, gasRetained := ctx.printEndCall(gasLeft, nil, ordinal)
+
+	if ctx.trace != nil {
+		ctx.trace.endCall(gasLeft, nil, ordinal, gasUsed, gasRetained)
+	}
 }
 
 // In-call methods
@@ -574,6 +1528,13 @@ func (ctx *Context) RecordKeccak(hashOfdata common.Hash, data []byte) {
 		Hash(hashOfdata),
 		Hex(data),
 	)
+
+	if call := ctx.activeTraceCall(); call != nil {
+		if call.KeccakPreimages == nil {
+			call.KeccakPreimages = map[common.Hash]hexutil.Bytes{}
+		}
+		call.KeccakPreimages[hashOfdata] = copyBytes(data)
+	}
 }
 
 func (ctx *Context) RecordGasRefund(gasOld, gasRefund uint64) {
@@ -582,13 +1543,19 @@ func (ctx *Context) RecordGasRefund(gasOld, gasRefund uint64) {
 	}
 
 	if gasRefund != 0 {
+		ctx.counts.GasChanges++
+		ordinal := ctx.totalOrderingCounter.Inc()
 		ctx.printer.Print("GAS_CHANGE",
 			ctx.callIndex(),
 			Uint64(gasOld),
 			Uint64(gasOld+gasRefund),
 			string(RefundAfterExecutionGasChangeReason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
+			Uint64(ordinal),
 		)
+
+		if ctx.trace != nil {
+			ctx.trace.recordGasChange(&GasChange{Old: gasOld, New: gasOld + gasRefund, Reason: RefundAfterExecutionGasChangeReason, Ordinal: ordinal})
+		}
 	}
 }
 
@@ -598,13 +1565,19 @@ func (ctx *Context) RecordGasConsume(gasOld, gasConsumed uint64, reason GasChang
 	}
 
 	if gasConsumed != 0 && reason != IgnoredGasChangeReason {
+		ctx.counts.GasChanges++
+		ordinal := ctx.totalOrderingCounter.Inc()
 		ctx.printer.Print("GAS_CHANGE",
 			ctx.callIndex(),
 			Uint64(gasOld),
 			Uint64(gasOld-gasConsumed),
-			string(reason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
+			reason.mustBeKnownWhenStrict(),
+			Uint64(ordinal),
 		)
+
+		if ctx.trace != nil {
+			ctx.trace.recordGasChange(&GasChange{Old: gasOld, New: gasOld - gasConsumed, Reason: reason, Ordinal: ordinal})
+		}
 	}
 }
 
@@ -613,14 +1586,42 @@ func (ctx *Context) RecordStorageChange(addr common.Address, key, oldData, newDa
 		return
 	}
 
+	ctx.counts.StorageChanges++
+	ordinal := ctx.totalOrderingCounter.Inc()
 	ctx.printer.Print("STORAGE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
 		Hash(key),
 		Hash(oldData),
 		Hash(newData),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ordinal),
+	)
+
+	if call := ctx.activeTraceCall(); call != nil {
+		call.StorageChanges = append(call.StorageChanges, &StorageChange{Address: addr, Key: key, Old: oldData, New: newData, Ordinal: ordinal})
+	}
+}
+
+// RecordTransientStorageChange records a change of the transient storage of EIP-1153
+// (TSTORE), discarded at the end of the transaction unlike a `STORAGE_CHANGE`.
+func (ctx *Context) RecordTransientStorageChange(addr common.Address, key, oldData, newData common.Hash) {
+	if ctx == nil {
+		return
+	}
+
+	ordinal := ctx.totalOrderingCounter.Inc()
+	ctx.printer.Print("TRANSIENT_STORAGE_CHANGE",
+		ctx.callIndex(),
+		Addr(addr),
+		Hash(key),
+		Hash(oldData),
+		Hash(newData),
+		Uint64(ordinal),
 	)
+
+	if call := ctx.activeTraceCall(); call != nil {
+		call.TransientStorageChanges = append(call.TransientStorageChanges, &StorageChange{Address: addr, Key: key, Old: oldData, New: newData, Ordinal: ordinal})
+	}
 }
 
 func (ctx *Context) RecordBalanceChange(addr common.Address, oldBalance, newBalance *big.Int, reason BalanceChangeReason) {
@@ -629,19 +1630,76 @@ func (ctx *Context) RecordBalanceChange(addr common.Address, oldBalance, newBala
 	}
 
 	if reason != IgnoredBalanceChangeReason {
+		reasonName := reason.mustBeKnownWhenStrict()
+
+		if ctx.accounting != nil {
+			ctx.accounting.recordBalanceChange(oldBalance, newBalance, reason)
+		}
+
 		// THOUGHTS: There is a choice between storage vs CPU here as we store the old balance and the new balance.
 		//           Usually, balances are quite big. Storing instead the old balance and the delta would probably
 		//           reduce a lot the storage space at the expense of CPU time to compute the delta and recomputed
 		//           the new balance in place where it's required. This would need to be computed (the space
 		//           savings) to see if it make sense to apply it or not.
+		ctx.counts.BalanceChanges++
+		ordinal := ctx.totalOrderingCounter.Inc()
 		ctx.printer.Print("BALANCE_CHANGE",
 			ctx.callIndex(),
 			Addr(addr),
 			BigInt(oldBalance),
 			BigInt(newBalance),
-			string(reason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
+			reasonName,
+			Uint64(ordinal),
 		)
+
+		if ctx.trace != nil {
+			ctx.trace.recordBalanceChange(&BalanceChange{
+				Address: addr,
+				Old:     copyBigInt(oldBalance),
+				New:     copyBigInt(newBalance),
+				Reason:  reason,
+				Ordinal: ordinal,
+			})
+		}
+	}
+}
+
+// RecordBalanceDelta records that the balance of `addr` was credited by `delta`, or debited
+// when it's negative, for `reason`. It's meant for the debit-style system operations of chain
+// variants whose engine adjusts a balance by a signed amount out of the state journal, the
+// balances before and after the operation being unknown, see `Variant`. The record carries
+// the delta with an explicit sign, see `SignedBigInt`.
+func (ctx *Context) RecordBalanceDelta(addr common.Address, delta *big.Int, reason BalanceChangeReason) {
+	if ctx == nil {
+		return
+	}
+
+	if reason == IgnoredBalanceChangeReason {
+		return
+	}
+
+	reasonName := reason.mustBeKnownWhenStrict()
+	if ctx.accounting != nil {
+		ctx.accounting.recordBalanceChange(nil, delta, reason)
+	}
+
+	ctx.counts.BalanceChanges++
+	ordinal := ctx.totalOrderingCounter.Inc()
+	ctx.printer.Print("BALANCE_DELTA",
+		ctx.callIndex(),
+		Addr(addr),
+		SignedBigInt(delta),
+		reasonName,
+		Uint64(ordinal),
+	)
+
+	if ctx.trace != nil {
+		ctx.trace.recordBalanceChange(&BalanceChange{
+			Address: addr,
+			Delta:   copyBigInt(delta),
+			Reason:  reason,
+			Ordinal: ordinal,
+		})
 	}
 }
 
@@ -650,19 +1708,32 @@ func (ctx *Context) RecordLog(log *types.Log) {
 		return
 	}
 
-	strtopics := make([]string, len(log.Topics))
-	for idx, topic := range log.Topics {
-		strtopics[idx] = Hash(topic)
+	strtopics := ctx.topicsScratch[:0]
+	for _, topic := range log.Topics {
+		strtopics = append(strtopics, Hash(topic))
 	}
+	ctx.topicsScratch = strtopics
 
+	indexInBlock := ctx.blockLogIndex
+	ctx.counts.Logs++
+	ordinal := ctx.totalOrderingCounter.Inc()
 	ctx.printer.Print("ADD_LOG",
 		ctx.callIndex(),
 		ctx.logIndexInBlock(),
 		Addr(log.Address),
 		strings.Join(strtopics, ","),
 		Hex(log.Data),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ordinal),
 	)
+
+	if call := ctx.activeTraceCall(); call != nil {
+		traced := &Log{Address: log.Address, Data: copyBytes(log.Data), IndexInBlock: indexInBlock, Ordinal: ordinal}
+		if len(log.Topics) > 0 {
+			traced.Topics = append([]common.Hash(nil), log.Topics...)
+		}
+
+		call.Logs = append(call.Logs, traced)
+	}
 }
 
 func (ctx *Context) logIndexInBlock() string {
@@ -684,11 +1755,15 @@ func (ctx *Context) RecordSuicide(addr common.Address, suicided bool, balanceBef
 		BigInt(balanceBeforeSuicide),
 	)
 
+	if call := ctx.activeTraceCall(); call != nil {
+		call.SuicideChanges = append(call.SuicideChanges, &SuicideChange{Address: addr, Suicided: suicided, BalanceBefore: copyBigInt(balanceBeforeSuicide)})
+	}
+
 	if balanceBeforeSuicide.Sign() != 0 {
 		// We need to explicit add a balance change removing the suicided contract balance since
 		// the remaining balance of the contract has already been resetted to 0 by the time we
 		// do the print call.
-		ctx.RecordBalanceChange(addr, balanceBeforeSuicide, common.Big0, BalanceChangeReason("suicide_withdraw"))
+		ctx.RecordBalanceChange(addr, balanceBeforeSuicide, common.Big0, SuicideWithdrawBalanceChangeReason)
 	}
 }
 
@@ -697,11 +1772,57 @@ func (ctx *Context) RecordNewAccount(addr common.Address) {
 		return
 	}
 
+	ordinal := ctx.totalOrderingCounter.Inc()
 	ctx.printer.Print("CREATED_ACCOUNT",
 		ctx.callIndex(),
 		Addr(addr),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ordinal),
 	)
+
+	if ctx.trace != nil {
+		ctx.trace.recordCreatedAccount(&CreatedAccount{Address: addr, Ordinal: ordinal})
+	}
+}
+
+// RecordDeletedAccount records the removal of `addr` from the state, for `reason`, when the
+// changes of the transaction are finalized. Along with `RecordNewAccount`, it enables
+// consumers to maintain the exact set of accounts of the state.
+func (ctx *Context) RecordDeletedAccount(addr common.Address, reason AccountDeletionReason) {
+	if ctx == nil {
+		return
+	}
+
+	ordinal := ctx.totalOrderingCounter.Inc()
+	ctx.printer.Print("DELETED_ACCOUNT",
+		ctx.callIndex(),
+		Addr(addr),
+		reason.mustBeKnownWhenStrict(),
+		Uint64(ordinal),
+	)
+
+	if ctx.trace != nil {
+		ctx.trace.recordDeletedAccount(&DeletedAccount{Address: addr, Reason: reason, Ordinal: ordinal})
+	}
+}
+
+// RecordAccountTouch records the EIP-158 touch of `addr`, a zero value balance addition that
+// changes no balance but makes the account, if empty, eligible for removal at the end of the
+// transaction. Touches are recorded only when `AccountTouchesEnabled` is set.
+func (ctx *Context) RecordAccountTouch(addr common.Address) {
+	if ctx == nil || !AccountTouchesEnabled {
+		return
+	}
+
+	ordinal := ctx.totalOrderingCounter.Inc()
+	ctx.printer.Print("ACCOUNT_TOUCH",
+		ctx.callIndex(),
+		Addr(addr),
+		Uint64(ordinal),
+	)
+
+	if ctx.trace != nil {
+		ctx.trace.recordAccountTouch(&AccountTouch{Address: addr, Ordinal: ordinal})
+	}
 }
 
 func (ctx *Context) RecordCodeChange(addr common.Address, oldCodeHash, oldCode []byte, newCodeHash common.Hash, newCode []byte) {
@@ -709,6 +1830,7 @@ func (ctx *Context) RecordCodeChange(addr common.Address, oldCodeHash, oldCode [
 		return
 	}
 
+	ordinal := ctx.totalOrderingCounter.Inc()
 	ctx.printer.Print("CODE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
@@ -716,8 +1838,21 @@ func (ctx *Context) RecordCodeChange(addr common.Address, oldCodeHash, oldCode [
 		Hex(oldCode),
 		Hash(newCodeHash),
 		Hex(newCode),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ordinal),
 	)
+
+	ctx.creations.recordCodeChange(addr, newCodeHash)
+
+	if ctx.trace != nil {
+		ctx.trace.recordCodeChange(&CodeChange{
+			Address:     addr,
+			OldCodeHash: copyBytes(oldCodeHash),
+			OldCode:     copyBytes(oldCode),
+			NewCodeHash: newCodeHash,
+			NewCode:     copyBytes(newCode),
+			Ordinal:     ordinal,
+		})
+	}
 }
 
 func (ctx *Context) RecordNonceChange(addr common.Address, oldNonce, newNonce uint64) {
@@ -725,13 +1860,18 @@ func (ctx *Context) RecordNonceChange(addr common.Address, oldNonce, newNonce ui
 		return
 	}
 
+	ordinal := ctx.totalOrderingCounter.Inc()
 	ctx.printer.Print("NONCE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
 		Uint64(oldNonce),
 		Uint64(newNonce),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ordinal),
 	)
+
+	if ctx.trace != nil {
+		ctx.trace.recordNonceChange(&NonceChange{Address: addr, Old: oldNonce, New: newNonce, Ordinal: ordinal})
+	}
 }
 
 // Mempool methods
@@ -773,6 +1913,140 @@ func (ctx *Context) RecordTrxPool(eventType string, tx *types.Transaction, err e
 	)
 }
 
+// PendingDropReason denotes why a transaction left the transaction pool without being
+// included in a block by this node.
+type PendingDropReason string
+
+const (
+	// ReplacedPendingDropReason is used when a transaction with the same nonce and a higher
+	// gas price replaced the transaction, or when the transaction lost against such one.
+	ReplacedPendingDropReason PendingDropReason = "replaced"
+	// UnderpricedPendingDropReason is used when the transaction was evicted to make room for
+	// better priced ones or when the pool's minimum gas price was raised above its own.
+	UnderpricedPendingDropReason PendingDropReason = "underpriced"
+	// ExpiredPendingDropReason is used when the transaction stayed queued longer than the
+	// pool's lifetime.
+	ExpiredPendingDropReason PendingDropReason = "expired"
+	// StaleNoncePendingDropReason is used when the sender's nonce moved past the transaction's
+	// one, most of the time because it was included in a block.
+	StaleNoncePendingDropReason PendingDropReason = "stale_nonce"
+	// UnpayablePendingDropReason is used when the sender cannot pay for the transaction anymore
+	// or when its gas limit is above the block gas limit.
+	UnpayablePendingDropReason PendingDropReason = "unpayable"
+	// PoolLimitPendingDropReason is used when the transaction was evicted because the sender
+	// or the whole pool was above its allowed number of transactions.
+	PoolLimitPendingDropReason PendingDropReason = "pool_limit"
+)
+
+// RecordHeartbeat records that the node is alive at `now` while no block was emitted lately,
+// `head` being the current chain head, so that a chain producing no block can be told apart
+// from a wedged node. It can be called concurrently to block processing.
+func (ctx *Context) RecordHeartbeat(head *types.Header, now time.Time) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printConcurrent("HEARTBEAT", Uint64(head.Number.Uint64()), Hash(head.Hash()), Uint64(uint64(now.Unix())))
+}
+
+// RecordPendingTransaction records that `tx`, sent by `from`, entered the transaction pool.
+// The transaction input is identified by its hash only. It can be called concurrently to
+// block processing.
+func (ctx *Context) RecordPendingTransaction(tx *types.Transaction, from common.Address) {
+	if ctx == nil {
+		return
+	}
+
+	toAsString := "."
+	if tx.To() != nil {
+		toAsString = Addr(*tx.To())
+	}
+
+	ctx.printConcurrent("PENDING_TRX",
+		Hash(tx.Hash()),
+		Addr(from),
+		toAsString,
+		Uint64(tx.Nonce()),
+		Hex(tx.GasPrice().Bytes()),
+		Uint64(tx.Gas()),
+		Hash(crypto.Keccak256Hash(tx.Data())),
+	)
+}
+
+// RecordPendingDrop records that the transaction `hash` left the transaction pool for
+// `reason`. It can be called concurrently to block processing.
+func (ctx *Context) RecordPendingDrop(hash common.Hash, reason PendingDropReason) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printConcurrent("PENDING_DROP", Hash(hash), string(reason))
+}
+
+// RecordPendingReplacement records that the transaction `hash` replaced, paying a higher fee,
+// the transaction `replaced` of the same sender and nonce in the transaction pool. It follows
+// the `PENDING_DROP` of `replaced` and may precede the `PENDING_TRX` of `hash`. It can be
+// called concurrently to block processing.
+func (ctx *Context) RecordPendingReplacement(hash common.Hash, replaced common.Hash) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printConcurrent("PENDING_REPLACE", Hash(hash), Hash(replaced))
+}
+
+// RecordPendingExecutable records that the transaction `hash`, waiting in the transaction
+// pool for a nonce gap to be filled, became executable once the transaction `gapFiller` of
+// the same sender filled it. It can be called concurrently to block processing.
+func (ctx *Context) RecordPendingExecutable(hash common.Hash, gapFiller common.Hash) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printConcurrent("PENDING_EXECUTABLE", Hash(hash), Hash(gapFiller))
+}
+
+// RecordPendingEffects records the predicted effects of the pending transaction `trx`,
+// speculatively executed on top of `head`: its status (`succeeded`, `reverted` or `failed`),
+// its gas used, its logs and the value transfers that were not rolled back. It can be called
+// concurrently to block processing.
+func (ctx *Context) RecordPendingEffects(head *types.Header, trx *TransactionTrace) {
+	if ctx == nil {
+		return
+	}
+
+	status, transfers := "succeeded", []*Transfer{}
+	for _, transfer := range trx.ValueTransfers() {
+		// Only the value sent along calls is predicted
+		if transfer.Kind == TransferKindCall {
+			transfers = append(transfers, &Transfer{From: *transfer.From, To: transfer.To, Value: transfer.Value})
+		}
+	}
+
+	if len(trx.Calls) > 0 {
+		if root := trx.Calls[0]; root.Reverted {
+			status = "reverted"
+		} else if root.Failed {
+			status = "failed"
+		}
+	}
+
+	logs := trx.ReceiptLogs
+	if logs == nil {
+		logs = []*ReceiptLog{}
+	}
+
+	ctx.printConcurrent("PENDING_TRX_EFFECTS",
+		Hash(trx.Hash),
+		Uint64(head.Number.Uint64()),
+		Hash(head.Hash()),
+		status,
+		Uint64(trx.GasUsed),
+		JSON(logs),
+		JSON(transfers),
+	)
+}
+
 type AccessList types.AccessList
 
 // marshal in a binary format that will be printed as hex in firehose and read on the console reader
@@ -1,9 +1,13 @@
 package firehose
 
 import (
+	"bytes"
 	"encoding/hex"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -110,3 +114,111 @@ func hash(t *testing.T, in string) common.Hash {
 
 	return common.HexToHash(in)
 }
+
+// startTestTransaction starts recording a contract creation transaction with the given
+// hash and gas limit, the first of its block.
+func startTestTransaction(ctx *Context, hash common.Hash, gasLimit uint64) {
+	ctx.StartTransactionRaw(hash, nil, common.Big0, nil, nil, nil, gasLimit, common.Big1, 0, nil, nil, nil, nil, 0, 0)
+}
+
+func TestAcquireTransactionContextWithBuffer(t *testing.T) {
+	buffer := bytes.NewBuffer(nil)
+
+	ctx := AcquireTransactionContextWithBuffer(buffer)
+	startTestTransaction(ctx, common.Hash{}, 21000)
+	ctx.StartCall(CallTypeCall, "", false, common.Hash{}, false)
+	require.True(t, ctx.inTransaction.Load())
+	require.NotEmpty(t, buffer.Bytes())
+
+	ReleaseContext(ctx)
+
+	ctx = AcquireTransactionContextWithBuffer(buffer)
+	defer ReleaseContext(ctx)
+
+	assert.Empty(t, buffer.Bytes())
+	assert.False(t, ctx.inTransaction.Load())
+	assert.Equal(t, "0", ctx.activeCallIndex)
+	assert.Equal(t, 1, ctx.callStack.Len())
+	assert.Equal(t, uint64(0), ctx.totalOrderingCounter.Load())
+}
+
+func TestWriteBlockWithMarkers(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	blockHash := common.HexToHash("0xab")
+
+	before := time.Now()
+	writeBlockWithMarkers(printer, 7, blockHash, before, []byte("FIRE BEGIN_BLOCK 7\nFIRE END_BLOCK 7\n"))
+	assert.False(t, LastBlockWriteTime().Before(before), "last block write time must be updated")
+
+	// The timings are disabled by default
+	assert.Equal(t, "FIRE BLOCK_BEGIN 7 "+Hash(blockHash)+" 0 0\n"+
+		"FIRE BEGIN_BLOCK 7\n"+
+		"FIRE END_BLOCK 7\n"+
+		"FIRE BLOCK_END 7 "+Hash(blockHash)+" 2 0 0\n", printer.Buffer().String())
+}
+
+func TestWriteBlockWithMarkers_Timings(t *testing.T) {
+	defer func(enabled bool) { TimingsEnabled = enabled }(TimingsEnabled)
+	TimingsEnabled = true
+
+	printer := NewToBufferPrinter(1024)
+	start := time.Now()
+	time.Sleep(time.Millisecond)
+
+	writeBlockWithMarkers(printer, 7, common.HexToHash("0xab"), start, []byte("FIRE BEGIN_BLOCK 7\nFIRE END_BLOCK 7\n"))
+	lines := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+
+	begin, end := strings.Fields(lines[0]), strings.Fields(lines[3])
+	require.Len(t, begin, 6)
+	require.Len(t, end, 7)
+
+	beginTime, _ := strconv.ParseInt(begin[4], 10, 64)
+	endTime, _ := strconv.ParseInt(end[5], 10, 64)
+	beginElapsed, _ := strconv.ParseInt(begin[5], 10, 64)
+	endElapsed, _ := strconv.ParseInt(end[6], 10, 64)
+
+	assert.GreaterOrEqual(t, beginTime, start.UnixNano())
+	assert.GreaterOrEqual(t, endTime, beginTime)
+	assert.GreaterOrEqual(t, beginElapsed, int64(time.Millisecond))
+	assert.GreaterOrEqual(t, endElapsed, beginElapsed)
+
+	// A block framed out of its processing has no start, only the times are recorded
+	framed := strings.Fields(strings.SplitN(string(FramedBlock(7, common.HexToHash("0xab"), nil)), "\n", 2)[0])
+	assert.NotEqual(t, "0", framed[4])
+	assert.Equal(t, "0", framed[5])
+}
+
+func TestAbortTransaction(t *testing.T) {
+	defer func(enabled, syncEnabled bool, ctx *Context) {
+		Enabled, SyncInstrumentationEnabled, syncContext = enabled, syncEnabled, ctx
+	}(Enabled, SyncInstrumentationEnabled, syncContext)
+
+	printer := NewToBufferPrinter(1024)
+	Enabled, SyncInstrumentationEnabled, syncContext = true, true, NewContext(printer, false)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: common.Big1})
+	txHash := common.HexToHash("0xcd")
+
+	ctx := AcquireTransactionContextWithBuffer(bytes.NewBuffer(nil))
+	defer ReleaseContext(ctx)
+
+	startTestTransaction(ctx, txHash, 21000)
+	partialTrace := append([]byte(nil), ctx.FirehoseLog()...)
+
+	var err error
+	func() {
+		defer func() {
+			err = AbortTransaction(ctx, block, 3, txHash, recover(), nil)
+		}()
+
+		// Closing a call that was never opened leaves the call index stack inconsistent
+		ctx.EndCall(0, nil)
+	}()
+
+	panicMessage := "at least one element must exist in the index stack at this point"
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), panicMessage)
+	assert.Equal(t, "FIRE TRX_ABORT 1 "+Hash(block.Hash())+" 3 "+Hash(txHash)+" "+hex.EncodeToString(partialTrace)+` "`+panicMessage+`"`+"\n", printer.Buffer().String())
+}
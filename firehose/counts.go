@@ -0,0 +1,51 @@
+package firehose
+
+// RecordCounts are the amounts of records of each category emitted for a block, recorded by
+// its `END_BLOCK` record. Consumers check they decoded all of them without walking the raw
+// stream again, operators spot anomalous blocks (e.g. a log explosion) out of the end record
+// alone.
+type RecordCounts struct {
+	// Calls counts the `EVM_RUN_CALL` records, system calls included
+	Calls uint64 `json:"calls"`
+	// Logs counts the `ADD_LOG` records
+	Logs uint64 `json:"logs"`
+	// BalanceChanges counts the `BALANCE_CHANGE` records, block level changes included
+	BalanceChanges uint64 `json:"balanceChanges"`
+	// StorageChanges counts the `STORAGE_CHANGE` records, transient storage is not counted
+	StorageChanges uint64 `json:"storageChanges"`
+	// GasChanges counts the `GAS_CHANGE` records
+	GasChanges uint64 `json:"gasChanges"`
+}
+
+func (c *RecordCounts) merge(other *RecordCounts) {
+	c.Calls += other.Calls
+	c.Logs += other.Logs
+	c.BalanceChanges += other.BalanceChanges
+	c.StorageChanges += other.StorageChanges
+	c.GasChanges += other.GasChanges
+}
+
+// CountRecords returns the amounts of records of each category held by the block trace, to
+// be compared with the counts recorded by its `END_BLOCK` record.
+func (b *BlockTrace) CountRecords() *RecordCounts {
+	counts := &RecordCounts{BalanceChanges: uint64(len(b.BalanceChanges))}
+
+	countCalls := func(calls []*Call) {
+		for _, call := range calls {
+			counts.Calls++
+			counts.Logs += uint64(len(call.Logs))
+			counts.BalanceChanges += uint64(len(call.BalanceChanges))
+			counts.StorageChanges += uint64(len(call.StorageChanges))
+			counts.GasChanges += uint64(len(call.GasChanges))
+		}
+	}
+
+	for _, trx := range b.Transactions {
+		counts.BalanceChanges += uint64(len(trx.BalanceChanges))
+		counts.GasChanges += uint64(len(trx.GasChanges))
+		countCalls(trx.Calls)
+	}
+	countCalls(b.SystemCalls)
+
+	return counts
+}
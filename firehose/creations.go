@@ -0,0 +1,129 @@
+package firehose
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// CreatedContract is a contract created by a transaction of a block, see `CREATED_CONTRACTS`.
+type CreatedContract struct {
+	Address common.Address `json:"address"`
+
+	// Creator is the account that created the contract, the transaction sender or the
+	// contract executing the `CREATE` or `CREATE2` opcode
+	Creator         common.Address `json:"creator"`
+	TransactionHash common.Hash    `json:"transactionHash"`
+
+	// InitCodeHash is the hash of the code executed to create the contract and `CodeHash` is
+	// the hash of the runtime code it returned, the empty code hash when it returned none
+	InitCodeHash common.Hash `json:"initCodeHash"`
+	CodeHash     common.Hash `json:"codeHash"`
+}
+
+// contractCreations tracks the contracts created by a block, or by a single transaction when
+// held by a transaction context, in which case they are merged in the block's ones when the
+// transaction is flushed. The contracts created by a call that failed, or that is nested in
+// a call that failed, are discarded since their creation was rolled back.
+type contractCreations struct {
+	created []*CreatedContract
+
+	// Transaction state
+	trxHash common.Hash
+	pending []*CreatedContract
+	calls   []creationsCall
+}
+
+// creationsCall is an open call of the transaction, `creation` is the contract it creates,
+// nil if it's not a creation, and `mark` the count of contracts created before it started.
+type creationsCall struct {
+	creation *CreatedContract
+	mark     int
+	failed   bool
+}
+
+// creationCode returns `code`, the init code of a contract creation or the runtime code it
+// returned, when it's recorded in the creation call records, nil otherwise. Code larger than
+// `CreationCodeMaxSize` is never recorded, its hash being available through the `codeHash`
+// of the `EVM_RUN_CALL` record for the init code and the `CODE_CHANGE` record for the runtime
+// code.
+func creationCode(code []byte) []byte {
+	if !CreationCodeEnabled || (CreationCodeMaxSize > 0 && len(code) > CreationCodeMaxSize) {
+		return nil
+	}
+
+	return code
+}
+
+func (c *contractCreations) resetBlock() {
+	c.created = nil
+}
+
+func (c *contractCreations) resetTransaction() {
+	c.trxHash = common.Hash{}
+	c.pending = nil
+	c.calls = c.calls[:0]
+}
+
+func (c *contractCreations) startTransaction(hash common.Hash) {
+	c.trxHash = hash
+}
+
+func (c *contractCreations) startCall(callType CallType, codeHash common.Hash) {
+	call := creationsCall{mark: len(c.pending)}
+	if callType == CallTypeCreate {
+		call.creation = &CreatedContract{TransactionHash: c.trxHash, InitCodeHash: codeHash, CodeHash: emptyCodeHash}
+		c.pending = append(c.pending, call.creation)
+	}
+
+	c.calls = append(c.calls, call)
+}
+
+func (c *contractCreations) activeCall() *creationsCall {
+	if len(c.calls) == 0 {
+		return nil
+	}
+
+	return &c.calls[len(c.calls)-1]
+}
+
+func (c *contractCreations) recordCallParams(caller, callee common.Address) {
+	if call := c.activeCall(); call != nil && call.creation != nil {
+		call.creation.Creator = caller
+		call.creation.Address = callee
+	}
+}
+
+func (c *contractCreations) recordCodeChange(addr common.Address, newCodeHash common.Hash) {
+	if call := c.activeCall(); call != nil && call.creation != nil && call.creation.Address == addr {
+		call.creation.CodeHash = newCodeHash
+	}
+}
+
+func (c *contractCreations) recordCallFailed() {
+	if call := c.activeCall(); call != nil {
+		call.failed = true
+	}
+}
+
+func (c *contractCreations) endCall() {
+	call := c.activeCall()
+	if call == nil {
+		return
+	}
+
+	if call.failed {
+		c.pending = c.pending[:call.mark]
+	}
+
+	c.calls = c.calls[:len(c.calls)-1]
+}
+
+func (c *contractCreations) endTransaction() {
+	c.created = append(c.created, c.pending...)
+}
+
+func (c *contractCreations) merge(other *contractCreations) {
+	c.created = append(c.created, other.created...)
+}
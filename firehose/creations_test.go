@@ -0,0 +1,155 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_CreatedContracts(t *testing.T) {
+	var (
+		sender   = common.Address{0x01}
+		factory  = common.Address{0xfa}
+		child    = common.Address{0xc1}
+		reverted = common.Address{0xc2}
+		nested   = common.Address{0xc3}
+		other    = common.Address{0xc4}
+
+		trxHash1 = common.Hash{0x01}
+		trxHash2 = common.Hash{0x02}
+
+		factoryInit, factoryCode = common.Hash{0xf1}, common.Hash{0xf2}
+		childInit, childCode     = common.Hash{0xc1}, common.Hash{0xc2}
+		otherInit                = common.Hash{0xd1}
+	)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)})
+	buffer := bytes.NewBuffer(nil)
+	ctx := NewBlockContextWithBuffer(buffer)
+	ctx.EnableBlockTrace()
+
+	startCall := func(txCtx *Context, callType CallType, caller, callee common.Address, codeHash common.Hash) {
+		txCtx.StartCall(callType, "", false, codeHash, true)
+		txCtx.RecordCallParams(callType, caller, callee, EmptyValue, 100_000, nil, 0, false)
+	}
+
+	ctx.StartBlock(block)
+
+	// The factory creates a child, then a contract whose creation reverts, then calls a
+	// contract that creates one before failing, only the factory and its child remain
+	txCtx := NewTransactionContextWithBuffer(bytes.NewBuffer(nil))
+	startTestTransaction(txCtx, trxHash1, 100_000)
+	startCall(txCtx, CallTypeCreate, sender, factory, factoryInit)
+	startCall(txCtx, CallTypeCreate, factory, child, childInit)
+	txCtx.RecordCodeChange(child, nil, nil, childCode, []byte{0x01})
+	txCtx.EndCall(0, nil)
+	startCall(txCtx, CallTypeCreate, factory, reverted, childInit)
+	txCtx.EndFailedCall(0, true, ExecutionRevertedCallFailureCode, "execution reverted")
+	startCall(txCtx, CallTypeCall, factory, child, childCode)
+	startCall(txCtx, CallTypeCreate, child, nested, childInit)
+	txCtx.EndCall(0, nil)
+	txCtx.RecordCallFailed(0, OutOfGasCallFailureCode, "out of gas")
+	txCtx.EndCall(0, nil)
+	txCtx.RecordCodeChange(factory, nil, nil, factoryCode, []byte{0x02})
+	txCtx.EndCall(0, nil)
+	txCtx.EndTransaction(&types.Receipt{})
+	ctx.FlushTransaction(txCtx)
+
+	// A creation returning no runtime code
+	startTestTransaction(txCtx, trxHash2, 100_000)
+	startCall(txCtx, CallTypeCreate, sender, other, otherInit)
+	txCtx.EndCall(0, nil)
+	txCtx.EndTransaction(&types.Receipt{})
+	ctx.FlushTransaction(txCtx)
+
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, big.NewInt(1))
+
+	expected := []*CreatedContract{
+		{Address: factory, Creator: sender, TransactionHash: trxHash1, InitCodeHash: factoryInit, CodeHash: factoryCode},
+		{Address: child, Creator: factory, TransactionHash: trxHash1, InitCodeHash: childInit, CodeHash: childCode},
+		{Address: other, Creator: sender, TransactionHash: trxHash2, InitCodeHash: otherInit, CodeHash: emptyCodeHash},
+	}
+	assert.Equal(t, expected, ctx.BlockTrace().CreatedContracts)
+
+	var line string
+	for _, candidate := range strings.Split(buffer.String(), "\n") {
+		if strings.HasPrefix(candidate, "FIRE CREATED_CONTRACTS ") {
+			line = candidate
+		}
+	}
+	require.NotEmpty(t, line, "no CREATED_CONTRACTS record in:\n%s", buffer.String())
+
+	var recorded []*CreatedContract
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "FIRE CREATED_CONTRACTS ")), &recorded))
+	assert.Equal(t, expected, recorded)
+}
+
+func TestContext_CreatedContractsNone(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)})
+	buffer := bytes.NewBuffer(nil)
+	ctx := NewBlockContextWithBuffer(buffer)
+
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, big.NewInt(1))
+
+	assert.NotContains(t, buffer.String(), "CREATED_CONTRACTS")
+}
+
+func TestContext_CreationCode(t *testing.T) {
+	defer func(enabled bool, maxSize int) {
+		CreationCodeEnabled, CreationCodeMaxSize = enabled, maxSize
+	}(CreationCodeEnabled, CreationCodeMaxSize)
+
+	var (
+		sender, created = common.Address{0x01}, common.Address{0xc1}
+		initCode        = []byte{0x60, 0x01, 0x60, 0x00, 0xf3}
+		runtimeCode     = []byte{0x00}
+	)
+
+	record := func() (string, *Call) {
+		ctx := NewSpeculativeExecutionContext(1024)
+		ctx.EnableBlockTrace()
+		startTestTransaction(ctx, common.Hash{}, 100_000)
+		ctx.StartCall(CallTypeCall, "", false, common.Hash{}, false)
+		ctx.RecordCallParams(CallTypeCall, sender, sender, EmptyValue, 100_000, []byte{0xaa}, 0, false)
+		ctx.StartCall(CallTypeCreate, "CREATE", false, common.Hash{0x01}, true)
+		ctx.RecordCallParams(CallTypeCreate, sender, created, EmptyValue, 50_000, initCode, 1, false)
+		ctx.EndCall(10_000, runtimeCode)
+		ctx.EndCall(50_000, []byte{0xbb})
+
+		return string(ctx.FirehoseLog()), ctx.trace.trx.Calls[1]
+	}
+
+	CreationCodeEnabled = false
+	log, call := record()
+	assert.Contains(t, log, "FIRE EVM_PARAM CREATE 2 0100000000000000000000000000000000000000 c100000000000000000000000000000000000000 . 50000 . . 1 false\n")
+	assert.Contains(t, log, "FIRE EVM_END_CALL 2 10000 . ")
+	assert.Empty(t, call.Input)
+	assert.Empty(t, call.ReturnData)
+
+	CreationCodeEnabled = true
+	log, call = record()
+	assert.Contains(t, log, "FIRE EVM_PARAM CREATE 2 0100000000000000000000000000000000000000 c100000000000000000000000000000000000000 . 50000 60016000f3 . 1 false\n")
+	assert.Contains(t, log, "FIRE EVM_END_CALL 2 10000 00 ")
+	assert.Equal(t, initCode, []byte(call.Input))
+	assert.Equal(t, runtimeCode, []byte(call.ReturnData))
+
+	// The input and return data of the other calls are recorded whatever the settings
+	assert.Contains(t, log, "FIRE EVM_END_CALL 1 50000 bb ")
+
+	// The init code is too large to be recorded but the runtime code is not
+	CreationCodeMaxSize = len(initCode) - 1
+	log, call = record()
+	assert.Contains(t, log, "FIRE EVM_PARAM CREATE 2 0100000000000000000000000000000000000000 c100000000000000000000000000000000000000 . 50000 . . 1 false\n")
+	assert.Empty(t, call.Input)
+	assert.Equal(t, runtimeCode, []byte(call.ReturnData))
+}
@@ -0,0 +1,1021 @@
+// Package decode parses the Firehose line format emitted by the instrumentation back into
+// typed Go structures, it's meant to be used by Go tooling consuming Firehose output
+// instead of re-implementing ad hoc parsers.
+//
+// The decoder is kept in sync with the encoder of the `firehose` package, it supports only
+// the protocol version defined by `firehose.ProtocolVersion()`.
+package decode
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
+)
+
+// recordFieldCounts is the number of fields of each known record, the last field of a
+// record can contain spaces.
+var recordFieldCounts = map[string]int{
+	"INIT":                         4,
+	"BLOCK_BEGIN":                  4,
+	"BLOCK_END":                    5,
+	"BLOCK_SEGMENT":                4,
+	"BLOCK_ABORT":                  2,
+	"BEGIN_BLOCK":                  5,
+	"NON_EXECUTED_BLOCK":           1,
+	"BACKFILLED_BLOCK":             1,
+	"REPLAYED_BLOCK":               1,
+	"PROPOSED_BLOCK":               2,
+	"FORK_ACTIVATION":              3,
+	"BLOCK_WITNESS":                1,
+	"CREATED_CONTRACTS":            1,
+	"CLIQUE_SEAL":                  1,
+	"SYSTEM_CALL_START":            0,
+	"SYSTEM_CALL_END":              0,
+	"SYSTEM_TRX":                   0,
+	"IRREGULAR_STATE_CHANGE_START": 2,
+	"IRREGULAR_STATE_CHANGE_END":   2,
+	"BEGIN_APPLY_TRX":              16,
+	"TRX_FROM":                     1,
+	"TRX_SIGNATURE":                2,
+	"SET_CODE_AUTHORIZATION":       6,
+	"EVM_RUN_CALL":                 10,
+	"EVM_PARAM":                    10,
+	"ACCOUNT_WITHOUT_CODE":         1,
+	"EVM_PRECOMPILE":               2,
+	"EVM_CALL_FAILED":              4,
+	"EVM_CREATE_FAILED":            4,
+	"EVM_REVERTED":                 1,
+	"EVM_END_CALL":                 7,
+	"EVM_KECCAK":                   3,
+	"GAS_CHANGE":                   5,
+	"STORAGE_CHANGE":               6,
+	"TRANSIENT_STORAGE_CHANGE":     6,
+	"BALANCE_CHANGE":               6,
+	"BALANCE_DELTA":                5,
+	"ADD_LOG":                      6,
+	"SUICIDE_CHANGE":               4,
+	"CREATED_ACCOUNT":              3,
+	"ACCOUNT_TOUCH":                3,
+	"DELETED_ACCOUNT":              4,
+	"CODE_CHANGE":                  7,
+	"NONCE_CHANGE":                 5,
+	"END_APPLY_TRX":                10,
+	"FINALIZE_BLOCK":               1,
+	"END_BLOCK":                    4,
+	"TRX_ABORT":                    6,
+	"HEAD_UPDATE":                  4,
+	"SIDE_CHAIN_BLOCK":             4,
+	"UNDO_BLOCK":                   4,
+	"HEARTBEAT":                    3,
+	"SYNC_PROGRESS":                3,
+	"PENDING_TRX":                  7,
+	"PENDING_DROP":                 2,
+	"PENDING_REPLACE":              2,
+	"PENDING_EXECUTABLE":           2,
+	"PENDING_TRX_EFFECTS":          7,
+}
+
+// Decoder reads Firehose records from a stream and assembles them back into typed
+// structures. Lines not starting with the `FIRE` prefix and unknown records (e.g.
+// mempool records) are skipped.
+type Decoder struct {
+	scanner *bufio.Scanner
+
+	// Block state
+	block     *Block
+	irregular *IrregularStateChange
+
+	// Transaction state, a system call being decoded as a transaction holding its calls,
+	// `systemTrx` is set by `SYSTEM_TRX` until the system transaction it flags begins
+	trx        *TransactionTrace
+	systemCall bool
+	systemTrx  bool
+	calls      map[string]*Call
+	callStack  []*Call
+
+	// Framing state, set between `BLOCK_BEGIN` and `BLOCK_END` markers
+	frame *blockFrame
+}
+
+// blockFrame tracks the records seen between the `BLOCK_BEGIN` and `BLOCK_END` markers
+// so that the framing can be validated once the `BLOCK_END` marker is received.
+type blockFrame struct {
+	number    uint64
+	hash      common.Hash
+	lineCount uint64
+	block     *Block
+
+	// Emission timing recorded by the `BLOCK_BEGIN` marker, see `BlockEmission`
+	beginTime    uint64
+	beginElapsed time.Duration
+
+	// Segments state, the lines received since the last `BLOCK_SEGMENT` marker, if any
+	segments         uint64
+	segmentLineCount uint64
+}
+
+// NewDecoder returns a decoder reading Firehose records from `reader`.
+func NewDecoder(reader io.Reader) *Decoder {
+	scanner := bufio.NewScanner(reader)
+	// Blocks can hold huge records (e.g. contract creation code), accept lines up to 100 MiB
+	scanner.Buffer(make([]byte, 0, 64*1024), 100*1024*1024)
+
+	return &Decoder{scanner: scanner}
+}
+
+// DecodeTransaction decodes the records of a single transaction recorded outside of any block,
+// like the ones of a `firehose.SpeculativeExecution`, from its `BEGIN_APPLY_TRX` record up to
+// its `END_APPLY_TRX` one.
+func DecodeTransaction(reader io.Reader) (*TransactionTrace, error) {
+	d := NewDecoder(reader)
+
+	// The transaction is decoded as the single one of a block which is never completed
+	d.block = &Block{}
+	element, err := d.Next()
+	if err == nil {
+		return nil, fmt.Errorf("unexpected %T element outside of the transaction", element)
+	}
+	if err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	if d.trx != nil {
+		return nil, fmt.Errorf("transaction %s is not completed", d.trx.Hash.Hex())
+	}
+	if len(d.block.Transactions) != 1 {
+		return nil, fmt.Errorf("%d transactions decoded, expected a single one", len(d.block.Transactions))
+	}
+
+	return d.block.Transactions[0], nil
+}
+
+// Next returns the next decoded element of the stream which is either an `*Init`, a
+// complete `*Block`, a `*TransactionAbort`, a `*BlockAbort`, a `*HeadUpdate`, a `*SideChainBlock`, an
+// `*UndoBlock`, a `*Heartbeat`, a `*PendingTransaction`, a `*PendingDrop` or a
+// `*PendingEffects`. It returns `io.EOF` once the stream is exhausted, `io.ErrUnexpectedEOF`
+// if the stream ends in the middle of a block.
+//
+// Blocks framed by `BLOCK_BEGIN`/`BLOCK_END` markers are returned only once the framing
+// was validated, an error is returned if the markers do not match the block or if the
+// number of lines between them differs from the one announced. The `BLOCK_SEGMENT` markers of
+// a block emitted in segments are validated the same way, they are not counted as lines of
+// the block.
+func (d *Decoder) Next() (interface{}, error) {
+	for d.scanner.Scan() {
+		line := d.scanner.Text()
+		if !strings.HasPrefix(line, "FIRE ") {
+			continue
+		}
+
+		record := strings.TrimPrefix(line, "FIRE ")
+		kind := record
+		if i := strings.IndexByte(record, ' '); i != -1 {
+			kind = record[:i]
+		}
+
+		if d.frame != nil && kind != "BLOCK_END" && kind != "BLOCK_SEGMENT" && kind != "BLOCK_ABORT" {
+			d.frame.lineCount++
+			d.frame.segmentLineCount++
+		}
+
+		count, known := recordFieldCounts[kind]
+		if !known {
+			continue
+		}
+
+		values := strings.SplitN(strings.TrimPrefix(record, kind+" "), " ", count)
+		if len(values) != count {
+			return nil, fmt.Errorf("%s record has %d fields, expected %d", kind, len(values), count)
+		}
+
+		element, err := d.decodeRecord(&fields{record: kind, values: values})
+		if err != nil {
+			return nil, err
+		}
+
+		if block, ok := element.(*Block); ok && d.frame != nil {
+			if d.frame.block != nil {
+				return nil, fmt.Errorf("block #%d framed by BLOCK_BEGIN marker of block #%d contains more than one block", block.Number, d.frame.number)
+			}
+
+			// Held until the framing is validated by the `BLOCK_END` marker
+			d.frame.block = block
+			continue
+		}
+
+		if element != nil {
+			return element, nil
+		}
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if d.block != nil || d.frame != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return nil, io.EOF
+}
+
+func (d *Decoder) decodeRecord(f *fields) (element interface{}, err error) {
+	switch f.record {
+	case "INIT":
+		init := &Init{ProtocolVersion: f.string(0), Variant: f.string(1), NodeVersion: f.string(2)}
+		if err := json.Unmarshal([]byte(f.string(3)), &init.ChainConfig); err != nil {
+			return nil, fmt.Errorf("INIT record chain config: %w", err)
+		}
+
+		element = init
+
+	case "BLOCK_BEGIN":
+		if d.frame != nil {
+			return nil, fmt.Errorf("BLOCK_BEGIN marker for block #%s while block #%d framing is not completed", f.string(0), d.frame.number)
+		}
+
+		d.frame = &blockFrame{number: f.uint64(0), hash: f.hash(1), beginTime: f.uint64(2), beginElapsed: time.Duration(f.uint64(3))}
+
+	case "BLOCK_END":
+		frame := d.frame
+		if frame == nil {
+			return nil, fmt.Errorf("BLOCK_END marker for block #%s without a BLOCK_BEGIN marker", f.string(0))
+		}
+		d.frame = nil
+
+		number, hash, lineCount := f.uint64(0), f.hash(1), f.uint64(2)
+		if f.err != nil {
+			return nil, f.err
+		}
+
+		if number != frame.number || hash != frame.hash {
+			return nil, fmt.Errorf("BLOCK_END marker for block #%d (%s) does not match BLOCK_BEGIN marker for block #%d (%s)", number, hash.Hex(), frame.number, frame.hash.Hex())
+		}
+
+		if lineCount != frame.lineCount {
+			return nil, fmt.Errorf("block #%d framing announced %d lines but %d were received", number, lineCount, frame.lineCount)
+		}
+
+		if frame.block == nil {
+			return nil, fmt.Errorf("block #%d framing does not contain any complete block", number)
+		}
+
+		if frame.block.Number != number || frame.block.Header.Hash() != hash {
+			return nil, fmt.Errorf("block #%d (%s) framed by markers of block #%d (%s)", frame.block.Number, frame.block.Header.Hash().Hex(), number, hash.Hex())
+		}
+
+		// The timestamps are 0 when the timings are disabled
+		if endTime := f.uint64(3); endTime != 0 {
+			frame.block.Emission = &BlockEmission{
+				BeginTime:    frame.beginTime,
+				EndTime:      endTime,
+				BeginElapsed: frame.beginElapsed,
+				EndElapsed:   time.Duration(f.uint64(4)),
+			}
+		}
+
+		element = frame.block
+
+	case "BLOCK_SEGMENT":
+		frame := d.frame
+		if frame == nil {
+			return nil, fmt.Errorf("BLOCK_SEGMENT marker for block #%s without a BLOCK_BEGIN marker", f.string(0))
+		}
+
+		number, hash, sequence, lineCount := f.uint64(0), f.hash(1), f.uint64(2), f.uint64(3)
+		if f.err != nil {
+			return nil, f.err
+		}
+
+		if number != frame.number || hash != frame.hash {
+			return nil, fmt.Errorf("BLOCK_SEGMENT marker for block #%d (%s) does not match BLOCK_BEGIN marker for block #%d (%s)", number, hash.Hex(), frame.number, frame.hash.Hex())
+		}
+
+		if sequence != frame.segments {
+			return nil, fmt.Errorf("block #%d segment %d received while segment %d was expected", number, sequence, frame.segments)
+		}
+
+		if lineCount != frame.segmentLineCount {
+			return nil, fmt.Errorf("block #%d segment %d announced %d lines but %d were received", number, sequence, lineCount, frame.segmentLineCount)
+		}
+
+		frame.segments++
+		frame.segmentLineCount = 0
+
+	case "BLOCK_ABORT":
+		frame := d.frame
+		if frame == nil {
+			return nil, fmt.Errorf("BLOCK_ABORT marker for block #%s without a BLOCK_BEGIN marker", f.string(0))
+		}
+
+		abort := &BlockAbort{Number: f.uint64(0), Hash: f.hash(1)}
+		if f.err != nil {
+			return nil, f.err
+		}
+
+		if abort.Number != frame.number || abort.Hash != frame.hash {
+			return nil, fmt.Errorf("BLOCK_ABORT marker for block #%d (%s) does not match BLOCK_BEGIN marker for block #%d (%s)", abort.Number, abort.Hash.Hex(), frame.number, frame.hash.Hex())
+		}
+
+		// The segments received are discarded, the block is emitted again from scratch, if ever
+		d.frame, d.block, d.trx, d.irregular = nil, nil, nil, nil
+		element = abort
+
+	case "TRX_ABORT":
+		abort := &TransactionAbort{
+			BlockNumber:  f.uint64(0),
+			BlockHash:    f.hash(1),
+			TxIndex:      f.uint64(2),
+			TxHash:       f.hash(3),
+			PartialTrace: f.bytes(4),
+		}
+
+		if err := json.Unmarshal([]byte(f.string(5)), &abort.Message); err != nil {
+			return nil, fmt.Errorf("TRX_ABORT record message: %w", err)
+		}
+
+		// The aborted block is never completed, a new attempt starts from scratch
+		d.block, d.trx, d.irregular = nil, nil, nil
+		element = abort
+
+	case "HEAD_UPDATE":
+		if d.block != nil {
+			return nil, fmt.Errorf("HEAD_UPDATE record while block #%d is not completed", d.block.Number)
+		}
+
+		element = &HeadUpdate{
+			Number:         f.uint64(0),
+			Hash:           f.hash(1),
+			PreviousNumber: f.uint64(2),
+			PreviousHash:   f.hash(3),
+		}
+
+	case "SIDE_CHAIN_BLOCK":
+		if d.block != nil {
+			return nil, fmt.Errorf("SIDE_CHAIN_BLOCK record while block #%d is not completed", d.block.Number)
+		}
+
+		element = &SideChainBlock{
+			Number:           f.uint64(0),
+			Hash:             f.hash(1),
+			ForkParentNumber: f.uint64(2),
+			ForkParentHash:   f.hash(3),
+		}
+
+	case "UNDO_BLOCK":
+		if d.block != nil {
+			return nil, fmt.Errorf("UNDO_BLOCK record while block #%d is not completed", d.block.Number)
+		}
+
+		undo := &UndoBlock{
+			Number:     f.uint64(0),
+			Hash:       f.hash(1),
+			ParentHash: f.hash(2),
+		}
+		if err := json.Unmarshal([]byte(f.string(3)), &undo.Logs); err != nil {
+			return nil, fmt.Errorf("UNDO_BLOCK record logs: %w", err)
+		}
+		element = undo
+
+	case "HEARTBEAT":
+		if d.block != nil {
+			return nil, fmt.Errorf("HEARTBEAT record while block #%d is not completed", d.block.Number)
+		}
+
+		element = &Heartbeat{
+			HeadNumber: f.uint64(0),
+			HeadHash:   f.hash(1),
+			Timestamp:  f.uint64(2),
+		}
+
+	case "SYNC_PROGRESS":
+		if d.block != nil {
+			return nil, fmt.Errorf("SYNC_PROGRESS record while block #%d is not completed", d.block.Number)
+		}
+
+		element = &SyncProgress{
+			Stage:  f.string(0),
+			Number: f.uint64(1),
+			Hash:   f.hash(2),
+		}
+
+	case "PENDING_TRX":
+		if d.block != nil {
+			return nil, fmt.Errorf("PENDING_TRX record while block #%d is not completed", d.block.Number)
+		}
+
+		element = &PendingTransaction{
+			Hash:      f.hash(0),
+			From:      f.address(1),
+			To:        f.optionalAddress(2),
+			Nonce:     f.uint64(3),
+			GasPrice:  f.bigInt(4),
+			GasLimit:  f.uint64(5),
+			InputHash: f.hash(6),
+		}
+
+	case "PENDING_DROP":
+		if d.block != nil {
+			return nil, fmt.Errorf("PENDING_DROP record while block #%d is not completed", d.block.Number)
+		}
+
+		element = &PendingDrop{Hash: f.hash(0), Reason: f.string(1)}
+
+	case "PENDING_REPLACE":
+		if d.block != nil {
+			return nil, fmt.Errorf("PENDING_REPLACE record while block #%d is not completed", d.block.Number)
+		}
+
+		element = &PendingReplacement{Hash: f.hash(0), ReplacedHash: f.hash(1)}
+
+	case "PENDING_EXECUTABLE":
+		if d.block != nil {
+			return nil, fmt.Errorf("PENDING_EXECUTABLE record while block #%d is not completed", d.block.Number)
+		}
+
+		element = &PendingExecutable{Hash: f.hash(0), GapFillerHash: f.hash(1)}
+
+	case "PENDING_TRX_EFFECTS":
+		if d.block != nil {
+			return nil, fmt.Errorf("PENDING_TRX_EFFECTS record while block #%d is not completed", d.block.Number)
+		}
+
+		effects := &PendingEffects{
+			Hash:       f.hash(0),
+			HeadNumber: f.uint64(1),
+			HeadHash:   f.hash(2),
+			Status:     f.string(3),
+			GasUsed:    f.uint64(4),
+		}
+		if err := json.Unmarshal([]byte(f.string(5)), &effects.Logs); err != nil {
+			return nil, fmt.Errorf("PENDING_TRX_EFFECTS record logs: %w", err)
+		}
+		if err := json.Unmarshal([]byte(f.string(6)), &effects.Transfers); err != nil {
+			return nil, fmt.Errorf("PENDING_TRX_EFFECTS record transfers: %w", err)
+		}
+		element = effects
+
+	case "BEGIN_BLOCK":
+		if d.block != nil {
+			return nil, fmt.Errorf("BEGIN_BLOCK record for block #%s while block #%d is not completed", f.string(0), d.block.Number)
+		}
+
+		d.block = &Block{
+			Number:    f.uint64(0),
+			GasLimit:  f.uint64(1),
+			GasTarget: f.uint64(2),
+			BaseFee:   f.optionalBigInt(3),
+			LogsBloom: f.bytes(4),
+		}
+
+	default:
+		if d.block == nil {
+			return nil, fmt.Errorf("%s record received outside of a block", f.record)
+		}
+
+		element, err = d.decodeBlockRecord(f)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return element, nil
+}
+
+func (d *Decoder) decodeBlockRecord(f *fields) (element interface{}, err error) {
+	switch f.record {
+	case "BEGIN_APPLY_TRX":
+		if d.trx != nil {
+			return nil, fmt.Errorf("BEGIN_APPLY_TRX record while transaction %s is not completed", d.trx.Hash.Hex())
+		}
+
+		if d.block.Finalized && !d.systemTrx {
+			return nil, fmt.Errorf("BEGIN_APPLY_TRX record after FINALIZE_BLOCK without a SYSTEM_TRX record, only system transactions are applied once the block is finalized")
+		}
+
+		d.trx = &TransactionTrace{
+			Hash:         f.hash(0),
+			To:           f.optionalAddress(1),
+			Value:        f.bigInt(2),
+			V:            f.bytes(3),
+			R:            f.bytes(4),
+			S:            f.bytes(5),
+			GasLimit:     f.uint64(6),
+			GasPrice:     f.bigInt(7),
+			Nonce:        f.uint64(8),
+			Input:        f.bytes(9),
+			AccessList:   f.bytes(10),
+			Type:         uint8(f.uint64(13)),
+			BeginOrdinal: f.uint64(14),
+			Index:        f.uint64(15),
+			System:       d.systemTrx,
+		}
+		d.systemTrx = false
+		d.calls = map[string]*Call{}
+		d.callStack = nil
+
+	case "SYSTEM_TRX":
+		if d.trx != nil {
+			return nil, fmt.Errorf("SYSTEM_TRX record while transaction %s is not completed", d.trx.Hash.Hex())
+		}
+
+		if d.systemTrx {
+			return nil, fmt.Errorf("SYSTEM_TRX record while the previous one was not followed by its transaction")
+		}
+
+		d.systemTrx = true
+
+	case "SYSTEM_CALL_START":
+		if d.trx != nil {
+			return nil, fmt.Errorf("SYSTEM_CALL_START record while transaction %s is not completed", d.trx.Hash.Hex())
+		}
+
+		d.trx = &TransactionTrace{}
+		d.systemCall = true
+		d.calls = map[string]*Call{}
+		d.callStack = nil
+
+	case "SYSTEM_CALL_END":
+		if !d.systemCall {
+			return nil, fmt.Errorf("SYSTEM_CALL_END record without its start record")
+		}
+
+		if len(d.callStack) != 0 {
+			return nil, fmt.Errorf("SYSTEM_CALL_END record while %d call(s) are still open", len(d.callStack))
+		}
+
+		d.block.SystemCalls = append(d.block.SystemCalls, d.trx.Calls...)
+		d.trx = nil
+		d.systemCall = false
+
+	case "END_APPLY_TRX":
+		trx, err := d.activeTransaction(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if d.systemCall {
+			return nil, fmt.Errorf("END_APPLY_TRX record while a system call is not completed")
+		}
+
+		if len(d.callStack) != 0 {
+			return nil, fmt.Errorf("END_APPLY_TRX record while %d call(s) are still open", len(d.callStack))
+		}
+
+		trx.GasUsed = f.uint64(0)
+		trx.PostState = f.bytes(1)
+		trx.CumulativeGasUsed = f.uint64(2)
+		trx.LogsBloom = f.bytes(3)
+		trx.EndOrdinal = f.uint64(4)
+		trx.Duration = time.Duration(f.uint64(5))
+		trx.Status = f.uint64(6)
+		trx.EffectiveGasPrice = f.bigInt(7)
+		trx.ContractAddress = f.optionalAddress(8)
+
+		if err := json.Unmarshal([]byte(f.string(9)), &trx.ReceiptLogs); err != nil {
+			return nil, fmt.Errorf("END_APPLY_TRX record logs: %w", err)
+		}
+
+		d.block.Transactions = append(d.block.Transactions, trx)
+		d.trx = nil
+
+	case "TRX_FROM":
+		trx, err := d.activeTransaction(f)
+		if err != nil {
+			return nil, err
+		}
+
+		trx.From = f.address(0)
+
+	case "TRX_SIGNATURE":
+		trx, err := d.activeTransaction(f)
+		if err != nil {
+			return nil, err
+		}
+
+		trx.SignatureScheme = f.string(0)
+		trx.YParity = f.uint64(1)
+
+	case "SET_CODE_AUTHORIZATION":
+		trx, err := d.activeTransaction(f)
+		if err != nil {
+			return nil, err
+		}
+
+		trx.SetCodeAuthorizations = append(trx.SetCodeAuthorizations, &SetCodeAuthorization{
+			ChainID:   f.bigInt(0),
+			Address:   f.address(1),
+			Nonce:     f.uint64(2),
+			Authority: f.optionalAddress(3),
+			Applied:   f.bool(4),
+			Ordinal:   f.uint64(5),
+		})
+
+	case "NON_EXECUTED_BLOCK":
+		d.block.NonExecuted = true
+
+	case "BACKFILLED_BLOCK":
+		d.block.Backfilled = true
+
+	case "REPLAYED_BLOCK":
+		d.block.Replayed = true
+
+	case "PROPOSED_BLOCK":
+		d.block.Proposed = true
+		d.block.ProposedTaskID = f.uint64(1)
+
+	case "FORK_ACTIVATION":
+		d.block.ForkActivations = append(d.block.ForkActivations, &ForkActivation{
+			Name:      f.string(0),
+			Number:    f.uint64(1),
+			Timestamp: f.uint64(2),
+		})
+
+	case "BLOCK_WITNESS":
+		if err := json.Unmarshal([]byte(f.string(0)), &d.block.Witness); err != nil {
+			return nil, fmt.Errorf("BLOCK_WITNESS record: %w", err)
+		}
+
+	case "CREATED_CONTRACTS":
+		if err := json.Unmarshal([]byte(f.string(0)), &d.block.CreatedContracts); err != nil {
+			return nil, fmt.Errorf("CREATED_CONTRACTS record: %w", err)
+		}
+
+	case "CLIQUE_SEAL":
+		if err := json.Unmarshal([]byte(f.string(0)), &d.block.CliqueSeal); err != nil {
+			return nil, fmt.Errorf("CLIQUE_SEAL record: %w", err)
+		}
+
+	case "IRREGULAR_STATE_CHANGE_START":
+		if d.trx != nil {
+			return nil, fmt.Errorf("IRREGULAR_STATE_CHANGE_START record while transaction %s is not completed", d.trx.Hash.Hex())
+		}
+		if d.irregular != nil {
+			return nil, fmt.Errorf("IRREGULAR_STATE_CHANGE_START record while irregular state change %q is not completed", d.irregular.Reason)
+		}
+
+		d.irregular = &IrregularStateChange{Reason: firehose.IrregularStateChangeReason(f.string(0)), BeginOrdinal: f.uint64(1)}
+
+	case "IRREGULAR_STATE_CHANGE_END":
+		if d.irregular == nil || string(d.irregular.Reason) != f.string(0) {
+			return nil, fmt.Errorf("IRREGULAR_STATE_CHANGE_END record of %q without its start record", f.string(0))
+		}
+
+		d.irregular.EndOrdinal = f.uint64(1)
+		d.block.IrregularStateChanges = append(d.block.IrregularStateChanges, d.irregular)
+		d.irregular = nil
+
+	case "FINALIZE_BLOCK":
+		d.block.Finalized = true
+
+	case "END_BLOCK":
+		if d.trx != nil {
+			return nil, fmt.Errorf("END_BLOCK record while transaction %s is not completed", d.trx.Hash.Hex())
+		}
+
+		if d.systemTrx {
+			return nil, fmt.Errorf("END_BLOCK record while the SYSTEM_TRX record was not followed by its transaction")
+		}
+
+		var data struct {
+			Header          *types.Header   `json:"header"`
+			Uncles          []*types.Header `json:"uncles"`
+			TotalDifficulty *hexutil.Big    `json:"totalDifficulty"`
+			Counts          *RecordCounts   `json:"counts"`
+		}
+		if err := json.Unmarshal([]byte(f.string(3)), &data); err != nil {
+			return nil, fmt.Errorf("END_BLOCK record data: %w", err)
+		}
+
+		if data.Header == nil {
+			return nil, fmt.Errorf("END_BLOCK record data has no header")
+		}
+
+		if d.irregular != nil {
+			return nil, fmt.Errorf("END_BLOCK record while irregular state change %q is not completed", d.irregular.Reason)
+		}
+
+		block := d.block
+		block.Size = f.uint64(1)
+		block.Duration = time.Duration(f.uint64(2))
+		block.Header = data.Header
+		block.Uncles = data.Uncles
+		block.TotalDifficulty = (*big.Int)(data.TotalDifficulty)
+		block.Counts = data.Counts
+
+		// The counts are only recorded from fh2.34 on
+		if data.Counts != nil {
+			if decoded := block.CountRecords(); *decoded != *data.Counts {
+				return nil, fmt.Errorf("END_BLOCK record counts %+v do not match the %+v records decoded for block #%d", *data.Counts, *decoded, block.Number)
+			}
+		}
+
+		d.block = nil
+		element = block
+
+	default:
+		err = d.decodeChangeRecord(f)
+	}
+
+	return element, err
+}
+
+func (d *Decoder) decodeChangeRecord(f *fields) error {
+	switch f.record {
+	case "EVM_RUN_CALL":
+		trx, err := d.activeTransaction(f)
+		if err != nil {
+			return err
+		}
+
+		call := &Call{
+			CallType:     firehose.CallType(f.string(0)),
+			Index:        f.uint64(1),
+			BeginOrdinal: f.uint64(2),
+			ExecutedCode: true,
+		}
+		if opCode := f.string(3); opCode != "." {
+			call.OpCode = opCode
+		}
+		call.Precompile = f.bool(4)
+		call.CodeHash = f.optionalHash(5)
+		call.HasCode = f.bool(6)
+		call.ParentIndex = f.uint64(7)
+		call.ParentOrdinal = f.uint64(8)
+		call.Depth = f.uint64(9)
+		if f.err != nil {
+			return f.err
+		}
+
+		var parentIndex, parentOrdinal uint64
+		if len(d.callStack) > 0 {
+			parent := d.callStack[len(d.callStack)-1]
+			parentIndex, parentOrdinal = parent.Index, parent.BeginOrdinal
+		}
+		if call.ParentIndex != parentIndex || call.ParentOrdinal != parentOrdinal || call.Depth != uint64(len(d.callStack)) {
+			return fmt.Errorf("EVM_RUN_CALL record for call #%d references parent call #%d (ordinal %d) at depth %d while the active call is #%d (ordinal %d) at depth %d",
+				call.Index, call.ParentIndex, call.ParentOrdinal, call.Depth, parentIndex, parentOrdinal, len(d.callStack))
+		}
+
+		d.calls[f.string(1)] = call
+		d.callStack = append(d.callStack, call)
+		trx.Calls = append(trx.Calls, call)
+		return nil
+
+	case "BALANCE_CHANGE", "BALANCE_DELTA":
+		change := &BalanceChange{Address: f.address(1)}
+		if f.record == "BALANCE_DELTA" {
+			change.Delta = f.signedBigInt(2)
+			change.Reason = firehose.BalanceChangeReason(f.string(3))
+			change.Ordinal = f.uint64(4)
+		} else {
+			change.Old = f.bigInt(2)
+			change.New = f.bigInt(3)
+			change.Reason = firehose.BalanceChangeReason(f.string(4))
+			change.Ordinal = f.uint64(5)
+		}
+
+		// Out of transactions, balance changes are recorded at the block level
+		if d.trx == nil {
+			d.block.BalanceChanges = append(d.block.BalanceChanges, change)
+			return nil
+		}
+
+		call, err := d.call(f)
+		if err != nil {
+			return err
+		}
+
+		if call == nil {
+			d.trx.BalanceChanges = append(d.trx.BalanceChanges, change)
+		} else {
+			call.BalanceChanges = append(call.BalanceChanges, change)
+		}
+		return nil
+	}
+
+	if _, err := d.activeTransaction(f); err != nil {
+		return err
+	}
+
+	call, err := d.call(f)
+	if err != nil {
+		return err
+	}
+
+	switch f.record {
+	case "GAS_CHANGE":
+		change := &GasChange{Old: f.uint64(1), New: f.uint64(2), Reason: firehose.GasChangeReason(f.string(3)), Ordinal: f.uint64(4)}
+		if call == nil {
+			d.trx.GasChanges = append(d.trx.GasChanges, change)
+		} else {
+			call.GasChanges = append(call.GasChanges, change)
+		}
+
+	case "NONCE_CHANGE":
+		change := &NonceChange{Address: f.address(1), Old: f.uint64(2), New: f.uint64(3), Ordinal: f.uint64(4)}
+		if call == nil {
+			d.trx.NonceChanges = append(d.trx.NonceChanges, change)
+		} else {
+			call.NonceChanges = append(call.NonceChanges, change)
+		}
+
+	case "CREATED_ACCOUNT":
+		change := &CreatedAccount{Address: f.address(1), Ordinal: f.uint64(2)}
+		if call == nil {
+			d.trx.CreatedAccounts = append(d.trx.CreatedAccounts, change)
+		} else {
+			call.CreatedAccounts = append(call.CreatedAccounts, change)
+		}
+
+	case "DELETED_ACCOUNT":
+		d.trx.DeletedAccounts = append(d.trx.DeletedAccounts, &DeletedAccount{
+			Address: f.address(1),
+			Reason:  firehose.AccountDeletionReason(f.string(2)),
+			Ordinal: f.uint64(3),
+		})
+
+	case "ACCOUNT_TOUCH":
+		if call != nil {
+			call.AccountTouches = append(call.AccountTouches, &AccountTouch{Address: f.address(1), Ordinal: f.uint64(2)})
+		}
+
+	case "CODE_CHANGE":
+		change := &CodeChange{
+			Address:     f.address(1),
+			OldCodeHash: f.bytes(2),
+			OldCode:     f.bytes(3),
+			NewCodeHash: f.hash(4),
+			NewCode:     f.bytes(5),
+			Ordinal:     f.uint64(6),
+		}
+		if call == nil {
+			d.trx.CodeChanges = append(d.trx.CodeChanges, change)
+		} else {
+			call.CodeChanges = append(call.CodeChanges, change)
+		}
+
+	default:
+		if call == nil {
+			return fmt.Errorf("%s record received while no call is active", f.record)
+		}
+
+		return d.decodeCallRecord(f, call)
+	}
+
+	return nil
+}
+
+func (d *Decoder) decodeCallRecord(f *fields, call *Call) error {
+	switch f.record {
+	case "EVM_PARAM":
+		call.Caller = f.address(2)
+		call.Address = f.address(3)
+		call.Value = f.bigInt(4)
+		call.GasLimit = f.uint64(5)
+		call.Input = f.bytes(6)
+		call.DelegateCaller = f.optionalAddress(7)
+		call.Depth = f.uint64(8)
+		call.ReadOnly = f.bool(9)
+
+	case "ACCOUNT_WITHOUT_CODE":
+		call.ExecutedCode = false
+
+	case "EVM_PRECOMPILE":
+		call.Precompile = true
+		if name := f.string(1); name != "." {
+			call.PrecompileName = name
+		}
+
+	case "EVM_CALL_FAILED":
+		call.Failed = true
+		call.FailureCode = firehose.CallFailureCode(f.string(2))
+		call.FailureReason = f.string(3)
+
+	case "EVM_CREATE_FAILED":
+		if call.CallType != firehose.CallTypeCreate {
+			return fmt.Errorf("EVM_CREATE_FAILED record for call #%d which is not a contract creation", call.Index)
+		}
+
+		call.CreateFailure = &CreateFailure{
+			Code:           firehose.CallFailureCode(f.string(1)),
+			GasDisposition: firehose.GasDisposition(f.string(2)),
+			Gas:            f.uint64(3),
+		}
+
+	case "EVM_REVERTED":
+		call.Reverted = true
+
+	case "EVM_END_CALL":
+		if len(d.callStack) == 0 || d.callStack[len(d.callStack)-1] != call {
+			return fmt.Errorf("EVM_END_CALL record for call #%d is not closing the active call", call.Index)
+		}
+
+		d.callStack = d.callStack[:len(d.callStack)-1]
+		call.GasLeft = f.uint64(1)
+		call.ReturnData = f.bytes(2)
+		call.EndOrdinal = f.uint64(3)
+		call.GasUsed = f.uint64(5)
+		call.GasRetained = f.uint64(6)
+		if gasLimit := f.uint64(4); f.err == nil && gasLimit != call.GasLimit {
+			return fmt.Errorf("EVM_END_CALL record for call #%d accounts a gas limit of %d while its parameters record %d", call.Index, gasLimit, call.GasLimit)
+		}
+
+	case "EVM_KECCAK":
+		if call.KeccakPreimages == nil {
+			call.KeccakPreimages = map[common.Hash]hexutil.Bytes{}
+		}
+		call.KeccakPreimages[f.hash(1)] = f.bytes(2)
+
+	case "STORAGE_CHANGE":
+		call.StorageChanges = append(call.StorageChanges, &StorageChange{
+			Address: f.address(1),
+			Key:     f.hash(2),
+			Old:     f.hash(3),
+			New:     f.hash(4),
+			Ordinal: f.uint64(5),
+		})
+
+	case "TRANSIENT_STORAGE_CHANGE":
+		call.TransientStorageChanges = append(call.TransientStorageChanges, &StorageChange{
+			Address: f.address(1),
+			Key:     f.hash(2),
+			Old:     f.hash(3),
+			New:     f.hash(4),
+			Ordinal: f.uint64(5),
+		})
+
+	case "ADD_LOG":
+		log := &Log{
+			IndexInBlock: f.uint64(1),
+			Address:      f.address(2),
+			Data:         f.bytes(4),
+			Ordinal:      f.uint64(5),
+		}
+
+		if topics := f.string(3); topics != "" {
+			for i, topic := range strings.Split(topics, ",") {
+				topicFields := &fields{record: f.record + " topic", values: []string{topic}}
+				log.Topics = append(log.Topics, topicFields.hash(0))
+				if topicFields.err != nil {
+					return fmt.Errorf("ADD_LOG record topic #%d: %w", i, topicFields.err)
+				}
+			}
+		}
+
+		call.Logs = append(call.Logs, log)
+
+	case "SUICIDE_CHANGE":
+		call.SuicideChanges = append(call.SuicideChanges, &SuicideChange{
+			Address:       f.address(1),
+			Suicided:      f.bool(2),
+			BalanceBefore: f.bigInt(3),
+		})
+
+	}
+
+	return nil
+}
+
+func (d *Decoder) activeTransaction(f *fields) (*TransactionTrace, error) {
+	if d.trx == nil {
+		return nil, fmt.Errorf("%s record received outside of a transaction", f.record)
+	}
+
+	return d.trx, nil
+}
+
+// call returns the call referenced by the record's call index, which is always the first
+// field of the record except for the call records, nil when the index is "0" meaning that
+// no call is active.
+func (d *Decoder) call(f *fields) (*Call, error) {
+	index := f.string(0)
+	if f.record == "EVM_PARAM" {
+		index = f.string(1)
+	}
+
+	if index == "0" {
+		return nil, nil
+	}
+
+	call, found := d.calls[index]
+	if !found {
+		return nil, fmt.Errorf("%s record references unknown call #%s", f.record, index)
+	}
+
+	return call, nil
+}
@@ -0,0 +1,1208 @@
+package decode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	miner    = common.HexToAddress("0xaa")
+	sender   = common.HexToAddress("0xbb")
+	proxy    = common.HexToAddress("0xcc")
+	logic    = common.HexToAddress("0xdd")
+	created  = common.HexToAddress("0xee")
+	trxHash  = common.HexToHash("0x01")
+	slotKey  = common.HexToHash("0x02")
+	slotNew  = common.HexToHash("0x03")
+	topic    = common.HexToHash("0x04")
+	codeHash = common.HexToHash("0x05")
+
+	proxyCodeHash = common.HexToHash("0x06")
+	logicCodeHash = common.HexToHash("0x07")
+	initCodeHash  = common.HexToHash("0x08")
+)
+
+func TestDecoder_RoundTrip(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(7), Difficulty: big.NewInt(2), GasLimit: 8_000_000, GasUsed: 50_000, Coinbase: miner}
+	block := types.NewBlockWithHeader(header)
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+
+	ctx.StartBlock(block)
+	ctx.StartTransactionRaw(trxHash, &proxy, big.NewInt(10), []byte{0x1b}, []byte{0x0a}, []byte{0x0b}, 100_000, big.NewInt(1), 3, []byte{0xca, 0xfe}, nil, nil, nil, types.LegacyTxType, 0)
+	ctx.RecordTrxFrom(sender)
+	ctx.RecordBalanceChange(sender, big.NewInt(1_000_000), big.NewInt(900_000), firehose.BalanceChangeReason("gas_buy"))
+	ctx.RecordGasConsume(100_000, 21_000, firehose.GasChangeReason("intrinsic_gas"))
+
+	ctx.StartCall(firehose.CallTypeCall, "", false, proxyCodeHash, true)
+	ctx.RecordCallParams(firehose.CallTypeCall, sender, proxy, big.NewInt(10), 79_000, []byte{0xca, 0xfe}, 0, false)
+	ctx.RecordBalanceChange(sender, big.NewInt(900_000), big.NewInt(899_990), firehose.BalanceChangeReason("transfer"))
+
+	ctx.RecordCallGasRetention(1_000)
+	ctx.StartCall(firehose.CallTypeDelegate, "DELEGATECALL", false, logicCodeHash, true)
+	ctx.RecordDelegateCallParams(proxy, sender, logic, big.NewInt(10), 70_000, nil, 1, true)
+	ctx.RecordKeccak(topic, []byte("preimage"))
+	ctx.RecordStorageChange(proxy, slotKey, common.Hash{}, slotNew)
+	ctx.RecordLog(&types.Log{Address: proxy, Topics: []common.Hash{topic}, Data: []byte{0x01}})
+	ctx.EndCall(60_000, []byte{0x02})
+
+	ctx.StartCall(firehose.CallTypeCreate, "CREATE2", false, initCodeHash, true)
+	ctx.RecordCallParams(firehose.CallTypeCreate, proxy, created, firehose.EmptyValue, 50_000, nil, 1, false)
+	ctx.RecordNonceChange(created, 0, 1)
+	ctx.RecordNewAccount(created)
+	ctx.EndFailedCall(50_000, true, firehose.ExecutionRevertedCallFailureCode, "execution reverted: some reason")
+
+	ctx.StartCall(firehose.CallTypeCall, "CALL", false, common.Hash{}, false)
+	ctx.RecordCallParams(firehose.CallTypeCall, proxy, created, firehose.EmptyValue, 2_300, nil, 1, false)
+	ctx.RecordCallWithoutCode()
+	ctx.RecordCodeChange(created, nil, nil, codeHash, []byte{0x60})
+	ctx.RecordSuicide(created, true, big.NewInt(5))
+	ctx.EndCall(2_300, nil)
+
+	ctx.EndCall(10_000, nil)
+	ctx.EndTransaction(&types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		GasUsed:           50_000,
+		CumulativeGasUsed: 50_000,
+		PostState:         []byte{0x0f},
+		Logs:              []*types.Log{{Address: proxy, Topics: []common.Hash{topic}, Data: []byte{0x01}, Index: 4}},
+	})
+	ctx.FinalizeBlock(block)
+	ctx.RecordBalanceChange(miner, nil, big.NewInt(2), firehose.BalanceChangeReason("reward_mine_block"))
+	ctx.EndBlock(block, big.NewInt(14))
+
+	decoder := NewDecoder(strings.NewReader("not a firehose line\n" + buffer.String()))
+
+	element, err := decoder.Next()
+	require.NoError(t, err)
+	require.IsType(t, &Block{}, element)
+	decoded := element.(*Block)
+
+	_, err = decoder.Next()
+	assert.Equal(t, io.EOF, err)
+
+	assert.Equal(t, uint64(7), decoded.Number)
+	assert.Equal(t, block.Hash(), decoded.Header.Hash())
+	assert.Equal(t, big.NewInt(14), decoded.TotalDifficulty)
+	assert.True(t, decoded.Finalized)
+	assert.Equal(t, uint64(8_000_000), decoded.GasLimit)
+	assert.Equal(t, uint64(8_000_000), decoded.GasTarget)
+	assert.Nil(t, decoded.BaseFee)
+	assert.Equal(t, header.Bloom.Bytes(), []byte(decoded.LogsBloom))
+	assert.Equal(t, []*BalanceChange{
+		{Address: miner, Old: new(big.Int), New: big.NewInt(2), Reason: "reward_mine_block", Ordinal: 20},
+	}, decoded.BalanceChanges)
+
+	require.Len(t, decoded.Transactions, 1)
+	trx := decoded.Transactions[0]
+
+	assert.Equal(t, &TransactionTrace{
+		Hash:         trxHash,
+		To:           &proxy,
+		From:         sender,
+		Value:        big.NewInt(10),
+		V:            []byte{0x1b},
+		R:            []byte{0x0a},
+		S:            []byte{0x0b},
+		GasLimit:     100_000,
+		GasPrice:     big.NewInt(1),
+		Nonce:        3,
+		Input:        []byte{0xca, 0xfe},
+		AccessList:   []byte{0x00},
+		Type:         types.LegacyTxType,
+		BeginOrdinal: 1,
+		EndOrdinal:   19,
+
+		GasUsed:           50_000,
+		PostState:         []byte{0x0f},
+		CumulativeGasUsed: 50_000,
+		LogsBloom:         make([]byte, types.BloomByteLength),
+		ReceiptLogs:       []*ReceiptLog{{Address: proxy, Topics: []common.Hash{topic}, Data: []byte{0x01}, Index: 4}},
+		Status:            types.ReceiptStatusSuccessful,
+		EffectiveGasPrice: big.NewInt(1),
+
+		Calls: trx.Calls,
+
+		BalanceChanges: []*BalanceChange{{Address: sender, Old: big.NewInt(1_000_000), New: big.NewInt(900_000), Reason: "gas_buy", Ordinal: 2}},
+		GasChanges:     []*GasChange{{Old: 100_000, New: 79_000, Reason: "intrinsic_gas", Ordinal: 3}},
+	}, trx)
+
+	require.Len(t, trx.Calls, 4)
+	assert.Equal(t, &Call{
+		Index:          1,
+		CallType:       firehose.CallTypeCall,
+		Caller:         sender,
+		Address:        proxy,
+		Value:          big.NewInt(10),
+		GasLimit:       79_000,
+		GasLeft:        10_000,
+		GasUsed:        69_000,
+		Input:          []byte{0xca, 0xfe},
+		HasCode:        true,
+		CodeHash:       &proxyCodeHash,
+		ExecutedCode:   true,
+		BeginOrdinal:   4,
+		EndOrdinal:     18,
+		BalanceChanges: []*BalanceChange{{Address: sender, Old: big.NewInt(900_000), New: big.NewInt(899_990), Reason: "transfer", Ordinal: 5}},
+	}, trx.Calls[0])
+
+	assert.Equal(t, &Call{
+		Index:           2,
+		ParentIndex:     1,
+		ParentOrdinal:   4,
+		Depth:           1,
+		CallType:        firehose.CallTypeDelegate,
+		OpCode:          "DELEGATECALL",
+		Caller:          proxy,
+		Address:         logic,
+		Value:           big.NewInt(10),
+		GasLimit:        70_000,
+		GasLeft:         60_000,
+		GasUsed:         10_000,
+		GasRetained:     1_000,
+		ReturnData:      []byte{0x02},
+		DelegateCaller:  &sender,
+		ReadOnly:        true,
+		HasCode:         true,
+		CodeHash:        &logicCodeHash,
+		ExecutedCode:    true,
+		BeginOrdinal:    6,
+		EndOrdinal:      9,
+		KeccakPreimages: map[common.Hash]hexutil.Bytes{topic: []byte("preimage")},
+		StorageChanges:  []*StorageChange{{Address: proxy, Key: slotKey, Old: common.Hash{}, New: slotNew, Ordinal: 7}},
+		Logs:            []*Log{{Address: proxy, Topics: []common.Hash{topic}, Data: []byte{0x01}, IndexInBlock: 0, Ordinal: 8}},
+	}, trx.Calls[1])
+
+	assert.Equal(t, &Call{
+		Index:           3,
+		ParentIndex:     1,
+		ParentOrdinal:   4,
+		Depth:           1,
+		CallType:        firehose.CallTypeCreate,
+		OpCode:          "CREATE2",
+		Caller:          proxy,
+		Address:         created,
+		Value:           new(big.Int),
+		GasLimit:        50_000,
+		GasLeft:         50_000,
+		HasCode:         true,
+		CodeHash:        &initCodeHash,
+		ExecutedCode:    true,
+		Failed:          true,
+		FailureCode:     firehose.ExecutionRevertedCallFailureCode,
+		FailureReason:   "execution reverted: some reason",
+		Reverted:        true,
+		BeginOrdinal:    10,
+		EndOrdinal:      13,
+		NonceChanges:    []*NonceChange{{Address: created, Old: 0, New: 1, Ordinal: 11}},
+		CreatedAccounts: []*CreatedAccount{{Address: created, Ordinal: 12}},
+	}, trx.Calls[2])
+
+	assert.Equal(t, &Call{
+		Index:          4,
+		ParentIndex:    1,
+		ParentOrdinal:  4,
+		Depth:          1,
+		CallType:       firehose.CallTypeCall,
+		OpCode:         "CALL",
+		Caller:         proxy,
+		Address:        created,
+		Value:          new(big.Int),
+		GasLimit:       2_300,
+		GasLeft:        2_300,
+		BeginOrdinal:   14,
+		EndOrdinal:     17,
+		CodeChanges:    []*CodeChange{{Address: created, NewCodeHash: codeHash, NewCode: []byte{0x60}, Ordinal: 15}},
+		SuicideChanges: []*SuicideChange{{Address: created, Suicided: true, BalanceBefore: big.NewInt(5)}},
+		BalanceChanges: []*BalanceChange{{Address: created, Old: big.NewInt(5), New: new(big.Int), Reason: "suicide_withdraw", Ordinal: 16}},
+	}, trx.Calls[3])
+}
+
+func TestDecoder_TransientStorageChange(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(8), Difficulty: big.NewInt(2), GasLimit: 8_000_000, Coinbase: miner}
+	block := types.NewBlockWithHeader(header)
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+
+	ctx.StartBlock(block)
+	ctx.StartTransactionRaw(trxHash, &proxy, big.NewInt(0), []byte{0x1b}, []byte{0x0a}, []byte{0x0b}, 100_000, big.NewInt(1), 0, nil, nil, nil, nil, types.LegacyTxType, 0)
+	ctx.RecordTrxFrom(sender)
+	ctx.StartCall(firehose.CallTypeCall, "", false, common.Hash{}, false)
+	ctx.RecordCallParams(firehose.CallTypeCall, sender, proxy, firehose.EmptyValue, 79_000, nil, 0, false)
+	ctx.RecordTransientStorageChange(proxy, slotKey, common.Hash{}, slotNew)
+	ctx.EndCall(78_900, nil)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21_100, CumulativeGasUsed: 21_100})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, big.NewInt(16))
+
+	element, err := NewDecoder(buffer).Next()
+	require.NoError(t, err)
+
+	call := element.(*Block).Transactions[0].Calls[0]
+	assert.Empty(t, call.StorageChanges)
+	assert.Equal(t, []*StorageChange{{Address: proxy, Key: slotKey, Old: common.Hash{}, New: slotNew, Ordinal: 3}}, call.TransientStorageChanges)
+}
+
+func TestDecoder_AccountTouch(t *testing.T) {
+	defer func(enabled bool) { firehose.AccountTouchesEnabled = enabled }(firehose.AccountTouchesEnabled)
+	firehose.AccountTouchesEnabled = true
+
+	header := &types.Header{Number: big.NewInt(8), Difficulty: big.NewInt(2), GasLimit: 8_000_000, Coinbase: miner}
+	block := types.NewBlockWithHeader(header)
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+
+	ctx.StartBlock(block)
+	ctx.StartTransactionRaw(trxHash, &proxy, big.NewInt(0), []byte{0x1b}, []byte{0x0a}, []byte{0x0b}, 100_000, big.NewInt(1), 0, nil, nil, nil, nil, types.LegacyTxType, 0)
+	ctx.RecordTrxFrom(sender)
+	ctx.StartCall(firehose.CallTypeStatic, "", false, common.Hash{}, false)
+	ctx.RecordCallParams(firehose.CallTypeStatic, sender, proxy, firehose.EmptyValue, 79_000, nil, 0, true)
+	ctx.RecordAccountTouch(proxy)
+	ctx.EndCall(78_900, nil)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21_100, CumulativeGasUsed: 21_100})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, big.NewInt(16))
+
+	element, err := NewDecoder(buffer).Next()
+	require.NoError(t, err)
+
+	call := element.(*Block).Transactions[0].Calls[0]
+	assert.Empty(t, call.BalanceChanges)
+	assert.Equal(t, []*AccountTouch{{Address: proxy, Ordinal: 3}}, call.AccountTouches)
+}
+
+func TestDecoder_DeletedAccount(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(8), Difficulty: big.NewInt(2), GasLimit: 8_000_000, Coinbase: miner}
+	block := types.NewBlockWithHeader(header)
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+
+	ctx.StartBlock(block)
+	ctx.StartTransactionRaw(trxHash, &proxy, big.NewInt(0), []byte{0x1b}, []byte{0x0a}, []byte{0x0b}, 100_000, big.NewInt(1), 0, nil, nil, nil, nil, types.LegacyTxType, 0)
+	ctx.RecordTrxFrom(sender)
+	ctx.StartCall(firehose.CallTypeCall, "", false, proxyCodeHash, true)
+	ctx.RecordCallParams(firehose.CallTypeCall, sender, proxy, firehose.EmptyValue, 79_000, nil, 0, false)
+	ctx.EndCall(78_900, nil)
+	ctx.RecordDeletedAccount(logic, firehose.TouchCleanupAccountDeletionReason)
+	ctx.RecordDeletedAccount(proxy, firehose.SuicideAccountDeletionReason)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21_100, CumulativeGasUsed: 21_100})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, big.NewInt(16))
+
+	element, err := NewDecoder(buffer).Next()
+	require.NoError(t, err)
+
+	assert.Equal(t, []*DeletedAccount{
+		{Address: logic, Reason: firehose.TouchCleanupAccountDeletionReason, Ordinal: 4},
+		{Address: proxy, Reason: firehose.SuicideAccountDeletionReason, Ordinal: 5},
+	}, element.(*Block).Transactions[0].DeletedAccounts)
+}
+
+func TestDecoder_Golden(t *testing.T) {
+	goldenFiles, err := filepath.Glob(filepath.Join("..", "testdata", "golden", "*.golden"))
+	require.NoError(t, err)
+	require.NotEmpty(t, goldenFiles)
+
+	for _, goldenFile := range goldenFiles {
+		t.Run(filepath.Base(goldenFile), func(t *testing.T) {
+			content, err := os.ReadFile(goldenFile)
+			require.NoError(t, err)
+
+			decoder := NewDecoder(bytes.NewReader(content))
+
+			element, err := decoder.Next()
+			require.NoError(t, err)
+			require.IsType(t, &Block{}, element)
+
+			block := element.(*Block)
+			assert.Equal(t, block.Number, block.Header.Number.Uint64())
+			require.NotEmpty(t, block.Transactions)
+
+			for _, trx := range block.Transactions {
+				require.NotEmpty(t, trx.Calls)
+				assert.Equal(t, uint64(1), trx.Calls[0].Index)
+				assert.Equal(t, trx.GasChanges[0].New, trx.Calls[0].GasLimit, "root call should receive the gas left after intrinsic gas")
+			}
+
+			_, err = decoder.Next()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestDecoder_Precompile(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "testdata", "golden", "precompile.golden"))
+	require.NoError(t, err)
+
+	element, err := NewDecoder(bytes.NewReader(content)).Next()
+	require.NoError(t, err)
+
+	call := element.(*Block).Transactions[0].Calls[0]
+	assert.True(t, call.Precompile)
+	assert.Equal(t, "sha256", call.PrecompileName)
+}
+
+func TestDecoder_CreatedContracts(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "testdata", "golden", "contract_creation.golden"))
+	require.NoError(t, err)
+
+	element, err := NewDecoder(bytes.NewReader(content)).Next()
+	require.NoError(t, err)
+
+	block := element.(*Block)
+	call := block.Transactions[0].Calls[0]
+	require.Len(t, block.CreatedContracts, 1)
+	assert.Equal(t, &CreatedContract{
+		Address:         call.Address,
+		Creator:         block.Transactions[0].From,
+		TransactionHash: block.Transactions[0].Hash,
+		InitCodeHash:    *call.CodeHash,
+		CodeHash:        call.CodeChanges[0].NewCodeHash,
+	}, block.CreatedContracts[0])
+	assert.Equal(t, &call.Address, block.Transactions[0].ContractAddress)
+}
+
+func TestDecoder_SetCodeAuthorization(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "testdata", "golden", "set_code.golden"))
+	require.NoError(t, err)
+
+	element, err := NewDecoder(bytes.NewReader(content)).Next()
+	require.NoError(t, err)
+
+	authority := common.HexToAddress("0x703c4b2bd70c169f5717101caee543299fc946c7")
+	storeContract := common.HexToAddress("0x1000000000000000000000000000000000000001")
+
+	trx := element.(*Block).Transactions[0]
+	assert.Equal(t, uint8(0x04), trx.Type, "set code transaction type")
+	assert.Equal(t, []*SetCodeAuthorization{
+		{ChainID: big.NewInt(1), Address: storeContract, Nonce: 0, Authority: &authority, Applied: true, Ordinal: 5},
+		{ChainID: big.NewInt(1), Address: common.HexToAddress("0x1000000000000000000000000000000000000002"), Nonce: 0, Authority: &authority, Applied: false, Ordinal: 9},
+	}, trx.SetCodeAuthorizations)
+
+	require.Len(t, trx.CodeChanges, 1)
+	assert.Equal(t, authority, trx.CodeChanges[0].Address)
+	// The EIP-7702 delegation designator, 0xef0100 followed by the delegated address
+	assert.Equal(t, hexutil.Bytes(append([]byte{0xef, 0x01, 0x00}, storeContract.Bytes()...)), trx.CodeChanges[0].NewCode)
+
+	// The authority executes the delegated code in its own context
+	require.Len(t, trx.Calls[0].StorageChanges, 1)
+	assert.Equal(t, authority, trx.Calls[0].StorageChanges[0].Address)
+}
+
+func TestDecoder_HeadUpdate(t *testing.T) {
+	previous := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(8), Difficulty: big.NewInt(2)})
+	head := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(8), Difficulty: big.NewInt(3), ParentHash: common.HexToHash("0x07")})
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+	ctx.RecordHeadUpdate(head, previous)
+	record := buffer.String()
+
+	element, err := NewDecoder(strings.NewReader(record)).Next()
+	require.NoError(t, err)
+	assert.Equal(t, &HeadUpdate{Number: 8, Hash: head.Hash(), PreviousNumber: 8, PreviousHash: previous.Hash()}, element)
+
+	_, err = NewDecoder(strings.NewReader("FIRE BEGIN_BLOCK 9 8000000 8000000 . 00\n" + record)).Next()
+	assert.EqualError(t, err, "HEAD_UPDATE record while block #9 is not completed")
+}
+
+func TestDecoder_SideChainBlock(t *testing.T) {
+	forkParent := &types.Header{Number: big.NewInt(6), Difficulty: big.NewInt(2)}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(8), Difficulty: big.NewInt(3), ParentHash: common.HexToHash("0x07")})
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+	ctx.RecordSideChainBlock(block, forkParent)
+	record := buffer.String()
+
+	element, err := NewDecoder(strings.NewReader(record)).Next()
+	require.NoError(t, err)
+	assert.Equal(t, &SideChainBlock{Number: 8, Hash: block.Hash(), ForkParentNumber: 6, ForkParentHash: forkParent.Hash()}, element)
+
+	_, err = NewDecoder(strings.NewReader("FIRE BEGIN_BLOCK 9 8000000 8000000 . 00\n" + record)).Next()
+	assert.EqualError(t, err, "SIDE_CHAIN_BLOCK record while block #9 is not completed")
+}
+
+func TestDecoder_UndoBlock(t *testing.T) {
+	parent := &types.Header{Number: big.NewInt(7)}
+	header := &types.Header{Number: big.NewInt(8), ParentHash: parent.Hash()}
+
+	removed := &types.Log{Address: common.Address{0xaa}, Topics: []common.Hash{{0x01}}, Data: []byte{0x02}, BlockNumber: 8, BlockHash: header.Hash(), Removed: true}
+
+	record := "FIRE UNDO_BLOCK 8 " + firehose.Hash(header.Hash()) + " " + firehose.Hash(parent.Hash()) + " " + firehose.JSON([]*types.Log{removed}) + "\n"
+
+	element, err := NewDecoder(strings.NewReader(record)).Next()
+	require.NoError(t, err)
+	assert.Equal(t, &UndoBlock{Number: 8, Hash: header.Hash(), ParentHash: parent.Hash(), Logs: []*types.Log{removed}}, element)
+
+	_, err = NewDecoder(strings.NewReader("FIRE BEGIN_BLOCK 9 8000000 8000000 . 00\n" + record)).Next()
+	assert.EqualError(t, err, "UNDO_BLOCK record while block #9 is not completed")
+}
+
+func TestDecoder_Init(t *testing.T) {
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+	ctx.InitVersion("1.10.1-stable", "2.20", "geth", params.TestChainConfig)
+	ctx.InitVersion("1.10.1-stable", "2.20", "geth", nil)
+
+	decoder := NewDecoder(strings.NewReader(buffer.String()))
+
+	element, err := decoder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &Init{ProtocolVersion: "2.20", Variant: "geth", NodeVersion: "1.10.1-stable", ChainConfig: params.TestChainConfig}, element)
+
+	element, err = decoder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &Init{ProtocolVersion: "2.20", Variant: "geth", NodeVersion: "1.10.1-stable"}, element)
+}
+
+func TestDecoder_ForkActivations(t *testing.T) {
+	defer func(forksActivatedAt func(*params.ChainConfig, *big.Int) []string) {
+		firehose.ForksActivatedAt = forksActivatedAt
+	}(firehose.ForksActivatedAt)
+	firehose.ForksActivatedAt = func(config *params.ChainConfig, number *big.Int) []string {
+		if number.Cmp(config.BerlinBlock) != 0 {
+			return nil
+		}
+		return []string{"berlin", "rip7212"}
+	}
+
+	config := *params.TestChainConfig
+	config.BerlinBlock = big.NewInt(5)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5), Time: 1600000000})
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.RecordForkActivations(&config, block)
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+
+	assert.Equal(t, []*ForkActivation{
+		{Name: "berlin", Number: 5, Timestamp: 1600000000},
+		{Name: "rip7212", Number: 5, Timestamp: 1600000000},
+	}, element.(*Block).ForkActivations)
+	assert.Equal(t, ctx.BlockTrace().ForkActivations, element.(*Block).ForkActivations)
+}
+
+func TestDecoder_IrregularStateChange(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)})
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.RecordIrregularStateChange(firehose.DAOForkIrregularStateChangeReason, func() {
+		ctx.RecordBalanceChange(common.Address{0xaa}, big.NewInt(0), big.NewInt(10), firehose.DaoRefundContractBalanceChangeReason)
+		ctx.RecordBalanceChange(common.Address{0xbb}, big.NewInt(10), big.NewInt(0), firehose.DaoAdjustBalanceBalanceChangeReason)
+	})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+
+	decoded := element.(*Block)
+	assert.Equal(t, []*IrregularStateChange{{Reason: firehose.DAOForkIrregularStateChangeReason, BeginOrdinal: 1, EndOrdinal: 4}}, decoded.IrregularStateChanges)
+	assert.Equal(t, ctx.BlockTrace().IrregularStateChanges, decoded.IrregularStateChanges)
+	require.Len(t, decoded.BalanceChanges, 2)
+	assert.Equal(t, uint64(2), decoded.BalanceChanges[0].Ordinal)
+
+	_, err = NewDecoder(strings.NewReader("FIRE BEGIN_BLOCK 5 8000000 8000000 . 00\nFIRE IRREGULAR_STATE_CHANGE_END dao_fork 1\n")).Next()
+	assert.EqualError(t, err, `IRREGULAR_STATE_CHANGE_END record of "dao_fork" without its start record`)
+}
+
+func TestDecoder_BlockWitness(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)})
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.RecordBlockWitness([][]byte{{0x01, 0x02}, {0x03}}, [][]byte{{0x60, 0x00}})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+
+	assert.Equal(t, &BlockWitness{
+		Nodes: []hexutil.Bytes{{0x01, 0x02}, {0x03}},
+		Codes: []hexutil.Bytes{{0x60, 0x00}},
+	}, element.(*Block).Witness)
+	assert.Equal(t, ctx.BlockTrace().Witness, element.(*Block).Witness)
+}
+
+func TestDecoder_CliqueSeal(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)})
+	seal := &CliqueSeal{
+		Signer: common.Address{0x01},
+		InTurn: true,
+		Vote:   &CliqueVote{Address: common.Address{0x02}, Authorize: true},
+	}
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.RecordCliqueSeal(seal)
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+
+	assert.Equal(t, seal, element.(*Block).CliqueSeal)
+	assert.Equal(t, ctx.BlockTrace().CliqueSeal, element.(*Block).CliqueSeal)
+}
+
+func TestDecoder_RecordCounts(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)})
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+	ctx.RecordBalanceChange(miner, big.NewInt(0), big.NewInt(2), firehose.RewardMineBlockBalanceChangeReason)
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+
+	assert.Equal(t, &RecordCounts{BalanceChanges: 1}, element.(*Block).Counts)
+	assert.Equal(t, ctx.BlockTrace().Counts, element.(*Block).Counts)
+
+	// A record lost on the way is caught at the end of the block
+	tampered := strings.Replace(string(ctx.FirehoseLog()), `"balanceChanges":1`, `"balanceChanges":2`, 1)
+	_, err = NewDecoder(strings.NewReader(tampered)).Next()
+	assert.EqualError(t, err, "END_BLOCK record counts {Calls:0 Logs:0 BalanceChanges:2 StorageChanges:0 GasChanges:0} do not match the {Calls:0 Logs:0 BalanceChanges:1 StorageChanges:0 GasChanges:0} records decoded for block #5")
+}
+
+func TestDecoder_Uncles(t *testing.T) {
+	uncle := &types.Header{
+		ParentHash:  common.Hash{0x01},
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    common.Address{0xcb},
+		Root:        common.Hash{0x02},
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(3),
+		Number:      big.NewInt(4),
+		GasLimit:    8_000_000,
+		GasUsed:     21_000,
+		Time:        10,
+		Extra:       []byte{0xee},
+		MixDigest:   common.Hash{0x03},
+		Nonce:       types.EncodeNonce(7),
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(5), Difficulty: big.NewInt(2)}, nil, []*types.Header{uncle}, nil, trie.NewStackTrie(nil))
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+
+	// The uncles are complete headers, enough to compute the uncle rewards and the ommers hash
+	decoded := element.(*Block)
+	require.Len(t, decoded.Uncles, 1)
+	assert.Equal(t, uncle, decoded.Uncles[0])
+	assert.Equal(t, uncle.Hash(), decoded.Uncles[0].Hash())
+	assert.Equal(t, decoded.Header.UncleHash, types.CalcUncleHash(decoded.Uncles))
+}
+
+func TestDecoder_SystemCall(t *testing.T) {
+	var (
+		system  = common.HexToAddress("0xfffffffffffffffffffffffffffffffffffffffe")
+		history = common.HexToAddress("0x0aae40965e6800cd9b1f4b05ff21581047e3f91e")
+	)
+
+	header := &types.Header{Number: big.NewInt(9), Difficulty: big.NewInt(2), GasLimit: 8_000_000, Coinbase: miner}
+	block := types.NewBlockWithHeader(header)
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.StartSystemCall()
+	ctx.StartCall(firehose.CallTypeCall, "", false, logicCodeHash, true)
+	ctx.RecordCallParams(firehose.CallTypeCall, system, history, firehose.EmptyValue, 30_000_000, header.ParentHash[:], 0, false)
+	ctx.RecordStorageChange(history, common.BigToHash(big.NewInt(8)), common.Hash{}, header.ParentHash)
+	ctx.EndCall(29_978_000, nil)
+	ctx.EndSystemCall()
+	ctx.StartTransactionRaw(trxHash, &proxy, big.NewInt(0), []byte{0x1b}, []byte{0x0a}, []byte{0x0b}, 21_000, big.NewInt(1), 0, nil, nil, nil, nil, types.LegacyTxType, 0)
+	ctx.RecordTrxFrom(sender)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21_000, CumulativeGasUsed: 21_000})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, big.NewInt(18))
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+
+	decoded := element.(*Block)
+	require.Len(t, decoded.SystemCalls, 1)
+	require.Len(t, decoded.Transactions, 1)
+
+	// The system call is not a transaction, its changes are recorded in its calls
+	call := decoded.SystemCalls[0]
+	assert.Equal(t, system, call.Caller)
+	assert.Equal(t, history, call.Address)
+	assert.Equal(t, []*StorageChange{
+		{Address: history, Key: common.BigToHash(big.NewInt(8)), Old: common.Hash{}, New: header.ParentHash, Ordinal: 2},
+	}, call.StorageChanges)
+	assert.Equal(t, trxHash, decoded.Transactions[0].Hash)
+	assert.Equal(t, ctx.BlockTrace().SystemCalls, decoded.SystemCalls)
+}
+
+// The system transactions hooks are enabled by the registered variant
+func init() {
+	firehose.RegisterVariant(&firehose.Variant{Name: "test", SystemTransactions: true})
+}
+
+func TestDecoder_SystemTransaction(t *testing.T) {
+	var (
+		validatorSet = common.HexToAddress("0x0000000000000000000000000000000000001000")
+		systemReward = firehose.RegisterBalanceChangeReason("test_system_reward", firehose.IssuanceBalanceChangeCategory)
+	)
+
+	header := &types.Header{Number: big.NewInt(9), Difficulty: big.NewInt(2), GasLimit: 8_000_000, Coinbase: miner}
+	block := types.NewBlockWithHeader(header)
+	tx := types.NewTransaction(0, validatorSet, big.NewInt(1000), 100_000, common.Big0, nil)
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+	ctx.RecordBalanceChange(miner, big.NewInt(1000), big.NewInt(900), systemReward)
+	ctx.StartSystemTransaction(tx, 0, miner)
+	ctx.EndTransaction(&types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21_000, CumulativeGasUsed: 21_000})
+	ctx.EndBlock(block, big.NewInt(18))
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+
+	decoded := element.(*Block)
+	require.Len(t, decoded.Transactions, 1)
+	assert.True(t, decoded.Transactions[0].System)
+	assert.Equal(t, tx.Hash(), decoded.Transactions[0].Hash)
+	assert.Equal(t, miner, decoded.Transactions[0].From)
+	require.Len(t, decoded.BalanceChanges, 1)
+	assert.Equal(t, systemReward, decoded.BalanceChanges[0].Reason)
+	assert.Equal(t, ctx.BlockTrace().Transactions[0].System, decoded.Transactions[0].System)
+
+	finalized := "FIRE BEGIN_BLOCK 9 8000000 8000000 . 00\nFIRE FINALIZE_BLOCK 9\n"
+	_, err = NewDecoder(strings.NewReader(finalized + "FIRE BEGIN_APPLY_TRX " + strings.Repeat(". ", 15) + "0\n")).Next()
+	assert.EqualError(t, err, "BEGIN_APPLY_TRX record after FINALIZE_BLOCK without a SYSTEM_TRX record, only system transactions are applied once the block is finalized")
+
+	_, err = NewDecoder(strings.NewReader(finalized + "FIRE SYSTEM_TRX\nFIRE END_BLOCK 9 0 0 {\"header\":{}}\n")).Next()
+	assert.EqualError(t, err, "END_BLOCK record while the SYSTEM_TRX record was not followed by its transaction")
+}
+
+func TestDecoder_BalanceDelta(t *testing.T) {
+	tx := types.NewTransaction(0, common.HexToAddress("0xaa"), big.NewInt(1000), 21000, big.NewInt(1), nil)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5), GasUsed: 21000}).WithBody([]*types.Transaction{tx}, nil)
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.RecordBalanceDelta(sender, big.NewInt(-10), firehose.BalanceChangeReason("transfer"))
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000, CumulativeGasUsed: 21000})
+	ctx.FinalizeBlock(block)
+	ctx.RecordBalanceDelta(miner, big.NewInt(2), firehose.BalanceChangeReason("reward_mine_block"))
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+	decoded := element.(*Block)
+
+	require.Len(t, decoded.Transactions, 1)
+	require.Len(t, decoded.Transactions[0].BalanceChanges, 1)
+	assert.Equal(t, &BalanceChange{Address: sender, Delta: big.NewInt(-10), Reason: "transfer", Ordinal: 2}, decoded.Transactions[0].BalanceChanges[0])
+	assert.Equal(t, ctx.BlockTrace().Transactions[0].BalanceChanges, decoded.Transactions[0].BalanceChanges)
+	assert.Equal(t, ctx.BlockTrace().BalanceChanges, decoded.BalanceChanges)
+	assert.Equal(t, big.NewInt(2), decoded.BalanceChanges[0].Delta)
+
+	_, err = NewDecoder(strings.NewReader("FIRE BEGIN_BLOCK 9 8000000 8000000 . 00\nFIRE FINALIZE_BLOCK 9\nFIRE BALANCE_DELTA 0 " + firehose.Addr(miner) + " 02 reward_mine_block 1\n")).Next()
+	assert.EqualError(t, err, `BALANCE_DELTA record field #2 "02" is not a valid signed hexadecimal string: expected a + or - sign followed by the magnitude`)
+}
+
+func TestDecoder_Timings(t *testing.T) {
+	defer func(enabled bool) { firehose.TimingsEnabled = enabled }(firehose.TimingsEnabled)
+	firehose.TimingsEnabled = true
+
+	tx := types.NewTransaction(0, common.HexToAddress("0xaa"), big.NewInt(1000), 21000, big.NewInt(1), nil)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5), GasUsed: 21000}).WithBody([]*types.Transaction{tx}, nil)
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.StartTransaction(tx, 0, nil)
+	time.Sleep(time.Millisecond)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000, CumulativeGasUsed: 21000})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+	decoded := element.(*Block)
+
+	require.Len(t, decoded.Transactions, 1)
+	assert.GreaterOrEqual(t, int64(decoded.Transactions[0].Duration), int64(time.Millisecond))
+	assert.GreaterOrEqual(t, int64(decoded.Duration), int64(decoded.Transactions[0].Duration))
+	assert.Equal(t, ctx.BlockTrace().Duration, decoded.Duration)
+	assert.Equal(t, ctx.BlockTrace().Transactions[0].Duration, decoded.Transactions[0].Duration)
+}
+
+func TestDecoder_TransactionSignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	tx, err := types.SignTx(types.NewTransaction(0, common.HexToAddress("0xaa"), big.NewInt(1000), 21000, big.NewInt(1), nil), types.NewEIP155Signer(big.NewInt(1)), key)
+	require.NoError(t, err)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5), GasUsed: 21000}).WithBody([]*types.Transaction{tx}, nil)
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.RecordTrxFrom(crypto.PubkeyToAddress(key.PublicKey))
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000, CumulativeGasUsed: 21000})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	element, err := NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+	decoded := element.(*Block)
+
+	require.Len(t, decoded.Transactions, 1)
+	v, _, _ := tx.RawSignatureValues()
+	assert.Equal(t, "eip155", decoded.Transactions[0].SignatureScheme)
+	assert.Equal(t, v.Uint64()-37, decoded.Transactions[0].YParity)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), decoded.Transactions[0].From)
+	assert.Equal(t, ctx.BlockTrace().Transactions[0].SignatureScheme, decoded.Transactions[0].SignatureScheme)
+	assert.Equal(t, ctx.BlockTrace().Transactions[0].YParity, decoded.Transactions[0].YParity)
+}
+
+func TestDecoder_CreateFailure(t *testing.T) {
+	from, to := common.HexToAddress("0xaa"), common.HexToAddress("0xbb")
+	tx := types.NewTransaction(0, to, big.NewInt(0), 100_000, big.NewInt(1), nil)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5), GasUsed: 100_000}).WithBody([]*types.Transaction{tx}, nil)
+
+	record := func(failedCallType firehose.CallType) []byte {
+		ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+		ctx.EnableBlockTrace()
+		ctx.StartBlock(block)
+		ctx.StartTransaction(tx, 0, nil)
+		ctx.StartCall(firehose.CallTypeCall, "", false, common.Hash{}, false)
+		ctx.RecordCallParams(firehose.CallTypeCall, from, to, firehose.EmptyValue, 100_000, nil, 0, false)
+		ctx.StartCall(failedCallType, "CREATE", false, common.Hash{}, false)
+		ctx.RecordCallParams(failedCallType, to, common.HexToAddress("0xcc"), firehose.EmptyValue, 60_000, nil, 1, false)
+		ctx.RecordCallFailed(60_000, firehose.ContractAddressCollisionCallFailureCode, "contract address collision")
+		ctx.RecordCreateFailed(firehose.ContractAddressCollisionCallFailureCode, firehose.BurnedGasDisposition, 60_000)
+		ctx.RecordGasConsume(60_000, 60_000, firehose.FailedExecutionGasChangeReason)
+		ctx.EndCall(0, nil)
+		ctx.EndCall(0, nil)
+		ctx.EndTransaction(&types.Receipt{GasUsed: 100_000, CumulativeGasUsed: 100_000})
+		ctx.FinalizeBlock(block)
+		ctx.EndBlock(block, nil)
+
+		if failedCallType == firehose.CallTypeCreate {
+			assert.Equal(t, &CreateFailure{
+				Code:           firehose.ContractAddressCollisionCallFailureCode,
+				GasDisposition: firehose.BurnedGasDisposition,
+				Gas:            60_000,
+			}, ctx.BlockTrace().Transactions[0].Calls[1].CreateFailure)
+		}
+
+		return ctx.FirehoseLog()
+	}
+
+	element, err := NewDecoder(bytes.NewReader(record(firehose.CallTypeCreate))).Next()
+	require.NoError(t, err)
+	decoded := element.(*Block)
+
+	require.Len(t, decoded.Transactions, 1)
+	require.Len(t, decoded.Transactions[0].Calls, 2)
+	failed := decoded.Transactions[0].Calls[1]
+	assert.Equal(t, firehose.ContractAddressCollisionCallFailureCode, failed.FailureCode)
+	assert.Equal(t, &CreateFailure{
+		Code:           firehose.ContractAddressCollisionCallFailureCode,
+		GasDisposition: firehose.BurnedGasDisposition,
+		Gas:            60_000,
+	}, failed.CreateFailure)
+
+	_, err = NewDecoder(bytes.NewReader(record(firehose.CallTypeCall))).Next()
+	assert.EqualError(t, err, "EVM_CREATE_FAILED record for call #2 which is not a contract creation")
+}
+
+func TestDecoder_CallTreeReferences(t *testing.T) {
+	from, to := common.HexToAddress("0xaa"), common.HexToAddress("0xbb")
+	tx := types.NewTransaction(0, to, big.NewInt(0), 100_000, big.NewInt(1), nil)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5), GasUsed: 100_000}).WithBody([]*types.Transaction{tx}, nil)
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.StartBlock(block)
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.StartCall(firehose.CallTypeCall, "", false, common.Hash{}, false)
+	ctx.RecordCallParams(firehose.CallTypeCall, from, to, firehose.EmptyValue, 100_000, nil, 0, false)
+	ctx.StartCall(firehose.CallTypeCall, "CALL", false, common.Hash{}, false)
+	ctx.RecordCallParams(firehose.CallTypeCall, to, from, firehose.EmptyValue, 60_000, nil, 1, false)
+	ctx.EndCall(60_000, nil)
+	ctx.EndCall(100_000, nil)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 100_000, CumulativeGasUsed: 100_000})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+	log := string(ctx.FirehoseLog())
+
+	element, err := NewDecoder(strings.NewReader(log)).Next()
+	require.NoError(t, err)
+	calls := element.(*Block).Transactions[0].Calls
+
+	require.Len(t, calls, 2)
+	assert.Equal(t, []uint64{0, 0, 0}, []uint64{calls[0].ParentIndex, calls[0].ParentOrdinal, calls[0].Depth})
+	assert.Equal(t, []uint64{1, calls[0].BeginOrdinal, 1}, []uint64{calls[1].ParentIndex, calls[1].ParentOrdinal, calls[1].Depth})
+
+	// A call referencing another parent than the active call is rejected
+	nested := fmt.Sprintf("false 1 %d 1\n", calls[0].BeginOrdinal)
+	require.Contains(t, log, nested)
+	_, err = NewDecoder(strings.NewReader(strings.Replace(log, nested, "false 1 1 1\n", 1))).Next()
+	assert.EqualError(t, err, fmt.Sprintf("EVM_RUN_CALL record for call #2 references parent call #1 (ordinal 1) at depth 1 while the active call is #1 (ordinal %d) at depth 1", calls[0].BeginOrdinal))
+}
+
+func TestDecoder_CallGasAccounting(t *testing.T) {
+	from, to := common.HexToAddress("0xaa"), common.HexToAddress("0xbb")
+	tx := types.NewTransaction(0, to, big.NewInt(0), 100_000, big.NewInt(1), nil)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5), GasUsed: 100_000}).WithBody([]*types.Transaction{tx}, nil)
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.StartBlock(block)
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.StartCall(firehose.CallTypeCall, "", false, common.Hash{}, false)
+	ctx.RecordCallParams(firehose.CallTypeCall, from, to, firehose.EmptyValue, 100_000, nil, 0, false)
+	ctx.RecordCallGasRetention(1_500)
+	ctx.StartCall(firehose.CallTypeCall, "CALL", false, common.Hash{}, false)
+	ctx.RecordCallParams(firehose.CallTypeCall, to, from, firehose.EmptyValue, 60_000, nil, 1, false)
+	ctx.EndFailedCall(60_000, false, firehose.OutOfGasCallFailureCode, "out of gas")
+	ctx.EndCall(20_000, nil)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 100_000, CumulativeGasUsed: 100_000})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+	log := string(ctx.FirehoseLog())
+
+	element, err := NewDecoder(strings.NewReader(log)).Next()
+	require.NoError(t, err)
+	calls := element.(*Block).Transactions[0].Calls
+
+	require.Len(t, calls, 2)
+	assert.Equal(t, []uint64{100_000, 20_000, 80_000, 0}, []uint64{calls[0].GasLimit, calls[0].GasLeft, calls[0].GasUsed, calls[0].GasRetained})
+	assert.Equal(t, []uint64{60_000, 0, 60_000, 1_500}, []uint64{calls[1].GasLimit, calls[1].GasLeft, calls[1].GasUsed, calls[1].GasRetained})
+
+	// A call accounting another gas limit than the one of its parameters is rejected
+	require.Contains(t, log, " 60000 60000 1500\n")
+	_, err = NewDecoder(strings.NewReader(strings.Replace(log, " 60000 60000 1500\n", " 50000 60000 1500\n", 1))).Next()
+	assert.EqualError(t, err, "EVM_END_CALL record for call #2 accounts a gas limit of 50000 while its parameters record 60000")
+}
+
+func TestDecodeTransaction(t *testing.T) {
+	tx := types.NewTransaction(0, proxy, big.NewInt(0), 100_000, big.NewInt(1), nil)
+
+	ctx := firehose.NewSpeculativeExecutionContext(0)
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.RecordTrxFrom(sender)
+	ctx.StartCall(firehose.CallTypeCall, "", false, common.Hash{}, false)
+	ctx.RecordCallParams(firehose.CallTypeCall, sender, proxy, firehose.EmptyValue, 100_000, nil, 0, false)
+	ctx.EndCall(40_000, nil)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 60_000, CumulativeGasUsed: 60_000})
+	log := string(ctx.FirehoseLog())
+
+	trx, err := DecodeTransaction(strings.NewReader(log))
+	require.NoError(t, err)
+	assert.Equal(t, tx.Hash(), trx.Hash)
+	assert.Equal(t, sender, trx.From)
+	require.Len(t, trx.Calls, 1)
+	assert.Equal(t, []uint64{100_000, 40_000}, []uint64{trx.Calls[0].GasLimit, trx.Calls[0].GasLeft})
+
+	// A transaction cut short is rejected
+	_, err = DecodeTransaction(strings.NewReader(log[:strings.Index(log, "FIRE END_APPLY_TRX")]))
+	assert.EqualError(t, err, fmt.Sprintf("transaction %s is not completed", tx.Hash().Hex()))
+}
+
+func TestDecoder_Heartbeat(t *testing.T) {
+	head := &types.Header{Number: big.NewInt(8)}
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+	ctx.RecordHeartbeat(head, time.Unix(1600000000, 0))
+	record := buffer.String()
+
+	element, err := NewDecoder(strings.NewReader(record)).Next()
+	require.NoError(t, err)
+	assert.Equal(t, &Heartbeat{HeadNumber: 8, HeadHash: head.Hash(), Timestamp: 1600000000}, element)
+
+	_, err = NewDecoder(strings.NewReader("FIRE BEGIN_BLOCK 9 8000000 8000000 . 00\n" + record)).Next()
+	assert.EqualError(t, err, "HEARTBEAT record while block #9 is not completed")
+}
+
+func TestDecoder_SyncProgress(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(8)}
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+	ctx.RecordSyncProgress(firehose.SyncStageHeaders, header)
+	ctx.RecordSyncProgress(firehose.SyncStageReceipts, header)
+	records := buffer.String()
+
+	decoder := NewDecoder(strings.NewReader(records))
+	for _, stage := range []string{"headers", "receipts"} {
+		element, err := decoder.Next()
+		require.NoError(t, err)
+		assert.Equal(t, &SyncProgress{Stage: stage, Number: 8, Hash: header.Hash()}, element)
+	}
+
+	_, err := NewDecoder(strings.NewReader("FIRE BEGIN_BLOCK 9 8000000 8000000 . 00\n" + records)).Next()
+	assert.EqualError(t, err, "SYNC_PROGRESS record while block #9 is not completed")
+}
+
+func TestDecoder_PendingTransactions(t *testing.T) {
+	to := common.HexToAddress("0xaa")
+	from := common.HexToAddress("0xbb")
+	tx := types.NewTransaction(3, to, big.NewInt(1), 21000, big.NewInt(7), []byte{0x01, 0x02})
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+	ctx.RecordPendingTransaction(tx, from)
+	ctx.RecordPendingDrop(tx.Hash(), firehose.ReplacedPendingDropReason)
+
+	decoder := NewDecoder(strings.NewReader(buffer.String()))
+
+	element, err := decoder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &PendingTransaction{
+		Hash:      tx.Hash(),
+		From:      from,
+		To:        &to,
+		Nonce:     3,
+		GasPrice:  big.NewInt(7),
+		GasLimit:  21000,
+		InputHash: crypto.Keccak256Hash([]byte{0x01, 0x02}),
+	}, element)
+
+	element, err = decoder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &PendingDrop{Hash: tx.Hash(), Reason: "replaced"}, element)
+}
+
+func TestDecoder_PendingReplacements(t *testing.T) {
+	replaced, replacing, queued := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+	ctx.RecordPendingReplacement(replacing, replaced)
+	ctx.RecordPendingExecutable(queued, replacing)
+	records := buffer.String()
+
+	decoder := NewDecoder(strings.NewReader(records))
+
+	element, err := decoder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &PendingReplacement{Hash: replacing, ReplacedHash: replaced}, element)
+
+	element, err = decoder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &PendingExecutable{Hash: queued, GapFillerHash: replacing}, element)
+
+	_, err = NewDecoder(strings.NewReader("FIRE BEGIN_BLOCK 9 8000000 8000000 . 00\n" + records)).Next()
+	assert.EqualError(t, err, "PENDING_REPLACE record while block #9 is not completed")
+}
+
+func TestDecoder_PendingEffects(t *testing.T) {
+	head := &types.Header{Number: big.NewInt(8), Difficulty: big.NewInt(2)}
+	trx := &firehose.TransactionTrace{
+		Hash:        common.HexToHash("0x01"),
+		GasUsed:     50000,
+		ReceiptLogs: []*firehose.ReceiptLog{{Address: proxy, Topics: []common.Hash{{0x01}}, Data: []byte{0x02}}},
+		Calls: []*firehose.Call{
+			{Index: 1, Caller: sender, Address: proxy, Value: big.NewInt(5)},
+			{Index: 2, ParentIndex: 1, Depth: 1, Caller: proxy, Address: logic, Value: big.NewInt(3), Failed: true, Reverted: true},
+			{Index: 3, ParentIndex: 2, Depth: 2, Caller: logic, Address: miner, Value: big.NewInt(2)},
+			{Index: 4, ParentIndex: 1, Depth: 1, Caller: proxy, Address: miner, Value: big.NewInt(1)},
+		},
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := firehose.NewBlockContextWithBuffer(buffer)
+	ctx.RecordPendingEffects(head, trx)
+
+	// The calls rolled back by a failed ancestor do not transfer value
+	element, err := NewDecoder(strings.NewReader(buffer.String())).Next()
+	require.NoError(t, err)
+	assert.Equal(t, &PendingEffects{
+		Hash:       trx.Hash,
+		HeadNumber: 8,
+		HeadHash:   head.Hash(),
+		Status:     "succeeded",
+		GasUsed:    50000,
+		Logs:       trx.ReceiptLogs,
+		Transfers: []*Transfer{
+			{From: sender, To: proxy, Value: big.NewInt(5)},
+			{From: proxy, To: miner, Value: big.NewInt(1)},
+		},
+	}, element)
+
+	trx.Calls[0].Failed, trx.Calls[0].Reverted, trx.ReceiptLogs = true, true, nil
+
+	buffer.Reset()
+	ctx.RecordPendingEffects(head, trx)
+
+	element, err = NewDecoder(strings.NewReader(buffer.String())).Next()
+	require.NoError(t, err)
+	assert.Equal(t, "reverted", element.(*PendingEffects).Status)
+	assert.Empty(t, element.(*PendingEffects).Transfers)
+	assert.Empty(t, element.(*PendingEffects).Logs)
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectedErr string
+	}{
+		{"record outside of block", "FIRE BEGIN_APPLY_TRX", "BEGIN_APPLY_TRX record has 1 fields, expected 16"},
+		{"change outside of block", "FIRE TRX_FROM " + firehose.Addr(sender), "TRX_FROM record received outside of a block"},
+		{"invalid field", "FIRE BEGIN_BLOCK abc 8000000 8000000 . 00", `BEGIN_BLOCK record field #0 "abc" is not a valid unsigned integer`},
+		{"unknown call", "FIRE BEGIN_BLOCK 1 8000000 8000000 . 00\nFIRE BEGIN_APPLY_TRX " + firehose.Hash(trxHash) + " . . . . . 0 . 0 . 00 . . 0 1 0\nFIRE EVM_REVERTED 2", "EVM_REVERTED record references unknown call #2"},
+		{"unterminated system call", "FIRE BEGIN_BLOCK 1 8000000 8000000 . 00\nFIRE SYSTEM_CALL_START\nFIRE BEGIN_APPLY_TRX " + firehose.Hash(trxHash) + " . . . . . 0 . 0 . 00 . . 0 1 0", "BEGIN_APPLY_TRX record while transaction"},
+		{"system call end without start", "FIRE BEGIN_BLOCK 1 8000000 8000000 . 00\nFIRE SYSTEM_CALL_END", "SYSTEM_CALL_END record without its start record"},
+		{"truncated block", "FIRE BEGIN_BLOCK 1 8000000 8000000 . 00", "unexpected EOF"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewDecoder(strings.NewReader(test.input)).Next()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestDecoder_Framing(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "testdata", "golden", "transfer.golden"))
+	require.NoError(t, err)
+
+	element, err := NewDecoder(bytes.NewReader(content)).Next()
+	require.NoError(t, err)
+	block := element.(*Block)
+
+	lineCount := bytes.Count(content, []byte{'\n'})
+	frame := func(number uint64, hash common.Hash, lineCount int) string {
+		return fmt.Sprintf("FIRE BLOCK_BEGIN %d %s 1600000000000000000 5\n%sFIRE BLOCK_END %d %s %d 1600000000000000100 105\n", number, firehose.Hash(hash), content, number, firehose.Hash(hash), lineCount)
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		expectedErr string
+	}{
+		{"valid", frame(block.Number, block.Header.Hash(), lineCount), ""},
+		{"line count mismatch", frame(block.Number, block.Header.Hash(), lineCount+1), fmt.Sprintf("announced %d lines but %d were received", lineCount+1, lineCount)},
+		{"block mismatch", frame(block.Number, common.HexToHash("0xff"), lineCount), "framed by markers of block"},
+		{"unterminated", fmt.Sprintf("FIRE BLOCK_BEGIN %d %s 0 0\n%s", block.Number, firehose.Hash(block.Header.Hash()), content), "unexpected EOF"},
+		{"end without begin", fmt.Sprintf("FIRE BLOCK_END 1 %s 0 0 0\n", firehose.Hash(common.Hash{})), "without a BLOCK_BEGIN marker"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			element, err := NewDecoder(strings.NewReader(test.input)).Next()
+			if test.expectedErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, block.Header.Hash(), element.(*Block).Header.Hash())
+				assert.Equal(t, &BlockEmission{BeginTime: 1600000000000000000, EndTime: 1600000000000000100, BeginElapsed: 5, EndElapsed: 105}, element.(*Block).Emission)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestDecoder_Segments(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "testdata", "golden", "transfer.golden"))
+	require.NoError(t, err)
+
+	element, err := NewDecoder(bytes.NewReader(content)).Next()
+	require.NoError(t, err)
+	block := element.(*Block)
+
+	number, hash := block.Number, firehose.Hash(block.Header.Hash())
+	lines := strings.SplitAfter(string(content), "\n")
+	lines = lines[:len(lines)-1]
+	first, rest := strings.Join(lines[:2], ""), strings.Join(lines[2:], "")
+
+	segmented := func(sequence int, segmentLineCount int) string {
+		return fmt.Sprintf("FIRE BLOCK_BEGIN %d %s 0 0\n%sFIRE BLOCK_SEGMENT %d %s %d %d\n%sFIRE BLOCK_END %d %s %d 0 0\n", number, hash, first, number, hash, sequence, segmentLineCount, rest, number, hash, len(lines))
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		expectedErr string
+	}{
+		{"valid", segmented(0, 2), ""},
+		{"sequence mismatch", segmented(1, 2), "segment 1 received while segment 0 was expected"},
+		{"line count mismatch", segmented(0, 3), "segment 0 announced 3 lines but 2 were received"},
+		{"segment without begin", fmt.Sprintf("FIRE BLOCK_SEGMENT %d %s 0 0\n", number, hash), "without a BLOCK_BEGIN marker"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			element, err := NewDecoder(strings.NewReader(test.input)).Next()
+			if test.expectedErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, block.Header.Hash(), element.(*Block).Header.Hash())
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+
+	// The segments of an aborted block are discarded, the block emitted again is decoded
+	aborted := fmt.Sprintf("FIRE BLOCK_BEGIN %d %s 0 0\n%sFIRE BLOCK_SEGMENT %d %s 0 2\nFIRE BLOCK_ABORT %d %s\n", number, hash, first, number, hash, number, hash)
+	decoder := NewDecoder(strings.NewReader(aborted + segmented(0, 2)))
+
+	element, err = decoder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &BlockAbort{Number: number, Hash: block.Header.Hash()}, element)
+
+	element, err = decoder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, block.Header.Hash(), element.(*Block).Header.Hash())
+
+	_, err = decoder.Next()
+	assert.Equal(t, io.EOF, err)
+}
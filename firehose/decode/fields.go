@@ -0,0 +1,136 @@
+package decode
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fields gives typed access to the fields of a record, the first conversion error is
+// retained and all subsequent conversions return zero values, check `err` once done.
+type fields struct {
+	record string
+	values []string
+	err    error
+}
+
+func (f *fields) fail(i int, kind string, cause error) {
+	if f.err == nil {
+		f.err = fmt.Errorf("%s record field #%d %q is not a valid %s: %w", f.record, i, f.values[i], kind, cause)
+	}
+}
+
+func (f *fields) string(i int) string {
+	return f.values[i]
+}
+
+func (f *fields) uint64(i int) uint64 {
+	value, err := strconv.ParseUint(f.values[i], 10, 64)
+	if err != nil {
+		f.fail(i, "unsigned integer", err)
+	}
+
+	return value
+}
+
+func (f *fields) bool(i int) bool {
+	value, err := strconv.ParseBool(f.values[i])
+	if err != nil {
+		f.fail(i, "boolean", err)
+	}
+
+	return value
+}
+
+// bytes decodes a hexadecimal field, the "null" value (i.e. a dot character) being
+// decoded as nil.
+func (f *fields) bytes(i int) []byte {
+	if f.values[i] == "." {
+		return nil
+	}
+
+	value, err := hex.DecodeString(f.values[i])
+	if err != nil {
+		f.fail(i, "hexadecimal string", err)
+	}
+
+	return value
+}
+
+func (f *fields) bigInt(i int) *big.Int {
+	return new(big.Int).SetBytes(f.bytes(i))
+}
+
+// signedBigInt decodes a magnitude prefixed by its `+` or `-` sign, `.` being 0, see
+// `firehose.SignedBigInt`.
+func (f *fields) signedBigInt(i int) *big.Int {
+	value := f.values[i]
+	if value == "." {
+		return new(big.Int)
+	}
+
+	if len(value) < 2 || (value[0] != '+' && value[0] != '-') {
+		f.fail(i, "signed hexadecimal string", errors.New("expected a + or - sign followed by the magnitude"))
+		return new(big.Int)
+	}
+
+	magnitude, err := hex.DecodeString(value[1:])
+	if err != nil {
+		f.fail(i, "signed hexadecimal string", err)
+	}
+
+	out := new(big.Int).SetBytes(magnitude)
+	if value[0] == '-' {
+		out.Neg(out)
+	}
+
+	return out
+}
+
+func (f *fields) optionalBigInt(i int) *big.Int {
+	if f.values[i] == "." {
+		return nil
+	}
+
+	return f.bigInt(i)
+}
+
+func (f *fields) address(i int) common.Address {
+	value := f.bytes(i)
+	if len(value) != common.AddressLength {
+		f.fail(i, "address", fmt.Errorf("expected %d bytes, got %d", common.AddressLength, len(value)))
+	}
+
+	return common.BytesToAddress(value)
+}
+
+func (f *fields) optionalAddress(i int) *common.Address {
+	if f.values[i] == "." {
+		return nil
+	}
+
+	address := f.address(i)
+	return &address
+}
+
+func (f *fields) hash(i int) common.Hash {
+	value := f.bytes(i)
+	if len(value) != common.HashLength {
+		f.fail(i, "hash", fmt.Errorf("expected %d bytes, got %d", common.HashLength, len(value)))
+	}
+
+	return common.BytesToHash(value)
+}
+
+func (f *fields) optionalHash(i int) *common.Hash {
+	if f.values[i] == "." {
+		return nil
+	}
+
+	hash := f.hash(i)
+	return &hash
+}
@@ -0,0 +1,48 @@
+package decode
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzDecoder(f *testing.F) {
+	goldenFiles, err := filepath.Glob(filepath.Join("..", "testdata", "golden", "*.golden"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, goldenFile := range goldenFiles {
+		content, err := os.ReadFile(goldenFile)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(content)
+	}
+	f.Add([]byte("FIRE BEGIN_BLOCK\n"))
+	f.Add([]byte("FIRE END_BLOCK 1 2 3 {}\n"))
+	f.Add([]byte("FIRE BLOCK_BEGIN 1 . 0 0\nFIRE BLOCK_END 1 . 0 0 0\n"))
+
+	f.Fuzz(func(t *testing.T, stream []byte) {
+		// Decoding the same stream twice must yield the same elements, or fail the same way
+		first, firstErr := decodeAll(stream)
+		second, secondErr := decodeAll(stream)
+
+		if len(first) != len(second) || (firstErr == nil) != (secondErr == nil) {
+			t.Fatalf("decoding is not stable, got %d elements (%v) then %d elements (%v)", len(first), firstErr, len(second), secondErr)
+		}
+	})
+}
+
+// decodeAll decodes all the elements of `stream` up to the end of the stream or the first
+// error.
+func decodeAll(stream []byte) (elements []interface{}, err error) {
+	decoder := NewDecoder(bytes.NewReader(stream))
+	for {
+		element, err := decoder.Next()
+		if err != nil {
+			return elements, err
+		}
+		elements = append(elements, element)
+	}
+}
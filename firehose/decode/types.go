@@ -0,0 +1,163 @@
+package decode
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Init is the `INIT` record emitted once when the Firehose instrumentation starts.
+type Init struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	Variant         string `json:"variant"`
+	NodeVersion     string `json:"nodeVersion"`
+
+	// ChainConfig is the config of the chain, nil if it was not known by the node
+	ChainConfig *params.ChainConfig `json:"chainConfig,omitempty"`
+}
+
+// The typed structures of a block are shared with the `firehose` package which builds them
+// in-process when the block trace of a context is enabled, see `firehose.Context.BlockTrace`.
+type (
+	// Block is a fully decoded block, from its `BEGIN_BLOCK` record up to its `END_BLOCK` record.
+	Block = firehose.BlockTrace
+
+	TransactionTrace     = firehose.TransactionTrace
+	ReceiptLog           = firehose.ReceiptLog
+	Call                 = firehose.Call
+	BalanceChange        = firehose.BalanceChange
+	GasChange            = firehose.GasChange
+	NonceChange          = firehose.NonceChange
+	CreateFailure        = firehose.CreateFailure
+	StorageChange        = firehose.StorageChange
+	Log                  = firehose.Log
+	SuicideChange        = firehose.SuicideChange
+	CreatedAccount       = firehose.CreatedAccount
+	AccountTouch         = firehose.AccountTouch
+	DeletedAccount       = firehose.DeletedAccount
+	CodeChange           = firehose.CodeChange
+	SetCodeAuthorization = firehose.SetCodeAuthorization
+	Transfer             = firehose.Transfer
+	ForkActivation       = firehose.ForkActivation
+	BlockWitness         = firehose.BlockWitness
+	CreatedContract      = firehose.CreatedContract
+	CliqueSeal           = firehose.CliqueSeal
+	CliqueVote           = firehose.CliqueVote
+	RecordCounts         = firehose.RecordCounts
+	BlockEmission        = firehose.BlockEmission
+	IrregularStateChange = firehose.IrregularStateChange
+)
+
+// TransactionAbort is the `TRX_ABORT` record emitted when the instrumentation panicked
+// while applying a transaction, the block import was failed and the block is not emitted.
+type TransactionAbort struct {
+	BlockNumber  uint64        `json:"blockNumber"`
+	BlockHash    common.Hash   `json:"blockHash"`
+	TxIndex      uint64        `json:"txIndex"`
+	TxHash       common.Hash   `json:"txHash"`
+	PartialTrace hexutil.Bytes `json:"partialTrace,omitempty"`
+	Message      string        `json:"message"`
+}
+
+// BlockAbort is the `BLOCK_ABORT` marker emitted when the processing of a block emitted in
+// segments failed, the segments received for the block are discarded.
+type BlockAbort struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// HeadUpdate is the `HEAD_UPDATE` record emitted when the fork choice moved the chain head to
+// a block which is not a child of the previous head, the blocks of the branch the head left
+// are not canonical anymore.
+type HeadUpdate struct {
+	Number         uint64      `json:"number"`
+	Hash           common.Hash `json:"hash"`
+	PreviousNumber uint64      `json:"previousNumber"`
+	PreviousHash   common.Hash `json:"previousHash"`
+}
+
+// PendingTransaction is the `PENDING_TRX` record emitted when a transaction entered the
+// transaction pool, its input is identified by its hash only.
+type PendingTransaction struct {
+	Hash      common.Hash     `json:"hash"`
+	From      common.Address  `json:"from"`
+	To        *common.Address `json:"to,omitempty"`
+	Nonce     uint64          `json:"nonce"`
+	GasPrice  *big.Int        `json:"gasPrice"`
+	GasLimit  uint64          `json:"gasLimit"`
+	InputHash common.Hash     `json:"inputHash"`
+}
+
+// PendingDrop is the `PENDING_DROP` record emitted when a transaction left the transaction
+// pool without being included in a block by the node.
+type PendingDrop struct {
+	Hash   common.Hash `json:"hash"`
+	Reason string      `json:"reason"`
+}
+
+// PendingReplacement is the `PENDING_REPLACE` record emitted when a transaction replaced,
+// paying a higher fee, the transaction `ReplacedHash` of the same sender and nonce.
+type PendingReplacement struct {
+	Hash         common.Hash `json:"hash"`
+	ReplacedHash common.Hash `json:"replacedHash"`
+}
+
+// PendingExecutable is the `PENDING_EXECUTABLE` record emitted when a transaction waiting for
+// a nonce gap became executable once the transaction `GapFillerHash` filled the gap.
+type PendingExecutable struct {
+	Hash          common.Hash `json:"hash"`
+	GapFillerHash common.Hash `json:"gapFillerHash"`
+}
+
+// PendingEffects is the `PENDING_TRX_EFFECTS` record emitted with the predicted effects of
+// a pending transaction speculatively executed on top of the block `HeadNumber`.
+type PendingEffects struct {
+	Hash       common.Hash   `json:"hash"`
+	HeadNumber uint64        `json:"headNumber"`
+	HeadHash   common.Hash   `json:"headHash"`
+	Status     string        `json:"status"`
+	GasUsed    uint64        `json:"gasUsed"`
+	Logs       []*ReceiptLog `json:"logs"`
+	Transfers  []*Transfer   `json:"transfers"`
+}
+
+// SideChainBlock is the `SIDE_CHAIN_BLOCK` record emitted after a block imported on a side
+// chain, the block is not canonical and forks from the canonical block `ForkParentNumber`.
+type SideChainBlock struct {
+	Number           uint64      `json:"number"`
+	Hash             common.Hash `json:"hash"`
+	ForkParentNumber uint64      `json:"forkParentNumber"`
+	ForkParentHash   common.Hash `json:"forkParentHash"`
+}
+
+// UndoBlock is the `UNDO_BLOCK` record emitted before a block at the same or a lower height
+// than the last emitted block, the last emitted block `Number` is reverted and its parent
+// becomes the last emitted block. The `Logs` of the reverted block are flagged as removed.
+type UndoBlock struct {
+	Number     uint64       `json:"number"`
+	Hash       common.Hash  `json:"hash"`
+	ParentHash common.Hash  `json:"parentHash"`
+	Logs       []*types.Log `json:"logs"`
+}
+
+// Heartbeat is the `HEARTBEAT` record emitted periodically while no block is emitted, the
+// node is alive and its chain head is `HeadNumber`, `Timestamp` being the unix time in seconds
+// at which the record was emitted.
+type Heartbeat struct {
+	HeadNumber uint64      `json:"headNumber"`
+	HeadHash   common.Hash `json:"headHash"`
+	Timestamp  uint64      `json:"timestamp"`
+}
+
+// SyncProgress is the `SYNC_PROGRESS` record emitted while the initial sync imports blocks
+// without executing them, the items of `Stage` (`headers`, `bodies` or `receipts`) being
+// imported up to block `Number`.
+type SyncProgress struct {
+	Stage  string      `json:"stage"`
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
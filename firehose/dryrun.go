@@ -0,0 +1,143 @@
+package firehose
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// DryRunRecordStats are the amount of data a category of Firehose records accounted for
+// during a dry run, see `DryRunPrinter`.
+type DryRunRecordStats struct {
+	Lines uint64
+	Bytes uint64
+}
+
+type dryRunRecordMetrics struct {
+	lines metrics.Counter
+	bytes metrics.Counter
+}
+
+// DryRunPrinter is a printer discarding everything it receives, accounting instead for the
+// lines and bytes of each category of record, the record name (`BEGIN_APPLY_TRX`,
+// `BALANCE_CHANGE`, etc.), in the `firehose/dryrun/records/<record>/{lines,bytes}` metrics.
+// Complete blocks are counted in the `firehose/dryrun/blocks` metric. Lines that are not
+// Firehose records are accounted for under the `other` category.
+//
+// Both the `text` and `jsonl` encodings are recognized, the accounting being made on the
+// encoded output, the printer must be the last one of the chain.
+//
+// DryRunPrinter is thread-safe.
+type DryRunPrinter struct {
+	lock    sync.Mutex
+	stats   map[string]*DryRunRecordStats
+	metrics map[string]*dryRunRecordMetrics
+}
+
+// NewDryRunPrinter creates a printer accounting for the data it receives and discarding it.
+func NewDryRunPrinter() *DryRunPrinter {
+	return &DryRunPrinter{
+		stats:   map[string]*DryRunRecordStats{},
+		metrics: map[string]*dryRunRecordMetrics{},
+	}
+}
+
+func (p *DryRunPrinter) Disabled() bool {
+	return false
+}
+
+func (p *DryRunPrinter) Write(in []byte) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for len(in) > 0 {
+		line := in
+		if i := bytes.IndexByte(in, '\n'); i >= 0 {
+			line, in = in[:i+1], in[i+1:]
+		} else {
+			in = nil
+		}
+
+		p.account(dryRunRecordCategory(line), len(line))
+	}
+}
+
+func (p *DryRunPrinter) Print(input ...string) {
+	if len(input) == 0 {
+		return
+	}
+
+	// Same length as the `FIRE <input> <input>...\n` line a writing printer would send
+	size := len("FIRE ") + len(input)
+	for _, in := range input {
+		size += len(in)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.account(input[0], size)
+}
+
+// Stats returns a copy of the amount of data accounted for so far, per record category.
+func (p *DryRunPrinter) Stats() map[string]DryRunRecordStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	out := make(map[string]DryRunRecordStats, len(p.stats))
+	for category, stats := range p.stats {
+		out[category] = *stats
+	}
+
+	return out
+}
+
+// account must be called with the lock held.
+func (p *DryRunPrinter) account(category string, size int) {
+	stats, found := p.stats[category]
+	if !found {
+		stats = &DryRunRecordStats{}
+		p.stats[category] = stats
+		p.metrics[category] = &dryRunRecordMetrics{
+			lines: metrics.GetOrRegisterCounter("firehose/dryrun/records/"+category+"/lines", nil),
+			bytes: metrics.GetOrRegisterCounter("firehose/dryrun/records/"+category+"/bytes", nil),
+		}
+	}
+
+	stats.Lines++
+	stats.Bytes += uint64(size)
+	p.metrics[category].lines.Inc(1)
+	p.metrics[category].bytes.Inc(int64(size))
+
+	dryRunLinesCounter.Inc(1)
+	dryRunBytesCounter.Inc(int64(size))
+	if category == "BLOCK_END" {
+		dryRunBlocksCounter.Inc(1)
+	}
+}
+
+var jsonLinesRecordPrefix = []byte(`{"record":"`)
+
+// dryRunRecordCategory returns the name of the record of the `text` or `jsonl` encoded
+// line, `other` if the line is not a Firehose record.
+func dryRunRecordCategory(line []byte) string {
+	var record []byte
+	switch {
+	case bytes.HasPrefix(line, []byte("FIRE ")):
+		record = line[len("FIRE "):]
+	case bytes.HasPrefix(line, jsonLinesRecordPrefix):
+		record = line[len(jsonLinesRecordPrefix):]
+	default:
+		return "other"
+	}
+
+	if i := bytes.IndexAny(record, " \"\n"); i >= 0 {
+		record = record[:i]
+	}
+	if len(record) == 0 {
+		return "other"
+	}
+
+	return string(record)
+}
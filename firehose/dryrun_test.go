@@ -0,0 +1,31 @@
+package firehose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunPrinter(t *testing.T) {
+	printer := NewDryRunPrinter()
+
+	printer.Print("BEGIN_BLOCK", "1")
+	printer.Write([]byte("FIRE BALANCE_CHANGE 1 aa 00 01 transfer 4\nFIRE BALANCE_CHANGE 1 bb 01 00 transfer 5\nnot a record\n"))
+	printer.Print("END_BLOCK", "1", "10", "2", "{}")
+
+	assert.Equal(t, map[string]DryRunRecordStats{
+		"BEGIN_BLOCK":    {Lines: 1, Bytes: uint64(len("FIRE BEGIN_BLOCK 1\n"))},
+		"BALANCE_CHANGE": {Lines: 2, Bytes: 2 * uint64(len("FIRE BALANCE_CHANGE 1 aa 00 01 transfer 4\n"))},
+		"other":          {Lines: 1, Bytes: uint64(len("not a record\n"))},
+		"END_BLOCK":      {Lines: 1, Bytes: uint64(len("FIRE END_BLOCK 1 10 2 {}\n"))},
+	}, printer.Stats())
+}
+
+func TestDryRunPrinter_JSONLines(t *testing.T) {
+	printer := NewDryRunPrinter()
+	NewJSONLinesPrinter(printer).Print("BEGIN_BLOCK", "1", "30000000", "30000000", ".", "00")
+
+	assert.Equal(t, map[string]DryRunRecordStats{
+		"BEGIN_BLOCK": {Lines: 1, Bytes: uint64(len(`{"record":"BEGIN_BLOCK","number":1,"gasLimit":30000000,"gasTarget":30000000,"baseFee":null,"logsBloom":"0x00"}` + "\n"))},
+	}, printer.Stats())
+}
@@ -0,0 +1,133 @@
+package firehose
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// EmittedBlock identifies the last block emitted in the order of the chain, see
+// `Context.LastEmittedBlock`.
+type EmittedBlock struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// LastEmittedBlock returns the last block flushed through the context, nil if none was. Blocks
+// emitted out of the order of the chain, like backfilled or proposed blocks, are not tracked.
+//
+// Only the sync context tracks the emitted blocks since all blocks are flushed through it.
+func (ctx *Context) LastEmittedBlock() *EmittedBlock {
+	if ctx == nil {
+		return nil
+	}
+
+	ctx.emittedLock.Lock()
+	defer ctx.emittedLock.Unlock()
+
+	if ctx.lastEmitted == nil {
+		return nil
+	}
+
+	last := *ctx.lastEmitted
+	return &last
+}
+
+// RecordUndoBlock records that the last emitted block, `header`, is undone, consumers must
+// revert it and its parent becomes the last emitted block. A block can be emitted at the same
+// or a lower height than the last emitted block only once the blocks above it were undone,
+// one by one from the last emitted one.
+//
+// The `logs` of the undone block are recorded along, in their block order, with their
+// `removed` marker set like the `eth_subscribe` logs of reverted blocks are, so that log
+// driven consumers can invalidate what they derived from them.
+//
+// An error is returned, and nothing is recorded, if `header` is not the last emitted block.
+// It must be called outside of any block.
+func (ctx *Context) RecordUndoBlock(header *types.Header, logs []*types.Log) error {
+	if ctx == nil {
+		return nil
+	}
+
+	if ctx.inBlock.Load() {
+		panic("recording an undo block while in a block scope")
+	}
+
+	ctx.emittedLock.Lock()
+	defer ctx.emittedLock.Unlock()
+
+	number, hash := header.Number.Uint64(), header.Hash()
+	if last := ctx.lastEmitted; last == nil || last.Number != number || last.Hash != hash {
+		return fmt.Errorf("undoing block #%d (%s) which is not the last emitted block %s", number, hash, ctx.lastEmitted)
+	}
+
+	removed := make([]*types.Log, len(logs))
+	for i, log := range logs {
+		copied := *log
+		copied.Removed = true
+		removed[i] = &copied
+	}
+
+	ctx.printer.Print("UNDO_BLOCK", Uint64(number), Hash(hash), Hash(header.ParentHash), JSON(removed))
+
+	ctx.lastEmitted = nil
+	if number > 0 {
+		ctx.lastEmitted = &EmittedBlock{Number: number - 1, Hash: header.ParentHash}
+	}
+
+	return nil
+}
+
+// DropBlock discards the data recorded for the block of the context instead of flushing it,
+// for a block that must not be emitted like a block imported on a side chain when those are
+// disabled, see `SideChainBlocksEnabled`. The block is not
+// tracked as emitted. When it was emitted in segments, the segments already emitted are
+// aborted, readers discard them.
+func (ctx *Context) DropBlock() {
+	if ctx == nil || !Enabled {
+		return
+	}
+
+	if v, ok := ctx.printer.(*ToBufferPrinter); ok {
+		v.Reset()
+	}
+
+	ctx.exitBlock()
+}
+
+// FlushSideChainBlock flushes the data recorded for the block of the context, a block imported
+// on a side chain, like `FlushBlock` does but out of the order of the chain. The block is not
+// tracked as emitted, the blocks emitted at or above its height are left untouched and it's
+// emitted again, as part of the chain, if a reorg makes it canonical later on.
+func (ctx *Context) FlushSideChainBlock() error {
+	if ctx == nil || !Enabled {
+		return nil
+	}
+
+	ctx.blockOutOfOrder = true
+	return ctx.FlushBlock()
+}
+
+// trackEmittedBlock makes the block `number` with `hash` the last emitted block. It refuses,
+// failing loudly, a block at the same or a lower height than the last emitted block since it
+// would make the stream ambiguous, the blocks above it must be undone first.
+func (ctx *Context) trackEmittedBlock(number uint64, hash common.Hash) error {
+	ctx.emittedLock.Lock()
+	defer ctx.emittedLock.Unlock()
+
+	if last := ctx.lastEmitted; last != nil && number <= last.Number {
+		return fmt.Errorf("refusing to emit block #%d (%s) at or below the last emitted block %s without undoing it first", number, hash, last)
+	}
+
+	ctx.lastEmitted = &EmittedBlock{Number: number, Hash: hash}
+	return nil
+}
+
+func (b *EmittedBlock) String() string {
+	if b == nil {
+		return "<none>"
+	}
+
+	return fmt.Sprintf("#%d (%s)", b.Number, b.Hash)
+}
@@ -0,0 +1,78 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushBlock_EmissionOrder(t *testing.T) {
+	defer func(enabled, syncEnabled bool, ctx *Context) {
+		Enabled, SyncInstrumentationEnabled, syncContext = enabled, syncEnabled, ctx
+	}(Enabled, SyncInstrumentationEnabled, syncContext)
+
+	printer := NewToBufferPrinter(1024)
+	Enabled, SyncInstrumentationEnabled, syncContext = true, true, NewContext(printer, false)
+
+	block1 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	block2 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), ParentHash: block1.Hash()})
+	fork2 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), ParentHash: block1.Hash(), Extra: []byte{0x01}})
+
+	flush := func(block *types.Block, outOfOrder bool) error {
+		ctx := NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+		ctx.StartBlock(block)
+		if outOfOrder {
+			ctx.RecordBackfilledBlock(block)
+		}
+		ctx.EndBlock(block, nil)
+		return ctx.FlushBlock()
+	}
+
+	require.NoError(t, flush(block1, false))
+	require.NoError(t, flush(block2, false))
+	assert.Equal(t, &EmittedBlock{Number: 2, Hash: block2.Hash()}, syncContext.LastEmittedBlock())
+
+	// A block at the same height is refused, nothing is written
+	written := printer.Buffer().Len()
+	assert.EqualError(t, flush(fork2, false), "refusing to emit block #2 ("+fork2.Hash().String()+") at or below the last emitted block #2 ("+block2.Hash().String()+") without undoing it first")
+	assert.Equal(t, written, printer.Buffer().Len())
+
+	// A dropped block is neither written nor tracked
+	dropped := NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	dropped.StartBlock(fork2)
+	dropped.EndBlock(fork2, nil)
+	dropped.DropBlock()
+	assert.Equal(t, written, printer.Buffer().Len())
+	assert.Equal(t, &EmittedBlock{Number: 2, Hash: block2.Hash()}, syncContext.LastEmittedBlock())
+
+	// Blocks emitted out of the order of the chain are not tracked
+	require.NoError(t, flush(block1, true))
+
+	side := NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	side.StartBlock(fork2)
+	side.EndBlock(fork2, nil)
+	written = printer.Buffer().Len()
+	require.NoError(t, side.FlushSideChainBlock())
+	assert.Greater(t, printer.Buffer().Len(), written)
+	assert.Equal(t, &EmittedBlock{Number: 2, Hash: block2.Hash()}, syncContext.LastEmittedBlock())
+
+	assert.Error(t, syncContext.RecordUndoBlock(block1.Header(), nil))
+	require.NoError(t, syncContext.RecordUndoBlock(block2.Header(), nil))
+	assert.Equal(t, &EmittedBlock{Number: 1, Hash: block1.Hash()}, syncContext.LastEmittedBlock())
+	assert.Contains(t, printer.Buffer().String(), "FIRE UNDO_BLOCK 2 "+Hash(block2.Hash())+" "+Hash(block1.Hash())+" []\n")
+
+	// The logs of the undone block are recorded as removed, leaving the received ones untouched
+	log := &types.Log{Address: common.Address{0xaa}, BlockNumber: 2, BlockHash: fork2.Hash()}
+	require.NoError(t, flush(fork2, false))
+	require.NoError(t, syncContext.RecordUndoBlock(fork2.Header(), []*types.Log{log}))
+	assert.False(t, log.Removed)
+	assert.Contains(t, printer.Buffer().String(), "FIRE UNDO_BLOCK 2 "+Hash(fork2.Hash())+" "+Hash(block1.Hash())+" "+JSON([]*types.Log{{Address: log.Address, BlockNumber: 2, BlockHash: fork2.Hash(), Removed: true}})+"\n")
+
+	require.NoError(t, flush(fork2, false))
+	assert.Equal(t, &EmittedBlock{Number: 2, Hash: fork2.Hash()}, syncContext.LastEmittedBlock())
+}
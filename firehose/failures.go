@@ -0,0 +1,64 @@
+package firehose
+
+// CallFailureCode classifies why a call failed. Unlike the error message recorded along with
+// it, which can change from one node version to the other, the codes are stable so consumers
+// can rely on them to classify the failures.
+type CallFailureCode string
+
+const (
+	// ExecutionRevertedCallFailureCode is an explicit `REVERT` of the called code
+	ExecutionRevertedCallFailureCode CallFailureCode = "execution_reverted"
+	// DepthLimitCallFailureCode is a call exceeding the maximum call depth, it never started
+	DepthLimitCallFailureCode CallFailureCode = "depth_limit"
+	// InsufficientBalanceCallFailureCode is a call whose caller cannot afford the value it
+	// transfers, it never started
+	InsufficientBalanceCallFailureCode CallFailureCode = "insufficient_balance"
+	// OutOfGasCallFailureCode is a call running out of gas while executing its code
+	OutOfGasCallFailureCode CallFailureCode = "out_of_gas"
+	// CodeStoreOutOfGasCallFailureCode is a contract creation without enough gas left to pay
+	// for storing the code it returned
+	CodeStoreOutOfGasCallFailureCode CallFailureCode = "code_store_out_of_gas"
+	// GasUintOverflowCallFailureCode is a call whose gas cost overflows 64 bits
+	GasUintOverflowCallFailureCode CallFailureCode = "gas_uint_overflow"
+	// ContractAddressCollisionCallFailureCode is a contract creation at an address already
+	// holding code or a nonce
+	ContractAddressCollisionCallFailureCode CallFailureCode = "contract_address_collision"
+	// NonceOverflowCallFailureCode is a contract creation whose caller nonce cannot be
+	// incremented anymore without overflowing 64 bits (EIP-2681)
+	NonceOverflowCallFailureCode CallFailureCode = "nonce_overflow"
+	// MaxCodeSizeExceededCallFailureCode is a contract creation returning code larger than
+	// the maximum code size
+	MaxCodeSizeExceededCallFailureCode CallFailureCode = "max_code_size_exceeded"
+	// StackUnderflowCallFailureCode is an operation popping more items than the stack holds
+	StackUnderflowCallFailureCode CallFailureCode = "stack_underflow"
+	// StackOverflowCallFailureCode is an operation pushing beyond the stack limit
+	StackOverflowCallFailureCode CallFailureCode = "stack_overflow"
+	// InvalidOpCodeCallFailureCode is the execution of an undefined or invalid opcode
+	InvalidOpCodeCallFailureCode CallFailureCode = "invalid_opcode"
+	// InvalidJumpCallFailureCode is a jump to a destination which is not a `JUMPDEST`
+	InvalidJumpCallFailureCode CallFailureCode = "invalid_jump"
+	// InvalidSubroutineCallFailureCode is a misuse of the EIP-2315 subroutines (invalid
+	// entry, return without subroutine or return stack exceeded)
+	InvalidSubroutineCallFailureCode CallFailureCode = "invalid_subroutine"
+	// WriteProtectionCallFailureCode is a state modification attempted in a static call
+	WriteProtectionCallFailureCode CallFailureCode = "write_protection"
+	// ReturnDataOutOfBoundsCallFailureCode is a `RETURNDATACOPY` reading past the return data
+	ReturnDataOutOfBoundsCallFailureCode CallFailureCode = "return_data_out_of_bounds"
+	// PrecompileFailureCallFailureCode is a precompiled contract rejecting its input
+	PrecompileFailureCallFailureCode CallFailureCode = "precompile_failure"
+	// UnknownCallFailureCode is a failure that is not classified, the error message recorded
+	// along with it is then the only information about it
+	UnknownCallFailureCode CallFailureCode = "unknown"
+)
+
+// GasDisposition denotes what happened to the gas given to a call that failed.
+type GasDisposition string
+
+const (
+	// BurnedGasDisposition is the gas given to the call being consumed, as for any failure
+	// other than a revert
+	BurnedGasDisposition GasDisposition = "burned"
+	// ReturnedGasDisposition is the gas given to the call being returned to its caller, as for
+	// a revert
+	ReturnedGasDisposition GasDisposition = "returned"
+)
@@ -0,0 +1,146 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+func FuzzHex(f *testing.F) {
+	f.Add([]byte(nil))
+	f.Add([]byte{})
+	f.Add([]byte{0x00})
+	f.Add(bytes.Repeat([]byte{0xff}, 32))
+	f.Add(bytes.Repeat([]byte{0xff}, 64*1024))
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		out := Hex(in)
+		if strings.ContainsAny(out, " \n") {
+			t.Fatalf("encoded value %q contains a field or record separator", out)
+		}
+
+		if out == "." {
+			if len(in) != 0 {
+				t.Fatalf("non-empty value %x encoded as null", in)
+			}
+			return
+		}
+
+		decoded, err := hex.DecodeString(out)
+		if err != nil {
+			t.Fatalf("encoded value %q is not hexadecimal: %v", out, err)
+		}
+		if !bytes.Equal(decoded, in) {
+			t.Fatalf("round trip mismatch, got %x, expected %x", decoded, in)
+		}
+	})
+}
+
+func FuzzBigInt(f *testing.F) {
+	f.Add([]byte(nil), true)
+	f.Add([]byte{}, false)
+	f.Add([]byte{0x00, 0x00, 0x01}, false)
+	f.Add(math.MaxBig256.Bytes(), false)
+	f.Add(bytes.Repeat([]byte{0xff}, 1024), false)
+
+	f.Fuzz(func(t *testing.T, in []byte, null bool) {
+		var value *big.Int
+		if !null {
+			value = new(big.Int).SetBytes(in)
+		}
+
+		out := BigInt(value)
+		if out == "." {
+			if value != nil && value.Sign() != 0 {
+				t.Fatalf("non-zero value %s encoded as null", value)
+			}
+			return
+		}
+
+		decoded, err := hex.DecodeString(out)
+		if err != nil {
+			t.Fatalf("encoded value %q is not hexadecimal: %v", out, err)
+		}
+		if decoded[0] == 0 {
+			t.Fatalf("encoded value %q is not minimal", out)
+		}
+		if new(big.Int).SetBytes(decoded).Cmp(value) != 0 {
+			t.Fatalf("round trip mismatch, got %x, expected %s", decoded, value)
+		}
+	})
+}
+
+func FuzzJSON(f *testing.F) {
+	f.Add("")
+	f.Add("value with spaces\nand new lines")
+	f.Add("\x00\xff invalid UTF-8 \xc3\x28")
+	f.Add(`{"json":"already"}`)
+
+	f.Fuzz(func(t *testing.T, in string) {
+		out := JSON([]string{in})
+		if strings.ContainsAny(out, "\n") {
+			t.Fatalf("encoded value %q contains a record separator", out)
+		}
+
+		var decoded []string
+		if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+			t.Fatalf("encoded value %q is not valid JSON: %v", out, err)
+		}
+
+		// Invalid UTF-8 sequences are replaced by the replacement character when encoded
+		if utf8.ValidString(in) && (len(decoded) != 1 || decoded[0] != in) {
+			t.Fatalf("round trip mismatch, got %q, expected %q", decoded, in)
+		}
+	})
+}
+
+func FuzzJSONLinesPrinter(f *testing.F) {
+	f.Add("BEGIN_BLOCK 7")
+	f.Add("BEGIN_BLOCK 007")
+	f.Add("BEGIN_APPLY_TRX 01 . . . . . . . . . . 0")
+	f.Add("END_APPLY_TRX 007 . . 1 [] 0")
+	f.Add("TRX_FROM 00000000000000000000000000000000000000bb")
+	f.Add("BALANCE_DELTA 1 00000000000000000000000000000000000000bb -03e8 transfer 5")
+	f.Add("UNKNOWN_RECORD with some fields")
+	f.Add("END_BLOCK 1 2 3 {\"header\":")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, record string) {
+		if strings.Contains(record, "\n") {
+			t.Skip("a single record is assembled at a time")
+		}
+
+		buffer := NewToBufferPrinter(0)
+		printer := NewJSONLinesPrinter(buffer)
+
+		kind := record
+		if i := strings.IndexByte(record, ' '); i >= 0 {
+			kind = record[:i]
+		}
+
+		printer.Write([]byte("FIRE " + record + "\n"))
+		printer.Print(strings.Split(record, " ")...)
+
+		lines := strings.Split(strings.TrimSuffix(buffer.Buffer().String(), "\n"), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 JSON lines, got %d: %q", len(lines), lines)
+		}
+
+		for _, line := range lines {
+			var decoded map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+				t.Fatalf("line %q is not valid JSON: %v", line, err)
+			}
+
+			if utf8.ValidString(kind) && decoded["record"] != kind {
+				t.Fatalf("line %q has record %q, expected %q", line, decoded["record"], kind)
+			}
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// GenesisView is the view Firehose has of the genesis of the chain, enough to validate the
+// genesis block and to record it. It's implemented for `*core.Genesis` by the adapter
+// returned by `core.NewFirehoseGenesisView`, the `core` package depending on this one.
+type GenesisView interface {
+	// Header returns the header of the genesis block computed out of the genesis.
+	Header() *types.Header
+
+	// ChainConfig returns the chain config of the genesis, nil if it has none.
+	ChainConfig() *params.ChainConfig
+
+	// ForEachAccount calls `fn` for each account of the genesis allocation, in any order.
+	ForEachAccount(fn func(account *GenesisAccount))
+}
+
+// GenesisAccount is an account prefunded or predeployed by the genesis allocation.
+type GenesisAccount struct {
+	Address common.Address
+	Balance *big.Int
+	Code    []byte
+	Nonce   uint64
+	Storage map[common.Hash]common.Hash
+}
+
+// RecordGenesisBlock records `block`, the genesis block of `genesis`, the forks activated by
+// `config` at the genesis block are recorded and the genesis allocation is recorded as the
+// changes of a synthetic transaction, see `recordGenesisAlloc`.
+func (ctx *Context) RecordGenesisBlock(block *types.Block, config *params.ChainConfig, genesis GenesisView) {
+	if ctx == nil {
+		return
+	}
+
+	if ctx.inBlock.Load() {
+		panic("trying to record genesis block while in block context")
+	}
+
+	zero := common.Address{}
+	root := block.Root()
+
+	ctx.StartBlock(block)
+	ctx.RecordForkActivations(config, block)
+	ctx.StartTransactionRaw(common.Hash{}, &zero, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0)
+	ctx.RecordTrxFrom(zero)
+	ctx.recordGenesisAlloc(genesis)
+	ctx.EndTransaction(&types.Receipt{PostState: root[:]})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, block.Difficulty())
+	ctx.FlushBlock()
+}
+
+// recordGenesisAlloc records the genesis allocation, the balance, code, nonce and storage of
+// each prefunded or predeployed account, as changes. The accounts, and the storage slots of
+// each, are recorded in their key order so the genesis block payload is the same from one
+// run to another.
+func (ctx *Context) recordGenesisAlloc(genesis GenesisView) {
+	var accounts []*GenesisAccount
+	genesis.ForEachAccount(func(account *GenesisAccount) {
+		accounts = append(accounts, account)
+	})
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].Address[:], accounts[j].Address[:]) < 0
+	})
+
+	for _, account := range accounts {
+		ctx.RecordNewAccount(account.Address)
+
+		ctx.RecordBalanceChange(account.Address, common.Big0, account.Balance, GenesisBalanceBalanceChangeReason)
+		if len(account.Code) > 0 {
+			ctx.RecordCodeChange(account.Address, nil, nil, crypto.Keccak256Hash(account.Code), account.Code)
+		}
+
+		if account.Nonce > 0 {
+			ctx.RecordNonceChange(account.Address, 0, account.Nonce)
+		}
+
+		keys := make([]common.Hash, 0, len(account.Storage))
+		for key := range account.Storage {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+
+		for _, key := range keys {
+			ctx.RecordStorageChange(account.Address, key, common.Hash{}, account.Storage[key])
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGenesisView is a genesis view out of a fixed header and allocation.
+type testGenesisView struct {
+	header   *types.Header
+	accounts []*GenesisAccount
+}
+
+func (v *testGenesisView) Header() *types.Header            { return v.header }
+func (v *testGenesisView) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+func (v *testGenesisView) ForEachAccount(fn func(account *GenesisAccount)) {
+	for _, account := range v.accounts {
+		fn(account)
+	}
+}
+
+func TestRecordGenesisBlock(t *testing.T) {
+	defer func(enabled bool, ctx *Context) { Enabled, syncContext = enabled, ctx }(Enabled, syncContext)
+	Enabled, syncContext = true, NewContext(NewToBufferPrinter(1024), false)
+
+	genesis := &testGenesisView{
+		header: &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)},
+		accounts: []*GenesisAccount{
+			{Address: common.Address{0xbb}, Balance: big.NewInt(1)},
+			{Address: common.Address{0xaa}, Balance: big.NewInt(2), Code: []byte{0x00}, Nonce: 1, Storage: map[common.Hash]common.Hash{
+				{0x02}: {0x20},
+				{0x01}: {0x10},
+				{0x03}: {0x30},
+			}},
+		},
+	}
+
+	record := func() string {
+		buffer := bytes.NewBuffer(nil)
+		NewBlockContextWithBuffer(buffer).RecordGenesisBlock(types.NewBlockWithHeader(genesis.Header()), genesis.ChainConfig(), genesis)
+		return buffer.String()
+	}
+
+	// The accounts are iterated in reverse order the second time, the payload must not change
+	payload := record()
+	genesis.accounts[0], genesis.accounts[1] = genesis.accounts[1], genesis.accounts[0]
+	require.Equal(t, payload, record(), "genesis block payload must not depend on the allocation order")
+
+	var kinds []string
+	for _, line := range strings.Split(strings.TrimSpace(payload), "\n") {
+		fields := strings.Fields(line)
+		kind := fields[1]
+		switch kind {
+		case "BEGIN_BLOCK", "BEGIN_APPLY_TRX", "TRX_FROM", "END_APPLY_TRX", "FINALIZE_BLOCK", "END_BLOCK", "FORK_ACTIVATION":
+			continue
+		case "STORAGE_CHANGE":
+			kind += " " + fields[4]
+		}
+		kinds = append(kinds, kind)
+	}
+
+	assert.Equal(t, []string{
+		"CREATED_ACCOUNT", "BALANCE_CHANGE", "CODE_CHANGE", "NONCE_CHANGE",
+		"STORAGE_CHANGE " + Hash(common.Hash{0x01}),
+		"STORAGE_CHANGE " + Hash(common.Hash{0x02}),
+		"STORAGE_CHANGE " + Hash(common.Hash{0x03}),
+		"CREATED_ACCOUNT", "BALANCE_CHANGE",
+	}, kinds)
+}
@@ -2,10 +2,11 @@ package firehose
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
-	"reflect"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -40,17 +41,227 @@ var MiningEnabled = false
 // and will not impact any other firehose logs. If you need firehose
 // instrumentation, activate firehose. The firehose setting has
 // precedence over this setting.
+//
+// While the initial sync imports headers, bodies or receipts without executing blocks, the
+// progress is recorded as `SYNC_PROGRESS` records, see `RecordSyncProgress`.
 var BlockProgressEnabled = false
 
+// NonExecutedBlocksEnabled determines if blocks imported without being executed, like the
+// blocks below the pivot of a fast or snap sync, are emitted out of their header and receipts.
+// They are flagged as non-executed and contain no call nor state change, see
+// `Context.RecordNonExecutedBlock`.
+//
+// When disabled (the default), a full sync is forced so that no block is imported without
+// being executed.
+var NonExecutedBlocksEnabled = false
+
+// SideChainBlocksEnabled determines if the blocks executed while being imported on a side
+// chain are emitted, out of the order of the chain, each one followed by a `SIDE_CHAIN_BLOCK`
+// record marking it as non-canonical along with the canonical block it forks from. When
+// disabled (the default), their data is dropped.
+//
+// Either way, a side chain block is emitted as part of the chain if a reorg makes it canonical
+// later on. Side chain blocks whose fork parent state was pruned are not executed on import,
+// they are emitted only then.
+var SideChainBlocksEnabled = false
+
+// PendingTransactionsEnabled determines if the transactions entering the transaction pool
+// are emitted as `PENDING_TRX` records and the ones leaving it without being included in a
+// block by this node as `PENDING_DROP` records. The replacements of a transaction by a higher
+// fee one are emitted as `PENDING_REPLACE` records and the transactions becoming executable
+// once a nonce gap is filled as `PENDING_EXECUTABLE` records.
+var PendingTransactionsEnabled = false
+
+// PendingEffectsEnabled determines if the transactions promoted as executable in the
+// transaction pool are speculatively executed on top of the current head, their predicted
+// effects being emitted as `PENDING_TRX_EFFECTS` records.
+var PendingEffectsEnabled = false
+
+// ProposedBlocksEnabled determines if, when mining is enabled (see `MiningEnabled`), each
+// candidate block assembled by the miner is emitted as a proposed block before being sealed,
+// see `ProposedBlock`.
+var ProposedBlocksEnabled = false
+
+// HeartbeatInterval is the interval at which a `HEARTBEAT` record is emitted while no block
+// is emitted, e.g. when waiting on peers or when the chain is stalled. When set to 0 (the
+// default), no heartbeat is emitted.
+var HeartbeatInterval = time.Duration(0)
+
+// BlockWitnessEnabled determines if, for each executed block, the parent state accessed while
+// executing it is emitted as a `BLOCK_WITNESS` record, enabling stateless verification of the
+// block from the Firehose stream. Witnesses are large, it's disabled by default.
+var BlockWitnessEnabled = false
+
+// BlockStoreRetention is the number of most recent block heights whose emitted blocks are
+// stored in the node's database, so consumers can fetch again a block they missed through
+// the `firehose_getBlock` RPC method instead of re-executing it. The blocks emitted in segments
+// are not stored, their data is never held in full, see `BlockSegmentSize`. When set to 0 (the
+// default), no block is stored.
+var BlockStoreRetention = uint64(0)
+
+// CallIndexEnabled determines if the calls of each executed block emitted are indexed in the
+// node's database by caller and callee address, so internal transactions of an address can
+// be looked up through the `firehose_getCallsByAddress` RPC method, which logs cannot
+// provide. Only the blocks emitted while it's enabled are indexed, it's disabled by default.
+var CallIndexEnabled = false
+
+// TransferIndexEnabled determines if the value transfers of each executed block emitted (value
+// sent along calls, self-destructed balances, block, uncle and transaction fee rewards) are
+// indexed in the node's database by sender and recipient address, so the internal transfers
+// of an address can be looked up through the `firehose_getTransfers` RPC method. Only the
+// blocks emitted while it's enabled are indexed, it's disabled by default.
+var TransferIndexEnabled = false
+
+// AccountTouchesEnabled determines if the EIP-158 touches of an account, the zero value
+// balance additions performed by static calls to make an empty account eligible for removal,
+// are emitted as `ACCOUNT_TOUCH` records, distinct from the balance changes which they are
+// not. When disabled (the default), touches are not recorded at all.
+var AccountTouchesEnabled = false
+
+// TimingsEnabled determines if the wall-clock time spent applying each transaction and each
+// block is measured and recorded in their `END_APPLY_TRX` and `END_BLOCK` records. The
+// `BLOCK_BEGIN` and `BLOCK_END` markers then record the wall-clock time at which they were
+// written along with the monotonic time elapsed since the block start, so the latency from
+// the block time up to its availability in the stream can be measured. When disabled (the
+// default), the recorded durations and times are always 0.
+var TimingsEnabled = false
+
+// CreationCodeEnabled determines if the init code of each contract creation is recorded as the
+// input of its `EVM_PARAM` record and the runtime code it returned as the return data of its
+// `EVM_END_CALL` record, so verification services can match the created contracts to source
+// builds. When disabled (the default), both are empty, the init code being available in the
+// transaction input or in the memory of the creating call.
+var CreationCodeEnabled = false
+
+// CreationCodeMaxSize is the amount of bytes above which the init code or runtime code of a
+// contract creation is not recorded even when `CreationCodeEnabled` is set, only its hash is.
+// When set to 0 (the default), the code is recorded whatever its size.
+var CreationCodeMaxSize = 0
+
+// SinkBatchSize is the amount of bytes accumulated in memory before being written to
+// standard output. When set to 0 (the default), every flushed block or line is written
+// right away to standard output.
+//
+// Batching writes greatly reduce the syscall overhead when blocks are small and frequent,
+// like when re-processing old parts of the chain.
+var SinkBatchSize = 0
+
+// SinkBatchFlushInterval is the maximum amount of time accumulated data stays buffered
+// in memory before being written to standard output when batching is enabled through
+// `SinkBatchSize`.
+var SinkBatchFlushInterval = 1 * time.Second
+
+// SinkWriter is where the Firehose output is written, standard output unless redirected,
+// for example to capture the stream of an in-process node in tests. It must be set before
+// `Init` is called.
+var SinkWriter io.Writer = os.Stdout
+
+// DryRun when enabled performs all the instrumentation and encoding of the Firehose records
+// but discards the output instead of writing it to standard output, accounting for the
+// lines and bytes of each record category in metrics, see `DryRunPrinter`. It's meant to
+// size the storage and bandwidth of a full history run. The sink options (batching,
+// throttling and write failure policy) have no effect in dry run.
+var DryRun = false
+
+// SinkThrottleThreshold is the amount of bytes of Firehose output waiting to be written to
+// standard output above which block import is slowed down, giving a slow consumer the time
+// to catch up without blocking import right away nor dropping data, see `ThrottleSync`. When
+// set to 0 (the default), output is written synchronously and import blocks on a slow
+// consumer. When set, `SinkBatchSize` is ignored, queued output is written in batches.
+var SinkThrottleThreshold = 0
+
+// SinkThrottleMaxDelay is the maximum amount of time block import is slowed down, after each
+// block, while the sink backlog is above `SinkThrottleThreshold`.
+var SinkThrottleMaxDelay = 500 * time.Millisecond
+
+// SinkRateLimit is the amount of bytes per second, on average, written to standard output.
+// It keeps a job sharing its host with other nodes, like a backfill, from saturating the disk
+// or the network, writes beyond the limit wait and block import is slowed down accordingly.
+// When set to 0 (the default), the output is not rate limited.
+var SinkRateLimit = 0
+
+// SinkRateBurst is the amount of bytes that can be written at once to standard output, above
+// `SinkRateLimit`, after the output was idle. When set to 0 (the default), the burst is the
+// rate limit, one second worth of output.
+var SinkRateBurst = 0
+
+// BlockBufferSize is the initial capacity, in bytes, of the buffer accumulating the Firehose
+// data of the block being processed, re-used across blocks. The buffer is grown for larger
+// blocks and shrunk back once the recent blocks are much smaller, see `BufferMemoryCap`.
+var BlockBufferSize = 50 * 1024 * 1024
+
+// TxBufferSize is the initial capacity, in bytes, of the buffer accumulating the Firehose data
+// of the transaction being processed, re-used across transactions and tuned like the block one.
+var TxBufferSize = 5 * 1024 * 1024
+
+// BufferMemoryCap is the amount of bytes the block and transaction buffers can hold together.
+// A block whose Firehose data does not fit is not emitted, flushing it fails with
+// `ErrBufferMemoryCapExceeded` and so does its import, the cap must then be raised for the
+// node to make progress. When set to 0 (the default), the buffers grow without limit.
+var BufferMemoryCap = 0
+
+// BlockSegmentSize is the amount of bytes of Firehose data accumulated for a block above which
+// the data is emitted right away, at the end of the transaction being processed, as a segment
+// of the block instead of being held until the block is complete. It bounds the memory used by
+// giant blocks and spreads their emission over their processing. Readers assemble the ordered
+// `BLOCK_SEGMENT`s and commit the block on its `BLOCK_END` marker, a `BLOCK_ABORT` marker
+// discarding them. The blocks emitted in segments are left out of the block store, see
+// `BlockStoreRetention`. When set to 0 (the default), blocks are emitted at once.
+var BlockSegmentSize = 0
+
+// SummaryInterval is the amount of blocks emitted between two INFO log lines summarizing the
+// Firehose throughput over them (blocks and bytes per second, average block payload size and
+// sink backlog), giving operators visibility without scraping the metrics. When set to 0 (the
+// default), no summary is logged.
+var SummaryInterval = uint64(0)
+
+// OTLPEndpoint is the OpenTelemetry collector, an OTLP/HTTP endpoint like
+// `http://localhost:4318`, to which the spans tracing the processing of the blocks and the
+// flush of their Firehose data are exported, so import slowdowns can be correlated with the
+// Firehose behavior, see `Span`. When empty (the default), nothing is traced.
+var OTLPEndpoint = ""
+
+// OTLPServiceName is the `service.name` resource attribute of the spans exported to the
+// `OTLPEndpoint` collector, telling apart the nodes exporting to the same collector.
+var OTLPServiceName = "geth"
+
+// SinkOutputFormat determines how the records are written to standard output, either as the
+// positional `FIRE` lines read by the Firehose readers (the default) or as JSON lines for
+// ad hoc consumption (e.g. jq, log pipelines), see `OutputFormat`.
+var SinkOutputFormat = OutputFormatText
+
+// BlockReplayPolicy determines what happens to the blocks emitted again after a restart while
+// they were already emitted completely before it, see `ReplayPolicy` for the possible values.
+var BlockReplayPolicy = ReplayPolicyMark
+
+// SinkWriteFailurePolicy determines what happens when Firehose data cannot be written to
+// standard output after all retries. See `WriteFailurePolicy` for the possible values.
+// Defaults to dropping the data and logging the error, like Firehose always did.
+var SinkWriteFailurePolicy = WriteFailurePolicyDropAndLog
+
+// SinkShutdownTimeout is the amount of time given to an in-flight Firehose write to
+// complete when the node is shutting down before it's aborted, see `CancelSink`.
+var SinkShutdownTimeout = 10 * time.Second
+
+// SelfCheck determines if, at the end of each block, the recorded balance and gas changes
+// are verified against the block accounting, see `SelfCheckMode` for possible values.
+var SelfCheck = SelfCheckOff
+
+// StrictChangeReasons determines if recording a balance or gas change with a reason that
+// was not registered (see `RegisterBalanceChangeReason` and `RegisterGasChangeReason`)
+// panics instead of being emitted as-is.
+var StrictChangeReasons = false
+
+// BadBlocksDir is the directory where the diagnostic report of a block failing processing
+// or consensus validation is written, see `ReportBadBlock`. When empty (the default), the
+// system's temporary directory is used.
+var BadBlocksDir = ""
+
 // GenesisConfig keeps globally for the process the genesis config of the chain.
 // The genesis config extracted from the initialization code of Geth, otherwise
 // the operator will need to set the flag `--firehose-genesis-file` pointing
-// it to correct genesis.json file for the chain.
-//
-// **Note** We use `interface{}` here instead of `*core.Genesis` because we otherwise
-// have a compilation cycle because `core` package already uses `firehose` package.
-// Consumer of this library make the cast back to the correct types when needed.
-var GenesisConfig interface{}
+// it to correct genesis.json file for the chain. See `GenesisView`.
+var GenesisConfig GenesisView
 
 var MissingGenesisPanicMessage = "Firehose requires to have the genesis config to properly emit genesis block for this chain " +
 	"but it appears it was not set properly. Ensure you are using either chain's specific flag like " +
@@ -60,19 +271,24 @@ var MissingGenesisPanicMessage = "Firehose requires to have the genesis config t
 
 // Init initializes firehose with the given parameters.
 //
-// We cannot depend on `core` package because it already depends on `firehose` package. That's why here you see `genesis interface{}`
-// (which should have been `*core.Genesis`) and a provided way to decode the genesis reader into its correct type.
+// The `genesis` is the view of the genesis built by the node (see `GenesisView`), when it's
+// nil, the genesis is read from `genesisFile`, if set, through `decodeGenesis`. It fails when
+// the transaction types of the node's chain model are not the ones Firehose knows.
 func Init(
 	enabled bool,
 	syncInstrumentation bool,
 	miningEnabled bool,
 	blockProgress bool,
-	genesis interface{},
+	genesis GenesisView,
 	genesisFile string,
-	newGenesis func() interface{},
+	decodeGenesis func(reader io.Reader) (GenesisView, error),
 	gethVersion string,
 ) error {
 	log.Debug("Initializing firehose")
+	if err := validateKnownTransactionTypes(); err != nil {
+		return err
+	}
+
 	Enabled = enabled
 	SyncInstrumentationEnabled = syncInstrumentation
 	MiningEnabled = miningEnabled
@@ -80,8 +296,7 @@ func Init(
 
 	genesisProvenance := "unset"
 
-	// We must check for both `nil` and `(*core.Genesis)(nil)`, latter case that is not catch by using `genesis == nil` directly
-	if !isNilInterfaceOrNilValue(genesis) {
+	if genesis != nil {
 		GenesisConfig = genesis
 		genesisProvenance = "Geth Specific Flag (--<chain>)"
 	} else {
@@ -92,8 +307,8 @@ func Init(
 			}
 			defer file.Close()
 
-			var genesis = newGenesis()
-			if err := json.NewDecoder(file).Decode(genesis); err != nil {
+			genesis, err := decodeGenesis(file)
+			if err != nil {
 				return fmt.Errorf("decode genesis file %q: %w", genesisFilePath, err)
 			}
 
@@ -103,55 +318,121 @@ func Init(
 	}
 
 	if Enabled {
+		if err := validateBufferSizes(); err != nil {
+			return err
+		}
 		AllocateBuffers()
 	}
 
+	StartTracing(OTLPEndpoint, OTLPServiceName)
+
+	// The sink taps receive exactly what is written to the sink, see `TapSink`
+	var sinkWriter io.Writer = &tappedWriter{SinkWriter}
+
+	sinkRateLimiter = nil
+	if SinkRateLimit > 0 && !DryRun {
+		sinkRateLimiter = newRateLimitedWriter(sinkContext, sinkWriter, SinkRateLimit, SinkRateBurst)
+		sinkWriter = sinkRateLimiter
+	}
+
+	if DryRun {
+		syncContext.printer = NewDryRunPrinter()
+	} else if SinkThrottleThreshold > 0 {
+		if SinkBatchSize > 0 {
+			log.Warn("Firehose sink batch size is ignored when sink throttling is enabled", "sink_batch_size", SinkBatchSize, "sink_throttle_threshold", SinkThrottleThreshold)
+		}
+		syncContext.printer = NewQueueingPrinter(sinkContext, sinkWriter, SinkThrottleThreshold)
+	} else if SinkBatchSize > 0 {
+		syncContext.printer = NewBatchingPrinter(sinkContext, sinkWriter, SinkBatchSize, SinkBatchFlushInterval)
+	} else {
+		syncContext.printer = NewDelegateToWriterPrinter(sinkContext, sinkWriter)
+	}
+
+	if SinkOutputFormat == OutputFormatJSONLines {
+		syncContext.printer = NewJSONLinesPrinter(syncContext.printer)
+	}
+
 	if Enabled || SyncInstrumentationEnabled || BlockProgressEnabled || MiningEnabled {
 		log.Info("Firehose initialized",
 			"enabled", Enabled,
 			"sync_instrumentation_enabled", SyncInstrumentationEnabled,
 			"mining_enabled", MiningEnabled,
 			"block_progress_enabled", BlockProgressEnabled,
-			"genesis_configured", genesis != nil,
+			"non_executed_blocks_enabled", NonExecutedBlocksEnabled,
+			"side_chain_blocks_enabled", SideChainBlocksEnabled,
+			"pending_transactions_enabled", PendingTransactionsEnabled,
+			"pending_effects_enabled", PendingEffectsEnabled,
+			"proposed_blocks_enabled", ProposedBlocksEnabled,
+			"heartbeat_interval", HeartbeatInterval,
+			"block_witness_enabled", BlockWitnessEnabled,
+			"account_touches_enabled", AccountTouchesEnabled,
+			"timings_enabled", TimingsEnabled,
+			"creation_code_enabled", CreationCodeEnabled,
+			"creation_code_max_size", CreationCodeMaxSize,
+			"block_store_retention", BlockStoreRetention,
+			"call_index_enabled", CallIndexEnabled,
+			"transfer_index_enabled", TransferIndexEnabled,
+			"block_buffer_size", BlockBufferSize,
+			"tx_buffer_size", TxBufferSize,
+			"buffer_memory_cap", BufferMemoryCap,
+			"block_segment_size", BlockSegmentSize,
+			"otlp_endpoint", OTLPEndpoint,
+			"otlp_service_name", OTLPServiceName,
+			"summary_interval", SummaryInterval,
+			"sink_output_format", SinkOutputFormat,
+			"dry_run", DryRun,
+			"sink_batch_size", SinkBatchSize,
+			"sink_batch_flush_interval", SinkBatchFlushInterval,
+			"sink_throttle_threshold", SinkThrottleThreshold,
+			"sink_throttle_max_delay", SinkThrottleMaxDelay,
+			"sink_rate_limit", SinkRateLimit,
+			"sink_rate_burst", SinkRateBurst,
+			"sink_write_failure_policy", SinkWriteFailurePolicy,
+			"block_replay_policy", BlockReplayPolicy,
+			"self_check", SelfCheck,
+			"strict_change_reasons", StrictChangeReasons,
+			"bad_blocks_dir", BadBlocksDir,
+			"genesis_configured", GenesisConfig != nil,
 			"genesis_provenance", genesisProvenance,
-			"firehose_version", params.FirehoseVersion(),
+			"firehose_version", ProtocolVersion(),
 			"geth_version", gethVersion,
-			"chain_variant", params.Variant,
+			"chain_variant", variantName(),
 		)
 	}
 
+	var chainConfig *params.ChainConfig
+	if GenesisConfig != nil {
+		chainConfig = GenesisConfig.ChainConfig()
+	}
+
 	MaybeSyncContext().InitVersion(
 		gethVersion,
-		params.FirehoseVersion(),
-		params.Variant,
+		ProtocolVersion(),
+		variantName(),
+		chainConfig,
 	)
 
 	return nil
 }
 
-func isNilInterfaceOrNilValue(in interface{}) bool {
-	if in == nil {
-		return true
-	}
-
-	if rValue := reflect.ValueOf(in); rValue.Kind() == reflect.Ptr {
-		return rValue.IsNil()
-	}
-
-	return false
-}
+// ForksActivatedAt returns the names of the forks of `config` activated exactly at block
+// `number`, in their activation order, see `Context.RecordForkActivations`. Only the node knows
+// the forks of its chain config, it sets it while initializing, no fork activation is recorded
+// while it's nil.
+var ForksActivatedAt func(config *params.ChainConfig, number *big.Int) []string
 
-// AllocateBuffers is called manually when Firehose is bootstrapped.
+// AllocateBuffers is called manually when Firehose is bootstrapped, the buffers are allocated
+// with `BlockBufferSize` and `TxBufferSize` bytes and tuned from then on, see `tunedBuffer`.
 func AllocateBuffers() {
 	if !Enabled {
 		return
 	}
 
-	// 50 MiB
-	BlockSyncBuffer = bytes.NewBuffer(make([]byte, 0, 50*1024*1024))
+	syncBuffers.block = newTunedBuffer("block", BlockBufferSize, bufferBlockCapacityGauge)
+	syncBuffers.tx = newTunedBuffer("transaction", TxBufferSize, bufferTxCapacityGauge)
 
-	// 5 MiB
-	TxSyncBuffer = bytes.NewBuffer(make([]byte, 0, 5*1024*1024))
+	BlockSyncBuffer = syncBuffers.block.buffer
+	TxSyncBuffer = syncBuffers.tx.buffer
 }
 
 // BlockSyncBuffer to use and re-used for the state processor firehose context used to
@@ -160,8 +441,8 @@ func AllocateBuffers() {
 // BlockSyncBuffer is **not** thread-safe, it's expected to be used only by one thread at a time.
 var BlockSyncBuffer *bytes.Buffer
 
-// TxSyncBuffer holds a buffer of 5 MiB which should be enough for all transaction and it's
-// re-used for all transactions so shouldn't be a big deal for the memory
+// TxSyncBuffer holds a buffer re-used for all transactions so shouldn't be a big deal for the
+// memory, see `TxBufferSize`.
 //
 // TxSyncBuffer is **not** thread-safe, it's expected to be used only by one thread at a time.
 var TxSyncBuffer = bytes.NewBuffer(make([]byte, 0, 5*1024*1024))
@@ -0,0 +1,42 @@
+package firehose
+
+// RecordIrregularStateChange records the one-off mutation of the state applied by `changes`
+// for `reason`, like the balances moved by a hard fork or a variant specific migration. The
+// changes recorded while `changes` runs are framed by the `IRREGULAR_STATE_CHANGE_START` and
+// `IRREGULAR_STATE_CHANGE_END` records, so consumers can tell them apart from the regular
+// changes of the block.
+//
+// The `changes` are always applied, even when the context is nil. It must be called within
+// a block and out of any transaction.
+func (ctx *Context) RecordIrregularStateChange(reason IrregularStateChangeReason, changes func()) {
+	if ctx == nil {
+		changes()
+		return
+	}
+
+	if !ctx.inBlock.Load() {
+		panic("recording an irregular state change while not in a block scope")
+	}
+
+	if ctx.inTransaction.Load() {
+		panic("recording an irregular state change while in a transaction scope")
+	}
+
+	reasonName := reason.mustBeKnownWhenStrict()
+
+	beginOrdinal := ctx.totalOrderingCounter.Inc()
+	ctx.printer.Print("IRREGULAR_STATE_CHANGE_START", reasonName, Uint64(beginOrdinal))
+
+	changes()
+
+	endOrdinal := ctx.totalOrderingCounter.Inc()
+	ctx.printer.Print("IRREGULAR_STATE_CHANGE_END", reasonName, Uint64(endOrdinal))
+
+	if ctx.trace != nil && ctx.trace.block != nil {
+		ctx.trace.block.IrregularStateChanges = append(ctx.trace.block.IrregularStateChanges, &IrregularStateChange{
+			Reason:       reason,
+			BeginOrdinal: beginOrdinal,
+			EndOrdinal:   endOrdinal,
+		})
+	}
+}
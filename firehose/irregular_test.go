@@ -0,0 +1,25 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordIrregularStateChange(t *testing.T) {
+	applied := false
+	NoOpContext.RecordIrregularStateChange(DAOForkIrregularStateChangeReason, func() { applied = true })
+	assert.True(t, applied, "changes must be applied without a context")
+
+	ctx := NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	assert.PanicsWithValue(t, "recording an irregular state change while not in a block scope", func() {
+		ctx.RecordIrregularStateChange(DAOForkIrregularStateChangeReason, func() {})
+	})
+
+	ctx.StartBlock(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}))
+	ctx.RecordIrregularStateChange(DAOForkIrregularStateChangeReason, func() {})
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1 0 0 . "+Hex(types.Bloom{}.Bytes())+"\nFIRE IRREGULAR_STATE_CHANGE_START dao_fork 1\nFIRE IRREGULAR_STATE_CHANGE_END dao_fork 2\n", string(ctx.FirehoseLog()))
+}
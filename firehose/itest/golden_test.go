@@ -0,0 +1,271 @@
+package itest
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/decode"
+	"github.com/ethereum/go-ethereum/internal/firehosetest"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	goldenKey    = firehosetest.SenderKey
+	goldenSender = firehosetest.Sender
+	goldenSigner = types.LatestSigner(params.TestChainConfig)
+
+	goldenAuthorityKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	goldenAuthority       = crypto.PubkeyToAddress(goldenAuthorityKey.PublicKey)
+	goldenGenesis         = func(config *params.ChainConfig) *core.Genesis {
+		genesis := &core.Genesis{
+			Config: config,
+			Alloc: core.GenesisAlloc{
+				goldenSender:         {Balance: big.NewInt(params.Ether)},
+				storeContract:        {Code: firehosetest.StoreCode, Balance: new(big.Int)},
+				revertContract:       {Code: firehosetest.RevertCode, Balance: new(big.Int)},
+				selfDestructContract: {Code: firehosetest.SelfDestructCode, Balance: big.NewInt(1000)},
+				callRevertContract:   {Code: firehosetest.CallCode("f1", revertContract, "600055"), Balance: new(big.Int)},
+				delegateContract1:    {Code: firehosetest.CallCode("f4", delegateContract2, ""), Balance: new(big.Int)},
+				delegateContract2:    {Code: firehosetest.CallCode("f4", delegateContract3, ""), Balance: new(big.Int)},
+				delegateContract3:    {Code: firehosetest.CallCode("f4", storeContract, ""), Balance: new(big.Int)},
+				push0Contract:        {Code: common.FromHex("602a5f55" + "00"), Balance: new(big.Int)},
+				coinbaseContract:     {Code: common.FromHex("413150" + "00"), Balance: new(big.Int)},
+				collisionContract:    {Code: common.FromHex("6000600060006000f550" + "6000600060006000f550" + "00"), Balance: new(big.Int)},
+			},
+		}
+		if config.IsPrague(common.Big0) {
+			// The EIP-2935 system call stores the parent hash at the start of each block
+			genesis.Alloc[params.HistoryStorageAddress] = core.GenesisAccount{Code: params.HistoryStorageCode, Balance: new(big.Int)}
+		}
+
+		return genesis
+	}
+)
+
+var (
+	storeContract        = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	revertContract       = common.HexToAddress("0x1000000000000000000000000000000000000002")
+	selfDestructContract = common.HexToAddress("0x1000000000000000000000000000000000000003")
+	callRevertContract   = common.HexToAddress("0x1000000000000000000000000000000000000004")
+	delegateContract1    = common.HexToAddress("0x1000000000000000000000000000000000000005")
+	delegateContract2    = common.HexToAddress("0x1000000000000000000000000000000000000006")
+	delegateContract3    = common.HexToAddress("0x1000000000000000000000000000000000000007")
+	push0Contract        = common.HexToAddress("0x1000000000000000000000000000000000000008")
+	coinbaseContract     = common.HexToAddress("0x1000000000000000000000000000000000000009")
+	collisionContract    = common.HexToAddress("0x100000000000000000000000000000000000000a")
+	sha256Precompile     = common.BytesToAddress([]byte{0x02})
+)
+
+// shanghaiChainConfig is the chain config of the golden scenarios exercising the Shanghai
+// changes (PUSH0 and warm COINBASE)
+var shanghaiChainConfig = func() *params.ChainConfig {
+	config := *params.TestChainConfig
+	config.ShanghaiBlock = big.NewInt(0)
+	return &config
+}()
+
+// pragueChainConfig is the chain config of the golden scenarios exercising the Prague
+// changes (EIP-7702 set code transactions and the EIP-2935 history storage system call)
+var pragueChainConfig = func() *params.ChainConfig {
+	config := *params.TestChainConfig
+	config.ShanghaiBlock = big.NewInt(0)
+	config.CancunBlock = big.NewInt(0)
+	config.PragueBlock = big.NewInt(0)
+	return &config
+}()
+
+type goldenScenario struct {
+	name     string
+	config   *params.ChainConfig
+	generate func(i int, b *core.BlockGen)
+}
+
+var goldenScenarios = []goldenScenario{
+	{"transfer", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &common.Address{0xaa}, big.NewInt(1000), 21000, nil))
+	}},
+	{"contract_creation", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, nil, big.NewInt(0), 100000, firehosetest.StoreCreationCode))
+	}},
+	{"storage_change", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &storeContract, big.NewInt(0), 100000, nil))
+	}},
+	{"revert", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &revertContract, big.NewInt(0), 100000, nil))
+	}},
+	{"nested_revert", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &callRevertContract, big.NewInt(0), 100000, nil))
+	}},
+	{"self_destruct", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &selfDestructContract, big.NewInt(0), 100000, nil))
+	}},
+	{"precompile", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &sha256Precompile, big.NewInt(0), 100000, []byte("firehose")))
+	}},
+	{"deep_delegate_call", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &delegateContract1, big.NewInt(0), 200000, nil))
+	}},
+	{"contract_address_collision", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		// The contract runs CREATE2 twice with the same salt and init code, the second
+		// creation collides with the account created by the first one
+		b.AddTx(goldenTx(b, &collisionContract, big.NewInt(0), 200000, nil))
+	}},
+	{"multiple_transactions", params.TestChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &common.Address{0xaa}, big.NewInt(1000), 21000, nil))
+		b.AddTx(goldenTx(b, &storeContract, big.NewInt(0), 100000, nil))
+		b.AddTx(goldenTx(b, &revertContract, big.NewInt(0), 100000, nil))
+	}},
+	{"push0", shanghaiChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &push0Contract, big.NewInt(0), 100000, nil))
+	}},
+	{"warm_coinbase", shanghaiChainConfig, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &coinbaseContract, big.NewInt(0), 100000, nil))
+	}},
+	{"history_storage", pragueChainConfig, func(i int, b *core.BlockGen) {
+		// Reads the hash of the genesis block, stored by the system call of the block
+		b.AddTx(goldenTx(b, &params.HistoryStorageAddress, big.NewInt(0), 100000, common.LeftPadBytes(nil, 32)))
+	}},
+	{"set_code", pragueChainConfig, func(i int, b *core.BlockGen) {
+		// The second authorization is skipped, its nonce is stale once the first one is applied
+		b.AddTx(goldenSetCodeTx(b, goldenAuthority, 100000,
+			goldenAuthorization(storeContract, 0),
+			goldenAuthorization(revertContract, 0),
+		))
+	}},
+}
+
+func goldenTx(b *core.BlockGen, to *common.Address, value *big.Int, gasLimit uint64, data []byte) *types.Transaction {
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(b.TxNonce(goldenSender), value, gasLimit, big.NewInt(1), data)
+	} else {
+		tx = types.NewTransaction(b.TxNonce(goldenSender), *to, value, gasLimit, big.NewInt(1), data)
+	}
+
+	signed, err := types.SignTx(tx, goldenSigner, goldenKey)
+	if err != nil {
+		panic(err)
+	}
+
+	return signed
+}
+
+func goldenSetCodeTx(b *core.BlockGen, to common.Address, gasLimit uint64, authList ...types.SetCodeAuthorization) *types.Transaction {
+	signed, err := types.SignNewTx(goldenKey, types.LatestSigner(pragueChainConfig), &types.SetCodeTx{
+		ChainID:   pragueChainConfig.ChainID,
+		Nonce:     b.TxNonce(goldenSender),
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(1),
+		Gas:       gasLimit,
+		To:        to,
+		Value:     new(big.Int),
+		AuthList:  authList,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return signed
+}
+
+// goldenAuthorization is an authorization of `goldenAuthority` delegating its code to `address`
+func goldenAuthorization(address common.Address, nonce uint64) types.SetCodeAuthorization {
+	auth, err := types.SignSetCode(goldenAuthorityKey, types.SetCodeAuthorization{
+		ChainID: pragueChainConfig.ChainID,
+		Address: address,
+		Nonce:   nonce,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return auth
+}
+
+func TestGolden(t *testing.T) {
+	for _, scenario := range goldenScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			actual := executeGoldenScenario(t, scenario, false).FirehoseLog()
+			firehosetest.CompareGolden(t, filepath.Join("..", "testdata", "golden", scenario.name+".golden"), actual)
+		})
+	}
+}
+
+func TestGolden_BlockTrace(t *testing.T) {
+	for _, scenario := range goldenScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			firehoseContext := executeGoldenScenario(t, scenario, true)
+			require.NotNil(t, firehoseContext.BlockTrace())
+
+			element, err := decode.NewDecoder(bytes.NewReader(firehoseContext.FirehoseLog())).Next()
+			require.NoError(t, err)
+
+			// The in-memory trace must be exactly what a consumer decodes out of the records
+			expected, err := json.Marshal(element)
+			require.NoError(t, err)
+			actual, err := json.Marshal(firehoseContext.BlockTrace())
+			require.NoError(t, err)
+
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+// executeGoldenScenario generates a single block chain out of the scenario, imports it in an
+// in-memory chain, then re-processes the block with Firehose enabled returning the context
+// that recorded it, with its block trace when `blockTrace` is true.
+func executeGoldenScenario(t *testing.T, scenario goldenScenario, blockTrace bool) *firehose.Context {
+	t.Helper()
+
+	db := rawdb.NewMemoryDatabase()
+	genesis := goldenGenesis(scenario.config)
+	genesisBlock := genesis.MustCommit(db)
+
+	blocks, _ := core.GenerateChain(genesis.Config, genesisBlock, ethash.NewFaker(), db, 1, scenario.generate)
+
+	chain, err := core.NewBlockChain(db, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	require.NoError(t, err)
+	defer chain.Stop()
+
+	_, err = chain.InsertChain(blocks)
+	require.NoError(t, err)
+
+	return captureFirehoseBlock(t, chain, blocks[0], blockTrace)
+}
+
+func captureFirehoseBlock(t *testing.T, chain *core.BlockChain, block *types.Block, blockTrace bool) *firehose.Context {
+	t.Helper()
+
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	statedb, err := state.New(parent.Root(), chain.StateCache(), nil)
+	require.NoError(t, err)
+
+	firehoseContext := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	if blockTrace {
+		firehoseContext.EnableBlockTrace()
+	}
+
+	_, _, _, err = chain.Processor().Process(block, statedb, vm.Config{}, firehoseContext)
+	require.NoError(t, err)
+
+	firehoseContext.EndBlock(block, chain.GetTd(block.Hash(), block.NumberU64()))
+
+	return firehoseContext
+}
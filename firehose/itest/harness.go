@@ -0,0 +1,249 @@
+// Package itest is an end-to-end harness for the Firehose instrumentation, it boots an
+// in-process developer chain node, sends transactions to it and imports the blocks it mines
+// in an instrumented chain, capturing the Firehose stream produced, decoded back into typed
+// elements.
+package itest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"io"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/decode"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// receiptTimeout is how long a sent transaction is waited for before failing the test.
+const receiptTimeout = 10 * time.Second
+
+// Node is an in-process developer chain node, mining a block for each batch of pending
+// transactions. The blocks it mines are imported, as a syncing node would, by a follower
+// chain with Firehose enabled whose stream is captured in memory.
+//
+// Since the Firehose configuration is global to the process, a single node can run at a
+// time and the test binary should not run other Firehose tests concurrently.
+type Node struct {
+	stack    *node.Node
+	backend  *eth.Ethereum
+	follower *core.BlockChain
+
+	key     *ecdsa.PrivateKey
+	Address common.Address
+	signer  types.Signer
+	nonce   uint64
+
+	sink *sink
+}
+
+// NewNode boots a developer chain node and its Firehose enabled follower chain, the
+// developer account `Address`, prefunded, signs the blocks and the transactions sent
+// through the node. Both are stopped once the test completes.
+func NewNode(t testing.TB) *Node {
+	t.Helper()
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	n := &Node{key: key, Address: crypto.PubkeyToAddress(key.PublicKey), sink: &sink{}}
+
+	genesis := core.DeveloperGenesisBlock(0, n.Address)
+	n.signer = types.LatestSigner(genesis.Config)
+
+	// Only the follower chain is instrumented, Firehose is initialized once the node is created
+	firehose.Enabled = false
+
+	stack, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("can't create node: %v", err)
+	}
+	t.Cleanup(func() { stack.Close() })
+
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	account, err := ks.ImportECDSA(key, "")
+	if err != nil {
+		t.Fatalf("can't import developer account: %v", err)
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		t.Fatalf("can't unlock developer account: %v", err)
+	}
+
+	config := ethconfig.Defaults
+	config.Genesis = genesis
+	config.NetworkId = genesis.Config.ChainID.Uint64()
+	config.Miner.Etherbase = n.Address
+	config.Miner.GasPrice = big.NewInt(1)
+	config.Miner.DeterministicTime = true
+
+	n.backend, err = eth.New(stack, &config)
+	if err != nil {
+		t.Fatalf("can't create ethereum service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("can't start node: %v", err)
+	}
+	n.stack = stack
+
+	// The node's own chain writes the blocks it mines without emitting them, mining must
+	// still be enabled for the miner to keep working once Firehose is.
+	firehose.SinkWriter = n.sink
+	if err := firehose.Init(true, true, true, false, core.NewFirehoseGenesisView(genesis), "", nil, params.VersionWithMeta); err != nil {
+		t.Fatalf("can't initialize firehose: %v", err)
+	}
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db)
+	n.follower, err = core.NewBlockChain(db, nil, genesis.Config, clique.New(genesis.Config.Clique, db), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("can't create follower chain: %v", err)
+	}
+	t.Cleanup(func() {
+		n.follower.Stop()
+
+		// The emitted blocks are tracked globally, they are undone so that another node,
+		// starting over from its genesis, can be booted afterwards
+		syncContext := firehose.SyncContext()
+		for last := syncContext.LastEmittedBlock(); last != nil; last = syncContext.LastEmittedBlock() {
+			header := n.follower.GetHeader(last.Hash, last.Number)
+			if header == nil {
+				t.Fatalf("can't undo emitted block #%d (%s): header not found", last.Number, last.Hash)
+			}
+			if err := syncContext.RecordUndoBlock(header, nil); err != nil {
+				t.Fatalf("can't undo emitted block #%d (%s): %v", last.Number, last.Hash, err)
+			}
+		}
+	})
+
+	if err := n.backend.StartMining(1); err != nil {
+		t.Fatalf("can't start mining: %v", err)
+	}
+
+	return n
+}
+
+// Deploy sends a contract creation transaction of `code`, the creation code, and waits
+// for it to be mined, it returns the receipt holding the created contract address.
+func (n *Node) Deploy(t testing.TB, code []byte, value *big.Int) *types.Receipt {
+	t.Helper()
+
+	return n.send(t, nil, value, code)
+}
+
+// Send sends a transaction to `to` and waits for it to be mined, it returns its receipt.
+func (n *Node) Send(t testing.TB, to common.Address, value *big.Int, input []byte) *types.Receipt {
+	t.Helper()
+
+	return n.send(t, &to, value, input)
+}
+
+func (n *Node) send(t testing.TB, to *common.Address, value *big.Int, input []byte) *types.Receipt {
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(n.nonce, value, 1000000, big.NewInt(params.GWei), input)
+	} else {
+		tx = types.NewTransaction(n.nonce, *to, value, 1000000, big.NewInt(params.GWei), input)
+	}
+	tx, err := types.SignTx(tx, n.signer, n.key)
+	if err != nil {
+		t.Fatalf("can't sign transaction: %v", err)
+	}
+	if err := n.backend.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("can't send transaction: %v", err)
+	}
+	n.nonce++
+
+	deadline := time.Now().Add(receiptTimeout)
+	for time.Now().Before(deadline) {
+		receipt, _, _, _ := rawdb.ReadReceipt(n.backend.ChainDb(), tx.Hash(), n.backend.BlockChain().Config())
+		if receipt != nil {
+			n.sync(t)
+			return receipt
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("transaction %s not mined after %s", tx.Hash().Hex(), receiptTimeout)
+	return nil
+}
+
+// sync imports in the follower chain the blocks mined by the node since the last call.
+func (n *Node) sync(t testing.TB) {
+	miner := n.backend.BlockChain()
+
+	var blocks types.Blocks
+	for number := n.follower.CurrentBlock().NumberU64() + 1; number <= miner.CurrentBlock().NumberU64(); number++ {
+		blocks = append(blocks, miner.GetBlockByNumber(number))
+	}
+	if _, err := n.follower.InsertChain(blocks); err != nil {
+		t.Fatalf("can't import mined blocks in follower chain: %v", err)
+	}
+}
+
+// BlockChain returns the follower chain, the chain whose Firehose stream is captured.
+func (n *Node) BlockChain() *core.BlockChain {
+	return n.follower
+}
+
+// Stream returns the elements of the Firehose stream captured so far, decoded.
+func (n *Node) Stream(t testing.TB) []interface{} {
+	t.Helper()
+
+	firehose.FlushSink()
+
+	var elements []interface{}
+	decoder := decode.NewDecoder(bytes.NewReader(n.sink.bytes()))
+	for {
+		element, err := decoder.Next()
+		if err != nil {
+			if err == io.EOF {
+				return elements
+			}
+			t.Fatalf("can't decode firehose stream: %v", err)
+		}
+		elements = append(elements, element)
+	}
+}
+
+// sink is a thread-safe in-memory Firehose sink.
+type sink struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (s *sink) Write(in []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.buffer.Write(in)
+}
+
+func (s *sink) contains(content string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return bytes.Contains(s.buffer.Bytes(), []byte(content))
+}
+
+func (s *sink) bytes() []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]byte(nil), s.buffer.Bytes()...)
+}
@@ -0,0 +1,149 @@
+package itest
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/decode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creationCode returns the creation code deploying `runtime` as the contract code.
+func creationCode(runtime string) []byte {
+	code := common.FromHex(runtime)
+	// PUSH1 <len> PUSH1 0x0c PUSH1 0x00 CODECOPY PUSH1 <len> PUSH1 0x00 RETURN
+	return append([]byte{0x60, byte(len(code)), 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, byte(len(code)), 0x60, 0x00, 0xf3}, code...)
+}
+
+func TestDevChain(t *testing.T) {
+	n := NewNode(t)
+
+	// PUSH1 0x01 PUSH1 0x00 SSTORE STOP
+	store := n.Deploy(t, creationCode("600160005500"), nil)
+	require.Equal(t, types.ReceiptStatusSuccessful, store.Status)
+	// PUSH1 0x00 PUSH1 0x00 REVERT
+	revert := n.Deploy(t, creationCode("60006000fd"), nil)
+	require.Equal(t, types.ReceiptStatusSuccessful, revert.Status)
+	// CALLER SELFDESTRUCT
+	selfDestruct := n.Deploy(t, creationCode("33ff"), big.NewInt(1000))
+	require.Equal(t, types.ReceiptStatusSuccessful, selfDestruct.Status)
+
+	receipts := []*types.Receipt{
+		store, revert, selfDestruct,
+		n.Send(t, store.ContractAddress, nil, nil),
+		n.Send(t, revert.ContractAddress, nil, nil),
+		n.Send(t, selfDestruct.ContractAddress, nil, nil),
+		n.Send(t, common.BytesToAddress([]byte{0x02}), nil, []byte("firehose")),
+		n.Send(t, common.Address{0xaa}, big.NewInt(params.Ether), nil),
+	}
+	assert.Equal(t, types.ReceiptStatusFailed, receipts[4].Status)
+
+	stream := n.Stream(t)
+	require.NotEmpty(t, stream)
+
+	init, ok := stream[0].(*decode.Init)
+	require.True(t, ok, "stream must start with the init record, got %T", stream[0])
+	assert.Equal(t, firehose.ProtocolVersion(), init.ProtocolVersion)
+
+	// Every canonical block is emitted once, in order from the genesis, with its transactions
+	traces := map[common.Hash]*decode.TransactionTrace{}
+	var blocks []*decode.Block
+	for _, element := range stream[1:] {
+		if block, ok := element.(*decode.Block); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	head := n.BlockChain().CurrentBlock().NumberU64()
+	require.Len(t, blocks, int(head)+1)
+
+	for i, block := range blocks {
+		canonical := n.BlockChain().GetBlockByNumber(uint64(i))
+		require.Equal(t, canonical.Hash(), block.Header.Hash(), "block #%d", i)
+		if i == 0 {
+			// The genesis allocation is recorded as a synthetic transaction
+			continue
+		}
+
+		require.Len(t, block.Transactions, len(canonical.Transactions()), "block #%d", i)
+
+		lastOrdinal := uint64(0)
+		for j, trx := range block.Transactions {
+			assert.Equal(t, canonical.Transactions()[j].Hash(), trx.Hash, "block #%d transaction #%d", i, j)
+			assert.Greater(t, trx.BeginOrdinal, lastOrdinal, "block #%d transaction #%d ordinals must increase", i, j)
+			assert.Greater(t, trx.EndOrdinal, trx.BeginOrdinal, "block #%d transaction #%d", i, j)
+			lastOrdinal = trx.EndOrdinal
+
+			traces[trx.Hash] = trx
+		}
+	}
+
+	// The transactions are traced according to their receipt
+	for _, receipt := range receipts {
+		trx := traces[receipt.TxHash]
+		require.NotNil(t, trx, "transaction %s not emitted", receipt.TxHash.Hex())
+		assert.Equal(t, receipt.GasUsed, trx.GasUsed, "transaction %s", receipt.TxHash.Hex())
+		require.NotEmpty(t, trx.Calls, "transaction %s", receipt.TxHash.Hex())
+		assert.Equal(t, receipt.Status == types.ReceiptStatusFailed, trx.Calls[0].Failed, "transaction %s", receipt.TxHash.Hex())
+	}
+
+	storeDeploy := traces[store.TxHash].Calls[0]
+	assert.Equal(t, firehose.CallTypeCreate, storeDeploy.CallType)
+	require.NotEmpty(t, storeDeploy.CodeChanges)
+	assert.Equal(t, common.FromHex("600160005500"), []byte(storeDeploy.CodeChanges[0].NewCode))
+
+	storeCall := traces[receipts[3].TxHash].Calls[0]
+	require.Len(t, storeCall.StorageChanges, 1)
+	assert.Equal(t, common.Hash{31: 0x01}, storeCall.StorageChanges[0].New)
+	assert.True(t, storeCall.HasCode)
+	assert.Equal(t, crypto.Keccak256Hash(common.FromHex("600160005500")), *storeCall.CodeHash)
+
+	assert.True(t, traces[receipts[4].TxHash].Calls[0].Reverted)
+
+	destructCall := traces[receipts[5].TxHash].Calls[0]
+	require.Len(t, destructCall.SuicideChanges, 1)
+	assert.Equal(t, selfDestruct.ContractAddress, destructCall.SuicideChanges[0].Address)
+
+	precompileCall := traces[receipts[6].TxHash].Calls[0]
+	assert.True(t, precompileCall.Precompile)
+	assert.False(t, precompileCall.HasCode)
+}
+
+func TestStreamSubscription(t *testing.T) {
+	n := NewNode(t)
+
+	client, err := n.stack.Attach()
+	require.NoError(t, err)
+	defer client.Close()
+
+	chunks := make(chan string, 64)
+	sub, err := client.Subscribe(context.Background(), "firehosestream", chunks, "stream")
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	n.Send(t, common.Address{0xbb}, big.NewInt(1), nil)
+	firehose.FlushSink()
+
+	// The live stream receives the bytes written to the sink from the subscription on
+	var received strings.Builder
+	for !strings.Contains(received.String(), "FIRE BLOCK_END ") {
+		select {
+		case chunk := <-chunks:
+			received.WriteString(chunk)
+		case err := <-sub.Err():
+			t.Fatalf("stream subscription failed: %v", err)
+		case <-time.After(receiptTimeout):
+			t.Fatalf("no block received from the stream, got %q", received.String())
+		}
+	}
+	assert.True(t, n.sink.contains(received.String()), "stream received is not part of the sink output")
+	assert.Contains(t, received.String(), "FIRE BLOCK_BEGIN 1 ")
+}
@@ -0,0 +1,63 @@
+package itest
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/decode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordNonExecutedBlock(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := goldenGenesis(params.TestChainConfig)
+	genesisBlock := genesis.MustCommit(db)
+
+	blocks, receipts := core.GenerateChain(genesis.Config, genesisBlock, ethash.NewFaker(), db, 1, func(i int, b *core.BlockGen) {
+		b.AddTx(goldenTx(b, &common.Address{0xaa}, big.NewInt(1000), 21000, nil))
+		b.AddTx(goldenTx(b, &storeContract, big.NewInt(0), 100000, nil))
+	})
+	block := blocks[0]
+
+	// Receipts received from the network hold only their consensus fields
+	encoded, err := rlp.EncodeToBytes(receipts[0])
+	require.NoError(t, err)
+	var networkReceipts types.Receipts
+	require.NoError(t, rlp.DecodeBytes(encoded, &networkReceipts))
+
+	ctx := firehose.NewBlockContextWithBuffer(bytes.NewBuffer(nil))
+	ctx.EnableBlockTrace()
+	require.NoError(t, ctx.RecordNonExecutedBlock(block, networkReceipts, genesis.Config, big.NewInt(3)))
+
+	element, err := decode.NewDecoder(bytes.NewReader(ctx.FirehoseLog())).Next()
+	require.NoError(t, err)
+	decoded := element.(*decode.Block)
+
+	assert.True(t, decoded.NonExecuted)
+	assert.Equal(t, block.Hash(), decoded.Header.Hash())
+	require.Len(t, decoded.Transactions, 2)
+	for i, trx := range decoded.Transactions {
+		assert.Equal(t, block.Transactions()[i].Hash(), trx.Hash)
+		assert.Equal(t, goldenSender, trx.From)
+		assert.Equal(t, receipts[0][i].GasUsed, trx.GasUsed)
+		assert.Empty(t, trx.Calls)
+		assert.Empty(t, trx.BalanceChanges)
+	}
+
+	expected, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	actual, err := json.Marshal(ctx.BlockTrace())
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
@@ -0,0 +1,55 @@
+package itest
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func Example_speculativeExecution() {
+	// Usually enabled through `firehose.Init`
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	// The state the transaction is executed on top of, here the genesis state
+	db := rawdb.NewMemoryDatabase()
+	genesis := goldenGenesis(params.TestChainConfig).MustCommit(db)
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), GasLimit: genesis.GasLimit(), Difficulty: big.NewInt(1)}
+
+	tx, _ := types.SignTx(types.NewTransaction(0, storeContract, new(big.Int), 100_000, big.NewInt(1), nil), goldenSigner, goldenKey)
+	statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+	execution := firehose.NewSpeculativeExecution()
+	defer execution.Release()
+
+	execution.StartTransaction(tx, goldenSender)
+	receipt, err := core.ApplyTransaction(params.TestChainConfig, nil, &header.Coinbase, new(core.GasPool).AddGas(header.GasLimit), statedb, header, tx, new(uint64), vm.Config{}, execution.Context())
+	if err != nil {
+		panic(err)
+	}
+	execution.EndTransaction(receipt)
+
+	trace := execution.Trace()
+	fmt.Println("gas used:", trace.GasUsed)
+	fmt.Println("storage changes:", len(trace.Calls[0].StorageChanges))
+
+	records := strings.Split(strings.TrimSpace(string(execution.Records())), "\n")
+	fmt.Println("first record:", strings.Fields(records[0])[1])
+	fmt.Println("last record:", strings.Fields(records[len(records)-1])[1])
+
+	// Output:
+	// gas used: 43106
+	// storage changes: 1
+	// first record: BEGIN_APPLY_TRX
+	// last record: END_APPLY_TRX
+}
@@ -0,0 +1,146 @@
+package itest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// otlpJSONIdentifiers are the fields of the OTLP/JSON encoding holding trace and span
+// identifiers, hex encoded while the protobuf JSON mapping encodes bytes in base64.
+var otlpJSONIdentifiers = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// decodeOTLPTraces decodes an OTLP/JSON traces export request with the OTLP protobuf
+// definitions, any field unknown to the schema fails the decoding.
+func decodeOTLPTraces(t *testing.T, payload []byte) *coltracepb.ExportTraceServiceRequest {
+	t.Helper()
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var generic interface{}
+	require.NoError(t, decoder.Decode(&generic))
+	require.NoError(t, hexToBase64Identifiers(generic))
+
+	normalized, err := json.Marshal(generic)
+	require.NoError(t, err)
+
+	request := &coltracepb.ExportTraceServiceRequest{}
+	require.NoError(t, protojson.Unmarshal(normalized, request))
+	return request
+}
+
+// hexToBase64Identifiers re-encodes in base64 the hex encoded identifiers of `value`, the only
+// deviation of the OTLP/JSON encoding from the protobuf JSON mapping.
+func hexToBase64Identifiers(value interface{}) error {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if id, ok := field.(string); ok && otlpJSONIdentifiers[key] {
+				raw, err := hex.DecodeString(id)
+				if err != nil {
+					return err
+				}
+				value[key] = base64.StdEncoding.EncodeToString(raw)
+				continue
+			}
+			if err := hexToBase64Identifiers(field); err != nil {
+				return err
+			}
+		}
+
+	case []interface{}:
+		for _, element := range value {
+			if err := hexToBase64Identifiers(element); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestTracing_OTLPSchema(t *testing.T) {
+	var lock sync.Mutex
+	var requests []*coltracepb.ExportTraceServiceRequest
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		payload, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		lock.Lock()
+		defer lock.Unlock()
+		requests = append(requests, decodeOTLPTraces(t, payload))
+	}))
+	defer collector.Close()
+
+	firehose.StartTracing(collector.URL, "itest")
+
+	root := firehose.StartSpan("itest.Root", nil)
+	root.SetInt("block.number", 7)
+	root.SetString("block.hash", "0xab")
+	child := firehose.StartSpan("itest.Child", root)
+	child.End()
+	root.SetError(errors.New("failed"))
+	root.End()
+
+	firehose.StopTracing()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	spans := map[string]*tracepb.Span{}
+	for _, request := range requests {
+		for _, resource := range request.ResourceSpans {
+			require.Len(t, resource.Resource.Attributes, 1)
+			assert.Equal(t, "service.name", resource.Resource.Attributes[0].Key)
+			assert.Equal(t, "itest", resource.Resource.Attributes[0].Value.GetStringValue())
+
+			for _, scope := range resource.ScopeSpans {
+				assert.Equal(t, "github.com/ethereum/go-ethereum/firehose", scope.Scope.Name)
+				for _, span := range scope.Spans {
+					spans[span.Name] = span
+				}
+			}
+		}
+	}
+	require.Contains(t, spans, "itest.Root")
+	require.Contains(t, spans, "itest.Child")
+
+	rootSpan, childSpan := spans["itest.Root"], spans["itest.Child"]
+	assert.Len(t, rootSpan.TraceId, 16)
+	assert.Len(t, rootSpan.SpanId, 8)
+	assert.Empty(t, rootSpan.ParentSpanId)
+	assert.Equal(t, tracepb.Span_SPAN_KIND_INTERNAL, rootSpan.Kind)
+	assert.NotZero(t, rootSpan.StartTimeUnixNano)
+	assert.GreaterOrEqual(t, rootSpan.EndTimeUnixNano, rootSpan.StartTimeUnixNano)
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, rootSpan.Status.Code)
+	assert.Equal(t, "failed", rootSpan.Status.Message)
+
+	require.Len(t, rootSpan.Attributes, 2)
+	assert.Equal(t, "block.number", rootSpan.Attributes[0].Key)
+	assert.Equal(t, int64(7), rootSpan.Attributes[0].Value.GetIntValue())
+	assert.Equal(t, "block.hash", rootSpan.Attributes[1].Key)
+	assert.Equal(t, "0xab", rootSpan.Attributes[1].Value.GetStringValue())
+
+	assert.Equal(t, rootSpan.TraceId, childSpan.TraceId)
+	assert.Equal(t, rootSpan.SpanId, childSpan.ParentSpanId)
+	assert.Equal(t, tracepb.Status_STATUS_CODE_UNSET, childSpan.Status.GetCode())
+}
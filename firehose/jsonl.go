@@ -0,0 +1,338 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OutputFormat determines how the Firehose records are written to standard output.
+type OutputFormat string
+
+const (
+	// OutputFormatText writes each record as a `FIRE <RECORD> <field> <field>...` line, the
+	// fields being positional and space separated. It's the format read by the Firehose readers.
+	OutputFormatText OutputFormat = "text"
+
+	// OutputFormatJSONLines writes each record as a single line JSON object, the record name
+	// being under the `record` key and each field under its name with a typed value, see
+	// `JSONLinesPrinter`.
+	OutputFormatJSONLines OutputFormat = "jsonl"
+)
+
+// ParseOutputFormat parses the received string into an OutputFormat, returns an error if
+// the format is unknown.
+func ParseOutputFormat(in string) (OutputFormat, error) {
+	switch format := OutputFormat(in); format {
+	case OutputFormatText, OutputFormatJSONLines:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unknown firehose output format %q, valid values are %q and %q", in, OutputFormatText, OutputFormatJSONLines)
+}
+
+type fieldKind int
+
+const (
+	stringField fieldKind = iota
+	uintField
+	boolField
+	// hexField is hex encoded bytes, `.` being empty bytes
+	hexField
+	// optionalHexField is hex encoded bytes, `.` being none
+	optionalHexField
+	// signedHexField is a hex encoded magnitude prefixed by its `+` or `-` sign, `.` being 0
+	signedHexField
+	// optionalStringField is a string, `.` being none
+	optionalStringField
+	// hexListField is a comma separated list of hex encoded bytes
+	hexListField
+	jsonField
+)
+
+type recordField struct {
+	name string
+	kind fieldKind
+}
+
+var (
+	callIndexField = recordField{"callIndex", uintField}
+	ordinalField   = recordField{"ordinal", uintField}
+	numberField    = recordField{"number", uintField}
+	hashField      = recordField{"hash", hexField}
+	addressField   = recordField{"address", hexField}
+)
+
+// recordFields are the fields, in their order, of each known record, the last field of a
+// record can contain spaces.
+var recordFields = map[string][]recordField{
+	"INIT":                         {{"version", stringField}, {"variant", stringField}, {"nodeVersion", stringField}, {"chainConfig", jsonField}},
+	"BLOCK_BEGIN":                  {numberField, hashField, {"timestamp", uintField}, {"elapsed", uintField}},
+	"BLOCK_END":                    {numberField, hashField, {"lineCount", uintField}, {"timestamp", uintField}, {"elapsed", uintField}},
+	"BLOCK_SEGMENT":                {numberField, hashField, {"sequence", uintField}, {"lineCount", uintField}},
+	"BLOCK_ABORT":                  {numberField, hashField},
+	"BEGIN_BLOCK":                  {numberField, {"gasLimit", uintField}, {"gasTarget", uintField}, {"baseFee", optionalHexField}, {"logsBloom", hexField}},
+	"NON_EXECUTED_BLOCK":           {numberField},
+	"BACKFILLED_BLOCK":             {numberField},
+	"REPLAYED_BLOCK":               {numberField},
+	"PROPOSED_BLOCK":               {numberField, {"taskId", uintField}},
+	"FORK_ACTIVATION":              {{"name", stringField}, numberField, {"timestamp", uintField}},
+	"BLOCK_WITNESS":                {{"witness", jsonField}},
+	"CREATED_CONTRACTS":            {{"contracts", jsonField}},
+	"CLIQUE_SEAL":                  {{"seal", jsonField}},
+	"SYSTEM_CALL_START":            {},
+	"SYSTEM_CALL_END":              {},
+	"SYSTEM_TRX":                   {},
+	"IRREGULAR_STATE_CHANGE_START": {{"reason", stringField}, ordinalField},
+	"IRREGULAR_STATE_CHANGE_END":   {{"reason", stringField}, ordinalField},
+	"BEGIN_APPLY_TRX": {
+		hashField, {"to", optionalHexField}, {"value", hexField}, {"v", hexField}, {"r", hexField}, {"s", hexField},
+		{"gasLimit", uintField}, {"gasPrice", hexField}, {"nonce", uintField}, {"input", hexField}, {"accessList", hexField},
+		{"maxFeePerGas", optionalHexField}, {"maxPriorityFeePerGas", optionalHexField}, {"type", uintField}, ordinalField, {"index", uintField},
+	},
+	"TRX_FROM":                 {{"from", hexField}},
+	"TRX_SIGNATURE":            {{"scheme", stringField}, {"yParity", uintField}},
+	"SET_CODE_AUTHORIZATION":   {{"chainId", hexField}, addressField, {"nonce", uintField}, {"authority", optionalHexField}, {"applied", boolField}, ordinalField},
+	"EVM_RUN_CALL":             {{"callType", stringField}, callIndexField, ordinalField, {"opCode", optionalStringField}, {"precompile", boolField}, {"codeHash", optionalHexField}, {"hasCode", boolField}, {"parentIndex", uintField}, {"parentOrdinal", uintField}, {"depth", uintField}},
+	"EVM_PARAM":                {{"callType", stringField}, callIndexField, {"caller", hexField}, addressField, {"value", hexField}, {"gasLimit", uintField}, {"input", hexField}, {"delegateCaller", optionalHexField}, {"depth", uintField}, {"readOnly", boolField}},
+	"ACCOUNT_WITHOUT_CODE":     {callIndexField},
+	"EVM_PRECOMPILE":           {callIndexField, {"name", optionalStringField}},
+	"EVM_CALL_FAILED":          {callIndexField, {"gasLeft", uintField}, {"code", stringField}, {"reason", stringField}},
+	"EVM_CREATE_FAILED":        {callIndexField, {"code", stringField}, {"gasDisposition", stringField}, {"gas", uintField}},
+	"EVM_REVERTED":             {callIndexField},
+	"EVM_END_CALL":             {callIndexField, {"gasLeft", uintField}, {"returnData", hexField}, ordinalField, {"gasLimit", uintField}, {"gasUsed", uintField}, {"gasRetained", uintField}},
+	"EVM_KECCAK":               {callIndexField, hashField, {"data", hexField}},
+	"GAS_CHANGE":               {callIndexField, {"old", uintField}, {"new", uintField}, {"reason", stringField}, ordinalField},
+	"STORAGE_CHANGE":           {callIndexField, addressField, {"key", hexField}, {"old", hexField}, {"new", hexField}, ordinalField},
+	"TRANSIENT_STORAGE_CHANGE": {callIndexField, addressField, {"key", hexField}, {"old", hexField}, {"new", hexField}, ordinalField},
+	"BALANCE_CHANGE":           {callIndexField, addressField, {"old", hexField}, {"new", hexField}, {"reason", stringField}, ordinalField},
+	"BALANCE_DELTA":            {callIndexField, addressField, {"delta", signedHexField}, {"reason", stringField}, ordinalField},
+	"ADD_LOG":                  {callIndexField, {"indexInBlock", uintField}, addressField, {"topics", hexListField}, {"data", hexField}, ordinalField},
+	"SUICIDE_CHANGE":           {callIndexField, addressField, {"suicided", boolField}, {"balanceBefore", hexField}},
+	"CREATED_ACCOUNT":          {callIndexField, addressField, ordinalField},
+	"ACCOUNT_TOUCH":            {callIndexField, addressField, ordinalField},
+	"DELETED_ACCOUNT":          {callIndexField, addressField, {"reason", stringField}, ordinalField},
+	"CODE_CHANGE":              {callIndexField, addressField, {"oldCodeHash", hexField}, {"oldCode", hexField}, {"newCodeHash", hexField}, {"newCode", hexField}, ordinalField},
+	"NONCE_CHANGE":             {callIndexField, addressField, {"old", uintField}, {"new", uintField}, ordinalField},
+	"END_APPLY_TRX":            {{"gasUsed", uintField}, {"postState", hexField}, {"cumulativeGasUsed", uintField}, {"logsBloom", hexField}, ordinalField, {"duration", uintField}, {"status", uintField}, {"effectiveGasPrice", hexField}, {"contractAddress", optionalHexField}, {"logs", jsonField}},
+	"FINALIZE_BLOCK":           {numberField},
+	"END_BLOCK":                {numberField, {"size", uintField}, {"duration", uintField}, {"data", jsonField}},
+	"TRX_ABORT":                {{"blockNumber", uintField}, {"blockHash", hexField}, {"txIndex", uintField}, {"txHash", hexField}, {"partialTrace", hexField}, {"message", jsonField}},
+	"HEAD_UPDATE":              {numberField, hashField, {"previousNumber", uintField}, {"previousHash", hexField}},
+	"SIDE_CHAIN_BLOCK":         {numberField, hashField, {"forkParentNumber", uintField}, {"forkParentHash", hexField}},
+	"UNDO_BLOCK":               {numberField, hashField, {"parentHash", hexField}, {"logs", jsonField}},
+	"HEARTBEAT":                {{"headNumber", uintField}, {"headHash", hexField}, {"timestamp", uintField}},
+	"SYNC_PROGRESS":            {{"stage", stringField}, numberField, hashField},
+	"PENDING_TRX":              {hashField, {"from", hexField}, {"to", optionalHexField}, {"nonce", uintField}, {"gasPrice", hexField}, {"gasLimit", uintField}, {"inputHash", hexField}},
+	"PENDING_DROP":             {hashField, {"reason", stringField}},
+	"PENDING_REPLACE":          {hashField, {"replacedHash", hexField}},
+	"PENDING_EXECUTABLE":       {hashField, {"gapFillerHash", hexField}},
+	"PENDING_TRX_EFFECTS":      {hashField, {"headNumber", uintField}, {"headHash", hexField}, {"status", stringField}, {"gasUsed", uintField}, {"logs", jsonField}, {"transfers", jsonField}},
+}
+
+// JSONLinesPrinter is a printer writing each record it receives, printed or written as
+// pre-formatted `FIRE` lines, as a single line JSON object to the printer it wraps, e.g.:
+//
+//	{"record":"BALANCE_CHANGE","callIndex":1,"address":"0x...","old":"0x0de0b6b3a7640000","new":"0x...","reason":"transfer","ordinal":4}
+//
+// Numbers are JSON numbers, bytes are 0x prefixed hex strings, JSON fields are embedded as is
+// and fields recorded as `.` when absent are null. The fields of an unknown record, or of a
+// record not having the expected number of fields, are kept as strings in a `fields` array.
+// Lines not starting with `FIRE` are written untouched.
+type JSONLinesPrinter struct {
+	printer Printer
+}
+
+// NewJSONLinesPrinter creates a printer converting the records to JSON lines before handing
+// them to `printer`.
+func NewJSONLinesPrinter(printer Printer) *JSONLinesPrinter {
+	return &JSONLinesPrinter{printer: printer}
+}
+
+func (p *JSONLinesPrinter) Disabled() bool {
+	return false
+}
+
+func (p *JSONLinesPrinter) Write(in []byte) {
+	out := bytes.NewBuffer(make([]byte, 0, len(in)+len(in)/4))
+	for len(in) > 0 {
+		line := in
+		if i := bytes.IndexByte(in, '\n'); i >= 0 {
+			line, in = in[:i], in[i+1:]
+		} else {
+			in = nil
+		}
+
+		if !bytes.HasPrefix(line, []byte("FIRE ")) {
+			out.Write(line)
+			out.WriteByte('\n')
+			continue
+		}
+
+		record := string(line[len("FIRE "):])
+		kind := record
+		if i := strings.IndexByte(record, ' '); i >= 0 {
+			kind = record[:i]
+		}
+
+		var values []string
+		if kind != record {
+			count := len(recordFields[kind])
+			if count == 0 {
+				count = -1
+			}
+			values = strings.SplitN(record[len(kind)+1:], " ", count)
+		}
+		writeJSONLine(out, kind, values)
+	}
+
+	p.printer.Write(out.Bytes())
+}
+
+func (p *JSONLinesPrinter) Print(input ...string) {
+	if len(input) == 0 {
+		return
+	}
+
+	out := bytes.NewBuffer(nil)
+	writeJSONLine(out, input[0], input[1:])
+	p.printer.Write(out.Bytes())
+}
+
+// BlockBoundary forwards the block boundary to the wrapped printer, see `BatchingPrinter`.
+func (p *JSONLinesPrinter) BlockBoundary() {
+	if v, ok := p.printer.(blockBoundaryNotifier); ok {
+		v.BlockBoundary()
+	}
+}
+
+// Flush flushes the wrapped printer, see `BatchingPrinter`.
+func (p *JSONLinesPrinter) Flush() {
+	if v, ok := p.printer.(flusher); ok {
+		v.Flush()
+	}
+}
+
+// AfterWrite forwards the callback to the wrapped printer, see `AfterSinkWrite`.
+func (p *JSONLinesPrinter) AfterWrite(fn func()) {
+	afterWrite(p.printer, fn)
+}
+
+// Backlog returns the backlog of the wrapped printer, see `QueueingPrinter`.
+func (p *JSONLinesPrinter) Backlog() int {
+	if v, ok := p.printer.(sinkBacklogger); ok {
+		return v.Backlog()
+	}
+
+	return 0
+}
+
+// Err returns the first error that happened while writing to the wrapped printer's sink, if any.
+func (p *JSONLinesPrinter) Err() error {
+	if v, ok := p.printer.(SinkErrorer); ok {
+		return v.Err()
+	}
+
+	return nil
+}
+
+func writeJSONLine(out *bytes.Buffer, kind string, values []string) {
+	out.WriteString(`{"record":`)
+	writeJSONString(out, kind)
+
+	fields, known := recordFields[kind]
+	if !known || len(fields) != len(values) {
+		if len(values) > 0 {
+			out.WriteString(`,"fields":`)
+			out.WriteString(JSON(values))
+		}
+		out.WriteString("}\n")
+		return
+	}
+
+	for i, field := range fields {
+		out.WriteString(`,"`)
+		out.WriteString(field.name)
+		out.WriteString(`":`)
+		writeJSONValue(out, field.kind, values[i])
+	}
+	out.WriteString("}\n")
+}
+
+func writeJSONValue(out *bytes.Buffer, kind fieldKind, value string) {
+	switch kind {
+	case uintField:
+		number, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeJSONString(out, value)
+			return
+		}
+		// Re-formatted, leading zeros are accepted when parsing but invalid in JSON numbers
+		out.WriteString(strconv.FormatUint(number, 10))
+
+	case boolField:
+		if value != "true" && value != "false" {
+			writeJSONString(out, value)
+			return
+		}
+		out.WriteString(value)
+
+	case hexField:
+		if value == "." {
+			value = ""
+		}
+		writeJSONString(out, "0x"+value)
+
+	case optionalHexField:
+		if value == "." {
+			out.WriteString("null")
+			return
+		}
+		writeJSONString(out, "0x"+value)
+
+	case signedHexField:
+		switch {
+		case value == ".":
+			writeJSONString(out, "0x0")
+		case strings.HasPrefix(value, "-"):
+			writeJSONString(out, "-0x"+value[1:])
+		default:
+			writeJSONString(out, "0x"+strings.TrimPrefix(value, "+"))
+		}
+
+	case optionalStringField:
+		if value == "." {
+			out.WriteString("null")
+			return
+		}
+		writeJSONString(out, value)
+
+	case hexListField:
+		out.WriteByte('[')
+		if value != "" {
+			for i, element := range strings.Split(value, ",") {
+				if i != 0 {
+					out.WriteByte(',')
+				}
+				writeJSONString(out, "0x"+element)
+			}
+		}
+		out.WriteByte(']')
+
+	case jsonField:
+		if !json.Valid([]byte(value)) {
+			writeJSONString(out, value)
+			return
+		}
+		out.WriteString(value)
+
+	default:
+		writeJSONString(out, value)
+	}
+}
+
+func writeJSONString(out *bytes.Buffer, value string) {
+	out.WriteString(JSON(value))
+}
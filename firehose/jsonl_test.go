@@ -0,0 +1,60 @@
+package firehose
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLinesPrinter(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	jsonl := NewJSONLinesPrinter(printer)
+
+	jsonl.Print("BALANCE_CHANGE", "1", "aa00000000000000000000000000000000000000", ".", "03e8", "transfer", "4")
+	jsonl.Print("BALANCE_DELTA", "1", "aa00000000000000000000000000000000000000", "-03e8", "transfer", "5")
+	jsonl.Write([]byte("FIRE EVM_RUN_CALL CALL 2 5 . false 0000000000000000000000000000000000000000000000000000000000000005 true 1 3 1\nFIRE ADD_LOG 2 0 aa00000000000000000000000000000000000000 01,02 . 6\nFIRE UNKNOWN_RECORD a b\nnot a record\n"))
+
+	assert.Equal(t, `{"record":"BALANCE_CHANGE","callIndex":1,"address":"0xaa00000000000000000000000000000000000000","old":"0x","new":"0x03e8","reason":"transfer","ordinal":4}
+{"record":"BALANCE_DELTA","callIndex":1,"address":"0xaa00000000000000000000000000000000000000","delta":"-0x03e8","reason":"transfer","ordinal":5}
+{"record":"EVM_RUN_CALL","callType":"CALL","callIndex":2,"ordinal":5,"opCode":null,"precompile":false,"codeHash":"0x0000000000000000000000000000000000000000000000000000000000000005","hasCode":true,"parentIndex":1,"parentOrdinal":3,"depth":1}
+{"record":"ADD_LOG","callIndex":2,"indexInBlock":0,"address":"0xaa00000000000000000000000000000000000000","topics":["0x01","0x02"],"data":"0x","ordinal":6}
+{"record":"UNKNOWN_RECORD","fields":["a","b"]}
+not a record
+`, printer.Buffer().String())
+}
+
+func TestJSONLinesPrinter_Golden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "golden", "*.golden"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		printer := NewToBufferPrinter(len(content) * 2)
+		NewJSONLinesPrinter(printer).Write(content)
+
+		// Each record is converted, one line per line, with every field named
+		lines := 0
+		scanner := bufio.NewScanner(bytes.NewReader(printer.Buffer().Bytes()))
+		scanner.Buffer(nil, 10*1024*1024)
+		for scanner.Scan() {
+			lines++
+
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "%s: line %d", file, lines)
+			assert.Contains(t, recordFields, record["record"], "%s: line %d", file, lines)
+			assert.NotContains(t, record, "fields", "%s: line %d", file, lines)
+			assert.Len(t, record, len(recordFields[record["record"].(string)])+1, "%s: line %d", file, lines)
+		}
+		require.NoError(t, scanner.Err())
+		assert.Equal(t, bytes.Count(content, []byte{'\n'}), lines, file)
+	}
+}
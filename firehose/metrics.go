@@ -0,0 +1,27 @@
+package firehose
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	sinkWriteErrorCounter   = metrics.NewRegisteredCounter("firehose/sink/write/errors", nil)
+	sinkWriteFailureCounter = metrics.NewRegisteredCounter("firehose/sink/write/failures", nil)
+	sinkDroppedBytesCounter = metrics.NewRegisteredCounter("firehose/sink/dropped/bytes", nil)
+	sinkThrottleTimer       = metrics.NewRegisteredTimer("firehose/sink/throttle", nil)
+	sinkRateLimitTimer      = metrics.NewRegisteredTimer("firehose/sink/ratelimit", nil)
+
+	bufferBlockCapacityGauge = metrics.NewRegisteredGauge("firehose/buffer/block/capacity", nil)
+	bufferTxCapacityGauge    = metrics.NewRegisteredGauge("firehose/buffer/tx/capacity", nil)
+	bufferCapExceededCounter = metrics.NewRegisteredCounter("firehose/buffer/cap/exceeded", nil)
+
+	blockSegmentsCounter        = metrics.NewRegisteredCounter("firehose/block/segments", nil)
+	deferredRecordsDropsCounter = metrics.NewRegisteredCounter("firehose/block/segments/deferred/dropped", nil)
+
+	tracingDroppedSpansCounter = metrics.NewRegisteredCounter("firehose/tracing/dropped", nil)
+	tracingExportErrorCounter  = metrics.NewRegisteredCounter("firehose/tracing/export/errors", nil)
+
+	dryRunLinesCounter  = metrics.NewRegisteredCounter("firehose/dryrun/lines", nil)
+	dryRunBytesCounter  = metrics.NewRegisteredCounter("firehose/dryrun/bytes", nil)
+	dryRunBlocksCounter = metrics.NewRegisteredCounter("firehose/dryrun/blocks", nil)
+)
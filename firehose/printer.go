@@ -1,18 +1,25 @@
 package firehose
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/holiman/uint256"
 )
 
 type Printer interface {
@@ -26,8 +33,46 @@ type Printer interface {
 	Print(input ...string)
 }
 
+// ErrSinkCanceled is returned when a write to the Firehose sink was aborted because
+// its context was canceled, see `CancelSink`.
+var ErrSinkCanceled = errors.New("firehose sink canceled")
+
+// SinkErrorer is implemented by printers writing to an external sink, it reports the
+// first error that happened while writing, after which the sink must be considered broken.
+type SinkErrorer interface {
+	Err() error
+}
+
+// blockBoundaryNotifier is implemented by printers that must be notified once a complete
+// block was written to them, see `BatchingPrinter.BlockBoundary`.
+type blockBoundaryNotifier interface {
+	BlockBoundary()
+}
+
+// flusher is implemented by printers holding data in memory, see `BatchingPrinter.Flush`.
+type flusher interface {
+	Flush()
+}
+
+// writeNotifier is implemented by printers holding data in memory, see `AfterSinkWrite`.
+type writeNotifier interface {
+	// AfterWrite calls `fn` once all the data written to the printer so far was sent to
+	// its underlying writer, `fn` is never called if sending the data failed.
+	AfterWrite(fn func())
+}
+
 type DelegateToWriterPrinter struct {
+	ctx    context.Context
 	writer io.Writer
+
+	errLock sync.Mutex
+	err     error
+}
+
+// NewDelegateToWriterPrinter creates a printer writing straight to `writer`. Writes blocked
+// on `writer` are aborted when `ctx` is canceled.
+func NewDelegateToWriterPrinter(ctx context.Context, writer io.Writer) *DelegateToWriterPrinter {
+	return &DelegateToWriterPrinter{ctx: ctx, writer: newCancelableWriter(ctx, writer)}
 }
 
 func (p *DelegateToWriterPrinter) Disabled() bool {
@@ -35,43 +80,383 @@ func (p *DelegateToWriterPrinter) Disabled() bool {
 }
 
 func (p *DelegateToWriterPrinter) Write(in []byte) {
-	flushToFirehose(in, p.writer)
+	p.setErr(flushToFirehose(p.ctx, in, p.writer))
 }
 
 func (p *DelegateToWriterPrinter) Print(input ...string) {
-	flushToFirehose([]byte("FIRE "+strings.Join(input, " ")+"\n"), p.writer)
+	p.setErr(flushToFirehose(p.ctx, []byte("FIRE "+strings.Join(input, " ")+"\n"), p.writer))
+}
+
+// Err returns the first error that happened while writing to the sink, if any.
+func (p *DelegateToWriterPrinter) Err() error {
+	p.errLock.Lock()
+	defer p.errLock.Unlock()
+
+	return p.err
+}
+
+func (p *DelegateToWriterPrinter) setErr(err error) {
+	if err == nil {
+		return
+	}
+
+	p.errLock.Lock()
+	defer p.errLock.Unlock()
+
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// BatchingPrinter is a printer that accumulates writes in memory and sends them to
+// the underlying writer in large batches, reducing the syscall overhead when blocks
+// are small and frequent.
+//
+// Accumulated data is sent when the batch is full, when `BlockBoundary` is called and
+// the flush interval elapsed since the last flush, and periodically in the background
+// so that data is never held for more than the flush interval when the chain is idle.
+//
+// BatchingPrinter is thread-safe.
+type BatchingPrinter struct {
+	ctx           context.Context
+	lock          sync.Mutex
+	batch         *bufio.Writer
+	flushInterval time.Duration
+	lastFlush     time.Time
+
+	// Callbacks to call once the data currently in the batch was sent, see `AfterWrite`
+	afterFlush []func()
+}
+
+// NewBatchingPrinter creates a new BatchingPrinter accumulating up to `batchSize` bytes
+// before writing to `writer`. The `flushInterval` is the maximum amount of time data
+// stays buffered. Writes blocked on `writer` are aborted when `ctx` is canceled.
+func NewBatchingPrinter(ctx context.Context, writer io.Writer, batchSize int, flushInterval time.Duration) *BatchingPrinter {
+	writer = newCancelableWriter(ctx, writer)
+	p := &BatchingPrinter{
+		ctx: ctx,
+		// The retry logic is applied at the underlying writer level, so each batch is retried as a whole
+		batch: bufio.NewWriterSize(writerFunc(func(in []byte) (int, error) {
+			if err := flushToFirehose(ctx, in, writer); err != nil {
+				return 0, err
+			}
+
+			return len(in), nil
+		}), batchSize),
+		flushInterval: flushInterval,
+		lastFlush:     time.Now(),
+	}
+
+	if flushInterval > 0 {
+		go p.flushPeriodically()
+	}
+
+	return p
+}
+
+func (p *BatchingPrinter) Disabled() bool {
+	return false
+}
+
+func (p *BatchingPrinter) Write(in []byte) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.batch.Write(in)
+}
+
+func (p *BatchingPrinter) Print(input ...string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.batch.WriteString("FIRE ")
+	for i, in := range input {
+		if i != 0 {
+			p.batch.WriteByte(' ')
+		}
+		p.batch.WriteString(in)
+	}
+	p.batch.WriteByte('\n')
+}
+
+// BlockBoundary is called once a complete block has been written to the printer, it
+// flushes the accumulated batch if the flush interval elapsed since the last flush.
+func (p *BatchingPrinter) BlockBoundary() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if time.Since(p.lastFlush) >= p.flushInterval {
+		p.flush()
+	}
+}
+
+// Flush sends all accumulated data to the underlying writer right away.
+func (p *BatchingPrinter) Flush() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.flush()
+}
+
+// AfterWrite calls `fn` once the data currently in the batch was sent to the underlying
+// writer, right away if the batch is empty. It's called with the printer's lock held, `fn`
+// must not write to the printer.
+func (p *BatchingPrinter) AfterWrite(fn func()) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.batch.Buffered() == 0 {
+		if _, err := p.batch.Write(nil); err == nil {
+			fn()
+		}
+		return
+	}
+
+	p.afterFlush = append(p.afterFlush, fn)
+}
+
+// Err returns the first error that happened while writing to the sink, if any.
+func (p *BatchingPrinter) Err() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// The buffered writer error is sticky, writing an empty slice only reports it
+	_, err := p.batch.Write(nil)
+	return err
+}
+
+func (p *BatchingPrinter) flush() {
+	err := p.batch.Flush()
+	p.lastFlush = time.Now()
+
+	if err == nil {
+		for _, fn := range p.afterFlush {
+			fn()
+		}
+	}
+	p.afterFlush = nil
+}
+
+func (p *BatchingPrinter) flushPeriodically() {
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.BlockBoundary()
+		}
+	}
+}
+
+type writerFunc func(in []byte) (int, error)
+
+func (f writerFunc) Write(in []byte) (int, error) {
+	return f(in)
+}
+
+// WriteFailurePolicy determines how `flushToFirehose` reacts when data cannot be written
+// to Firehose after all retries.
+type WriteFailurePolicy string
+
+const (
+	// WriteFailurePolicyCrash halts the node right away, no block is imported
+	// past the one that failed to be written.
+	WriteFailurePolicyCrash WriteFailurePolicy = "crash"
+
+	// WriteFailurePolicyPauseSync keeps retrying the write until it succeeds, since
+	// writes happen within block import, this effectively pauses the sync.
+	WriteFailurePolicyPauseSync WriteFailurePolicy = "pause-sync"
+
+	// WriteFailurePolicyDropAndLog drops the data that could not be written, logs
+	// the error and moves on. The resulting Firehose stream is corrupted.
+	WriteFailurePolicyDropAndLog WriteFailurePolicy = "drop-and-log"
+)
+
+// ParseWriteFailurePolicy parses the received string into a WriteFailurePolicy,
+// returns an error if the policy is unknown.
+func ParseWriteFailurePolicy(in string) (WriteFailurePolicy, error) {
+	switch policy := WriteFailurePolicy(in); policy {
+	case WriteFailurePolicyCrash, WriteFailurePolicyPauseSync, WriteFailurePolicyDropAndLog:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("unknown firehose write failure policy %q, valid values are %q, %q and %q", in,
+		WriteFailurePolicyCrash, WriteFailurePolicyPauseSync, WriteFailurePolicyDropAndLog)
 }
 
 // flushToFirehose sends data to Firehose via `io.Writter` checking for errors
 // and retrying if necessary.
 //
-// If error is still present after 10 retries, prints an error message to `writer`
-// as well as writing file `/tmp/firehose_writer_failed_print.log` with the same
-// error message.
-func flushToFirehose(in []byte, writer io.Writer) {
+// If error is still present after 10 retries, the active `SinkWriteFailurePolicy`
+// is applied. If `ctx` is canceled while writing, `ErrSinkCanceled` is returned
+// right away without applying the policy.
+func flushToFirehose(ctx context.Context, in []byte, writer io.Writer) error {
+	in, err := writeWithRetries(ctx, in, writer, 10)
+	if err == nil || err == ErrSinkCanceled {
+		return err
+	}
+
+	sinkWriteFailureCounter.Inc(1)
+
+	switch SinkWriteFailurePolicy {
+	case WriteFailurePolicyPauseSync:
+		return pauseUntilWritten(ctx, in, writer, err)
+
+	case WriteFailurePolicyCrash:
+		log.Crit("Firehose failed writing to its sink, halting the node", "policy", SinkWriteFailurePolicy, "pending_bytes", len(in), "err", err)
+		return err
+
+	default:
+		dropAndLog(in, writer, err)
+		return nil
+	}
+}
+
+// writeWithRetries writes `in` to `writer` trying at most `loops` times, it returns
+// the data that was not written yet and the last error encountered, if any.
+func writeWithRetries(ctx context.Context, in []byte, writer io.Writer, loops int) ([]byte, error) {
 	var written int
 	var err error
-	loops := 10
 	for i := 0; i < loops; i++ {
+		if ctx.Err() != nil {
+			return in, ErrSinkCanceled
+		}
+
 		written, err = writer.Write(in)
+		if err == ErrSinkCanceled {
+			return in, err
+		}
 
 		if len(in) == written {
-			return
+			return nil, nil
 		}
 
+		sinkWriteErrorCounter.Inc(1)
 		in = in[written:]
-		if i == loops-1 {
-			break
+	}
+
+	if err == nil {
+		err = io.ErrShortWrite
+	}
+
+	return in, err
+}
+
+// cancelableWriter sends the writes to `writer` through a single long-lived goroutine, so
+// that a write blocked on a consumer that stopped reading can be abandoned by canceling
+// `ctx`. Once `ctx` is canceled, no other write is ever sent to `writer`, the abandoned
+// write, which may still complete later on, cannot interleave with further output.
+type cancelableWriter struct {
+	ctx    context.Context
+	writer io.Writer
+
+	start    sync.Once
+	requests chan writeRequest
+}
+
+type writeRequest struct {
+	in     []byte
+	result chan writeResult
+}
+
+type writeResult struct {
+	written int
+	err     error
+}
+
+// newCancelableWriter wraps `writer` so that its writes are aborted when `ctx` is canceled,
+// `writer` is returned as is if `ctx` can never be canceled.
+func newCancelableWriter(ctx context.Context, writer io.Writer) io.Writer {
+	if ctx.Done() == nil {
+		return writer
+	}
+
+	return &cancelableWriter{ctx: ctx, writer: writer, requests: make(chan writeRequest)}
+}
+
+// Write is thread-safe, concurrent writes are sent to the underlying writer one at a time.
+func (w *cancelableWriter) Write(in []byte) (int, error) {
+	if w.ctx.Err() != nil {
+		return 0, ErrSinkCanceled
+	}
+	w.start.Do(func() { go w.run() })
+
+	request := writeRequest{in: in, result: make(chan writeResult, 1)}
+	select {
+	case w.requests <- request:
+	case <-w.ctx.Done():
+		return 0, ErrSinkCanceled
+	}
+
+	select {
+	case result := <-request.result:
+		return result.written, result.err
+	case <-w.ctx.Done():
+		return 0, ErrSinkCanceled
+	}
+}
+
+func (w *cancelableWriter) run() {
+	for {
+		select {
+		case request := <-w.requests:
+			if w.ctx.Err() != nil {
+				request.result <- writeResult{0, ErrSinkCanceled}
+				return
+			}
+
+			written, err := w.writer.Write(request.in)
+			request.result <- writeResult{written, err}
+		case <-w.ctx.Done():
+			return
 		}
 	}
+}
+
+func pauseUntilWritten(ctx context.Context, in []byte, writer io.Writer, err error) error {
+	backoff := 100 * time.Millisecond
+	for {
+		log.Warn("Firehose failed writing to its sink, sync is paused until write succeeds", "pending_bytes", len(in), "retry_in", backoff, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return ErrSinkCanceled
+		case <-time.After(backoff):
+		}
+
+		if in, err = writeWithRetries(ctx, in, writer, 1); err == nil || err == ErrSinkCanceled {
+			if err == nil {
+				log.Info("Firehose sink write succeeded, resuming sync")
+			}
+			return err
+		}
+
+		if backoff < 5*time.Second {
+			backoff *= 2
+		}
+	}
+}
 
-	errstr := fmt.Sprintf("\nFIREHOSE FAILED WRITING %dx: %s\n", loops, err)
+// dropAndLog prints an error message to `writer` as well as writing file
+// `/tmp/firehose_writer_failed_print.log` with the same error message.
+func dropAndLog(in []byte, writer io.Writer, err error) {
+	sinkDroppedBytesCounter.Inc(int64(len(in)))
+	log.Error("Firehose failed writing to its sink, dropping data, Firehose stream is now corrupted", "dropped_bytes", len(in), "err", err)
+
+	errstr := fmt.Sprintf("\nFIREHOSE FAILED WRITING: %s\n", err)
 	os.WriteFile("/tmp/firehose_writer_failed_print.log", []byte(errstr), 0644)
 	fmt.Fprint(writer, errstr)
 }
 
 type ToBufferPrinter struct {
 	buffer *bytes.Buffer
+
+	// tuned is set when the buffer is one of the tuned buffers, see `AllocateBuffers`
+	tuned *tunedBuffer
+	err   error
 }
 
 func NewToBufferPrinter(initialAllocationSizeInBytes int) *ToBufferPrinter {
@@ -86,11 +471,13 @@ func NewToBufferPrinterWithBuffer(buffer *bytes.Buffer) *ToBufferPrinter {
 
 	return &ToBufferPrinter{
 		buffer: buffer,
+		tuned:  tunedBufferOf(buffer),
 	}
 }
 
 func (p *ToBufferPrinter) Reset() {
 	p.buffer.Reset()
+	p.err = nil
 }
 
 func (p *ToBufferPrinter) Disabled() bool {
@@ -98,11 +485,42 @@ func (p *ToBufferPrinter) Disabled() bool {
 }
 
 func (p *ToBufferPrinter) Write(in []byte) {
-	p.buffer.Write(in)
+	if p.reserve(len(in)) {
+		p.buffer.Write(in)
+	}
 }
 
 func (p *ToBufferPrinter) Print(input ...string) {
-	p.buffer.WriteString("FIRE " + strings.Join(input, " ") + "\n")
+	line := "FIRE " + strings.Join(input, " ") + "\n"
+	if p.reserve(len(line)) {
+		p.buffer.WriteString(line)
+	}
+}
+
+// Err returns the error wrapping `ErrBufferMemoryCapExceeded` if the data printed since the
+// last reset did not fit in the buffer, the data printed from then on was dropped.
+func (p *ToBufferPrinter) Err() error {
+	return p.err
+}
+
+// reserve returns true if `size` more bytes fit in the buffer, see `BufferMemoryCap`.
+func (p *ToBufferPrinter) reserve(size int) bool {
+	if p.err != nil {
+		return false
+	}
+
+	if p.tuned != nil {
+		p.err = p.tuned.reserve(size)
+	}
+
+	return p.err == nil
+}
+
+// fail records `err` as the error of the printer, unless it already has one.
+func (p *ToBufferPrinter) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
 }
 
 func (p *ToBufferPrinter) Buffer() *bytes.Buffer {
@@ -142,6 +560,34 @@ func BigInt(in *big.Int) string {
 	return Hex(in.Bytes())
 }
 
+// SignedBigInt encodes `in` as its magnitude, like `BigInt`, prefixed by an explicit `+` or
+// `-` sign marker, e.g. `-0de0b6b3a7640000`. Zero, like nil, has no sign and is encoded as `.`.
+func SignedBigInt(in *big.Int) string {
+	if in == nil || in.Sign() == 0 {
+		return "."
+	}
+
+	if in.Sign() < 0 {
+		return "-" + Hex(new(big.Int).Abs(in).Bytes())
+	}
+
+	return "+" + Hex(in.Bytes())
+}
+
+// SignedUint256 encodes `in`, interpreted as a two's complement 256-bit signed integer, the
+// same way `SignedBigInt` does.
+func SignedUint256(in *uint256.Int) string {
+	if in == nil || in.IsZero() {
+		return "."
+	}
+
+	if in.Sign() < 0 {
+		return "-" + Hex(new(uint256.Int).Neg(in).Bytes())
+	}
+
+	return "+" + Hex(in.Bytes())
+}
+
 func Uint(in uint) string {
 	return strconv.FormatUint(uint64(in), 10)
 }
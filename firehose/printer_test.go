@@ -0,0 +1,209 @@
+package firehose
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(in []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(in)
+}
+
+func TestBatchingPrinter(t *testing.T) {
+	writer := &countingWriter{}
+	printer := NewBatchingPrinter(context.Background(), writer, 1024, time.Hour)
+
+	printer.Print("BEGIN_BLOCK", "1")
+	printer.Write([]byte("FIRE END_BLOCK 1\n"))
+	printer.BlockBoundary()
+
+	printer.Print("BEGIN_BLOCK", "2")
+	printer.Print("END_BLOCK", "2")
+	printer.BlockBoundary()
+
+	assert.Equal(t, 0, writer.writes, "flush interval not elapsed, nothing should have been written")
+
+	printer.Flush()
+	assert.Equal(t, 1, writer.writes)
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\nFIRE END_BLOCK 1\nFIRE BEGIN_BLOCK 2\nFIRE END_BLOCK 2\n", writer.String())
+}
+
+func TestBatchingPrinter_BlockBoundaryFlush(t *testing.T) {
+	writer := &countingWriter{}
+	printer := NewBatchingPrinter(context.Background(), writer, 1024, 0)
+
+	printer.Print("BEGIN_BLOCK", "1")
+	assert.Equal(t, 0, writer.writes)
+
+	printer.BlockBoundary()
+	assert.Equal(t, 1, writer.writes)
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", writer.String())
+}
+
+func TestBatchingPrinter_AfterWrite(t *testing.T) {
+	writer := &countingWriter{}
+	printer := NewBatchingPrinter(context.Background(), writer, 1024, time.Hour)
+
+	written := 0
+	printer.AfterWrite(func() { written++ })
+	assert.Equal(t, 1, written, "empty batch, callback should be called right away")
+
+	printer.Print("BEGIN_BLOCK", "1")
+	printer.AfterWrite(func() { written++ })
+	printer.BlockBoundary()
+	assert.Equal(t, 1, written, "block still in the batch, callback should not be called")
+
+	printer.Flush()
+	assert.Equal(t, 2, written)
+}
+
+// failingWriter fails the first `failures` writes then delegates to its buffer.
+type failingWriter struct {
+	bytes.Buffer
+	failures int
+}
+
+func (w *failingWriter) Write(in []byte) (int, error) {
+	if w.failures > 0 {
+		w.failures--
+		return 0, errors.New("broken pipe")
+	}
+
+	return w.Buffer.Write(in)
+}
+
+func TestFlushToFirehose_WriteFailurePolicy(t *testing.T) {
+	defer func(policy WriteFailurePolicy) { SinkWriteFailurePolicy = policy }(SinkWriteFailurePolicy)
+
+	t.Run("default", func(t *testing.T) {
+		assert.Equal(t, WriteFailurePolicyDropAndLog, SinkWriteFailurePolicy)
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		SinkWriteFailurePolicy = WriteFailurePolicyCrash
+
+		writer := &failingWriter{failures: 9}
+		flushToFirehose(context.Background(), []byte("FIRE BEGIN_BLOCK 1\n"), writer)
+		assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", writer.String())
+	})
+
+	t.Run("pause-sync", func(t *testing.T) {
+		SinkWriteFailurePolicy = WriteFailurePolicyPauseSync
+
+		writer := &failingWriter{failures: 11}
+		flushToFirehose(context.Background(), []byte("FIRE BEGIN_BLOCK 1\n"), writer)
+		assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", writer.String())
+	})
+
+	t.Run("drop-and-log", func(t *testing.T) {
+		SinkWriteFailurePolicy = WriteFailurePolicyDropAndLog
+
+		writer := &failingWriter{failures: 10}
+		flushToFirehose(context.Background(), []byte("FIRE BEGIN_BLOCK 1\n"), writer)
+		assert.Equal(t, "\nFIREHOSE FAILED WRITING: broken pipe\n", writer.String())
+	})
+}
+
+func TestParseWriteFailurePolicy(t *testing.T) {
+	policy, err := ParseWriteFailurePolicy("pause-sync")
+	require.NoError(t, err)
+	assert.Equal(t, WriteFailurePolicyPauseSync, policy)
+
+	_, err = ParseWriteFailurePolicy("unknown")
+	assert.Error(t, err)
+}
+
+// blockedWriter simulates a consumer that stopped reading, writes block until released.
+type blockedWriter struct {
+	release chan struct{}
+}
+
+func (w *blockedWriter) Write(in []byte) (int, error) {
+	<-w.release
+	return len(in), nil
+}
+
+func TestDelegateToWriterPrinter_Canceled(t *testing.T) {
+	writer := &blockedWriter{release: make(chan struct{})}
+	defer close(writer.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	printer := NewDelegateToWriterPrinter(ctx, writer)
+
+	done := make(chan struct{})
+	go func() {
+		printer.Print("BEGIN_BLOCK", "1")
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked write was not aborted by context cancellation")
+	}
+
+	assert.Equal(t, ErrSinkCanceled, printer.Err())
+}
+
+func TestCancelableWriter_NoWriteAfterCancel(t *testing.T) {
+	sink := &recordingBlockedWriter{blockedWriter: blockedWriter{release: make(chan struct{})}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	writer := newCancelableWriter(ctx, sink)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := writer.Write([]byte("FIRE BEGIN_BLOCK 1\n"))
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool { return sink.calls.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.Equal(t, ErrSinkCanceled, <-done)
+
+	_, err := writer.Write([]byte("FIRE BEGIN_BLOCK 2\n"))
+	assert.Equal(t, ErrSinkCanceled, err)
+
+	// The abandoned write completes, no later write reaches the sink
+	close(sink.release)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), sink.calls.Load())
+}
+
+type recordingBlockedWriter struct {
+	blockedWriter
+	calls atomic.Int32
+}
+
+func (w *recordingBlockedWriter) Write(in []byte) (int, error) {
+	w.calls.Inc()
+	return w.blockedWriter.Write(in)
+}
+
+func TestSignedBigInt(t *testing.T) {
+	assert.Equal(t, ".", SignedBigInt(nil))
+	assert.Equal(t, ".", SignedBigInt(big.NewInt(0)))
+	assert.Equal(t, "+03e8", SignedBigInt(big.NewInt(1000)))
+	assert.Equal(t, "-03e8", SignedBigInt(big.NewInt(-1000)))
+	assert.Equal(t, "-03e8", SignedUint256(new(uint256.Int).Neg(uint256.NewInt().SetUint64(1000))))
+	assert.Equal(t, "+03e8", SignedUint256(uint256.NewInt().SetUint64(1000)))
+	assert.Equal(t, ".", SignedUint256(uint256.NewInt()))
+	assert.Equal(t, ".", SignedUint256(nil))
+}
@@ -0,0 +1,163 @@
+package firehose
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// ProbeState is a snapshot of the Firehose instrumentation of the block being imported, for
+// live troubleshooting of a node whose emission is wedged, see `Probe`.
+type ProbeState struct {
+	// InBlock is true while a block is being imported, `BlockNumber` and `BlockHash` are then
+	// the ones of the block, otherwise the ones of the last block imported.
+	InBlock     bool   `json:"in_block"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+
+	// InTransaction is true while a transaction of the block is being applied, `TxIndex` being
+	// its index in the block, -1 when no transaction is applied.
+	InTransaction bool  `json:"in_transaction"`
+	TxIndex       int64 `json:"tx_index"`
+
+	// CallDepth is the depth of the active call of the transaction, 0 for its root call.
+	CallDepth int64 `json:"call_depth"`
+
+	// BlockOrdinal and TxOrdinal are the last ordinals of the records of the block and of the
+	// transaction being applied.
+	BlockOrdinal uint64 `json:"block_ordinal"`
+	TxOrdinal    uint64 `json:"tx_ordinal"`
+
+	// BlockBufferBytes and TxBufferBytes are the amount of bytes accumulated for the block and
+	// for the last transaction applied, updated once per transaction.
+	BlockBufferBytes int64 `json:"block_buffer_bytes"`
+	TxBufferBytes    int64 `json:"tx_buffer_bytes"`
+
+	// LastEmittedBlock is the number of the last block emitted, 0 if none was, and
+	// LastBlockWrite the time at which it was written.
+	LastEmittedBlock uint64    `json:"last_emitted_block"`
+	LastBlockWrite   time.Time `json:"last_block_write"`
+}
+
+// liveProbe is the state published, through atomics, by the contexts instrumenting the block
+// import, the ones accumulating their data in `BlockSyncBuffer` and `TxSyncBuffer`. It's read
+// by `Probe` without ever pausing block processing.
+var liveProbe = struct {
+	inBlock          *atomic.Bool
+	blockNumber      *atomic.Uint64
+	blockHash        *atomic.String
+	inTransaction    *atomic.Bool
+	txIndex          *atomic.Int64
+	callDepth        *atomic.Int64
+	blockOrdinals    *atomic.Value
+	txOrdinals       *atomic.Value
+	blockBufferBytes *atomic.Int64
+	txBufferBytes    *atomic.Int64
+}{
+	inBlock:          atomic.NewBool(false),
+	blockNumber:      atomic.NewUint64(0),
+	blockHash:        atomic.NewString(""),
+	inTransaction:    atomic.NewBool(false),
+	txIndex:          atomic.NewInt64(-1),
+	callDepth:        atomic.NewInt64(0),
+	blockOrdinals:    &atomic.Value{},
+	txOrdinals:       &atomic.Value{},
+	blockBufferBytes: atomic.NewInt64(0),
+	txBufferBytes:    atomic.NewInt64(0),
+}
+
+// Probe returns a snapshot of the Firehose instrumentation of the block being imported. Each
+// value is read atomically while the block is processed, the snapshot as a whole is not, e.g.
+// the transaction index may already be the one of the next transaction.
+func Probe() ProbeState {
+	state := ProbeState{
+		InBlock:          liveProbe.inBlock.Load(),
+		BlockNumber:      liveProbe.blockNumber.Load(),
+		BlockHash:        liveProbe.blockHash.Load(),
+		InTransaction:    liveProbe.inTransaction.Load(),
+		TxIndex:          liveProbe.txIndex.Load(),
+		CallDepth:        liveProbe.callDepth.Load(),
+		BlockOrdinal:     loadOrdinal(liveProbe.blockOrdinals),
+		TxOrdinal:        loadOrdinal(liveProbe.txOrdinals),
+		BlockBufferBytes: liveProbe.blockBufferBytes.Load(),
+		TxBufferBytes:    liveProbe.txBufferBytes.Load(),
+		LastBlockWrite:   LastBlockWriteTime(),
+	}
+
+	if last := syncContext.LastEmittedBlock(); last != nil {
+		state.LastEmittedBlock = last.Number
+	}
+
+	return state
+}
+
+// ProbeHandler returns the HTTP handler serving the `Probe` snapshot as JSON.
+func ProbeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Probe())
+	})
+}
+
+// loadOrdinal returns the value of the ordinal counter held by `counters`, 0 if none is.
+func loadOrdinal(counters *atomic.Value) uint64 {
+	if counter, ok := counters.Load().(*atomic.Uint64); ok {
+		return counter.Load()
+	}
+
+	return 0
+}
+
+// probeBlock publishes the start or the end, when `inBlock` is false, of the block of the
+// context if it's one of the block import.
+func (ctx *Context) probeBlock(inBlock bool) {
+	if !ctx.probed {
+		return
+	}
+
+	if inBlock {
+		liveProbe.blockNumber.Store(ctx.blockNumber)
+		liveProbe.blockHash.Store(Hash(ctx.blockHash))
+		liveProbe.blockOrdinals.Store(ctx.totalOrderingCounter)
+		liveProbe.blockBufferBytes.Store(0)
+	}
+	liveProbe.inBlock.Store(inBlock)
+}
+
+// probeTransaction publishes the start of the transaction at `txIndex`, or the end when it's
+// -1, of the context if it's one of the block import.
+func (ctx *Context) probeTransaction(txIndex int64) {
+	if !ctx.probed {
+		return
+	}
+
+	if txIndex >= 0 {
+		liveProbe.txOrdinals.Store(ctx.totalOrderingCounter)
+	}
+	liveProbe.txIndex.Store(txIndex)
+	liveProbe.callDepth.Store(0)
+	liveProbe.inTransaction.Store(txIndex >= 0)
+}
+
+// probeCallDepth publishes the depth of the active call of the context if it's one of the
+// block import.
+func (ctx *Context) probeCallDepth() {
+	if ctx.probed {
+		liveProbe.callDepth.Store(int64(ctx.callStack.Len() - 1))
+	}
+}
+
+// probeFlushedTransaction publishes the amount of bytes accumulated for the block and for the
+// transaction `txBytes` flushed into it, if it's the block import.
+func (ctx *Context) probeFlushedTransaction(txBytes int) {
+	if !ctx.probed {
+		return
+	}
+
+	liveProbe.txBufferBytes.Store(int64(txBytes))
+	if v, ok := ctx.printer.(*ToBufferPrinter); ok {
+		liveProbe.blockBufferBytes.Store(int64(v.buffer.Len()))
+	}
+}
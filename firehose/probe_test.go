@@ -0,0 +1,95 @@
+package firehose
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbe(t *testing.T) {
+	defer func(enabled, syncEnabled bool, ctx *Context) {
+		Enabled, SyncInstrumentationEnabled, syncContext = enabled, syncEnabled, ctx
+	}(Enabled, SyncInstrumentationEnabled, syncContext)
+
+	withBuffers(t, 1024, 1024, 0)
+	Enabled, SyncInstrumentationEnabled, syncContext = true, true, NewContext(NewToBufferPrinter(1024), false)
+
+	// The state is read while the block is processed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				Probe()
+			}
+		}
+	}()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)})
+
+	ctx := NewBlockContextWithBuffer(BlockSyncBuffer)
+	ctx.StartBlock(block)
+
+	txCtx := AcquireTransactionContextWithBuffer(TxSyncBuffer)
+	defer ReleaseContext(txCtx)
+
+	txCtx.StartTransactionRaw(common.Hash{}, nil, common.Big0, nil, nil, nil, 21000, common.Big1, 0, nil, nil, nil, nil, 0, 2)
+	txCtx.StartCall(CallTypeCall, "", false, common.Hash{}, false)
+	txCtx.StartCall(CallTypeCall, "", false, common.Hash{}, false)
+
+	state := Probe()
+	assert.True(t, state.InBlock)
+	assert.Equal(t, uint64(5), state.BlockNumber)
+	assert.Equal(t, Hash(block.Hash()), state.BlockHash)
+	assert.True(t, state.InTransaction)
+	assert.Equal(t, int64(2), state.TxIndex)
+	assert.Equal(t, int64(2), state.CallDepth)
+	assert.Equal(t, txCtx.totalOrderingCounter.Load(), state.TxOrdinal)
+
+	txCtx.EndCall(0, nil)
+	assert.Equal(t, int64(1), Probe().CallDepth)
+
+	txBytes := TxSyncBuffer.Len()
+	ctx.FlushTransaction(txCtx)
+
+	state = Probe()
+	assert.False(t, state.InTransaction)
+	assert.Equal(t, int64(-1), state.TxIndex)
+	assert.Equal(t, int64(txBytes), state.TxBufferBytes)
+	assert.Equal(t, int64(BlockSyncBuffer.Len()), state.BlockBufferBytes)
+
+	ctx.EndBlock(block, nil)
+	require.NoError(t, ctx.FlushBlock())
+
+	// The handler serves the state of the last block once it's emitted
+	recorder := httptest.NewRecorder()
+	ProbeHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/firehose", nil))
+
+	var served ProbeState
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &served))
+	assert.False(t, served.InBlock)
+	assert.Equal(t, uint64(5), served.BlockNumber)
+	assert.Equal(t, uint64(5), served.LastEmittedBlock)
+	assert.False(t, served.LastBlockWrite.IsZero())
+}
+
+func TestProbe_OtherContexts(t *testing.T) {
+	withBuffers(t, 1024, 1024, 0)
+
+	before := Probe()
+
+	// Contexts not instrumenting the block import do not publish their state
+	ctx := NewBlockContextWithBuffer(NewToBufferPrinter(1024).Buffer())
+	ctx.StartBlock(types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(before.BlockNumber + 1)}))
+
+	assert.Equal(t, before, Probe())
+}
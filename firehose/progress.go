@@ -0,0 +1,36 @@
+package firehose
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SyncStage is a stage of the initial sync importing blocks without executing them, see
+// `RecordSyncProgress`.
+type SyncStage string
+
+const (
+	// SyncStageHeaders is the import of the headers of the chain, ahead of their content in a
+	// fast or light sync.
+	SyncStageHeaders SyncStage = "headers"
+
+	// SyncStageBodies is the import of the downloaded bodies of a full sync, the blocks being
+	// executed right after, each of them then emitted as usual.
+	SyncStageBodies SyncStage = "bodies"
+
+	// SyncStageReceipts is the import of the bodies and the receipts of the blocks of a fast
+	// sync below its pivot, which are not executed.
+	SyncStageReceipts SyncStage = "receipts"
+)
+
+// RecordSyncProgress records that the initial sync imported the items of `stage` up to the block
+// of `header`, so that the tooling tracking the last block seen through the `FINALIZE_BLOCK`
+// records keeps track of the sync progress while blocks are imported without being executed,
+// see `BlockProgressEnabled`. It can be called concurrently to block processing.
+func (ctx *Context) RecordSyncProgress(stage SyncStage, header *types.Header) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printConcurrent("SYNC_PROGRESS", string(stage), Uint64(header.Number.Uint64()), Hash(header.Hash()))
+	ctx.blockBoundary()
+}
@@ -0,0 +1,164 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/atomic"
+)
+
+// proposedBufferPool recycles the buffers of the proposed blocks across the sealing tasks of
+// the miner, each task holding buffers of its own until it's released, see `ProposedBlock`.
+var proposedBufferPool = sync.Pool{
+	New: func() interface{} {
+		return bytes.NewBuffer(nil)
+	},
+}
+
+// proposedTaskIDs is the last identifier given to a sealing task, see `ProposedBlock.TaskID`.
+var proposedTaskIDs = atomic.NewUint64(0)
+
+// ProposedBlock accumulates the records of the transactions applied, one after the other,
+// to a block being built by the miner, so that each candidate block assembled out of them
+// can be emitted as a proposed block, see `Emit`.
+//
+// Proposed blocks are speculative, they are flagged by a `PROPOSED_BLOCK` record and are not
+// part of the canonical chain, the block is emitted again as any other block if it's sealed
+// and imported.
+//
+// Each proposed block is a sealing task of the miner, it accumulates its records in pooled
+// buffers of its own, never shared with the other tasks nor with block import, so concurrent
+// speculative executions cannot interleave their records. The buffers are given back to the
+// pool by `Release`.
+type ProposedBlock struct {
+	taskID       uint64
+	transactions *bytes.Buffer
+	accounting   *blockAccounting
+	creations    contractCreations
+
+	txContext *Context
+	txBuffer  *bytes.Buffer
+}
+
+// NewProposedBlock creates the proposed block of a new sealing task, its buffers are taken
+// from the pool and must be given back through `Release` once the task is discarded.
+func NewProposedBlock() *ProposedBlock {
+	p := &ProposedBlock{
+		taskID:       proposedTaskIDs.Inc(),
+		transactions: acquireProposedBuffer(),
+		txBuffer:     acquireProposedBuffer(),
+	}
+	p.txContext = NewTransactionContextWithBuffer(p.txBuffer)
+	if SelfCheck != SelfCheckOff {
+		p.accounting = newBlockAccounting()
+	}
+
+	return p
+}
+
+// TaskID returns the identifier of the sealing task, unique for the process, recorded by the
+// `PROPOSED_BLOCK` record of each candidate block emitted by the task.
+func (p *ProposedBlock) TaskID() uint64 {
+	if p == nil {
+		return 0
+	}
+
+	return p.taskID
+}
+
+// Release gives the buffers of the sealing task back to the pool, the proposed block must not
+// be used anymore. Releasing a released proposed block is a no-op.
+func (p *ProposedBlock) Release() {
+	if p == nil || p.transactions == nil {
+		return
+	}
+
+	releaseProposedBuffer(p.transactions)
+	releaseProposedBuffer(p.txBuffer)
+	p.transactions, p.txBuffer, p.txContext = nil, nil, nil
+}
+
+func acquireProposedBuffer() *bytes.Buffer {
+	buffer := proposedBufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+
+	return buffer
+}
+
+func releaseProposedBuffer(buffer *bytes.Buffer) {
+	proposedBufferPool.Put(buffer)
+}
+
+// StartTransaction starts recording `tx`, sent by `from`, applied as the transaction at
+// `txIndex` of the block being built. The returned context must be used to apply it and the
+// transaction must be ended through `EndTransaction` whether it was applied or not.
+func (p *ProposedBlock) StartTransaction(tx *types.Transaction, txIndex uint, from common.Address) *Context {
+	if p == nil {
+		return NoOpContext
+	}
+
+	// London fork not active in this branch yet, replace by `header.BaseFee` instead of `nil` when it's the case (and remove this comment)
+	p.txContext.StartTransaction(tx, txIndex, nil)
+	p.txContext.RecordTrxFrom(from)
+
+	return p.txContext
+}
+
+// EndTransaction ends the transaction started by `StartTransaction`, it's kept in the block
+// being built with its `receipt`. When `receipt` is nil, the transaction was rejected and
+// its records are dropped.
+func (p *ProposedBlock) EndTransaction(receipt *types.Receipt) {
+	if p == nil {
+		return
+	}
+
+	if receipt != nil {
+		p.txContext.EndTransaction(receipt)
+		p.transactions.Write(p.txBuffer.Bytes())
+
+		if p.accounting != nil && p.txContext.accounting != nil {
+			p.accounting.merge(p.txContext.accounting)
+		}
+
+		p.creations.merge(p.txContext.creations)
+	}
+
+	p.txBuffer.Reset()
+	p.txContext.Reset()
+}
+
+// Emit writes `block`, assembled out of the transactions applied so far, as a proposed block.
+// The `finalize` function must record, in the context it receives, the changes applied to
+// the block once its transactions were applied (e.g. block and uncle rewards).
+//
+// The block is flushed like any other block, along with the blocks being imported, see
+// `Context.FlushBlock`.
+func (p *ProposedBlock) Emit(block *types.Block, totalDifficulty *big.Int, finalize func(ctx *Context)) error {
+	if p == nil || !Enabled {
+		return nil
+	}
+
+	buffer := acquireProposedBuffer()
+	defer releaseProposedBuffer(buffer)
+
+	ctx := NewBlockContextWithBuffer(buffer)
+	ctx.StartBlock(block)
+	ctx.printer.Print("PROPOSED_BLOCK", Uint64(block.NumberU64()), Uint64(p.taskID))
+	ctx.blockOutOfOrder = true
+	ctx.printer.Write(p.transactions.Bytes())
+
+	if ctx.accounting != nil && p.accounting != nil {
+		ctx.accounting.merge(p.accounting)
+	}
+
+	ctx.creations.merge(&p.creations)
+
+	ctx.FinalizeBlock(block)
+	finalize(ctx)
+	ctx.EndBlock(block, totalDifficulty)
+
+	return ctx.FlushBlock()
+}
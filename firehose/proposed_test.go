@@ -0,0 +1,92 @@
+package firehose
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposedBlock(t *testing.T) {
+	defer func(enabled, syncEnabled bool, ctx *Context) {
+		Enabled, SyncInstrumentationEnabled, syncContext = enabled, syncEnabled, ctx
+	}(Enabled, SyncInstrumentationEnabled, syncContext)
+
+	printer := NewToBufferPrinter(1024)
+	Enabled, SyncInstrumentationEnabled, syncContext = true, true, NewContext(printer, false)
+
+	var (
+		sender   = common.Address{0x01}
+		coinbase = common.Address{0xcb}
+		applied  = types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1000), 21000, big.NewInt(1), nil)
+		rejected = types.NewTransaction(1, common.Address{0xaa}, big.NewInt(1000), 21000, big.NewInt(1), nil)
+	)
+
+	proposed := NewProposedBlock()
+
+	proposed.StartTransaction(applied, 0, sender)
+	proposed.EndTransaction(&types.Receipt{GasUsed: 21000, CumulativeGasUsed: 21000})
+
+	// A transaction rejected by the miner is not part of the block being built
+	proposed.StartTransaction(rejected, 1, sender).RecordNonceChange(sender, 1, 2)
+	proposed.EndTransaction(nil)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7), GasUsed: 21000, Coinbase: coinbase}).WithBody([]*types.Transaction{applied}, nil)
+	require.NoError(t, proposed.Emit(block, big.NewInt(42), func(ctx *Context) {
+		ctx.RecordBalanceChange(coinbase, big.NewInt(0), big.NewInt(2), RewardMineBlockBalanceChangeReason)
+	}))
+
+	output := printer.Buffer().String()
+	assert.True(t, strings.HasPrefix(output, "FIRE BLOCK_BEGIN 7 "+Hash(block.Hash())+" 0 0\nFIRE BEGIN_BLOCK 7 0 0 . "+Hex(types.Bloom{}.Bytes())+"\nFIRE PROPOSED_BLOCK 7 "+Uint64(proposed.TaskID())+"\n"), output)
+	assert.Contains(t, output, "FIRE BEGIN_APPLY_TRX "+Hash(applied.Hash()))
+	assert.NotContains(t, output, Hash(rejected.Hash()))
+	assert.NotContains(t, output, "NONCE_CHANGE")
+	assert.Contains(t, output, "FIRE BALANCE_CHANGE 0 "+Addr(coinbase))
+	assert.Contains(t, output, "FIRE BLOCK_END 7 "+Hash(block.Hash()))
+}
+
+func TestProposedBlock_Tasks(t *testing.T) {
+	defer func(enabled, syncEnabled bool, ctx *Context) {
+		Enabled, SyncInstrumentationEnabled, syncContext = enabled, syncEnabled, ctx
+	}(Enabled, SyncInstrumentationEnabled, syncContext)
+
+	printer := NewToBufferPrinter(1024)
+	Enabled, SyncInstrumentationEnabled, syncContext = true, true, NewContext(printer, false)
+
+	var (
+		sender = common.Address{0x01}
+		first  = types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1000), 21000, big.NewInt(1), nil)
+		second = types.NewTransaction(0, common.Address{0xbb}, big.NewInt(1000), 21000, big.NewInt(1), nil)
+	)
+
+	// Two sealing tasks building a block concurrently each accumulate their own records
+	firstTask, secondTask := NewProposedBlock(), NewProposedBlock()
+	assert.NotEqual(t, firstTask.TaskID(), secondTask.TaskID())
+
+	firstTask.StartTransaction(first, 0, sender)
+	secondTask.StartTransaction(second, 0, sender)
+	secondTask.EndTransaction(&types.Receipt{GasUsed: 21000, CumulativeGasUsed: 21000})
+	firstTask.EndTransaction(&types.Receipt{GasUsed: 21000, CumulativeGasUsed: 21000})
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7), GasUsed: 21000}).WithBody([]*types.Transaction{second}, nil)
+	require.NoError(t, secondTask.Emit(block, big.NewInt(42), func(ctx *Context) {}))
+
+	output := printer.Buffer().String()
+	assert.Contains(t, output, "FIRE PROPOSED_BLOCK 7 "+Uint64(secondTask.TaskID())+"\n")
+	assert.Contains(t, output, "FIRE BEGIN_APPLY_TRX "+Hash(second.Hash()))
+	assert.NotContains(t, output, Hash(first.Hash()))
+
+	// A released task gives its buffers back, releasing it again is a no-op
+	firstTask.Release()
+	firstTask.Release()
+	secondTask.Release()
+	assert.Nil(t, firstTask.transactions)
+
+	var released *ProposedBlock
+	released.Release()
+	assert.Equal(t, uint64(0), released.TaskID())
+}
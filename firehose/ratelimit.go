@@ -0,0 +1,158 @@
+package firehose
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// SinkRateLimitStatus is the state of the rate limit applied to the Firehose sink, see
+// `SinkRateLimit`.
+type SinkRateLimitStatus struct {
+	// Limit is the amount of bytes written per second to the sink, on average
+	Limit int `json:"limit"`
+	// Burst is the amount of bytes that can be written at once after the sink was idle
+	Burst int `json:"burst"`
+	// Available is the amount of bytes that can be written right now without waiting
+	Available int `json:"available"`
+	// Throttled is true while a write is waiting for the rate limit
+	Throttled bool `json:"throttled"`
+	// ThrottledMs is the total time, in milliseconds, writes waited for the rate limit
+	ThrottledMs uint64 `json:"throttledMs"`
+}
+
+// rateLimitedWriter is a token bucket limiting the bytes per second written to the underlying
+// writer. The bucket holds up to `burst` bytes and is refilled at `limit` bytes per second, a
+// write larger than the bytes available waits for the bucket to refill, writes larger than
+// the burst are split in chunks of at most `burst` bytes.
+//
+// rateLimitedWriter is thread-safe.
+type rateLimitedWriter struct {
+	ctx    context.Context
+	writer io.Writer
+	limit  int
+	burst  int
+
+	lock      sync.Mutex
+	tokens    float64
+	last      time.Time
+	waiting   int
+	throttled time.Duration
+}
+
+// newRateLimitedWriter creates a writer limited to `limit` bytes per second on average and to
+// `burst` bytes at once, the burst defaults to the limit when not positive. A write waiting
+// for the rate limit is aborted with `ErrSinkCanceled` when `ctx` is canceled.
+func newRateLimitedWriter(ctx context.Context, writer io.Writer, limit int, burst int) *rateLimitedWriter {
+	if burst <= 0 {
+		burst = limit
+	}
+
+	return &rateLimitedWriter{
+		ctx:    ctx,
+		writer: writer,
+		limit:  limit,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (w *rateLimitedWriter) Write(in []byte) (int, error) {
+	written := 0
+	for written < len(in) {
+		chunk := in[written:]
+		if len(chunk) > w.burst {
+			chunk = chunk[:w.burst]
+		}
+
+		if err := w.wait(len(chunk)); err != nil {
+			return written, err
+		}
+
+		n, err := w.writer.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// wait takes `size` bytes from the bucket, waiting for it to refill when they are not
+// available. The bytes are taken right away, the bucket going negative, so concurrent
+// writers are served in order.
+func (w *rateLimitedWriter) wait(size int) error {
+	w.lock.Lock()
+	w.refill(time.Now())
+	w.tokens -= float64(size)
+	if w.tokens >= 0 {
+		w.lock.Unlock()
+		return nil
+	}
+
+	delay := time.Duration(-w.tokens / float64(w.limit) * float64(time.Second))
+	w.waiting++
+	w.lock.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-w.ctx.Done():
+		err = ErrSinkCanceled
+	case <-timer.C:
+	}
+
+	w.lock.Lock()
+	w.waiting--
+	w.throttled += delay
+	w.lock.Unlock()
+
+	sinkRateLimitTimer.Update(delay)
+	return err
+}
+
+// refill must be called with the lock held.
+func (w *rateLimitedWriter) refill(now time.Time) {
+	w.tokens += now.Sub(w.last).Seconds() * float64(w.limit)
+	if w.tokens > float64(w.burst) {
+		w.tokens = float64(w.burst)
+	}
+	w.last = now
+}
+
+func (w *rateLimitedWriter) status() *SinkRateLimitStatus {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.refill(time.Now())
+
+	available := 0
+	if w.tokens > 0 {
+		available = int(w.tokens)
+	}
+
+	return &SinkRateLimitStatus{
+		Limit:       w.limit,
+		Burst:       w.burst,
+		Available:   available,
+		Throttled:   w.waiting > 0,
+		ThrottledMs: uint64(w.throttled / time.Millisecond),
+	}
+}
+
+var sinkRateLimiter *rateLimitedWriter
+
+// sinkRateLimitStatus returns the state of the rate limit applied to the Firehose sink, nil
+// when the sink is not rate limited.
+func sinkRateLimitStatus() *SinkRateLimitStatus {
+	if sinkRateLimiter == nil {
+		return nil
+	}
+
+	return sinkRateLimiter.status()
+}
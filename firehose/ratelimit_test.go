@@ -0,0 +1,76 @@
+package firehose
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedWriter(t *testing.T) {
+	sink := bytes.NewBuffer(nil)
+	writer := newRateLimitedWriter(context.Background(), sink, 1000, 100)
+
+	start := time.Now()
+	n, err := writer.Write(bytes.Repeat([]byte{'a'}, 100))
+	require.NoError(t, err)
+	assert.Equal(t, 100, n)
+	assert.Less(t, int64(time.Since(start)), int64(50*time.Millisecond), "the burst must be written right away")
+
+	status := writer.status()
+	assert.Equal(t, 1000, status.Limit)
+	assert.Equal(t, 100, status.Burst)
+	assert.False(t, status.Throttled)
+
+	// Twice the burst at 1000 bytes per second, split in two chunks, the bucket is empty
+	start = time.Now()
+	n, err = writer.Write(bytes.Repeat([]byte{'b'}, 200))
+	require.NoError(t, err)
+	assert.Equal(t, 200, n)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(150*time.Millisecond))
+	assert.Equal(t, 300, sink.Len())
+
+	assert.GreaterOrEqual(t, writer.status().ThrottledMs, uint64(150))
+}
+
+func TestRateLimitedWriter_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := bytes.NewBuffer(nil)
+	writer := newRateLimitedWriter(ctx, sink, 10, 0)
+
+	_, err := writer.Write(bytes.Repeat([]byte{'a'}, 10))
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := writer.Write([]byte("FIRE END_BLOCK\n"))
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool { return writer.status().Throttled }, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Equal(t, ErrSinkCanceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("write waiting for the rate limit was not aborted")
+	}
+	assert.Equal(t, 10, sink.Len())
+}
+
+func TestCurrentSinkStatus(t *testing.T) {
+	defer func(limiter *rateLimitedWriter) { sinkRateLimiter = limiter }(sinkRateLimiter)
+
+	sinkRateLimiter = nil
+	assert.Nil(t, CurrentSinkStatus().RateLimit)
+
+	sinkRateLimiter = newRateLimitedWriter(context.Background(), bytes.NewBuffer(nil), 1000, 0)
+	status := CurrentSinkStatus()
+	require.NotNil(t, status.RateLimit)
+	assert.Equal(t, 1000, status.RateLimit.Burst)
+	assert.Equal(t, 1000, status.RateLimit.Available)
+}
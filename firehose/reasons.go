@@ -0,0 +1,256 @@
+package firehose
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// BalanceChangeReason denotes a reason why a given balance change occurred.
+//
+// **Important!** All valid reasons must be registered through `RegisterBalanceChangeReason`
+// when the package is initialized, see the list below, so they can be enumerated with
+// `AllBalanceChangeReasons`, so `StrictChangeReasons` accepts them and so the self-check
+// reconciles them according to their category.
+type BalanceChangeReason string
+
+// GasChangeReason denotes a reason why a given gas cost was incurred for an operation.
+//
+// **Important!** All valid reasons must be registered through `RegisterGasChangeReason`
+// when the package is initialized, see the list below, so they can be enumerated with
+// `AllGasChangeReasons` and so `StrictChangeReasons` accepts them.
+type GasChangeReason string
+
+// IrregularStateChangeReason denotes why a one-off mutation of the state, out of any
+// transaction and not following the regular rules of the chain (e.g. a hard fork moving
+// balances), was applied, see `Context.RecordIrregularStateChange`.
+//
+// **Important!** All valid reasons must be registered through `RegisterIrregularStateChangeReason`
+// when the package is initialized, see the list below, so `StrictChangeReasons` accepts them.
+type IrregularStateChangeReason string
+
+// AccountDeletionReason denotes why an account was removed from the state when the changes
+// of a transaction were finalized, see `Context.RecordDeletedAccount`.
+//
+// **Important!** All valid reasons must be registered through `RegisterAccountDeletionReason`
+// when the package is initialized, see the list below, so `StrictChangeReasons` accepts them.
+type AccountDeletionReason string
+
+var (
+	balanceChangeReasons        = map[BalanceChangeReason]BalanceChangeCategory{}
+	gasChangeReasons            = map[GasChangeReason]bool{}
+	irregularStateChangeReasons = map[IrregularStateChangeReason]bool{}
+	accountDeletionReasons      = map[AccountDeletionReason]bool{}
+
+	changeReasonRegexp = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+var (
+	RewardMineUncleBalanceChangeReason      = RegisterBalanceChangeReason("reward_mine_uncle", IssuanceBalanceChangeCategory)
+	RewardMineBlockBalanceChangeReason      = RegisterBalanceChangeReason("reward_mine_block", IssuanceBalanceChangeCategory)
+	DaoRefundContractBalanceChangeReason    = RegisterBalanceChangeReason("dao_refund_contract", IrregularBalanceChangeCategory)
+	DaoAdjustBalanceBalanceChangeReason     = RegisterBalanceChangeReason("dao_adjust_balance", IrregularBalanceChangeCategory)
+	TransferBalanceChangeReason             = RegisterBalanceChangeReason("transfer", TransferBalanceChangeCategory)
+	GenesisBalanceBalanceChangeReason       = RegisterBalanceChangeReason("genesis_balance", IssuanceBalanceChangeCategory)
+	GasBuyBalanceChangeReason               = RegisterBalanceChangeReason("gas_buy", FeesBalanceChangeCategory)
+	RewardTransactionFeeBalanceChangeReason = RegisterBalanceChangeReason("reward_transaction_fee", FeesBalanceChangeCategory)
+	GasRefundBalanceChangeReason            = RegisterBalanceChangeReason("gas_refund", FeesBalanceChangeCategory)
+	SuicideRefundBalanceChangeReason        = RegisterBalanceChangeReason("suicide_refund", BurnBalanceChangeCategory)
+	SuicideWithdrawBalanceChangeReason      = RegisterBalanceChangeReason("suicide_withdraw", BurnBalanceChangeCategory)
+
+	// L1DataFeeBalanceChangeReason is the L1 data fee charged to the sender of a transaction
+	// by L2 variants (e.g. OP-stack, ArbOS) for posting its data to L1, and credited to the
+	// L1 fee vault, on top of the L2 execution fee
+	L1DataFeeBalanceChangeReason = RegisterBalanceChangeReason("l1_data_fee", FeesBalanceChangeCategory)
+
+	// SequencerFeeBalanceChangeReason is the L2 execution fee of a transaction paid to the
+	// sequencer fee vault by L2 variants, in place of `RewardTransactionFeeBalanceChangeReason`
+	SequencerFeeBalanceChangeReason = RegisterBalanceChangeReason("sequencer_fee", FeesBalanceChangeCategory)
+
+	// IgnoredBalanceChangeReason is on purpose not registered, balance changes with this
+	// reason are never recorded.
+	IgnoredBalanceChangeReason BalanceChangeReason = "ignored"
+)
+
+var (
+	CallGasChangeReason                = RegisterGasChangeReason("call")
+	CallCodeGasChangeReason            = RegisterGasChangeReason("call_code")
+	CallDataCopyGasChangeReason        = RegisterGasChangeReason("call_data_copy")
+	CodeCopyGasChangeReason            = RegisterGasChangeReason("code_copy")
+	CodeStorageGasChangeReason         = RegisterGasChangeReason("code_storage")
+	ContractCreationGasChangeReason    = RegisterGasChangeReason("contract_creation")
+	ContractCreation2GasChangeReason   = RegisterGasChangeReason("contract_creation2")
+	DelegateCallGasChangeReason        = RegisterGasChangeReason("delegate_call")
+	EventLogGasChangeReason            = RegisterGasChangeReason("event_log")
+	ExtCodeCopyGasChangeReason         = RegisterGasChangeReason("ext_code_copy")
+	IntrinsicGasChangeReason           = RegisterGasChangeReason("intrinsic_gas")
+	MemoryCopyGasChangeReason          = RegisterGasChangeReason("memory_copy")
+	PrecompiledContractGasChangeReason = RegisterGasChangeReason("precompiled_contract")
+	ReturnGasChangeReason              = RegisterGasChangeReason("return")
+	ReturnDataCopyGasChangeReason      = RegisterGasChangeReason("return_data_copy")
+	RevertGasChangeReason              = RegisterGasChangeReason("revert")
+	SelfDestructGasChangeReason        = RegisterGasChangeReason("self_destruct")
+	StateColdAccessGasChangeReason     = RegisterGasChangeReason("state_cold_access")
+	StaticCallGasChangeReason          = RegisterGasChangeReason("static_call")
+	TransientStorageGasChangeReason    = RegisterGasChangeReason("transient_storage")
+
+	// RefundAfterExecutionGasChangeReason to be used for all gas refund operation
+	RefundAfterExecutionGasChangeReason = RegisterGasChangeReason("refund_after_execution")
+
+	// FailedExecutionGasChangeReason to be used for all call failure remaining gas burning operation
+	FailedExecutionGasChangeReason = RegisterGasChangeReason("failed_execution")
+
+	// IgnoredGasChangeReason is on purpose not registered, gas changes with this reason
+	// are never recorded.
+	IgnoredGasChangeReason GasChangeReason = "ignored"
+)
+
+var (
+	// DAOForkIrregularStateChangeReason is the DAO hard fork, draining the DAO accounts into
+	// the refund contract
+	DAOForkIrregularStateChangeReason = RegisterIrregularStateChangeReason("dao_fork")
+)
+
+var (
+	// SuicideAccountDeletionReason is an account that self-destructed during the transaction
+	SuicideAccountDeletionReason = RegisterAccountDeletionReason("suicide")
+
+	// TouchCleanupAccountDeletionReason is an empty account touched during the transaction,
+	// removed by the EIP-158 cleanup
+	TouchCleanupAccountDeletionReason = RegisterAccountDeletionReason("touch_cleanup")
+)
+
+// RegisterBalanceChangeReason registers a valid balance change reason of the given category
+// and returns it, it must only be called while initializing packages (i.e. to define a package
+// variable) and panics if the reason is malformed or already registered, or if the category
+// is unknown.
+func RegisterBalanceChangeReason(reason string, category BalanceChangeCategory) BalanceChangeReason {
+	_, registered := balanceChangeReasons[BalanceChangeReason(reason)]
+	mustBeValidChangeReason("balance", reason, registered)
+
+	if !isBalanceChangeCategory(category) {
+		panic(fmt.Errorf("firehose balance change reason %q has unknown category %q", reason, category))
+	}
+
+	balanceChangeReasons[BalanceChangeReason(reason)] = category
+	return BalanceChangeReason(reason)
+}
+
+// RegisterGasChangeReason registers a valid gas change reason and returns it, it must only
+// be called while initializing packages (i.e. to define a package variable) and panics if
+// the reason is malformed or already registered.
+func RegisterGasChangeReason(reason string) GasChangeReason {
+	mustBeValidChangeReason("gas", reason, gasChangeReasons[GasChangeReason(reason)])
+
+	gasChangeReasons[GasChangeReason(reason)] = true
+	return GasChangeReason(reason)
+}
+
+// RegisterIrregularStateChangeReason registers a valid irregular state change reason and returns
+// it, it must only be called while initializing packages (i.e. to define a package variable) and
+// panics if the reason is malformed or already registered.
+func RegisterIrregularStateChangeReason(reason string) IrregularStateChangeReason {
+	mustBeValidChangeReason("irregular state", reason, irregularStateChangeReasons[IrregularStateChangeReason(reason)])
+
+	irregularStateChangeReasons[IrregularStateChangeReason(reason)] = true
+	return IrregularStateChangeReason(reason)
+}
+
+// RegisterAccountDeletionReason registers a valid account deletion reason and returns it, it
+// must only be called while initializing packages (i.e. to define a package variable) and
+// panics if the reason is malformed or already registered.
+func RegisterAccountDeletionReason(reason string) AccountDeletionReason {
+	mustBeValidChangeReason("account deletion", reason, accountDeletionReasons[AccountDeletionReason(reason)])
+
+	accountDeletionReasons[AccountDeletionReason(reason)] = true
+	return AccountDeletionReason(reason)
+}
+
+func mustBeValidChangeReason(kind string, reason string, registered bool) {
+	if !changeReasonRegexp.MatchString(reason) {
+		panic(fmt.Errorf("firehose %s change reason %q is invalid, it must match %s", kind, reason, changeReasonRegexp))
+	}
+
+	if registered {
+		panic(fmt.Errorf("firehose %s change reason %q is already registered", kind, reason))
+	}
+}
+
+// AllBalanceChangeReasons returns all the registered balance change reasons, sorted
+// alphabetically.
+func AllBalanceChangeReasons() (out []BalanceChangeReason) {
+	for reason := range balanceChangeReasons {
+		out = append(out, reason)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return
+}
+
+// AllGasChangeReasons returns all the registered gas change reasons, sorted alphabetically.
+func AllGasChangeReasons() (out []GasChangeReason) {
+	for reason := range gasChangeReasons {
+		out = append(out, reason)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return
+}
+
+// IsKnown returns true if the reason has been registered.
+func (r BalanceChangeReason) IsKnown() bool {
+	_, found := balanceChangeReasons[r]
+	return found
+}
+
+// Category returns the category of the reason, the empty category if it's not registered.
+func (r BalanceChangeReason) Category() BalanceChangeCategory {
+	return balanceChangeReasons[r]
+}
+
+// IsKnown returns true if the reason has been registered.
+func (r GasChangeReason) IsKnown() bool {
+	return gasChangeReasons[r]
+}
+
+// IsKnown returns true if the reason has been registered.
+func (r IrregularStateChangeReason) IsKnown() bool {
+	return irregularStateChangeReasons[r]
+}
+
+// IsKnown returns true if the reason has been registered.
+func (r AccountDeletionReason) IsKnown() bool {
+	return accountDeletionReasons[r]
+}
+
+func (r BalanceChangeReason) mustBeKnownWhenStrict() string {
+	if StrictChangeReasons && !r.IsKnown() {
+		panic(fmt.Errorf("firehose unknown balance change reason %q, it must be registered through 'RegisterBalanceChangeReason'", string(r)))
+	}
+
+	return string(r)
+}
+
+func (r GasChangeReason) mustBeKnownWhenStrict() string {
+	if StrictChangeReasons && !r.IsKnown() {
+		panic(fmt.Errorf("firehose unknown gas change reason %q, it must be registered through 'RegisterGasChangeReason'", string(r)))
+	}
+
+	return string(r)
+}
+
+func (r IrregularStateChangeReason) mustBeKnownWhenStrict() string {
+	if StrictChangeReasons && !r.IsKnown() {
+		panic(fmt.Errorf("firehose unknown irregular state change reason %q, it must be registered through 'RegisterIrregularStateChangeReason'", string(r)))
+	}
+
+	return string(r)
+}
+
+func (r AccountDeletionReason) mustBeKnownWhenStrict() string {
+	if StrictChangeReasons && !r.IsKnown() {
+		panic(fmt.Errorf("firehose unknown account deletion reason %q, it must be registered through 'RegisterAccountDeletionReason'", string(r)))
+	}
+
+	return string(r)
+}
@@ -0,0 +1,71 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllBalanceChangeReasons(t *testing.T) {
+	reasons := AllBalanceChangeReasons()
+	require.Len(t, reasons, len(balanceChangeReasons))
+	assert.Equal(t, DaoAdjustBalanceBalanceChangeReason, reasons[0])
+	assert.NotContains(t, reasons, IgnoredBalanceChangeReason)
+
+	for _, reason := range reasons {
+		assert.True(t, isBalanceChangeCategory(reason.Category()), "balance change reason %q has no self-check category", reason)
+	}
+}
+
+func TestAllGasChangeReasons(t *testing.T) {
+	reasons := AllGasChangeReasons()
+	require.Len(t, reasons, len(gasChangeReasons))
+	assert.Equal(t, CallGasChangeReason, reasons[0])
+	assert.Contains(t, reasons, RefundAfterExecutionGasChangeReason)
+	assert.NotContains(t, reasons, IgnoredGasChangeReason)
+}
+
+func TestRegisterChangeReason_Invalid(t *testing.T) {
+	assert.PanicsWithError(t, `firehose balance change reason "transfer" is already registered`, func() {
+		RegisterBalanceChangeReason("transfer", TransferBalanceChangeCategory)
+	})
+	assert.PanicsWithError(t, `firehose balance change reason "test_unknown_category" has unknown category "minted"`, func() {
+		RegisterBalanceChangeReason("test_unknown_category", "minted")
+	})
+	assert.PanicsWithError(t, `firehose gas change reason "call" is already registered`, func() {
+		RegisterGasChangeReason("call")
+	})
+	assert.PanicsWithError(t, `firehose gas change reason "Not Valid" is invalid, it must match ^[a-z0-9_]+$`, func() {
+		RegisterGasChangeReason("Not Valid")
+	})
+}
+
+func TestStrictChangeReasons(t *testing.T) {
+	defer func(strict bool) { StrictChangeReasons = strict }(StrictChangeReasons)
+
+	ctx := AcquireTransactionContextWithBuffer(bytes.NewBuffer(nil))
+	defer ReleaseContext(ctx)
+
+	startTestTransaction(ctx, common.Hash{}, 21000)
+	ctx.StartCall(CallTypeCall, "", false, common.Hash{}, false)
+	addr := common.HexToAddress("0x01")
+
+	StrictChangeReasons = false
+	assert.NotPanics(t, func() {
+		ctx.RecordBalanceChange(addr, big.NewInt(1), big.NewInt(2), "unregistered")
+		ctx.RecordGasConsume(10, 1, "unregistered")
+	})
+
+	StrictChangeReasons = true
+	assert.NotPanics(t, func() {
+		ctx.RecordBalanceChange(addr, big.NewInt(1), big.NewInt(2), TransferBalanceChangeReason)
+		ctx.RecordBalanceChange(addr, big.NewInt(1), big.NewInt(2), IgnoredBalanceChangeReason)
+		ctx.RecordGasConsume(10, 1, IntrinsicGasChangeReason)
+	})
+	assert.Panics(t, func() { ctx.RecordBalanceChange(addr, big.NewInt(1), big.NewInt(2), "unregistered") })
+	assert.Panics(t, func() { ctx.RecordGasConsume(10, 1, "unregistered") })
+}
@@ -0,0 +1,82 @@
+package firehose
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReplayPolicy determines what happens to the blocks the node emits again after a restart,
+// blocks it had already emitted completely before it stopped (e.g. the blocks re-imported
+// once the chain head was rewound to the last block with a persisted state).
+type ReplayPolicy string
+
+const (
+	// ReplayPolicyMark emits the replayed blocks, flagged by a `REPLAYED_BLOCK` record, so
+	// that consumers can drop them or use them to check the blocks they already have.
+	ReplayPolicyMark ReplayPolicy = "mark"
+
+	// ReplayPolicySkip does not emit the replayed blocks, the stream resumes right after the
+	// last block completely emitted before the restart.
+	ReplayPolicySkip ReplayPolicy = "skip"
+)
+
+// ParseReplayPolicy parses the received string into a ReplayPolicy, returns an error if the
+// policy is unknown.
+func ParseReplayPolicy(in string) (ReplayPolicy, error) {
+	switch policy := ReplayPolicy(in); policy {
+	case ReplayPolicyMark, ReplayPolicySkip:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("unknown firehose replay policy %q, valid values are %q and %q", in, ReplayPolicyMark, ReplayPolicySkip)
+}
+
+// RecordReplayedBlock flags the block being recorded as a block already emitted completely
+// before the node restarted, see `ReplayPolicy`.
+func (ctx *Context) RecordReplayedBlock(block *types.Block) {
+	if ctx == nil {
+		return
+	}
+
+	if !ctx.inBlock.Load() {
+		panic("recording a replayed block while not in a block scope")
+	}
+
+	ctx.printer.Print("REPLAYED_BLOCK", Uint64(block.NumberU64()))
+
+	if ctx.trace != nil && ctx.trace.block != nil {
+		ctx.trace.block.Replayed = true
+	}
+}
+
+// SkipReplayedBlock is used in place of `FlushBlock` for a replayed block that must not be
+// emitted, see `ReplayPolicySkip`. The data accumulated for the block is dropped, the block is
+// nonetheless tracked as the last emitted one since it was emitted before the restart.
+func (ctx *Context) SkipReplayedBlock() error {
+	if ctx == nil || !Enabled {
+		return nil
+	}
+
+	if ctx.segment != nil {
+		// Its segments are already emitted, the replayed block is completed instead, see
+		// `RecordReplayedBlock`
+		log.Warn("Firehose replayed block emitted in segments cannot be skipped, completing it", "number", ctx.blockNumber, "hash", ctx.blockHash)
+		return ctx.FlushBlock()
+	}
+
+	if v, ok := ctx.printer.(*ToBufferPrinter); ok {
+		v.Reset()
+
+		if !ctx.blockOutOfOrder {
+			if err := syncContext.trackEmittedBlock(ctx.blockNumber, ctx.blockHash); err != nil {
+				ctx.exitBlock()
+				return err
+			}
+		}
+	}
+
+	ctx.exitBlock()
+	return nil
+}
@@ -0,0 +1,181 @@
+package firehose
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// segmentedBlock is a block whose Firehose data is emitted in segments, see
+// `BlockSegmentSize`. At most one block is emitted in segments at a time, the open one is
+// tracked by `openSegmentedBlock`.
+type segmentedBlock struct {
+	number    uint64
+	hash      string
+	segments  uint64
+	lineCount int
+}
+
+var (
+	// openSegmentedBlock is the block whose segments are being emitted, nil when none. It's
+	// guarded by `blockWriteLock`.
+	openSegmentedBlock *segmentedBlock
+
+	// deferredRecords are the records emitted concurrently to block processing while a block
+	// is emitted in segments, written once it's completed, and droppedDeferredRecords the
+	// count of the ones dropped past `maxDeferredRecords`. They're guarded by `blockWriteLock`.
+	deferredRecords        [][]string
+	droppedDeferredRecords int
+)
+
+// maxDeferredRecords is the maximum count of records deferred while a block is emitted in
+// segments, the concurrent records (heartbeats, pending transactions) emitted past it are
+// dropped, a giant block must not make them grow without bound.
+const maxDeferredRecords = 4096
+
+// Segmented returns true if the block of the context is emitted in segments, its Firehose data
+// is then not held in full by the context, see `BlockSegmentSize`.
+func (ctx *Context) Segmented() bool {
+	return ctx != nil && ctx.segment != nil
+}
+
+// maybeEmitSegment emits the data accumulated by the block context as a `BLOCK_SEGMENT` of
+// the block when it's larger than `BlockSegmentSize`. The first segment opens the block with
+// its `BLOCK_BEGIN` marker, the block must then be completed by `FlushBlock`, any other exit
+// aborts it.
+//
+// A block at or below the last emitted block is never segmented, the blocks above it must be
+// undone first which only happens when the block is flushed.
+func (ctx *Context) maybeEmitSegment() {
+	// Only the blocks processed on import, accumulated in the block buffer, are segmented
+	v, ok := ctx.printer.(*ToBufferPrinter)
+	if !ok || v.tuned == nil || v.tuned != syncBuffers.block || BlockSegmentSize <= 0 || v.Err() != nil || v.buffer.Len() < BlockSegmentSize {
+		return
+	}
+
+	if ctx.segment == nil {
+		if last := syncContext.LastEmittedBlock(); last != nil && ctx.blockNumber <= last.Number {
+			return
+		}
+	}
+
+	blockWriteLock.Lock()
+	defer blockWriteLock.Unlock()
+
+	if ctx.segment == nil {
+		abortOpenSegmentedBlock()
+
+		ctx.segment = &segmentedBlock{number: ctx.blockNumber, hash: Hash(ctx.blockHash)}
+		openSegmentedBlock = ctx.segment
+		timestamp, elapsed := markerTimings(ctx.blockStartTime)
+		syncContext.printer.Print("BLOCK_BEGIN", Uint64(ctx.segment.number), ctx.segment.hash, timestamp, elapsed)
+	}
+
+	segment := ctx.segment
+	payload := v.buffer.Bytes()
+	lineCount := bytes.Count(payload, []byte{'\n'})
+
+	syncContext.printer.Write(payload)
+	syncContext.printer.Print("BLOCK_SEGMENT", Uint64(segment.number), segment.hash, Uint64(segment.segments), strconv.Itoa(lineCount))
+
+	segment.segments++
+	segment.lineCount += lineCount
+	v.buffer.Reset()
+
+	blockSegmentsCounter.Inc(1)
+	blockEmissionSummary.addBytes(len(payload))
+	lastBlockWrite.Store(time.Now().UnixNano())
+}
+
+// writeLastSegment completes the block emitted in segments with the remaining `payload` and
+// the `BLOCK_END` marker, whose line count covers all the segments.
+func (ctx *Context) writeLastSegment(payload []byte) error {
+	blockWriteLock.Lock()
+	defer blockWriteLock.Unlock()
+
+	segment := ctx.segment
+	ctx.segment = nil
+
+	if openSegmentedBlock != segment {
+		return fmt.Errorf("block #%d (%s) emitted in segments was aborted before being completed", ctx.blockNumber, ctx.blockHash)
+	}
+
+	syncContext.printer.Write(payload)
+	timestamp, elapsed := markerTimings(ctx.blockStartTime)
+	syncContext.printer.Print("BLOCK_END", Uint64(segment.number), segment.hash, strconv.Itoa(segment.lineCount+bytes.Count(payload, []byte{'\n'})), timestamp, elapsed)
+	closeOpenSegmentedBlock()
+
+	lastBlockWrite.Store(time.Now().UnixNano())
+	return nil
+}
+
+// abortSegments aborts the block of the context if it's emitted in segments, readers discard
+// the segments already emitted.
+func (ctx *Context) abortSegments() {
+	if ctx == nil || ctx.segment == nil {
+		return
+	}
+
+	blockWriteLock.Lock()
+	defer blockWriteLock.Unlock()
+
+	if openSegmentedBlock == ctx.segment {
+		abortOpenSegmentedBlock()
+	}
+	ctx.segment = nil
+}
+
+// abortOpenSegmentedBlock writes the `BLOCK_ABORT` marker of the block being emitted in
+// segments, if any, e.g. a block whose processing failed. It must be called with the
+// `blockWriteLock` held.
+func abortOpenSegmentedBlock() {
+	segment := openSegmentedBlock
+	if segment == nil {
+		return
+	}
+
+	log.Warn("Aborting Firehose block emitted in segments", "number", segment.number, "segments", segment.segments)
+	syncContext.printer.Print("BLOCK_ABORT", Uint64(segment.number), segment.hash)
+	closeOpenSegmentedBlock()
+}
+
+// closeOpenSegmentedBlock writes the records deferred while the block was emitted in segments.
+// It must be called with the `blockWriteLock` held.
+func closeOpenSegmentedBlock() {
+	openSegmentedBlock = nil
+
+	for _, record := range deferredRecords {
+		syncContext.printer.Print(record...)
+	}
+	deferredRecords = nil
+
+	if droppedDeferredRecords > 0 {
+		log.Warn("Dropped Firehose records emitted while a block was emitted in segments", "dropped", droppedDeferredRecords, "max", maxDeferredRecords)
+		droppedDeferredRecords = 0
+	}
+}
+
+// printConcurrent prints a record emitted concurrently to block processing, it's never written
+// in the middle of a block: it waits for the block being written, if any, and is deferred until
+// the block emitted in segments, if any, is completed. At most `maxDeferredRecords` are deferred,
+// the next ones are dropped.
+func (ctx *Context) printConcurrent(input ...string) {
+	blockWriteLock.Lock()
+	defer blockWriteLock.Unlock()
+
+	if openSegmentedBlock != nil && ctx == syncContext {
+		if len(deferredRecords) >= maxDeferredRecords {
+			droppedDeferredRecords++
+			deferredRecordsDropsCounter.Inc(1)
+			return
+		}
+
+		deferredRecords = append(deferredRecords, input)
+		return
+	}
+
+	ctx.printer.Print(input...)
+}
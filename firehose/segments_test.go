@@ -0,0 +1,98 @@
+package firehose
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushBlock_Segments(t *testing.T) {
+	defer func(enabled, syncEnabled bool, ctx *Context, segmentSize int) {
+		Enabled, SyncInstrumentationEnabled, syncContext, BlockSegmentSize = enabled, syncEnabled, ctx, segmentSize
+	}(Enabled, SyncInstrumentationEnabled, syncContext, BlockSegmentSize)
+
+	withBuffers(t, 1024, 1024, 0)
+
+	printer := NewToBufferPrinter(1024)
+	Enabled, SyncInstrumentationEnabled, syncContext, BlockSegmentSize = true, true, NewContext(printer, false), 1000
+
+	block1 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	block2 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), ParentHash: block1.Hash()})
+
+	txContext := AcquireTransactionContextWithBuffer(TxSyncBuffer)
+	defer ReleaseContext(txContext)
+
+	// process records the transactions of `data` in a block context, returned before the
+	// block is ended
+	process := func(block *types.Block, data ...string) *Context {
+		ctx := NewBlockContextWithBuffer(BlockSyncBuffer)
+		ctx.StartBlock(block)
+		for _, trx := range data {
+			txContext.printer.Print("DATA", trx)
+			ctx.FlushTransaction(txContext)
+		}
+
+		return ctx
+	}
+
+	kinds := func() (kinds []string) {
+		for _, line := range strings.Split(strings.TrimSpace(printer.Buffer().String()), "\n") {
+			kinds = append(kinds, strings.Fields(line)[1])
+		}
+		printer.Reset()
+
+		return kinds
+	}
+
+	// The segments are emitted as the block is processed, the concurrent records are deferred
+	ctx := process(block1, strings.Repeat("a", 1000), "b", strings.Repeat("c", 1000))
+	require.True(t, ctx.Segmented())
+	syncContext.RecordPendingDrop(common.HexToHash("0x01"), ReplacedPendingDropReason)
+	assert.Equal(t, []string{"BLOCK_BEGIN", "BEGIN_BLOCK", "DATA", "BLOCK_SEGMENT", "DATA", "DATA", "BLOCK_SEGMENT"}, kinds())
+
+	ctx.EndBlock(block1, nil)
+	require.NoError(t, ctx.FlushBlock())
+	assert.False(t, ctx.Segmented())
+
+	output := printer.Buffer().String()
+	assert.Contains(t, output, "FIRE BLOCK_END 1 "+Hash(block1.Hash())+" 5 0 0\n")
+	assert.Equal(t, []string{"END_BLOCK", "BLOCK_END", "PENDING_DROP"}, kinds())
+	assert.Equal(t, &EmittedBlock{Number: 1, Hash: block1.Hash()}, syncContext.LastEmittedBlock())
+
+	// A block whose processing failed is aborted by the next block emitted
+	process(block2, strings.Repeat("a", 1000))
+	assert.Equal(t, []string{"BLOCK_BEGIN", "BEGIN_BLOCK", "DATA", "BLOCK_SEGMENT"}, kinds())
+
+	ctx = process(block2, "b")
+	require.False(t, ctx.Segmented())
+	ctx.EndBlock(block2, nil)
+	require.NoError(t, ctx.FlushBlock())
+	assert.Equal(t, []string{"BLOCK_ABORT", "BLOCK_BEGIN", "BEGIN_BLOCK", "DATA", "END_BLOCK", "BLOCK_END"}, kinds())
+
+	// A block at or below the last emitted one is not segmented, it must be undone first
+	ctx = process(block2, strings.Repeat("a", 1000))
+	assert.False(t, ctx.Segmented())
+	assert.Empty(t, printer.Buffer().String())
+
+	// The records deferred while a block is emitted in segments are capped
+	block3 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(3), ParentHash: block2.Hash()})
+	ctx = process(block3, strings.Repeat("a", 1000))
+	require.True(t, ctx.Segmented())
+	for i := 0; i < maxDeferredRecords+10; i++ {
+		syncContext.RecordPendingDrop(common.HexToHash("0x01"), ReplacedPendingDropReason)
+	}
+	assert.Len(t, deferredRecords, maxDeferredRecords)
+	assert.Equal(t, 10, droppedDeferredRecords)
+	printer.Reset()
+
+	ctx.EndBlock(block3, nil)
+	require.NoError(t, ctx.FlushBlock())
+	assert.Equal(t, maxDeferredRecords, strings.Count(printer.Buffer().String(), "FIRE PENDING_DROP "))
+	assert.Nil(t, deferredRecords)
+	assert.Zero(t, droppedDeferredRecords)
+}
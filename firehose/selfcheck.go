@@ -0,0 +1,226 @@
+package firehose
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SelfCheckMode determines if and how the Firehose instrumentation verifies, at the end of
+// each block, that the recorded balance and gas changes reconcile with the block accounting.
+// It's used to catch instrumentation regressions when rebasing on upstream Geth.
+type SelfCheckMode string
+
+const (
+	// SelfCheckOff disables the self-check entirely, this is the default
+	SelfCheckOff SelfCheckMode = "off"
+
+	// SelfCheckLog logs an error for each block that does not reconcile
+	SelfCheckLog SelfCheckMode = "log"
+
+	// SelfCheckAbort halts the node on the first block that does not reconcile
+	SelfCheckAbort SelfCheckMode = "abort"
+)
+
+// ParseSelfCheckMode parses the received string into a SelfCheckMode, returns an error
+// if the mode is unknown.
+func ParseSelfCheckMode(in string) (SelfCheckMode, error) {
+	switch mode := SelfCheckMode(in); mode {
+	case SelfCheckOff, SelfCheckLog, SelfCheckAbort:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown firehose self-check mode %q, valid values are %q, %q and %q", in, SelfCheckOff, SelfCheckLog, SelfCheckAbort)
+}
+
+// BalanceChangeCategory classifies each balance change reason by how it affects the total
+// supply of Ether, the self-check reconciles the balance changes of a block by category. Each
+// reason is classified when registered, see `RegisterBalanceChangeReason`.
+type BalanceChangeCategory string
+
+const (
+	// IssuanceBalanceChangeCategory is for balance changes creating new Ether (rewards, genesis)
+	IssuanceBalanceChangeCategory BalanceChangeCategory = "issuance"
+
+	// BurnBalanceChangeCategory is for balance changes that can only destroy Ether (self-destruct
+	// to self)
+	BurnBalanceChangeCategory BalanceChangeCategory = "burn"
+
+	// TransferBalanceChangeCategory is for value moved between accounts, it must net to 0
+	TransferBalanceChangeCategory BalanceChangeCategory = "transfer"
+
+	// FeesBalanceChangeCategory is for gas bought by the sender, refunded to it and paid to the
+	// miner, it must net to 0 since fees are not burned in this fork
+	FeesBalanceChangeCategory BalanceChangeCategory = "fees"
+
+	// IrregularBalanceChangeCategory is for hard-fork irregular state changes, they must net to 0
+	IrregularBalanceChangeCategory BalanceChangeCategory = "irregular"
+)
+
+var balanceChangeCategories = []BalanceChangeCategory{
+	IssuanceBalanceChangeCategory,
+	BurnBalanceChangeCategory,
+	TransferBalanceChangeCategory,
+	FeesBalanceChangeCategory,
+	IrregularBalanceChangeCategory,
+}
+
+func isBalanceChangeCategory(category BalanceChangeCategory) bool {
+	for _, known := range balanceChangeCategories {
+		if category == known {
+			return true
+		}
+	}
+	return false
+}
+
+// blockAccounting tallies the recorded balance and gas changes of a block, or of a single
+// transaction when held by a transaction context, in which case it's merged in the block's
+// accounting when the transaction is flushed.
+type blockAccounting struct {
+	balanceDeltas map[BalanceChangeReason]*big.Int
+
+	gasUsed      uint64
+	expectedFees *big.Int
+
+	// Transaction state
+	txGasPrice *big.Int
+}
+
+func newBlockAccounting() *blockAccounting {
+	return &blockAccounting{
+		balanceDeltas: make(map[BalanceChangeReason]*big.Int),
+		expectedFees:  new(big.Int),
+	}
+}
+
+func (a *blockAccounting) recordBalanceChange(oldBalance, newBalance *big.Int, reason BalanceChangeReason) {
+	delta, found := a.balanceDeltas[reason]
+	if !found {
+		delta = new(big.Int)
+		a.balanceDeltas[reason] = delta
+	}
+
+	if newBalance != nil {
+		delta.Add(delta, newBalance)
+	}
+
+	if oldBalance != nil {
+		delta.Sub(delta, oldBalance)
+	}
+}
+
+func (a *blockAccounting) startTransaction(gasPrice *big.Int) {
+	a.txGasPrice = gasPrice
+}
+
+func (a *blockAccounting) endTransaction(gasUsed uint64) {
+	a.gasUsed += gasUsed
+
+	if a.txGasPrice != nil {
+		a.expectedFees.Add(a.expectedFees, new(big.Int).Mul(new(big.Int).SetUint64(gasUsed), a.txGasPrice))
+	}
+
+	a.txGasPrice = nil
+}
+
+func (a *blockAccounting) merge(other *blockAccounting) {
+	for reason, otherDelta := range other.balanceDeltas {
+		a.recordBalanceChange(nil, otherDelta, reason)
+	}
+
+	a.gasUsed += other.gasUsed
+	a.expectedFees.Add(a.expectedFees, other.expectedFees)
+}
+
+// verify reconciles the accounting against the block header and returns a description
+// of each mismatch found, nil if the block reconciles.
+func (a *blockAccounting) verify(header *types.Header) (mismatches []string) {
+	byCategory := map[BalanceChangeCategory]*big.Int{}
+	for _, category := range balanceChangeCategories {
+		byCategory[category] = new(big.Int)
+	}
+
+	reasons := make([]string, 0, len(a.balanceDeltas))
+	for reason := range a.balanceDeltas {
+		reasons = append(reasons, string(reason))
+	}
+	sort.Strings(reasons)
+
+	for _, reason := range reasons {
+		delta := a.balanceDeltas[BalanceChangeReason(reason)]
+
+		category := BalanceChangeReason(reason).Category()
+		if category == "" {
+			mismatches = append(mismatches, fmt.Sprintf("balance change reason %q is not registered, its delta %s cannot be reconciled", reason, delta))
+			continue
+		}
+
+		byCategory[category].Add(byCategory[category], delta)
+	}
+
+	if delta := byCategory[TransferBalanceChangeCategory]; delta.Sign() != 0 {
+		mismatches = append(mismatches, fmt.Sprintf("value transferred does not net to 0 but to %s", delta))
+	}
+
+	if delta := byCategory[FeesBalanceChangeCategory]; delta.Sign() != 0 {
+		mismatches = append(mismatches, fmt.Sprintf("gas bought, refunded and paid to miner does not net to 0 but to %s", delta))
+	}
+
+	if delta := byCategory[IrregularBalanceChangeCategory]; delta.Sign() != 0 {
+		mismatches = append(mismatches, fmt.Sprintf("irregular state changes do not net to 0 but to %s", delta))
+	}
+
+	if delta := byCategory[BurnBalanceChangeCategory]; delta.Sign() > 0 {
+		mismatches = append(mismatches, fmt.Sprintf("self-destructs created %s instead of only burning", delta))
+	}
+
+	if issuance := byCategory[IssuanceBalanceChangeCategory]; issuance.Sign() < 0 {
+		mismatches = append(mismatches, fmt.Sprintf("rewards destroyed %s instead of only issuing", issuance))
+	}
+
+	// The L2 variants pay the execution fees to the sequencer fee vault instead of the miner
+	fees := a.balanceDeltas[RewardTransactionFeeBalanceChangeReason]
+	if sequencerFees := a.balanceDeltas[SequencerFeeBalanceChangeReason]; sequencerFees != nil {
+		if fees == nil {
+			fees = sequencerFees
+		} else {
+			fees = new(big.Int).Add(fees, sequencerFees)
+		}
+	}
+	if fees != nil && fees.Cmp(a.expectedFees) != 0 {
+		mismatches = append(mismatches, fmt.Sprintf("transaction fees paid to miner %s do not match gas used times gas price %s", fees, a.expectedFees))
+	}
+
+	if a.gasUsed != header.GasUsed {
+		mismatches = append(mismatches, fmt.Sprintf("transactions gas used %d does not match header gas used %d", a.gasUsed, header.GasUsed))
+	}
+
+	return mismatches
+}
+
+// selfCheckBlock verifies the block's accounting, reporting mismatches according to the
+// active `SelfCheck` mode.
+func (ctx *Context) selfCheckBlock(block *types.Block) {
+	if ctx.accounting == nil {
+		return
+	}
+
+	mismatches := ctx.accounting.verify(block.Header())
+	if len(mismatches) == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("Firehose self-check failed for block #%d (%s), recorded changes do not reconcile with block accounting:\n  - %s",
+		block.NumberU64(), block.Hash(), strings.Join(mismatches, "\n  - "))
+
+	if SelfCheck == SelfCheckAbort {
+		log.Crit(message)
+	}
+
+	log.Error(message)
+}
@@ -0,0 +1,102 @@
+package firehose
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockAccounting_verify(t *testing.T) {
+	gasPrice := big.NewInt(10)
+
+	newTransaction := func(gasLimit, gasUsed int64, value int64, feeOverride int64) *blockAccounting {
+		tx := newBlockAccounting()
+		tx.startTransaction(gasPrice)
+		tx.recordBalanceChange(big.NewInt(gasLimit*10), big.NewInt(0), "gas_buy")
+		tx.recordBalanceChange(big.NewInt(value), big.NewInt(0), "transfer")
+		tx.recordBalanceChange(big.NewInt(0), big.NewInt(value), "transfer")
+		tx.recordBalanceChange(big.NewInt(0), big.NewInt((gasLimit-gasUsed)*10), "gas_refund")
+
+		fee := gasUsed * 10
+		if feeOverride != 0 {
+			fee = feeOverride
+		}
+		tx.recordBalanceChange(big.NewInt(0), big.NewInt(fee), "reward_transaction_fee")
+		tx.endTransaction(uint64(gasUsed))
+
+		return tx
+	}
+
+	tests := []struct {
+		name           string
+		transactions   []*blockAccounting
+		reward         int64
+		headerGasUsed  uint64
+		wantMismatches int
+	}{
+		{"empty block", nil, 2, 0, 0},
+		{"reconciles", []*blockAccounting{newTransaction(30000, 21000, 5, 0), newTransaction(50000, 40000, 0, 0)}, 2, 61000, 0},
+		{"gas used mismatch", []*blockAccounting{newTransaction(30000, 21000, 5, 0)}, 2, 42000, 1},
+		{"fees mismatch", []*blockAccounting{newTransaction(30000, 21000, 5, 1)}, 2, 21000, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := newBlockAccounting()
+			for _, tx := range tt.transactions {
+				block.merge(tx)
+			}
+			block.recordBalanceChange(big.NewInt(0), big.NewInt(tt.reward), "reward_mine_block")
+
+			mismatches := block.verify(&types.Header{GasUsed: tt.headerGasUsed})
+			assert.Len(t, mismatches, tt.wantMismatches, "mismatches %v", mismatches)
+		})
+	}
+}
+
+func TestBlockAccounting_verifyRollupFees(t *testing.T) {
+	// The sender pays the L1 data fee on top of the gas, credited to the L1 fee vault, the
+	// execution fee is paid to the sequencer fee vault
+	tx := newBlockAccounting()
+	tx.startTransaction(big.NewInt(10))
+	tx.recordBalanceChange(big.NewInt(300000), big.NewInt(0), "gas_buy")
+	tx.recordBalanceChange(big.NewInt(1000), big.NewInt(0), "l1_data_fee")
+	tx.recordBalanceChange(big.NewInt(0), big.NewInt(90000), "gas_refund")
+	tx.recordBalanceChange(big.NewInt(0), big.NewInt(1000), "l1_data_fee")
+	tx.recordBalanceChange(big.NewInt(0), big.NewInt(210000), "sequencer_fee")
+	tx.endTransaction(21000)
+
+	block := newBlockAccounting()
+	block.merge(tx)
+
+	assert.Empty(t, block.verify(&types.Header{GasUsed: 21000}))
+}
+
+var (
+	testCustomRewardBalanceChangeReason = RegisterBalanceChangeReason("test_custom_reward", IssuanceBalanceChangeCategory)
+	testTreasuryFeeBalanceChangeReason  = RegisterBalanceChangeReason("test_self_check_treasury_fee", TransferBalanceChangeCategory)
+)
+
+func TestBlockAccounting_verifyCustomRewardReasons(t *testing.T) {
+	// A variant's reward scheme issuing a custom reward then redirecting part of it to a
+	// treasury, both reasons reconcile according to the category they were registered with
+	block := newBlockAccounting()
+	block.recordBalanceChange(big.NewInt(0), big.NewInt(100), testCustomRewardBalanceChangeReason)
+	block.recordBalanceChange(big.NewInt(100), big.NewInt(90), testTreasuryFeeBalanceChangeReason)
+	block.recordBalanceChange(big.NewInt(0), big.NewInt(10), testTreasuryFeeBalanceChangeReason)
+
+	assert.Empty(t, block.verify(&types.Header{}))
+
+	// The treasury fee not netting to 0 is reported as any other value transfer
+	block.recordBalanceChange(big.NewInt(0), big.NewInt(1), testTreasuryFeeBalanceChangeReason)
+	assert.Equal(t, []string{"value transferred does not net to 0 but to 1"}, block.verify(&types.Header{}))
+}
+
+func TestBlockAccounting_verifyUnclassifiedReason(t *testing.T) {
+	block := newBlockAccounting()
+	block.recordBalanceChange(big.NewInt(0), big.NewInt(1), "unknown_reason")
+
+	assert.Len(t, block.verify(&types.Header{}), 1)
+}
@@ -0,0 +1,65 @@
+package firehose
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SignatureScheme denotes how the hash signed by the sender of a transaction is computed.
+type SignatureScheme string
+
+const (
+	// HomesteadSignatureScheme is used by the legacy transactions not protected against
+	// replays, the signed hash does not commit to any chain id.
+	HomesteadSignatureScheme SignatureScheme = "homestead"
+	// EIP155SignatureScheme is used by the legacy transactions protected against replays, the
+	// chain id being folded into their `v` value.
+	EIP155SignatureScheme SignatureScheme = "eip155"
+	// TypedSignatureScheme is used by the EIP-2718 typed transactions, the signed hash being
+	// the one of their type prefixed payload.
+	TypedSignatureScheme SignatureScheme = "typed"
+)
+
+var (
+	big27 = big.NewInt(27)
+	big35 = big.NewInt(35)
+)
+
+// transactionSignature returns the signature scheme of `tx` along with the y-parity of its
+// signature, i.e. its `v` value stripped of the offset and chain id of its scheme.
+func transactionSignature(tx *types.Transaction) (scheme SignatureScheme, yParity uint64) {
+	v, _, _ := tx.RawSignatureValues()
+	if v == nil {
+		v = new(big.Int)
+	}
+
+	switch {
+	case tx.Type() != types.LegacyTxType:
+		return TypedSignatureScheme, v.Uint64()
+
+	case tx.Protected():
+		// The `v` value of a protected transaction is `chainId * 2 + 35 + yParity`
+		offset := new(big.Int).Add(new(big.Int).Lsh(tx.ChainId(), 1), big35)
+		return EIP155SignatureScheme, new(big.Int).Sub(v, offset).Uint64()
+
+	case v.Cmp(big27) >= 0:
+		return HomesteadSignatureScheme, new(big.Int).Sub(v, big27).Uint64()
+	}
+
+	return HomesteadSignatureScheme, v.Uint64()
+}
+
+// recordTrxSignature records the signature scheme and the y-parity of the signature of `tx`,
+// the transaction being applied, so its sender recovery can be checked without knowing the
+// signing rules of the chain.
+func (ctx *Context) recordTrxSignature(tx *types.Transaction) {
+	scheme, yParity := transactionSignature(tx)
+
+	ctx.printer.Print("TRX_SIGNATURE", string(scheme), Uint64(yParity))
+
+	if ctx.trace != nil && ctx.trace.trx != nil {
+		ctx.trace.trx.SignatureScheme = string(scheme)
+		ctx.trace.trx.YParity = yParity
+	}
+}
@@ -0,0 +1,52 @@
+package firehose
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionSignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	chainID := big.NewInt(1337)
+	legacy := &types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1), Gas: 21000, To: &common.Address{0xaa}, Value: big.NewInt(1)}
+	typed := &types.AccessListTx{ChainID: chainID, Nonce: 1, GasPrice: big.NewInt(1), Gas: 21000, To: &common.Address{0xaa}, Value: big.NewInt(1)}
+
+	tests := []struct {
+		name   string
+		signer types.Signer
+		tx     types.TxData
+		scheme SignatureScheme
+	}{
+		{"homestead", types.HomesteadSigner{}, legacy, HomesteadSignatureScheme},
+		{"eip155", types.NewEIP155Signer(chainID), legacy, EIP155SignatureScheme},
+		{"typed", types.NewEIP2930Signer(chainID), typed, TypedSignatureScheme},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx, err := types.SignNewTx(key, test.signer, test.tx)
+			require.NoError(t, err)
+
+			// The y-parity is the recovery id of the signature over the hash of its scheme
+			sig, err := crypto.Sign(test.signer.Hash(tx).Bytes(), key)
+			require.NoError(t, err)
+
+			scheme, yParity := transactionSignature(tx)
+			assert.Equal(t, test.scheme, scheme)
+			assert.Equal(t, uint64(sig[crypto.RecoveryIDOffset]), yParity)
+		})
+	}
+
+	// An unsigned transaction does not underflow
+	scheme, yParity := transactionSignature(types.NewTx(legacy))
+	assert.Equal(t, HomesteadSignatureScheme, scheme)
+	assert.Equal(t, uint64(0), yParity)
+}
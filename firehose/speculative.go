@@ -0,0 +1,99 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SpeculativeExecution captures the Firehose records and the typed trace of a single
+// transaction, or call, executed outside of the import of a block, for example on top of the
+// head state to preview its effects. It's the stable entry point for projects embedding this
+// fork as a library to capture traces programmatically:
+//
+//  1. Create the execution with `NewSpeculativeExecution` and `Release` it once done.
+//  2. Start the transaction with `StartTransaction`, or `StartCall` for a message.
+//  3. Attach `Context()` to the EVM executing it, e.g. through `core.ApplyTransaction` or
+//     the `FirehoseContext` field of `vm.TxContext`.
+//  4. End the transaction with its receipt with `EndTransaction`.
+//  5. Extract the typed trace with `Trace` or the records with `Records`.
+//
+// Firehose must be enabled (see `Enabled`), nothing is recorded otherwise. An execution is
+// not thread-safe and captures a single transaction.
+type SpeculativeExecution struct {
+	ctx    *Context
+	buffer *bytes.Buffer
+}
+
+// NewSpeculativeExecution creates a speculative execution, its context is taken from the
+// pool of transaction contexts and must be given back through `Release`.
+func NewSpeculativeExecution() *SpeculativeExecution {
+	buffer := bytes.NewBuffer(nil)
+	ctx := AcquireTransactionContextWithBuffer(buffer)
+	ctx.EnableBlockTrace()
+
+	return &SpeculativeExecution{ctx: ctx, buffer: buffer}
+}
+
+// Context returns the context to attach to the EVM executing the transaction.
+func (e *SpeculativeExecution) Context() *Context {
+	return e.ctx
+}
+
+// StartTransaction starts recording `tx`, sent by `from`.
+func (e *SpeculativeExecution) StartTransaction(tx *types.Transaction, from common.Address) {
+	if !e.ctx.Enabled() {
+		return
+	}
+
+	// London fork not active in this branch yet, replace by `header.BaseFee` instead of `nil` when it's the case (and remove this comment)
+	e.ctx.StartTransaction(tx, 0, nil)
+	e.ctx.RecordTrxFrom(from)
+}
+
+// StartCall starts recording a message, like the ones of `eth_call`, that is not a signed
+// transaction. It's recorded as a transaction without hash nor signature.
+func (e *SpeculativeExecution) StartCall(from common.Address, to *common.Address, value *big.Int, gas uint64, gasPrice *big.Int, nonce uint64, data []byte) {
+	if !e.ctx.Enabled() {
+		return
+	}
+
+	e.ctx.StartTransactionRaw(common.Hash{}, to, value, nil, nil, nil, gas, gasPrice, nonce, data, nil, nil, nil, 0, 0)
+	e.ctx.RecordTrxFrom(from)
+}
+
+// EndTransaction ends the transaction started by `StartTransaction` or `StartCall` with its
+// `receipt`.
+func (e *SpeculativeExecution) EndTransaction(receipt *types.Receipt) {
+	if !e.ctx.Enabled() {
+		return
+	}
+
+	e.ctx.EndTransaction(receipt)
+}
+
+// Trace returns the typed trace of the transaction, nil until it's ended. It must be treated
+// as read-only, it remains valid once the execution is released.
+func (e *SpeculativeExecution) Trace() *TransactionTrace {
+	traces := e.ctx.TransactionTraces()
+	if len(traces) == 0 {
+		return nil
+	}
+
+	return traces[len(traces)-1]
+}
+
+// Records returns the records of the transaction, from `BEGIN_APPLY_TRX` up to
+// `END_APPLY_TRX`, as they would be emitted. The returned slice is owned by the caller.
+func (e *SpeculativeExecution) Records() []byte {
+	return append([]byte(nil), e.buffer.Bytes()...)
+}
+
+// Release gives the context of the execution back to the pool, the context must not be
+// used anymore, the trace and the records extracted remain valid.
+func (e *SpeculativeExecution) Release() {
+	ReleaseContext(e.ctx)
+	e.ctx = nil
+}
@@ -0,0 +1,53 @@
+package firehose
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeculativeExecution_Call(t *testing.T) {
+	defer func(enabled bool) { Enabled = enabled }(Enabled)
+	Enabled = true
+
+	var (
+		from = common.Address{0x01}
+		to   = common.Address{0xaa}
+	)
+
+	execution := NewSpeculativeExecution()
+	execution.StartCall(from, &to, big.NewInt(1000), 50_000, big.NewInt(1), 3, []byte{0x01})
+	execution.EndTransaction(&types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21_000})
+
+	trace := execution.Trace()
+	require.NotNil(t, trace)
+	assert.Equal(t, common.Hash{}, trace.Hash)
+	assert.Equal(t, from, trace.From)
+	assert.Equal(t, uint64(21_000), trace.GasUsed)
+
+	records := string(execution.Records())
+	execution.Release()
+
+	assert.True(t, strings.HasPrefix(records, "FIRE BEGIN_APPLY_TRX "), records)
+	assert.Contains(t, records, "FIRE END_APPLY_TRX ")
+	assert.Equal(t, records, string(execution.buffer.Bytes()), "released execution must keep its records")
+}
+
+func TestSpeculativeExecution_Disabled(t *testing.T) {
+	defer func(enabled bool) { Enabled = enabled }(Enabled)
+	Enabled = false
+
+	execution := NewSpeculativeExecution()
+	defer execution.Release()
+
+	execution.StartTransaction(types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1000), 21000, big.NewInt(1), nil), common.Address{0x01})
+	execution.EndTransaction(&types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21_000})
+
+	assert.Nil(t, execution.Trace())
+	assert.Empty(t, execution.Records())
+}
@@ -0,0 +1,95 @@
+package firehose
+
+import (
+	"errors"
+)
+
+// ErrEmptyStack is returned by `Stack.Pop` and `Stack.Peek` when the stack
+// does not hold any element.
+var ErrEmptyStack = errors.New("stack is empty")
+
+// Stack is a typed LIFO stack backed by a slice. The zero value is an empty
+// stack ready to use.
+//
+// Popping an element does not release the underlying storage, so a Stack
+// that is `Reset` and re-used across transactions does not allocate once it
+// reached its deepest size.
+//
+// Stack is **not** thread-safe.
+type Stack[T any] struct {
+	items []T
+}
+
+// NewStack creates a new Stack pre-allocating room for `capacity` elements.
+func NewStack[T any](capacity int) *Stack[T] {
+	return &Stack[T]{items: make([]T, 0, capacity)}
+}
+
+// Len returns the number of elements in the stack.
+func (s *Stack[T]) Len() int {
+	return len(s.items)
+}
+
+// Push adds an element on top of the stack.
+func (s *Stack[T]) Push(value T) {
+	s.items = append(s.items, value)
+}
+
+// Pop removes and returns the top element of the stack, returns `ErrEmptyStack`
+// if the stack is empty.
+func (s *Stack[T]) Pop() (out T, err error) {
+	if len(s.items) == 0 {
+		return out, ErrEmptyStack
+	}
+
+	last := len(s.items) - 1
+	out = s.items[last]
+
+	// Clear the slot so that the popped value can be garbage collected if it holds pointers
+	var zero T
+	s.items[last] = zero
+	s.items = s.items[:last]
+
+	return out, nil
+}
+
+// Peek returns the top element of the stack without removing it, returns
+// `ErrEmptyStack` if the stack is empty.
+func (s *Stack[T]) Peek() (out T, err error) {
+	if len(s.items) == 0 {
+		return out, ErrEmptyStack
+	}
+
+	return s.items[len(s.items)-1], nil
+}
+
+// MustPop is like `Pop` but panics if the stack is empty.
+func (s *Stack[T]) MustPop() T {
+	popped, err := s.Pop()
+	if err != nil {
+		panic("at least one element must exist in the index stack at this point")
+	}
+
+	return popped
+}
+
+// MustPeek is like `Peek` but panics if the stack is empty.
+func (s *Stack[T]) MustPeek() T {
+	peeked, err := s.Peek()
+	if err != nil {
+		panic("at least one element must exist in the index stack at this point")
+	}
+
+	return peeked
+}
+
+// Reset removes all elements from the stack while retaining the underlying storage
+// for future re-use.
+func (s *Stack[T]) Reset() {
+	var zero T
+	for i := range s.items {
+		s.items[i] = zero
+	}
+
+	s.items = s.items[:0]
+}
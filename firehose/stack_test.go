@@ -0,0 +1,108 @@
+package firehose
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStack(t *testing.T) {
+	s := &Stack[string]{}
+	assert.Equal(t, 0, s.Len())
+
+	_, err := s.Pop()
+	assert.Equal(t, ErrEmptyStack, err)
+
+	_, err = s.Peek()
+	assert.Equal(t, ErrEmptyStack, err)
+
+	s.Push("0")
+	s.Push("1")
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, "1", s.MustPeek())
+
+	popped, err := s.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, "1", popped)
+
+	peeked, err := s.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "0", peeked)
+
+	assert.Equal(t, "0", s.MustPop())
+	assert.Equal(t, 0, s.Len())
+
+	assert.Panics(t, func() { s.MustPop() })
+	assert.Panics(t, func() { s.MustPeek() })
+
+	s.Push("a")
+	s.Push("b")
+	s.Reset()
+	assert.Equal(t, 0, s.Len())
+}
+
+var callIndexes = func() (out []string) {
+	for i := 0; i < 1024; i++ {
+		out = append(out, strconv.FormatUint(uint64(i), 10))
+	}
+	return
+}()
+
+// boxedStack mimics the previous `interface{}` based linked stack implementation
+// and is kept only to compare allocations against `Stack`.
+type boxedStack struct {
+	top *boxedNode
+}
+
+type boxedNode struct {
+	value interface{}
+	prev  *boxedNode
+}
+
+func (s *boxedStack) Push(value interface{}) {
+	s.top = &boxedNode{value, s.top}
+}
+
+func (s *boxedStack) Pop() interface{} {
+	if s.top == nil {
+		return nil
+	}
+
+	value := s.top.value
+	s.top = s.top.prev
+	return value
+}
+
+func BenchmarkStack_DeepCallTrace(b *testing.B) {
+	s := NewStack[string](16)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Reset()
+		for _, index := range callIndexes {
+			s.Push(index)
+		}
+
+		for range callIndexes {
+			s.MustPop()
+		}
+	}
+}
+
+func BenchmarkBoxedStack_DeepCallTrace(b *testing.B) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := &boxedStack{}
+		for _, index := range callIndexes {
+			s.Push(index)
+		}
+
+		for range callIndexes {
+			_ = s.Pop().(string)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package firehose
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// emissionSummary accumulates the blocks emitted since the last summary log line, see
+// `SummaryInterval`.
+type emissionSummary struct {
+	lock sync.Mutex
+
+	start     time.Time
+	blocks    uint64
+	bytes     uint64
+	lastBlock uint64
+}
+
+// blockEmissionSummary is the summary of the blocks emitted through the sync context.
+var blockEmissionSummary = &emissionSummary{}
+
+// summaryStats is the throughput of the blocks emitted over a summary interval.
+type summaryStats struct {
+	blocks         uint64
+	lastBlock      uint64
+	elapsed        time.Duration
+	blocksPerSec   float64
+	bytesPerSec    float64
+	averagePayload uint64
+}
+
+// addBytes accounts `size` bytes of Firehose data written before the block they belong to is
+// completed, i.e. the segments of a block emitted in segments, see `BlockSegmentSize`.
+func (s *emissionSummary) addBytes(size int) {
+	if SummaryInterval == 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.bytes += uint64(size)
+}
+
+// addBlock accounts the block `number` completed with its last `size` bytes of Firehose data,
+// the stats of the blocks since the last summary are returned once `SummaryInterval` blocks
+// were emitted, nil otherwise.
+func (s *emissionSummary) addBlock(number uint64, size int, now time.Time) *summaryStats {
+	if SummaryInterval == 0 {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.start.IsZero() {
+		s.start = now
+	}
+
+	s.blocks++
+	s.bytes += uint64(size)
+	s.lastBlock = number
+	if s.blocks < SummaryInterval {
+		return nil
+	}
+
+	stats := &summaryStats{
+		blocks:         s.blocks,
+		lastBlock:      s.lastBlock,
+		elapsed:        now.Sub(s.start),
+		averagePayload: s.bytes / s.blocks,
+	}
+	if seconds := stats.elapsed.Seconds(); seconds > 0 {
+		stats.blocksPerSec = float64(s.blocks) / seconds
+		stats.bytesPerSec = float64(s.bytes) / seconds
+	}
+
+	s.start, s.blocks, s.bytes = now, 0, 0
+	return stats
+}
+
+// summarizeEmittedBlock accounts the block `number` completed with its last `size` bytes of
+// Firehose data, logging the summary of the blocks emitted once `SummaryInterval` blocks were
+// emitted since the last one.
+func summarizeEmittedBlock(number uint64, size int) {
+	stats := blockEmissionSummary.addBlock(number, size, time.Now())
+	if stats == nil {
+		return
+	}
+
+	var backlog int
+	if sink, ok := syncContext.printer.(sinkBacklogger); ok {
+		backlog = sink.Backlog()
+	}
+
+	log.Info("Firehose blocks emitted",
+		"blocks", stats.blocks,
+		"number", stats.lastBlock,
+		"elapsed", common.PrettyDuration(stats.elapsed),
+		"blocks_per_sec", stats.blocksPerSec,
+		"mb_per_sec", stats.bytesPerSec/1024/1024,
+		"avg_payload", common.StorageSize(stats.averagePayload),
+		"queue_depth", common.StorageSize(backlog),
+	)
+}
@@ -0,0 +1,50 @@
+package firehose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmissionSummary(t *testing.T) {
+	defer func(interval uint64) { SummaryInterval = interval }(SummaryInterval)
+	SummaryInterval = 3
+
+	summary := &emissionSummary{}
+	start := time.Unix(1000, 0)
+
+	assert.Nil(t, summary.addBlock(10, 1000, start))
+	summary.addBytes(4000)
+	assert.Nil(t, summary.addBlock(11, 1000, start.Add(time.Second)))
+
+	stats := summary.addBlock(12, 3000, start.Add(2*time.Second))
+	require.NotNil(t, stats)
+	assert.Equal(t, &summaryStats{
+		blocks:         3,
+		lastBlock:      12,
+		elapsed:        2 * time.Second,
+		blocksPerSec:   1.5,
+		bytesPerSec:    4500,
+		averagePayload: 3000,
+	}, stats)
+
+	// The next summary covers the blocks emitted since this one
+	assert.Nil(t, summary.addBlock(13, 100, start.Add(3*time.Second)))
+	assert.Nil(t, summary.addBlock(14, 100, start.Add(4*time.Second)))
+	stats = summary.addBlock(15, 100, start.Add(6*time.Second))
+	require.NotNil(t, stats)
+	assert.Equal(t, 4*time.Second, stats.elapsed)
+	assert.Equal(t, uint64(100), stats.averagePayload)
+}
+
+func TestEmissionSummary_Disabled(t *testing.T) {
+	defer func(interval uint64) { SummaryInterval = interval }(SummaryInterval)
+	SummaryInterval = 0
+
+	summary := &emissionSummary{}
+	for i := uint64(0); i < 10; i++ {
+		assert.Nil(t, summary.addBlock(i, 100, time.Now()))
+	}
+}
@@ -0,0 +1,106 @@
+package firehose
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrSinkTapLagging is the error of a sink tap closed because its consumer did not keep up
+// with the stream, the tap would otherwise have had a gap.
+var ErrSinkTapLagging = errors.New("firehose sink tap closed, its consumer is lagging behind the stream")
+
+// SinkTap is a subscription to the raw bytes written to the Firehose sink, exactly as written
+// to standard output, for local tooling that wants the stream without owning standard output.
+// The tap starts receiving from the moment it's created, possibly in the middle of a block,
+// consumers must skip the records up to the first `BLOCK_BEGIN` marker.
+type SinkTap struct {
+	chunks chan []byte
+
+	closeOnce sync.Once
+	err       error
+}
+
+var (
+	sinkTapsLock sync.Mutex
+	sinkTaps     = map[*SinkTap]struct{}{}
+)
+
+// TapSink creates a tap receiving the bytes written to the Firehose sink from now on, up to
+// `capacity` writes are buffered, the tap is closed with `ErrSinkTapLagging` when its consumer
+// falls further behind, so the sink is never slowed down by a tap. It must be closed once
+// done with.
+func TapSink(capacity int) *SinkTap {
+	tap := &SinkTap{chunks: make(chan []byte, capacity)}
+
+	sinkTapsLock.Lock()
+	sinkTaps[tap] = struct{}{}
+	sinkTapsLock.Unlock()
+
+	return tap
+}
+
+// Chunks returns the channel receiving the bytes written to the sink, in order, it's closed
+// once the tap is, see `Err`.
+func (t *SinkTap) Chunks() <-chan []byte {
+	return t.chunks
+}
+
+// Err returns why the tap was closed, `ErrSinkTapLagging` if its consumer did not keep up, nil
+// if it's still open or was closed by `Close`.
+func (t *SinkTap) Err() error {
+	sinkTapsLock.Lock()
+	defer sinkTapsLock.Unlock()
+
+	return t.err
+}
+
+// Close stops the tap, the chunks buffered and not received yet are dropped.
+func (t *SinkTap) Close() {
+	sinkTapsLock.Lock()
+	defer sinkTapsLock.Unlock()
+
+	t.close(nil)
+}
+
+// close must be called with the taps lock held
+func (t *SinkTap) close(err error) {
+	t.closeOnce.Do(func() {
+		delete(sinkTaps, t)
+		t.err = err
+		close(t.chunks)
+	})
+}
+
+// tappedWriter writes to the sink writer and hands a copy of what was written to each tap.
+type tappedWriter struct {
+	writer io.Writer
+}
+
+func (w *tappedWriter) Write(in []byte) (int, error) {
+	n, err := w.writer.Write(in)
+	if n > 0 {
+		broadcastToSinkTaps(in[:n])
+	}
+
+	return n, err
+}
+
+func broadcastToSinkTaps(in []byte) {
+	sinkTapsLock.Lock()
+	defer sinkTapsLock.Unlock()
+
+	if len(sinkTaps) == 0 {
+		return
+	}
+
+	// The printers reuse their buffer once written, the taps receive their own copy
+	chunk := append([]byte(nil), in...)
+	for tap := range sinkTaps {
+		select {
+		case tap.chunks <- chunk:
+		default:
+			tap.close(ErrSinkTapLagging)
+		}
+	}
+}
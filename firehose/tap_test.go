@@ -0,0 +1,58 @@
+package firehose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSinkTap(t *testing.T) {
+	sink := bytes.NewBuffer(nil)
+	writer := &tappedWriter{sink}
+
+	writer.Write([]byte("FIRE BEFORE_TAP\n"))
+
+	tap := TapSink(2)
+	defer tap.Close()
+
+	line := []byte("FIRE FINALIZE_BLOCK 1\n")
+	writer.Write(line)
+	line[5] = 'X'
+
+	assert.Equal(t, "FIRE BEFORE_TAP\nFIRE FINALIZE_BLOCK 1\n", sink.String())
+	assert.Equal(t, []byte("FIRE FINALIZE_BLOCK 1\n"), <-tap.Chunks(), "the tap must receive its own copy")
+	assert.NoError(t, tap.Err())
+
+	tap.Close()
+	_, open := <-tap.Chunks()
+	assert.False(t, open)
+	assert.NoError(t, tap.Err())
+
+	// Closing twice is harmless and a closed tap receives nothing anymore
+	tap.Close()
+	writer.Write(line)
+}
+
+func TestSinkTap_Lagging(t *testing.T) {
+	writer := &tappedWriter{bytes.NewBuffer(nil)}
+
+	lagging := TapSink(1)
+	defer lagging.Close()
+	following := TapSink(2)
+	defer following.Close()
+
+	writer.Write([]byte("FIRE A\n"))
+	writer.Write([]byte("FIRE B\n"))
+
+	var received []string
+	for chunk := range lagging.Chunks() {
+		received = append(received, string(chunk))
+	}
+	assert.Equal(t, []string{"FIRE A\n"}, received)
+	assert.Equal(t, ErrSinkTapLagging, lagging.Err())
+
+	require.Len(t, following.Chunks(), 2)
+	assert.NoError(t, following.Err())
+}
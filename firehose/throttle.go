@@ -0,0 +1,245 @@
+package firehose
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// QueueingPrinter is a printer that queues writes in memory and sends them to the underlying
+// writer from a background goroutine, so that block import is not blocked right away by a
+// slow consumer. The amount of data queued, the backlog, is used to slow down block import
+// when it crosses `SinkThrottleThreshold`, see `ThrottleSync`.
+//
+// The backlog is bounded, a write blocks while the backlog is above `SinkThrottleThreshold`
+// times `queueingHardLimitFactor`, no data is ever dropped by the printer itself.
+//
+// QueueingPrinter is thread-safe.
+type QueueingPrinter struct {
+	ctx    context.Context
+	writer io.Writer
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	queue   []byte
+	writing int
+	hardCap int
+	err     error
+
+	// Total amount of bytes queued and sent so far, and the callbacks to call once the
+	// queued data up to an offset was sent, see `AfterWrite`
+	queued    uint64
+	sent      uint64
+	afterSent []sentCallback
+}
+
+type sentCallback struct {
+	offset uint64
+	fn     func()
+}
+
+// queueingHardLimitFactor is the factor, applied to the throttle threshold, of the backlog
+// above which writes to a `QueueingPrinter` block.
+const queueingHardLimitFactor = 4
+
+// NewQueueingPrinter creates a printer queuing up to `threshold` times `queueingHardLimitFactor`
+// bytes before blocking writes to `writer`. Writes blocked on `writer` are aborted when `ctx`
+// is canceled.
+func NewQueueingPrinter(ctx context.Context, writer io.Writer, threshold int) *QueueingPrinter {
+	p := &QueueingPrinter{ctx: ctx, writer: newCancelableWriter(ctx, writer), hardCap: threshold * queueingHardLimitFactor}
+	p.cond = sync.NewCond(&p.lock)
+
+	go p.drain()
+	go func() {
+		// Wake up writers and flushers waiting on a canceled sink
+		<-ctx.Done()
+		p.lock.Lock()
+		p.cond.Broadcast()
+		p.lock.Unlock()
+	}()
+
+	return p
+}
+
+func (p *QueueingPrinter) Disabled() bool {
+	return false
+}
+
+func (p *QueueingPrinter) Write(in []byte) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.enqueue(in)
+}
+
+func (p *QueueingPrinter) Print(input ...string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	line := make([]byte, 0, 256)
+	line = append(line, "FIRE "...)
+	for i, in := range input {
+		if i != 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, in...)
+	}
+	p.enqueue(append(line, '\n'))
+}
+
+// Backlog returns the amount of bytes queued and being written to the underlying writer.
+func (p *QueueingPrinter) Backlog() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.queue) + p.writing
+}
+
+// Flush waits until all queued data was sent to the underlying writer, or until writing
+// failed or the sink was canceled.
+func (p *QueueingPrinter) Flush() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for (len(p.queue) > 0 || p.writing > 0) && p.err == nil && p.ctx.Err() == nil {
+		p.cond.Wait()
+	}
+}
+
+// AfterWrite calls `fn` once the data currently queued was sent to the underlying writer,
+// right away if the queue is empty. It's called with the printer's lock held, `fn` must not
+// write to the printer.
+func (p *QueueingPrinter) AfterWrite(fn func()) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.err != nil {
+		return
+	}
+	if p.sent == p.queued {
+		fn()
+		return
+	}
+
+	p.afterSent = append(p.afterSent, sentCallback{offset: p.queued, fn: fn})
+}
+
+// Err returns the first error that happened while writing to the sink, if any.
+func (p *QueueingPrinter) Err() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.err
+}
+
+// enqueue must be called with the lock held.
+func (p *QueueingPrinter) enqueue(in []byte) {
+	for len(p.queue)+p.writing >= p.hardCap && p.err == nil && p.ctx.Err() == nil {
+		p.cond.Wait()
+	}
+
+	if p.err != nil {
+		return
+	}
+
+	p.queue = append(p.queue, in...)
+	p.queued += uint64(len(in))
+	p.cond.Broadcast()
+}
+
+func (p *QueueingPrinter) drain() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for {
+		for len(p.queue) == 0 && p.ctx.Err() == nil {
+			p.cond.Wait()
+		}
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		chunk := p.queue
+		p.queue, p.writing = nil, len(chunk)
+
+		p.lock.Unlock()
+		err := flushToFirehose(p.ctx, chunk, p.writer)
+		p.lock.Lock()
+
+		p.writing = 0
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+		if err == nil {
+			p.sent += uint64(len(chunk))
+			for len(p.afterSent) > 0 && p.afterSent[0].offset <= p.sent {
+				p.afterSent[0].fn()
+				p.afterSent = p.afterSent[1:]
+			}
+		}
+		p.cond.Broadcast()
+
+		if p.err != nil {
+			return
+		}
+	}
+}
+
+// sinkBacklogger is implemented by printers queuing data in memory, see `QueueingPrinter`.
+type sinkBacklogger interface {
+	Backlog() int
+}
+
+// ThrottleSync slows down block import, once a block was flushed, while the backlog of the
+// sink is above `SinkThrottleThreshold`, waiting for the consumer to catch up for at most
+// `SinkThrottleMaxDelay`. It returns the time spent waiting, 0 when throttling is disabled
+// or when the backlog is below the threshold.
+func ThrottleSync() time.Duration {
+	if SinkThrottleThreshold <= 0 {
+		return 0
+	}
+
+	sink, ok := syncContext.printer.(sinkBacklogger)
+	if !ok || sink.Backlog() <= SinkThrottleThreshold {
+		return 0
+	}
+
+	start := time.Now()
+	for sink.Backlog() > SinkThrottleThreshold && time.Since(start) < SinkThrottleMaxDelay {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	delay := time.Since(start)
+	sinkThrottleTimer.Update(delay)
+	log.Debug("Firehose sink backlog above threshold, block import throttled", "backlog", sink.Backlog(), "threshold", SinkThrottleThreshold, "delay", delay)
+
+	return delay
+}
+
+// SinkStatus is the state of the throttling applied to the Firehose sink.
+type SinkStatus struct {
+	// Backlog is the amount of bytes waiting to be written to the sink, always 0 when sink
+	// throttling is disabled, see `SinkThrottleThreshold`
+	Backlog int `json:"backlog"`
+	// ThrottleThreshold is the backlog above which block import is slowed down
+	ThrottleThreshold int `json:"throttleThreshold"`
+	// RateLimit is the state of the sink rate limit, nil when disabled, see `SinkRateLimit`
+	RateLimit *SinkRateLimitStatus `json:"rateLimit"`
+}
+
+// CurrentSinkStatus returns the current state of the throttling applied to the Firehose sink.
+func CurrentSinkStatus() *SinkStatus {
+	status := &SinkStatus{
+		ThrottleThreshold: SinkThrottleThreshold,
+		RateLimit:         sinkRateLimitStatus(),
+	}
+
+	if sink, ok := syncContext.printer.(sinkBacklogger); ok {
+		status.Backlog = sink.Backlog()
+	}
+
+	return status
+}
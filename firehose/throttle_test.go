@@ -0,0 +1,90 @@
+package firehose
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedWriter blocks every write until its gate is opened.
+type gatedWriter struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+	gate chan struct{}
+}
+
+func (w *gatedWriter) Write(in []byte) (int, error) {
+	<-w.gate
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.buf.Write(in)
+}
+
+func (w *gatedWriter) String() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.buf.String()
+}
+
+func TestQueueingPrinter(t *testing.T) {
+	writer := &gatedWriter{gate: make(chan struct{})}
+	printer := NewQueueingPrinter(context.Background(), writer, 1024)
+
+	printer.Print("BEGIN_BLOCK", "1")
+	printer.Write([]byte("FIRE END_BLOCK 1\n"))
+	assert.Equal(t, 36, printer.Backlog(), "consumer blocked, everything should be queued")
+
+	close(writer.gate)
+	printer.Flush()
+
+	assert.Equal(t, 0, printer.Backlog())
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\nFIRE END_BLOCK 1\n", writer.String())
+	assert.NoError(t, printer.Err())
+}
+
+func TestQueueingPrinter_AfterWrite(t *testing.T) {
+	writer := &gatedWriter{gate: make(chan struct{})}
+	printer := NewQueueingPrinter(context.Background(), writer, 1024)
+
+	written := make(chan string, 2)
+	printer.AfterWrite(func() { written <- "empty" })
+	assert.Equal(t, "empty", <-written, "empty queue, callback should be called right away")
+
+	printer.Print("BEGIN_BLOCK", "1")
+	printer.AfterWrite(func() { written <- writer.String() })
+	assert.Empty(t, written, "consumer blocked, callback should not be called")
+
+	close(writer.gate)
+	select {
+	case out := <-written:
+		assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", out, "callback called before the data was written")
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not called once the queued data was written")
+	}
+}
+
+func TestThrottleSync(t *testing.T) {
+	defer func(threshold int, maxDelay time.Duration, ctx *Context) {
+		SinkThrottleThreshold, SinkThrottleMaxDelay, syncContext = threshold, maxDelay, ctx
+	}(SinkThrottleThreshold, SinkThrottleMaxDelay, syncContext)
+
+	writer := &gatedWriter{gate: make(chan struct{})}
+	defer close(writer.gate)
+
+	SinkThrottleThreshold, SinkThrottleMaxDelay = 32, 50*time.Millisecond
+	printer := NewQueueingPrinter(context.Background(), writer, SinkThrottleThreshold)
+	syncContext = NewContext(printer, false)
+
+	printer.Print("BEGIN_BLOCK", "1")
+	assert.Equal(t, time.Duration(0), ThrottleSync(), "backlog below threshold, import should not be throttled")
+
+	printer.Print("END_BLOCK", "1")
+	delay := ThrottleSync()
+	assert.GreaterOrEqual(t, int64(delay), int64(SinkThrottleMaxDelay), "consumer stuck, import should be throttled")
+	assert.Less(t, int64(delay), int64(time.Second), "throttling should be bounded by the max delay")
+}
@@ -0,0 +1,628 @@
+package firehose
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// BlockTrace is the typed trace of a block, from its `BEGIN_BLOCK` record up to its
+// `END_BLOCK` record.
+type BlockTrace struct {
+	Number          uint64          `json:"number"`
+	Size            uint64          `json:"size"`
+	Header          *types.Header   `json:"header,omitempty"`
+	Uncles          []*types.Header `json:"uncles,omitempty"`
+	TotalDifficulty *big.Int        `json:"totalDifficulty,omitempty"`
+
+	// GasLimit, GasTarget, BaseFee and LogsBloom are the header fields recorded by the
+	// `BEGIN_BLOCK` record, the base fee is nil before the London fork
+	GasLimit  uint64        `json:"gasLimit"`
+	GasTarget uint64        `json:"gasTarget"`
+	BaseFee   *big.Int      `json:"baseFee,omitempty"`
+	LogsBloom hexutil.Bytes `json:"logsBloom,omitempty"`
+
+	Transactions []*TransactionTrace `json:"transactions,omitempty"`
+
+	// SystemCalls are the calls executed by the system out of any transaction (e.g. EIP-4788
+	// beacon root and EIP-2935 history storage updates), framed by the `SYSTEM_CALL_START` and
+	// `SYSTEM_CALL_END` records, the root call of each first
+	SystemCalls []*Call `json:"systemCalls,omitempty"`
+
+	// NonExecuted is true when the `NON_EXECUTED_BLOCK` record was seen, the block was imported
+	// without being executed, its transactions have no call nor change
+	NonExecuted bool `json:"nonExecuted,omitempty"`
+
+	// Backfilled is true when the `BACKFILLED_BLOCK` record was seen, the block was emitted
+	// first as a non-executed block and is emitted again once re-executed
+	Backfilled bool `json:"backfilled,omitempty"`
+
+	// Replayed is true when the `REPLAYED_BLOCK` record was seen, the block was already
+	// emitted before the node restarted and is emitted again
+	Replayed bool `json:"replayed,omitempty"`
+
+	// Proposed is true when the `PROPOSED_BLOCK` record was seen, the block is a candidate
+	// block assembled by the miner, it's not part of the canonical chain
+	Proposed bool `json:"proposed,omitempty"`
+
+	// ProposedTaskID identifies the sealing task of the miner that assembled the proposed block,
+	// the candidate blocks of a same task share it
+	ProposedTaskID uint64 `json:"proposedTaskId,omitempty"`
+
+	// ForkActivations are the forks of the chain config activated at the block, the decoding
+	// rules of the fork apply from the block on
+	ForkActivations []*ForkActivation `json:"forkActivations,omitempty"`
+
+	// Witness holds the parent state accessed while executing the block, see `BLOCK_WITNESS`
+	Witness *BlockWitness `json:"witness,omitempty"`
+
+	// CreatedContracts are the contracts created by the transactions of the block, in their
+	// creation order, see `CREATED_CONTRACTS`
+	CreatedContracts []*CreatedContract `json:"createdContracts,omitempty"`
+
+	// CliqueSeal is the seal of the block on Clique (proof-of-authority) chains, nil on other
+	// chains, see `CLIQUE_SEAL`
+	CliqueSeal *CliqueSeal `json:"cliqueSeal,omitempty"`
+
+	// Counts are the amounts of records of each category emitted for the block, as recorded
+	// by the `END_BLOCK` record, nil for the blocks emitted before they were recorded
+	Counts *RecordCounts `json:"counts,omitempty"`
+
+	// Duration is the wall-clock time spent from the block start up to its end, 0 when the
+	// timings are disabled
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Emission is the timing of the writing of the block to the output, as recorded by its
+	// `BLOCK_BEGIN` and `BLOCK_END` markers, nil when the timings are disabled
+	Emission *BlockEmission `json:"emission,omitempty"`
+
+	// Finalized is true when the `FINALIZE_BLOCK` record was seen, the changes recorded
+	// after it (block and uncle rewards) are in `BalanceChanges`
+	Finalized bool `json:"finalized"`
+
+	// BalanceChanges are the changes recorded out of any transaction (e.g. DAO hard fork,
+	// block and uncle rewards)
+	BalanceChanges []*BalanceChange `json:"balanceChanges,omitempty"`
+
+	// IrregularStateChanges are the one-off mutations of the state applied to the block, the
+	// changes they made are the ones recorded out of any transaction within their ordinals
+	IrregularStateChanges []*IrregularStateChange `json:"irregularStateChanges,omitempty"`
+}
+
+// BlockEmission is the timing of the writing of a block to the output, see `TimingsEnabled`.
+type BlockEmission struct {
+	// BeginTime and EndTime are the wall-clock times, in nanoseconds since the Unix epoch, at
+	// which the `BLOCK_BEGIN` and `BLOCK_END` markers were written
+	BeginTime uint64 `json:"beginTime"`
+	EndTime   uint64 `json:"endTime"`
+
+	// BeginElapsed and EndElapsed are the monotonic time elapsed from the block start up to
+	// the writing of the `BLOCK_BEGIN` and `BLOCK_END` markers
+	BeginElapsed time.Duration `json:"beginElapsed"`
+	EndElapsed   time.Duration `json:"endElapsed"`
+}
+
+// IrregularStateChange is a one-off mutation of the state applied to a block out of any
+// transaction, framed by the `IRREGULAR_STATE_CHANGE_START` and `IRREGULAR_STATE_CHANGE_END`
+// records, see `Context.RecordIrregularStateChange`.
+type IrregularStateChange struct {
+	Reason       IrregularStateChangeReason `json:"reason"`
+	BeginOrdinal uint64                     `json:"beginOrdinal"`
+	EndOrdinal   uint64                     `json:"endOrdinal"`
+}
+
+// ForkActivation is a fork of the chain config activated at a block, see `FORK_ACTIVATION`.
+type ForkActivation struct {
+	Name      string `json:"name"`
+	Number    uint64 `json:"number"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// BlockWitness is the part of the parent state of a block accessed while executing it, enough
+// to re-execute the block statelessly, see `BLOCK_WITNESS`. The trie nodes are the Merkle
+// proofs, against the parent state root, of every account and storage slot accessed.
+type BlockWitness struct {
+	Nodes []hexutil.Bytes `json:"nodes"`
+	Codes []hexutil.Bytes `json:"codes"`
+}
+
+// CliqueSeal is the seal of a block of a Clique (proof-of-authority) chain, see `CLIQUE_SEAL`.
+type CliqueSeal struct {
+	// Signer is the authorized signer that sealed the block, recovered from its signature
+	Signer common.Address `json:"signer"`
+
+	// InTurn is true when the signer sealed the block in its turn, false when it sealed it
+	// out of turn because the in-turn signer did not
+	InTurn bool `json:"inTurn"`
+
+	// Checkpoint is true for the blocks starting an epoch, which discard the pending votes
+	// and list the authorized signers in `Signers`
+	Checkpoint bool             `json:"checkpoint"`
+	Signers    []common.Address `json:"signers,omitempty"`
+
+	// Vote is the vote cast by the signer, nil if none, checkpoints cast none
+	Vote *CliqueVote `json:"vote,omitempty"`
+}
+
+// CliqueVote is a vote of a Clique signer to authorize, or to drop when `Authorize` is false,
+// the signer `Address`.
+type CliqueVote struct {
+	Address   common.Address `json:"address"`
+	Authorize bool           `json:"authorize"`
+}
+
+// TransactionTrace is a transaction of a block along with everything recorded while it
+// was applied.
+type TransactionTrace struct {
+	Hash     common.Hash     `json:"hash"`
+	To       *common.Address `json:"to,omitempty"`
+	From     common.Address  `json:"from"`
+	Value    *big.Int        `json:"value,omitempty"`
+	V        hexutil.Bytes   `json:"v,omitempty"`
+	R        hexutil.Bytes   `json:"r,omitempty"`
+	S        hexutil.Bytes   `json:"s,omitempty"`
+	GasLimit uint64          `json:"gasLimit"`
+	GasPrice *big.Int        `json:"gasPrice,omitempty"`
+	Nonce    uint64          `json:"nonce"`
+	Input    hexutil.Bytes   `json:"input,omitempty"`
+
+	// AccessList is the access list binary payload, as emitted by Firehose
+	AccessList hexutil.Bytes `json:"accessList,omitempty"`
+	Type       uint8         `json:"type"`
+	Index      uint64        `json:"index"`
+
+	// SignatureScheme is how the hash signed by the sender is computed (`homestead`, `eip155`
+	// or `typed`) and YParity the parity of the signature, from the `TRX_SIGNATURE` record
+	SignatureScheme string `json:"signatureScheme,omitempty"`
+	YParity         uint64 `json:"yParity"`
+
+	// System is true for the system transactions executed by the consensus engine of chain
+	// variants once the block is finalized, flagged by the `SYSTEM_TRX` record
+	System bool `json:"system,omitempty"`
+
+	BeginOrdinal uint64 `json:"beginOrdinal"`
+	EndOrdinal   uint64 `json:"endOrdinal"`
+
+	// Duration is the wall-clock time spent applying the transaction, 0 when the timings
+	// are disabled
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Receipt
+	GasUsed           uint64        `json:"gasUsed"`
+	PostState         hexutil.Bytes `json:"postState,omitempty"`
+	CumulativeGasUsed uint64        `json:"cumulativeGasUsed"`
+	LogsBloom         hexutil.Bytes `json:"logsBloom,omitempty"`
+	ReceiptLogs       []*ReceiptLog `json:"receiptLogs,omitempty"`
+	Status            uint64        `json:"status"`
+
+	// EffectiveGasPrice is the price paid per unit of gas, the gas price of the transaction
+	// until the London fork is active in this branch
+	EffectiveGasPrice *big.Int `json:"effectiveGasPrice,omitempty"`
+
+	// ContractAddress is the address of the contract created, nil when the transaction is
+	// not a contract creation
+	ContractAddress *common.Address `json:"contractAddress,omitempty"`
+
+	// Calls is the flat list of all calls in their execution order, the root call first
+	Calls []*Call `json:"calls,omitempty"`
+
+	// SetCodeAuthorizations are the EIP-7702 authorizations of a set code transaction, in
+	// the order they were applied
+	SetCodeAuthorizations []*SetCodeAuthorization `json:"setCodeAuthorizations,omitempty"`
+
+	// Changes recorded while no call is active (e.g. buying gas, refunding gas, paying
+	// the miner, applying the EIP-7702 authorizations)
+	BalanceChanges  []*BalanceChange  `json:"balanceChanges,omitempty"`
+	GasChanges      []*GasChange      `json:"gasChanges,omitempty"`
+	NonceChanges    []*NonceChange    `json:"nonceChanges,omitempty"`
+	CreatedAccounts []*CreatedAccount `json:"createdAccounts,omitempty"`
+	CodeChanges     []*CodeChange     `json:"codeChanges,omitempty"`
+
+	// DeletedAccounts are the accounts removed from the state once the transaction executed
+	DeletedAccounts []*DeletedAccount `json:"deletedAccounts,omitempty"`
+}
+
+// Transfer is a value transfer predicted for a pending transaction, see
+// `Context.RecordPendingEffects`.
+type Transfer struct {
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Value *big.Int       `json:"value"`
+}
+
+// ReceiptLog is a log of the transaction's receipt.
+type ReceiptLog struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+
+	// Index is the index of the log in the block, the one of the first log of a transaction
+	// being the offset of its logs in the block
+	Index uint64 `json:"index"`
+}
+
+// Call is an EVM call of a transaction, from its `EVM_RUN_CALL` record up to its
+// `EVM_END_CALL` record.
+type Call struct {
+	Index       uint64   `json:"index"`
+	ParentIndex uint64   `json:"parentIndex"`
+	Depth       uint64   `json:"depth"`
+	CallType    CallType `json:"callType"`
+
+	// ParentOrdinal is the begin ordinal of the parent call, 0 for the root call
+	ParentOrdinal uint64 `json:"parentOrdinal"`
+
+	// OpCode is the name of the EVM opcode that triggered the call, empty for the root call
+	OpCode string `json:"opCode,omitempty"`
+
+	Caller   common.Address `json:"caller"`
+	Address  common.Address `json:"address"`
+	Value    *big.Int       `json:"value,omitempty"`
+	GasLimit uint64         `json:"gasLimit"`
+	GasLeft  uint64         `json:"gasLeft"`
+	Input    hexutil.Bytes  `json:"input,omitempty"`
+
+	// GasUsed is the gas used by the call, burned gas included, GasLeft being the gas it
+	// returned to its caller. GasRetained is the gas retained by the caller at the call site
+	// because of the EIP-150 "all but one 64th" rule, 0 for the root call.
+	GasUsed     uint64 `json:"gasUsed"`
+	GasRetained uint64 `json:"gasRetained"`
+
+	ReturnData hexutil.Bytes `json:"returnData,omitempty"`
+
+	// DelegateCaller is only set on delegate calls
+	DelegateCaller *common.Address `json:"delegateCaller,omitempty"`
+
+	// ReadOnly is true when the call executes under STATICCALL restrictions, it's a static
+	// call or is nested in one
+	ReadOnly bool `json:"readOnly"`
+
+	// Precompile is true when the call targets a precompiled contract, PrecompileName being
+	// its name when it's known
+	Precompile     bool   `json:"precompile"`
+	PrecompileName string `json:"precompileName,omitempty"`
+
+	// HasCode is true when the call executes code, CodeHash being its hash: the code of the
+	// called account, or of the account it delegates to (EIP-7702), or the init code of a
+	// contract creation
+	HasCode  bool         `json:"hasCode"`
+	CodeHash *common.Hash `json:"codeHash,omitempty"`
+
+	ExecutedCode  bool            `json:"executedCode"`
+	Failed        bool            `json:"failed"`
+	FailureCode   CallFailureCode `json:"failureCode,omitempty"`
+	FailureReason string          `json:"failureReason,omitempty"`
+	Reverted      bool            `json:"reverted"`
+
+	// CreateFailure is set when the call is a contract creation aborted before running its
+	// init code, e.g. because of a contract address collision
+	CreateFailure *CreateFailure `json:"createFailure,omitempty"`
+
+	BeginOrdinal uint64 `json:"beginOrdinal"`
+	EndOrdinal   uint64 `json:"endOrdinal"`
+
+	KeccakPreimages         map[common.Hash]hexutil.Bytes `json:"keccakPreimages,omitempty"`
+	StorageChanges          []*StorageChange              `json:"storageChanges,omitempty"`
+	TransientStorageChanges []*StorageChange              `json:"transientStorageChanges,omitempty"`
+	BalanceChanges          []*BalanceChange              `json:"balanceChanges,omitempty"`
+	GasChanges              []*GasChange                  `json:"gasChanges,omitempty"`
+	NonceChanges            []*NonceChange                `json:"nonceChanges,omitempty"`
+	Logs                    []*Log                        `json:"logs,omitempty"`
+	SuicideChanges          []*SuicideChange              `json:"suicideChanges,omitempty"`
+	CreatedAccounts         []*CreatedAccount             `json:"createdAccounts,omitempty"`
+	CodeChanges             []*CodeChange                 `json:"codeChanges,omitempty"`
+	AccountTouches          []*AccountTouch               `json:"accountTouches,omitempty"`
+}
+
+type BalanceChange struct {
+	Address common.Address      `json:"address"`
+	Old     *big.Int            `json:"old,omitempty"`
+	New     *big.Int            `json:"new,omitempty"`
+	Reason  BalanceChangeReason `json:"reason"`
+	Ordinal uint64              `json:"ordinal"`
+
+	// Delta is the signed amount credited, or debited when negative, of a change recorded by
+	// a `BALANCE_DELTA` record, `Old` and `New` being then unknown
+	Delta *big.Int `json:"delta,omitempty"`
+}
+
+type GasChange struct {
+	Old     uint64          `json:"old"`
+	New     uint64          `json:"new"`
+	Reason  GasChangeReason `json:"reason"`
+	Ordinal uint64          `json:"ordinal"`
+}
+
+// CreateFailure is why a contract creation was aborted before running its init code, along with
+// what happened to the gas it was given.
+type CreateFailure struct {
+	Code           CallFailureCode `json:"code"`
+	GasDisposition GasDisposition  `json:"gasDisposition"`
+	Gas            uint64          `json:"gas"`
+}
+
+type NonceChange struct {
+	Address common.Address `json:"address"`
+	Old     uint64         `json:"old"`
+	New     uint64         `json:"new"`
+	Ordinal uint64         `json:"ordinal"`
+}
+
+type StorageChange struct {
+	Address common.Address `json:"address"`
+	Key     common.Hash    `json:"key"`
+	Old     common.Hash    `json:"old"`
+	New     common.Hash    `json:"new"`
+	Ordinal uint64         `json:"ordinal"`
+}
+
+type Log struct {
+	Address      common.Address `json:"address"`
+	Topics       []common.Hash  `json:"topics,omitempty"`
+	Data         hexutil.Bytes  `json:"data,omitempty"`
+	IndexInBlock uint64         `json:"indexInBlock"`
+	Ordinal      uint64         `json:"ordinal"`
+}
+
+type SuicideChange struct {
+	Address       common.Address `json:"address"`
+	Suicided      bool           `json:"suicided"`
+	BalanceBefore *big.Int       `json:"balanceBefore,omitempty"`
+}
+
+type CreatedAccount struct {
+	Address common.Address `json:"address"`
+	Ordinal uint64         `json:"ordinal"`
+}
+
+// DeletedAccount is an account removed from the state when the changes of a transaction were
+// finalized, see `Context.RecordDeletedAccount`.
+type DeletedAccount struct {
+	Address common.Address        `json:"address"`
+	Reason  AccountDeletionReason `json:"reason"`
+	Ordinal uint64                `json:"ordinal"`
+}
+
+// AccountTouch is an EIP-158 touch of an account, recorded only when
+// `AccountTouchesEnabled` is set, see `Context.RecordAccountTouch`.
+type AccountTouch struct {
+	Address common.Address `json:"address"`
+	Ordinal uint64         `json:"ordinal"`
+}
+
+type CodeChange struct {
+	Address     common.Address `json:"address"`
+	OldCodeHash hexutil.Bytes  `json:"oldCodeHash,omitempty"`
+	OldCode     hexutil.Bytes  `json:"oldCode,omitempty"`
+	NewCodeHash common.Hash    `json:"newCodeHash"`
+	NewCode     hexutil.Bytes  `json:"newCode,omitempty"`
+	Ordinal     uint64         `json:"ordinal"`
+}
+
+// SetCodeAuthorization is an EIP-7702 authorization, Authority is nil when it could not be
+// recovered from the authorization's signature. Applied is false when the authorization was
+// invalid and skipped.
+type SetCodeAuthorization struct {
+	ChainID   *big.Int        `json:"chainId,omitempty"`
+	Address   common.Address  `json:"address"`
+	Nonce     uint64          `json:"nonce"`
+	Authority *common.Address `json:"authority,omitempty"`
+	Applied   bool            `json:"applied"`
+	Ordinal   uint64          `json:"ordinal"`
+}
+
+// traceBuilder assembles the typed trace of the records of a context, it follows the same
+// rules as the `decode` package so that in-process consumers see exactly what a consumer
+// of the emitted records would decode.
+//
+// A transaction context has no block, the transactions it completes are held until they
+// are flushed in the block context, see `FlushTransaction`.
+type traceBuilder struct {
+	block     *BlockTrace
+	completed *BlockTrace
+
+	trx          *TransactionTrace
+	callStack    []*Call
+	transactions []*TransactionTrace
+}
+
+func (b *traceBuilder) resetBlock() {
+	b.block = nil
+	b.transactions = nil
+}
+
+func (b *traceBuilder) resetTransaction() {
+	b.trx = nil
+	b.callStack = b.callStack[:0]
+}
+
+// activeCall returns the innermost call not ended yet, nil when no call is active
+func (b *traceBuilder) activeCall() *Call {
+	if len(b.callStack) == 0 {
+		return nil
+	}
+
+	return b.callStack[len(b.callStack)-1]
+}
+
+func (b *traceBuilder) startBlock(header *types.Header, gasTarget uint64) {
+	b.block = &BlockTrace{
+		Number:    header.Number.Uint64(),
+		GasLimit:  header.GasLimit,
+		GasTarget: gasTarget,
+		LogsBloom: copyBytes(header.Bloom[:]),
+	}
+	b.completed = nil
+}
+
+func (b *traceBuilder) endBlock(block *types.Block, totalDifficulty *big.Int, duration time.Duration) {
+	if b.block == nil {
+		return
+	}
+
+	b.block.Duration = duration
+	b.block.Size = uint64(block.Size())
+	b.block.Header = block.Header()
+	b.block.Uncles = block.Uncles()
+	if totalDifficulty != nil {
+		b.block.TotalDifficulty = new(big.Int).Set(totalDifficulty)
+	}
+
+	b.completed, b.block = b.block, nil
+}
+
+// startSystemCall starts a system call, its calls are held by a transaction without any
+// field until the system call ends
+func (b *traceBuilder) startSystemCall() {
+	b.trx = &TransactionTrace{}
+}
+
+func (b *traceBuilder) endSystemCall() {
+	if b.block != nil && b.trx != nil {
+		b.block.SystemCalls = append(b.block.SystemCalls, b.trx.Calls...)
+	}
+
+	b.trx = nil
+}
+
+func (b *traceBuilder) endTransaction(receipt *types.Receipt, effectiveGasPrice *big.Int, ordinal uint64, duration time.Duration) {
+	if b.trx == nil {
+		return
+	}
+
+	trx := b.trx
+	trx.Duration = duration
+	trx.GasUsed = receipt.GasUsed
+	trx.PostState = copyBytes(receipt.PostState)
+	trx.CumulativeGasUsed = receipt.CumulativeGasUsed
+	trx.LogsBloom = copyBytes(receipt.Bloom[:])
+	trx.Status = receipt.Status
+	trx.EffectiveGasPrice = copyBigInt(effectiveGasPrice)
+	trx.EndOrdinal = ordinal
+
+	if receipt.ContractAddress != (common.Address{}) {
+		contractAddress := receipt.ContractAddress
+		trx.ContractAddress = &contractAddress
+	}
+
+	trx.ReceiptLogs = make([]*ReceiptLog, len(receipt.Logs))
+	for i, log := range receipt.Logs {
+		trx.ReceiptLogs[i] = &ReceiptLog{Address: log.Address, Topics: log.Topics, Data: common.CopyBytes(log.Data), Index: uint64(log.Index)}
+	}
+
+	if b.block != nil {
+		b.block.Transactions = append(b.block.Transactions, trx)
+	} else {
+		b.transactions = append(b.transactions, trx)
+	}
+}
+
+func (b *traceBuilder) startCall(callType CallType, opCode string, index uint64, ordinal uint64) *Call {
+	if b.trx == nil {
+		return nil
+	}
+
+	call := &Call{CallType: callType, OpCode: opCode, Index: index, BeginOrdinal: ordinal, ExecutedCode: true}
+	if parent := b.activeCall(); parent != nil {
+		call.ParentIndex = parent.Index
+		call.ParentOrdinal = parent.BeginOrdinal
+		call.Depth = parent.Depth + 1
+	}
+
+	b.callStack = append(b.callStack, call)
+	b.trx.Calls = append(b.trx.Calls, call)
+	return call
+}
+
+func (b *traceBuilder) endCall(gasLeft uint64, returnData []byte, ordinal uint64, gasUsed, gasRetained uint64) {
+	call := b.activeCall()
+	if call == nil {
+		return
+	}
+
+	b.callStack = b.callStack[:len(b.callStack)-1]
+	call.GasLeft = gasLeft
+	call.GasUsed = gasUsed
+	call.GasRetained = gasRetained
+	call.ReturnData = copyBytes(returnData)
+	call.EndOrdinal = ordinal
+}
+
+// recordBalanceChange records the change in the active call, in the transaction when no
+// call is active or in the block when out of any transaction
+func (b *traceBuilder) recordBalanceChange(change *BalanceChange) {
+	switch call := b.activeCall(); {
+	case call != nil:
+		call.BalanceChanges = append(call.BalanceChanges, change)
+	case b.trx != nil:
+		b.trx.BalanceChanges = append(b.trx.BalanceChanges, change)
+	case b.block != nil:
+		b.block.BalanceChanges = append(b.block.BalanceChanges, change)
+	}
+}
+
+func (b *traceBuilder) recordGasChange(change *GasChange) {
+	if call := b.activeCall(); call != nil {
+		call.GasChanges = append(call.GasChanges, change)
+	} else if b.trx != nil {
+		b.trx.GasChanges = append(b.trx.GasChanges, change)
+	}
+}
+
+func (b *traceBuilder) recordNonceChange(change *NonceChange) {
+	if call := b.activeCall(); call != nil {
+		call.NonceChanges = append(call.NonceChanges, change)
+	} else if b.trx != nil {
+		b.trx.NonceChanges = append(b.trx.NonceChanges, change)
+	}
+}
+
+func (b *traceBuilder) recordCreatedAccount(change *CreatedAccount) {
+	if call := b.activeCall(); call != nil {
+		call.CreatedAccounts = append(call.CreatedAccounts, change)
+	} else if b.trx != nil {
+		b.trx.CreatedAccounts = append(b.trx.CreatedAccounts, change)
+	}
+}
+
+func (b *traceBuilder) recordDeletedAccount(change *DeletedAccount) {
+	if b.trx != nil {
+		b.trx.DeletedAccounts = append(b.trx.DeletedAccounts, change)
+	}
+}
+
+func (b *traceBuilder) recordAccountTouch(touch *AccountTouch) {
+	if call := b.activeCall(); call != nil {
+		call.AccountTouches = append(call.AccountTouches, touch)
+	}
+}
+
+func (b *traceBuilder) recordCodeChange(change *CodeChange) {
+	if call := b.activeCall(); call != nil {
+		call.CodeChanges = append(call.CodeChanges, change)
+	} else if b.trx != nil {
+		b.trx.CodeChanges = append(b.trx.CodeChanges, change)
+	}
+}
+
+// copyBigInt copies `in`, nil being copied as 0 like the "null" value of a record is decoded
+func copyBigInt(in *big.Int) *big.Int {
+	if in == nil {
+		return new(big.Int)
+	}
+
+	return new(big.Int).Set(in)
+}
+
+// copyBytes copies `in`, an empty slice being copied as nil like the "null" value of a record
+// is decoded
+func copyBytes(in []byte) []byte {
+	if len(in) == 0 {
+		return nil
+	}
+
+	return common.CopyBytes(in)
+}
@@ -0,0 +1,388 @@
+package firehose
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"go.uber.org/atomic"
+)
+
+const (
+	// spanQueueSize is the amount of ended spans waiting to be exported above which new spans
+	// are dropped, export never slows down block processing
+	spanQueueSize = 4096
+
+	// spanBatchSize is the maximum amount of spans sent in a single export request
+	spanBatchSize = 512
+
+	// spanExportInterval is the maximum amount of time an ended span waits before being exported
+	spanExportInterval = 2 * time.Second
+
+	// spanExportTimeout bounds each export request, a slow collector only delays the export
+	spanExportTimeout = 10 * time.Second
+
+	// tracingScopeName is the instrumentation scope of the exported spans
+	tracingScopeName = "github.com/ethereum/go-ethereum/firehose"
+)
+
+// Span is an operation traced in the tracing backend of the operator, see `OTLPEndpoint`, like
+// the processing of a block, the application of a transaction or the flush of a block's
+// Firehose data. The spans started while tracing is disabled are nil, all of their methods
+// are then no-ops, the instrumentation can be left in place at no cost.
+//
+// A span is **not** thread-safe, it's expected to be used by a single thread until ended.
+type Span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes []spanAttribute
+	err        error
+}
+
+type spanAttribute struct {
+	key    string
+	int    int64
+	str    string
+	isText bool
+}
+
+// StartSpan starts a span named `name`, child of `parent` or root of a new trace when `parent`
+// is nil. A nil span is returned when tracing is disabled, see `OTLPEndpoint`.
+func StartSpan(name string, parent *Span) *Span {
+	if currentSpanExporter() == nil {
+		return nil
+	}
+
+	span := &Span{name: name, start: time.Now()}
+
+	spanIDsLock.Lock()
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		spanIDs.Read(span.traceID[:])
+	}
+	spanIDs.Read(span.spanID[:])
+	spanIDsLock.Unlock()
+
+	return span
+}
+
+var (
+	// spanIDs generates the trace and span identifiers, they only need to be unique, it's a
+	// pseudo-random source seeded once from the system's one. It's guarded by `spanIDsLock`.
+	spanIDs     = rand.New(rand.NewSource(spanIDsSeed()))
+	spanIDsLock sync.Mutex
+)
+
+func spanIDsSeed() int64 {
+	var seed [8]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+
+	return int64(binary.LittleEndian.Uint64(seed[:]))
+}
+
+// SetInt sets the integer attribute `key` of the span.
+func (s *Span) SetInt(key string, value int64) {
+	if s == nil {
+		return
+	}
+
+	s.attributes = append(s.attributes, spanAttribute{key: key, int: value})
+}
+
+// SetString sets the string attribute `key` of the span.
+func (s *Span) SetString(key string, value string) {
+	if s == nil {
+		return
+	}
+
+	s.attributes = append(s.attributes, spanAttribute{key: key, str: value, isText: true})
+}
+
+// SetError flags the span as failed with `err`, a nil error leaves the span untouched.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.err = err
+}
+
+// End ends the span and queues it for export, the span must not be used anymore but as the
+// parent of other spans.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.end = time.Now()
+	if exporter := currentSpanExporter(); exporter != nil {
+		exporter.enqueue(s)
+	}
+}
+
+// SetSpan sets the span tracing the processing of the context's block, the parent of the span
+// of its flush, see `FlushBlock`. It's cleared when the block ends.
+func (ctx *Context) SetSpan(span *Span) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.span = span
+}
+
+// activeSpanExporter holds the `*spanExporter` of the spans, see `currentSpanExporter`.
+var activeSpanExporter atomic.Value
+
+// currentSpanExporter returns the exporter of the spans, nil while tracing is disabled.
+func currentSpanExporter() *spanExporter {
+	exporter, _ := activeSpanExporter.Load().(*spanExporter)
+	return exporter
+}
+
+// spanExporter exports the ended spans, in batches, to an OpenTelemetry collector through the
+// OTLP/HTTP protocol using its JSON encoding.
+//
+// The OpenTelemetry SDK and its `otlptracehttp` exporter are not used on purpose: they bring
+// gRPC, the gRPC gateway and recent `protobuf`, `golang.org/x/net` and `golang.org/x/sys`
+// releases to the dependencies of this module, imported by readers and tooling, and of the
+// node. The encoding is checked against the OTLP schema by `TestTracing_OTLPSchema` of
+// `firehose/itest`, which decodes the exported payloads with the OTLP protobuf definitions.
+type spanExporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+	spans       chan *Span
+	flushes     chan chan struct{}
+	stops       chan chan struct{}
+
+	// failing is true while the export requests fail, only the first failure is logged
+	failing bool
+}
+
+// StartTracing starts exporting the spans to the OTLP/HTTP collector at `endpoint` (e.g.
+// `http://localhost:4318`), the spans being sent to its `/v1/traces` path as the ones of the
+// `serviceName` service. It's a no-op when `endpoint` is empty, tracing then stays disabled.
+func StartTracing(endpoint string, serviceName string) {
+	if endpoint == "" {
+		return
+	}
+
+	exporter := &spanExporter{
+		url:         strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: spanExportTimeout},
+		spans:       make(chan *Span, spanQueueSize),
+		flushes:     make(chan chan struct{}),
+		stops:       make(chan chan struct{}),
+	}
+	go exporter.run()
+
+	activeSpanExporter.Store(exporter)
+}
+
+// FlushSpans exports the spans ended so far, if tracing is enabled. It should be called before
+// the process exits.
+func FlushSpans() {
+	if exporter := currentSpanExporter(); exporter != nil {
+		done := make(chan struct{})
+		exporter.flushes <- done
+		<-done
+	}
+}
+
+// StopTracing exports the spans ended so far then stops exporting spans, if tracing is
+// enabled. Tracing stays disabled until started again, see `StartTracing`.
+func StopTracing() {
+	exporter := currentSpanExporter()
+	if exporter == nil {
+		return
+	}
+
+	activeSpanExporter.Store((*spanExporter)(nil))
+
+	done := make(chan struct{})
+	exporter.stops <- done
+	<-done
+}
+
+func (e *spanExporter) enqueue(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		tracingDroppedSpansCounter.Inc(1)
+	}
+}
+
+func (e *spanExporter) run() {
+	ticker := time.NewTicker(spanExportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, spanBatchSize)
+	export := func() {
+		if len(batch) > 0 {
+			e.export(batch)
+			batch = batch[:0]
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case span := <-e.spans:
+				if batch = append(batch, span); len(batch) >= spanBatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.spans:
+			if batch = append(batch, span); len(batch) >= spanBatchSize {
+				export()
+			}
+
+		case <-ticker.C:
+			export()
+
+		case done := <-e.flushes:
+			drain()
+			close(done)
+
+		case done := <-e.stops:
+			drain()
+			close(done)
+			return
+		}
+	}
+}
+
+func (e *spanExporter) export(spans []*Span) {
+	err := e.post(encodeSpans(spans, e.serviceName))
+	if err != nil {
+		tracingExportErrorCounter.Inc(1)
+		if !e.failing {
+			log.Warn("Failed to export Firehose tracing spans, dropping them until the collector recovers", "url", e.url, "err", err)
+		}
+		e.failing = true
+		return
+	}
+
+	if e.failing {
+		log.Info("Firehose tracing spans export recovered", "url", e.url)
+	}
+	e.failing = false
+}
+
+func (e *spanExporter) post(payload []byte) error {
+	response, err := e.client.Post(e.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("collector answered %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// encodeSpans encodes `spans`, of the `serviceName` service, as an OTLP
+// `ExportTraceServiceRequest` in its JSON encoding, identifiers being hex encoded and 64 bits
+// integers being strings.
+func encodeSpans(spans []*Span, serviceName string) []byte {
+	type value struct {
+		IntValue    string `json:"intValue,omitempty"`
+		StringValue string `json:"stringValue,omitempty"`
+	}
+	type attribute struct {
+		Key   string `json:"key"`
+		Value value  `json:"value"`
+	}
+	type status struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	type span struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		ParentSpanID      string      `json:"parentSpanId,omitempty"`
+		Name              string      `json:"name"`
+		Kind              int         `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []attribute `json:"attributes,omitempty"`
+		Status            status      `json:"status"`
+	}
+
+	encoded := make([]span, len(spans))
+	for i, s := range spans {
+		encoded[i] = span{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != [8]byte{} {
+			encoded[i].ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+
+		for _, attr := range s.attributes {
+			if attr.isText {
+				encoded[i].Attributes = append(encoded[i].Attributes, attribute{Key: attr.key, Value: value{StringValue: attr.str}})
+			} else {
+				encoded[i].Attributes = append(encoded[i].Attributes, attribute{Key: attr.key, Value: value{IntValue: strconv.FormatInt(attr.int, 10)}})
+			}
+		}
+
+		if s.err != nil {
+			encoded[i].Status = status{Code: 2, Message: s.err.Error()} // STATUS_CODE_ERROR
+		}
+	}
+
+	request := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []attribute{{Key: "service.name", Value: value{StringValue: serviceName}}},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": tracingScopeName},
+				"spans": encoded,
+			}},
+		}},
+	}
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		// Only made of strings and integers, it cannot happen
+		panic(fmt.Errorf("encode tracing spans: %w", err))
+	}
+
+	return payload
+}
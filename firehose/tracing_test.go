@@ -0,0 +1,154 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	} `json:"attributes"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+func (s exportedSpan) attribute(key string) string {
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			for _, value := range attr.Value {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+// withCollector starts tracing, as the `serviceName` service, to a collector recording the
+// exported spans, returned by the returned function once flushed.
+func withCollector(t *testing.T, serviceName string) func() []exportedSpan {
+	t.Helper()
+
+	var lock sync.Mutex
+	var spans []exportedSpan
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		payload, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var request struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []struct {
+						Key   string            `json:"key"`
+						Value map[string]string `json:"value"`
+					} `json:"attributes"`
+				} `json:"resource"`
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &request))
+
+		lock.Lock()
+		defer lock.Unlock()
+		for _, resource := range request.ResourceSpans {
+			require.Len(t, resource.Resource.Attributes, 1)
+			assert.Equal(t, "service.name", resource.Resource.Attributes[0].Key)
+			assert.Equal(t, serviceName, resource.Resource.Attributes[0].Value["stringValue"])
+
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(collector.Close)
+
+	StartTracing(collector.URL, serviceName)
+	t.Cleanup(StopTracing)
+
+	return func() []exportedSpan {
+		FlushSpans()
+
+		lock.Lock()
+		defer lock.Unlock()
+		return spans
+	}
+}
+
+func TestSpan_Disabled(t *testing.T) {
+	span := StartSpan("Process", nil)
+	assert.Nil(t, span)
+
+	// A disabled span is a no-op
+	span.SetInt("block.number", 1)
+	span.SetError(errors.New("failed"))
+	span.End()
+	FlushSpans()
+}
+
+func TestFlushBlock_Span(t *testing.T) {
+	defer func(enabled, syncEnabled bool, ctx *Context) {
+		Enabled, SyncInstrumentationEnabled, syncContext = enabled, syncEnabled, ctx
+	}(Enabled, SyncInstrumentationEnabled, syncContext)
+
+	exported := withCollector(t, "geth-mainnet")
+
+	Enabled, SyncInstrumentationEnabled, syncContext = true, true, NewContext(NewToBufferPrinter(1024), false)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7)})
+
+	process := StartSpan("Process", nil)
+	process.SetInt("block.number", 7)
+
+	ctx := NewBlockContextWithBuffer(bytes.NewBuffer(make([]byte, 0, 1024)))
+	ctx.StartBlock(block)
+	ctx.SetSpan(process)
+	ctx.EndBlock(block, nil)
+	payloadBytes := ctx.printer.(*ToBufferPrinter).buffer.Len()
+	require.NoError(t, ctx.FlushBlock())
+
+	process.SetError(errors.New("failed"))
+	process.End()
+
+	spans := exported()
+	require.Len(t, spans, 2)
+
+	flush, root := spans[0], spans[1]
+	assert.Equal(t, "firehose.FlushBlock", flush.Name)
+	assert.Equal(t, "7", flush.attribute("block.number"))
+	assert.Equal(t, strconv.Itoa(payloadBytes), flush.attribute("payload.bytes"))
+	assert.Equal(t, 0, flush.Status.Code)
+
+	assert.Equal(t, "Process", root.Name)
+	assert.Empty(t, root.ParentSpanID)
+	assert.Equal(t, 2, root.Status.Code)
+	assert.Equal(t, "failed", root.Status.Message)
+
+	assert.Equal(t, root.TraceID, flush.TraceID)
+	assert.Equal(t, root.SpanID, flush.ParentSpanID)
+	assert.Len(t, root.TraceID, 32)
+	assert.Len(t, root.SpanID, 16)
+}
@@ -0,0 +1,115 @@
+package firehose
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TransferKind denotes how value was moved by a `ValueTransfer`.
+type TransferKind string
+
+const (
+	// TransferKindCall is the value sent along a call or a contract creation
+	TransferKindCall TransferKind = "call"
+	// TransferKindSelfDestruct is the balance of a self-destructed contract sent to its beneficiary
+	TransferKindSelfDestruct TransferKind = "self_destruct"
+	// TransferKindReward is value minted or paid to the miner (block, uncle and transaction fee rewards)
+	TransferKindReward TransferKind = "reward"
+)
+
+// ValueTransfer is a transfer of value, that was not rolled back, out of the block or the
+// transaction it's extracted from, see `BlockTrace.ValueTransfers`.
+type ValueTransfer struct {
+	Kind TransferKind
+
+	// From is nil for rewards, their value is not taken from any account
+	From  *common.Address
+	To    common.Address
+	Value *big.Int
+
+	// Transaction is the transaction the transfer happened in, nil for block and uncle rewards
+	Transaction *TransactionTrace
+	Ordinal     uint64
+}
+
+// ValueTransfers returns the value transfers of the block, the ones of its transactions in
+// their execution order followed by the block and uncle rewards. A non-executed block has
+// only its rewards.
+func (b *BlockTrace) ValueTransfers() []*ValueTransfer {
+	var transfers []*ValueTransfer
+	for _, trx := range b.Transactions {
+		transfers = append(transfers, trx.ValueTransfers()...)
+	}
+
+	for _, change := range b.BalanceChanges {
+		if change.Reason == RewardMineBlockBalanceChangeReason || change.Reason == RewardMineUncleBalanceChangeReason {
+			transfers = append(transfers, rewardTransfer(nil, change))
+		}
+	}
+
+	return transfers
+}
+
+// ValueTransfers returns the value transfers of the transaction that were not rolled back, in
+// their execution order, followed by the transaction fee paid to the miner.
+func (trx *TransactionTrace) ValueTransfers() []*ValueTransfer {
+	var transfers []*ValueTransfer
+
+	// A failed call rolls back the state changes of its whole sub-tree
+	rolledBack := map[uint64]bool{}
+	for _, call := range trx.Calls {
+		rolledBack[call.Index] = call.Failed || (call.Depth > 0 && rolledBack[call.ParentIndex])
+		if rolledBack[call.Index] {
+			continue
+		}
+
+		if call.Value != nil && call.Value.Sign() > 0 && call.CallType != CallTypeDelegate {
+			caller := call.Caller
+			transfers = append(transfers, &ValueTransfer{Kind: TransferKindCall, From: &caller, To: call.Address, Value: call.Value, Transaction: trx, Ordinal: call.BeginOrdinal})
+		}
+
+		for _, change := range call.BalanceChanges {
+			if change.Reason != SuicideRefundBalanceChangeReason || call.Address == change.Address {
+				continue
+			}
+
+			if value := balanceDelta(change); value.Sign() > 0 {
+				contract := call.Address
+				transfers = append(transfers, &ValueTransfer{Kind: TransferKindSelfDestruct, From: &contract, To: change.Address, Value: value, Transaction: trx, Ordinal: change.Ordinal})
+			}
+		}
+	}
+
+	// The self-destructed balances are recorded within their call, after its sub-calls
+	sort.SliceStable(transfers, func(i, j int) bool { return transfers[i].Ordinal < transfers[j].Ordinal })
+
+	for _, change := range trx.BalanceChanges {
+		if change.Reason == RewardTransactionFeeBalanceChangeReason {
+			transfers = append(transfers, rewardTransfer(trx, change))
+		}
+	}
+
+	return transfers
+}
+
+func rewardTransfer(trx *TransactionTrace, change *BalanceChange) *ValueTransfer {
+	return &ValueTransfer{Kind: TransferKindReward, To: change.Address, Value: balanceDelta(change), Transaction: trx, Ordinal: change.Ordinal}
+}
+
+func balanceDelta(change *BalanceChange) *big.Int {
+	if change.Delta != nil {
+		return new(big.Int).Set(change.Delta)
+	}
+
+	delta := new(big.Int)
+	if change.New != nil {
+		delta.Set(change.New)
+	}
+	if change.Old != nil {
+		delta.Sub(delta, change.Old)
+	}
+
+	return delta
+}
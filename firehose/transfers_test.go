@@ -0,0 +1,67 @@
+package firehose
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockTrace_ValueTransfers(t *testing.T) {
+	var (
+		sender   = common.Address{0x01}
+		contract = common.Address{0xcc}
+		callee   = common.Address{0xdd}
+		coinbase = common.Address{0xcb}
+	)
+
+	trx := &TransactionTrace{
+		Calls: []*Call{
+			{Index: 1, Caller: sender, Address: contract, CallType: CallTypeCall, Value: big.NewInt(10), BeginOrdinal: 1, BalanceChanges: []*BalanceChange{
+				{Address: sender, Old: big.NewInt(0), New: big.NewInt(7), Reason: SuicideRefundBalanceChangeReason, Ordinal: 9},
+			}},
+			// Rolled back along its whole sub-tree
+			{Index: 2, ParentIndex: 1, Depth: 1, Caller: contract, Address: callee, CallType: CallTypeCall, Value: big.NewInt(3), Failed: true, BeginOrdinal: 2},
+			{Index: 3, ParentIndex: 2, Depth: 2, Caller: callee, Address: sender, CallType: CallTypeCall, Value: big.NewInt(1), BeginOrdinal: 3},
+			// Delegate calls move no value, the parent's one is only visible
+			{Index: 4, ParentIndex: 1, Depth: 1, Caller: contract, Address: callee, CallType: CallTypeDelegate, BeginOrdinal: 5},
+			{Index: 5, ParentIndex: 1, Depth: 1, Caller: contract, Address: callee, CallType: CallTypeCall, Value: big.NewInt(2), BeginOrdinal: 7},
+		},
+		BalanceChanges: []*BalanceChange{
+			{Address: coinbase, New: big.NewInt(21), Reason: RewardTransactionFeeBalanceChangeReason, Ordinal: 11},
+		},
+	}
+	block := &BlockTrace{
+		Transactions: []*TransactionTrace{trx},
+		BalanceChanges: []*BalanceChange{
+			{Address: coinbase, Old: big.NewInt(21), New: big.NewInt(100), Reason: RewardMineBlockBalanceChangeReason, Ordinal: 12},
+		},
+	}
+
+	transfers := block.ValueTransfers()
+	require.Len(t, transfers, 5)
+
+	expected := []struct {
+		kind  TransferKind
+		from  *common.Address
+		to    common.Address
+		value int64
+	}{
+		{TransferKindCall, &sender, contract, 10},
+		{TransferKindCall, &contract, callee, 2},
+		{TransferKindSelfDestruct, &contract, sender, 7},
+		{TransferKindReward, nil, coinbase, 21},
+		{TransferKindReward, nil, coinbase, 79},
+	}
+	for i, want := range expected {
+		assert.Equal(t, want.kind, transfers[i].Kind, "transfer %d", i)
+		assert.Equal(t, want.from, transfers[i].From, "transfer %d", i)
+		assert.Equal(t, want.to, transfers[i].To, "transfer %d", i)
+		assert.Equal(t, want.value, transfers[i].Value.Int64(), "transfer %d", i)
+	}
+
+	assert.Equal(t, trx, transfers[3].Transaction)
+	assert.Nil(t, transfers[4].Transaction)
+}
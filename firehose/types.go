@@ -1,65 +1,47 @@
 package firehose
 
 import (
+	"fmt"
 	"math/big"
-
-	"github.com/golang-collections/collections/stack"
 )
 
 var EmptyValue = new(big.Int)
 
 type logItem = map[string]interface{}
 
-type ExtendedStack struct {
-	stack.Stack
-}
+// CallType denotes the Firehose kind of a call, it's emitted along the EVM opcode that
+// triggered the call, which is more precise (e.g. `CREATE` and `CREATE2` are both of
+// kind `CallTypeCreate`).
+//
+// When adding a new call kind (e.g. AUTHCALL), add it to `knownCallTypes` too, the
+// context refuses to record any call kind it does not know.
+type CallType string
+
+const (
+	CallTypeCall     CallType = "CALL"
+	CallTypeCallCode CallType = "CALLCODE"
+	CallTypeDelegate CallType = "DELEGATE"
+	CallTypeStatic   CallType = "STATIC"
+	CallTypeCreate   CallType = "CREATE"
+)
 
-func (s *ExtendedStack) MustPop() string {
-	popped := s.Pop()
-	if popped == nil {
-		panic("at least one element must exist in the index stack at this point")
-	}
+var knownCallTypes = map[CallType]bool{
+	CallTypeCall:     true,
+	CallTypeCallCode: true,
+	CallTypeDelegate: true,
+	CallTypeStatic:   true,
+	CallTypeCreate:   true,
+}
 
-	return popped.(string)
+// IsKnown returns true if the call type is a known Firehose call kind.
+func (t CallType) IsKnown() bool {
+	return knownCallTypes[t]
 }
 
-func (s *ExtendedStack) MustPeek() string {
-	peeked := s.Peek()
-	if peeked == nil {
-		panic("at least one element must exist in the index stack at this point")
+func (t CallType) mustBeKnown() string {
+	if !t.IsKnown() {
+		panic(fmt.Errorf("firehose unknown call type %q, it must be defined in 'knownCallTypes' after proper instrumentation", string(t)))
 	}
 
-	return peeked.(string)
+	return string(t)
 }
-
-// BalanceChangeReason denotes a reason why a given balance change occurred.
-//
-// **Important!** For easier extraction of all possible `BalanceChangeReason`, ensure you always
-//
-//	define valid value using the type wrapper so it matches the extraction
-//	regex `BalanceChangeReason\("[a-z0-9_]+"\)`. All other values that should not
-//	be matched can be defined here using `var X BalanceChangeReason = "something"`
-//	since does not match the above regexp.
-type BalanceChangeReason string
-
-// IgnoredBalanceChangeReason **On purposely defined using a different syntax, check `BalanceChangeReason` type doc above**
-var IgnoredBalanceChangeReason BalanceChangeReason = "ignored"
-
-// GasChangeReason denotes a reason why a given gas cost was incurred for an operation.
-//
-// **Important!** For easier extraction of all possible `GasChangeReason`, ensure you always
-//
-//	define valid value using the type wrapper so it matches the extraction
-//	regex `GasChangeReason\("[a-z0-9_]+"\)`. All other values that should not
-//	be matched can be defined here using `var X GasChangeReason = "something"`
-//	since does not match the above regexp.
-type GasChangeReason string
-
-// RefundAfterExecutionGasChangeReason to be used for all gas refund operation
-var RefundAfterExecutionGasChangeReason = GasChangeReason("refund_after_execution")
-
-// FailedExecutionGasChangeReason to be used for all call failure remaining gas burning operation
-var FailedExecutionGasChangeReason = GasChangeReason("failed_execution")
-
-// IgnoredGasChangeReason **On purposely defined using a different syntax, check `GasChangeReason` type doc above**
-var IgnoredGasChangeReason GasChangeReason = "ignored"
@@ -13,14 +13,23 @@ import (
 var errFirehoseUnknownType = errors.New("firehose unknown tx type")
 var sanitizeRegexp = regexp.MustCompile(`[\t( ){2,}]+`)
 
-func init() {
-	firehoseKnownTxTypes := map[byte]bool{types.LegacyTxType: true, types.AccessListTxType: true}
-
+// setCodeTxType is the type of the EIP-7702 set code transactions, the upstream release the
+// module is built against does not define it.
+const setCodeTxType = 0x04
+
+// firehoseKnownTxTypes are the transaction types handled by the Firehose instrumentation.
+var firehoseKnownTxTypes = map[byte]bool{types.LegacyTxType: true, types.AccessListTxType: true, setCodeTxType: true}
+
+// validateKnownTransactionTypes checks that the transaction types of the chain model the node
+// is built with are exactly the ones known by Firehose, it's called by `Init` and not when the
+// package is imported: readers and tooling importing the module against an upstream release
+// never instrument a node.
+func validateKnownTransactionTypes() error {
 	for txType := byte(0); txType < 255; txType++ {
 		err := validateFirehoseKnownTransactionType(txType, firehoseKnownTxTypes[txType])
 		if err != nil {
-			panic(fmt.Errorf(sanitizeRegexp.ReplaceAllString(`
-				If you see this panic message, it comes from a sanity check of Firehose instrumentation
+			return fmt.Errorf(sanitizeRegexp.ReplaceAllString(`
+				If you see this error message, it comes from a sanity check of Firehose instrumentation
 				around Ethereum transaction types.
 
 				Over time, Ethereum added new transaction types but there is no easy way for Firehose to
@@ -28,7 +37,7 @@ func init() {
 				have a runtime check at initialization of the process that encode/decode each possible
 				transaction's receipt and check proper handling.
 
-				This panic means that a transaction that Firehose don't know about has most probably
+				This error means that a transaction that Firehose don't know about has most probably
 				been added and you must take **great care** to instrument it. One of the most important place
 				to look is in 'firehose.StartTransaction' where it should be properly handled. Think
 				carefully, read the EIP and ensure that any new "semantic" the transactions type's is
@@ -36,7 +45,7 @@ func init() {
 
 				For example, when London fork appeared, semantic of 'GasPrice' changed and it required
 				a different computation for 'GasPrice' when 'DynamicFeeTx' transaction were added. If you determined
-				it was indeed a new transaction's type, fix 'firehoseKnownTxTypes' variable above to include it
+				it was indeed a new transaction's type, fix 'firehoseKnownTxTypes' variable to include it
 				as a known Firehose type (after proper instrumentation of course).
 
 				It's also possible the test itself is now flaky, we do 'receipt := types.Receipt{Type: <type>}'
@@ -44,9 +53,11 @@ func init() {
 				new transaction types but could be now generate false positive.
 
 				Received error: %w
-			`, " "), err))
+			`, " "), err)
 		}
 	}
+
+	return nil
 }
 
 func validateFirehoseKnownTransactionType(txType byte, isKnownFirehoseTxType bool) error {
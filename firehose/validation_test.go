@@ -1,6 +1,7 @@
 package firehose
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -14,6 +15,7 @@ func Test_validateKnownTransactionTypes(t *testing.T) {
 		{"legacy", 0, true, nil},
 		{"access_list", 1, true, nil},
 		{"inexistant", 255, false, nil},
+		{"known_unsupported", 255, true, errors.New("firehose known type but encoding RLP of receipt led to 'types.ErrTxTypeNotSupported'")},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
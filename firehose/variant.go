@@ -0,0 +1,88 @@
+package firehose
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Variant is a chain variant, a fork of the node whose consensus engine changes the state
+// beyond the standard Ethereum rules, like Parlia (BSC) transferring the system rewards and
+// updating the validator set through system transactions executed at the end of the block.
+//
+// A variant is registered through `RegisterVariant` while initializing the package of its
+// engine, enabling the hooks it declares. The balances its engine moves out of any transaction
+// (e.g. the system reward transfers) are recorded after the `FINALIZE_BLOCK` record like the
+// block rewards, with the reasons it registers through `RegisterBalanceChangeReason`. The
+// ones adjusting a balance by a signed amount are recorded through `RecordBalanceDelta`.
+type Variant struct {
+	// Name is the variant name recorded by the `INIT` record in place of `DefaultVariant`
+	Name string
+
+	// SystemTransactions is true when the engine executes system transactions once the
+	// block is finalized, see `Context.StartSystemTransaction`
+	SystemTransactions bool
+}
+
+var registeredVariant *Variant
+
+// RegisterVariant registers the chain variant the node is built for, it must only be called
+// while initializing packages and panics if the variant name is malformed or if a variant is
+// already registered.
+func RegisterVariant(variant *Variant) {
+	if !changeReasonRegexp.MatchString(variant.Name) {
+		panic(fmt.Errorf("firehose variant %q is invalid, it must match %s", variant.Name, changeReasonRegexp))
+	}
+
+	if registeredVariant != nil {
+		panic(fmt.Errorf("firehose variant %q is already registered, cannot register %q", registeredVariant.Name, variant.Name))
+	}
+
+	registeredVariant = variant
+}
+
+// RegisteredVariant returns the registered chain variant, nil when the node is not built for
+// a variant.
+func RegisteredVariant() *Variant {
+	return registeredVariant
+}
+
+// variantName returns the name of the registered variant, `DefaultVariant` if none is.
+func variantName() string {
+	if registeredVariant != nil {
+		return registeredVariant.Name
+	}
+
+	return DefaultVariant
+}
+
+// StartSystemTransaction starts `tx` sent by `from`, a system transaction executed by the
+// consensus engine of the registered variant once the block is finalized, the records of
+// the transaction are preceded by a `SYSTEM_TRX` record flagging it. It's ended by
+// `EndTransaction` like any other transaction.
+//
+// It panics if the registered variant does not declare system transactions.
+func (ctx *Context) StartSystemTransaction(tx *types.Transaction, txIndex uint, from common.Address) {
+	if ctx == nil {
+		return
+	}
+
+	if registeredVariant == nil || !registeredVariant.SystemTransactions {
+		panic("starting a system transaction while the registered variant does not declare any")
+	}
+
+	if !ctx.inBlock.Load() {
+		panic("starting a system transaction while not in a block scope")
+	}
+
+	ctx.printer.Print("SYSTEM_TRX")
+
+	// System transactions are not priced, the base fee is not needed
+	ctx.StartTransaction(tx, txIndex, nil)
+	ctx.RecordTrxFrom(from)
+
+	if ctx.trace != nil && ctx.trace.trx != nil {
+		ctx.trace.trx.System = true
+	}
+}
@@ -0,0 +1,67 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterVariant(t *testing.T) {
+	defer func(variant *Variant) { registeredVariant = variant }(registeredVariant)
+	registeredVariant = nil
+
+	assert.Equal(t, DefaultVariant, variantName())
+
+	assert.PanicsWithError(t, `firehose variant "Not Valid" is invalid, it must match ^[a-z0-9_]+$`, func() {
+		RegisterVariant(&Variant{Name: "Not Valid"})
+	})
+
+	variant := &Variant{Name: "parlia", SystemTransactions: true}
+	RegisterVariant(variant)
+	assert.Equal(t, variant, RegisteredVariant())
+	assert.Equal(t, "parlia", variantName())
+
+	assert.PanicsWithError(t, `firehose variant "parlia" is already registered, cannot register "other"`, func() {
+		RegisterVariant(&Variant{Name: "other"})
+	})
+}
+
+func TestContext_StartSystemTransaction(t *testing.T) {
+	defer func(variant *Variant) { registeredVariant = variant }(registeredVariant)
+	registeredVariant = nil
+
+	var (
+		coinbase = common.Address{0xcb}
+		validset = common.Address{0x10, 0x00}
+		block    = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1), Coinbase: coinbase})
+		tx       = types.NewTransaction(0, validset, common.Big0, 100_000, common.Big0, nil)
+	)
+
+	buffer := bytes.NewBuffer(nil)
+	ctx := NewBlockContextWithBuffer(buffer)
+	ctx.EnableBlockTrace()
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+
+	assert.PanicsWithValue(t, "starting a system transaction while the registered variant does not declare any", func() {
+		ctx.StartSystemTransaction(tx, 0, coinbase)
+	})
+
+	registeredVariant = &Variant{Name: "parlia", SystemTransactions: true}
+	ctx.StartSystemTransaction(tx, 0, coinbase)
+	ctx.EndTransaction(&types.Receipt{Status: types.ReceiptStatusSuccessful})
+	ctx.EndBlock(block, big.NewInt(1))
+
+	assert.True(t, strings.Contains(buffer.String(), "FIRE FINALIZE_BLOCK 1\nFIRE SYSTEM_TRX\nFIRE BEGIN_APPLY_TRX "), "no system transaction in:\n%s", buffer.String())
+
+	trace := ctx.BlockTrace()
+	require.Len(t, trace.Transactions, 1)
+	assert.True(t, trace.Transactions[0].System)
+	assert.Equal(t, coinbase, trace.Transactions[0].From)
+}
@@ -0,0 +1,20 @@
+package firehose
+
+import (
+	"fmt"
+)
+
+const (
+	ProtocolVersionMajor = 2  // Major version component of the Firehose protocol
+	ProtocolVersionMinor = 50 // Minor version component of the Firehose protocol
+
+	// DefaultVariant is the variant name recorded by the `INIT` record when no chain variant
+	// is registered, see `RegisterVariant`.
+	DefaultVariant = "geth"
+)
+
+// ProtocolVersion returns the version of the Firehose protocol, the format of the records
+// emitted, recorded by the `INIT` record.
+func ProtocolVersion() string {
+	return fmt.Sprintf("%d.%d", ProtocolVersionMajor, ProtocolVersionMinor)
+}
@@ -17,6 +17,7 @@
 package debug
 
 import (
+	"expvar"
 	"fmt"
 	"io"
 	"net/http"
@@ -110,11 +111,168 @@ var (
 		Name:  "firehose-block-progress",
 		Usage: "Activate/deactivate Firehose block progress output instrumentation, disabled by default",
 	}
+	firehoseNonExecutedBlocksFlag = cli.BoolFlag{
+		Name:  "firehose-non-executed-blocks",
+		Usage: "Keep the configured fast/snap sync mode and emit the blocks imported without execution (below the sync pivot) out of their header and receipts, flagged as non-executed, disabled by default",
+	}
+	firehoseSideChainBlocksFlag = cli.BoolFlag{
+		Name:  "firehose-side-chain-blocks",
+		Usage: "Emit the blocks imported on a side chain, marked as non-canonical along with the canonical block they fork from, disabled by default",
+	}
+	firehosePendingTransactionsFlag = cli.BoolFlag{
+		Name:  "firehose-pending-transactions",
+		Usage: "Emit the transactions entering the transaction pool and the ones evicted or replaced, disabled by default",
+	}
+	firehosePendingEffectsFlag = cli.BoolFlag{
+		Name:  "firehose-pending-effects",
+		Usage: "Speculatively execute the transactions becoming executable in the transaction pool on top of the current head and emit their predicted effects, disabled by default",
+	}
+	firehoseProposedBlocksFlag = cli.BoolFlag{
+		Name:  "firehose-proposed-blocks",
+		Usage: "Emit each candidate block assembled by the miner, flagged as proposed, before it's sealed, requires --firehose-mining-enabled, disabled by default",
+	}
+	firehoseCreationCodeFlag = cli.BoolFlag{
+		Name:  "firehose-creation-code",
+		Usage: "Record the init code of each contract creation in its call parameters and the runtime code it returned in its call end, disabled by default",
+	}
+	firehoseCreationCodeMaxSizeFlag = cli.IntFlag{
+		Name:  "firehose-creation-code-max-size",
+		Usage: "Amount of bytes above which the init or runtime code of a contract creation is not recorded, only its hash is, 0 for no limit",
+		Value: firehose.CreationCodeMaxSize,
+	}
+	firehoseHeartbeatIntervalFlag = cli.DurationFlag{
+		Name:  "firehose-heartbeat-interval",
+		Usage: "Interval at which a Firehose heartbeat holding the chain head is emitted while no block is emitted, 0 disables heartbeats",
+		Value: firehose.HeartbeatInterval,
+	}
+	firehoseBlockWitnessFlag = cli.BoolFlag{
+		Name:  "firehose-block-witness",
+		Usage: "Emit, for each executed block, the trie nodes and contract code of the parent state accessed while executing it, enabling stateless verification, disabled by default",
+	}
+	firehoseAccountTouchesFlag = cli.BoolFlag{
+		Name:  "firehose-account-touches",
+		Usage: "Record the EIP-158 touches of accounts (zero value balance additions of static calls) as Firehose account touch records, disabled by default",
+	}
+	firehoseTimingsFlag = cli.BoolFlag{
+		Name:  "firehose-timings",
+		Usage: "Record the wall-clock time spent applying each transaction and each block in their Firehose end records, and the emission time of each block on its markers, disabled by default",
+	}
+	firehoseBlockStoreRetentionFlag = cli.Uint64Flag{
+		Name:  "firehose-block-store-retention",
+		Usage: "Number of most recent block heights whose emitted Firehose blocks are stored in the database and served by firehose_getBlock, 0 disables the storage",
+		Value: firehose.BlockStoreRetention,
+	}
+	firehoseCallIndexFlag = cli.BoolFlag{
+		Name:  "firehose-call-index",
+		Usage: "Index, in the database, the calls of each emitted Firehose block by caller and callee address, served by firehose_getCallsByAddress, disabled by default",
+	}
+	firehoseTransferIndexFlag = cli.BoolFlag{
+		Name:  "firehose-transfer-index",
+		Usage: "Index, in the database, the value transfers (calls, self-destructs, rewards) of each emitted Firehose block by sender and recipient address, served by firehose_getTransfers, disabled by default",
+	}
 	firehoseGenesisFileFlag = cli.StringFlag{
 		Name:  "firehose-genesis-file",
 		Usage: "On private chains where the genesis config is not known to Geth, you **must** provide the 'genesis.json' file path for proper instrumentation of genesis block",
 		Value: "",
 	}
+	firehoseSinkBatchSizeFlag = cli.IntFlag{
+		Name:  "firehose-sink-batch-size",
+		Usage: "Amount of bytes of Firehose output accumulated in memory before being written to standard output, 0 writes each block right away",
+		Value: firehose.SinkBatchSize,
+	}
+	firehoseSinkBatchFlushIntervalFlag = cli.DurationFlag{
+		Name:  "firehose-sink-batch-flush-interval",
+		Usage: "Maximum amount of time Firehose output stays buffered in memory when --firehose-sink-batch-size is set",
+		Value: firehose.SinkBatchFlushInterval,
+	}
+	firehoseSinkThrottleThresholdFlag = cli.IntFlag{
+		Name:  "firehose-sink-throttle-threshold",
+		Usage: "Amount of bytes of Firehose output waiting to be written to standard output above which block import is slowed down instead of blocking on the consumer, 0 disables throttling",
+		Value: firehose.SinkThrottleThreshold,
+	}
+	firehoseSinkThrottleMaxDelayFlag = cli.DurationFlag{
+		Name:  "firehose-sink-throttle-max-delay",
+		Usage: "Maximum amount of time block import is slowed down, after each block, while the Firehose output backlog is above --firehose-sink-throttle-threshold",
+		Value: firehose.SinkThrottleMaxDelay,
+	}
+	firehoseSinkRateLimitFlag = cli.IntFlag{
+		Name:  "firehose-sink-rate-limit",
+		Usage: "Amount of bytes per second, on average, of Firehose output written to standard output, writes beyond it wait and slow down block import, 0 disables the rate limit",
+		Value: firehose.SinkRateLimit,
+	}
+	firehoseSinkRateBurstFlag = cli.IntFlag{
+		Name:  "firehose-sink-rate-burst",
+		Usage: "Amount of bytes of Firehose output that can be written at once above --firehose-sink-rate-limit after the output was idle, 0 uses the rate limit",
+		Value: firehose.SinkRateBurst,
+	}
+	firehoseBlockBufferSizeFlag = cli.IntFlag{
+		Name:  "firehose-block-buffer-size",
+		Usage: "Initial amount of bytes of the buffer accumulating the Firehose data of a block, grown and shrunk from then on based on the blocks observed",
+		Value: firehose.BlockBufferSize,
+	}
+	firehoseTxBufferSizeFlag = cli.IntFlag{
+		Name:  "firehose-tx-buffer-size",
+		Usage: "Initial amount of bytes of the buffer accumulating the Firehose data of a transaction, grown and shrunk from then on based on the transactions observed",
+		Value: firehose.TxBufferSize,
+	}
+	firehoseBufferMemoryCapFlag = cli.IntFlag{
+		Name:  "firehose-buffer-memory-cap",
+		Usage: "Amount of bytes the Firehose block and transaction buffers can hold together, the import of a block whose Firehose data does not fit fails, 0 for no cap",
+		Value: firehose.BufferMemoryCap,
+	}
+	firehoseBlockSegmentSizeFlag = cli.IntFlag{
+		Name:  "firehose-block-segment-size",
+		Usage: "Amount of bytes of Firehose data accumulated for a block above which it's emitted right away as a segment of the block, bounding the memory used by giant blocks, 0 emits blocks at once",
+		Value: firehose.BlockSegmentSize,
+	}
+	firehoseSummaryIntervalFlag = cli.Uint64Flag{
+		Name:  "firehose-summary-interval",
+		Usage: "Amount of Firehose blocks emitted between two log lines summarizing the Firehose throughput over them, 0 to disable the summary",
+		Value: firehose.SummaryInterval,
+	}
+	firehoseOTLPEndpointFlag = cli.StringFlag{
+		Name:  "firehose-otlp-endpoint",
+		Usage: "OpenTelemetry collector OTLP/HTTP endpoint (e.g. 'http://localhost:4318') to which the spans of block processing and Firehose block flushes are exported, empty to disable tracing",
+		Value: firehose.OTLPEndpoint,
+	}
+	firehoseOTLPServiceNameFlag = cli.StringFlag{
+		Name:  "firehose-otlp-service-name",
+		Usage: "Service name ('service.name' resource attribute) of the spans exported to the OpenTelemetry collector, see --firehose-otlp-endpoint",
+		Value: firehose.OTLPServiceName,
+	}
+	firehoseOutputFormatFlag = cli.StringFlag{
+		Name:  "firehose-output-format",
+		Usage: "Format of the Firehose output written to standard output, one of 'text' (positional records read by the Firehose readers) or 'jsonl' (one JSON object per record with named and typed fields, for ad hoc consumption)",
+		Value: string(firehose.SinkOutputFormat),
+	}
+	firehoseDryRunFlag = cli.BoolFlag{
+		Name:  "firehose-dry-run",
+		Usage: "Perform all the Firehose instrumentation and encoding but discard the output, accounting for the lines and bytes of each record category in metrics to size a full history run",
+	}
+	firehoseSinkWriteFailurePolicyFlag = cli.StringFlag{
+		Name:  "firehose-sink-write-failure-policy",
+		Usage: "What to do when Firehose output cannot be written to standard output, one of 'crash' (halt the node), 'pause-sync' (stop importing until writes succeed) or 'drop-and-log' (drop the data, corrupting the stream)",
+		Value: string(firehose.SinkWriteFailurePolicy),
+	}
+	firehoseBlockReplayPolicyFlag = cli.StringFlag{
+		Name:  "firehose-block-replay-policy",
+		Usage: "What to do with the blocks emitted again after a restart while they were already emitted completely before it, one of 'mark' (emit them flagged by a REPLAYED_BLOCK record) or 'skip' (do not emit them)",
+		Value: string(firehose.BlockReplayPolicy),
+	}
+	firehoseSelfCheckFlag = cli.StringFlag{
+		Name:  "firehose-self-check",
+		Usage: "Verify at each block end that recorded balance and gas changes reconcile with block accounting, one of 'off', 'log' (log mismatches) or 'abort' (halt the node on mismatch)",
+		Value: string(firehose.SelfCheck),
+	}
+	firehoseStrictChangeReasonsFlag = cli.BoolFlag{
+		Name:  "firehose-strict-change-reasons",
+		Usage: "Halt the node when a balance or gas change is recorded with an unregistered reason, list them with 'geth firehose reasons', disabled by default",
+	}
+	firehoseBadBlocksDirFlag = cli.StringFlag{
+		Name:  "firehose-bad-blocks-dir",
+		Usage: "Directory where the partially captured Firehose trace of a block failing processing or consensus validation is dumped along with the failing check, the system's temporary directory by default",
+		Value: firehose.BadBlocksDir,
+	}
 )
 
 // Flags holds all command-line flags required for debugging.
@@ -127,7 +285,9 @@ var Flags = []cli.Flag{
 // FirehoseFlags holds all StreamingFast Firehose related command-line flags.
 var FirehoseFlags = []cli.Flag{
 	firehoseEnabledFlag, firehoseSyncInstrumentationFlag, firehoseMiningEnabledFlag, firehoseBlockProgressFlag,
-	firehoseGenesisFileFlag,
+	firehoseNonExecutedBlocksFlag, firehoseSideChainBlocksFlag, firehosePendingTransactionsFlag,
+	firehosePendingEffectsFlag, firehoseProposedBlocksFlag, firehoseCreationCodeFlag, firehoseCreationCodeMaxSizeFlag, firehoseHeartbeatIntervalFlag, firehoseBlockWitnessFlag, firehoseAccountTouchesFlag, firehoseTimingsFlag, firehoseBlockStoreRetentionFlag, firehoseCallIndexFlag, firehoseTransferIndexFlag, firehoseGenesisFileFlag, firehoseSinkBatchSizeFlag, firehoseSinkBatchFlushIntervalFlag,
+	firehoseSinkThrottleThresholdFlag, firehoseSinkThrottleMaxDelayFlag, firehoseSinkRateLimitFlag, firehoseSinkRateBurstFlag, firehoseBlockBufferSizeFlag, firehoseTxBufferSizeFlag, firehoseBufferMemoryCapFlag, firehoseBlockSegmentSizeFlag, firehoseSummaryIntervalFlag, firehoseOTLPEndpointFlag, firehoseOTLPServiceNameFlag, firehoseOutputFormatFlag, firehoseDryRunFlag, firehoseSinkWriteFailurePolicyFlag, firehoseBlockReplayPolicyFlag, firehoseSelfCheckFlag, firehoseStrictChangeReasonsFlag, firehoseBadBlocksDirFlag,
 }
 
 var (
@@ -191,13 +351,68 @@ func Setup(ctx *cli.Context, firehoseGenesis *core.Genesis, firehoseGethVersion
 		StartPProf(address, !ctx.GlobalIsSet("metrics.addr"))
 	}
 
+	firehose.SinkBatchSize = ctx.GlobalInt(firehoseSinkBatchSizeFlag.Name)
+	firehose.SinkBatchFlushInterval = ctx.GlobalDuration(firehoseSinkBatchFlushIntervalFlag.Name)
+	firehose.SinkThrottleThreshold = ctx.GlobalInt(firehoseSinkThrottleThresholdFlag.Name)
+	firehose.SinkThrottleMaxDelay = ctx.GlobalDuration(firehoseSinkThrottleMaxDelayFlag.Name)
+	firehose.SinkRateLimit = ctx.GlobalInt(firehoseSinkRateLimitFlag.Name)
+	firehose.SinkRateBurst = ctx.GlobalInt(firehoseSinkRateBurstFlag.Name)
+	firehose.BlockBufferSize = ctx.GlobalInt(firehoseBlockBufferSizeFlag.Name)
+	firehose.TxBufferSize = ctx.GlobalInt(firehoseTxBufferSizeFlag.Name)
+	firehose.BufferMemoryCap = ctx.GlobalInt(firehoseBufferMemoryCapFlag.Name)
+	firehose.BlockSegmentSize = ctx.GlobalInt(firehoseBlockSegmentSizeFlag.Name)
+	firehose.SummaryInterval = ctx.GlobalUint64(firehoseSummaryIntervalFlag.Name)
+	firehose.OTLPEndpoint = ctx.GlobalString(firehoseOTLPEndpointFlag.Name)
+	firehose.OTLPServiceName = ctx.GlobalString(firehoseOTLPServiceNameFlag.Name)
+
+	outputFormat, err := firehose.ParseOutputFormat(ctx.GlobalString(firehoseOutputFormatFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid --%s flag: %w", firehoseOutputFormatFlag.Name, err)
+	}
+	firehose.SinkOutputFormat = outputFormat
+	firehose.DryRun = ctx.GlobalBool(firehoseDryRunFlag.Name)
+
+	policy, err := firehose.ParseWriteFailurePolicy(ctx.GlobalString(firehoseSinkWriteFailurePolicyFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid --%s flag: %w", firehoseSinkWriteFailurePolicyFlag.Name, err)
+	}
+	firehose.SinkWriteFailurePolicy = policy
+
+	replayPolicy, err := firehose.ParseReplayPolicy(ctx.GlobalString(firehoseBlockReplayPolicyFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid --%s flag: %w", firehoseBlockReplayPolicyFlag.Name, err)
+	}
+	firehose.BlockReplayPolicy = replayPolicy
+
+	selfCheck, err := firehose.ParseSelfCheckMode(ctx.GlobalString(firehoseSelfCheckFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid --%s flag: %w", firehoseSelfCheckFlag.Name, err)
+	}
+	firehose.SelfCheck = selfCheck
+	firehose.StrictChangeReasons = ctx.GlobalBool(firehoseStrictChangeReasonsFlag.Name)
+	firehose.NonExecutedBlocksEnabled = ctx.GlobalBool(firehoseNonExecutedBlocksFlag.Name)
+	firehose.BadBlocksDir = ctx.GlobalString(firehoseBadBlocksDirFlag.Name)
+	firehose.SideChainBlocksEnabled = ctx.GlobalBool(firehoseSideChainBlocksFlag.Name)
+	firehose.PendingTransactionsEnabled = ctx.GlobalBool(firehosePendingTransactionsFlag.Name)
+	firehose.PendingEffectsEnabled = ctx.GlobalBool(firehosePendingEffectsFlag.Name)
+	firehose.ProposedBlocksEnabled = ctx.GlobalBool(firehoseProposedBlocksFlag.Name)
+	firehose.HeartbeatInterval = ctx.GlobalDuration(firehoseHeartbeatIntervalFlag.Name)
+	firehose.BlockWitnessEnabled = ctx.GlobalBool(firehoseBlockWitnessFlag.Name)
+	firehose.AccountTouchesEnabled = ctx.GlobalBool(firehoseAccountTouchesFlag.Name)
+	firehose.TimingsEnabled = ctx.GlobalBool(firehoseTimingsFlag.Name)
+	firehose.CreationCodeEnabled = ctx.GlobalBool(firehoseCreationCodeFlag.Name)
+	firehose.CreationCodeMaxSize = ctx.GlobalInt(firehoseCreationCodeMaxSizeFlag.Name)
+	firehose.BlockStoreRetention = ctx.GlobalUint64(firehoseBlockStoreRetentionFlag.Name)
+	firehose.CallIndexEnabled = ctx.GlobalBool(firehoseCallIndexFlag.Name)
+	firehose.TransferIndexEnabled = ctx.GlobalBool(firehoseTransferIndexFlag.Name)
+
 	if err := firehose.Init(ctx.GlobalBool(firehoseEnabledFlag.Name),
 		ctx.GlobalBoolT(firehoseSyncInstrumentationFlag.Name),
 		ctx.GlobalBool(firehoseMiningEnabledFlag.Name),
 		ctx.GlobalBool(firehoseBlockProgressFlag.Name),
-		firehoseGenesis,
+		core.NewFirehoseGenesisView(firehoseGenesis),
 		ctx.GlobalString(firehoseGenesisFileFlag.Name),
-		func() interface{} { return new(core.Genesis) },
+		core.DecodeFirehoseGenesis,
 		firehoseGethVersion,
 	); err != nil {
 		return fmt.Errorf("initializing firehose: %w", err)
@@ -213,6 +428,9 @@ func StartPProf(address string, withMetrics bool) {
 		exp.Exp(metrics.DefaultRegistry)
 	}
 	http.Handle("/memsize/", http.StripPrefix("/memsize", &Memsize))
+	// Live Firehose instrumentation state, to troubleshoot a wedged emission
+	http.Handle("/debug/firehose", firehose.ProbeHandler())
+	expvar.Publish("firehose", expvar.Func(func() interface{} { return firehose.Probe() }))
 	log.Info("Starting pprof server", "addr", fmt.Sprintf("http://%s/debug/pprof", address))
 	go func() {
 		if err := http.ListenAndServe(address, nil); err != nil {
@@ -226,4 +444,6 @@ func StartPProf(address string, withMetrics bool) {
 func Exit() {
 	Handler.StopCPUProfile()
 	Handler.StopGoTrace()
+	firehose.FlushSink()
+	firehose.StopTracing()
 }
@@ -911,8 +911,11 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 		// Update the state with pending changes
 		var root []byte
 		if config.IsByzantium(header.Number) {
-			state.Finalise(true)
+			state.Finalise(true, firehoseContext)
 		} else {
+			// Finalised first so the accounts removed are recorded, the intermediate root
+			// computation has then nothing more to finalise
+			state.Finalise(config.IsEIP158(header.Number), firehoseContext)
 			root = state.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
 		}
 
@@ -959,6 +962,125 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 	return result, nil
 }
 
+// accessListResult is the result of `eth_createAccessList`, Error holds the reason the call
+// failed when executed with the access list.
+type accessListResult struct {
+	Accesslist *types.AccessList `json:"accessList"`
+	Error      string            `json:"error,omitempty"`
+	GasUsed    hexutil.Uint64    `json:"gasUsed"`
+}
+
+// CreateAccessList creates an EIP-2930 access list for the given call, executed on top of
+// the given block, the pending one by default. The returned gas used is the one of the call
+// executed with the access list.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*accessListResult, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+
+	accessList, gasUsed, vmErr, err := AccessList(ctx, s.b, bNrOrHash, args)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &accessListResult{Accesslist: &accessList, GasUsed: hexutil.Uint64(gasUsed)}
+	if vmErr != nil {
+		result.Error = vmErr.Error()
+	}
+	return result, nil
+}
+
+// AccessList creates an access list for the given call out of the state accesses recorded by
+// the Firehose instrumentation, see `firehose.Context.EnableAccessRecording`. The accounts and
+// slots of the access list supplied in `args` are kept, except the sender, the recipient and
+// the precompiled contracts which are always warm.
+//
+// Adding an access list changes the gas available to the call and so possibly its execution
+// path, the call is executed again with the access list until no new access is recorded. It
+// returns the access list, the gas used by the call executed with it and the error of the call
+// if it failed.
+func AccessList(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash, args CallArgs) (acl types.AccessList, gasUsed uint64, vmErr error, err error) {
+	_, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil || err != nil {
+		return nil, 0, nil, err
+	}
+
+	rules := b.ChainConfig().Rules(header.Number)
+	if !rules.IsBerlin {
+		return nil, 0, nil, fmt.Errorf("access lists are not supported before the Berlin fork, block #%d", header.Number)
+	}
+
+	excluded := map[common.Address]struct{}{}
+	if args.From != nil {
+		excluded[*args.From] = struct{}{}
+	} else {
+		excluded[common.Address{}] = struct{}{}
+	}
+	if args.To != nil {
+		excluded[*args.To] = struct{}{}
+	}
+	_, precompiles := vm.DefaultPrecompiles(rules)
+	for _, addr := range precompiles {
+		excluded[addr] = struct{}{}
+	}
+
+	prevList := types.AccessList{}
+	if args.AccessList != nil {
+		for _, tuple := range *args.AccessList {
+			if _, found := excluded[tuple.Address]; !found {
+				prevList = append(prevList, tuple)
+			}
+		}
+	}
+
+	for {
+		args.AccessList = &prevList
+
+		firehoseContext := firehose.NewSpeculativeExecutionContext(0)
+		firehoseContext.EnableAccessRecording()
+
+		result, err := DoCall(ctx, b, args, blockNrOrHash, nil, vm.Config{}, 0, b.RPCGasCap(), firehoseContext)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+
+		// The accounts and slots of the access list are warm, only the new accesses are recorded
+		accesses := firehoseContext.AccessList()
+		if len(accesses) == 0 {
+			return prevList, result.UsedGas, result.Err, nil
+		}
+
+		prevList = mergeAccessLists(prevList, accesses)
+	}
+}
+
+// mergeAccessLists returns `list` extended with the accounts and storage slots of `accesses`
+// it does not hold yet, keeping the order of `list` then of `accesses`.
+func mergeAccessLists(list types.AccessList, accesses types.AccessList) types.AccessList {
+	merged := make(types.AccessList, 0, len(list)+len(accesses))
+	indexes := make(map[common.Address]int, len(list)+len(accesses))
+	slots := make(map[common.Address]map[common.Hash]struct{}, len(list)+len(accesses))
+
+	for _, tuple := range append(append(types.AccessList{}, list...), accesses...) {
+		index, found := indexes[tuple.Address]
+		if !found {
+			merged = append(merged, types.AccessTuple{Address: tuple.Address, StorageKeys: []common.Hash{}})
+			index = len(merged) - 1
+			indexes[tuple.Address], slots[tuple.Address] = index, make(map[common.Hash]struct{})
+		}
+
+		for _, slot := range tuple.StorageKeys {
+			if _, found := slots[tuple.Address][slot]; !found {
+				slots[tuple.Address][slot] = struct{}{}
+				merged[index].StorageKeys = append(merged[index].StorageKeys, slot)
+			}
+		}
+	}
+
+	return merged
+}
+
 func newRevertError(result *core.ExecutionResult) *revertError {
 	reason, errUnpack := abi.UnpackRevert(result.Revert())
 	err := errors.New("execution reverted")
@@ -1019,12 +1141,14 @@ func (s *PublicBlockChainAPI) Execute(ctx context.Context, args CallArgs, blockN
 		accounts = *overrides
 	}
 
-	firehoseContext := firehose.NewSpeculativeExecutionContext(512 * 1024)
-	result, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCGasCap(), firehoseContext)
+	execution := firehose.NewSpeculativeExecution()
+	defer execution.Release()
+
+	result, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCGasCap(), execution.Context())
 
 	// As soon as we have an execution result, we should have a complete Firehose log, so let's return it
 	if result != nil {
-		return firehoseContext.FirehoseLog(), nil
+		return execution.Records(), nil
 	}
 
 	if err != nil {
@@ -1296,23 +1420,24 @@ func (s *PublicBlockChainAPI) rpcMarshalBlock(ctx context.Context, b *types.Bloc
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
-	BlockHash        *common.Hash      `json:"blockHash"`
-	BlockNumber      *hexutil.Big      `json:"blockNumber"`
-	From             common.Address    `json:"from"`
-	Gas              hexutil.Uint64    `json:"gas"`
-	GasPrice         *hexutil.Big      `json:"gasPrice"`
-	Hash             common.Hash       `json:"hash"`
-	Input            hexutil.Bytes     `json:"input"`
-	Nonce            hexutil.Uint64    `json:"nonce"`
-	To               *common.Address   `json:"to"`
-	TransactionIndex *hexutil.Uint64   `json:"transactionIndex"`
-	Value            *hexutil.Big      `json:"value"`
-	Type             hexutil.Uint64    `json:"type"`
-	Accesses         *types.AccessList `json:"accessList,omitempty"`
-	ChainID          *hexutil.Big      `json:"chainId,omitempty"`
-	V                *hexutil.Big      `json:"v"`
-	R                *hexutil.Big      `json:"r"`
-	S                *hexutil.Big      `json:"s"`
+	BlockHash         *common.Hash                 `json:"blockHash"`
+	BlockNumber       *hexutil.Big                 `json:"blockNumber"`
+	From              common.Address               `json:"from"`
+	Gas               hexutil.Uint64               `json:"gas"`
+	GasPrice          *hexutil.Big                 `json:"gasPrice"`
+	Hash              common.Hash                  `json:"hash"`
+	Input             hexutil.Bytes                `json:"input"`
+	Nonce             hexutil.Uint64               `json:"nonce"`
+	To                *common.Address              `json:"to"`
+	TransactionIndex  *hexutil.Uint64              `json:"transactionIndex"`
+	Value             *hexutil.Big                 `json:"value"`
+	Type              hexutil.Uint64               `json:"type"`
+	Accesses          *types.AccessList            `json:"accessList,omitempty"`
+	ChainID           *hexutil.Big                 `json:"chainId,omitempty"`
+	AuthorizationList []types.SetCodeAuthorization `json:"authorizationList,omitempty"`
+	V                 *hexutil.Big                 `json:"v"`
+	R                 *hexutil.Big                 `json:"r"`
+	S                 *hexutil.Big                 `json:"s"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -1350,10 +1475,16 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		result.BlockNumber = (*hexutil.Big)(new(big.Int).SetUint64(blockNumber))
 		result.TransactionIndex = (*hexutil.Uint64)(&index)
 	}
-	if tx.Type() == types.AccessListTxType {
+	switch tx.Type() {
+	case types.AccessListTxType:
+		al := tx.AccessList()
+		result.Accesses = &al
+		result.ChainID = (*hexutil.Big)(tx.ChainId())
+	case types.SetCodeTxType:
 		al := tx.AccessList()
 		result.Accesses = &al
 		result.ChainID = (*hexutil.Big)(tx.ChainId())
+		result.AuthorizationList = tx.SetCodeAuthorizations()
 	}
 	return result
 }
@@ -0,0 +1,74 @@
+// Package firehosetest holds the fixtures shared by the Firehose golden tests of `core` and of
+// `firehose/itest`: the contracts their scenarios call, the account sending the transactions
+// and the handling of the golden files, along with the `-update` flag regenerating them.
+package firehosetest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var update = flag.Bool("update", false, "Update the golden files of the Firehose golden tests with the actual output")
+
+var (
+	// SenderKey is the key of the account sending the transactions of the golden scenarios.
+	SenderKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+	// Sender is the address of `SenderKey`.
+	Sender = crypto.PubkeyToAddress(SenderKey.PublicKey)
+)
+
+var (
+	// StoreCode stores 1 in slot 0: PUSH1 0x01 PUSH1 0x00 SSTORE STOP
+	StoreCode = common.FromHex("6001600055" + "00")
+
+	// RevertCode reverts without data: PUSH1 0x00 PUSH1 0x00 REVERT
+	RevertCode = common.FromHex("60006000fd")
+
+	// SelfDestructCode self destructs to the caller: CALLER SELFDESTRUCT
+	SelfDestructCode = common.FromHex("33ff")
+
+	// StoreCreationCode is an init code returning `StoreCode` as the runtime code.
+	StoreCreationCode = common.FromHex("65" + "600160005500" + "600052" + "6006601af3")
+)
+
+// CallCode assembles a contract forwarding all its gas to `target` using the call opcode
+// `op`, followed by the `epilogue` code, `STOP` is appended at the end.
+func CallCode(op string, target common.Address, epilogue string) []byte {
+	args := "6000600060006000"
+	if op == "f1" {
+		// CALL has an extra value argument
+		args += "6000"
+	}
+
+	return common.FromHex(args + "73" + common.Bytes2Hex(target[:]) + "5a" + op + epilogue + "00")
+}
+
+// CompareGolden compares the Firehose output `actual` against the golden file `goldenFile`,
+// the golden file being overwritten by `actual` first when the tests run with `-update`.
+func CompareGolden(t testing.TB, goldenFile string, actual []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(goldenFile), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(goldenFile, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("golden file %q not found, run with -update to create it: %v", goldenFile, err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Firehose output differs from golden file %q, run with -update to accept the new output\nhave:\n%s\nwant:\n%s", goldenFile, actual, expected)
+	}
+}
@@ -25,6 +25,7 @@ var Modules = map[string]string{
 	"ethash":     EthashJs,
 	"debug":      DebugJs,
 	"eth":        EthJs,
+	"firehose":   FirehoseJs,
 	"miner":      MinerJs,
 	"net":        NetJs,
 	"personal":   PersonalJs,
@@ -509,6 +510,12 @@ web3._extend({
 			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter],
 			outputFormatter: web3._extend.utils.toDecimal
 		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'eth_createAccessList',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter],
+		}),
 		new web3._extend.Method({
 			name: 'submitTransaction',
 			call: 'eth_submitTransaction',
@@ -779,6 +786,49 @@ web3._extend({
 });
 `
 
+const FirehoseJs = `
+web3._extend({
+	property: 'firehose',
+	methods: [
+		new web3._extend.Method({
+			name: 'getBlock',
+			call: 'firehose_getBlock',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getCallsByAddress',
+			call: 'firehose_getCallsByAddress',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getTransfers',
+			call: 'firehose_getTransfers',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'verifyRange',
+			call: 'firehose_verifyRange',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'backfillStatus',
+			getter: 'firehose_backfillStatus'
+		}),
+		new web3._extend.Property({
+			name: 'sinkStatus',
+			getter: 'firehose_sinkStatus'
+		}),
+	]
+});
+`
+
 const AccountingJs = `
 web3._extend({
 	property: 'accounting',
@@ -50,6 +50,8 @@ type Config struct {
 	GasPrice  *big.Int       // Minimum gas price for mining a transaction
 	Recommit  time.Duration  // The time interval for miner to re-create mining work.
 	Noverify  bool           // Disable remote mining solution verification(only useful in ethash).
+
+	DeterministicTime bool // Derive block timestamps from the parent block only (only useful in clique, deterministic developer mode).
 }
 
 // Miner creates blocks and searches for proof-of-work values.
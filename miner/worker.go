@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/log"
@@ -92,6 +93,11 @@ type environment struct {
 	header   *types.Header
 	txs      []*types.Transaction
 	receipts []*types.Receipt
+
+	firehoseProposed *firehose.ProposedBlock // records of the transactions applied, nil when proposed blocks are not emitted
+
+	evm         *vm.EVM        // EVM re-used across the transactions applied, created for evmCoinbase
+	evmCoinbase common.Address // coinbase of the block context of evm
 }
 
 // task contains all information for consensus engine sealing and result submitting.
@@ -697,11 +703,19 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 	// Keep track of transactions which return errors so they can be removed
 	env.tcount = 0
 
+	if firehose.Enabled && firehose.ProposedBlocksEnabled {
+		env.firehoseProposed = firehose.NewProposedBlock()
+	}
+
 	// Swap out the old work with the new one, terminating any leftover prefetcher
 	// processes in the mean time and starting a new one.
 	if w.current != nil && w.current.state != nil {
 		w.current.state.StopPrefetcher()
 	}
+	if w.current != nil {
+		// The sealing task is discarded, its Firehose buffers are re-used by the next ones
+		w.current.firehoseProposed.Release()
+	}
 	w.current = env
 	return nil
 }
@@ -761,11 +775,29 @@ func (w *worker) updateSnapshot() {
 func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Address) ([]*types.Log, error) {
 	snap := w.current.state.Snapshot()
 
-	receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &coinbase, w.current.gasPool, w.current.state, w.current.header, tx, &w.current.header.GasUsed, *w.chain.GetVMConfig(), firehose.NoOpContext)
+	txFirehoseContext := firehose.NoOpContext
+	if w.current.firehoseProposed != nil {
+		// The sender was already recovered when the transaction entered the pool
+		from, _ := types.Sender(w.current.signer, tx)
+		txFirehoseContext = w.current.firehoseProposed.StartTransaction(tx, uint(w.current.tcount), from)
+	}
+
+	// The EVM is created once per block, it's reset with each transaction applied
+	if w.current.evm == nil || w.current.evmCoinbase != coinbase {
+		blockContext := core.NewEVMBlockContext(w.current.header, w.chain, &coinbase)
+		w.current.evm = vm.NewEVM(blockContext, vm.TxContext{}, w.current.state, w.chainConfig, *w.chain.GetVMConfig())
+		w.current.evmCoinbase = coinbase
+	}
+
+	receipt, result, _, err := core.ApplyTransactionWithResult(w.current.evm, w.chainConfig, w.chain, &coinbase, w.current.gasPool, w.current.state, w.current.header, tx, &w.current.header.GasUsed, txFirehoseContext, false)
+	w.current.firehoseProposed.EndTransaction(receipt)
 	if err != nil {
 		w.current.state.RevertToSnapshot(snap)
 		return nil, err
 	}
+	if result.Failed() {
+		log.Trace("Included failed transaction", "hash", tx.Hash(), "gas", result.UsedGas, "err", result.Err)
+	}
 	w.current.txs = append(w.current.txs, tx)
 	w.current.receipts = append(w.current.receipts, receipt)
 
@@ -945,6 +977,11 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	if w.chainConfig.DAOForkSupport && w.chainConfig.DAOForkBlock != nil && w.chainConfig.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(env.state, firehose.NoOpContext)
 	}
+	if w.chainConfig.IsPrague(header.Number) {
+		blockContext := core.NewEVMBlockContext(header, w.chain, &header.Coinbase)
+		vmenv := vm.NewEVM(blockContext, vm.TxContext{}, env.state, w.chainConfig, *w.chain.GetVMConfig())
+		core.ProcessParentBlockHash(header.ParentHash, vmenv, env.state, firehose.NoOpContext)
+	}
 	// Accumulate the uncles for the current block
 	uncles := make([]*types.Header, 0, 2)
 	commitUncles := func(blocks map[common.Hash]*types.Block) {
@@ -1034,6 +1071,10 @@ func (w *worker) commit(uncles []*types.Header, interval func(), update bool, st
 				"gas", block.GasUsed(), "fees", totalFees(block, receipts),
 				"elapsed", common.PrettyDuration(time.Since(start)))
 
+			if err := w.emitFirehoseProposedBlock(block); err != nil {
+				log.Error("Failed emitting Firehose proposed block", "number", block.Number(), "err", err)
+			}
+
 		case <-w.exitCh:
 			log.Info("Worker has exited")
 		}
@@ -1044,6 +1085,25 @@ func (w *worker) commit(uncles []*types.Header, interval func(), update bool, st
 	return nil
 }
 
+// emitFirehoseProposedBlock emits the candidate `block`, assembled out of the current
+// environment, as a Firehose proposed block, if enabled.
+func (w *worker) emitFirehoseProposedBlock(block *types.Block) error {
+	if w.current.firehoseProposed == nil {
+		return nil
+	}
+
+	totalDifficulty := w.chain.GetTd(block.ParentHash(), block.NumberU64()-1)
+	if totalDifficulty != nil {
+		totalDifficulty = new(big.Int).Add(totalDifficulty, block.Difficulty())
+	}
+
+	return w.current.firehoseProposed.Emit(block, totalDifficulty, func(ctx *firehose.Context) {
+		// The rewards were applied without being recorded while assembling the block, they are
+		// applied again on a copy of the state to record them
+		w.engine.Finalize(w.chain, block.Header(), w.current.state.Copy(), block.Transactions(), block.Uncles(), ctx)
+	})
+}
+
 // copyReceipts makes a deep copy of the given receipts.
 func copyReceipts(receipts []*types.Receipt) []*types.Receipt {
 	result := make([]*types.Receipt, len(receipts))
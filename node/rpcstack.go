@@ -528,6 +528,9 @@ func RegisterApisFromWhitelist(apis []rpc.API, modules []string, srv *rpc.Server
 	}
 	// Register all the APIs exposed by the services
 	for _, api := range apis {
+		if api.IPCOnly {
+			continue
+		}
 		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
 				return err
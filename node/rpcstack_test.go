@@ -32,6 +32,31 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// TestRegisterApisFromWhitelist_IPCOnly makes sure the IPC only APIs are never registered on
+// the http and websocket servers, even when their module is whitelisted.
+func TestRegisterApisFromWhitelist_IPCOnly(t *testing.T) {
+	apis := []rpc.API{
+		{Namespace: "public", Version: "1.0", Service: new(NoopLifecycle), Public: true},
+		{Namespace: "ipconly", Version: "1.0", Service: new(NoopLifecycle), IPCOnly: true},
+	}
+
+	for _, modules := range [][]string{nil, {"public", "ipconly"}} {
+		srv := rpc.NewServer()
+		assert.NoError(t, RegisterApisFromWhitelist(apis, modules, srv, false))
+
+		client := rpc.DialInProc(srv)
+		supported, err := client.SupportedModules()
+		assert.NoError(t, err)
+		assert.Contains(t, supported, "public")
+		assert.NotContains(t, supported, "ipconly")
+		client.Close()
+	}
+
+	bad, available := checkModuleAvailability([]string{"ipconly"}, apis)
+	assert.Equal(t, []string{"ipconly"}, bad)
+	assert.Equal(t, []string{"public"}, available)
+}
+
 // TestCorsHandler makes sure CORS are properly handled on the http server.
 func TestCorsHandler(t *testing.T) {
 	srv := createAndStartServer(t, &httpConfig{CorsAllowedOrigins: []string{"test", "test.com"}}, false, &wsConfig{})
@@ -104,10 +104,8 @@ compile_fuzzer tests/fuzzers/abi        Fuzz fuzzAbi
 compile_fuzzer tests/fuzzers/les        Fuzz fuzzLes
 
 compile_fuzzer tests/fuzzers/bls12381  FuzzG1Add fuzz_g1_add
-compile_fuzzer tests/fuzzers/bls12381  FuzzG1Mul fuzz_g1_mul
 compile_fuzzer tests/fuzzers/bls12381  FuzzG1MultiExp fuzz_g1_multiexp
 compile_fuzzer tests/fuzzers/bls12381  FuzzG2Add fuzz_g2_add
-compile_fuzzer tests/fuzzers/bls12381  FuzzG2Mul fuzz_g2_mul
 compile_fuzzer tests/fuzzers/bls12381  FuzzG2MultiExp fuzz_g2_multiexp
 compile_fuzzer tests/fuzzers/bls12381  FuzzPairing fuzz_pairing
 compile_fuzzer tests/fuzzers/bls12381  FuzzMapG1 fuzz_map_g1
@@ -20,6 +20,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -244,16 +245,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, new(EthashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -325,15 +326,37 @@ type ChainConfig struct {
 	IstanbulBlock       *big.Int `json:"istanbulBlock,omitempty"`       // Istanbul switch block (nil = no fork, 0 = already on istanbul)
 	MuirGlacierBlock    *big.Int `json:"muirGlacierBlock,omitempty"`    // Eip-2384 (bomb delay) switch block (nil = no fork, 0 = already activated)
 	BerlinBlock         *big.Int `json:"berlinBlock,omitempty"`         // Berlin switch block (nil = no fork, 0 = already on berlin)
+	ShanghaiBlock       *big.Int `json:"shanghaiBlock,omitempty"`       // Shanghai switch block (nil = no fork, 0 = already on shanghai)
+	CancunBlock         *big.Int `json:"cancunBlock,omitempty"`         // Cancun switch block (nil = no fork, 0 = already on cancun), without blob transactions
+	PragueBlock         *big.Int `json:"pragueBlock,omitempty"`         // Prague switch block (nil = no fork, 0 = already on prague)
 
 	YoloV3Block *big.Int `json:"yoloV3Block,omitempty"` // YOLO v3: Gas repricings TODO @holiman add EIP references
 	EWASMBlock  *big.Int `json:"ewasmBlock,omitempty"`  // EWASM switch block (nil = no fork, 0 = already activated)
 
+	// EIP2537Block activates the BLS12-381 precompiles (https://eips.ethereum.org/EIPS/eip-2537), it's
+	// independent from the other forks for the variant chains needing BLS operations
+	EIP2537Block *big.Int `json:"eip2537Block,omitempty"` // EIP2537 switch block (nil = no fork, 0 = already activated)
+
+	// RIP7212Block activates the secp256r1 signature verification precompile (https://github.com/ethereum/RIPs/blob/master/RIPS/rip-7212.md)
+	// for the variant chains adopting it
+	RIP7212Block *big.Int `json:"rip7212Block,omitempty"` // RIP7212 switch block (nil = no fork, 0 = already activated)
+
+	// OpcodeOverrides customizes the instruction set of the variant chains diverging from the
+	// Ethereum one (e.g. L2s and sidechains), keyed by opcode name (e.g. "SELFDESTRUCT"). They
+	// apply on top of the instruction set of the active fork, from the genesis block.
+	OpcodeOverrides map[string]*OpcodeOverride `json:"opcodeOverrides,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
 }
 
+// OpcodeOverride is the override of a single opcode of the instruction set.
+type OpcodeOverride struct {
+	ConstantGas *uint64 `json:"constantGas,omitempty"` // Replaces the constant gas cost of the opcode (nil = unchanged)
+	Disabled    bool    `json:"disabled,omitempty"`    // Whether the opcode is removed from the instruction set, executing it fails as an invalid opcode
+}
+
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
 type EthashConfig struct{}
 
@@ -364,7 +387,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, Berlin: %v, YOLO v3: %v, Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, Berlin: %v, Shanghai: %v, Cancun: %v, Prague: %v, YOLO v3: %v, EIP-2537: %v, RIP-7212: %v, Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -378,7 +401,12 @@ func (c *ChainConfig) String() string {
 		c.IstanbulBlock,
 		c.MuirGlacierBlock,
 		c.BerlinBlock,
+		c.ShanghaiBlock,
+		c.CancunBlock,
+		c.PragueBlock,
 		c.YoloV3Block,
+		c.EIP2537Block,
+		c.RIP7212Block,
 		engine,
 	)
 }
@@ -440,11 +468,99 @@ func (c *ChainConfig) IsBerlin(num *big.Int) bool {
 	return isForked(c.BerlinBlock, num) || isForked(c.YoloV3Block, num)
 }
 
+// IsShanghai returns whether num is either equal to the Shanghai fork block or greater.
+func (c *ChainConfig) IsShanghai(num *big.Int) bool {
+	return isForked(c.ShanghaiBlock, num)
+}
+
+// IsCancun returns whether num is either equal to the Cancun fork block or greater.
+func (c *ChainConfig) IsCancun(num *big.Int) bool {
+	return isForked(c.CancunBlock, num)
+}
+
+// IsPrague returns whether num is either equal to the Prague fork block or greater.
+func (c *ChainConfig) IsPrague(num *big.Int) bool {
+	return isForked(c.PragueBlock, num)
+}
+
 // IsEWASM returns whether num represents a block number after the EWASM fork
 func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
+// IsEIP2537 returns whether num is either equal to the EIP2537 (BLS12-381 precompiles) fork block or greater.
+func (c *ChainConfig) IsEIP2537(num *big.Int) bool {
+	return isForked(c.EIP2537Block, num)
+}
+
+// IsRIP7212 returns whether num is either equal to the RIP7212 (secp256r1 precompile) fork block or greater.
+func (c *ChainConfig) IsRIP7212(num *big.Int) bool {
+	return isForked(c.RIP7212Block, num)
+}
+
+// ForksActivatedAt returns the names of the forks activated exactly at block num, in their
+// activation order, the forks already active before num are not returned.
+func (c *ChainConfig) ForksActivatedAt(num *big.Int) []string {
+	var forks []string
+	for _, fork := range c.forkBlocks() {
+		if fork.block != nil && fork.block.Cmp(num) == 0 {
+			forks = append(forks, fork.name)
+		}
+	}
+	return forks
+}
+
+// ForkBlocks returns the distinct blocks at which at least one fork is activated, in
+// ascending order. The rules of the chain are the same for all the blocks between two
+// consecutive fork blocks.
+func (c *ChainConfig) ForkBlocks() []*big.Int {
+	var blocks []*big.Int
+	for _, fork := range c.forkBlocks() {
+		if fork.block != nil {
+			blocks = append(blocks, fork.block)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Cmp(blocks[j]) < 0 })
+
+	distinct := blocks[:0]
+	for _, block := range blocks {
+		if len(distinct) == 0 || distinct[len(distinct)-1].Cmp(block) != 0 {
+			distinct = append(distinct, block)
+		}
+	}
+	return distinct
+}
+
+type forkBlock struct {
+	name  string
+	block *big.Int
+}
+
+// forkBlocks returns the fork blocks of the config, nil when a fork is not scheduled, in
+// their activation order.
+func (c *ChainConfig) forkBlocks() []forkBlock {
+	return []forkBlock{
+		{"homestead", c.HomesteadBlock},
+		{"daoFork", c.DAOForkBlock},
+		{"eip150", c.EIP150Block},
+		{"eip155", c.EIP155Block},
+		{"eip158", c.EIP158Block},
+		{"byzantium", c.ByzantiumBlock},
+		{"constantinople", c.ConstantinopleBlock},
+		{"petersburg", c.PetersburgBlock},
+		{"istanbul", c.IstanbulBlock},
+		{"muirGlacier", c.MuirGlacierBlock},
+		{"berlin", c.BerlinBlock},
+		{"shanghai", c.ShanghaiBlock},
+		{"cancun", c.CancunBlock},
+		{"prague", c.PragueBlock},
+		{"yoloV3", c.YoloV3Block},
+		{"ewasm", c.EWASMBlock},
+		{"eip2537", c.EIP2537Block},
+		{"rip7212", c.RIP7212Block},
+	}
+}
+
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
 func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
@@ -484,6 +600,9 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 		{name: "istanbulBlock", block: c.IstanbulBlock},
 		{name: "muirGlacierBlock", block: c.MuirGlacierBlock, optional: true},
 		{name: "berlinBlock", block: c.BerlinBlock},
+		{name: "shanghaiBlock", block: c.ShanghaiBlock},
+		{name: "cancunBlock", block: c.CancunBlock, optional: true},
+		{name: "pragueBlock", block: c.PragueBlock, optional: true},
 	} {
 		if lastFork.name != "" {
 			// Next one must be higher number
@@ -550,15 +669,64 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.BerlinBlock, newcfg.BerlinBlock, head) {
 		return newCompatError("Berlin fork block", c.BerlinBlock, newcfg.BerlinBlock)
 	}
+	if isForkIncompatible(c.ShanghaiBlock, newcfg.ShanghaiBlock, head) {
+		return newCompatError("Shanghai fork block", c.ShanghaiBlock, newcfg.ShanghaiBlock)
+	}
+	if isForkIncompatible(c.CancunBlock, newcfg.CancunBlock, head) {
+		return newCompatError("Cancun fork block", c.CancunBlock, newcfg.CancunBlock)
+	}
+	if isForkIncompatible(c.PragueBlock, newcfg.PragueBlock, head) {
+		return newCompatError("Prague fork block", c.PragueBlock, newcfg.PragueBlock)
+	}
 	if isForkIncompatible(c.YoloV3Block, newcfg.YoloV3Block, head) {
 		return newCompatError("YOLOv3 fork block", c.YoloV3Block, newcfg.YoloV3Block)
 	}
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.EIP2537Block, newcfg.EIP2537Block, head) {
+		return newCompatError("EIP2537 fork block", c.EIP2537Block, newcfg.EIP2537Block)
+	}
+	if isForkIncompatible(c.RIP7212Block, newcfg.RIP7212Block, head) {
+		return newCompatError("RIP7212 fork block", c.RIP7212Block, newcfg.RIP7212Block)
+	}
+	// The opcode overrides apply from the genesis block, changing them once a block was
+	// processed requires to rewind to it
+	if isForked(common.Big1, head) && !opcodeOverridesEqual(c.OpcodeOverrides, newcfg.OpcodeOverrides) {
+		return newCompatError(OpcodeOverridesCompatibility, common.Big0, common.Big0)
+	}
 	return nil
 }
 
+// OpcodeOverridesCompatibility is the `ConfigCompatError.What` of a change of the opcode
+// overrides, its rewind to the genesis block being the only one required by a change
+// applying from it.
+const OpcodeOverridesCompatibility = "opcode overrides"
+
+// opcodeOverridesEqual returns whether the opcode overrides `x` and `y` define the same
+// instruction set changes.
+func opcodeOverridesEqual(x, y map[string]*OpcodeOverride) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for name, override := range x {
+		other, ok := y[name]
+		if !ok || (override == nil) != (other == nil) {
+			return false
+		}
+		if override == nil {
+			continue
+		}
+		if override.Disabled != other.Disabled || (override.ConstantGas == nil) != (other.ConstantGas == nil) {
+			return false
+		}
+		if override.ConstantGas != nil && *override.ConstantGas != *other.ConstantGas {
+			return false
+		}
+	}
+	return true
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -623,7 +791,8 @@ type Rules struct {
 	ChainID                                                 *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158               bool
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
-	IsBerlin                                                bool
+	IsBerlin, IsShanghai, IsCancun, IsPrague                bool
+	IsEIP2537, IsRIP7212                                    bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -643,5 +812,10 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsPetersburg:     c.IsPetersburg(num),
 		IsIstanbul:       c.IsIstanbul(num),
 		IsBerlin:         c.IsBerlin(num),
+		IsShanghai:       c.IsShanghai(num),
+		IsCancun:         c.IsCancun(num),
+		IsPrague:         c.IsPrague(num),
+		IsEIP2537:        c.IsEIP2537(num),
+		IsRIP7212:        c.IsRIP7212(num),
 	}
 }
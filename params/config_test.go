@@ -87,6 +87,29 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{OpcodeOverrides: map[string]*OpcodeOverride{"SELFDESTRUCT": {Disabled: true}}},
+			new:     &ChainConfig{OpcodeOverrides: map[string]*OpcodeOverride{"SELFDESTRUCT": {Disabled: false}}},
+			head:    0,
+			wantErr: nil,
+		},
+		{
+			stored:  &ChainConfig{OpcodeOverrides: map[string]*OpcodeOverride{"SELFDESTRUCT": {Disabled: true}}},
+			new:     &ChainConfig{OpcodeOverrides: map[string]*OpcodeOverride{"SELFDESTRUCT": {Disabled: true}}},
+			head:    10,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{OpcodeOverrides: map[string]*OpcodeOverride{"SELFDESTRUCT": {Disabled: true}}},
+			new:    &ChainConfig{OpcodeOverrides: map[string]*OpcodeOverride{"SELFDESTRUCT": {ConstantGas: new(uint64)}}},
+			head:   10,
+			wantErr: &ConfigCompatError{
+				What:         OpcodeOverridesCompatibility,
+				StoredConfig: big.NewInt(0),
+				NewConfig:    big.NewInt(0),
+				RewindTo:     0,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -96,3 +119,37 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckConfigForkOrder_EIP2537Cancun(t *testing.T) {
+	config := *TestChainConfig
+	config.CancunBlock = big.NewInt(10)
+	if err := config.CheckConfigForkOrder(); err == nil {
+		t.Fatal("expected an error with Cancun enabled before Shanghai")
+	}
+
+	config.ShanghaiBlock = big.NewInt(5)
+	if err := config.CheckConfigForkOrder(); err != nil {
+		t.Fatalf("unexpected error with Cancun only: %v", err)
+	}
+
+	// The BLS12-381 precompiles follow the point evaluation one, both are activated together
+	config.EIP2537Block = big.NewInt(0)
+	if err := config.CheckConfigForkOrder(); err != nil {
+		t.Fatalf("unexpected error with both EIP-2537 and Cancun activated: %v", err)
+	}
+}
+
+func TestForksActivatedAt(t *testing.T) {
+	config := *TestChainConfig
+	config.BerlinBlock, config.ShanghaiBlock, config.RIP7212Block = big.NewInt(5), big.NewInt(10), big.NewInt(10)
+
+	if forks := config.ForksActivatedAt(big.NewInt(10)); !reflect.DeepEqual(forks, []string{"shanghai", "rip7212"}) {
+		t.Errorf("forks activated at 10 mismatch: have %v", forks)
+	}
+	if forks := config.ForksActivatedAt(big.NewInt(5)); !reflect.DeepEqual(forks, []string{"berlin"}) {
+		t.Errorf("forks activated at 5 mismatch: have %v", forks)
+	}
+	if forks := config.ForksActivatedAt(big.NewInt(6)); len(forks) != 0 {
+		t.Errorf("forks activated at 6 mismatch: have %v", forks)
+	}
+}
@@ -21,14 +21,10 @@ import (
 )
 
 const (
-	VersionMajor = 1       // Major version component of the current release
-	VersionMinor = 10      // Minor version component of the current release
-	VersionPatch = 1       // Patch version component of the current release
-	VersionMeta  = "fh2.4" // Version metadata to append to the version string
-
-	FirehoseVersionMajor = 2
-	FirehoseVersionMinor = 4
-	Variant              = "geth"
+	VersionMajor = 1        // Major version component of the current release
+	VersionMinor = 10       // Minor version component of the current release
+	VersionPatch = 1        // Patch version component of the current release
+	VersionMeta  = "fh2.50" // Version metadata to append to the version string
 )
 
 // Version holds the textual version string.
@@ -45,10 +41,6 @@ var VersionWithMeta = func() string {
 	return v
 }()
 
-func FirehoseVersion() string {
-	return fmt.Sprintf("%d.%d", FirehoseVersionMajor, FirehoseVersionMinor)
-}
-
 // ArchiveVersion holds the textual version string used for Geth archives.
 // e.g. "1.8.11-dea1ce05" for stable releases, or
 //
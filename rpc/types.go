@@ -33,6 +33,7 @@ type API struct {
 	Version   string      // api version for DApp's
 	Service   interface{} // receiver instance which holds the methods
 	Public    bool        // indication if the methods must be considered safe for public use
+	IPCOnly   bool        // indication if the methods must only be served on the IPC and in-process endpoints
 }
 
 // Error wraps RPC errors, which contain an error code in addition to the message.